@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/db"
+	"excavation_service/internal/app/diagnostics"
+	"excavation_service/internal/app/logging"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/preflight"
+	"excavation_service/internal/app/queue"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/search"
+	"excavation_service/internal/app/secrets"
+	"excavation_service/internal/app/tracing"
+	"excavation_service/internal/app/week"
+	"excavation_service/migrations"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+// app はサブコマンドが共有する設定とロガーです。サブコマンドの終了時にcloseを呼び出してください。
+type app struct {
+	cfg     *config.Config
+	logger  *slog.Logger
+	closers []func()
+
+	sqlDB    *sql.DB // openSQLで接続したDB。接続に失敗した場合はsqlDBErr
+	sqlDBErr error
+}
+
+// setupは設定を読み込み、ロガー・秘密情報・トレース・診断用エンドポイントを準備します。
+// requiredにはサブコマンドに必要な設定のキーを指定します。
+func setup(cmd *cobra.Command, loader *config.Loader, required ...string) (*app, error) {
+	cfg, err := loader.Load(required...)
+	if err != nil {
+		return nil, err
+	}
+	// --verbose/--quietはLOG_LEVELより優先する
+	if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+		cfg.LogLevel = "debug"
+	}
+	if quiet(cmd) {
+		cfg.LogLevel = "warn"
+	}
+	logger := logging.Setup(cfg.LogLevel, cfg.LogFormat)
+	week.SetLocation(cfg.Location)
+
+	ctx := cmd.Context()
+	if err := cfg.ResolveSecrets(ctx, secrets.NewResolver(cfg.SecretsCacheTTL)); err != nil {
+		return nil, fmt.Errorf("resolve secrets: %w", err)
+	}
+	logging.AddSecrets(cfg.Secrets()...)
+
+	// PPROF_ADDRを設定すると、実行中のプロファイルを取得できる
+	diagnostics.Start(logger, cfg.PprofAddr)
+
+	shutdownTracing, err := tracing.Setup(ctx, "excavation-"+cmd.Name())
+	if err != nil {
+		return nil, fmt.Errorf("setup tracing: %w", err)
+	}
+	a := &app{cfg: cfg, logger: logger}
+	a.onClose(func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("トレースの送信に失敗しました", "error", err)
+		}
+	})
+	return a, nil
+}
+
+// quietは--quietが指定され、進捗などの表示を抑えるべきかを返します。
+func quiet(cmd *cobra.Command) bool {
+	q, _ := cmd.Flags().GetBool("quiet")
+	return q
+}
+
+// actorは監査ログに記録する操作者です。--actorを指定しなければcli:<OSのユーザー名>です。
+func actor(cmd *cobra.Command) string {
+	if a, _ := cmd.Flags().GetString("actor"); a != "" {
+		return a
+	}
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return "cli:" + name
+	}
+	return "cli"
+}
+
+// auditは管理操作を監査ログに記録します。操作は完了しているため、記録に失敗してもエラーのログを出すのみとします。
+func (a *app) audit(cmd *cobra.Command, db *gorm.DB, action, targetType string, targetID, before, after interface{}) {
+	err := repository.NewAuditRepository(db).WithContext(cmd.Context()).Record(actor(cmd), action, targetType, targetID, before, after)
+	if err != nil {
+		a.logger.Error("監査ログの記録に失敗しました", "action", action, "error", err)
+	}
+}
+
+// userNotifierは通知先が"line:<ユーザーID>"の通知をLINEで、"slack:<WebhookのURL>"の通知をSlackで、
+// それ以外をfallbackで送るNotifierを返します。
+// ウォッチの通知やダイジェストのように、ユーザーごとに受け取り方を選べる通知に使います。
+func (a *app) userNotifier(fallback notify.Notifier) notify.Notifier {
+	return notify.NewRouter(fallback).
+		Route(notify.LINEScheme, notify.NewLINE(a.cfg.LINEChannelAccessToken)).
+		Route(notify.SlackScheme, notify.NewSlackWebhook())
+}
+
+// storeIndexはMEILISEARCH_URLが設定されていれば店舗の検索インデックスを返します。設定されていなければnilです。
+func (a *app) storeIndex() *search.Meilisearch {
+	if a.cfg.MeilisearchURL == "" {
+		return nil
+	}
+	return search.NewMeilisearch(a.cfg.MeilisearchURL, a.cfg.MeilisearchAPIKey, a.cfg.MeilisearchIndex)
+}
+
+// topicQueueはTOPIC_QUEUEで選んだ発掘の依頼のキューを返します。postgresの場合はgormDBのtopic_queueテーブルを使います。
+func (a *app) topicQueue(ctx context.Context, gormDB *gorm.DB) (queue.Queue, error) {
+	if a.cfg.TopicQueue == "postgres" {
+		if a.cfg.TopicQueueLease <= 0 {
+			return nil, fmt.Errorf("TOPIC_QUEUE_LEASE must be positive")
+		}
+		return queue.NewPostgres(gormDB, a.logger, a.cfg.TopicQueueLease, a.cfg.TopicQueueMaxAttempts), nil
+	}
+	if a.cfg.TopicQueueURL == "" {
+		return nil, fmt.Errorf("TOPIC_QUEUE_URL is required when TOPIC_QUEUE=sqs (env TOPIC_QUEUE_URL or flag --topic-queue-url)")
+	}
+	return queue.NewSQS(ctx, a.cfg.TopicQueueURL, a.cfg.SQSEndpoint)
+}
+
+// isTerminalはfが端末(文字デバイス)かを返します。
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (a *app) onClose(f func()) {
+	a.closers = append(a.closers, f)
+}
+
+// closeは登録された終了処理を登録とは逆の順に実行します。
+func (a *app) close() {
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+}
+
+// openSQLはDBに接続します。2回目以降は同じ接続(または接続のエラー)を返します。接続はcloseで閉じられます。
+func (a *app) openSQL() (*sql.DB, error) {
+	if a.sqlDB != nil || a.sqlDBErr != nil {
+		return a.sqlDB, a.sqlDBErr
+	}
+	sqlDB, err := db.ConnectDatabase(a.cfg.DatabaseURL, db.Options{
+		SSLMode:     a.cfg.DatabaseSSLMode,
+		SSLRootCert: a.cfg.DatabaseSSLRootCert,
+		Auth:        a.cfg.DatabaseAuth,
+	})
+	if err != nil {
+		a.sqlDBErr = err
+		return nil, err
+	}
+	a.sqlDB = sqlDB
+	a.onClose(func() { sqlDB.Close() })
+	return sqlDB, nil
+}
+
+// preflightは処理を始める前に、DBへの接続と未適用のマイグレーション、およびextraの項目をすべて確認します。
+// 失敗した項目があれば、対処方法を含めて1つのエラーにまとめて返します。
+func (a *app) preflight(cmd *cobra.Command, extra ...preflight.Check) error {
+	checks := append([]preflight.Check{
+		preflight.Database(a.openSQL),
+		preflight.Migrations(a.openSQL, migrations.FS),
+	}, extra...)
+	_, err := preflight.Run(cmd.Context(), checks)
+	return err
+}
+
+// openDBはDBに接続し、GORMから利用できるようにします。接続はcloseで閉じられます。
+func (a *app) openDB() (*gorm.DB, error) {
+	sqlDB, err := a.openSQL()
+	if err != nil {
+		return nil, err
+	}
+	return db.OpenGorm(sqlDB)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/discovery"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/preflight"
+	"excavation_service/internal/app/queue"
+
+	"github.com/spf13/cobra"
+)
+
+func newConsumeCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "consume",
+		Short: "キューから発掘の依頼を受け取り、依頼されたトピックをすぐに発掘します",
+		Long: `TOPIC_QUEUEのキューから他のシステムやscheduleが送った発掘の依頼を受け取り、依頼ごとに発掘を実行します。
+停止(SIGTERM)するまで待ち受けます。依頼はJSONで、登録済みのトピックのIDか、エンティティを指定します。
+
+  {"topic_id": 12}
+  {"entity": "西日暮里", "entity_type": "restaurant", "topic": "西日暮里 カレー"}
+
+エンティティのトピックが未登録なら登録してから発掘します。発掘に失敗した依頼はキューに残り、可視性タイムアウトの後に再試行します。
+キューの可視性タイムアウトは1トピックの発掘にかかる時間より長くしてください。
+
+TOPIC_QUEUE=sqs(既定)ではTOPIC_QUEUE_URLのSQSのキューを使います。TOPIC_QUEUE=postgresではSQSの代わりにDBのtopic_queueテーブルを使い、
+複数のconsumeがSELECT ... FOR UPDATE SKIP LOCKEDで依頼を1件ずつ取得します。TOPIC_QUEUE_LEASEが可視性タイムアウトに当たり、
+TOPIC_QUEUE_MAX_ATTEMPTS回取得しても終わらなかった依頼はfailedにします。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL, config.BraveAPIKey, config.OpenAIAPIKey)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			var checks []preflight.Check
+			if a.cfg.ScrapeRulesFile != "" {
+				checks = append(checks, preflight.ReadableFile("SCRAPE_RULES_FILE", a.cfg.ScrapeRulesFile))
+			}
+			if a.cfg.RawArchive && a.cfg.ExportBucket == "" {
+				checks = append(checks, preflight.WritableDir("EXPORT_DIR", a.cfg.ExportDir))
+			}
+			if err := a.preflight(cmd, checks...); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			q, err := a.topicQueue(cmd.Context(), gormDB)
+			if err != nil {
+				return err
+			}
+
+			a.logger.Info("発掘の依頼の待ち受けを開始しました", "queue", a.cfg.TopicQueue)
+			queue.Consume(cmd.Context(), a.logger, gormDB, q, actor(cmd), func(ctx context.Context, topicID uint) error {
+				runID := discovery.NewRunID()
+				opts, err := a.discoverOptions(ctx, runID, []uint{topicID}, false)
+				if err != nil {
+					return err
+				}
+				a.audit(cmd, gormDB, model.AuditRunDiscover, "run", runID, nil, map[string]interface{}{"topic_ids": []uint{topicID}, "source": "queue"})
+				return discovery.Run(ctx, a.logger.With("run_id", runID), gormDB, opts)
+			})
+			a.logger.Info("発掘の依頼の待ち受けを終了しました")
+			return nil
+		},
+	}
+}
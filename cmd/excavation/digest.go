@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/digest"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/preflight"
+	"excavation_service/internal/app/week"
+
+	"github.com/spf13/cobra"
+)
+
+func newDigestCmd(loader *config.Loader) *cobra.Command {
+	var (
+		weekStr string
+		preview bool
+	)
+	cmd := &cobra.Command{
+		Use:   "digest",
+		Short: "週次ダイジェストメールを購読者に送ります",
+		Long: `指定した週(既定は先週)のスコア上位のトピック、前週から大きく動いたトピック、新しく見つかった店舗をまとめたメールを、
+配信を停止していない購読者に送ります。同じ週のダイジェストを同じ購読者に2回送ることはないため、失敗した場合は再実行できます。
+
+送信方法はMAIL_PROVIDER(log, smtp, sendgrid)で選びます。LINEで受け取る購読者にはLINE_CHANNEL_ACCESS_TOKENのチャネルから送ります。--preview では送信せずにHTMLの本文を標準出力に書き出します。`,
+		Example: `  excavation digest
+  excavation digest --week 2024-06-03 --preview > digest.html`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			// 週の途中では今週のトレンドが揃っていないため、既定は先週とする
+			w := week.Current().AddDate(0, 0, -7)
+			if weekStr != "" {
+				parsed, err := week.Parse(weekStr)
+				if err != nil {
+					return err
+				}
+				w = parsed
+			}
+
+			cfg := a.cfg
+			var checks []preflight.Check
+			var notifier notify.Notifier = notify.LogNotifier{}
+			switch {
+			case preview: // 送信しないため送信方法の設定は不要
+			case cfg.MailProvider == "smtp":
+				checks = append(checks, preflight.Setting("MAIL_FROM", cfg.MailFrom), preflight.Setting("SMTP_ADDR", cfg.SMTPAddr))
+				notifier = notify.NewSMTP(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+			case cfg.MailProvider == "sendgrid":
+				checks = append(checks, preflight.Setting("MAIL_FROM", cfg.MailFrom), preflight.Setting("SENDGRID_API_KEY", cfg.SendGridAPIKey))
+				notifier = notify.NewSendGrid(cfg.SendGridAPIKey, cfg.MailFrom)
+			}
+			if err := a.preflight(cmd, checks...); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+
+			if preview {
+				content, err := digest.Build(cmd.Context(), gormDB, w)
+				if err != nil {
+					return err
+				}
+				msg, err := content.Render("preview", digest.UnsubscribeURL(cfg.PublicBaseURL, "preview"))
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprint(cmd.OutOrStdout(), msg.HTML)
+				return err
+			}
+
+			sent, err := digest.Send(cmd.Context(), a.logger, gormDB, a.userNotifier(notifier), w, cfg.PublicBaseURL)
+			a.logger.Info("週次ダイジェストを送信しました", "week", w.Format(week.Layout), "provider", cfg.MailProvider, "sent", sent)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&weekStr, "week", "", "対象の週 (YYYY-MM-DD、既定は先週)")
+	cmd.Flags().BoolVar(&preview, "preview", false, "送信せずにHTMLの本文を標準出力に書き出す")
+	return cmd
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/discord"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/preflight"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+
+	"github.com/spf13/cobra"
+)
+
+func newDiscordCmd(loader *config.Loader) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "discord",
+		Short: "Discordとの連携を管理します",
+		Long: `Discordのチャンネルへの週間ランキングの投稿と、/trendスラッシュコマンドの登録を行います。
+/trendコマンドにはDISCORD_PUBLIC_KEYを設定したAPIサーバーが応答します。DiscordのアプリケーションのInteractions Endpoint URLには
+<APIサーバーの公開URL>/api/v1/discord/interactions を設定してください。`,
+	}
+	cmd.AddCommand(
+		newDiscordRankingCmd(loader),
+		newDiscordRegisterCmd(loader),
+	)
+	return cmd
+}
+
+func newDiscordRankingCmd(loader *config.Loader) *cobra.Command {
+	var (
+		weekStr string
+		preview bool
+	)
+	cmd := &cobra.Command{
+		Use:   "ranking",
+		Short: "週間ランキングをDiscordのチャンネルに投稿します",
+		Long: `指定した週(既定は先週)のスコア上位のトピックを、DISCORD_WEBHOOK_URLのチャンネルに投稿します。
+--preview では投稿せずに本文を標準出力に書き出します。`,
+		Example: `  excavation discord ranking
+  excavation discord ranking --week 2024-06-03 --preview`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			// 週の途中では今週のトレンドが揃っていないため、既定は先週とする
+			w := week.Current().AddDate(0, 0, -7)
+			if weekStr != "" {
+				parsed, err := week.Parse(weekStr)
+				if err != nil {
+					return err
+				}
+				w = parsed
+			}
+
+			var checks []preflight.Check
+			if !preview {
+				checks = append(checks, preflight.Setting("DISCORD_WEBHOOK_URL", a.cfg.DiscordWebhookURL))
+			}
+			if err := a.preflight(cmd, checks...); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+
+			top, err := repository.NewTrendRepository(gormDB).WithContext(cmd.Context()).ForTenant(model.DefaultTenantID).Top(w, discord.RankingLimit)
+			if err != nil {
+				return err
+			}
+			msg := discord.Ranking(w, top)
+			if preview {
+				_, err := fmt.Fprintf(cmd.OutOrStdout(), "%s\n%s\n", msg.Subject, msg.Body)
+				return err
+			}
+			if err := notify.NewDiscord(a.cfg.DiscordWebhookURL).Send(cmd.Context(), msg); err != nil {
+				return err
+			}
+			a.logger.Info("週間ランキングをDiscordに投稿しました", "week", w.Format(week.Layout), "topics", len(top))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&weekStr, "week", "", "対象の週 (YYYY-MM-DD、既定は先週)")
+	cmd.Flags().BoolVar(&preview, "preview", false, "投稿せずに本文を標準出力に書き出す")
+	return cmd
+}
+
+func newDiscordRegisterCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "register",
+		Short: "/trendスラッシュコマンドをDiscordに登録します",
+		Long: `DISCORD_APPLICATION_IDのアプリケーションに/trendコマンドを登録します。既に登録されているコマンドは置き換えます。
+DISCORD_GUILD_IDを設定するとそのサーバーのみに登録し、すぐに使えるようになります。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, "DISCORD_APPLICATION_ID", "DISCORD_BOT_TOKEN")
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			cfg := a.cfg
+			if err := discord.RegisterCommands(cmd.Context(), cfg.DiscordApplicationID, cfg.DiscordGuildID, cfg.DiscordBotToken); err != nil {
+				return err
+			}
+			a.logger.Info("Discordにスラッシュコマンドを登録しました", "application_id", cfg.DiscordApplicationID, "guild_id", cfg.DiscordGuildID)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/dedup"
+	"excavation_service/internal/app/discovery"
+	"excavation_service/internal/app/fixtures"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/objectstore"
+	"excavation_service/internal/app/preflight"
+
+	"github.com/spf13/cobra"
+)
+
+func newDiscoverCmd(loader *config.Loader) *cobra.Command {
+	var (
+		topicIDs         []uint
+		tenantSlug       string
+		progressInterval time.Duration
+		offline          bool
+		fixturesDir      string
+	)
+	cmd := &cobra.Command{
+		Use:   "discover",
+		Short: "トピックの今週のトレンドを発掘して保存します",
+		Long: `Brave Searchの検索結果から話題の店舗を集め、店舗への言及とGPTのスコアからトピックの今週のトレンドを保存します。
+ウォッチの閾値を超えたトピックは通知されます。cronなどから定期的に実行します。
+
+--topic-id を省略すると有効なすべてのトピックを発掘します。実行中は進捗(終わったトピック数、取得したページ数、残りの見込み時間)を
+端末の標準エラー出力に定期的に表示し、jobsテーブルにも記録します(APIの /api/v1/admin/jobs から参照できます)。
+
+--offline では、Brave Search・食べログ・GPTへのリクエストにローカルのサーバーからフィクスチャを返すため、
+ネットワークやAPIキーなしでパイプライン全体を実行できます。フィクスチャは同梱のもの、または --fixtures のディレクトリを使います。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if fixturesDir != "" && !offline {
+				return fmt.Errorf("--fixtures can only be used with --offline")
+			}
+			// APIキーなどの設定はクロールを始める前にまとめて検証する。オフラインモードではAPIキーは不要
+			required := []string{config.DatabaseURL, config.BraveAPIKey, config.OpenAIAPIKey}
+			if offline {
+				required = required[:1]
+			}
+			a, err := setup(cmd, loader, required...)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if offline {
+				fsys := fixtures.Bundle()
+				if fixturesDir != "" {
+					fsys = os.DirFS(fixturesDir)
+				}
+				srv, err := fixtures.Start(a.logger, fsys)
+				if err != nil {
+					return fmt.Errorf("start fixture server: %w", err)
+				}
+				a.onClose(func() { srv.Close() })
+				discovery.SetTransport(srv.Transport())
+				if a.cfg.BraveAPIKey == "" {
+					a.cfg.BraveAPIKey = "offline"
+				}
+				if a.cfg.OpenAIAPIKey == "" {
+					a.cfg.OpenAIAPIKey = "offline"
+				}
+			}
+
+			// 実行ごとのrun_idをすべてのログに付与する
+			runID := discovery.NewRunID()
+			logger := a.logger.With("run_id", runID)
+			slog.SetDefault(logger)
+
+			var checks []preflight.Check
+			if a.cfg.ScrapeRulesFile != "" {
+				checks = append(checks, preflight.ReadableFile("SCRAPE_RULES_FILE", a.cfg.ScrapeRulesFile))
+			}
+			if a.cfg.RawArchive && a.cfg.ExportBucket == "" {
+				checks = append(checks, preflight.WritableDir("EXPORT_DIR", a.cfg.ExportDir))
+			}
+			if err := a.preflight(cmd, checks...); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			// 進捗の行は対話的な実行でのみ表示する。cronなどからの実行ではジョブの記録のみ更新する
+			var progressOutput io.Writer
+			if !quiet(cmd) && isTerminal(os.Stderr) {
+				progressOutput = os.Stderr
+			}
+			opts, err := a.discoverOptions(cmd.Context(), runID, topicIDs, offline)
+			if err != nil {
+				return err
+			}
+			opts.ProgressInterval, opts.ProgressOutput = progressInterval, progressOutput
+			if tenantSlug != "" {
+				tenant, err := findTenant(cmd.Context(), gormDB, tenantSlug)
+				if err != nil {
+					return err
+				}
+				opts.TenantID = tenant.ID
+			}
+			a.audit(cmd, gormDB, model.AuditRunDiscover, "run", runID, nil, map[string]interface{}{"topic_ids": topicIDs, "tenant": tenantSlug, "offline": offline})
+			return discovery.Run(cmd.Context(), logger, gormDB, opts)
+		},
+	}
+	cmd.Flags().UintSliceVar(&topicIDs, "topic-id", nil, "発掘するトピックのID (複数指定可、既定は有効なすべてのトピック)")
+	cmd.Flags().StringVar(&tenantSlug, "tenant", "", "このテナント(スラッグ)のトピックのみ発掘する (既定はすべてのテナント)")
+	cmd.Flags().BoolVar(&offline, "offline", false, "外部サービスの代わりにフィクスチャを返すサーバーを使う")
+	cmd.Flags().StringVar(&fixturesDir, "fixtures", "", "--offlineで使うフィクスチャのディレクトリ (既定は同梱のフィクスチャ)")
+	cmd.Flags().DurationVar(&progressInterval, "progress-interval", 10*time.Second, "進捗を表示・記録する間隔 (0で表示しない)")
+	return cmd
+}
+
+// discoverOptionsは発掘の実行の設定を返します。offlineの場合はSlackやLINEに通知せず、検索APIのレスポンスも書き出しません。
+func (a *app) discoverOptions(ctx context.Context, runID string, topicIDs []uint, offline bool) (discovery.Options, error) {
+	opts := discovery.Options{
+		RunID:                     runID,
+		TopicIDs:                  topicIDs,
+		BraveAPIKey:               a.cfg.BraveAPIKey,
+		OpenAIAPIKey:              a.cfg.OpenAIAPIKey,
+		ScrapeRulesFile:           a.cfg.ScrapeRulesFile,
+		ScrapeRulesReloadInterval: a.cfg.ScrapeRulesReloadInterval,
+		Sources:                   a.cfg.DiscoverySources,
+		StageWorkers:              a.cfg.DiscoverStageWorkers,
+		Concurrency:               a.cfg.DiscoverConcurrency,
+		HostConcurrency:           a.cfg.DiscoverHostConcurrency,
+		Budget: discovery.Budget{
+			MaxSearchResults: a.cfg.DiscoverMaxResults,
+			MaxMatomePages:   a.cfg.DiscoverMaxMatomePages,
+			MaxListingPages:  a.cfg.DiscoverMaxListingPages,
+			WallClock:        a.cfg.DiscoverFetchTimeout,
+		},
+		Domains: discovery.DomainPolicy{Allowed: a.cfg.DiscoverAllowedDomains, Blocked: a.cfg.DiscoverBlockedDomains},
+	}
+	if index := a.storeIndex(); index != nil {
+		opts.StoreIndex = index
+	}
+	if offline {
+		return opts, nil
+	}
+	if a.cfg.RawArchive {
+		store, err := objectstore.New(ctx, a.cfg.ExportBucket, a.cfg.ExportPrefix, a.cfg.S3Endpoint, a.cfg.ExportDir)
+		if err != nil {
+			return opts, err
+		}
+		opts.RawArchive = store
+	}
+	if a.cfg.DedupRedisURL != "" {
+		set, err := dedup.NewRedis(a.cfg.DedupRedisURL, a.cfg.DedupTTL)
+		if err != nil {
+			return opts, err
+		}
+		opts.Dedup = set
+	}
+	opts.Notifier = a.userNotifier(notify.LogNotifier{})
+	if a.cfg.SlackWebhookURL != "" || len(a.cfg.SlackAreaWebhooks) > 0 {
+		slack := notify.NewSlack(a.cfg.SlackWebhookURL, a.cfg.SlackAreaWebhooks)
+		opts.RunNotifier, opts.RunNotifyAreas = slack, slack.Areas()
+	}
+	return opts, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/discovery"
+
+	"github.com/spf13/cobra"
+)
+
+func newEnrichCmd(loader *config.Loader) *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "enrich",
+		Short: "保存済みの店舗の詳細情報を店舗ページから収集します",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			n, err := discovery.Enrich(cmd.Context(), a.logger, gormDB, limit)
+			if err != nil {
+				return err
+			}
+			a.logger.Info("店舗情報の収集が完了しました", "stores", n)
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 100, "1回に処理する店舗の最大数 (更新日時が古い順)")
+	return cmd
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"excavation_service/internal/app/analytics"
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/logging"
+	"excavation_service/internal/app/objectstore"
+	"excavation_service/internal/app/preflight"
+	"excavation_service/internal/app/week"
+
+	"github.com/spf13/cobra"
+)
+
+func newExportCmd(loader *config.Loader) *cobra.Command {
+	var (
+		format     string
+		from, to   string
+		withStores bool
+		output     string
+	)
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "トレンドをParquet・CSV・JSONでエクスポートします",
+		Long: `--format parquet (既定) では、トレンド・店舗・クロール統計をParquet形式でオブジェクトストレージ(またはEXPORT_DIR)へエクスポートします。
+直近EXPORT_WEEKS週(既定は2週、遅れて保存されたトレンドを拾うため)のパーティションを毎回書き直します。
+
+--format csv または json では、トレンドを週・ID順にファイル(--output)か標準出力へ書き出します。
+--from/--to で週の範囲を、--with-stores で言及された店舗ごとの行への展開を指定できます。`,
+		Example: `  excavation export --format csv --from 2024-01-01 > trends.csv
+  excavation export --format json --from 2024-01-01 --to 2024-03-31 --with-stores -o trends.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "parquet" {
+				for _, name := range []string{"from", "to", "with-stores", "output"} {
+					if cmd.Flags().Changed(name) {
+						return fmt.Errorf("--%s can only be used with --format csv or json", name)
+					}
+				}
+				return exportParquet(cmd, loader)
+			}
+			if format != "csv" && format != "json" {
+				return fmt.Errorf("unsupported format %q (want parquet, csv or json)", format)
+			}
+
+			var opts analytics.DumpOptions
+			opts.WithStores = withStores
+			var err error
+			if from != "" {
+				if opts.From, err = week.Parse(from); err != nil {
+					return fmt.Errorf("invalid --from: %w", err)
+				}
+			}
+			if to != "" {
+				if opts.To, err = week.Parse(to); err != nil {
+					return fmt.Errorf("invalid --to: %w", err)
+				}
+			}
+
+			// 標準出力にデータを書き出すため、ログは標準エラー出力に出す
+			if output == "-" {
+				logging.Output = os.Stderr
+			}
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+
+			w := os.Stdout
+			if output != "-" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			n, err := analytics.Dump(cmd.Context(), gormDB, w, format, opts)
+			if err != nil {
+				return err
+			}
+			if w != os.Stdout {
+				if err := w.Close(); err != nil {
+					return err
+				}
+			}
+			a.logger.Info("トレンドのエクスポートが完了しました", "format", format, "rows", n, "output", output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "parquet", "出力形式 (parquet, csv, json)")
+	cmd.Flags().StringVar(&from, "from", "", "この日を含む週以降のトレンドを書き出す (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "この日を含む週以前のトレンドを書き出す (YYYY-MM-DD)")
+	cmd.Flags().BoolVar(&withStores, "with-stores", false, "トレンドを言及された店舗ごとの行に展開する")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "出力先のファイル (-は標準出力)")
+	return cmd
+}
+
+// exportParquetはトレンド・店舗・クロール統計をParquet形式でオブジェクトストレージへエクスポートします。
+func exportParquet(cmd *cobra.Command, loader *config.Loader) error {
+	a, err := setup(cmd, loader, config.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	var checks []preflight.Check
+	if a.cfg.ExportBucket == "" {
+		checks = append(checks, preflight.WritableDir("EXPORT_DIR", a.cfg.ExportDir))
+	}
+	if err := a.preflight(cmd, checks...); err != nil {
+		return err
+	}
+	gormDB, err := a.openDB()
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	cfg := a.cfg
+	store, err := objectstore.New(ctx, cfg.ExportBucket, cfg.ExportPrefix, cfg.S3Endpoint, cfg.ExportDir)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	current := week.Of(now)
+	weeks := make([]time.Time, 0, cfg.ExportWeeks)
+	for i := 0; i < cfg.ExportWeeks; i++ {
+		weeks = append(weeks, current.AddDate(0, 0, -7*i))
+	}
+
+	if err := analytics.NewExporter(gormDB, store).Export(ctx, weeks, now); err != nil {
+		return err
+	}
+	a.logger.Info("分析用データのエクスポートが完了しました", "weeks", cfg.ExportWeeks)
+	return nil
+}
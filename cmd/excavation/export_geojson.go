@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/geojson"
+	"excavation_service/internal/app/logging"
+	"excavation_service/internal/app/repository"
+
+	"github.com/spf13/cobra"
+)
+
+func newExportGeoJSONCmd(loader *config.Loader) *cobra.Command {
+	var (
+		area    string
+		topicID uint
+		output  string
+	)
+	cmd := &cobra.Command{
+		Use:   "export-geojson",
+		Short: "緯度・経度を変換済みの店舗をGeoJSONでエクスポートします",
+		Long: `geocodeで緯度・経度を変換した店舗を、言及していたトピック・最新のトレンドのスコア・言及数とともにGeoJSONで書き出します。
+QGISやMapboxなどの地図のツールで直接読み込めます。すべてのテナントのトピックの店舗を書き出します。APIの /api/v1/stores/geojson はAPIキーのテナントのトピックの店舗のみ返します。`,
+		Example: `  excavation export-geojson --area 渋谷 -o shibuya.geojson
+  excavation export-geojson --topic-id 12 > topic12.geojson`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// 標準出力にデータを書き出すため、ログは標準エラー出力に出す
+			if output == "-" {
+				logging.Output = os.Stderr
+			}
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			stores, err := repository.NewStoreRepository(gormDB).WithContext(cmd.Context()).Features(0, area, topicID)
+			if err != nil {
+				return err
+			}
+			body, err := geojson.Stores(stores).Marshal()
+			if err != nil {
+				return err
+			}
+
+			if output == "-" {
+				_, err = os.Stdout.Write(append(body, '\n'))
+			} else {
+				err = os.WriteFile(output, body, 0o644)
+			}
+			if err != nil {
+				return err
+			}
+			a.logger.Info("店舗のGeoJSONのエクスポートが完了しました", "stores", len(stores), "output", output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&area, "area", "", "トピック名にこのエリアを含むトピックで言及された店舗のみ書き出す")
+	cmd.Flags().UintVar(&topicID, "topic-id", 0, "このトピックで言及された店舗のみ書き出す")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "出力先のファイル (-は標準出力)")
+	return cmd
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"excavation_service/internal/app/analytics"
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/objectstore"
+	"excavation_service/internal/app/preflight"
+	"excavation_service/internal/app/week"
+
+	"github.com/spf13/cobra"
+)
+
+func newExportRawCmd(loader *config.Loader) *cobra.Command {
+	var date string
+	cmd := &cobra.Command{
+		Use:   "export-raw",
+		Short: "クロールで見つけた店舗への言及をgzip圧縮したJSONLでエクスポートします",
+		Long: `指定した日(既定は昨日)に記録した店舗への言及を、加工せずにraw/crawl_items/dt=YYYY-MM-DD/crawl_items.jsonl.gzとして
+オブジェクトストレージ(またはEXPORT_DIR)へ書き出します。同じ日を再び書き出すとファイルを上書きします。cronなどから毎日実行します。
+
+検索APIのレスポンスは、RAW_ARCHIVE=true の場合に発掘の実行ごとに raw/search_responses/dt=YYYY-MM-DD/run_id=<ID>.jsonl.gz へ書き出されます。`,
+		Example: `  excavation export-raw
+  excavation export-raw --date 2024-06-05`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			// 日の境界はTIMEZONEで判定する
+			day := week.Day(time.Now()).AddDate(0, 0, -1)
+			if date != "" {
+				d, err := week.ParseDay(date)
+				if err != nil {
+					return fmt.Errorf("invalid --date: %w", err)
+				}
+				day = d
+			}
+
+			var checks []preflight.Check
+			if a.cfg.ExportBucket == "" {
+				checks = append(checks, preflight.WritableDir("EXPORT_DIR", a.cfg.ExportDir))
+			}
+			if err := a.preflight(cmd, checks...); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			cfg := a.cfg
+			store, err := objectstore.New(ctx, cfg.ExportBucket, cfg.ExportPrefix, cfg.S3Endpoint, cfg.ExportDir)
+			if err != nil {
+				return err
+			}
+
+			n, err := analytics.ExportCrawlItems(ctx, gormDB, store, day)
+			if err != nil {
+				return err
+			}
+			a.logger.Info("クロールの生データのエクスポートが完了しました", "date", day.Format("2006-01-02"), "items", n)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&date, "date", "", "書き出す日 (YYYY-MM-DD、既定は昨日)")
+	return cmd
+}
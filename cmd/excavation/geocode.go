@@ -0,0 +1,56 @@
+package main
+
+import (
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/discovery"
+	"excavation_service/internal/app/geocode"
+	"excavation_service/internal/app/preflight"
+
+	"github.com/spf13/cobra"
+)
+
+func newGeocodeCmd(loader *config.Loader) *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "geocode",
+		Short: "店舗の住所を緯度・経度に変換して保存します",
+		Long: `住所の変換をまだ試みていない店舗について、店舗ページから住所を取得し、GEOCODERのサービスで緯度・経度に変換して保存します。
+リクエストはGEOCODE_INTERVAL以上の間隔を空けて送り、変換結果(見つからなかった住所を含む)はgeocode_cacheテーブルにキャッシュします。
+cronなどから発掘の後に定期的に実行します。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			var checks []preflight.Check
+			if a.cfg.ScrapeRulesFile != "" {
+				checks = append(checks, preflight.ReadableFile("SCRAPE_RULES_FILE", a.cfg.ScrapeRulesFile))
+			}
+			if err := a.preflight(cmd, checks...); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			provider, err := geocode.New(a.cfg.Geocoder, a.cfg.GoogleMapsAPIKey, a.cfg.NominatimUserAgent)
+			if err != nil {
+				return err
+			}
+			// キャッシュにある住所はサービスに問い合わせないため、間隔も空けない
+			geocoder := geocode.NewCached(geocode.NewRateLimited(provider, a.cfg.GeocodeInterval), geocode.NewDBCache(gormDB, a.cfg.Geocoder))
+
+			n, err := discovery.GeocodeStores(cmd.Context(), a.logger, gormDB, geocoder, a.cfg.ScrapeRulesFile, limit)
+			if err != nil {
+				return err
+			}
+			a.logger.Info("店舗の住所の変換が完了しました", "geocoder", a.cfg.Geocoder, "located", n)
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 100, "1回に処理する店舗の最大数")
+	return cmd
+}
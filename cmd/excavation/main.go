@@ -0,0 +1,75 @@
+// excavation は話題の店舗を発掘してトピックのトレンドを記録するサービスのコマンドです。
+// APIサーバーの起動、マイグレーション、トレンドの発掘などをサブコマンドとして提供し、設定とDB接続はすべてのサブコマンドで共通です。
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	_ "time/tzdata" // TIMEZONEをタイムゾーンのデータベースがないイメージでも解釈できるようにする
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/logging"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	// Ctrl+CやSIGTERMで実行中の処理のコンテキストをキャンセルする
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCmd().ExecuteContext(ctx); err != nil {
+		// エラーにはDBの接続URLなどが含まれることがあるため、秘密情報を取り除いてから表示する
+		fmt.Fprintln(os.Stderr, "Error:", logging.Redact(err.Error()))
+		stop()
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	loader := config.NewLoader("excavation")
+	root := &cobra.Command{
+		Use:   "excavation",
+		Short: "話題の店舗を発掘してトピックのトレンドを記録します",
+		Long: `話題の店舗を発掘してトピックのトレンドを記録します。
+
+設定は既定値・プロファイル(--env)・設定ファイル(--config)・環境変数・コマンドライン引数の順に読み込み、
+後のものほど優先されます。すべての設定項目は同名の環境変数(例: --database-url は DATABASE_URL)でも指定できます。`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.PersistentFlags().AddGoFlagSet(loader.FlagSet())
+	root.PersistentFlags().BoolP("verbose", "v", false, "デバッグログを出力する (LOG_LEVEL=debugと同じ)")
+	root.PersistentFlags().BoolP("quiet", "q", false, "警告とエラーのみを出力し、進捗を表示しない")
+	root.MarkFlagsMutuallyExclusive("verbose", "quiet")
+	root.PersistentFlags().String("actor", "", "監査ログに記録する操作者 (既定はcli:<OSのユーザー名>)")
+
+	root.AddCommand(
+		newServeCmd(loader),
+		newMigrateCmd(loader),
+		newPreflightCmd(loader),
+		newDiscoverCmd(loader),
+		newConsumeCmd(loader),
+		newScheduleCmd(loader),
+		newEnrichCmd(loader),
+		newGeocodeCmd(loader),
+		newScoreCmd(loader),
+		newExportCmd(loader),
+		newExportRawCmd(loader),
+		newExportGeoJSONCmd(loader),
+		newSheetsCmd(loader),
+		newSearchCmd(loader),
+		newDigestCmd(loader),
+		newDiscordCmd(loader),
+		newSeedCmd(loader),
+		newRetentionCmd(loader),
+		newPurgeCmd(loader),
+		newTopicsCmd(loader),
+		newTenantsCmd(loader),
+		newStoresCmd(loader),
+	)
+	return root
+}
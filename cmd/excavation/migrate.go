@@ -0,0 +1,35 @@
+package main
+
+import (
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/db"
+	"excavation_service/migrations"
+
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "未適用のマイグレーションを適用します",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			sqlDB, err := a.openSQL()
+			if err != nil {
+				return err
+			}
+			applied, err := db.Migrate(cmd.Context(), sqlDB, migrations.FS)
+			if err != nil {
+				return err
+			}
+			a.logger.Info("マイグレーションが完了しました", "applied", len(applied))
+			return nil
+		},
+	}
+}
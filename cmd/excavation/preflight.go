@@ -0,0 +1,46 @@
+package main
+
+import (
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/preflight"
+	"excavation_service/migrations"
+
+	"github.com/spf13/cobra"
+)
+
+func newPreflightCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "preflight",
+		Short: "実行環境を確認して結果を表示します",
+		Long: `DBへの接続、未適用のマイグレーション、APIキー、アーカイブ・エクスポート先の書き込み権限などをまとめて確認し、結果を表で表示します。
+各サブコマンドも処理を始める前に必要な項目を確認しますが、このコマンドではすべての項目を確認します。
+問題があれば終了コード1で終了します。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			cfg := a.cfg
+			checks := []preflight.Check{
+				preflight.Database(a.openSQL),
+				preflight.Migrations(a.openSQL, migrations.FS),
+				preflight.Setting(config.BraveAPIKey, cfg.BraveAPIKey),
+				preflight.Setting(config.OpenAIAPIKey, cfg.OpenAIAPIKey),
+			}
+			if cfg.ExportBucket == "" {
+				checks = append(checks, preflight.WritableDir("ARCHIVE_DIR", cfg.ArchiveDir), preflight.WritableDir("EXPORT_DIR", cfg.ExportDir))
+			}
+			if cfg.ScrapeRulesFile != "" {
+				checks = append(checks, preflight.ReadableFile("SCRAPE_RULES_FILE", cfg.ScrapeRulesFile))
+			}
+			results, err := preflight.Run(cmd.Context(), checks)
+			if werr := preflight.WriteReport(cmd.OutOrStdout(), results); werr != nil {
+				return werr
+			}
+			return err
+		},
+	}
+}
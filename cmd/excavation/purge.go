@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/preflight"
+	"excavation_service/internal/app/retention"
+
+	"github.com/spf13/cobra"
+)
+
+func newPurgeCmd(loader *config.Loader) *cobra.Command {
+	var (
+		crawlItems, trends bool
+		olderThan          string
+		dryRun, yes        bool
+		allowLocal         bool
+	)
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "指定した期間より古い行を手動でアーカイブしてから削除します",
+		Long: `指定した期間(--older-than)より古い行を、保持期間の設定とは別に手動でアーカイブしてから削除します。
+対象のテーブルは --crawl-items (店舗への言及、store_mentions) と --trends (topic_trends) で選びます。
+
+アーカイブはEXPORT_BUCKETのarchive/以下に書き出します。EXPORT_BUCKETが未設定の場合は実行を拒否し、
+--allow-local-archive を指定したときのみARCHIVE_DIRへ書き出します。
+
+削除の前に対象の行数を表示して確認を求めます。--dry-run では行数の表示のみを行い、--yes では確認を省略します。`,
+		Example: `  excavation purge --crawl-items --older-than 90d --dry-run
+  excavation purge --crawl-items --trends --older-than 365d --yes`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !crawlItems && !trends {
+				return errors.New("select tables to purge with --crawl-items and/or --trends")
+			}
+			age, err := parseAge(olderThan)
+			if err != nil {
+				return fmt.Errorf("invalid --older-than: %w", err)
+			}
+			selected := map[string]bool{"store_mentions": crawlItems, "topic_trends": trends}
+			var policies []retention.Policy
+			for _, p := range retention.Policies(0, 0) {
+				if selected[p.Table] {
+					p.MaxAge = age
+					policies = append(policies, p)
+				}
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			ctx := cmd.Context()
+			now := time.Now()
+			// dry runでは書き出さないため、アーカイブ先が決まらなくても対象の行数は表示する
+			archiver, dest, err := a.archiver(ctx, now, allowLocal || dryRun)
+			if err != nil {
+				return err
+			}
+			var checks []preflight.Check
+			if !dryRun {
+				checks = a.archiveChecks()
+			}
+			if err := a.preflight(cmd, checks...); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			preview, err := retention.Preview(ctx, gormDB, policies, now)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "TABLE\tOLDER_THAN\tROWS")
+			total := 0
+			for _, r := range preview {
+				fmt.Fprintf(tw, "%s\t%s\t%d\n", r.Table, r.Cutoff.Format(time.RFC3339), r.Expired)
+				total += r.Expired
+			}
+			tw.Flush()
+
+			if dryRun {
+				fmt.Fprintf(out, "\n(dry run) %d rows would be archived to %s and deleted\n", total, dest)
+				return nil
+			}
+			if total == 0 {
+				fmt.Fprintln(out, "\nnothing to purge")
+				return nil
+			}
+			if !yes {
+				fmt.Fprintf(out, "\narchive %d rows to %s and delete them? [y/N] ", total, dest)
+				answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				if ans := strings.ToLower(strings.TrimSpace(answer)); ans != "y" && ans != "yes" {
+					return errors.New("aborted")
+				}
+			}
+
+			results, err := retention.Run(ctx, gormDB, archiver, policies, now)
+			expired := make(map[string]retention.Result, len(preview))
+			for _, r := range preview {
+				expired[r.Table] = r
+			}
+			for _, r := range results {
+				fmt.Fprintf(out, "%s: archived %d, deleted %d\n", r.Table, r.Archived, r.Deleted)
+				a.audit(cmd, gormDB, model.AuditDataPurge, "table", r.Table,
+					map[string]interface{}{"rows": expired[r.Table].Expired, "cutoff": r.Cutoff},
+					map[string]interface{}{"archived": r.Archived, "deleted": r.Deleted})
+			}
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&crawlItems, "crawl-items", false, "店舗への言及(store_mentions)を削除する")
+	cmd.Flags().BoolVar(&trends, "trends", false, "トレンド(topic_trends)を削除する")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "この期間より前に作成された行を削除する (例: 90d, 720h)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "削除せずに対象の行数のみを表示する")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "確認せずに削除する")
+	cmd.Flags().BoolVar(&allowLocal, allowLocalArchiveFlag, false, "EXPORT_BUCKET未設定時にARCHIVE_DIR(ローカルディスク)へアーカイブする")
+	cmd.MarkFlagRequired("older-than")
+	return cmd
+}
+
+// parseAgeは90dのような日数、またはtime.ParseDurationの形式の期間を解釈します。
+func parseAge(s string) (time.Duration, error) {
+	var d time.Duration
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number of days", s)
+		}
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, err
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%q must be positive", s)
+	}
+	return d, nil
+}
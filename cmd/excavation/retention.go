@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/objectstore"
+	"excavation_service/internal/app/preflight"
+	"excavation_service/internal/app/retention"
+
+	"github.com/spf13/cobra"
+)
+
+// allowLocalArchiveFlagはEXPORT_BUCKET未設定時にARCHIVE_DIRへのアーカイブを許可するフラグです。
+const allowLocalArchiveFlag = "allow-local-archive"
+
+// errNoDurableArchiveは削除前の行のアーカイブ先に、消えないストレージが設定されていないことを表します。
+var errNoDurableArchive = errors.New("no durable archive target: set EXPORT_BUCKET, or pass --" + allowLocalArchiveFlag + " to archive to ARCHIVE_DIR")
+
+// archiverは削除前の行のアーカイブ先と、その表示名を返します。
+// EXPORT_BUCKET設定時はエクスポートと同じバケット・接頭辞のarchive/以下に書き出します。
+// 未設定の場合、コンテナのローカルディスクは再起動で消えるため、allowLocalのときのみARCHIVE_DIRへ書き出します。
+func (a *app) archiver(ctx context.Context, now time.Time, allowLocal bool) (retention.Archiver, string, error) {
+	cfg := a.cfg
+	if cfg.ExportBucket != "" {
+		store, err := objectstore.NewS3Store(ctx, cfg.ExportBucket, cfg.ExportPrefix, cfg.S3Endpoint)
+		if err != nil {
+			return nil, "", err
+		}
+		return retention.NewStoreArchiver(store, now), fmt.Sprintf("s3://%s/%s", cfg.ExportBucket, path.Join(cfg.ExportPrefix, "archive")), nil
+	}
+	if !allowLocal {
+		return nil, "", errNoDurableArchive
+	}
+	return retention.NewFileArchiver(cfg.ArchiveDir, now), cfg.ArchiveDir, nil
+}
+
+// archiveChecksはアーカイブ先を確認するプリフライトチェックを返します。ARCHIVE_DIRに書き出す場合のみ確認します。
+func (a *app) archiveChecks() []preflight.Check {
+	if a.cfg.ExportBucket != "" {
+		return nil
+	}
+	return []preflight.Check{preflight.WritableDir("ARCHIVE_DIR", a.cfg.ArchiveDir)}
+}
+
+func newRetentionCmd(loader *config.Loader) *cobra.Command {
+	var allowLocal bool
+	cmd := &cobra.Command{
+		Use:   "retention",
+		Short: "保持期間を過ぎた行をアーカイブしてから削除します",
+		Long: `保持期間(RETENTION_*_DAYS)を過ぎた行をアーカイブしてから削除します。
+cronなどから定期的に実行します。
+
+アーカイブはEXPORT_BUCKETのarchive/以下に書き出します。EXPORT_BUCKETが未設定の場合は実行を拒否し、
+--allow-local-archive を指定したときのみARCHIVE_DIRへ書き出します。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			now := time.Now()
+			archiver, dest, err := a.archiver(cmd.Context(), now, allowLocal)
+			if err != nil {
+				return err
+			}
+			if err := a.preflight(cmd, a.archiveChecks()...); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			cfg := a.cfg
+			policies := retention.Policies(cfg.RetentionStoreMentionsDays, cfg.RetentionTopicTrendsDays)
+
+			if _, err := retention.Run(cmd.Context(), gormDB, archiver, policies, now); err != nil {
+				return err
+			}
+			a.logger.Info("保持期間切れデータの削除が完了しました", "archive", dest)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&allowLocal, allowLocalArchiveFlag, false, "EXPORT_BUCKET未設定時にARCHIVE_DIR(ローカルディスク)へアーカイブする")
+	return cmd
+}
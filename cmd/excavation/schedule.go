@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"excavation_service/internal/app/clock"
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/leader"
+	"excavation_service/internal/app/queue"
+
+	"github.com/spf13/cobra"
+)
+
+func newScheduleCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "schedule",
+		Short: "今週のトレンドがまだないトピックの発掘の依頼を定期的にキューに送ります",
+		Long: `SCHEDULE_INTERVALごとに、今週のトレンドがまだない有効なトピックを確認し、TOPIC_QUEUEのキューに発掘の依頼を送ります。
+依頼はconsumeが受け取って発掘します。停止(SIGTERM)するまで動き続けます。
+
+冗長化のため複数のプロセスで動かせます。PostgreSQLのアドバイザリーロックでリーダーを1つ選び、リーダーのみが依頼を送ります。
+他のプロセスは待機し、リーダーが停止したりDBへの接続を失ったりすると、LEADER_CHECK_INTERVAL以内に待機中のプロセスがリーダーになります。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+			if a.cfg.ScheduleInterval <= 0 || a.cfg.LeaderCheckInterval <= 0 {
+				return fmt.Errorf("SCHEDULE_INTERVAL and LEADER_CHECK_INTERVAL must be positive")
+			}
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			sqlDB, err := a.openSQL()
+			if err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			q, err := a.topicQueue(cmd.Context(), gormDB)
+			if err != nil {
+				return err
+			}
+
+			a.logger.Info("定期的な発掘の依頼を開始しました", "queue", a.cfg.TopicQueue, "interval", a.cfg.ScheduleInterval)
+			leader.Run(cmd.Context(), a.logger, leader.NewPostgres(sqlDB, "schedule"), a.cfg.LeaderCheckInterval, func(ctx context.Context) {
+				queue.Schedule(ctx, a.logger, gormDB, q, a.cfg.ScheduleInterval, clock.Real)
+			})
+			a.logger.Info("定期的な発掘の依頼を終了しました")
+			return nil
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/discovery"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/week"
+
+	"github.com/spf13/cobra"
+)
+
+func newScoreCmd(loader *config.Loader) *cobra.Command {
+	var (
+		weekStr string
+		opts    discovery.RescoreOptions
+	)
+	cmd := &cobra.Command{
+		Use:   "score",
+		Short: "保存済みのトレンドのスコアを計算し直します",
+		Long: `指定した週のトレンドのスコアを、保存済みの入力から計算し直します。クロールは行いません。
+
+--model を指定しない場合は、保存済みのGPTスコアと言及数から合成スコアのみを計算し直します。
+--model を指定した場合は、トレンドに保存されたプロンプトをそのモデルのGPTに渡してスコアリングをやり直します。`,
+		Example: `  excavation score --week 2024-06-03
+  excavation score --topic 12 --week 2024-06-03 --model gpt-4o-mini`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			required := []string{config.DatabaseURL}
+			if opts.Model != "" {
+				required = append(required, config.OpenAIAPIKey)
+			}
+			a, err := setup(cmd, loader, required...)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			w := week.Current()
+			if weekStr != "" {
+				parsed, err := week.Parse(weekStr)
+				if err != nil {
+					return err
+				}
+				w = parsed
+			}
+
+			opts.OpenAIAPIKey = a.cfg.OpenAIAPIKey
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			n, err := discovery.Rescore(cmd.Context(), a.logger, gormDB, w, opts)
+			if err != nil {
+				return err
+			}
+			a.audit(cmd, gormDB, model.AuditRunScore, "week", w.Format(week.Layout), nil, map[string]interface{}{
+				"topic_id": opts.TopicID, "model": opts.Model, "trends": n,
+			})
+			a.logger.Info("スコアの再計算が完了しました", "week", w.Format(week.Layout), "topic_id", opts.TopicID, "model", opts.Model, "trends", n)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&weekStr, "week", "", "対象の週 (YYYY-MM-DD、既定は今週)")
+	cmd.Flags().UintVar(&opts.TopicID, "topic", 0, "対象のトピックのID (既定はすべてのトピック)")
+	cmd.Flags().StringVar(&opts.Model, "model", "", "GPTのスコアリングをやり直すモデル (例: "+discovery.DefaultGPTModel+"、gpt-4o-mini)")
+	return cmd
+}
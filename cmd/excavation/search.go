@@ -0,0 +1,49 @@
+package main
+
+import (
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/search"
+
+	"github.com/spf13/cobra"
+)
+
+func newSearchCmd(loader *config.Loader) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "search",
+		Short: "店舗の検索インデックスを管理します",
+	}
+	cmd.AddCommand(newSearchReindexCmd(loader))
+	return cmd
+}
+
+func newSearchReindexCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "reindex",
+		Short: "店舗をMeilisearchのインデックスに登録し直します",
+		Long: `MEILISEARCH_INDEXのインデックスの検索の設定を更新し、インデックスの店舗を削除してから既定のテナントのトピックで言及された店舗を登録し直します。
+発掘の実行では言及のあった店舗のみ登録するため、初めてMeilisearchを使うときや設定を変えたときに実行してください。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL, "MEILISEARCH_URL")
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			index := a.storeIndex()
+			if err := index.Configure(cmd.Context()); err != nil {
+				return err
+			}
+			n, err := search.Reindex(cmd.Context(), gormDB, index)
+			a.logger.Info("店舗を検索インデックスに登録しました", "index", a.cfg.MeilisearchIndex, "stores", n)
+			return err
+		},
+	}
+}
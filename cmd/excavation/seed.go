@@ -0,0 +1,50 @@
+package main
+
+import (
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/model"
+
+	"github.com/spf13/cobra"
+)
+
+func newSeedCmd(loader *config.Loader) *cobra.Command {
+	var entityName, entityType, topicName string
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "開発用の初期データ(エンティティとトピック)を登録します",
+		Long: `開発用の初期データとしてエンティティとトピックを登録します。既に登録されている場合は何もしません。
+既定では、discoverが対象とする「西日暮里」のトピックを登録します。`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			tx := gormDB.WithContext(cmd.Context())
+
+			entity := model.Entity{Name: entityName, Type: entityType}
+			if err := tx.Where("name = ? AND type = ?", entityName, entityType).FirstOrCreate(&entity).Error; err != nil {
+				return err
+			}
+			topic := model.EntityTopic{EntityID: entity.ID, Topic: topicName}
+			if err := tx.Where("entity_id = ? AND topic = ?", entity.ID, topicName).FirstOrCreate(&topic).Error; err != nil {
+				return err
+			}
+			a.logger.Info("初期データを登録しました", "entity_id", entity.ID, "topic_id", topic.ID, "topic", topic.Topic)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&entityName, "entity", "西日暮里", "エンティティ名")
+	cmd.Flags().StringVar(&entityType, "type", "restaurant", "エンティティの種別")
+	cmd.Flags().StringVar(&topicName, "topic", "西日暮里", "トピック (検索クエリに使われます)")
+	return cmd
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"excavation_service/internal/app/activity"
+	"excavation_service/internal/app/authtoken"
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/db"
+	"excavation_service/internal/app/discord"
+	"excavation_service/internal/app/googleauth"
+	"excavation_service/internal/app/handler"
+	"excavation_service/internal/app/outbox"
+	"excavation_service/internal/app/preflight"
+	"excavation_service/internal/app/pubsub"
+	"excavation_service/internal/app/queue"
+	"excavation_service/internal/app/ratelimit"
+	"excavation_service/internal/app/search"
+	"excavation_service/internal/app/trendstream"
+	"excavation_service/internal/app/webhook"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+)
+
+func newServeCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "APIサーバーを起動します",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+			a.logger.Info("Application starting...")
+
+			cfg := a.cfg
+			sinks := []outbox.Sink{webhook.Sink{}, trendstream.Sink{}}
+			var checks []preflight.Check
+			if cfg.EventSink == "pubsub" {
+				checks = append(checks, preflight.Setting("PUBSUB_PROJECT", cfg.PubSubProject), preflight.Setting("PUBSUB_TOPIC", cfg.PubSubTopic))
+				if cfg.PubSubEmulatorHost != "" {
+					sinks = append(sinks, pubsub.NewEmulatorSink(cfg.PubSubEmulatorHost, cfg.PubSubProject, cfg.PubSubTopic))
+				} else {
+					sinks = append(sinks, pubsub.NewSink(cfg.PubSubEndpoint, cfg.PubSubProject, cfg.PubSubTopic))
+				}
+			}
+			if cfg.GoogleClientID != "" {
+				checks = append(checks, preflight.Setting("JWT_SECRET", cfg.JWTSecret), preflight.Setting("GOOGLE_CLIENT_SECRET", cfg.GoogleClientSecret), preflight.Setting("GOOGLE_REDIRECT_URL", cfg.GoogleRedirectURL))
+			}
+			if err := a.preflight(cmd, checks...); err != nil {
+				return err
+			}
+			var tokens *authtoken.Signer
+			if cfg.JWTSecret != "" {
+				if tokens, err = authtoken.NewSigner(cfg.JWTSecret, cfg.AccessTokenTTL); err != nil {
+					return err
+				}
+			}
+			var limiter *ratelimit.Limiter
+			if cfg.RateLimitPerMinute > 0 {
+				var store ratelimit.Store = ratelimit.NewMemory()
+				if cfg.RateLimitRedisURL != "" {
+					redis, err := ratelimit.NewRedis(cfg.RateLimitRedisURL)
+					if err != nil {
+						return err
+					}
+					defer redis.Close()
+					store = redis
+				}
+				limiter = ratelimit.New(store, ratelimit.PerMinute(cfg.RateLimitPerMinute, cfg.RateLimitBurst))
+			}
+			sqlDB, err := a.openSQL()
+			if err != nil {
+				return err
+			}
+			gormDB, err := db.OpenGorm(sqlDB)
+			if err != nil {
+				return err
+			}
+			// バックグラウンドの処理は終了時に止め、DBの接続を閉じる前に終わるのを待つ
+			workerCtx, stopWorkers := context.WithCancel(cmd.Context())
+			var workers sync.WaitGroup
+			defer func() {
+				stopWorkers()
+				workers.Wait()
+			}()
+			background := func(run func(context.Context)) {
+				workers.Add(1)
+				go func() {
+					defer workers.Done()
+					run(workerCtx)
+				}()
+			}
+			// DBの再起動などで接続が切れたことを検知してヘルスチェックに反映する
+			monitor := db.NewMonitor(sqlDB)
+			if a.cfg.DBHealthCheckInterval > 0 {
+				background(func(ctx context.Context) { monitor.Run(ctx, a.logger, a.cfg.DBHealthCheckInterval) })
+			}
+			if a.cfg.OutboxRelayInterval > 0 {
+				background(func(ctx context.Context) {
+					outbox.NewRelay(gormDB, sinks...).Run(ctx, a.logger, a.cfg.OutboxRelayInterval)
+				})
+			}
+			// 新しいトレンドの通知を受け取り、GET /api/v1/trends/streamのクライアントに渡す。
+			// 終了時は接続中のクライアントの応答を終え、Shutdownがクライアントの切断を待たないようにする
+			stream := trendstream.NewHub()
+			background(func(ctx context.Context) { stream.Run(ctx, a.logger, sqlDB) })
+			// 発掘のプロセスが送る進捗を受け取り、運用者のダッシュボード(GET /api/v1/admin/activity)に渡す
+			progress := activity.NewHub()
+			background(func(ctx context.Context) { progress.Run(ctx, a.logger, sqlDB) })
+			if a.cfg.WebhookDeliveryInterval > 0 {
+				background(func(ctx context.Context) {
+					webhook.NewDispatcher(gormDB).Run(ctx, a.logger, a.cfg.WebhookDeliveryInterval)
+				})
+			}
+
+			// 運用者が依頼した発掘(POST /api/v1/admin/jobs/trend-discovery)を送るキュー。
+			// TOPIC_QUEUE=sqsでTOPIC_QUEUE_URLがなければ設定しない
+			var topicQueue queue.Sender
+			if cfg.TopicQueue == "postgres" || cfg.TopicQueueURL != "" {
+				if topicQueue, err = a.topicQueue(cmd.Context(), gormDB); err != nil {
+					return err
+				}
+			}
+
+			var stores search.StoreSearcher = search.NewPostgres(gormDB)
+			if index := a.storeIndex(); index != nil {
+				stores = index
+			}
+
+			// Echoサーバーの設定
+			e := echo.New()
+			e.HideBanner = true
+			e.IPExtractor = handler.IPExtractor(cfg.TrustedProxies)
+			e.HTTPErrorHandler = handler.LocalizedErrorHandler(e.DefaultHTTPErrorHandler)
+			e.Use(middleware.Recover())
+			e.Use(otelecho.Middleware("excavation-api"))
+			e.Use(middleware.RequestID())
+			e.Use(handler.RequestLogger())
+			e.Use(handler.AccessLog())
+			e.Use(handler.Language())
+			handler.RegisterRoutes(e, gormDB, a.cfg.RequireAPIKey, tokens, limiter, stream, progress, topicQueue, stores, cfg.DashboardOrigins)
+			handler.RegisterHealth(e, monitor)
+			handler.RegisterPublic(e, gormDB)
+			if cfg.GoogleClientID != "" {
+				handler.RegisterGoogleSignIn(e, gormDB, googleauth.NewClient(cfg.GoogleClientID, cfg.GoogleClientSecret, cfg.GoogleRedirectURL), tokens, limiter)
+			}
+			if a.cfg.DiscordPublicKey != "" {
+				publicKey, err := discord.ParsePublicKey(a.cfg.DiscordPublicKey)
+				if err != nil {
+					return err
+				}
+				handler.RegisterDiscord(e, gormDB, publicKey)
+			}
+
+			// docker-compose.yml ではホスト側の18080に割り当てています
+			a.logger.Info("Application started successfully.", "port", a.cfg.Port)
+			errc := make(chan error, 1)
+			go func() { errc <- e.Start(":" + a.cfg.Port) }()
+			select {
+			case err := <-errc:
+				// ポートが使用中などで待ち受けられなかった
+				return err
+			case <-cmd.Context().Done():
+			}
+
+			// SIGINT・SIGTERMを受けたら新しい接続の受け付けをやめ、処理中のリクエストが終わるのを待ってから終了する。
+			// DBの接続はdeferで閉じる。待ちきれなかった場合はエラーとして終了コード1で終了する
+			a.logger.Info("Shutting down...", "timeout", a.cfg.ShutdownTimeout)
+			ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+			defer cancel()
+			if err := e.Shutdown(ctx); err != nil {
+				return fmt.Errorf("shutdown: %w", err)
+			}
+			a.logger.Info("Application stopped.")
+			return nil
+		},
+	}
+}
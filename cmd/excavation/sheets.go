@@ -0,0 +1,64 @@
+package main
+
+import (
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/preflight"
+	"excavation_service/internal/app/sheets"
+	"excavation_service/internal/app/week"
+
+	"github.com/spf13/cobra"
+)
+
+func newSheetsCmd(loader *config.Loader) *cobra.Command {
+	var weekStr string
+	cmd := &cobra.Command{
+		Use:   "sheets",
+		Short: "週間ランキングをGoogleスプレッドシートに書き出します",
+		Long: `指定した週(既定は先週)のスコア上位のトピックと言及の多かった店舗を、SHEETS_SPREADSHEET_IDのスプレッドシートの
+週の名前(YYYY-MM-DD)のシートに書き出します。シートがなければ作成し、あれば内容を置き換えます。
+
+認証にはSHEETS_CREDENTIALS_FILEのサービスアカウントの鍵を使います。スプレッドシートをサービスアカウントの
+メールアドレスに編集者として共有してください。`,
+		Example: `  excavation sheets
+  excavation sheets --week 2024-06-03`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL, "SHEETS_SPREADSHEET_ID", "SHEETS_CREDENTIALS_FILE")
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			// 週の途中では今週のトレンドが揃っていないため、既定は先週とする
+			w := week.Current().AddDate(0, 0, -7)
+			if weekStr != "" {
+				parsed, err := week.Parse(weekStr)
+				if err != nil {
+					return err
+				}
+				w = parsed
+			}
+
+			if err := a.preflight(cmd, preflight.ReadableFile("SHEETS_CREDENTIALS_FILE", a.cfg.SheetsCredentialsFile)); err != nil {
+				return err
+			}
+			creds, err := sheets.LoadCredentials(a.cfg.SheetsCredentialsFile)
+			if err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+
+			n, err := sheets.ExportRanking(cmd.Context(), gormDB, sheets.NewClient(creds), a.cfg.SheetsSpreadsheetID, w)
+			if err != nil {
+				return err
+			}
+			a.logger.Info("週間ランキングをスプレッドシートに書き出しました", "week", w.Format(week.Layout), "topics", n)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&weekStr, "week", "", "対象の週 (YYYY-MM-DD、既定は先週)")
+	return cmd
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/storematch"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+func newStoresCmd(loader *config.Loader) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stores",
+		Short: "表記の揺れで重複した店舗を照合します",
+		Long: `表記の揺れで重複した店舗を照合します。
+
+店舗名は支店名(〇〇店)を除いて正規化し、編集距離と読み(かな)の比較で同じ店舗である確からしさを求めます。
+確からしさが高い組は自動で紐付け、それより低い候補は確認待ちのキューに入れます。キューの候補はreviewで確認し、resolveで紐付けるか却下します。
+
+店舗名・予算・評価・住所の検証に失敗して保存しなかった店舗の情報はquarantineで確認できます。`,
+	}
+	cmd.AddCommand(
+		newStoresMatchCmd(loader),
+		newStoresReviewCmd(loader),
+		newStoresResolveCmd(loader),
+		newStoresQuarantineCmd(loader),
+	)
+	return cmd
+}
+
+func newStoresMatchCmd(loader *config.Loader) *cobra.Command {
+	opts := storematch.Options{}
+	cmd := &cobra.Command{
+		Use:     "match",
+		Short:   "店舗名を照合して同じ店舗と思われる組を記録します",
+		Example: `  excavation stores match --link-threshold 0.95 --dry-run -v`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.ReviewThreshold > opts.LinkThreshold {
+				return fmt.Errorf("--review-threshold (%.2f) must not exceed --link-threshold (%.2f)", opts.ReviewThreshold, opts.LinkThreshold)
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			res, err := storematch.Run(cmd.Context(), a.logger, gormDB, opts)
+			if err != nil {
+				return err
+			}
+			a.logger.Info("店舗の照合が完了しました", "stores", res.Stores, "compared", res.Compared,
+				"linked", res.Linked, "queued", res.Queued, "dry_run", opts.DryRun)
+			return nil
+		},
+	}
+	cmd.Flags().Float64Var(&opts.LinkThreshold, "link-threshold", storematch.DefaultLinkThreshold, "確からしさがこれ以上の組は自動で紐付ける")
+	cmd.Flags().Float64Var(&opts.ReviewThreshold, "review-threshold", storematch.DefaultReviewThreshold, "確からしさがこれ以上の組を確認待ちのキューに入れる")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "記録せずに見つけた組をデバッグログに出力する")
+	return cmd
+}
+
+func newStoresReviewCmd(loader *config.Loader) *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "review",
+		Short: "確認待ちの店舗の組を確からしさの高い順に表示します",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			items, err := repository.NewStoreLinkRepository(gormDB).WithContext(cmd.Context()).ListPending(limit)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tCONFIDENCE\tMETHOD\tSTORE\tCANONICAL\tSTORE_URL\tCANONICAL_URL")
+			for _, l := range items {
+				fmt.Fprintf(tw, "%d\t%.2f\t%s\t%s\t%s\t%s\t%s\n",
+					l.ID, l.Confidence, l.Method, l.StoreName, l.CanonicalName, l.StoreURL, l.CanonicalURL)
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 50, "表示する組の最大数")
+	return cmd
+}
+
+func newStoresResolveCmd(loader *config.Loader) *cobra.Command {
+	var link, reject bool
+	cmd := &cobra.Command{
+		Use:     "resolve LINK_ID",
+		Short:   "確認待ちの店舗の組を同じ店舗として紐付けるか、別の店舗として却下します",
+		Example: `  excavation stores resolve 12 --link`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil || id == 0 {
+				return fmt.Errorf("invalid link id %q", args[0])
+			}
+			status := model.StoreLinkLinked
+			if reject {
+				status = model.StoreLinkRejected
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			err = repository.NewStoreLinkRepository(gormDB).WithContext(cmd.Context()).Resolve(uint(id), status, actor(cmd), time.Now())
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("pending store link %d not found", id)
+			}
+			if err != nil {
+				return err
+			}
+			a.audit(cmd, gormDB, model.AuditStoreLink, "store_link", id, nil, map[string]string{"status": status})
+			fmt.Fprintf(cmd.OutOrStdout(), "store link %d: %s\n", id, status)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&link, "link", false, "同じ店舗として紐付ける")
+	cmd.Flags().BoolVar(&reject, "reject", false, "別の店舗として却下する")
+	cmd.MarkFlagsMutuallyExclusive("link", "reject")
+	cmd.MarkFlagsOneRequired("link", "reject")
+	return cmd
+}
+
+func newStoresQuarantineCmd(loader *config.Loader) *cobra.Command {
+	var (
+		stage string
+		limit int
+	)
+	cmd := &cobra.Command{
+		Use:     "quarantine",
+		Short:   "検証に失敗して隔離した店舗の情報を新しい順に表示します",
+		Example: `  excavation stores quarantine --stage mention --limit 20`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch stage {
+			case "", model.QuarantineStageMention, model.QuarantineStageAddress, model.QuarantineStageEnrich:
+			default:
+				return fmt.Errorf("invalid --stage %q: must be one of %s, %s, %s", stage,
+					model.QuarantineStageMention, model.QuarantineStageAddress, model.QuarantineStageEnrich)
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			items, err := repository.NewStoreRepository(gormDB).WithContext(cmd.Context()).ListQuarantined(stage, limit)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tCREATED_AT\tSTAGE\tREASONS\tNAME\tURL\tDATA")
+			for _, q := range items {
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					q.ID, q.CreatedAt.Format(time.RFC3339), q.Stage, q.Reasons, q.Name, q.URL, q.Data)
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&stage, "stage", "", "この処理で隔離したもののみ表示する (mention, address, enrich)")
+	cmd.Flags().IntVar(&limit, "limit", 50, "表示する件数の最大数")
+	return cmd
+}
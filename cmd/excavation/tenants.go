@@ -0,0 +1,649 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+	"excavation_service/internal/app/week"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+// tenantSlugPattern はテナントのスラッグに使える形式です。
+var tenantSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+func newTenantsCmd(loader *config.Loader) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tenants",
+		Short: "テナントとAPIキーを管理します",
+		Long: `テナントとAPIキーを管理します。
+
+テナントごとにエンティティ・トピック・トレンドが分かれ、APIはAPIキーのテナントのデータのみ返します。
+テナントを導入する前のデータは既定のテナント(default)に属します。`,
+	}
+	cmd.AddCommand(
+		newTenantsAddCmd(loader),
+		newTenantsListCmd(loader),
+		newTenantsQuotaCmd(loader),
+		newTenantsKeysCmd(loader),
+	)
+	return cmd
+}
+
+// quotaFlags はテナントの上限を指定するフラグです。負の値は無制限を表します。
+type quotaFlags struct {
+	maxTopics int
+	requests  requestQuotaFlags
+}
+
+func (q *quotaFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&q.maxTopics, "max-topics", -1, "トピック数の上限 (負の値で無制限)")
+	q.requests.register(cmd)
+}
+
+func (q *quotaFlags) apply(t *model.Tenant) {
+	t.MaxTopics = limitFlag(q.maxTopics)
+	t.DailyRequestLimit, t.MonthlyRequestLimit = q.requests.limits()
+}
+
+// requestQuotaFlags はAPIリクエスト数の上限を指定するフラグです。テナントとAPIキーで共通です。負の値は無制限を表します。
+type requestQuotaFlags struct {
+	daily, monthly int
+}
+
+func (q *requestQuotaFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&q.daily, "daily-requests", -1, "1日のAPIリクエスト数の上限 (負の値で無制限)")
+	cmd.Flags().IntVar(&q.monthly, "monthly-requests", -1, "1か月のAPIリクエスト数の上限 (負の値で無制限)")
+}
+
+func (q *requestQuotaFlags) limits() (daily, monthly *int) {
+	return limitFlag(q.daily), limitFlag(q.monthly)
+}
+
+// registerScopesFlagはAPIキーのスコープを指定する--scopesを登録します。
+func registerScopesFlag(cmd *cobra.Command, scopes *[]string) {
+	cmd.Flags().StringSliceVar(scopes, "scopes", model.APIKeyScopes,
+		"キーで許可する操作 (read: データの取得, write: データの変更)。読み取り専用のキーはreadのみ指定する")
+}
+
+// parseScopesは--scopesの値を確認し、model.APIKeyScopesの順に重複なく並べて返します。
+func parseScopes(values []string) ([]string, error) {
+	for _, v := range values {
+		if !slices.Contains(model.APIKeyScopes, v) {
+			return nil, fmt.Errorf("invalid scope %q (must be any of %s)", v, strings.Join(model.APIKeyScopes, ", "))
+		}
+	}
+	var scopes []string
+	for _, s := range model.APIKeyScopes {
+		if slices.Contains(values, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	return scopes, nil
+}
+
+// limitFlagはフラグの値を上限にします。負の値は無制限(nil)です。
+func limitFlag(v int) *int {
+	if v < 0 {
+		return nil
+	}
+	return &v
+}
+
+func newTenantsAddCmd(loader *config.Loader) *cobra.Command {
+	var name string
+	var quota quotaFlags
+	cmd := &cobra.Command{
+		Use:     "add SLUG",
+		Short:   "テナントを登録します",
+		Example: `  excavation tenants add example-media --name "Example Media" --max-topics 50 --daily-requests 10000 --monthly-requests 200000`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slug := args[0]
+			if !tenantSlugPattern.MatchString(slug) {
+				return fmt.Errorf("invalid slug %q (use lowercase letters, digits and hyphens)", slug)
+			}
+			if name == "" {
+				name = slug
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			t := model.Tenant{Slug: slug, Name: name}
+			quota.apply(&t)
+			if err := repository.NewTenantRepository(gormDB).WithContext(cmd.Context()).Create(&t); err != nil {
+				return err
+			}
+			a.audit(cmd, gormDB, model.AuditTenantCreate, "tenant", t.ID, nil, t)
+			fmt.Fprintf(cmd.OutOrStdout(), "added tenant %d: %s (%s)\n", t.ID, t.Slug, t.Name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "テナントの表示名 (省略時はスラッグ)")
+	quota.register(cmd)
+	return cmd
+}
+
+func newTenantsListCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "テナントの一覧を表示します",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			repo := repository.NewTenantRepository(gormDB).WithContext(cmd.Context())
+			tenants, err := repo.List()
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tSLUG\tNAME\tTOPICS\tMAX_TOPICS\tDAILY_REQUESTS\tMONTHLY_REQUESTS\tCREATED")
+			for _, t := range tenants {
+				topics, err := repo.CountTopics(t.ID)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+					t.ID, t.Slug, t.Name, topics, limitString(t.MaxTopics), limitString(t.DailyRequestLimit),
+					limitString(t.MonthlyRequestLimit), t.CreatedAt.Format(week.Layout))
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+func newTenantsQuotaCmd(loader *config.Loader) *cobra.Command {
+	var quota quotaFlags
+	cmd := &cobra.Command{
+		Use:   "quota SLUG",
+		Short: "テナントのトピック数と1日・1か月のAPIリクエスト数の上限を変更します",
+		Long: `テナントのトピック数と1日・1か月のAPIリクエスト数の上限を変更します。指定しなかった上限は無制限になります。
+リクエスト数の上限はテナントのすべてのAPIキーを合わせた数です。上限を下げても既存のトピックは削除しません。`,
+		Example: `  excavation tenants quota example-media --max-topics 100 --daily-requests 20000 --monthly-requests 400000`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			t, err := findTenant(cmd.Context(), gormDB, args[0])
+			if err != nil {
+				return err
+			}
+			before := *t
+			quota.apply(t)
+			if err := repository.NewTenantRepository(gormDB).WithContext(cmd.Context()).UpdateQuotas(t); err != nil {
+				return err
+			}
+			a.audit(cmd, gormDB, model.AuditTenantQuota, "tenant", t.ID, before, t)
+			fmt.Fprintf(cmd.OutOrStdout(), "updated tenant %s: max topics %s, daily requests %s, monthly requests %s\n",
+				t.Slug, limitString(t.MaxTopics), limitString(t.DailyRequestLimit), limitString(t.MonthlyRequestLimit))
+			return nil
+		},
+	}
+	quota.register(cmd)
+	return cmd
+}
+
+func newTenantsKeysCmd(loader *config.Loader) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "テナントのAPIキーを管理します",
+	}
+	cmd.AddCommand(
+		newTenantsKeysCreateCmd(loader),
+		newTenantsKeysListCmd(loader),
+		newTenantsKeysRevokeCmd(loader),
+		newTenantsKeysQuotaCmd(loader),
+		newTenantsKeysScopesCmd(loader),
+		newTenantsKeysLinkGoogleCmd(loader),
+		newTenantsKeysUnlinkGoogleCmd(loader),
+		newTenantsKeysGoogleAccountsCmd(loader),
+	)
+	return cmd
+}
+
+func newTenantsKeysCreateCmd(loader *config.Loader) *cobra.Command {
+	var name string
+	var quota requestQuotaFlags
+	var scopeFlags []string
+	cmd := &cobra.Command{
+		Use:   "create SLUG",
+		Short: "テナントのAPIキーを発行します",
+		Long: `テナントのAPIキーを発行します。キーはこのときにのみ表示され、DBにはハッシュのみ保存します。
+APIには X-API-Key ヘッダー、または Authorization: Bearer <キー> で渡します。
+キーごとのリクエスト数の上限はテナントの上限とは別に判定し、どちらかを超えると429を返します。
+--scopesで許可する操作を制限でき、readのみのキーはデータを変更するエンドポイントで403になります。`,
+		Example: `  excavation tenants keys create example-media --name production --daily-requests 5000
+  excavation tenants keys create example-media --name partner-ranking --scopes read`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scopes, err := parseScopes(scopeFlags)
+			if err != nil {
+				return err
+			}
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			t, err := findTenant(cmd.Context(), gormDB, args[0])
+			if err != nil {
+				return err
+			}
+			key, apiKey, err := tenant.NewAPIKey(t.ID, name)
+			if err != nil {
+				return err
+			}
+			apiKey.DailyRequestLimit, apiKey.MonthlyRequestLimit = quota.limits()
+			apiKey.Scopes = scopes
+			if err := repository.NewTenantRepository(gormDB).WithContext(cmd.Context()).CreateAPIKey(apiKey); err != nil {
+				return err
+			}
+			a.audit(cmd, gormDB, model.AuditAPIKeyCreate, "api_key", apiKey.ID, nil, apiKey)
+			fmt.Fprintf(cmd.ErrOrStderr(), "created api key %d for tenant %s (shown only once)\n", apiKey.ID, t.Slug)
+			fmt.Fprintln(cmd.OutOrStdout(), key)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "default", "キーの用途などを表す名前")
+	quota.register(cmd)
+	registerScopesFlag(cmd, &scopeFlags)
+	return cmd
+}
+
+func newTenantsKeysListCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list SLUG",
+		Short: "テナントのAPIキーの一覧を表示します",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			t, err := findTenant(cmd.Context(), gormDB, args[0])
+			if err != nil {
+				return err
+			}
+			keys, err := repository.NewTenantRepository(gormDB).WithContext(cmd.Context()).ListAPIKeys(t.ID)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSTATUS\tSCOPES\tDAILY_REQUESTS\tMONTHLY_REQUESTS\tLAST_USED\tCREATED")
+			for _, k := range keys {
+				status, lastUsed := "active", "-"
+				if k.RevokedAt != nil {
+					status = "revoked"
+				}
+				if k.LastUsedAt != nil {
+					lastUsed = k.LastUsedAt.Format(time.DateTime)
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s…\t%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Prefix, status, strings.Join(k.Scopes, ","),
+					limitString(k.DailyRequestLimit), limitString(k.MonthlyRequestLimit), lastUsed, k.CreatedAt.Format(week.Layout))
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+func newTenantsKeysRevokeCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke KEY_ID",
+		Short: "APIキーを失効させます",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil || id == 0 {
+				return fmt.Errorf("invalid key id %q", args[0])
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			err = repository.NewTenantRepository(gormDB).WithContext(cmd.Context()).RevokeAPIKey(uint(id), time.Now())
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("active api key %d not found", id)
+			}
+			if err != nil {
+				return err
+			}
+			a.audit(cmd, gormDB, model.AuditAPIKeyRevoke, "api_key", id, nil, nil)
+			fmt.Fprintf(cmd.OutOrStdout(), "revoked api key %d\n", id)
+			return nil
+		},
+	}
+}
+
+func newTenantsKeysQuotaCmd(loader *config.Loader) *cobra.Command {
+	var quota requestQuotaFlags
+	cmd := &cobra.Command{
+		Use:   "quota KEY_ID",
+		Short: "APIキーの1日・1か月のAPIリクエスト数の上限を変更します",
+		Long: `APIキーの1日・1か月のAPIリクエスト数の上限を変更します。指定しなかった上限は無制限になります。
+キーの上限が無制限でも、テナントの上限は適用されます。`,
+		Example: `  excavation tenants keys quota 12 --daily-requests 1000 --monthly-requests 20000`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil || id == 0 {
+				return fmt.Errorf("invalid key id %q", args[0])
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			repo := repository.NewTenantRepository(gormDB).WithContext(cmd.Context())
+			key, err := repo.GetAPIKey(uint(id))
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("api key %d not found", id)
+			}
+			if err != nil {
+				return err
+			}
+			before := *key
+			key.DailyRequestLimit, key.MonthlyRequestLimit = quota.limits()
+			if err := repo.UpdateAPIKeyQuotas(key); err != nil {
+				return err
+			}
+			a.audit(cmd, gormDB, model.AuditAPIKeyQuota, "api_key", key.ID, before, key)
+			fmt.Fprintf(cmd.OutOrStdout(), "updated api key %d: daily requests %s, monthly requests %s\n",
+				key.ID, limitString(key.DailyRequestLimit), limitString(key.MonthlyRequestLimit))
+			return nil
+		},
+	}
+	quota.register(cmd)
+	return cmd
+}
+
+func newTenantsKeysScopesCmd(loader *config.Loader) *cobra.Command {
+	var scopeFlags []string
+	cmd := &cobra.Command{
+		Use:   "scopes KEY_ID",
+		Short: "APIキーで許可する操作を変更します",
+		Long: `APIキーで許可する操作(スコープ)を--scopesの値に置き換えます。
+発行済みのアクセストークンにも、次のリクエストから変更後のスコープが適用されます。`,
+		Example: `  excavation tenants keys scopes 12 --scopes read`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil || id == 0 {
+				return fmt.Errorf("invalid key id %q", args[0])
+			}
+			scopes, err := parseScopes(scopeFlags)
+			if err != nil {
+				return err
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			repo := repository.NewTenantRepository(gormDB).WithContext(cmd.Context())
+			key, err := repo.GetAPIKey(uint(id))
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("api key %d not found", id)
+			}
+			if err != nil {
+				return err
+			}
+			before := *key
+			key.Scopes = scopes
+			if err := repo.UpdateAPIKeyScopes(key); err != nil {
+				return err
+			}
+			a.audit(cmd, gormDB, model.AuditAPIKeyScopes, "api_key", key.ID, before, key)
+			fmt.Fprintf(cmd.OutOrStdout(), "updated api key %d: scopes %s\n", key.ID, strings.Join(key.Scopes, ","))
+			return nil
+		},
+	}
+	registerScopesFlag(cmd, &scopeFlags)
+	cmd.MarkFlagRequired("scopes")
+	return cmd
+}
+
+func newTenantsKeysLinkGoogleCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "link-google KEY_ID EMAIL",
+		Short: "GoogleアカウントをAPIキーに紐付けます",
+		Long: `メールアドレスEMAILのGoogleアカウントを有効なAPIキーに紐付けます。
+紐付けたアカウントでGET /auth/google/loginからサインインすると、そのAPIキーのアクセストークンを発行します。
+初回のサインインでGoogleのユーザーIDを記録し、以降は同じメールアドレスでもほかのアカウントのサインインは受け付けません。
+APIキーを失効させると、紐付けたアカウントでもサインインできなくなります。`,
+		Example: `  excavation tenants keys link-google 12 ops@example.com`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil || id == 0 {
+				return fmt.Errorf("invalid key id %q", args[0])
+			}
+			if !strings.Contains(args[1], "@") {
+				return fmt.Errorf("invalid email %q", args[1])
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			key, err := repository.NewTenantRepository(gormDB).WithContext(cmd.Context()).GetAPIKey(uint(id))
+			if err == nil && key.RevokedAt != nil {
+				err = gorm.ErrRecordNotFound
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("active api key %d not found", id)
+			}
+			if err != nil {
+				return err
+			}
+			identity, err := repository.NewOAuthIdentityRepository(gormDB).WithContext(cmd.Context()).Link(model.OAuthProviderGoogle, args[1], key)
+			if errors.Is(err, repository.ErrIdentityExists) {
+				return fmt.Errorf("google account %s is already linked; unlink it first", args[1])
+			}
+			if err != nil {
+				return err
+			}
+			a.audit(cmd, gormDB, model.AuditAPIKeyLink, "api_key", key.ID, nil, identity)
+			fmt.Fprintf(cmd.OutOrStdout(), "linked google account %s to api key %d\n", identity.Email, key.ID)
+			return nil
+		},
+	}
+}
+
+func newTenantsKeysUnlinkGoogleCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unlink-google EMAIL",
+		Short: "GoogleアカウントとAPIキーの紐付けを解除します",
+		Long: `メールアドレスEMAILのGoogleアカウントとAPIキーの紐付けを解除します。
+発行済みのアクセストークンは有効期限まで使えるため、すぐに止める場合はAPIキーを失効させてください。`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			identity, err := repository.NewOAuthIdentityRepository(gormDB).WithContext(cmd.Context()).Unlink(model.OAuthProviderGoogle, args[0])
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("google account %s is not linked", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			a.audit(cmd, gormDB, model.AuditAPIKeyUnlink, "api_key", identity.APIKeyID, identity, nil)
+			fmt.Fprintf(cmd.OutOrStdout(), "unlinked google account %s from api key %d\n", identity.Email, identity.APIKeyID)
+			return nil
+		},
+	}
+}
+
+func newTenantsKeysGoogleAccountsCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "google-accounts SLUG",
+		Short: "テナントのAPIキーに紐付いたGoogleアカウントの一覧を表示します",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			t, err := findTenant(cmd.Context(), gormDB, args[0])
+			if err != nil {
+				return err
+			}
+			identities, err := repository.NewOAuthIdentityRepository(gormDB).WithContext(cmd.Context()).List(t.ID)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "EMAIL\tKEY_ID\tLAST_SIGN_IN\tCREATED")
+			for _, i := range identities {
+				lastSignIn := "-"
+				if i.LastSignInAt != nil {
+					lastSignIn = i.LastSignInAt.Format(time.DateTime)
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", i.Email, i.APIKeyID, lastSignIn, i.CreatedAt.Format(week.Layout))
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+// findTenantはスラッグが一致するテナントを返します。
+func findTenant(ctx context.Context, db *gorm.DB, slug string) (*model.Tenant, error) {
+	t, err := repository.NewTenantRepository(db).WithContext(ctx).GetBySlug(slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("tenant %q not found", slug)
+	}
+	return t, err
+}
+
+// limitStringは上限を表示用の文字列にします。nilは無制限です。
+func limitString(limit *int) string {
+	if limit == nil {
+		return "unlimited"
+	}
+	return strconv.Itoa(*limit)
+}
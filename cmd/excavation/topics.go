@@ -0,0 +1,358 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"excavation_service/internal/app/config"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/topics"
+	"excavation_service/internal/app/week"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+func newTopicsCmd(loader *config.Loader) *cobra.Command {
+	var tenantSlug string
+	cmd := &cobra.Command{
+		Use:   "topics",
+		Short: "トピックを管理します",
+	}
+	cmd.PersistentFlags().StringVar(&tenantSlug, "tenant", "default", "登録・一覧するトピックのテナント(スラッグ)")
+	cmd.AddCommand(
+		newTopicsAddCmd(loader, &tenantSlug),
+		newTopicsListCmd(loader, &tenantSlug),
+		newTopicsSetDisabledCmd(loader, true),
+		newTopicsSetDisabledCmd(loader, false),
+		newTopicsPriorityCmd(loader),
+		newTopicsImportCmd(loader, &tenantSlug),
+	)
+	return cmd
+}
+
+func newTopicsAddCmd(loader *config.Loader, tenantSlug *string) *cobra.Command {
+	var entityType, topic string
+	cmd := &cobra.Command{
+		Use:   "add ENTITY",
+		Short: "エンティティとトピックを登録します",
+		Long: `エンティティとトピックを登録します。エンティティが未登録なら作成します。
+トピックは検索クエリとして使われ、省略時はエンティティ名と同じです。`,
+		Example: `  excavation topics add 西日暮里 --type restaurant
+  excavation topics add 草津温泉 --type onsen --topic "草津 日帰り温泉"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			tenant, err := findTenant(cmd.Context(), gormDB, *tenantSlug)
+			if err != nil {
+				return err
+			}
+			// 入力の検証と重複の判定はCSVのインポートと同じ規則で行う
+			row := topics.Row{Entity: strings.TrimSpace(args[0]), Type: strings.ToLower(entityType), Topic: strings.TrimSpace(topic)}
+			results, err := topics.Import(cmd.Context(), gormDB, actor(cmd), tenant.ID, []topics.Row{row}, false)
+			if err != nil {
+				return err
+			}
+			r := results[0]
+			switch r.Status {
+			case topics.StatusError:
+				return errors.New(r.Reason)
+			case topics.StatusSkipped:
+				return fmt.Errorf("topic %q of %s already exists (id %d)", r.Topic, r.Entity, r.TopicID)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "added topic %d: %s (%s) %q\n", r.TopicID, r.Entity, r.Type, r.Topic)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&entityType, "type", "", "エンティティの種別 (onsen, restaurant, brand)")
+	cmd.Flags().StringVar(&topic, "topic", "", "トピック (検索クエリ、省略時はエンティティ名)")
+	cmd.MarkFlagRequired("type")
+	return cmd
+}
+
+func newTopicsListCmd(loader *config.Loader, tenantSlug *string) *cobra.Command {
+	var status string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "トピックの一覧を表示します",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var disabled *bool
+			switch status {
+			case "all":
+			case "enabled", "disabled":
+				d := status == "disabled"
+				disabled = &d
+			default:
+				return fmt.Errorf("invalid --status %q (want all, enabled or disabled)", status)
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			tenant, err := findTenant(cmd.Context(), gormDB, *tenantSlug)
+			if err != nil {
+				return err
+			}
+			items, err := repository.NewTopicRepository(gormDB).WithContext(cmd.Context()).ForTenant(tenant.ID).ListTopics(disabled)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tENTITY\tTYPE\tTOPIC\tSTATUS\tPRIORITY\tLATEST_WEEK\tCREATED")
+			for _, t := range items {
+				state := "enabled"
+				if t.DisabledAt != nil {
+					state = "disabled"
+				}
+				latest := "-"
+				if t.LatestWeek != nil {
+					latest = t.LatestWeek.Format(week.Layout)
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+					t.ID, t.EntityName, t.EntityType, t.Topic, state, t.Priority, latest, t.CreatedAt.Format(week.Layout))
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&status, "status", "all", "表示するトピックの状態 (all, enabled, disabled)")
+	return cmd
+}
+
+// newTopicsSetDisabledCmdはトピックを無効化(disableがtrue)または有効化するサブコマンドを作成します。
+// 無効なトピックはdiscoverの対象外になりますが、トレンドやウォッチは残ります。
+func newTopicsSetDisabledCmd(loader *config.Loader, disable bool) *cobra.Command {
+	use, short, done := "enable TOPIC_ID...", "トピックを有効化して発掘の対象に戻します", "enabled"
+	if disable {
+		use, short, done = "disable TOPIC_ID...", "トピックを無効化して発掘の対象から外します", "disabled"
+	}
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids := make([]uint, 0, len(args))
+			for _, arg := range args {
+				id, err := strconv.ParseUint(arg, 10, 64)
+				if err != nil || id == 0 {
+					return fmt.Errorf("invalid topic id %q", arg)
+				}
+				ids = append(ids, uint(id))
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			action := model.AuditTopicEnable
+			if disable {
+				action = model.AuditTopicDisable
+			}
+			now := time.Now()
+			for _, id := range ids {
+				// 変更と監査ログの記録は同じトランザクションで行う
+				var topic *model.EntityTopic
+				err := gormDB.WithContext(cmd.Context()).Transaction(func(tx *gorm.DB) error {
+					repo := repository.NewTopicRepository(tx)
+					before, err := repo.GetTopic(id)
+					if err != nil {
+						return err
+					}
+					if topic, err = repo.SetTopicDisabled(id, disable, now); err != nil {
+						return err
+					}
+					if (before.DisabledAt == nil) == (topic.DisabledAt == nil) {
+						return nil // 変更なし
+					}
+					return repository.NewAuditRepository(tx).Record(actor(cmd), action, "topic", id, topicAudit(before), topicAudit(topic))
+				})
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("topic %d not found", id)
+				}
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s topic %d: %q\n", done, topic.ID, topic.Topic)
+			}
+			return nil
+		},
+	}
+}
+
+func newTopicsPriorityCmd(loader *config.Loader) *cobra.Command {
+	return &cobra.Command{
+		Use:   "priority TOPIC_ID PRIORITY",
+		Short: "トピックの発掘の優先度を変更します",
+		Long: `トピックの発掘の優先度を変更します。既定は0で、値が大きいトピックほどdiscoverやscheduleで先に発掘します。負の値も指定できます。
+
+実際の順序は優先度に次の加点をした値で決まります。
+  - ウォッチが設定されているトピック: +10
+  - 直近4週の間に前週比の変化量(絶対値)が20以上になったトピック: +20`,
+		Example: `  excavation topics priority 12 50`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil || id == 0 {
+				return fmt.Errorf("invalid topic id %q", args[0])
+			}
+			priority, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid priority %q", args[1])
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			var topic *model.EntityTopic
+			err = gormDB.WithContext(cmd.Context()).Transaction(func(tx *gorm.DB) error {
+				repo := repository.NewTopicRepository(tx)
+				before, err := repo.GetTopic(uint(id))
+				if err != nil {
+					return err
+				}
+				if topic, err = repo.SetPriority(uint(id), priority); err != nil {
+					return err
+				}
+				return repository.NewAuditRepository(tx).Record(actor(cmd), model.AuditTopicPriority, "topic", id, topicAudit(before), topicAudit(topic))
+			})
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("topic %d not found", id)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "set priority of topic %d (%q) to %d\n", topic.ID, topic.Topic, topic.Priority)
+			return nil
+		},
+	}
+}
+
+func newTopicsImportCmd(loader *config.Loader, tenantSlug *string) *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "CSVからエンティティとトピックを一括で登録します",
+		Long: `CSVからエンティティとトピックを一括で登録します。
+
+CSVの1行目はヘッダーで、entity (エンティティ名)、type (onsen, restaurant, brand)、topic (検索クエリ、省略時はエンティティ名) の列を持ちます。
+登録済みのトピックとファイル内の重複はスキップし、不正な行はエラーとして報告します。
+--dry-run では登録せずに、各行がどう扱われるかを表示します。`,
+		Example: `  excavation topics import --dry-run topics.csv
+  excavation topics import topics.csv`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			rows, err := topics.ParseCSV(f)
+			if err != nil {
+				return fmt.Errorf("read %s: %w", args[0], err)
+			}
+
+			a, err := setup(cmd, loader, config.DatabaseURL)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			if err := a.preflight(cmd); err != nil {
+				return err
+			}
+			gormDB, err := a.openDB()
+			if err != nil {
+				return err
+			}
+			tenant, err := findTenant(cmd.Context(), gormDB, *tenantSlug)
+			if err != nil {
+				return err
+			}
+			results, err := topics.Import(cmd.Context(), gormDB, actor(cmd), tenant.ID, rows, dryRun)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "LINE\tENTITY\tTYPE\tTOPIC\tSTATUS\tTOPIC_ID\tDETAIL")
+			for _, r := range results {
+				topicID, detail := "-", r.Reason
+				if r.TopicID != 0 {
+					topicID = fmt.Sprint(r.TopicID)
+				}
+				if r.EntityCreated {
+					detail = "new entity"
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Line, r.Entity, r.Type, r.Topic, r.Status, topicID, detail)
+			}
+			tw.Flush()
+
+			s := topics.Summarize(results)
+			prefix := ""
+			if dryRun {
+				prefix = "(dry run) "
+			}
+			fmt.Fprintf(out, "\n%s%d created, %d skipped, %d errored\n", prefix, s.Created, s.Skipped, s.Errored)
+			if s.Errored > 0 {
+				return fmt.Errorf("%d rows could not be imported", s.Errored)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "登録せずに結果のみを表示する")
+	return cmd
+}
+
+// topicAuditは監査ログに記録するトピックの状態です。
+func topicAudit(t *model.EntityTopic) map[string]interface{} {
+	return map[string]interface{}{"id": t.ID, "entity_id": t.EntityID, "topic": t.Topic, "priority": t.Priority, "disabled_at": t.DisabledAt}
+}
@@ -0,0 +1,124 @@
+// Package activity は発掘の実行中の進捗(トピックの開始、店舗の発見、トレンドの保存)を運用者のダッシュボードに配信します。
+//
+// 発掘のプロセスはPublishでPostgreSQLのNOTIFYを送り、APIサーバーの各プロセスのHubがLISTENで受け取って
+// 接続しているクライアント(WebSocketのGET /api/v1/admin/activity)に渡します。
+// 進捗は届かなくても発掘の結果に影響しないため、outboxを使わずにその場で送り、接続していないクライアントには残しません。
+package activity
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"excavation_service/internal/app/db"
+
+	"gorm.io/gorm"
+)
+
+// Channel は進捗を通知するPostgreSQLのチャネルです。
+const Channel = "excavation_activity"
+
+// 進捗の種類
+const (
+	TopicStarted  = "topic.started"  // トピックの発掘を始めた
+	StoresFound   = "stores.found"   // 店舗の候補をまとめた。Storesは見つかった店舗の数
+	TrendSaved    = "trend.saved"    // トレンドを保存した。Scoreはトレンドのスコア
+	TopicFinished = "topic.finished" // トピックの処理を終えた。Outcomeは結果、失敗した場合はError
+)
+
+// subscriberBuffer を超えて溜まった進捗は、読むのが遅いクライアントには渡さずに捨てます。
+const subscriberBuffer = 64
+
+// Event は発掘の進捗です。
+type Event struct {
+	Type     string    `json:"type"`
+	RunID    string    `json:"run_id"`
+	JobID    uint      `json:"job_id,omitempty"`
+	TenantID uint      `json:"tenant_id"`
+	TopicID  uint      `json:"topic_id"`
+	Topic    string    `json:"topic"`
+	Stores   int       `json:"stores,omitempty"`
+	TrendID  uint      `json:"trend_id,omitempty"`
+	Score    *float64  `json:"score,omitempty"`
+	Outcome  string    `json:"outcome,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Publishは進捗をNOTIFYで通知します。Atが設定されていなければ現在時刻にします。
+func Publish(ctx context.Context, tx *gorm.DB, event Event) error {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", event.Type, err)
+	}
+	if err := tx.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", Channel, string(payload)).Error; err != nil {
+		return fmt.Errorf("notify %s: %w", Channel, err)
+	}
+	return nil
+}
+
+// Hub はLISTENで受け取った進捗を、購読しているクライアントに渡します。進捗はNOTIFYの内容(EventのJSON)のまま渡します。
+type Hub struct {
+	mu      sync.Mutex
+	subs    map[chan []byte]struct{}
+	stopped bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[chan []byte]struct{})}
+}
+
+// Subscribeは進捗を受け取るチャネルと、購読をやめる関数を返します。Hubが止まるとチャネルは閉じられます。
+func (h *Hub) Subscribe() (<-chan []byte, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan []byte, subscriberBuffer)
+	if h.stopped {
+		close(ch)
+		return ch, func() {}
+	}
+	h.subs[ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishはpayloadをすべての購読者に渡します。バッファがいっぱいの購読者には渡しません。
+func (h *Hub) publish(payload []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+}
+
+// RunはLISTENで受け取った進捗を購読者に渡します。ctxがキャンセルされると購読者のチャネルを閉じて終了します。
+func (h *Hub) Run(ctx context.Context, logger *slog.Logger, sqlDB *sql.DB) {
+	defer func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for ch := range h.subs {
+			close(ch)
+		}
+		h.subs = make(map[chan []byte]struct{})
+		h.stopped = true
+	}()
+	db.Listen(ctx, logger, sqlDB, Channel, func(payload string) {
+		h.publish([]byte(payload))
+	})
+}
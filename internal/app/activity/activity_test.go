@@ -0,0 +1,62 @@
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/testdb"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
+}
+
+// TestPublishは、Publishした進捗がHubの購読者に届くことを本物のPostgreSQLで確認します。
+func TestPublish(t *testing.T) {
+	gormDB := testdb.Postgres(t)
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h := NewHub()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Run(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), sqlDB)
+	}()
+	events, _ := h.Subscribe()
+
+	// LISTENするまで送り直す
+	deadline := time.After(10 * time.Second)
+	for received := false; !received; {
+		if err := Publish(ctx, gormDB, Event{Type: StoresFound, RunID: "run-1", TopicID: 5, Stores: 12}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case payload := <-events:
+			var got Event
+			if err := json.Unmarshal(payload, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Type != StoresFound || got.TopicID != 5 || got.Stores != 12 || got.At.IsZero() {
+				t.Errorf("event = %+v", got)
+			}
+			received = true
+		case <-time.After(2 * time.Second):
+		case <-deadline:
+			t.Fatal("no event received")
+		}
+	}
+
+	cancel()
+	<-done
+	// 送り直した分を読み捨て、チャネルが閉じられていることを確認する
+	for range events {
+	}
+}
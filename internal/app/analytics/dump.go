@@ -0,0 +1,205 @@
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"excavation_service/internal/app/week"
+
+	"gorm.io/gorm"
+)
+
+// storeJoinColumns はDumpOptions.WithStoresのときにトレンドの列の後ろに追加する、言及された店舗の列です。
+// 店舗への言及がないトレンドは店舗の列がnullの1行になります。
+var storeJoinColumns = []Column{
+	{Name: "store_id", Type: Int64, Optional: true},
+	{Name: "store_url", Type: String, Optional: true},
+	{Name: "store_name", Type: String, Optional: true},
+	{Name: "store_mentions", Type: Int64},
+}
+
+// DumpOptions はDumpで書き出すトレンドの範囲です。
+type DumpOptions struct {
+	From       time.Time // この週以降のトレンドを書き出す。ゼロ値なら制限しない
+	To         time.Time // この週以前のトレンドを書き出す。ゼロ値なら制限しない
+	WithStores bool      // トレンドの週にトピックで言及された店舗ごとに1行に展開する
+}
+
+// Dumpはトレンドを週・ID順にformat(csv, json)でwに書き出し、書き出した行数を返します。
+// 行はDBから1行ずつ読みながら書き出すため、件数が多くてもメモリに溜め込みません。
+func Dump(ctx context.Context, db *gorm.DB, w io.Writer, format string, opts DumpOptions) (int, error) {
+	columns := trendColumns
+	q := trendQuery(db.WithContext(ctx))
+	if opts.WithStores {
+		columns = append(append([]Column{}, trendColumns...), storeJoinColumns...)
+		q = q.Select("t.id, t.topic_id, et.topic, e.id AS entity_id, e.name AS entity_name, e.type AS entity_type, " +
+			"t.week, t.score, t.gpt_score, t.delta, t.mention_count, t.top_title, t.created_at, " +
+			"s.id AS store_id, s.url AS store_url, s.name AS store_name, COUNT(sm.id) AS store_mentions").
+			Joins("LEFT JOIN store_mentions AS sm ON sm.topic_id = t.topic_id AND sm.week = t.week").
+			Joins("LEFT JOIN stores AS s ON s.id = sm.store_id").
+			Group("t.id, et.id, e.id, s.id")
+	}
+	if !opts.From.IsZero() {
+		q = q.Where("t.week >= ?", opts.From)
+	}
+	if !opts.To.IsZero() {
+		q = q.Where("t.week <= ?", opts.To)
+	}
+	q = q.Order("t.week, t.id")
+	if opts.WithStores {
+		q = q.Order("store_mentions DESC, s.id")
+	}
+
+	rw, err := newRecordWriter(w, format, columns)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := q.Rows()
+	if err != nil {
+		return 0, fmt.Errorf("query trends: %w", err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var r struct {
+			Trend         trendRow `gorm:"embedded"`
+			StoreID       *int64
+			StoreURL      *string
+			StoreName     *string
+			StoreMentions int64
+		}
+		if err := db.ScanRows(rows, &r); err != nil {
+			return n, fmt.Errorf("scan trend: %w", err)
+		}
+		values := r.Trend.values()
+		if opts.WithStores {
+			values = append(values, nullable(r.StoreID), nullable(r.StoreURL), nullable(r.StoreName), r.StoreMentions)
+		}
+		if err := rw.Write(values); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("query trends: %w", err)
+	}
+	return n, rw.Close()
+}
+
+// recordWriter は列定義に従って1行ずつ書き出します。Closeで書き出しを完了します。
+type recordWriter interface {
+	Write(values []interface{}) error
+	Close() error
+}
+
+func newRecordWriter(w io.Writer, format string, columns []Column) (recordWriter, error) {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		header := make([]string, len(columns))
+		for i, col := range columns {
+			header[i] = col.Name
+		}
+		if err := cw.Write(header); err != nil {
+			return nil, err
+		}
+		return &csvRecordWriter{w: cw, columns: columns}, nil
+	case "json":
+		return &jsonRecordWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q (want csv or json)", format)
+}
+
+// csvRecordWriter はヘッダー付きのCSVを書き出します。nullは空文字列になります。
+type csvRecordWriter struct {
+	w       *csv.Writer
+	columns []Column
+	record  []string
+}
+
+func (c *csvRecordWriter) Write(values []interface{}) error {
+	c.record = c.record[:0]
+	for i, v := range values {
+		c.record = append(c.record, formatCSV(c.columns[i], v))
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvRecordWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatCSV(col Column, v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return formatTime(col, v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// jsonRecordWriter は列の順にキーを並べたオブジェクトの配列を書き出します。
+type jsonRecordWriter struct {
+	w       *bufio.Writer
+	columns []Column
+	n       int
+}
+
+func (j *jsonRecordWriter) Write(values []interface{}) error {
+	if j.n == 0 {
+		j.w.WriteString("[\n")
+	} else {
+		j.w.WriteString(",\n")
+	}
+	j.n++
+
+	j.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			j.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(j.columns[i].Name)
+		j.w.Write(key)
+		j.w.WriteByte(':')
+		if t, ok := v.(time.Time); ok {
+			v = formatTime(j.columns[i], t)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", j.columns[i].Name, err)
+		}
+		j.w.Write(b)
+	}
+	j.w.WriteByte('}')
+	return nil
+}
+
+func (j *jsonRecordWriter) Close() error {
+	if j.n == 0 {
+		j.w.WriteString("[")
+	}
+	j.w.WriteString("\n]\n")
+	return j.w.Flush()
+}
+
+// formatTimeはDate列をYYYY-MM-DD、それ以外をRFC 3339の文字列にします。
+func formatTime(col Column, t time.Time) string {
+	if col.Type == Date {
+		return t.Format(week.Layout)
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,75 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var dumpTestColumns = []Column{
+	{Name: "id", Type: Int64},
+	{Name: "week", Type: Date},
+	{Name: "score", Type: Double},
+	{Name: "top_title", Type: String, Optional: true},
+}
+
+var dumpTestRows = [][]interface{}{
+	{int64(1), time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), 7.5, "テスト食堂, 本店"},
+	{int64(2), time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC), 3.0, nil},
+}
+
+func writeRecords(t *testing.T, format string, rows [][]interface{}) string {
+	t.Helper()
+	var buf bytes.Buffer
+	rw, err := newRecordWriter(&buf, format, dumpTestColumns)
+	if err != nil {
+		t.Fatalf("newRecordWriter(%q) error = %v", format, err)
+	}
+	for _, row := range rows {
+		if err := rw.Write(row); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.String()
+}
+
+func TestCSVRecordWriter(t *testing.T) {
+	got := writeRecords(t, "csv", dumpTestRows)
+	want := "id,week,score,top_title\n" +
+		"1,2024-06-03,7.5,\"テスト食堂, 本店\"\n" +
+		"2,2024-06-10,3,\n"
+	if got != want {
+		t.Errorf("csv output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestJSONRecordWriter(t *testing.T) {
+	got := writeRecords(t, "json", dumpTestRows)
+	var records []map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &records); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, got)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records) = %d, want 2", len(records))
+	}
+	if records[0]["week"] != "2024-06-03" || records[0]["top_title"] != "テスト食堂, 本店" {
+		t.Errorf("records[0] = %v", records[0])
+	}
+	if v, ok := records[1]["top_title"]; !ok || v != nil {
+		t.Errorf("records[1][top_title] = %v, want null", v)
+	}
+
+	if empty := writeRecords(t, "json", nil); empty != "[\n]\n" {
+		t.Errorf("empty output = %q, want an empty array", empty)
+	}
+}
+
+func TestNewRecordWriterRejectsUnknownFormat(t *testing.T) {
+	if _, err := newRecordWriter(&bytes.Buffer{}, "xml", dumpTestColumns); err == nil {
+		t.Error("newRecordWriter(xml) error = nil, want error")
+	}
+}
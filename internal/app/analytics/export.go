@@ -0,0 +1,212 @@
+// Package analytics は分析用にトレンド・店舗・クロール統計をParquet形式でエクスポートします。トレンドはCSVやJSONにも書き出せます。
+// 出力はHive形式のパーティション(例: trends/week=2024-06-03/trends.parquet)で、DuckDBやAthenaから直接クエリできます。
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"excavation_service/internal/app/objectstore"
+	"excavation_service/internal/app/week"
+
+	"gorm.io/gorm"
+)
+
+const parquetContentType = "application/vnd.apache.parquet"
+
+// trendColumns はtrendsテーブルの列定義です。
+var trendColumns = []Column{
+	{Name: "id", Type: Int64},
+	{Name: "topic_id", Type: Int64},
+	{Name: "topic", Type: String},
+	{Name: "entity_id", Type: Int64},
+	{Name: "entity_name", Type: String},
+	{Name: "entity_type", Type: String},
+	{Name: "week", Type: Date},
+	{Name: "score", Type: Double},
+	{Name: "gpt_score", Type: Double, Optional: true},
+	{Name: "delta", Type: Double, Optional: true},
+	{Name: "mention_count", Type: Int32},
+	{Name: "top_title", Type: String, Optional: true},
+	{Name: "created_at", Type: TimestampMillis},
+}
+
+// storeColumns はstoresテーブルの列定義です。
+var storeColumns = []Column{
+	{Name: "id", Type: Int64},
+	{Name: "url", Type: String},
+	{Name: "name", Type: String},
+	{Name: "created_at", Type: TimestampMillis},
+	{Name: "updated_at", Type: TimestampMillis},
+}
+
+// crawlStatColumns はcrawl_statsテーブルの列定義で、週・トピック・言及元の種別ごとの集計です。
+var crawlStatColumns = []Column{
+	{Name: "week", Type: Date},
+	{Name: "topic_id", Type: Int64},
+	{Name: "source_type", Type: String},
+	{Name: "sources", Type: Int64},
+	{Name: "stores", Type: Int64},
+	{Name: "mentions", Type: Int64},
+}
+
+// Exporter はテーブルをParquetに変換してオブジェクトストレージへ書き込みます。
+type Exporter struct {
+	db    *gorm.DB
+	store objectstore.Store
+}
+
+func NewExporter(db *gorm.DB, store objectstore.Store) *Exporter {
+	return &Exporter{db: db, store: store}
+}
+
+// Exportは指定した週のtrends・crawl_statsのパーティションと、storesのスナップショットを書き込みます。
+// 同じ週のパーティションは毎回上書きされるため、同じ週を何度エクスポートしても結果は変わりません。
+func (e *Exporter) Export(ctx context.Context, weeks []time.Time, now time.Time) error {
+	for _, w := range weeks {
+		partition := "week=" + w.Format(week.Layout)
+
+		trends, err := e.trendRows(ctx, w)
+		if err != nil {
+			return fmt.Errorf("query trends: %w", err)
+		}
+		if err := e.put(ctx, "trends/"+partition+"/trends.parquet", trendColumns, trends); err != nil {
+			return err
+		}
+
+		stats, err := e.crawlStatRows(ctx, w)
+		if err != nil {
+			return fmt.Errorf("query crawl stats: %w", err)
+		}
+		if err := e.put(ctx, "crawl_stats/"+partition+"/crawl_stats.parquet", crawlStatColumns, stats); err != nil {
+			return err
+		}
+		slog.Info("週のパーティションをエクスポートしました", "partition", partition, "trends", len(trends), "crawl_stats", len(stats))
+	}
+
+	stores, err := e.storeRows(ctx)
+	if err != nil {
+		return fmt.Errorf("query stores: %w", err)
+	}
+	key := "stores/snapshot_date=" + now.Format(week.Layout) + "/stores.parquet"
+	if err := e.put(ctx, key, storeColumns, stores); err != nil {
+		return err
+	}
+	slog.Info("店舗のスナップショットをエクスポートしました", "stores", len(stores))
+	return nil
+}
+
+// trendRow はtrendColumnsの順に並べたトレンドの1行です。
+type trendRow struct {
+	ID           int64
+	TopicID      int64
+	Topic        string
+	EntityID     int64
+	EntityName   string
+	EntityType   string
+	Week         time.Time
+	Score        float64
+	GPTScore     *float64
+	Delta        *float64
+	MentionCount int32
+	TopTitle     *string
+	CreatedAt    time.Time
+}
+
+func (r trendRow) values() []interface{} {
+	return []interface{}{
+		r.ID, r.TopicID, r.Topic, r.EntityID, r.EntityName, r.EntityType, r.Week,
+		r.Score, nullable(r.GPTScore), nullable(r.Delta), r.MentionCount, nullable(r.TopTitle), r.CreatedAt,
+	}
+}
+
+// trendQueryはトピックとエンティティを結合したトレンドのクエリです。列はtrendRowに対応します。
+func trendQuery(db *gorm.DB) *gorm.DB {
+	return db.Table("topic_trends AS t").
+		Select("t.id, t.topic_id, et.topic, e.id AS entity_id, e.name AS entity_name, e.type AS entity_type, " +
+			"t.week, t.score, t.gpt_score, t.delta, t.mention_count, t.top_title, t.created_at").
+		Joins("JOIN entity_topics AS et ON et.id = t.topic_id").
+		Joins("JOIN entities AS e ON e.id = et.entity_id")
+}
+
+func (e *Exporter) trendRows(ctx context.Context, w time.Time) ([][]interface{}, error) {
+	var rows []trendRow
+	err := trendQuery(e.db.WithContext(ctx)).
+		Where("t.week = ?", w).
+		Order("t.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([][]interface{}, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, r.values())
+	}
+	return out, nil
+}
+
+func (e *Exporter) crawlStatRows(ctx context.Context, w time.Time) ([][]interface{}, error) {
+	var rows []struct {
+		Week       time.Time
+		TopicID    int64
+		SourceType string
+		Sources    int64
+		Stores     int64
+		Mentions   int64
+	}
+	err := e.db.WithContext(ctx).Table("store_mentions").
+		Select("week, topic_id, source_type, COUNT(DISTINCT source_url) AS sources, COUNT(DISTINCT store_id) AS stores, COUNT(*) AS mentions").
+		Where("week = ?", w).
+		Group("week, topic_id, source_type").
+		Order("topic_id, source_type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([][]interface{}, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, []interface{}{r.Week, r.TopicID, r.SourceType, r.Sources, r.Stores, r.Mentions})
+	}
+	return out, nil
+}
+
+func (e *Exporter) storeRows(ctx context.Context) ([][]interface{}, error) {
+	var rows []struct {
+		ID        int64
+		URL       string
+		Name      string
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	if err := e.db.WithContext(ctx).Table("stores").Order("id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([][]interface{}, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, []interface{}{r.ID, r.URL, r.Name, r.CreatedAt, r.UpdatedAt})
+	}
+	return out, nil
+}
+
+// putはrowsをParquetに変換してkeyに書き込みます。
+func (e *Exporter) put(ctx context.Context, key string, columns []Column, rows [][]interface{}) error {
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, columns, rows); err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+	return e.store.Put(ctx, key, buf.Bytes(), parquetContentType)
+}
+
+// nullableはnilポインタをParquetのnullに、それ以外を値に変換します。
+func nullable[T any](p *T) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
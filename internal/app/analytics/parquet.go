@@ -0,0 +1,402 @@
+package analytics
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+// エクスポートに必要な範囲に絞ったParquetの書き込み実装です。
+// 1ファイル1行グループ、列ごとに1データページ(PLAINエンコーディング、Snappy圧縮)で書き込みます。
+// 仕様: https://github.com/apache/parquet-format
+
+// ColumnType はエクスポートする列の型です。
+type ColumnType int
+
+const (
+	Int32           ColumnType = iota // int32
+	Int64                             // int64
+	Double                            // float64
+	String                            // string (UTF8)
+	Date                              // time.Time (日付のみ)
+	TimestampMillis                   // time.Time
+)
+
+// Column はParquetファイルの1列の定義です。Optionalな列にはnilを書き込めます。
+type Column struct {
+	Name     string
+	Type     ColumnType
+	Optional bool
+}
+
+// Parquetのメタデータで使う定数
+const (
+	parquetMagic = "PAR1"
+
+	physicalInt32     = 1
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedDate            = 6
+	convertedTimestampMillis = 9
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecSnappy = 1
+
+	pageTypeData = 0
+)
+
+func (t ColumnType) physical() int32 {
+	switch t {
+	case Int32, Date:
+		return physicalInt32
+	case Int64, TimestampMillis:
+		return physicalInt64
+	case Double:
+		return physicalDouble
+	default:
+		return physicalByteArray
+	}
+}
+
+// convertedは論理型を返します。論理型を持たない型ではokがfalseです。
+func (t ColumnType) converted() (c int32, ok bool) {
+	switch t {
+	case String:
+		return convertedUTF8, true
+	case Date:
+		return convertedDate, true
+	case TimestampMillis:
+		return convertedTimestampMillis, true
+	}
+	return 0, false
+}
+
+type columnChunk struct {
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+// writeParquetはrowsをParquet形式でwに書き込みます。rowsの各行はcolumnsと同じ順序・数の値を持ちます。
+func writeParquet(w io.Writer, columns []Column, rows [][]interface{}) error {
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return fmt.Errorf("row %d has %d values, want %d", i, len(row), len(columns))
+		}
+	}
+
+	offset := int64(0)
+	write := func(b []byte) error {
+		n, err := w.Write(b)
+		offset += int64(n)
+		return err
+	}
+	if err := write([]byte(parquetMagic)); err != nil {
+		return err
+	}
+
+	var chunks []columnChunk
+	if len(rows) > 0 {
+		for ci, col := range columns {
+			page, err := encodePage(col, ci, rows)
+			if err != nil {
+				return err
+			}
+			compressed := snappy.Encode(nil, page)
+			header := encodePageHeader(len(rows), len(page), len(compressed))
+
+			chunk := columnChunk{
+				offset:           offset,
+				uncompressedSize: int64(len(header) + len(page)),
+				compressedSize:   int64(len(header) + len(compressed)),
+			}
+			if err := write(header); err != nil {
+				return err
+			}
+			if err := write(compressed); err != nil {
+				return err
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	footer := encodeFileMetaData(columns, int64(len(rows)), chunks)
+	if err := write(footer); err != nil {
+		return err
+	}
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+	if err := write(size[:]); err != nil {
+		return err
+	}
+	return write([]byte(parquetMagic))
+}
+
+// encodePageはci列目の値をデータページ(定義レベル + PLAINエンコードした値)に変換します。
+func encodePage(col Column, ci int, rows [][]interface{}) ([]byte, error) {
+	var values []byte
+	levels := make([]byte, 0, len(rows))
+	for ri, row := range rows {
+		v := row[ci]
+		if v == nil {
+			if !col.Optional {
+				return nil, fmt.Errorf("column %s: null value in row %d for required column", col.Name, ri)
+			}
+			levels = append(levels, 0)
+			continue
+		}
+		levels = append(levels, 1)
+
+		var err error
+		values, err = appendPlain(values, col.Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("column %s row %d: %w", col.Name, ri, err)
+		}
+	}
+
+	if !col.Optional {
+		return values, nil
+	}
+	encoded := encodeLevels(levels)
+	page := make([]byte, 4, 4+len(encoded)+len(values))
+	binary.LittleEndian.PutUint32(page, uint32(len(encoded)))
+	page = append(page, encoded...)
+	return append(page, values...), nil
+}
+
+func appendPlain(b []byte, t ColumnType, v interface{}) ([]byte, error) {
+	switch t {
+	case Int32:
+		n, ok := v.(int32)
+		if !ok {
+			return nil, fmt.Errorf("expected int32, got %T", v)
+		}
+		return binary.LittleEndian.AppendUint32(b, uint32(n)), nil
+	case Int64:
+		n, ok := v.(int64)
+		if !ok {
+			return nil, fmt.Errorf("expected int64, got %T", v)
+		}
+		return binary.LittleEndian.AppendUint64(b, uint64(n)), nil
+	case Double:
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected float64, got %T", v)
+		}
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(f)), nil
+	case String:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %T", v)
+		}
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+		return append(b, s...), nil
+	case Date:
+		tm, ok := v.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("expected time.Time, got %T", v)
+		}
+		y, m, d := tm.Date()
+		days := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400
+		return binary.LittleEndian.AppendUint32(b, uint32(int32(days))), nil
+	case TimestampMillis:
+		tm, ok := v.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("expected time.Time, got %T", v)
+		}
+		return binary.LittleEndian.AppendUint64(b, uint64(tm.UnixMilli())), nil
+	}
+	return nil, fmt.Errorf("unknown column type %d", t)
+}
+
+// encodeLevelsは定義レベル(0または1)をビット幅1のRLEランの列としてエンコードします。
+func encodeLevels(levels []byte) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		out = append(out, levels[i])
+		i = j
+	}
+	return out
+}
+
+func encodePageHeader(numValues, uncompressedSize, compressedSize int) []byte {
+	w := &compactWriter{}
+	w.beginStruct()
+	w.i32Field(1, pageTypeData)
+	w.i32Field(2, int32(uncompressedSize))
+	w.i32Field(3, int32(compressedSize))
+	w.structField(5) // DataPageHeader
+	w.i32Field(1, int32(numValues))
+	w.i32Field(2, encodingPlain)
+	w.i32Field(3, encodingRLE) // definition_level_encoding
+	w.i32Field(4, encodingRLE) // repetition_level_encoding
+	w.endStruct()
+	w.endStruct()
+	return w.buf
+}
+
+func encodeFileMetaData(columns []Column, numRows int64, chunks []columnChunk) []byte {
+	w := &compactWriter{}
+	w.beginStruct()
+	w.i32Field(1, 1) // version
+
+	// スキーマはルート要素に続けて各列を並べる
+	w.listField(2, ctStruct, len(columns)+1)
+	w.beginStruct()
+	w.stringField(4, "schema")
+	w.i32Field(5, int32(len(columns)))
+	w.endStruct()
+	for _, col := range columns {
+		w.beginStruct()
+		w.i32Field(1, col.Type.physical())
+		if col.Optional {
+			w.i32Field(3, repetitionOptional)
+		} else {
+			w.i32Field(3, repetitionRequired)
+		}
+		w.stringField(4, col.Name)
+		if c, ok := col.Type.converted(); ok {
+			w.i32Field(6, c)
+		}
+		w.endStruct()
+	}
+
+	w.i64Field(3, numRows)
+
+	if len(chunks) == 0 {
+		w.listField(4, ctStruct, 0)
+	} else {
+		w.listField(4, ctStruct, 1)
+		w.beginStruct() // RowGroup
+		w.listField(1, ctStruct, len(chunks))
+		var totalSize int64
+		for i, chunk := range chunks {
+			w.beginStruct() // ColumnChunk
+			w.i64Field(2, chunk.offset)
+			w.structField(3) // ColumnMetaData
+			w.i32Field(1, columns[i].Type.physical())
+			w.listField(2, ctI32, 2)
+			w.appendI32(encodingPlain)
+			w.appendI32(encodingRLE)
+			w.listField(3, ctBinary, 1)
+			w.appendString(columns[i].Name)
+			w.i32Field(4, codecSnappy)
+			w.i64Field(5, numRows)
+			w.i64Field(6, chunk.uncompressedSize)
+			w.i64Field(7, chunk.compressedSize)
+			w.i64Field(9, chunk.offset)
+			w.endStruct()
+			w.endStruct()
+			totalSize += chunk.uncompressedSize
+		}
+		w.i64Field(2, totalSize)
+		w.i64Field(3, numRows)
+		w.endStruct()
+	}
+
+	w.stringField(6, "excavation_service")
+	w.endStruct()
+	return w.buf
+}
+
+// Thrift Compact Protocolの型
+const (
+	ctI32    = 5
+	ctI64    = 6
+	ctBinary = 8
+	ctList   = 9
+	ctStruct = 12
+)
+
+// compactWriter はParquetのメタデータに必要な範囲のThrift Compact Protocolのエンコーダーです。
+type compactWriter struct {
+	buf  []byte
+	last []int16 // 書き込み中の構造体ごとの直前のフィールドID
+}
+
+func (w *compactWriter) beginStruct() {
+	w.last = append(w.last, 0)
+}
+
+func (w *compactWriter) endStruct() {
+	w.buf = append(w.buf, 0) // STOP
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *compactWriter) fieldHeader(id int16, typ byte) {
+	top := len(w.last) - 1
+	delta := id - w.last[top]
+	if delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.buf = binary.AppendUvarint(w.buf, uint64(zigzag32(int32(id))))
+	}
+	w.last[top] = id
+}
+
+func (w *compactWriter) i32Field(id int16, v int32) {
+	w.fieldHeader(id, ctI32)
+	w.appendI32(v)
+}
+
+func (w *compactWriter) i64Field(id int16, v int64) {
+	w.fieldHeader(id, ctI64)
+	w.buf = binary.AppendUvarint(w.buf, zigzag64(v))
+}
+
+func (w *compactWriter) stringField(id int16, s string) {
+	w.fieldHeader(id, ctBinary)
+	w.appendString(s)
+}
+
+// structFieldは構造体フィールドを開始します。フィールドを書き終えたらendStructを呼びます。
+func (w *compactWriter) structField(id int16) {
+	w.fieldHeader(id, ctStruct)
+	w.beginStruct()
+}
+
+// listFieldはリストフィールドのヘッダーを書き込みます。続けてn個の要素を書き込みます。
+func (w *compactWriter) listField(id int16, elemType byte, n int) {
+	w.fieldHeader(id, ctList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|elemType)
+		return
+	}
+	w.buf = append(w.buf, 0xF0|elemType)
+	w.buf = binary.AppendUvarint(w.buf, uint64(n))
+}
+
+func (w *compactWriter) appendI32(v int32) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(zigzag32(v)))
+}
+
+func (w *compactWriter) appendString(s string) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func zigzag32(n int32) uint32 {
+	return uint32(n<<1) ^ uint32(n>>31)
+}
+
+func zigzag64(n int64) uint64 {
+	return uint64(n<<1) ^ uint64(n>>63)
+}
@@ -0,0 +1,143 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+)
+
+func TestWriteParquetLayout(t *testing.T) {
+	columns := []Column{
+		{Name: "id", Type: Int64},
+		{Name: "name", Type: String, Optional: true},
+		{Name: "week", Type: Date},
+	}
+	rows := [][]interface{}{
+		{int64(1), "テスト食堂", time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)},
+		{int64(2), nil, time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)},
+	}
+
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, columns, rows); err != nil {
+		t.Fatalf("writeParquet() error = %v", err)
+	}
+	b := buf.Bytes()
+	if !bytes.HasPrefix(b, []byte(parquetMagic)) || !bytes.HasSuffix(b, []byte(parquetMagic)) {
+		t.Fatalf("file must start and end with %q", parquetMagic)
+	}
+	footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8 : len(b)-4]))
+	if footerLen <= 0 || footerLen > len(b)-12 {
+		t.Fatalf("invalid footer length %d for file of %d bytes", footerLen, len(b))
+	}
+	footer := b[len(b)-8-footerLen : len(b)-8]
+	if footer[len(footer)-1] != 0 {
+		t.Errorf("footer must end with a STOP field")
+	}
+}
+
+// TestWriteParquetRoundTripは書き込んだファイルを他の実装(parquet-go)で読み、スキーマと値、Optionalな列のnullが読めることを確認します。
+func TestWriteParquetRoundTrip(t *testing.T) {
+	columns := []Column{
+		{Name: "id", Type: Int64},
+		{Name: "mentions", Type: Int32, Optional: true},
+		{Name: "name", Type: String, Optional: true},
+		{Name: "score", Type: Double, Optional: true},
+		{Name: "week", Type: Date},
+		{Name: "created_at", Type: TimestampMillis, Optional: true},
+	}
+	week := time.Date(1969, 12, 29, 0, 0, 0, 0, time.UTC) // エポック前の日付は負の日数
+	created := time.Date(2024, 6, 3, 9, 30, 15, 250e6, time.UTC)
+	rows := [][]interface{}{
+		{int64(1), int32(3), "テスト食堂", 72.5, week, created},
+		{int64(2), nil, nil, nil, week, nil},
+		{int64(-3), int32(0), "", -1.25, week.AddDate(55, 0, 0), created},
+	}
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, columns, rows); err != nil {
+		t.Fatalf("writeParquet() error = %v", err)
+	}
+
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if f.NumRows() != int64(len(rows)) {
+		t.Errorf("NumRows() = %d, want %d", f.NumRows(), len(rows))
+	}
+	converted := map[ColumnType]deprecated.ConvertedType{String: deprecated.UTF8, Date: deprecated.Date, TimestampMillis: deprecated.TimestampMillis}
+	leaves := make([]parquet.LeafColumn, len(columns))
+	for i, col := range columns {
+		leaf, ok := f.Schema().Lookup(col.Name)
+		if !ok {
+			t.Fatalf("column %s not found in %v", col.Name, f.Schema())
+		}
+		if leaf.Node.Optional() != col.Optional {
+			t.Errorf("column %s: optional = %v, want %v", col.Name, leaf.Node.Optional(), col.Optional)
+		}
+		if want, ok := converted[col.Type]; ok {
+			if got := leaf.Node.Type().ConvertedType(); got == nil || *got != want {
+				t.Errorf("column %s: converted type = %v, want %v", col.Name, got, want)
+			}
+		}
+		leaves[i] = leaf
+	}
+
+	read := make([]parquet.Row, len(rows)+1)
+	n, err := parquet.NewReader(bytes.NewReader(buf.Bytes())).ReadRows(read)
+	if err != nil && !errors.Is(err, io.EOF) {
+		t.Fatalf("ReadRows() error = %v", err)
+	}
+	if n != len(rows) {
+		t.Fatalf("ReadRows() = %d rows, want %d", n, len(rows))
+	}
+	for ri, row := range rows {
+		for ci, col := range columns {
+			v := read[ri][leaves[ci].ColumnIndex]
+			if want := row[ci]; want == nil || v.IsNull() {
+				if (want == nil) != v.IsNull() {
+					t.Errorf("row %d column %s: null = %v, want %v", ri, col.Name, v.IsNull(), want == nil)
+				}
+				continue
+			}
+			var got interface{}
+			switch col.Type {
+			case Int32:
+				got = v.Int32()
+			case Int64:
+				got = v.Int64()
+			case Double:
+				got = v.Double()
+			case String:
+				got = string(v.ByteArray())
+			case Date:
+				got = time.Unix(int64(v.Int32())*86400, 0).UTC()
+			case TimestampMillis:
+				got = time.UnixMilli(v.Int64()).UTC()
+			}
+			if got != row[ci] {
+				t.Errorf("row %d column %s = %v, want %v", ri, col.Name, got, row[ci])
+			}
+		}
+	}
+}
+
+func TestWriteParquetRejectsNullInRequiredColumn(t *testing.T) {
+	columns := []Column{{Name: "id", Type: Int64}}
+	if err := writeParquet(&bytes.Buffer{}, columns, [][]interface{}{{nil}}); err == nil {
+		t.Fatal("writeParquet() should fail for null in required column")
+	}
+}
+
+func TestEncodeLevels(t *testing.T) {
+	got := encodeLevels([]byte{1, 1, 1, 0, 1})
+	want := []byte{3 << 1, 1, 1 << 1, 0, 1 << 1, 1}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeLevels() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package analytics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"excavation_service/internal/app/objectstore"
+
+	"gorm.io/gorm"
+)
+
+// 生データは再処理や機械学習の実験に使うため、加工せずに1行1件のJSON(JSONL)をgzipで圧縮して書き出します。
+// 出力は日付のパーティション(例: raw/crawl_items/dt=2024-06-05/crawl_items.jsonl.gz)です。
+const (
+	rawContentType = "application/gzip"
+	rawDateLayout  = "2006-01-02"
+)
+
+// RawSearchResponse は検索APIのレスポンスそのものです。
+type RawSearchResponse struct {
+	RunID     string          `json:"run_id"`
+	Query     string          `json:"query"`
+	URL       string          `json:"url"`
+	Status    int             `json:"status"`
+	FetchedAt time.Time       `json:"fetched_at"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// RawRecorder は発掘の実行中の検索APIのレスポンスを集め、実行の終了時にまとめて書き出します。
+type RawRecorder struct {
+	runID string
+
+	mu        sync.Mutex
+	responses []RawSearchResponse
+}
+
+func NewRawRecorder(runID string) *RawRecorder {
+	return &RawRecorder{runID: runID}
+}
+
+// Recordは検索APIのレスポンスを記録します。bodyがJSONでなければ文字列として記録します。
+func (r *RawRecorder) Record(query, url string, status int, body []byte, fetchedAt time.Time) {
+	raw := json.RawMessage(body)
+	if !json.Valid(body) {
+		raw, _ = json.Marshal(string(body))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses = append(r.responses, RawSearchResponse{RunID: r.runID, Query: query, URL: url, Status: status, FetchedAt: fetchedAt, Body: raw})
+}
+
+// Flushは記録したレスポンスをraw/search_responses/dt=<startedAtの日付>/run_id=<実行のID>.jsonl.gzに書き出し、書き出した件数を返します。
+// 記録したレスポンスがなければ何も書き出しません。
+func (r *RawRecorder) Flush(ctx context.Context, store objectstore.Store, startedAt time.Time) (int, error) {
+	r.mu.Lock()
+	responses := r.responses
+	r.responses = nil
+	r.mu.Unlock()
+	if len(responses) == 0 {
+		return 0, nil
+	}
+
+	body, err := gzipJSONL(responses)
+	if err != nil {
+		return 0, err
+	}
+	key := fmt.Sprintf("raw/search_responses/dt=%s/run_id=%s.jsonl.gz", startedAt.Format(rawDateLayout), r.runID)
+	if err := store.Put(ctx, key, body, rawContentType); err != nil {
+		return 0, err
+	}
+	return len(responses), nil
+}
+
+// CrawlItem はクロールで見つけた店舗への言及です。
+type CrawlItem struct {
+	ID         uint      `json:"id"`
+	StoreID    uint      `json:"store_id"`
+	StoreName  string    `json:"store_name"`
+	StoreURL   string    `json:"store_url"`
+	TopicID    uint      `json:"topic_id"`
+	Topic      string    `json:"topic"`
+	Week       time.Time `json:"week"`
+	SourceURL  string    `json:"source_url"`
+	SourceType string    `json:"source_type"`
+	RunID      string    `json:"run_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ExportCrawlItemsはday(ローカル時刻の0時)から1日の間に記録した店舗への言及を
+// raw/crawl_items/dt=<日付>/crawl_items.jsonl.gzに書き出し、書き出した件数を返します。同じ日のファイルは上書きします。
+func ExportCrawlItems(ctx context.Context, db *gorm.DB, store objectstore.Store, day time.Time) (int, error) {
+	var items []CrawlItem
+	err := db.WithContext(ctx).Table("store_mentions AS m").
+		Select("m.id, m.store_id, s.name AS store_name, s.url AS store_url, m.topic_id, et.topic, m.week, m.source_url, m.source_type, COALESCE(m.run_id, '') AS run_id, m.created_at").
+		Joins("JOIN stores AS s ON s.id = m.store_id").
+		Joins("JOIN entity_topics AS et ON et.id = m.topic_id").
+		Where("m.created_at >= ? AND m.created_at < ?", day, day.AddDate(0, 0, 1)).
+		Order("m.id").
+		Scan(&items).Error
+	if err != nil {
+		return 0, fmt.Errorf("query crawl items: %w", err)
+	}
+
+	body, err := gzipJSONL(items)
+	if err != nil {
+		return 0, err
+	}
+	key := fmt.Sprintf("raw/crawl_items/dt=%s/crawl_items.jsonl.gz", day.Format(rawDateLayout))
+	if err := store.Put(ctx, key, body, rawContentType); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// gzipJSONLはrowsを1行1件のJSONにしてgzipで圧縮します。
+func gzipJSONL[T any](rows []T) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	enc.SetEscapeHTML(false)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
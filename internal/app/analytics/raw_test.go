@@ -0,0 +1,60 @@
+package analytics
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/objectstore"
+)
+
+func TestRawRecorderFlush(t *testing.T) {
+	dir := t.TempDir()
+	store := objectstore.LocalStore{Dir: dir}
+	startedAt := time.Date(2024, 6, 5, 9, 30, 0, 0, time.Local)
+
+	recorder := NewRawRecorder("run-1")
+	if n, err := recorder.Flush(context.Background(), store, startedAt); err != nil || n != 0 {
+		t.Fatalf("Flush() with no responses = %d, %v, want 0, nil", n, err)
+	}
+	recorder.Record("渋谷 ラーメン 食べログ", "https://api.example.com/search?q=a", 200, []byte(`{"web":{"results":[]}}`), startedAt)
+	recorder.Record("新宿 カレー 食べログ", "https://api.example.com/search?q=b", 429, []byte("rate limited"), startedAt)
+	n, err := recorder.Flush(context.Background(), store, startedAt)
+	if err != nil || n != 2 {
+		t.Fatalf("Flush() = %d, %v, want 2, nil", n, err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "raw/search_responses/dt=2024-06-05/run_id=run-1.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []RawSearchResponse
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		var r RawSearchResponse
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, r)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d lines, want 2", len(got))
+	}
+	if got[0].RunID != "run-1" || got[0].Status != 200 || string(got[0].Body) != `{"web":{"results":[]}}` {
+		t.Errorf("line 1 = %+v", got[0])
+	}
+	// JSONでないレスポンスは文字列として記録する
+	if got[1].Status != 429 || string(got[1].Body) != `"rate limited"` {
+		t.Errorf("line 2 = %+v", got[1])
+	}
+}
@@ -0,0 +1,153 @@
+// Package apperr はサービスの誤りの分類です。
+//
+// 外部のAPIの失敗や応答の解析の失敗は、ログに出力して空の結果を返す代わりに、分類(ErrUpstreamRateLimitedなど)で包んだ誤りとして返します。
+// 呼び出し元はerrors.Isで分類を判定でき、実行のトピックの結果・実行結果の通知・APIの応答・メトリクスでは分類のコード(Code)で扱います。
+//
+//	if resp.StatusCode != http.StatusOK {
+//		return apperr.Wrap(apperr.FromStatus(resp.StatusCode), "brave search", fmt.Errorf("status %d", resp.StatusCode))
+//	}
+package apperr
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net"
+	"net/http"
+)
+
+// Code は誤りの分類のコードです。DBやAPIの応答、メトリクスのキーに使うため、値は変更しないでください。
+type Code string
+
+const (
+	CodeUpstreamRateLimited Code = "upstream_rate_limited" // 外部のAPIのレート制限 (429)
+	CodeUpstreamAuth        Code = "upstream_auth"         // 外部のAPIの認証の失敗 (401, 403)
+	CodeUpstreamRejected    Code = "upstream_rejected"     // 外部のAPIがリクエストを受け付けなかった (その他の4xx)
+	CodeUpstreamUnavailable Code = "upstream_unavailable"  // 外部のAPIに接続できないか、5xxを返した
+	CodeParseFailed         Code = "parse_failed"          // 外部のAPIやページの応答を解析できなかった
+	CodeNoResults           Code = "no_results"            // 検索結果や店舗が見つからなかった
+	CodeTimeout             Code = "timeout"
+	CodeCanceled            Code = "canceled"
+	CodeInternal            Code = "internal" // 分類されていない誤り
+)
+
+// kind は誤りの分類です。Err*の値のみ作成します。
+type kind struct {
+	code Code
+	msg  string
+}
+
+func (k *kind) Error() string { return k.msg }
+
+// 誤りの分類。Wrapで包むか、errors.Isで判定に使います。
+var (
+	ErrUpstreamRateLimited error = &kind{CodeUpstreamRateLimited, "upstream rate limited"}
+	ErrUpstreamAuth        error = &kind{CodeUpstreamAuth, "upstream authentication failed"}
+	ErrUpstreamRejected    error = &kind{CodeUpstreamRejected, "upstream rejected the request"}
+	ErrUpstreamUnavailable error = &kind{CodeUpstreamUnavailable, "upstream unavailable"}
+	ErrParseFailed         error = &kind{CodeParseFailed, "parse failed"}
+	ErrNoResults           error = &kind{CodeNoResults, "no results"}
+	ErrTimeout             error = &kind{CodeTimeout, "timeout"}
+)
+
+// Error は分類(Kind)で包んだ誤りです。errors.IsはKindとErrのどちらにも一致します。
+type Error struct {
+	Kind error  // Err*のいずれか
+	Op   string // 失敗した処理 (例: "brave search")
+	Err  error  // 元の誤り。nilの場合もある
+}
+
+func (e *Error) Error() string {
+	s := e.Op + ": " + e.Kind.Error()
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// Wrapはerrをopの失敗としてkind(Err*)で包みます。errはnilでも構いません。
+func Wrap(kind error, op string, err error) error {
+	return &Error{Kind: kind, Op: op, Err: err}
+}
+
+// Upstreamは外部のAPIへのリクエストの失敗(http.Client.Doの誤り)を分類して包みます。
+// タイムアウトはErrTimeout、それ以外の接続の失敗はErrUpstreamUnavailableです。
+func Upstream(op string, err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return Wrap(ErrTimeout, op, err)
+	}
+	return Wrap(ErrUpstreamUnavailable, op, err)
+}
+
+// FromStatusは外部のAPIが返した成功以外のHTTPステータスに対応する分類を返します。
+func FromStatus(status int) error {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrUpstreamRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrUpstreamAuth
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrTimeout
+	case status >= 400 && status < 500:
+		return ErrUpstreamRejected
+	default:
+		return ErrUpstreamUnavailable
+	}
+}
+
+// CodeOfはerrの分類のコードを返します。errがnilなら空文字列です。
+// 分類が複数含まれる場合は最も外側のものを返し、分類のない誤りはctxの終了ならCodeTimeoutかCodeCanceled、それ以外はCodeInternalです。
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var k *kind
+	switch {
+	case errors.As(err, &k):
+		return k.code
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	default:
+		return CodeInternal
+	}
+}
+
+// HTTPStatusはcodeの誤りをこのサービスのAPIで返す際のHTTPステータスです。
+func HTTPStatus(code Code) int {
+	switch code {
+	case CodeUpstreamRateLimited:
+		return http.StatusTooManyRequests
+	case CodeUpstreamAuth, CodeUpstreamRejected, CodeUpstreamUnavailable, CodeParseFailed:
+		return http.StatusBadGateway
+	case CodeNoResults:
+		return http.StatusNotFound
+	case CodeTimeout:
+		return http.StatusGatewayTimeout
+	case CodeCanceled:
+		return 499 // クライアントが切断した (nginxの慣例)
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// metrics は処理と分類のコードごとの誤りの件数です。キーは"処理.コード"です。PPROF_ADDR設定時は/debug/varsからも参照できます。
+var metrics = expvar.NewMap("errors")
+
+// Countはopで起きたerrをコードごとに数え、そのコードを返します。errがnilなら数えません。
+func Count(op string, err error) Code {
+	code := CodeOf(err)
+	if code != "" {
+		metrics.Add(op+"."+string(code), 1)
+	}
+	return code
+}
@@ -0,0 +1,66 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCodeOf(t *testing.T) {
+	parse := Wrap(ErrParseFailed, "gpt score", errors.New("invalid character"))
+	cases := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"nil", nil, ""},
+		{"wrapped", parse, CodeParseFailed},
+		{"wrapped by fmt", fmt.Errorf("topic 1: %w", parse), CodeParseFailed},
+		// 外側の分類を優先する
+		{"nested", Wrap(ErrNoResults, "tabelog", Wrap(ErrUpstreamRateLimited, "brave search", nil)), CodeNoResults},
+		{"status", Wrap(FromStatus(http.StatusTooManyRequests), "brave search", nil), CodeUpstreamRateLimited},
+		{"deadline", fmt.Errorf("call gpt: %w", context.DeadlineExceeded), CodeTimeout},
+		{"upstream deadline", Upstream("call gpt", context.DeadlineExceeded), CodeTimeout},
+		{"upstream", Upstream("call gpt", errors.New("connection refused")), CodeUpstreamUnavailable},
+		{"canceled", context.Canceled, CodeCanceled},
+		{"unclassified", errors.New("save trend"), CodeInternal},
+	}
+	for _, c := range cases {
+		if got := CodeOf(c.err); got != c.want {
+			t.Errorf("%s: CodeOf(%v) = %q, want %q", c.name, c.err, got, c.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	cause := errors.New("unexpected EOF")
+	err := Wrap(ErrParseFailed, "brave search", cause)
+	if !errors.Is(err, ErrParseFailed) || !errors.Is(err, cause) {
+		t.Errorf("errors.Is(%v) should match both the kind and the cause", err)
+	}
+	if errors.Is(err, ErrNoResults) {
+		t.Errorf("errors.Is(%v, ErrNoResults) = true", err)
+	}
+	if got, want := err.Error(), "brave search: parse failed: unexpected EOF"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestFromStatus(t *testing.T) {
+	cases := map[int]error{
+		http.StatusTooManyRequests:     ErrUpstreamRateLimited,
+		http.StatusUnauthorized:        ErrUpstreamAuth,
+		http.StatusForbidden:           ErrUpstreamAuth,
+		http.StatusBadRequest:          ErrUpstreamRejected,
+		http.StatusGatewayTimeout:      ErrTimeout,
+		http.StatusInternalServerError: ErrUpstreamUnavailable,
+		http.StatusServiceUnavailable:  ErrUpstreamUnavailable,
+	}
+	for status, want := range cases {
+		if got := FromStatus(status); got != want {
+			t.Errorf("FromStatus(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package authtoken はAPIキーと引き換えに発行する、有効期限の短いアクセストークン(JWT)を扱います。
+//
+// ブラウザやダッシュボードのように長期間有効なAPIキーを持たせたくないクライアントは、
+// APIキーで一度だけトークンを取得し、以降のリクエストではトークンを送ります。
+// トークンはHS256で署名し、テナントとAPIキーのIDを含みます。
+package authtoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MinSecretLen は署名の鍵の最小の長さ(バイト)です。HS256の鍵はハッシュの長さ以上にします。
+const MinSecretLen = 32
+
+// トークンの検証の失敗
+var (
+	ErrInvalid = errors.New("invalid access token")
+	ErrExpired = errors.New("access token expired")
+)
+
+// header はHS256のみ受け付けます。alg=noneなどで署名を省いたトークンを拒否するためです。
+const header = `{"alg":"HS256","typ":"JWT"}`
+
+var encoding = base64.RawURLEncoding
+
+// Claims はトークンに含める内容です。
+type Claims struct {
+	Subject   string `json:"sub"` // api_key:<APIキーのID>
+	TenantID  uint   `json:"tenant_id"`
+	APIKeyID  uint   `json:"api_key_id"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Signer はトークンを発行・検証します。
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewSignerはsecretで署名し、ttlの間有効なトークンを発行するSignerを作成します。
+func NewSigner(secret string, ttl time.Duration) (*Signer, error) {
+	if len(secret) < MinSecretLen {
+		return nil, fmt.Errorf("JWT secret must be at least %d bytes", MinSecretLen)
+	}
+	if ttl <= 0 {
+		return nil, errors.New("access token TTL must be positive")
+	}
+	return &Signer{secret: []byte(secret), ttl: ttl}, nil
+}
+
+// Issueはテナントとそのキーのトークンを発行し、トークンと有効期限を返します。
+func (s *Signer) Issue(tenantID, apiKeyID uint, now time.Time) (string, time.Time, error) {
+	expires := now.Add(s.ttl)
+	payload, err := json.Marshal(Claims{
+		Subject:   fmt.Sprintf("api_key:%d", apiKeyID),
+		TenantID:  tenantID,
+		APIKeyID:  apiKeyID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	signed := encoding.EncodeToString([]byte(header)) + "." + encoding.EncodeToString(payload)
+	return signed + "." + s.sign(signed), expires, nil
+}
+
+// Verifyはトークンの署名と有効期限を確認し、内容を返します。
+// 署名が正しくなければErrInvalid、有効期限を過ぎていればErrExpiredを返します。
+func (s *Signer) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalid
+	}
+	if h, err := encoding.DecodeString(parts[0]); err != nil || string(h) != header {
+		return nil, ErrInvalid
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(parts[0]+"."+parts[1]))) {
+		return nil, ErrInvalid
+	}
+	payload, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.TenantID == 0 || c.APIKeyID == 0 {
+		return nil, ErrInvalid
+	}
+	if now.Unix() >= c.ExpiresAt {
+		return nil, ErrExpired
+	}
+	return &c, nil
+}
+
+func (s *Signer) sign(signed string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(signed))
+	return encoding.EncodeToString(mac.Sum(nil))
+}
+
+// IsTokenはvalueがAPIキーではなくトークンの形式(ドットで区切った3つの部分)かを返します。
+func IsToken(value string) bool {
+	return strings.Count(value, ".") == 2
+}
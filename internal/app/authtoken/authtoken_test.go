@@ -0,0 +1,61 @@
+package authtoken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestIssueAndVerify(t *testing.T) {
+	s, err := NewSigner(testSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	token, expires, err := s.Issue(2, 7, now)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if !expires.Equal(now.Add(time.Hour)) || !IsToken(token) {
+		t.Errorf("Issue() = %q, %v", token, expires)
+	}
+
+	c, err := s.Verify(token, now.Add(59*time.Minute))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if c.TenantID != 2 || c.APIKeyID != 7 || c.Subject != "api_key:7" {
+		t.Errorf("Verify() = %+v", c)
+	}
+	if _, err := s.Verify(token, now.Add(time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify() after expiry error = %v, want ErrExpired", err)
+	}
+
+	other, _ := NewSigner(strings.Repeat("x", MinSecretLen), time.Hour)
+	parts := strings.Split(token, ".")
+	noneHeader := encoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	forged, _, _ := other.Issue(2, 7, now)
+	for name, tok := range map[string]string{
+		"other secret":  forged,
+		"alg none":      noneHeader + "." + parts[1] + ".",
+		"tampered":      parts[0] + "." + encoding.EncodeToString([]byte(`{"tenant_id":1,"api_key_id":7,"exp":9999999999}`)) + "." + parts[2],
+		"api key":       "exk_0123456789abcdef",
+		"missing parts": parts[0] + "." + parts[1],
+	} {
+		if _, err := s.Verify(tok, now); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Verify(%s) error = %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	if _, err := NewSigner("short", time.Hour); err == nil {
+		t.Error("NewSigner() with a short secret should fail")
+	}
+	if _, err := NewSigner(testSecret, 0); err == nil {
+		t.Error("NewSigner() with zero TTL should fail")
+	}
+}
@@ -0,0 +1,142 @@
+// Package clock は現在時刻と定期的なタイマーを差し替えられるようにします。
+// 週の切り替えやscheduleの定期的な確認をテストで決まった時刻に起こせるよう、time.Nowやtime.NewTickerの代わりに使います。
+// 本番ではReal、テストではFakeを使います。
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock は現在時刻とタイマーの提供元です。
+type Clock interface {
+	Now() time.Time
+	// NewTickerはdごとに時刻を送るTickerを返します。time.NewTickerと同じく、受け取られなかった時刻は捨てます。
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker は定期的に時刻を送るタイマーです。
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real はtimeパッケージの時刻とタイマーを使うClockです。
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// OrRealはcがnilならRealを、そうでなければcを返します。オプションのClockの既定値に使います。
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// Fake はAdvanceやSetで進めるまで止まっているテスト用のClockです。
+// 進めた時刻までに期限が来たTickerには、期限の早い順に時刻を送ります。
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeは現在時刻がnowのFakeを作成します。
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{clock: f, c: make(chan time.Time, 1), interval: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, t)
+	f.cond.Broadcast()
+	return t
+}
+
+// Advanceは時刻をdだけ進めます。
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Setは時刻をtにします。tが現在時刻より前の場合は時刻を戻すのみで、Tickerには送りません。
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for {
+		// 期限の早いTickerから送り、同じTickerの期限が何度も来る場合も順に送る
+		sort.Slice(f.tickers, func(i, j int) bool { return f.tickers[i].next.Before(f.tickers[j].next) })
+		if len(f.tickers) == 0 || f.tickers[0].next.After(t) {
+			break
+		}
+		tk := f.tickers[0]
+		f.now = tk.next
+		select {
+		case tk.c <- tk.next:
+		default:
+		}
+		tk.next = tk.next.Add(tk.interval)
+	}
+	f.now = t
+}
+
+// WaitForTickersは動作中のTickerがn個以上になるまで待ちます。
+// 別のgoroutineがNewTickerを呼ぶ前に時刻を進めてしまわないよう、テストで時刻を進める前に呼びます。
+func (f *Fake) WaitForTickers(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.tickers) < n {
+		f.cond.Wait()
+	}
+}
+
+type fakeTicker struct {
+	clock    *Fake
+	c        chan time.Time
+	interval time.Duration
+	next     time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	f := t.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, other := range f.tickers {
+		if other == t {
+			f.tickers = append(f.tickers[:i], f.tickers[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeTicker(t *testing.T) {
+	start := time.Date(2024, 6, 9, 23, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	ticker := f.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	f.Advance(59 * time.Minute)
+	select {
+	case got := <-ticker.C():
+		t.Fatalf("ticked at %s before the interval", got)
+	default:
+	}
+
+	// 期限を2回過ぎても、受け取られなかった時刻は捨てる
+	f.Advance(2 * time.Hour)
+	if got := <-ticker.C(); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("tick = %s, want %s", got, start.Add(time.Hour))
+	}
+	select {
+	case got := <-ticker.C():
+		t.Errorf("unexpected second tick %s", got)
+	default:
+	}
+	if got := f.Now(); !got.Equal(start.Add(179 * time.Minute)) {
+		t.Errorf("Now = %s", got)
+	}
+
+	ticker.Stop()
+	f.Advance(time.Hour)
+	select {
+	case got := <-ticker.C():
+		t.Errorf("stopped ticker ticked at %s", got)
+	default:
+	}
+}
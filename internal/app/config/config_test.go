@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "database_url: postgres://file\nexport_weeks: 4\nlog_level: debug\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("PORT", "")
+
+	cfg, err := Load("test", []string{"-env", "staging", "-log-level", "error"}, DatabaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DatabaseURL != "postgres://file" {
+		t.Errorf("DatabaseURL = %q, want value from file", cfg.DatabaseURL)
+	}
+	if cfg.ExportWeeks != 4 {
+		t.Errorf("ExportWeeks = %d, want 4", cfg.ExportWeeks)
+	}
+	if cfg.LogLevel != "error" {
+		t.Errorf("LogLevel = %q, want flag to override env and file", cfg.LogLevel)
+	}
+	if cfg.LogFormat != "json" {
+		t.Errorf("LogFormat = %q, want staging profile default", cfg.LogFormat)
+	}
+	if cfg.Port != "8080" || cfg.RetentionStoreMentionsDays != 90 {
+		t.Errorf("defaults not applied: port=%q retention=%d", cfg.Port, cfg.RetentionStoreMentionsDays)
+	}
+}
+
+func TestLoadAggregatesProblems(t *testing.T) {
+	// prodではDATABASE_URLの既定値がない
+	t.Setenv("APP_ENV", "prod")
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("EXPORT_WEEKS", "0")
+	t.Setenv("LOG_FORMAT", "xml")
+
+	_, err := Load("test", nil, DatabaseURL, BraveAPIKey)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want *ValidationError", err)
+	}
+	want := []string{"LOG_FORMAT", "EXPORT_WEEKS", "DATABASE_URL is required", "BRAVE_API_KEY is required"}
+	if len(verr.Problems) != len(want) {
+		t.Fatalf("problems = %q, want %d problems", verr.Problems, len(want))
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(verr.Problems[i], w) {
+			t.Errorf("problems[%d] = %q, want prefix %q", i, verr.Problems[i], w)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+// loadDotenvは.envと.env.<プロファイル>を読み込み、まだ設定されていない環境変数に設定します。
+// 既に設定されている環境変数は上書きしないため、シェルやコンテナで指定した値が常に優先されます。
+// 返り値はプロファイル名で、envFlag・環境変数APP_ENV・.envのAPP_ENV・DefaultEnvの順に決まります。
+func loadDotenv(envFlag string) (string, error) {
+	base, err := readDotenv(".env")
+	if err != nil {
+		return "", err
+	}
+
+	env := envFlag
+	if env == "" {
+		env = os.Getenv("APP_ENV")
+	}
+	if env == "" {
+		env = base["APP_ENV"]
+	}
+	if env == "" {
+		env = DefaultEnv
+	}
+
+	overrides, err := readDotenv(".env." + env)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range overrides {
+		base[k] = v
+	}
+	for k, v := range base {
+		if _, set := os.LookupEnv(k); set {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return "", err
+		}
+	}
+	return env, nil
+}
+
+// readDotenvはpathの.envファイルを読み込みます。ファイルがなければ空のmapを返します。
+func readDotenv(path string) (map[string]string, error) {
+	values, err := godotenv.Read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return values, nil
+}
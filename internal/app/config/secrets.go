@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// SecretResolver は設定値に書かれた秘密情報の参照(ssm:やvault:で始まる値)を実際の値に置き換えます。
+// secrets.Resolverが満たします。
+type SecretResolver interface {
+	Resolve(ctx context.Context, value string) (string, error)
+}
+
+// ResolveSecretsはAPIキー・DBとRedisの接続情報・GoogleのOAuthクライアントのシークレット・SlackのWebhook・メール・LINE・Discordの認証情報に書かれた秘密情報の参照を、取得した値に置き換えます。
+// DatabasePasswordが設定されていれば、DatabaseURLのパスワードをその値で置き換えます。
+// 取得に失敗した項目はすべてまとめて*ValidationErrorとして返します。
+func (c *Config) ResolveSecrets(ctx context.Context, r SecretResolver) error {
+	fields := []struct {
+		key string
+		dst *string
+	}{
+		{DatabaseURL, &c.DatabaseURL},
+		{"DATABASE_PASSWORD", &c.DatabasePassword},
+		{"JWT_SECRET", &c.JWTSecret},
+		{"GOOGLE_CLIENT_SECRET", &c.GoogleClientSecret},
+		{BraveAPIKey, &c.BraveAPIKey},
+		{OpenAIAPIKey, &c.OpenAIAPIKey},
+		{"SLACK_WEBHOOK_URL", &c.SlackWebhookURL},
+		{"SMTP_PASSWORD", &c.SMTPPassword},
+		{"SENDGRID_API_KEY", &c.SendGridAPIKey},
+		{"LINE_CHANNEL_ACCESS_TOKEN", &c.LINEChannelAccessToken},
+		{"DISCORD_WEBHOOK_URL", &c.DiscordWebhookURL},
+		{"DISCORD_BOT_TOKEN", &c.DiscordBotToken},
+		{"MEILISEARCH_API_KEY", &c.MeilisearchAPIKey},
+		{"GOOGLE_MAPS_API_KEY", &c.GoogleMapsAPIKey},
+		{"DEDUP_REDIS_URL", &c.DedupRedisURL},
+		{"RATE_LIMIT_REDIS_URL", &c.RateLimitRedisURL},
+	}
+	var problems []string
+	for _, f := range fields {
+		v, err := r.Resolve(ctx, *f.dst)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", f.key, err))
+			continue
+		}
+		*f.dst = v
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	if c.DatabasePassword != "" && c.DatabaseURL != "" {
+		u, err := url.Parse(c.DatabaseURL)
+		if err != nil {
+			return &ValidationError{Problems: []string{fmt.Sprintf("%s: invalid URL: %v", DatabaseURL, err)}}
+		}
+		u.User = url.UserPassword(u.User.Username(), c.DatabasePassword)
+		c.DatabaseURL = u.String()
+	}
+	return nil
+}
+
+// Secretsはログやエラーメッセージに出力してはならない設定値(APIキー、パスワード、Webhook)を返します。
+func (c *Config) Secrets() []string {
+	values := []string{c.BraveAPIKey, c.OpenAIAPIKey, c.DatabasePassword, c.JWTSecret, c.GoogleClientSecret, c.SlackWebhookURL, c.SMTPPassword, c.SendGridAPIKey,
+		c.LINEChannelAccessToken, c.DiscordWebhookURL, c.DiscordBotToken, c.MeilisearchAPIKey, c.GoogleMapsAPIKey}
+	for _, u := range c.SlackAreaWebhooks {
+		values = append(values, u)
+	}
+	for _, raw := range []string{c.DatabaseURL, c.DedupRedisURL, c.RateLimitRedisURL} {
+		if u, err := url.Parse(raw); err == nil && u.User != nil {
+			if password, ok := u.User.Password(); ok {
+				values = append(values, password)
+			}
+		}
+	}
+	return values
+}
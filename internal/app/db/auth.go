@@ -0,0 +1,242 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"excavation_service/internal/app/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/lib/pq"
+)
+
+// DBの認証方式 (DATABASE_AUTH)
+const (
+	AuthPassword    = "password"     // DATABASE_URL(またはDATABASE_PASSWORD)のパスワード
+	AuthRDSIAM      = "rds_iam"      // Amazon RDSのIAMデータベース認証のトークン
+	AuthCloudSQLIAM = "cloudsql_iam" // Cloud SQLのIAMデータベース認証(サービスアカウントのアクセストークン)
+)
+
+const (
+	rdsTokenExpiry   = 15 * time.Minute
+	metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// Options はDBへの接続の設定です。ゼロ値ならDATABASE_URLのとおりにパスワードで接続します。
+type Options struct {
+	SSLMode     string // disable, require, verify-ca, verify-full。空ならDATABASE_URLのsslmodeに従う
+	SSLRootCert string // サーバー証明書を検証するCA証明書のファイル。空ならDATABASE_URLのsslrootcertに従う
+	Auth        string // 認証方式 (Auth*)。空ならAuthPassword
+}
+
+// iam はパスワードの代わりに接続ごとにトークンを取得する認証方式かを返します。
+func (o Options) iam() bool {
+	return o.Auth != "" && o.Auth != AuthPassword
+}
+
+// connectionURLはdatabaseURLにoptsのTLSの設定を反映したURLを返します。TLSの設定がなくパスワードで認証する場合はdatabaseURLをそのまま返します。
+// IAM認証ではURLのパスワードを取り除き、トークンを平文で送らないようTLSを無効にする設定を拒否します。
+// エラーにはパスワードが含まれないよう、URLの値を含めません。
+func connectionURL(databaseURL string, opts Options) (string, error) {
+	if opts.SSLMode == "" && opts.SSLRootCert == "" && !opts.iam() {
+		return databaseURL, nil
+	}
+	u, err := url.Parse(databaseURL)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return "", fmt.Errorf("database URL must be a postgres:// URL to apply TLS or IAM options")
+	}
+	q := u.Query()
+	if opts.SSLMode != "" {
+		q.Set("sslmode", opts.SSLMode)
+	}
+	if opts.SSLRootCert != "" {
+		q.Set("sslrootcert", opts.SSLRootCert)
+	}
+	if opts.iam() {
+		if q.Get("sslmode") == "disable" {
+			return "", fmt.Errorf("%s authentication requires TLS (sslmode must not be disable)", opts.Auth)
+		}
+		if u.User == nil || u.User.Username() == "" {
+			return "", fmt.Errorf("%s authentication requires a user name in the database URL", opts.Auth)
+		}
+		u.User = url.User(u.User.Username())
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// newConnectorはoptsの認証方式でdatabaseURLのDBに接続するConnectorを作成します。
+func newConnector(ctx context.Context, databaseURL string, opts Options) (driver.Connector, error) {
+	dsn, err := connectionURL(databaseURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Auth {
+	case "", AuthPassword:
+		return pq.NewConnector(dsn)
+	case AuthRDSIAM:
+		u, _ := url.Parse(dsn)
+		tokens, err := newRDSTokens(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		return &iamConnector{dsn: u, token: tokens.token}, nil
+	case AuthCloudSQLIAM:
+		u, _ := url.Parse(dsn)
+		tokens := &cloudSQLTokens{tokenURL: metadataTokenURL, client: tracing.NewHTTPClient(10 * time.Second)}
+		return &iamConnector{dsn: u, token: tokens.token}, nil
+	default:
+		return nil, fmt.Errorf("unknown database auth %q", opts.Auth)
+	}
+}
+
+// iamConnector は接続ごとにトークンを取得し、パスワードとして使って接続します。
+// トークンには有効期限があるため、プールが新しく接続するたびに取得し直します。確立済みの接続は期限後も使えます。
+type iamConnector struct {
+	dsn   *url.URL
+	token func(ctx context.Context) (string, error)
+}
+
+func (c *iamConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	token, err := c.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u := *c.dsn
+	u.User = url.UserPassword(c.dsn.User.Username(), token)
+	connector, err := pq.NewConnector(u.String())
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *iamConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// rdsTokens はRDSのIAMデータベース認証のトークンを作成します。
+// トークンはrds-db:connectの署名付きURLで、標準のAWS認証情報(環境変数、共有設定ファイル、IAMロール)で署名します。
+type rdsTokens struct {
+	endpoint    string // host:port
+	user        string
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+}
+
+// newRDSTokensはURLuのホスト・ポート・ユーザーのトークンを作成するrdsTokensを返します。
+// リージョンが設定されていなければRDSのホスト名から判定します。
+func newRDSTokens(ctx context.Context, u *url.URL) (*rdsTokens, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = rdsRegion(u.Hostname())
+	}
+	if region == "" {
+		return nil, fmt.Errorf("aws region is not configured (set AWS_REGION)")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "5432"
+	}
+	return &rdsTokens{
+		endpoint:    net.JoinHostPort(u.Hostname(), port),
+		user:        u.User.Username(),
+		region:      region,
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+	}, nil
+}
+
+// rdsRegionはRDSのエンドポイント(<インスタンス>.<ID>.<リージョン>.rds.amazonaws.com)からリージョンを返します。
+func rdsRegion(host string) string {
+	parts := strings.Split(host, ".")
+	if n := len(parts); n >= 5 && parts[n-3] == "rds" && parts[n-2] == "amazonaws" {
+		return parts[n-4]
+	}
+	return ""
+}
+
+func (t *rdsTokens) token(ctx context.Context) (string, error) {
+	creds, err := t.credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("rds iam token: retrieve credentials: %w", err)
+	}
+	q := url.Values{
+		"Action":        {"connect"},
+		"DBUser":        {t.user},
+		"X-Amz-Expires": {fmt.Sprint(int(rdsTokenExpiry.Seconds()))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+t.endpoint+"/?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(nil)
+	signed, _, err := t.signer.PresignHTTP(ctx, creds, req, hex.EncodeToString(sum[:]), "rds-db", t.region, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("rds iam token: sign: %w", err)
+	}
+	return strings.TrimPrefix(signed, "https://"), nil
+}
+
+// cloudSQLTokens はメタデータサーバーから取得したサービスアカウントのアクセストークンを返します。
+// DATABASE_URLのユーザーにはサービスアカウントのIAMユーザー(<名前>@<プロジェクト>.iam)を指定します。
+type cloudSQLTokens struct {
+	tokenURL string
+	client   *http.Client
+
+	mu      sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// tokenはアクセストークンを返します。期限が近ければ取得し直します。
+func (t *cloudSQLTokens) token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if t.value != "" && now.Add(time.Minute).Before(t.expires) {
+		return t.value, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cloud sql iam token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("cloud sql iam token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("cloud sql iam token: %w", err)
+	}
+	t.value, t.expires = token.AccessToken, now.Add(time.Duration(token.ExpiresIn)*time.Second)
+	return t.value, nil
+}
@@ -1,49 +1,65 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"time" // timeパッケージを追加
 
+	"excavation_service/internal/app/tracing"
+
 	_ "github.com/lib/pq" // PostgreSQLドライバ
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
-func ConnectDatabase() (*sql.DB, error) {
-	databaseURL := os.Getenv("DATABASE_URL")
+// ConnectDatabaseはdatabaseURLのデータベースにoptsのTLSと認証方式で接続します。起動直後はDBの準備ができていないことがあるため、接続できるまでリトライします。
+func ConnectDatabase(databaseURL string, opts Options) (*sql.DB, error) {
 	if databaseURL == "" {
-		return nil, fmt.Errorf("DATABASE_URL environment variable not set")
+		return nil, fmt.Errorf("database URL is empty")
+	}
+	connector, err := newConnector(context.Background(), databaseURL, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	var db *sql.DB
-	var err error
 	maxRetries := 10 // 最大リトライ回数
 	retryInterval := 5 * time.Second // リトライ間隔
 
 	// データベースに接続（リトライ付き）
 	for i := 0; i < maxRetries; i++ {
-		log.Printf("Attempting to connect to database (Attempt %d/%d)...", i+1, maxRetries)
-		db, err = sql.Open("postgres", databaseURL)
-		if err != nil {
-			log.Printf("Failed to open database connection: %v. Retrying in %s...", err, retryInterval)
-			time.Sleep(retryInterval)
-			continue // 次のリトライへ
-		}
+		slog.Info("Attempting to connect to database", "attempt", i+1, "max_retries", maxRetries, "auth", opts.Auth)
+		db = sql.OpenDB(connector)
 
 		// 接続の確認（Ping）
 		if err = db.Ping(); err != nil {
 			db.Close() // Pingに失敗したら接続を閉じる
-			log.Printf("Failed to ping database: %v. Retrying in %s...", err, retryInterval)
+			slog.Warn("Failed to ping database. Retrying...", "error", err, "retry_in", retryInterval)
 			time.Sleep(retryInterval)
 			continue // 次のリトライへ
 		}
 
 		// 接続成功
-		fmt.Println("Successfully connected to database!")
+		slog.Info("Successfully connected to database!")
 		return db, nil
 	}
 
 	// 最大リトライ回数を超えても接続できなかった場合
 	return nil, fmt.Errorf("failed to connect to database after %d retries", maxRetries)
-}
\ No newline at end of file
+}
+
+// OpenGormは接続済みの*sql.DBをGORMから利用できるようにラップします。
+// クエリはdb.WithContext(ctx)で渡されたトレースのスパンとして記録され、失敗したクエリと遅いクエリはそのコンテキストのロガーに出力されます。
+func OpenGorm(sqlDB *sql.DB) (*gorm.DB, error) {
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: gormLogger{level: gormlogger.Warn}})
+	if err != nil {
+		return nil, err
+	}
+	if err := gormDB.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	return gormDB, nil
+}
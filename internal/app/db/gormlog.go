@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"excavation_service/internal/app/logging"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold を超えたクエリは遅いクエリとしてログに出力します。
+const slowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger はGORMのログをdb.WithContext(ctx)で渡されたコンテキストのロガー(logging.FromContext)に出力します。
+// APIのリクエストの処理ではリクエストIDが付くため、失敗したクエリや遅いクエリをリクエストのログと結び付けられます。
+// レコードが見つからないのは呼び出し側で扱う正常系のため出力しません。
+type gormLogger struct {
+	level gormlogger.LogLevel
+}
+
+func (l gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return gormLogger{level: level}
+}
+
+func (l gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		logging.FromContext(ctx).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		logging.FromContext(ctx).Warn(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		logging.FromContext(ctx).Error(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		sql, rows := fc()
+		logging.FromContext(ctx).Warn("クエリに失敗しました", "sql", sql, "rows", rows, "elapsed", elapsed, "error", err)
+	case elapsed > slowQueryThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		logging.FromContext(ctx).Warn("遅いクエリ", "sql", sql, "rows", rows, "elapsed", elapsed)
+	case l.level >= gormlogger.Info:
+		sql, rows := fc()
+		logging.FromContext(ctx).Debug("クエリ", "sql", sql, "rows", rows, "elapsed", elapsed)
+	}
+}
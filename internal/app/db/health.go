@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pingTimeout は接続確認1回あたりのタイムアウトです。
+const pingTimeout = 5 * time.Second
+
+var (
+	publishStats sync.Once
+	statsDB      atomic.Pointer[sql.DB]
+)
+
+// Monitor はAPIサーバーなど長時間動くプロセスでDBへの接続を定期的に確認します。
+// *sql.DBは切れた接続を自動的に破棄して接続し直しますが、DBが再起動している間などは
+// リクエストごとにエラーになるため、接続できない状態をヘルスチェックやログで把握できるようにします。
+type Monitor struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// NewMonitorはsqlDBを確認するMonitorを作成し、接続プールの統計をexpvarの"db"として公開します。
+// 統計はPPROF_ADDR設定時に/debug/varsから参照できます。
+func NewMonitor(sqlDB *sql.DB) *Monitor {
+	m := &Monitor{db: sqlDB}
+	m.healthy.Store(true)
+	statsDB.Store(sqlDB)
+	publishStats.Do(func() {
+		expvar.Publish("db", expvar.Func(func() any {
+			return poolStats(statsDB.Load())
+		}))
+	})
+	return m
+}
+
+// poolStatsは接続プールの統計を/debug/varsで読みやすい形に変換します。
+func poolStats(sqlDB *sql.DB) map[string]any {
+	if sqlDB == nil {
+		return nil
+	}
+	s := sqlDB.Stats()
+	return map[string]any{
+		"max_open":             s.MaxOpenConnections,
+		"open":                 s.OpenConnections,
+		"in_use":               s.InUse,
+		"idle":                 s.Idle,
+		"wait_count":           s.WaitCount,
+		"wait_duration_ms":     s.WaitDuration.Milliseconds(),
+		"max_idle_closed":      s.MaxIdleClosed,
+		"max_idle_time_closed": s.MaxIdleTimeClosed,
+		"max_lifetime_closed":  s.MaxLifetimeClosed,
+	}
+}
+
+// Healthyは直近の接続確認に成功していればtrueを返します。
+func (m *Monitor) Healthy() bool {
+	return m.healthy.Load()
+}
+
+// Pingは今DBに接続できるかを確認します。Runの確認の間隔を待たずに確かめたいレディネスチェックで使います。
+func (m *Monitor) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return m.db.PingContext(ctx)
+}
+
+// Runはctxがキャンセルされるまでintervalごとに接続を確認します。
+// 接続できない間は、回復を早く検知するため間隔を1秒から倍にしながらintervalまで短くして確認し直します。
+func (m *Monitor) Run(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	wait := interval
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err := m.db.PingContext(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		wasHealthy := m.healthy.Swap(err == nil)
+		switch {
+		case err != nil && wasHealthy:
+			logger.Error("DBに接続できません。接続し直します", "error", err, "stats", poolStats(m.db))
+			wait = time.Second
+		case err != nil:
+			wait = min(wait*2, interval)
+			logger.Warn("DBへの再接続に失敗しました", "error", err, "retry_in", wait)
+		case !wasHealthy:
+			logger.Info("DBへの接続が回復しました")
+			wait = interval
+		}
+	}
+}
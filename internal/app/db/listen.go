@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// pqは問い合わせの応答を読むときにのみ通知を受け取るため、listenPollIntervalごとに空の問い合わせを送る
+	listenPollInterval = time.Second
+	listenRetryWait    = 5 * time.Second
+)
+
+// ListenはsqlDBの接続の1つでchannelをLISTENし、NOTIFYを受け取るたびに内容をhandleに渡します。
+// 接続が切れたら接続し直し、ctxがキャンセルされるまで戻りません。handleは受信を止めて呼び出すため、すぐに返してください。
+// 他のプロセス(発掘やoutboxのRelay)からAPIサーバーのすべてのプロセスに知らせるために使います。
+func Listen(ctx context.Context, logger *slog.Logger, sqlDB *sql.DB, channel string, handle func(payload string)) {
+	for {
+		err := listen(ctx, sqlDB, channel, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warn("通知の受信が途切れました。接続し直します", "channel", channel, "error", err, "retry_in", listenRetryWait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryWait):
+		}
+	}
+}
+
+// listenは接続を1つ占有してLISTENし、ctxがキャンセルされるか接続が失敗するまで通知を受け取ります。
+func listen(ctx context.Context, sqlDB *sql.DB, channel string, handle func(payload string)) error {
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// LISTENしたままの接続をプールに戻さないよう、接続を捨てる
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+		conn.Close()
+	}()
+	err = conn.Raw(func(dc any) error {
+		c, ok := dc.(driver.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", dc)
+		}
+		pq.SetNotificationHandler(c, func(n *pq.Notification) { handle(n.Extra) })
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "LISTEN "+pq.QuoteIdentifier(channel)); err != nil {
+		return fmt.Errorf("listen %s: %w", channel, err)
+	}
+
+	ticker := time.NewTicker(listenPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if _, err := conn.ExecContext(ctx, "SELECT 1"); err != nil {
+			return err
+		}
+	}
+}
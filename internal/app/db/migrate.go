@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Migrateはfsysの*.up.sqlをファイル名の順に適用します。適用済みのものはschema_migrationsに記録し、次回以降は適用しません。
+// 各ファイルは1つのトランザクションで適用するため、失敗したファイルの変更は残りません。
+func Migrate(ctx context.Context, sqlDB *sql.DB, fsys fs.FS) ([]string, error) {
+	if _, err := sqlDB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version TEXT PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	pending, err := PendingMigrations(ctx, sqlDB, fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []string
+	for _, version := range pending {
+		body, err := fs.ReadFile(fsys, version+".up.sql")
+		if err != nil {
+			return applied, err
+		}
+		if err := applyMigration(ctx, sqlDB, version, string(body)); err != nil {
+			return applied, fmt.Errorf("apply migration %s: %w", version, err)
+		}
+		slog.Info("マイグレーションを適用しました", "version", version)
+		applied = append(applied, version)
+	}
+	return applied, nil
+}
+
+// PendingMigrationsはfsysの*.up.sqlのうち、まだ適用されていないもののバージョンをファイル名の順に返します。
+// schema_migrationsがなければ(一度もマイグレーションしていなければ)すべてを返します。
+func PendingMigrations(ctx context.Context, sqlDB *sql.DB, fsys fs.FS) ([]string, error) {
+	files, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var tableExists bool
+	if err := sqlDB.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&tableExists); err != nil {
+		return nil, fmt.Errorf("check schema_migrations: %w", err)
+	}
+
+	var pending []string
+	for _, name := range files {
+		version := strings.TrimSuffix(path.Base(name), ".up.sql")
+		var exists bool
+		if tableExists {
+			if err := sqlDB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&exists); err != nil {
+				return nil, fmt.Errorf("check migration %s: %w", version, err)
+			}
+		}
+		if !exists {
+			pending = append(pending, version)
+		}
+	}
+	return pending, nil
+}
+
+func applyMigration(ctx context.Context, sqlDB *sql.DB, version, body string) error {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,64 @@
+// Package dedup は発掘で同じ店舗やページを1回だけ処理するための、処理済みのキーの記録です。
+//
+// 記録は複数のgoroutineから同時に使えます。Redisに記録すると、同じスコープを使う複数のワーカーの間でも重複を除けます。
+package dedup
+
+import (
+	"context"
+	"sync"
+)
+
+// Set は処理済みのキーの集合です。
+type Set interface {
+	// Addはkeyを記録し、新しく記録した場合にtrueを返します。既に記録されていればfalseです。
+	// 同じkeyを同時に記録しようとしても、trueを返すのは1つだけです。
+	Add(ctx context.Context, key string) (bool, error)
+	// Containsはkeyが記録されているか判定します。
+	Contains(ctx context.Context, key string) (bool, error)
+}
+
+// Memory はプロセスのメモリに記録するSetです。
+type Memory struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{keys: make(map[string]struct{})}
+}
+
+func (m *Memory) Add(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.keys[key]; ok {
+		return false, nil
+	}
+	m.keys[key] = struct{}{}
+	return true, nil
+}
+
+func (m *Memory) Contains(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.keys[key]
+	return ok, nil
+}
+
+// Scopedはキーの前にscopeを付けてsetに記録するSetを返します。
+// 1つのRedisを実行やトピックごとに分けて使うために使います。
+func Scoped(set Set, scope string) Set {
+	return scoped{set: set, prefix: scope + ":"}
+}
+
+type scoped struct {
+	set    Set
+	prefix string
+}
+
+func (s scoped) Add(ctx context.Context, key string) (bool, error) {
+	return s.set.Add(ctx, s.prefix+key)
+}
+
+func (s scoped) Contains(ctx context.Context, key string) (bool, error) {
+	return s.set.Contains(ctx, s.prefix+key)
+}
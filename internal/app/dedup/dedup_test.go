@@ -0,0 +1,194 @@
+package dedup
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/testdb"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
+}
+
+func TestMemoryConcurrentAdd(t *testing.T) {
+	set := NewMemory()
+	var added atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := set.Add(context.Background(), "tabelog:13000001"); ok {
+				added.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if added.Load() != 1 {
+		t.Errorf("Add returned true %d times, want once", added.Load())
+	}
+}
+
+func TestScoped(t *testing.T) {
+	ctx := context.Background()
+	set := NewMemory()
+	a, b := Scoped(set, "run1:1"), Scoped(set, "run1:2")
+	if ok, _ := a.Add(ctx, "k"); !ok {
+		t.Fatal("first Add returned false")
+	}
+	if ok, _ := b.Add(ctx, "k"); !ok {
+		t.Error("Add in another scope returned false")
+	}
+	if ok, _ := a.Contains(ctx, "k"); !ok {
+		t.Error("Contains returned false after Add")
+	}
+	if ok, _ := a.Add(ctx, "k"); ok {
+		t.Error("second Add returned true")
+	}
+}
+
+// fakeRedisはAUTH・SELECT・SET NX・EXISTSのみに応答するRedisです。
+func fakeRedis(t *testing.T, password string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var mu sync.Mutex
+	keys := map[string]bool{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				authed := password == ""
+				for {
+					args, err := readCommand(r)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					var reply string
+					switch cmd := strings.ToUpper(args[0]); {
+					case cmd == "AUTH":
+						authed = args[1] == password
+						reply = "+OK\r\n"
+						if !authed {
+							reply = "-WRONGPASS invalid password\r\n"
+						}
+					case !authed:
+						reply = "-NOAUTH Authentication required.\r\n"
+					case cmd == "SELECT":
+						reply = "+OK\r\n"
+					case cmd == "SET":
+						if keys[args[1]] {
+							reply = "$-1\r\n"
+						} else {
+							keys[args[1]] = true
+							reply = "+OK\r\n"
+						}
+					case cmd == "EXISTS":
+						reply = ":0\r\n"
+						if keys[args[1]] {
+							reply = ":1\r\n"
+						}
+					}
+					mu.Unlock()
+					io.WriteString(conn, reply)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	addr := fakeRedis(t, "secret")
+	set, err := NewRedis("redis://:secret@"+addr+"/2", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer set.Close()
+
+	if ok, err := set.Add(ctx, "tabelog:13000001"); err != nil || !ok {
+		t.Fatalf("first Add = %v, %v", ok, err)
+	}
+	if ok, err := set.Add(ctx, "tabelog:13000001"); err != nil || ok {
+		t.Errorf("second Add = %v, %v, want false", ok, err)
+	}
+	if ok, err := set.Contains(ctx, "tabelog:13000001"); err != nil || !ok {
+		t.Errorf("Contains = %v, %v, want true", ok, err)
+	}
+
+	other, _ := NewRedis("redis://"+addr, time.Hour)
+	defer other.Close()
+	if ok, err := other.Contains(ctx, "tabelog:13000001"); err == nil {
+		t.Errorf("Contains without auth = %v, want error", ok)
+	}
+}
+
+func TestNewRedisInvalidURL(t *testing.T) {
+	for _, u := range []string{"localhost:6379", "http://localhost", "redis://localhost/db"} {
+		if _, err := NewRedis(u, time.Hour); err == nil {
+			t.Errorf("NewRedis(%q) succeeded", u)
+		}
+	}
+}
+
+// TestRedisServerは本物のRedis(TEST_REDIS_URLまたはDockerのコンテナ)で有効期限付きの記録を確認します。
+func TestRedisServer(t *testing.T) {
+	ctx := context.Background()
+	set, err := NewRedis(testdb.Redis(t), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer set.Close()
+
+	key := "test:" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if ok, err := set.Add(ctx, key); err != nil || !ok {
+		t.Fatalf("first Add = %v, %v", ok, err)
+	}
+	if ok, err := set.Add(ctx, key); err != nil || ok {
+		t.Errorf("second Add = %v, %v, want false", ok, err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if ok, err := set.Contains(ctx, key); err != nil || ok {
+		t.Errorf("Contains after ttl = %v, %v, want false", ok, err)
+	}
+}
@@ -0,0 +1,50 @@
+package dedup
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"excavation_service/internal/app/redisclient"
+)
+
+const redisKeyPrefix = "excavation:dedup:"
+
+// Redis はRedisに記録するSetです。SET NXで記録するため、複数のワーカーが同じキーを記録しても新しく記録できるのは1つだけです。
+// 記録したキーはttlの後に消えます。
+type Redis struct {
+	client *redisclient.Client
+	ttl    time.Duration
+}
+
+// NewRedisはredis://[:password@]host:port[/db]形式のURLのRedisに記録するSetを作成します。TLSで接続する場合はrediss://を使います。
+// 接続は最初に使うときに開きます。
+func NewRedis(rawURL string, ttl time.Duration) (*Redis, error) {
+	client, err := redisclient.New(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{client: client, ttl: ttl}, nil
+}
+
+func (r *Redis) Add(ctx context.Context, key string) (bool, error) {
+	reply, err := r.client.Do(ctx, "SET", redisKeyPrefix+key, "1", "NX", "PX", strconv.FormatInt(r.ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	// 既に記録されていればnilが返る
+	return reply == "OK", nil
+}
+
+func (r *Redis) Contains(ctx context.Context, key string) (bool, error) {
+	reply, err := r.client.Do(ctx, "EXISTS", redisKeyPrefix+key)
+	if err != nil {
+		return false, err
+	}
+	return reply == int64(1), nil
+}
+
+// Closeは接続を閉じます。
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
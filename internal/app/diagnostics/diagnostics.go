@@ -0,0 +1,83 @@
+// Package diagnostics はpprofなどの実行時診断用のエンドポイントを提供します。
+// 長時間のクロールでのメモリ増加やgoroutineのリークを本番環境で調べるために使います。
+package diagnostics
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+var startedAt = time.Now()
+
+// Startはaddrが空でなければ、そのアドレスで診断用のHTTPサーバーをバックグラウンドで起動します。
+// APIとは別のポートで待ち受け、認証もないため、外部に公開しないアドレス(例: localhost:6060)を指定してください。
+func Start(logger *slog.Logger, addr string) {
+	if addr == "" {
+		return
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		logger.Info("診断用エンドポイントを起動しました", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("診断用エンドポイントが停止しました", "addr", addr, "error", err)
+		}
+	}()
+}
+
+// Handlerは診断用のエンドポイントを返します。
+//
+//	/debug/pprof/  pprofのプロファイル (heap, goroutine, profile, trace など)
+//	/debug/vars    expvarの値 (memstatsを含む)
+//	/debug/runtime goroutine数やヒープ使用量の概要
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/runtime", runtimeStats)
+	return mux
+}
+
+type runtimeResponse struct {
+	Uptime       string `json:"uptime"`
+	GoVersion    string `json:"go_version"`
+	Goroutines   int    `json:"goroutines"`
+	HeapAlloc    uint64 `json:"heap_alloc_bytes"`
+	HeapInuse    uint64 `json:"heap_inuse_bytes"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	Sys          uint64 `json:"sys_bytes"`
+	NumGC        uint32 `json:"num_gc"`
+	PauseTotalNs uint64 `json:"gc_pause_total_ns"`
+}
+
+func runtimeStats(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runtimeResponse{
+		Uptime:       time.Since(startedAt).Round(time.Second).String(),
+		GoVersion:    runtime.Version(),
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    m.HeapAlloc,
+		HeapInuse:    m.HeapInuse,
+		HeapObjects:  m.HeapObjects,
+		Sys:          m.Sys,
+		NumGC:        m.NumGC,
+		PauseTotalNs: m.PauseTotalNs,
+	})
+}
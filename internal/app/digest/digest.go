@@ -0,0 +1,148 @@
+// Package digest は週のトップトレンド・変動の大きいトピック・新しい店舗をまとめた週次ダイジェストメールを作成し、購読者に送ります。
+package digest
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	"net/url"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+
+	"gorm.io/gorm"
+)
+
+const (
+	topLimit    = 10 // スコア上位のトピックの数
+	moversLimit = 5  // 上昇・下落それぞれのトピックの数
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+var funcs = map[string]interface{}{
+	"deref": func(f *float64) float64 { return *f },
+	"inc":   func(i int) int { return i + 1 },
+}
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("digest.html").Funcs(funcs).ParseFS(templatesFS, "templates/digest.html"))
+	textTemplate = texttemplate.Must(texttemplate.New("digest.txt").Funcs(funcs).ParseFS(templatesFS, "templates/digest.txt"))
+)
+
+// Content はある週のダイジェストの内容です。購読者によらず同じです。
+type Content struct {
+	Week      time.Time
+	Top       []repository.TopicScore
+	Gainers   []repository.TopicMover
+	Losers    []repository.TopicMover
+	NewStores []repository.NewStore
+}
+
+// Buildはweek週のダイジェストの内容を集めます。トピックは既定のテナントのもののみ載せます。
+func Build(ctx context.Context, db *gorm.DB, w time.Time) (*Content, error) {
+	trends := repository.NewTrendRepository(db).WithContext(ctx).ForTenant(model.DefaultTenantID)
+	c := &Content{Week: w}
+	var err error
+	if c.Top, err = trends.Top(w, topLimit); err != nil {
+		return nil, fmt.Errorf("top trends: %w", err)
+	}
+	if c.Gainers, c.Losers, err = trends.Movers(w, moversLimit); err != nil {
+		return nil, fmt.Errorf("movers: %w", err)
+	}
+	if c.NewStores, err = repository.NewStoreRepository(db).WithContext(ctx).NewStoresInWeek(w); err != nil {
+		return nil, fmt.Errorf("new stores: %w", err)
+	}
+	return c, nil
+}
+
+// Renderは購読者宛てのメールを作成します。unsubscribeURLは購読者ごとの配信停止のリンクです。
+func (c *Content) Render(recipient, unsubscribeURL string) (notify.Message, error) {
+	data := struct {
+		*Content
+		Subject        string
+		Empty          bool
+		UnsubscribeURL string
+	}{
+		Content:        c,
+		Subject:        fmt.Sprintf("今週のトレンド (%s週)", c.Week.Format(week.Layout)),
+		Empty:          len(c.Top) == 0 && len(c.NewStores) == 0,
+		UnsubscribeURL: unsubscribeURL,
+	}
+	var html, text bytes.Buffer
+	if err := htmlTemplate.Execute(&html, data); err != nil {
+		return notify.Message{}, fmt.Errorf("render html: %w", err)
+	}
+	if err := textTemplate.Execute(&text, data); err != nil {
+		return notify.Message{}, fmt.Errorf("render text: %w", err)
+	}
+	return notify.Message{
+		Recipient:      recipient,
+		Subject:        data.Subject,
+		Body:           strings.TrimLeft(text.String(), "\n"),
+		HTML:           html.String(),
+		UnsubscribeURL: unsubscribeURL,
+	}, nil
+}
+
+// UnsubscribeURLはbaseURLのAPIサーバーで購読tokenの配信を停止するURLを返します。
+func UnsubscribeURL(baseURL, token string) string {
+	return strings.TrimRight(baseURL, "/") + "/api/v1/digest/unsubscribe?token=" + url.QueryEscape(token)
+}
+
+// Sendはweek週のダイジェストを、配信を停止しておらずまだ送っていない購読者に送り、送った数を返します。
+// LINEで受け取る購読者にはnotifierに"line:<ユーザーID>"宛てで送るため、notifierはnotify.Routerで振り分けてください。
+// 送った購読者は記録するため、途中で失敗しても再実行すれば残りの購読者にのみ送ります。
+// 送信に失敗した購読者があってもすべての購読者への送信を試み、失敗した数をエラーで返します。
+func Send(ctx context.Context, logger *slog.Logger, db *gorm.DB, notifier notify.Notifier, w time.Time, baseURL string) (int, error) {
+	content, err := Build(ctx, db, w)
+	if err != nil {
+		return 0, err
+	}
+	repo := repository.NewDigestRepository(db).WithContext(ctx)
+	subs, err := repo.ListPending(w)
+	if err != nil {
+		return 0, fmt.Errorf("list subscriptions: %w", err)
+	}
+
+	sent, failed := 0, 0
+	for _, sub := range subs {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		msg, err := content.Render(recipient(sub), UnsubscribeURL(baseURL, sub.Token))
+		if err != nil {
+			return sent, err
+		}
+		if err := notifier.Send(ctx, msg); err != nil {
+			logger.Error("ダイジェストの送信に失敗しました", "subscription_id", sub.ID, "error", err)
+			failed++
+			continue
+		}
+		if err := repo.MarkSent(sub.ID, w); err != nil {
+			return sent, fmt.Errorf("mark subscription %d as sent: %w", sub.ID, err)
+		}
+		sent++
+	}
+	if failed > 0 {
+		return sent, fmt.Errorf("failed to send digest to %d of %d subscribers", failed, len(subs))
+	}
+	return sent, nil
+}
+
+// recipientは購読者の通知先を返します。LINEで受け取る購読者は"line:<ユーザーID>"、それ以外はメールアドレスです。
+func recipient(sub model.DigestSubscription) string {
+	if sub.LineUserID != "" {
+		return notify.LINERecipient(sub.LineUserID)
+	}
+	return sub.Email
+}
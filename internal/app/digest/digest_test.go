@@ -0,0 +1,46 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/repository"
+)
+
+func TestRender(t *testing.T) {
+	delta := 5.0
+	c := &Content{
+		Week: time.Date(2024, 6, 3, 0, 0, 0, 0, time.Local),
+		Top: []repository.TopicScore{
+			{TopicID: 1, Topic: "渋谷 カレー", Score: 72, Delta: &delta, TopTitle: "スパイス食堂 ほし"},
+			{TopicID: 2, Topic: "新宿 <ラーメン>", Score: 40, TopTitle: "中華そば 青葉"},
+		},
+		Gainers:   []repository.TopicMover{{TopicID: 1, Topic: "渋谷 カレー", Score: 72, Delta: 5}},
+		NewStores: []repository.NewStore{{StoreID: 10, Name: "喫茶 みどり", URL: "https://tabelog.com/tokyo/A1/A2/1", Topic: "渋谷 カレー"}},
+	}
+	unsubscribe := UnsubscribeURL("https://example.com/", "tok en")
+	msg, err := c.Render("a@example.com", unsubscribe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unsubscribe != "https://example.com/api/v1/digest/unsubscribe?token=tok+en" {
+		t.Errorf("unsubscribe = %q", unsubscribe)
+	}
+	if msg.Subject != "今週のトレンド (2024-06-03週)" || msg.UnsubscribeURL != unsubscribe {
+		t.Errorf("message = %+v", msg)
+	}
+	for _, want := range []string{"1. 渋谷 カレー: 72.0 (前週比+5.0) スパイス食堂 ほし", "2. 新宿 <ラーメン>: 40.0 中華そば 青葉", "▲ 渋谷 カレー", "- 喫茶 みどり (渋谷 カレー)", unsubscribe} {
+		if !strings.Contains(msg.Body, want) {
+			t.Errorf("text body does not contain %q:\n%s", want, msg.Body)
+		}
+	}
+	for _, want := range []string{"新宿 &lt;ラーメン&gt;", `<a href="https://tabelog.com/tokyo/A1/A2/1">喫茶 みどり</a>`, "5.0</td>"} {
+		if !strings.Contains(msg.HTML, want) {
+			t.Errorf("html body does not contain %q:\n%s", want, msg.HTML)
+		}
+	}
+	if strings.Contains(msg.Body, "トレンドがありませんでした") {
+		t.Errorf("non-empty digest rendered as empty:\n%s", msg.Body)
+	}
+}
@@ -0,0 +1,216 @@
+// Package discord はDiscordとの連携(週間ランキングの投稿と、/trendスラッシュコマンドへの応答)を提供します。
+//
+// スラッシュコマンドはDiscordからAPIサーバーのInteractions Endpoint(/api/v1/discord/interactions)に送られます。
+// コマンドは excavation discord register で登録します。
+package discord
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tracing"
+	"excavation_service/internal/app/week"
+)
+
+const (
+	apiBaseURL = "https://discord.com/api/v10"
+
+	// RankingLimit は週間ランキングに載せるトピックの数です。
+	RankingLimit = 10
+	// TrendReplyLimit は/trendコマンドの応答に載せるトピックの数です。
+	TrendReplyLimit = 5
+)
+
+// Interactionの種類と応答の種類 (https://discord.com/developers/docs/interactions/receiving-and-responding)
+const (
+	InteractionPing               = 1
+	InteractionApplicationCommand = 2
+
+	ResponsePong           = 1
+	ResponseChannelMessage = 4
+	MessageFlagEphemeral   = 1 << 6
+)
+
+// TrendCommand は/trendコマンドの名前です。
+const (
+	TrendCommand            = "trend"
+	trendCommandQueryOption = "query"
+	optionTypeString        = 3
+)
+
+// Interaction はDiscordから送られるInteractionのうち、応答に使う項目です。
+type Interaction struct {
+	Type int `json:"type"`
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string          `json:"name"`
+			Value json.RawMessage `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+// Optionはコマンドのオプションnameの文字列の値を返します。
+func (i *Interaction) Option(name string) string {
+	for _, o := range i.Data.Options {
+		if o.Name != name {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(o.Value, &s); err == nil {
+			return s
+		}
+	}
+	return ""
+}
+
+// Query は/trendコマンドの検索語です。
+func (i *Interaction) Query() string {
+	return strings.TrimSpace(i.Option(trendCommandQueryOption))
+}
+
+// Response はInteractionへの応答です。
+type Response struct {
+	Type int           `json:"type"`
+	Data *ResponseData `json:"data,omitempty"`
+}
+
+type ResponseData struct {
+	Content         string              `json:"content"`
+	Flags           int                 `json:"flags,omitempty"`
+	AllowedMentions map[string][]string `json:"allowed_mentions"`
+}
+
+// Replyはcontentをチャンネルに返信する応答を返します。ephemeralならコマンドを実行したユーザーにのみ表示します。
+func Reply(content string, ephemeral bool) Response {
+	data := &ResponseData{Content: notify.DiscordContent(content), AllowedMentions: map[string][]string{"parse": {}}}
+	if ephemeral {
+		data.Flags = MessageFlagEphemeral
+	}
+	return Response{Type: ResponseChannelMessage, Data: data}
+}
+
+// ParsePublicKeyはDiscordのアプリケーションの公開鍵(16進数)を読み込みます。
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid discord public key: must be %d bytes in hex", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// Verifyは X-Signature-Ed25519 と X-Signature-Timestamp ヘッダーの値で、Interactionの本文がDiscordから送られたものか検証します。
+func Verify(publicKey ed25519.PublicKey, signature, timestamp string, body []byte) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(publicKey, append([]byte(timestamp), body...), sig)
+}
+
+// TrendReplyは/trendコマンドの検索語queryに一致したトピックの最新のトレンドを応答の本文にします。
+func TrendReply(query string, items []repository.LatestTopicScore) string {
+	if len(items) == 0 {
+		return fmt.Sprintf("「%s」に一致するトピックのトレンドはありません。", query)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "「%s」の最新のトレンド\n", query)
+	for i, t := range items {
+		fmt.Fprintf(&b, "%d. **%s**: %.1f", i+1, t.Topic, t.Score)
+		if t.Delta != nil {
+			fmt.Fprintf(&b, " (前週比%+.1f)", *t.Delta)
+		}
+		fmt.Fprintf(&b, " %s週", t.Week.Format(week.Layout))
+		if t.TopTitle != "" {
+			fmt.Fprintf(&b, " %s", t.TopTitle)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Rankingはw週のスコア上位のトピックを週間ランキングの投稿にします。
+func Ranking(w time.Time, top []repository.TopicScore) notify.Message {
+	msg := notify.Message{Subject: fmt.Sprintf("週間トレンドランキング (%s週)", w.Format(week.Layout))}
+	if len(top) == 0 {
+		msg.Body = "この週のトレンドはありません。"
+		return msg
+	}
+	var b strings.Builder
+	for i, t := range top {
+		fmt.Fprintf(&b, "%d. %s: %.1f", i+1, t.Topic, t.Score)
+		if t.Delta != nil {
+			fmt.Fprintf(&b, " (前週比%+.1f)", *t.Delta)
+		}
+		if t.TopTitle != "" {
+			fmt.Fprintf(&b, " %s", t.TopTitle)
+		}
+		b.WriteString("\n")
+	}
+	msg.Body = strings.TrimRight(b.String(), "\n")
+	return msg
+}
+
+// command はスラッシュコマンドの定義です。
+type command struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Options     []commandOption `json:"options,omitempty"`
+}
+
+type commandOption struct {
+	Type        int    `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+var commands = []command{{
+	Name:        TrendCommand,
+	Description: "エリアや店名を含むトピックの最新のトレンドを表示します",
+	Options: []commandOption{{
+		Type:        optionTypeString,
+		Name:        trendCommandQueryOption,
+		Description: "検索語 (例: 西日暮里)",
+		Required:    true,
+	}},
+}}
+
+// RegisterCommandsはアプリケーションappIDのスラッシュコマンドを登録します。既存のコマンドは置き換えます。
+// guildIDを指定するとそのサーバーのみに登録し、すぐに使えるようになります(全体への登録は反映に時間がかかります)。
+func RegisterCommands(ctx context.Context, appID, guildID, botToken string) error {
+	url := fmt.Sprintf("%s/applications/%s/commands", apiBaseURL, appID)
+	if guildID != "" {
+		url = fmt.Sprintf("%s/applications/%s/guilds/%s/commands", apiBaseURL, appID, guildID)
+	}
+	payload, err := json.Marshal(commands)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bot "+botToken)
+	resp, err := tracing.NewHTTPClient(10 * time.Second).Do(req)
+	if err != nil {
+		return fmt.Errorf("register discord commands: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("register discord commands: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/repository"
+)
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParsePublicKey(hex.EncodeToString(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"type":1}`)
+	sig := hex.EncodeToString(ed25519.Sign(priv, append([]byte("1700000000"), body...)))
+
+	if !Verify(key, sig, "1700000000", body) {
+		t.Error("valid signature rejected")
+	}
+	if Verify(key, sig, "1700000001", body) {
+		t.Error("signature with another timestamp accepted")
+	}
+	if Verify(key, sig, "1700000000", []byte(`{"type":2}`)) {
+		t.Error("signature of another body accepted")
+	}
+	if Verify(key, "zz", "1700000000", body) {
+		t.Error("malformed signature accepted")
+	}
+	if _, err := ParsePublicKey("abcd"); err == nil {
+		t.Error("short public key accepted")
+	}
+}
+
+func TestInteractionQuery(t *testing.T) {
+	var i Interaction
+	body := `{"type":2,"data":{"name":"trend","options":[{"name":"query","type":3,"value":" 西日暮里 "}]}}`
+	if err := json.Unmarshal([]byte(body), &i); err != nil {
+		t.Fatal(err)
+	}
+	if i.Type != InteractionApplicationCommand || i.Data.Name != TrendCommand || i.Query() != "西日暮里" {
+		t.Errorf("interaction = %+v, query = %q", i, i.Query())
+	}
+}
+
+func TestTrendReply(t *testing.T) {
+	delta := -3.5
+	w := time.Date(2024, 6, 3, 0, 0, 0, 0, time.Local)
+	got := TrendReply("西日暮里", []repository.LatestTopicScore{
+		{TopicScore: repository.TopicScore{Topic: "西日暮里 カレー", Score: 61.25, Delta: &delta, TopTitle: "スパイス食堂"}, Week: w},
+		{TopicScore: repository.TopicScore{Topic: "西日暮里 ラーメン", Score: 40}, Week: w},
+	})
+	for _, want := range []string{"1. **西日暮里 カレー**: 61.2 (前週比-3.5) 2024-06-03週 スパイス食堂", "2. **西日暮里 ラーメン**: 40.0 2024-06-03週"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("reply does not contain %q:\n%s", want, got)
+		}
+	}
+	if got := TrendReply("谷中", nil); !strings.Contains(got, "「谷中」に一致するトピックのトレンドはありません") {
+		t.Errorf("empty reply = %q", got)
+	}
+}
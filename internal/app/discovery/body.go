@@ -0,0 +1,9 @@
+package discovery
+
+// レスポンスのボディの上限。上限を超えたレスポンスはhttpx.ErrBodyTooLargeになります。
+const (
+	maxPageBytes          = 5 << 20 // 食べログなどのHTMLのページ
+	maxBraveResponseBytes = 2 << 20
+	maxGPTResponseBytes   = 1 << 20
+	maxErrorBodyBytes     = 512 // エラーのメッセージに含めるボディ
+)
@@ -0,0 +1,127 @@
+package discovery
+
+import (
+	"context"
+	"expvar"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// クロールの予算の種類。JobTopic.BudgetExhaustedに記録する
+const (
+	BudgetSearchResults = "search_results" // 処理する検索結果の数
+	BudgetMatomePages   = "matome_pages"   // 取得する食べログのまとめ記事の数
+	BudgetListingPages  = "listing_pages"  // 取得する食べログのリストページの数
+	BudgetWallClock     = "wall_clock"     // 店舗の候補を探すのにかける時間
+)
+
+// DefaultMaxSearchResults はBudget.MaxSearchResultsが0の場合に処理する検索結果の数の上限です。
+const DefaultMaxSearchResults = 50
+
+// budgetMetrics は予算を使い切った回数を予算の種類ごとに集計します。
+var budgetMetrics = expvar.NewMap("crawl_budget_exhausted")
+
+// Budget は1回の実行で1トピックの店舗の候補を探すのに使うクロールの上限です。
+// MaxSearchResults以外の0の項目は制限しません。
+type Budget struct {
+	MaxSearchResults int           // 処理する検索結果の数。0ならDefaultMaxSearchResults
+	MaxMatomePages   int           // 取得するまとめ記事の数
+	MaxListingPages  int           // 取得するリストページの数
+	WallClock        time.Duration // fetchの段階にかける時間。過ぎるとそれまでに見つけた候補で以降の段階に進む
+}
+
+// topicBudget は1つのトピックのクロールの予算の使用状況です。パイプラインのトピックごとに作成します。
+type topicBudget struct {
+	limits Budget
+
+	mu        sync.Mutex
+	used      map[string]int
+	exhausted map[string]bool
+}
+
+func newTopicBudget(limits Budget) *topicBudget {
+	return &topicBudget{limits: limits, used: make(map[string]int), exhausted: make(map[string]bool)}
+}
+
+type budgetKey struct{}
+
+// withBudgetはクロールの予算にbを使うctxを返します。
+func withBudget(ctx context.Context, b *topicBudget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// budgetOfはctxのクロールの予算を返します。ctxにない場合はnilで、nilの予算は制限しません。
+func budgetOf(ctx context.Context) *topicBudget {
+	b, _ := ctx.Value(budgetKey{}).(*topicBudget)
+	return b
+}
+
+// limitは種類kindの上限を返します。0は制限しないことを表します。
+func (b *topicBudget) limit(kind string) int {
+	switch kind {
+	case BudgetSearchResults:
+		if b.limits.MaxSearchResults == 0 {
+			return DefaultMaxSearchResults
+		}
+		return b.limits.MaxSearchResults
+	case BudgetMatomePages:
+		return b.limits.MaxMatomePages
+	case BudgetListingPages:
+		return b.limits.MaxListingPages
+	}
+	return 0
+}
+
+// takeは種類kindの予算を1つ使い、上限に達していて使えなければfalseを返します。bがnilの場合は常にtrueです。
+func (b *topicBudget) take(kind string) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n := b.limit(kind); n > 0 && b.used[kind] >= n {
+		b.markExhausted(kind)
+		return false
+	}
+	b.used[kind]++
+	return true
+}
+
+// exhaustは種類kindの予算を使い切ったことを記録します。
+func (b *topicBudget) exhaust(kind string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.markExhausted(kind)
+}
+
+func (b *topicBudget) markExhausted(kind string) {
+	if !b.exhausted[kind] {
+		b.exhausted[kind] = true
+		budgetMetrics.Add(kind, 1)
+	}
+}
+
+// exhaustedKindsは使い切った予算の種類を名前順に返します。
+func (b *topicBudget) exhaustedKinds() []string {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	kinds := make([]string, 0, len(b.exhausted))
+	for kind := range b.exhausted {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// exhaustedListはJobTopic.BudgetExhaustedに記録する、使い切った予算の種類のカンマ区切りの一覧を返します。
+func (b *topicBudget) exhaustedList() string {
+	return strings.Join(b.exhaustedKinds(), ",")
+}
@@ -0,0 +1,44 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTopicBudget(t *testing.T) {
+	b := newTopicBudget(Budget{MaxMatomePages: 2})
+	for i := 0; i < 2; i++ {
+		if !b.take(BudgetMatomePages) {
+			t.Fatalf("take %d = false, want true", i)
+		}
+	}
+	if b.take(BudgetMatomePages) {
+		t.Error("take beyond the limit = true, want false")
+	}
+	// 0の項目は制限しない
+	for i := 0; i < 100; i++ {
+		if !b.take(BudgetListingPages) {
+			t.Fatalf("unlimited take %d = false", i)
+		}
+	}
+	// 検索結果の数は0でも既定の上限で制限する
+	for i := 0; i < DefaultMaxSearchResults; i++ {
+		b.take(BudgetSearchResults)
+	}
+	if b.take(BudgetSearchResults) {
+		t.Errorf("take beyond DefaultMaxSearchResults = true, want false")
+	}
+	b.exhaust(BudgetWallClock)
+	if got, want := b.exhaustedList(), "matome_pages,search_results,wall_clock"; got != want {
+		t.Errorf("exhaustedList = %q, want %q", got, want)
+	}
+
+	// ctxに予算がなければ制限しない
+	none := budgetOf(context.Background())
+	if !none.take(BudgetMatomePages) || none.exhaustedList() != "" {
+		t.Error("nil budget should not limit")
+	}
+	if budgetOf(withBudget(context.Background(), b)) != b {
+		t.Error("budgetOf does not return the budget set by withBudget")
+	}
+}
@@ -0,0 +1,56 @@
+package discovery
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// trackingParams は計測やセッションのためのクエリパラメータです。ページの内容を変えないため正規化で取り除きます。
+var trackingParams = map[string]bool{
+	"gclid": true, "dclid": true, "fbclid": true, "msclkid": true, "yclid": true, "twclid": true, "ttclid": true,
+	"igshid": true, "mc_cid": true, "mc_eid": true, "_ga": true, "_gl": true,
+	"sid": true, "sessionid": true, "session_id": true, "jsessionid": true, "phpsessid": true,
+}
+
+// mobileHosts はスマートフォン版のホストとPC版のホストの対応です。
+var mobileHosts = map[string]string{
+	"s.tabelog.com": "tabelog.com",
+}
+
+// canonicalURLは見た目だけが異なるURLが同じURLになるよう正規化したURLを返します。uは変更しません。
+//   - スキームとホストを小文字にし、既定のポートを取り除く
+//   - スマートフォン版のホスト(s.tabelog.com)をPC版のホストに置き換える
+//   - utm_*・クリックID・セッションIDのクエリパラメータ、パスの;jsessionid=...、フラグメントを取り除く
+//   - 残ったクエリパラメータを名前順に並べる
+func canonicalURL(u *url.URL) *url.URL {
+	c := *u
+	c.Scheme = strings.ToLower(c.Scheme)
+	host := strings.ToLower(c.Hostname())
+	if desktop, ok := mobileHosts[host]; ok {
+		host = desktop
+	}
+	if port := c.Port(); port != "" && !(port == "80" && c.Scheme == "http") && !(port == "443" && c.Scheme == "https") {
+		host = net.JoinHostPort(host, port)
+	}
+	c.Host = host
+
+	if i := strings.Index(strings.ToLower(c.Path), ";jsessionid="); i >= 0 {
+		c.Path = c.Path[:i]
+		c.RawPath = ""
+	}
+	c.Fragment, c.RawFragment = "", ""
+
+	if c.RawQuery != "" {
+		q := c.Query()
+		for name := range q {
+			lower := strings.ToLower(name)
+			if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+				q.Del(name)
+			}
+		}
+		c.RawQuery = q.Encode()
+	}
+	c.ForceQuery = false
+	return &c
+}
@@ -0,0 +1,31 @@
+package discovery
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://tabelog.com/tokyo/A1311/A131105/13034566/?utm_source=x&utm_medium=y", "https://tabelog.com/tokyo/A1311/A131105/13034566/"},
+		{"https://S.Tabelog.com/tokyo/A1311/A131105/13034566/", "https://tabelog.com/tokyo/A1311/A131105/13034566/"},
+		{"HTTPS://tabelog.com:443/matome/12345/#shop", "https://tabelog.com/matome/12345/"},
+		{"https://example.com/news?id=2&fbclid=abc&gclid=def&a=1", "https://example.com/news?a=1&id=2"},
+		{"https://example.com/page;jsessionid=ABC123?PHPSESSID=1", "https://example.com/page"},
+		{"http://example.com:8080/x", "http://example.com:8080/x"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := canonicalURL(u).String(); got != tt.want {
+			t.Errorf("canonicalURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if u.String() == tt.want && tt.in != tt.want {
+			t.Errorf("canonicalURL modified its argument %q", tt.in)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package discovery
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"excavation_service/internal/app/dedup"
+)
+
+type dedupKey struct{}
+
+// withDedupは検索結果のURLの重複排除にsetを使うctxを返します。
+func withDedup(ctx context.Context, set dedup.Set) context.Context {
+	return context.WithValue(ctx, dedupKey{}, set)
+}
+
+// dedupSetはctxの重複排除のセットを返します。ctxにない場合はメモリ上の新しいセットを返します。
+func dedupSet(ctx context.Context) dedup.Set {
+	if set, ok := ctx.Value(dedupKey{}).(dedup.Set); ok {
+		return set
+	}
+	return dedup.NewMemory()
+}
+
+// tabelogStoreIDPattern は食べログの店舗ページのパスの末尾の店舗IDです。
+var tabelogStoreIDPattern = regexp.MustCompile(`/(\d{8}|\d{10})/?$`)
+
+// storeKeyは正規化されたURLの重複排除のキーを返します。
+// 食べログの店舗ページはクエリや末尾のサブページに関わらず同じ店舗になるよう、店舗IDをキーにします。
+func storeKey(normalizedURL string) string {
+	if u, err := url.Parse(normalizedURL); err == nil && strings.HasSuffix(u.Host, "tabelog.com") {
+		if m := tabelogStoreIDPattern.FindStringSubmatch(u.Path); m != nil {
+			return "tabelog:" + m[1]
+		}
+	}
+	return "url:" + normalizedURL
+}
+
+// seenBeforeはnormalizedURLが既に処理済みか判定します。
+// セットへの問い合わせに失敗した場合は、取りこぼさないよう未処理として扱います。
+func seenBefore(ctx context.Context, logger *slog.Logger, set dedup.Set, normalizedURL string) bool {
+	seen, err := set.Contains(ctx, storeKey(normalizedURL))
+	if err != nil {
+		logger.Warn("重複排除のセットの参照に失敗しました", "url", normalizedURL, "error", err)
+		return false
+	}
+	return seen
+}
+
+// markSeenはnormalizedURLを処理済みとして記録し、初めて記録した場合にtrueを返します。
+// セットへの記録に失敗した場合は、取りこぼさないよう初めてのURLとして扱います。
+func markSeen(ctx context.Context, logger *slog.Logger, set dedup.Set, normalizedURL string) bool {
+	added, err := set.Add(ctx, storeKey(normalizedURL))
+	if err != nil {
+		logger.Warn("重複排除のセットへの記録に失敗しました", "url", normalizedURL, "error", err)
+		return true
+	}
+	return added
+}
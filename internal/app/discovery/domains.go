@@ -0,0 +1,73 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultAllowedDomains はDomainPolicy.Allowedが空の場合に取得してよいドメインです。
+var DefaultAllowedDomains = []string{"tabelog.com"}
+
+// errDomainNotAllowed はクロールの対象外のドメインのページを取得しようとしたことを表します。
+var errDomainNotAllowed = errors.New("domain is not allowed to crawl")
+
+// DomainPolicy はクローラーが取得してよいページのドメインです。
+// 検索結果やページ内のリンクをたどっても、許可したドメイン以外のホストにはリクエストを送りません。
+// ドメインはそのサブドメインも含みます (tabelog.comならs.tabelog.comも含む)。
+type DomainPolicy struct {
+	Allowed []string // 取得してよいドメイン。空ならDefaultAllowedDomains
+	Blocked []string // Allowedに含まれていても取得しないドメイン
+}
+
+// Allowsはホスト名host(ポートを含まない)のページを取得してよいかを返します。
+func (p DomainPolicy) Allows(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if matchDomain(host, p.Blocked) {
+		return false
+	}
+	allowed := p.Allowed
+	if len(allowed) == 0 {
+		allowed = DefaultAllowedDomains
+	}
+	return matchDomain(host, allowed)
+}
+
+// matchDomainはhostがdomainsのいずれかのドメインかそのサブドメインであるかを返します。
+func matchDomain(host string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+type domainPolicyKey struct{}
+
+// withDomainPolicyはクローラーのリクエストをpolicyのドメインに制限するctxを返します。
+func withDomainPolicy(ctx context.Context, policy DomainPolicy) context.Context {
+	return context.WithValue(ctx, domainPolicyKey{}, policy)
+}
+
+// checkDomainはuのページを取得してよいか、ctxのDomainPolicy(ctxにない場合は既定のポリシー)で確認します。
+func checkDomain(ctx context.Context, u *url.URL) error {
+	policy, _ := ctx.Value(domainPolicyKey{}).(DomainPolicy)
+	if policy.Allows(u.Hostname()) {
+		return nil
+	}
+	extractionMetrics.Add("pages_blocked_domain", 1)
+	return fmt.Errorf("%w: %s", errDomainNotAllowed, u.Hostname())
+}
+
+// checkRedirectはリダイレクト先のドメインもDomainPolicyで確認します。リダイレクトの回数の上限はhttp.Clientの既定と同じ10回です。
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return checkDomain(req.Context(), req.URL)
+}
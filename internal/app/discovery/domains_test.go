@@ -0,0 +1,43 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDomainPolicyAllows(t *testing.T) {
+	policy := DomainPolicy{Allowed: []string{"tabelog.com", "retty.me"}, Blocked: []string{"blog.retty.me"}}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"tabelog.com", true},
+		{"s.tabelog.com", true},
+		{"TABELOG.COM.", true},
+		{"retty.me", true},
+		{"blog.retty.me", false},
+		{"a.blog.retty.me", false},
+		{"nottabelog.com", false},
+		{"tabelog.com.example.com", false},
+		{"example.com", false},
+	}
+	for _, tt := range tests {
+		if got := policy.Allows(tt.host); got != tt.want {
+			t.Errorf("Allows(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+
+	// 許可するドメインがなければDefaultAllowedDomainsのみ
+	if !(DomainPolicy{}).Allows("tabelog.com") || (DomainPolicy{}).Allows("example.com") {
+		t.Error("zero DomainPolicy should allow only DefaultAllowedDomains")
+	}
+}
+
+func TestFetchPageRejectsDomain(t *testing.T) {
+	// 対象外のドメインにはリクエストを送らない
+	ctx := withDomainPolicy(context.Background(), DomainPolicy{Allowed: []string{"tabelog.com"}})
+	if _, err := fetchPage(ctx, "http://127.0.0.1:1/"); !errors.Is(err, errDomainNotAllowed) {
+		t.Errorf("fetchPage error = %v, want errDomainNotAllowed", err)
+	}
+}
@@ -0,0 +1,126 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acceptEncoding はクローラーが展開できるContent-Encodingです。
+// brotliは展開するライブラリを持たないため要求しません。
+const acceptEncoding = "gzip, deflate"
+
+// errUnsupportedEncoding は展開できないContent-Encodingのレスポンスを受け取ったことを表します。
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// gzipMagic はgzipのデータの先頭のバイトです。
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decodeBodiesはレスポンスのボディをContent-Encodingに従って展開するRoundTripperを返します。
+// http.TransportはAccept-Encodingを自分で付けた場合やDisableCompressionの場合はボディを展開しないため、
+// どのTransportを使ってもHTMLを圧縮されたまま解析しないよう、要求と展開をここで行います。
+// Content-Encodingがなくてもボディがgzipであれば展開します。展開できない形式のレスポンスはエラーにします。
+// Transfer-Encoding: chunkedはhttp.Transportが解除するため、ここでは扱いません。
+func decodeBodies(base http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Accept-Encoding") == "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := base.RoundTrip(req)
+		if err != nil || req.Method == http.MethodHead {
+			return resp, err
+		}
+		body, decoded, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("decode response from %s: %w", req.URL.Host, err)
+		}
+		resp.Body = body
+		if decoded {
+			resp.Header.Del("Content-Encoding")
+			resp.Header.Del("Content-Length")
+			resp.ContentLength = -1
+			resp.Uncompressed = true
+		}
+		return resp, nil
+	})
+}
+
+// decodeBodyはContent-Encodingの値encodingに従ってbodyを展開するReadCloserと、展開したかを返します。
+// 複数の符号化が適用されている場合は適用された逆の順に展開します。
+func decodeBody(body io.ReadCloser, encoding string) (io.ReadCloser, bool, error) {
+	var codings []string
+	for _, c := range strings.Split(encoding, ",") {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" && c != "identity" {
+			codings = append(codings, c)
+		}
+	}
+	if len(codings) == 0 {
+		return sniffGzip(body)
+	}
+
+	r := io.Reader(body)
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+		switch codings[i] {
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(r)
+		case "deflate":
+			r, err = newDeflateReader(r)
+		default:
+			return nil, false, fmt.Errorf("%w: %s", errUnsupportedEncoding, codings[i])
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", codings[i], err)
+		}
+	}
+	return decodedBody{Reader: r, closer: body}, true, nil
+}
+
+// sniffGzipはContent-Encodingを付けずにgzipのボディを返すサーバーのため、先頭がgzipのデータであれば展開します。
+func sniffGzip(body io.ReadCloser) (io.ReadCloser, bool, error) {
+	br := bufio.NewReader(body)
+	head, _ := br.Peek(len(gzipMagic))
+	if !bytes.Equal(head, gzipMagic) {
+		// 先頭を読んだ分はbrに残っているため、brから読む
+		return decodedBody{Reader: br, closer: body}, false, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, false, fmt.Errorf("gzip: %w", err)
+	}
+	return decodedBody{Reader: zr, closer: body}, true, nil
+}
+
+// newDeflateReaderはdeflateのボディを展開します。
+// deflateは本来zlib形式ですが、ヘッダーのない生のdeflateを返すサーバーもあるため、zlibのヘッダーがなければ生のdeflateとして扱います。
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	// zlibのヘッダー: 圧縮方式が8(deflate)で、先頭2バイトが31の倍数
+	if head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// decodedBody は展開したボディです。Closeは元のボディを閉じます。
+type decodedBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (b decodedBody) Close() error {
+	return b.closer.Close()
+}
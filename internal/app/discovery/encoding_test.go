@@ -0,0 +1,96 @@
+package discovery
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"excavation_service/internal/app/dedup"
+	"excavation_service/internal/app/fixtures"
+)
+
+// TestFetchEncodedPagesは同梱のまとめ記事のフィクスチャを圧縮・チャンク転送で返しても、同じ店舗を抽出できることを確認します。
+// 自動で展開しないTransport(DisableCompression)を使い、展開がdecodeBodiesで行われることを確認します。
+func TestFetchEncodedPages(t *testing.T) {
+	page, err := fs.ReadFile(fixtures.Bundle(), "tabelog.com/matome/12345/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compress := func(newWriter func(io.Writer) io.WriteCloser) []byte {
+		var b bytes.Buffer
+		w := newWriter(&b)
+		w.Write(page)
+		w.Close()
+		return b.Bytes()
+	}
+	gzipped := compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	encodings := map[string]struct {
+		header string
+		body   []byte
+	}{
+		"identity":   {"", page},
+		"gzip":       {"gzip", gzipped},
+		"x-gzip":     {"x-gzip", gzipped},
+		"zlib":       {"deflate", compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })},
+		"raw":        {"deflate", compress(func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.DefaultCompression); return fw })},
+		"unlabelled": {"", gzipped}, // Content-Encodingを付けずにgzipを返すサーバー
+		"brotli":     {"br", []byte{0x0b, 0x02, 0x80}},
+	}
+
+	var gotAcceptEncoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAcceptEncoding = r.Header.Get("Accept-Encoding")
+		enc := encodings[r.URL.Query().Get("enc")]
+		if enc.header != "" {
+			w.Header().Set("Content-Encoding", enc.header)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// Content-Lengthを付けずに分けて書き出し、Transfer-Encoding: chunkedで送る
+		for b := enc.body; len(b) > 0; {
+			n := min(len(b), 256)
+			w.Write(b[:n])
+			w.(http.Flusher).Flush()
+			b = b[n:]
+		}
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL)
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.DisableCompression = true
+	origHTTP, origBrave, origGPT := httpClient.Transport, braveClient.Transport, gptClient.Transport
+	defer func() {
+		httpClient.Transport, braveClient.Transport, gptClient.Transport = origHTTP, origBrave, origGPT
+	}()
+	SetTransport(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.URL.Scheme, r.URL.Host = target.Scheme, target.Host
+		return base.RoundTrip(r)
+	}))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for name := range encodings {
+		links := fetchStoreLinksFromMatome(context.Background(), logger, "https://tabelog.com/matome/12345/?enc="+name, dedup.NewMemory(), newMentionCollector(logger))
+		if name == "brotli" {
+			if len(links) != 0 {
+				t.Errorf("%s: extracted %d stores from an undecodable page", name, len(links))
+			}
+			continue
+		}
+		if len(links) == 0 {
+			t.Errorf("%s: no stores extracted", name)
+		}
+	}
+	if gotAcceptEncoding != acceptEncoding {
+		t.Errorf("Accept-Encoding = %q, want %q", gotAcceptEncoding, acceptEncoding)
+	}
+}
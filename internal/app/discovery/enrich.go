@@ -0,0 +1,39 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+
+	"gorm.io/gorm"
+)
+
+// Enrichは保存済みの店舗について、collectStoreInfoで店舗ページから詳細情報を収集します。
+// collectStoreInfoはまだダミー実装のため、現在は収集した情報をログに出力するだけで保存はしません。
+// 同じ店舗IDのページは1回のみ取得・解析します。返り値は処理した店舗の数です。
+func Enrich(ctx context.Context, logger *slog.Logger, db *gorm.DB, limit int) (int, error) {
+	var stores []model.Store
+	if err := db.WithContext(ctx).Order("updated_at").Limit(limit).Find(&stores).Error; err != nil {
+		return 0, fmt.Errorf("list stores: %w", err)
+	}
+
+	cache := newStoreCache(storeCacheSize)
+	repo := repository.NewStoreRepository(db).WithContext(ctx)
+	for _, s := range stores {
+		info := storeDetails(cache, s.Name, s.URL)
+		if info == nil {
+			logger.Info("店舗を除外対象と判定しました", "store", s.Name, "url", s.URL)
+			continue
+		}
+		if reasons := validateStore(*info); len(reasons) > 0 {
+			quarantineStore(logger, repo, model.QuarantineStageEnrich, "", *info, reasons)
+			continue
+		}
+		logger.Info("店舗情報", "store", info.Name, "url", info.URL, "genre", info.Genre,
+			"budget_lunch", info.BudgetLunch, "budget_dinner", info.BudgetDinner, "chain", info.IsChain)
+	}
+	return len(stores), nil
+}
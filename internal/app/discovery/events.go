@@ -0,0 +1,85 @@
+package discovery
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/outbox"
+	"excavation_service/internal/app/repository"
+
+	"gorm.io/gorm"
+)
+
+// trendCreatedEvent はtrend.createdのWebhookで送るトレンドです。
+type trendCreatedEvent struct {
+	TrendID      uint     `json:"trend_id"`
+	TenantID     uint     `json:"tenant_id"`
+	TopicID      uint     `json:"topic_id"`
+	Topic        string   `json:"topic"`
+	Week         string   `json:"week"`
+	Score        float64  `json:"score"`
+	GPTScore     float64  `json:"gpt_score"`
+	Delta        *float64 `json:"delta"`
+	MentionCount int      `json:"mention_count"`
+	TopTitle     string   `json:"top_title"`
+	RunID        string   `json:"run_id"`
+}
+
+// storeDiscoveredEvent はstore.discoveredのWebhookで送る、実行で初めて見つかった店舗です。
+type storeDiscoveredEvent struct {
+	StoreID uint   `json:"store_id"`
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	TopicID uint   `json:"topic_id"`
+	Topic   string `json:"topic"`
+	RunID   string `json:"run_id"`
+}
+
+// watchNewStoreEvent はwatch.new_storeのWebhookで送る、ウォッチ中のトピックで初めて言及された店舗です。
+type watchNewStoreEvent struct {
+	StoreID uint   `json:"store_id"`
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	TopicID uint   `json:"topic_id"`
+	Topic   string `json:"topic"`
+	Week    string `json:"week"`
+	RunID   string `json:"run_id"`
+}
+
+// runFailedEvent はrun.failedのWebhookで送る、失敗または中断した実行です。
+type runFailedEvent struct {
+	RunID        string    `json:"run_id"`
+	JobID        uint      `json:"job_id"`
+	Error        string    `json:"error"`
+	TopicsTotal  int       `json:"topics_total"`
+	TopicsFailed int       `json:"topics_failed"`
+	StartedAt    time.Time `json:"started_at"`
+}
+
+// publishは保存済みのデータから求めたイベントをoutboxに記録します。記録に失敗しても発掘は続けるため、ログに出力するのみとします。
+// 書き込みと同時に発生するイベントは、書き込みと同じトランザクションでoutbox.Publishを呼び出します。
+func publish(ctx context.Context, logger *slog.Logger, db *gorm.DB, event string, data interface{}) {
+	if err := outbox.Publish(db.WithContext(ctx), event, data); err != nil {
+		logger.Warn("イベントの記録に失敗しました", "event", event, "error", err)
+	}
+}
+
+// publishRunEventsは実行で初めて見つかった店舗と、実行の失敗をWebhookに通知します。
+func publishRunEvents(ctx context.Context, logger *slog.Logger, db *gorm.DB, job model.Job) {
+	stores, err := repository.NewStoreRepository(db).NewStoresByRun(job.RunID, job.StartedAt)
+	if err != nil {
+		logger.Warn("新しい店舗の取得に失敗しました", "error", err)
+	}
+	for _, s := range stores {
+		publish(ctx, logger, db, model.EventStoreDiscovered, storeDiscoveredEvent{
+			StoreID: s.StoreID, Name: s.Name, URL: s.URL, TopicID: s.TopicID, Topic: s.Topic, RunID: job.RunID,
+		})
+	}
+	if job.Status == model.JobStatusFailed {
+		publish(ctx, logger, db, model.EventRunFailed, runFailedEvent{
+			RunID: job.RunID, JobID: job.ID, Error: job.Error, TopicsTotal: job.TopicsTotal, TopicsFailed: job.TopicsFailed, StartedAt: job.StartedAt,
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package discovery
+
+import (
+	"expvar"
+	"log/slog"
+)
+
+// ページの種別。セレクタのヒット数はこの種別ごとに集計する
+const (
+	pageTypeSearch  = "search"
+	pageTypeMatome  = "matome"
+	pageTypeListing = "listing"
+)
+
+// 店舗名の棄却率がこれを超えたら、タイトルの形式が変わった可能性がある
+const maxStoreNameRejectRate = 0.5
+
+// extractionMetrics はプロセス開始からのページ解析の結果を集計します。
+// 食べログのHTMLが変わってセレクタや正規表現が一致しなくなっても処理はエラーにならないため、
+// 実行の最後に実行中の増分をログに出力して劣化に気付けるようにします。PPROF_ADDR設定時は/debug/varsからも累計を参照できます。
+var extractionMetrics = expvar.NewMap("extraction")
+
+// recordStorePageはisStorePageの判定結果を記録します。
+func recordStorePage(ok bool) {
+	if ok {
+		extractionMetrics.Add("store_page.pass", 1)
+	} else {
+		extractionMetrics.Add("store_page.fail", 1)
+	}
+}
+
+// recordStoreNameはextractStoreNameで店舗名を得られたか(cleaned)、除外したか(rejected)を記録します。
+func recordStoreName(name string) {
+	if name != "" {
+		extractionMetrics.Add("store_name.cleaned", 1)
+	} else {
+		extractionMetrics.Add("store_name.rejected", 1)
+	}
+}
+
+// recordPageは解析したページと、そのページでセレクタに一致した要素の数を記録します。
+func recordPage(pageType string, hits int) {
+	extractionMetrics.Add("pages."+pageType, 1)
+	extractionMetrics.Add("selector_hits."+pageType, int64(hits))
+	if hits == 0 {
+		extractionMetrics.Add("pages_without_hits."+pageType, 1)
+	}
+}
+
+func metricValue(key string) int64 {
+	if v, ok := extractionMetrics.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// extractionSnapshot は実行開始時点のextractionMetricsの値です。
+// consumeは同じプロセスでRunを繰り返すため、実行ごとの集計は開始時点との差で求めます。
+type extractionSnapshot map[string]int64
+
+func snapshotExtraction() extractionSnapshot {
+	s := make(extractionSnapshot)
+	extractionMetrics.Do(func(kv expvar.KeyValue) {
+		if v, ok := kv.Value.(*expvar.Int); ok {
+			s[kv.Key] = v.Value()
+		}
+	})
+	return s
+}
+
+// sinceはkeyの開始時点からの増分を返します。
+func (s extractionSnapshot) since(key string) int64 {
+	return metricValue(key) - s[key]
+}
+
+// rateはn/(n+m)を返します。n+mが0の場合は0です。
+func rate(n, m int64) float64 {
+	if n+m == 0 {
+		return 0
+	}
+	return float64(n) / float64(n+m)
+}
+
+// logExtractionSummaryは今回の実行の解析結果の集計をログに出力し、パーサーの劣化が疑われる場合は警告します。
+func logExtractionSummary(logger *slog.Logger, start extractionSnapshot) {
+	pass, fail := start.since("store_page.pass"), start.since("store_page.fail")
+	cleaned, rejected := start.since("store_name.cleaned"), start.since("store_name.rejected")
+	logger.Info("店舗ページ・店舗名の抽出結果",
+		"store_page_pass", pass, "store_page_fail", fail, "store_page_pass_rate", rate(pass, fail),
+		"store_name_cleaned", cleaned, "store_name_rejected", rejected, "store_name_reject_rate", rate(rejected, cleaned))
+	if rate(rejected, cleaned) > maxStoreNameRejectRate {
+		logger.Warn("店舗名の棄却率が高すぎます。タイトルの形式が変わった可能性があります", "rejected", rejected, "cleaned", cleaned)
+	}
+
+	for _, pageType := range []string{pageTypeSearch, pageTypeMatome, pageTypeListing} {
+		if skipped := start.since("pages_skipped_language." + pageType); skipped > 0 {
+			logger.Info("対象外の言語のためスキップしたページ数", "page_type", pageType, "pages_skipped", skipped)
+		}
+		pages := start.since("pages." + pageType)
+		if pages == 0 {
+			continue
+		}
+		hits := start.since("selector_hits." + pageType)
+		empty := start.since("pages_without_hits." + pageType)
+		logger.Info("ページ種別ごとのセレクタのヒット数", "page_type", pageType, "pages", pages, "selector_hits", hits, "pages_without_hits", empty)
+		if empty == pages {
+			logger.Warn("どのページでもセレクタが一致しませんでした。HTMLの構造が変わった可能性があります", "page_type", pageType, "pages", pages)
+		}
+	}
+}
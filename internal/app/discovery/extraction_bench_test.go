@@ -0,0 +1,168 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"excavation_service/internal/app/dedup"
+	"excavation_service/internal/app/fixtures"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// 店舗名の抽出(extractStoreName)・店舗ページの判定(isStorePage)・まとめ記事とリストページの解析のベンチマーク。
+// 検索結果のタイトルごとに正規表現を何度も適用するため、市区町村全体の発掘ではここが処理時間の大半を占めます。
+// 入力は同梱のフィクスチャ(fixtures.Bundle)です。実行とプロファイルの取得はMakefileのbench・profileを使います。
+
+// benchPages はベンチマークで解析するフィクスチャのページです。
+var benchPages = []struct {
+	name string
+	url  string
+	file string
+}{
+	{pageTypeMatome, "https://tabelog.com/matome/12345/", "tabelog.com/matome/12345/index.html"},
+	{pageTypeListing, "https://tabelog.com/tokyo/A1311/A131105/rstLst/", "tabelog.com/tokyo/A1311/A131105/rstLst/index.html"},
+}
+
+// benchCorpus はフィクスチャの検索結果とページのリンクから集めた、タイトルとURLの入力です。
+type benchCorpus struct {
+	titles []string
+	urls   []*url.URL
+}
+
+func loadBenchCorpus(b *testing.B) benchCorpus {
+	b.Helper()
+	fsys := fixtures.Bundle()
+	var c benchCorpus
+
+	raw, err := fs.ReadFile(fsys, "api.brave.com/res/v1/web/search.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var search struct {
+		Web struct {
+			Results []struct {
+				Title string `json:"title"`
+				URL   string `json:"url"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := json.Unmarshal(raw, &search); err != nil {
+		b.Fatal(err)
+	}
+	for _, r := range search.Web.Results {
+		c.titles = append(c.titles, r.Title)
+		if u, err := url.Parse(r.URL); err == nil {
+			c.urls = append(c.urls, u)
+		}
+	}
+
+	for _, p := range benchPages {
+		html, err := fs.ReadFile(fsys, p.file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+		if err != nil {
+			b.Fatal(err)
+		}
+		base, _ := url.Parse(p.url)
+		doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+			c.titles = append(c.titles, strings.TrimSpace(s.Text()))
+			href, _ := s.Attr("href")
+			if u, err := url.Parse(href); err == nil {
+				c.urls = append(c.urls, base.ResolveReference(u))
+			}
+		})
+	}
+	return c
+}
+
+func BenchmarkExtractStoreName(b *testing.B) {
+	titles := loadBenchCorpus(b).titles
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, title := range titles {
+			extractStoreName(title)
+		}
+	}
+	b.ReportMetric(float64(len(titles)), "titles/op")
+}
+
+func BenchmarkIsStorePage(b *testing.B) {
+	urls := loadBenchCorpus(b).urls
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, u := range urls {
+			isStorePage(u)
+		}
+	}
+	b.ReportMetric(float64(len(urls)), "urls/op")
+}
+
+// BenchmarkStoreLinksはまとめ記事とリストページの取得から店舗の抽出までを計測します。
+// ページはネットワークを使わずにフィクスチャから返すため、計測するのはほぼ解析と抽出の時間です。
+func BenchmarkStoreLinks(b *testing.B) {
+	parsers := map[string]func(context.Context, *slog.Logger, string, dedup.Set, *mentionCollector) map[string]string{
+		pageTypeMatome:  fetchStoreLinksFromMatome,
+		pageTypeListing: fetchLinksFromListingPage,
+	}
+	useFixturePages(b)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	for _, p := range benchPages {
+		parse := parsers[p.name]
+		b.Run(p.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				// 重複排除のセットを使い回すと2回目以降は店舗が見つからないため、毎回作り直す
+				if links := parse(ctx, logger, p.url, dedup.NewMemory(), newMentionCollector(logger)); len(links) == 0 {
+					b.Fatalf("no store links in %s", p.url)
+				}
+			}
+		})
+	}
+}
+
+// useFixturePagesはクローラーのリクエストにフィクスチャをメモリ上で返すようにし、ベンチマークの終了時に元に戻します。
+func useFixturePages(b *testing.B) {
+	b.Helper()
+	srv, err := fixtures.Start(slog.New(slog.NewTextHandler(io.Discard, nil)), fixtures.Bundle())
+	if err != nil {
+		b.Fatal(err)
+	}
+	origHTTP, origBrave, origGPT := httpClient.Transport, braveClient.Transport, gptClient.Transport
+	b.Cleanup(func() {
+		httpClient.Transport, braveClient.Transport, gptClient.Transport = origHTTP, origBrave, origGPT
+		srv.Close()
+	})
+	SetTransport(handlerTransport{srv})
+}
+
+// handlerTransport はリクエストを/<ホスト名>/<パス>としてhに渡すRoundTripperです。fixtures.Serverをループバックの接続なしで使います。
+type handlerTransport struct {
+	h http.Handler
+}
+
+func (t handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rewritten := r.Clone(r.Context())
+	u := *r.URL
+	u.Path = "/" + r.URL.Hostname() + r.URL.Path
+	u.RawPath = ""
+	rewritten.URL = &u
+	rec := httptest.NewRecorder()
+	t.h.ServeHTTP(rec, rewritten)
+	return rec.Result(), nil
+}
@@ -0,0 +1,38 @@
+package discovery
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// TestLogExtractionSummaryPerRunは同じプロセスで2回実行しても、2回目の集計に1回目の分が含まれないことを確認します。
+func TestLogExtractionSummaryPerRun(t *testing.T) {
+	run := func(pages, hits int) string {
+		var buf bytes.Buffer
+		start := snapshotExtraction()
+		for i := 0; i < pages; i++ {
+			recordPage(pageTypeListing, hits)
+			recordStorePage(true)
+		}
+		logExtractionSummary(slog.New(slog.NewTextHandler(&buf, nil)), start)
+		return buf.String()
+	}
+
+	// 1回目はセレクタが一致しないページだけ
+	first := run(3, 0)
+	if !strings.Contains(first, "store_page_pass=3 ") || !strings.Contains(first, "level=WARN") {
+		t.Errorf("first summary = %s, want 3 pages and a selector warning", first)
+	}
+	second := run(2, 4)
+	if !strings.Contains(second, "store_page_pass=2 ") || !strings.Contains(second, "pages=2 selector_hits=8 pages_without_hits=0") {
+		t.Errorf("second summary = %s, want only the 2 pages of the second run", second)
+	}
+	if strings.Contains(second, "level=WARN") {
+		t.Errorf("second summary = %s, want no warning carried over from the first run", second)
+	}
+	if got := metricValue("pages." + pageTypeListing); got < 5 {
+		t.Errorf("expvar pages.listing = %d, want the process-wide total of at least 5", got)
+	}
+}
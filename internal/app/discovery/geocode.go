@@ -0,0 +1,158 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/geocode"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+
+	"github.com/PuerkitoBio/goquery"
+	"gorm.io/gorm"
+)
+
+// GeocodeStoresは住所の変換をまだ試みていない店舗を最大limit件処理し、緯度・経度を保存します。
+// 住所が未取得の店舗は店舗ページから住所と画像のURLを取得します。住所が見つからなかった店舗は変換を試みた日時のみ記録し、
+// 店舗ページの取得や変換のサービスへのリクエストに失敗した店舗は次回の実行で再び処理します。
+// 返り値は緯度・経度を保存した店舗の数です。
+func GeocodeStores(ctx context.Context, logger *slog.Logger, db *gorm.DB, geocoder geocode.Geocoder, scrapeRulesFile string, limit int) (int, error) {
+	if scrapeRulesFile != "" {
+		if err := scrapeRules.Load(scrapeRulesFile); err != nil {
+			return 0, fmt.Errorf("load scrape rules: %w", err)
+		}
+	}
+	repo := repository.NewStoreRepository(db).WithContext(ctx)
+	stores, err := repo.ListUngeocoded(limit)
+	if err != nil {
+		return 0, fmt.Errorf("list stores: %w", err)
+	}
+
+	located := 0
+	for _, s := range stores {
+		if err := ctx.Err(); err != nil {
+			return located, err
+		}
+		storeLogger := logger.With("store_id", s.ID, "store", s.Name)
+		if s.Address == "" {
+			page, err := fetchStorePage(ctx, s.URL)
+			if err != nil {
+				storeLogger.Warn("店舗ページの住所の取得に失敗しました", "url", s.URL, "error", err)
+				continue
+			}
+			if page.imageURL != "" {
+				if err := repo.SetImageURL(s.ID, page.imageURL); err != nil {
+					return located, fmt.Errorf("save image of store %d: %w", s.ID, err)
+				}
+			}
+			data := StoreData{Name: s.Name, URL: s.URL, Address: page.address}
+			if reasons := validateStore(data); len(reasons) > 0 {
+				// 日本の住所に見えない住所は保存せず、住所が見つからなかった店舗として扱う
+				quarantineStore(storeLogger, repo, model.QuarantineStageAddress, "", data, reasons)
+				page.address = ""
+			}
+			if page.address != "" {
+				if err := repo.SetAddress(s.ID, page.address); err != nil {
+					return located, fmt.Errorf("save address of store %d: %w", s.ID, err)
+				}
+			}
+			s.Address = page.address
+		}
+		if s.Address == "" {
+			storeLogger.Info("店舗ページに住所が見つかりませんでした", "url", s.URL)
+			if err := repo.SetLocation(s.ID, nil, nil, time.Now()); err != nil {
+				return located, fmt.Errorf("save location of store %d: %w", s.ID, err)
+			}
+			continue
+		}
+
+		loc, err := geocoder.Geocode(ctx, s.Address)
+		switch {
+		case errors.Is(err, geocode.ErrNotFound):
+			storeLogger.Info("住所に該当する位置が見つかりませんでした", "address", s.Address)
+			err = repo.SetLocation(s.ID, nil, nil, time.Now())
+		case err != nil:
+			storeLogger.Warn("住所の変換に失敗しました", "address", s.Address, "error", err)
+			continue
+		default:
+			located++
+			storeLogger.Debug("住所を変換しました", "address", s.Address, "lat", loc.Lat, "lon", loc.Lon)
+			err = repo.SetLocation(s.ID, &loc.Lat, &loc.Lon, time.Now())
+		}
+		if err != nil {
+			return located, fmt.Errorf("save location of store %d: %w", s.ID, err)
+		}
+	}
+	return located, nil
+}
+
+// storePage は店舗ページから取得した情報です。見つからなかったものは空文字列です。
+type storePage struct {
+	address  string
+	imageURL string
+}
+
+// fetchStorePageは店舗ページから住所と画像のURLを取得します。
+func fetchStorePage(ctx context.Context, urlStr string) (storePage, error) {
+	resp, err := fetchPage(ctx, urlStr)
+	if err != nil {
+		return storePage{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return storePage{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return storePage{}, err
+	}
+	return storePage{address: extractAddress(doc), imageURL: extractImageURL(doc, resp.Request.URL)}, nil
+}
+
+// extractImageURLは店舗ページのog:imageの画像のURLを絶対URLにして返します。http(s)以外のURLは使いません。
+func extractImageURL(doc *goquery.Document, base *url.URL) string {
+	content, ok := doc.Find(`meta[property="og:image"]`).First().Attr("content")
+	if !ok || strings.TrimSpace(content) == "" {
+		return ""
+	}
+	u, err := base.Parse(strings.TrimSpace(content))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+// extractAddressは店舗ページの住所を返します。住所の欄が見つからなければ構造化データ(JSON-LD)のaddressを使います。
+func extractAddress(doc *goquery.Document) string {
+	if sel := doc.Find(scrapeRules.Current().AddressSelector).First(); sel.Length() > 0 {
+		// 住所は市区町村などのリンクに分かれているため、空白を詰めてつなげる
+		if address := strings.Join(strings.Fields(sel.Text()), ""); address != "" {
+			return address
+		}
+	}
+
+	var address string
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data struct {
+			Address struct {
+				Region   string `json:"addressRegion"`
+				Locality string `json:"addressLocality"`
+				Street   string `json:"streetAddress"`
+			} `json:"address"`
+		}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		a := data.Address
+		address = strings.TrimSpace(a.Region + a.Locality + a.Street)
+		return address == ""
+	})
+	return address
+}
@@ -0,0 +1,65 @@
+package discovery
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "address row",
+			html: `<p class="rstinfo-table__address"><span><a href="/tokyo/">東京都</a></span><span>
+				<a href="/tokyo/A1303/">渋谷区</a><a href="/tokyo/A1303/A130301/">神南</a>1-2-3</span> <span>神南ビル 2F</span></p>`,
+			want: "東京都渋谷区神南1-2-3神南ビル2F",
+		},
+		{
+			name: "json-ld",
+			html: `<script type="application/ld+json">{"@type":"Restaurant","address":{"@type":"PostalAddress","streetAddress":"西日暮里5-1-1","addressLocality":"荒川区","addressRegion":"東京都"}}</script>`,
+			want: "東京都荒川区西日暮里5-1-1",
+		},
+		{
+			name: "none",
+			html: `<p>住所</p><script type="application/ld+json">{"@type":"BreadcrumbList"}</script>`,
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := extractAddress(doc); got != tt.want {
+			t.Errorf("%s: extractAddress() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractImageURL(t *testing.T) {
+	base, _ := url.Parse("https://tabelog.com/tokyo/A1303/A130301/13000001/")
+	tests := []struct {
+		html string
+		want string
+	}{
+		{`<meta property="og:image" content="https://tblg.k-img.com/restaurant/images/1.jpg">`, "https://tblg.k-img.com/restaurant/images/1.jpg"},
+		{`<meta property="og:image" content="/images/2.jpg">`, "https://tabelog.com/images/2.jpg"},
+		{`<meta property="og:image" content="javascript:alert(1)">`, ""},
+		{`<meta property="og:title" content="店舗">`, ""},
+	}
+	for _, tt := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := extractImageURL(doc, base); got != tt.want {
+			t.Errorf("extractImageURL(%s) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
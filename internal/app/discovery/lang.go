@@ -0,0 +1,81 @@
+package discovery
+
+import (
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"excavation_service/internal/app/scraperules"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// langSampleLetters は言語の判定に使う本文の文字数の上限です。
+	langSampleLetters = 5000
+	// langMinLetters より本文の文字が少ないページは文字種から言語を判定しません。
+	langMinLetters = 50
+)
+
+// pageLanguageはページの言語を返します。本文の文字種から判定し、判定できない場合は<html lang>の主言語を返します。
+// どちらでも判定できない場合は空文字列です。
+// 日本語のページでも<html lang="en">としているサイトがあるため、属性より本文の文字種を優先します。
+func pageLanguage(doc *goquery.Document) string {
+	if lang := detectLanguage(doc.Find("body").Text()); lang != "" {
+		return lang
+	}
+	lang, _ := doc.Find("html").Attr("lang")
+	lang, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(lang)), "-")
+	return lang
+}
+
+// detectLanguageはtextの文字種の割合から言語を推定します。
+// かなを含めばja、ハングルが多ければko、かなを含まず漢字が多ければzh、ラテン文字が大半ならenです。
+// 文字が少なく判定できない場合は空文字列を返します。
+func detectLanguage(text string) string {
+	var kana, han, hangul, latin, total int
+	for _, r := range text {
+		if total >= langSampleLetters {
+			break
+		}
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana) && r != 'ー' && r != '・':
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		default:
+			continue
+		}
+		total++
+	}
+	if total < langMinLetters {
+		return ""
+	}
+	switch {
+	case kana*20 >= total: // かなが5%以上
+		return "ja"
+	case hangul*5 >= total:
+		return "ko"
+	case han*3 >= total:
+		return "zh"
+	case latin*2 >= total:
+		return "en"
+	}
+	return ""
+}
+
+// skipByLanguageはdocが解析の対象外の言語のページであればスキップを記録してtrueを返します。
+// 対象の言語はスクレイピングルールのlanguagesで設定します。
+func skipByLanguage(logger *slog.Logger, rules *scraperules.Rules, pageType, urlStr string, doc *goquery.Document) bool {
+	lang := pageLanguage(doc)
+	if rules.AllowsLanguage(lang) {
+		return false
+	}
+	extractionMetrics.Add("pages_skipped_language."+pageType, 1)
+	logger.Info("対象外の言語のページのためスキップしました", "url", urlStr, "page_type", pageType, "lang", lang, "reason", "language:"+lang)
+	return true
+}
@@ -0,0 +1,38 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"japanese", strings.Repeat("渋谷で人気のラーメン店を紹介します。", 5), "ja"},
+		{"japanese with latin menu", strings.Repeat("Ramen Shop Tokyo の醤油ラーメン ", 5), "ja"},
+		{"chinese", strings.Repeat("涩谷最受欢迎的拉面店推荐给大家。", 5), "zh"},
+		{"korean", strings.Repeat("시부야에서 인기 있는 라멘 가게를 소개합니다. ", 5), "ko"},
+		{"english", strings.Repeat("Popular ramen shops in Shibuya. ", 5), "en"},
+		{"too short", "ラーメン", ""},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.text); got != tt.want {
+			t.Errorf("%s: detectLanguage = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPageLanguageFallsBackToHTMLLang(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html lang="zh-TW"><body><a href="/">Top</a></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pageLanguage(doc); got != "zh" {
+		t.Errorf("pageLanguage = %q, want zh", got)
+	}
+}
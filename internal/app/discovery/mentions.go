@@ -0,0 +1,122 @@
+package discovery
+
+import (
+	"log/slog"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+)
+
+// スコア合成の設定
+const (
+	gptScoreWeight       = 0.7
+	mentionScoreWeight   = 0.3
+	mentionsForFullScore = 20 // 言及元ページ数がこの値に達すると言及スコアが100点になる
+)
+
+// socialHosts はSNSとして扱うホストの一覧です。これ以外の食べログ外ページはニュースとして扱います。
+var socialHosts = []string{
+	"twitter.com", "x.com", "instagram.com", "facebook.com", "tiktok.com", "youtube.com", "threads.net",
+}
+
+// storeMentions は1店舗分の言及元ページを保持します。
+type storeMentions struct {
+	Name    string
+	Sources map[string]string // key: 言及元ページURL, value: 言及元の種別
+}
+
+// mentionCollector は検索で辿ったページごとに、どの店舗へ言及していたかを集計します。
+// 検索結果の店舗の重複排除とは異なり、同じ店舗でも別のページからの言及はすべて記録します。
+type mentionCollector struct {
+	logger *slog.Logger
+	stores map[string]*storeMentions // key: 正規化された店舗URL
+}
+
+func newMentionCollector(logger *slog.Logger) *mentionCollector {
+	return &mentionCollector{logger: logger, stores: make(map[string]*storeMentions)}
+}
+
+// addは店舗storeURLがsourceURLのページで言及されたことを記録します。
+func (c *mentionCollector) add(storeURL, name, sourceURL, sourceType string) {
+	sm, ok := c.stores[storeURL]
+	if !ok {
+		sm = &storeMentions{Name: name, Sources: make(map[string]string)}
+		c.stores[storeURL] = sm
+	}
+	if _, exists := sm.Sources[sourceURL]; !exists {
+		sm.Sources[sourceURL] = sourceType
+		c.logger.Debug("言及を記録", "store", name, "source_type", sourceType, "source_url", sourceURL)
+	}
+}
+
+// matchTextは食べログ外のページ本文(タイトル・説明文)に店舗名が含まれていれば、そのページからの言及として記録します。
+func (c *mentionCollector) matchText(sourceURL, sourceType, text string) {
+	for storeURL, sm := range c.stores {
+		if len([]rune(sm.Name)) < 2 {
+			continue
+		}
+		if strings.Contains(text, sm.Name) {
+			c.add(storeURL, sm.Name, sourceURL, sourceType)
+		}
+	}
+}
+
+// totalは全店舗の言及元ページ数の合計を返します。
+func (c *mentionCollector) total() int {
+	n := 0
+	for _, sm := range c.stores {
+		n += len(sm.Sources)
+	}
+	return n
+}
+
+// classifySourceは食べログ外のページをSNSかニュースかに分類します。
+func classifySource(u *url.URL) string {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	for _, h := range socialHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return model.SourceTypeSocial
+		}
+	}
+	return model.SourceTypeNews
+}
+
+// compositeScoreはGPTの話題性スコアと言及元ページ数から合成スコア(0〜100)を計算します。
+func compositeScore(gptScore float64, mentions int) float64 {
+	mentionScore := math.Min(100, float64(mentions)*100/mentionsForFullScore)
+	return gptScore*gptScoreWeight + mentionScore*mentionScoreWeight
+}
+
+// saveMentionsは集計した言及を店舗・言及テーブルに保存します。言及にはこの実行のrunIDを記録します。
+func saveMentions(logger *slog.Logger, repo *repository.StoreRepository, runID string, topicID uint, week time.Time, c *mentionCollector) {
+	for storeURL, sm := range c.stores {
+		// 検証に失敗した店舗は言及を含めて保存せず、ランキングに混ざらないようにする
+		data := StoreData{Name: sm.Name, URL: storeURL}
+		if reasons := validateStore(data); len(reasons) > 0 {
+			quarantineStore(logger, repo, model.QuarantineStageMention, runID, data, reasons)
+			continue
+		}
+		store := model.Store{URL: storeURL, Name: sm.Name}
+		if err := repo.Upsert(&store); err != nil {
+			logger.Error("店舗保存失敗", "url", storeURL, "error", err)
+			continue
+		}
+		for sourceURL, sourceType := range sm.Sources {
+			mention := model.StoreMention{
+				StoreID:    store.ID,
+				TopicID:    topicID,
+				Week:       week,
+				SourceURL:  sourceURL,
+				SourceType: sourceType,
+				RunID:      runID,
+			}
+			if err := repo.RecordMention(&mention); err != nil {
+				logger.Error("言及保存失敗", "url", storeURL, "source_url", sourceURL, "error", err)
+			}
+		}
+	}
+}
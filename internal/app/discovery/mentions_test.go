@@ -0,0 +1,30 @@
+package discovery
+
+import (
+	"log/slog"
+	"testing"
+
+	"excavation_service/internal/app/model"
+)
+
+func TestMentionCollector(t *testing.T) {
+	c := newMentionCollector(slog.Default())
+	c.add("https://tabelog.com/tokyo/A1311/A131105/13034566", "テスト食堂", "https://tabelog.com/matome/1", model.SourceTypeMatome)
+	c.add("https://tabelog.com/tokyo/A1311/A131105/13034566", "テスト食堂", "https://tabelog.com/matome/1", model.SourceTypeMatome)
+	c.add("https://tabelog.com/tokyo/A1311/A131105/13034566", "テスト食堂", "https://tabelog.com/tokyo/rstLst/", model.SourceTypeListing)
+	c.matchText("https://example.com/news/1", model.SourceTypeNews, "話題のテスト食堂に行ってきた")
+	c.matchText("https://example.com/news/2", model.SourceTypeNews, "関係のない記事")
+
+	if got := c.total(); got != 3 {
+		t.Fatalf("total() = %d, want 3", got)
+	}
+}
+
+func TestCompositeScore(t *testing.T) {
+	if got := compositeScore(100, mentionsForFullScore*2); got != 100 {
+		t.Errorf("compositeScore(100, max) = %v, want 100", got)
+	}
+	if got := compositeScore(50, 0); got != 50*gptScoreWeight {
+		t.Errorf("compositeScore(50, 0) = %v, want %v", got, 50*gptScoreWeight)
+	}
+}
@@ -0,0 +1,47 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/repository"
+)
+
+// notifyPreferencesはトピックの通知の設定に従って、保存したトレンドを通知先に送ります。
+// 最低スコアに満たない設定には送らず、送り方がダイジェストの設定はdigestに追加して実行の終了時にまとめて送ります。
+// 通知に失敗してもトレンドの保存には影響させず、ログに出力するのみとします。
+func notifyPreferences(ctx context.Context, logger *slog.Logger, repo *repository.NotificationPreferenceRepository, notifier notify.Notifier,
+	digest *notify.Digest, topic model.EntityTopic, trend model.TopicTrend) {
+	prefs, err := repo.ListByTopic(topic.ID)
+	if err != nil {
+		logger.Error("通知の設定の取得失敗", "error", err)
+		return
+	}
+
+	line := preferenceLine(topic, trend)
+	for _, p := range prefs {
+		if p.MinScore != nil && trend.Score < *p.MinScore {
+			continue
+		}
+		if p.Mode == model.NotifyDigest {
+			digest.Add(p.Channel, line)
+			continue
+		}
+		msg := notify.Message{Recipient: p.Channel, Subject: fmt.Sprintf("「%s」のトレンド", topic.Topic), Body: line}
+		if err := notifier.Send(ctx, msg); err != nil {
+			logger.Error("トレンドの通知に失敗しました", "preference_id", p.ID, "to", notify.MaskSlackRecipient(p.Channel), "error", err)
+		}
+	}
+}
+
+// preferenceLineはトレンドの通知内容を1行で返します。
+func preferenceLine(topic model.EntityTopic, trend model.TopicTrend) string {
+	line := fmt.Sprintf("%s: スコア%.1f", topic.Topic, trend.Score)
+	if trend.Delta != nil {
+		line += fmt.Sprintf(" (前週比%+.1f)", *trend.Delta)
+	}
+	return line + " " + trend.TopTitle
+}
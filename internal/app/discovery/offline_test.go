@@ -0,0 +1,69 @@
+package discovery
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"excavation_service/internal/app/fixtures"
+)
+
+// TestSearchBraveOfflineは同梱のフィクスチャで、検索からまとめ記事・リストページ・ニュースまでを辿れることを確認します。
+func TestSearchBraveOffline(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv, err := fixtures.Start(logger, fixtures.Bundle())
+	if err != nil {
+		t.Fatalf("fixtures.Start() error = %v", err)
+	}
+	defer srv.Close()
+
+	origHTTP, origBrave, origGPT := httpClient.Transport, braveClient.Transport, gptClient.Transport
+	defer func() {
+		httpClient.Transport, braveClient.Transport, gptClient.Transport = origHTTP, origBrave, origGPT
+	}()
+	SetTransport(srv.Transport())
+
+	ctx := context.Background()
+	combined, topTitle, mentions, err := SearchBrave(ctx, logger, "offline", "西日暮里")
+	if err != nil {
+		t.Fatalf("SearchBrave() error = %v", err)
+	}
+	if combined == "" || topTitle == "" {
+		t.Fatalf("SearchBrave() found no stores")
+	}
+	for _, name := range []string{"スパイス食堂 ほし", "中華そば 青葉"} {
+		if !strings.Contains(topTitle, name) {
+			t.Errorf("top title %q does not contain %q", topTitle, name)
+		}
+	}
+	// 青葉はまとめ記事・リストページ・ニュース・SNSの4ページから言及されている
+	if got := mentions.stores["https://tabelog.com/tokyo/A1311/A131105/13000002"]; got == nil || len(got.Sources) != 4 {
+		t.Errorf("mentions of 中華そば 青葉 = %+v, want 4 sources", got)
+	}
+
+	if score, err := analyzeWithGPT(ctx, logger, "offline", combined); err != nil || score != 72 {
+		t.Errorf("analyzeWithGPT() = %v, %v, want 72", score, err)
+	}
+}
+
+func TestFixtureServerNotFound(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv, err := fixtures.Start(logger, fixtures.Bundle())
+	if err != nil {
+		t.Fatalf("fixtures.Start() error = %v", err)
+	}
+	defer srv.Close()
+
+	client := &http.Client{Transport: srv.Transport()}
+	resp, err := client.Get("https://tabelog.com/tokyo/A1311/A131105/99999999/")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,379 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"excavation_service/internal/app/activity"
+	"excavation_service/internal/app/apperr"
+	"excavation_service/internal/app/dedup"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/outbox"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+)
+
+// 発掘のパイプラインの段階。トピックはこの順に各段階を通ります。
+const (
+	StageFetch   = "fetch"   // エンティティの種別の発掘元(Source)で店舗の候補を探す
+	StageParse   = "parse"   // 発掘元ごとの候補をまとめ、GPTに渡す店舗と言及を決める
+	StageEnrich  = "enrich"  // 言及を保存し、新しい店舗を知らせ、保存済みのトレンドと照合する
+	StageScore   = "score"   // GPTと言及数からスコアを計算する
+	StagePersist = "persist" // トレンドを保存して通知する
+)
+
+// Stages はパイプラインの段階を処理する順に並べたものです。
+var Stages = []string{StageFetch, StageParse, StageEnrich, StageScore, StagePersist}
+
+// pipelineMetrics は段階ごとの処理件数と処理時間、次の段階が空くのを待った時間(ミリ秒)を集計します。
+// PPROF_ADDR設定時は/debug/varsからも参照できます。
+var pipelineMetrics = expvar.NewMap("pipeline")
+
+// topicWork はパイプラインを流れる1つのトピックの処理状態です。
+type topicWork struct {
+	ctx        context.Context
+	span       trace.Span
+	logger     *slog.Logger
+	topic      model.EntityTopic
+	entityType string
+	result     model.JobTopic
+	err        error
+	done       bool         // 結果が決まったため、以降の段階を飛ばす
+	budget     *topicBudget // fetchで使うクロールの予算
+
+	candidates     []StoreCandidate // fetchの結果
+	combinedTitles string           // parseの結果
+	topTitle       string
+	mentions       *mentionCollector
+	mentionCount   int // enrichの結果
+	gptScore       float64
+	score          float64 // scoreの結果
+	delta          *float64
+	prev           *model.TopicTrend
+}
+
+// finishはトピックの結果が決まったことを記録し、以降の段階を飛ばすようにします。
+func (w *topicWork) finish(outcome string, trendID *uint) {
+	w.result.Outcome, w.result.TrendID, w.done = outcome, trendID, true
+}
+
+// pipeline は1回の実行でトピックを発掘する各段階と、段階が使う依存をまとめたものです。
+type pipeline struct {
+	db                 *gorm.DB
+	storeRepo          *repository.StoreRepository
+	trendRepo          *repository.TrendRepository
+	watchRepo          *repository.WatchRepository
+	digest, prefDigest *notify.Digest
+	week               time.Time
+	opts               Options
+	sources            map[string][]Source // key: エンティティの種別。""は指定のない種別に使う
+	entityTypes        map[uint]string     // key: トピックのID
+}
+
+// reportはトピックの進捗を運用者のダッシュボードに知らせます。届かなくても発掘は続けます。
+func (p *pipeline) report(w *topicWork, event activity.Event) {
+	event.RunID, event.JobID, event.TenantID, event.TopicID, event.Topic = p.opts.RunID, w.result.JobID, w.topic.TenantID, w.topic.ID, w.topic.Topic
+	if err := activity.Publish(w.ctx, p.db, event); err != nil {
+		w.logger.Debug("進捗の通知に失敗しました", "type", event.Type, "error", err)
+	}
+}
+
+func (p *pipeline) stage(name string) func(*topicWork) {
+	switch name {
+	case StageFetch:
+		return p.fetch
+	case StageParse:
+		return p.parse
+	case StageEnrich:
+		return p.enrich
+	case StageScore:
+		return p.score
+	default:
+		return p.persist
+	}
+}
+
+func (p *pipeline) fetch(w *topicWork) {
+	p.report(w, activity.Event{Type: activity.TopicStarted})
+	sources, ok := p.sources[w.entityType]
+	if !ok {
+		sources = p.sources[""]
+	}
+	ctx := withSourceLogger(w.ctx, w.logger)
+	if d := p.opts.Budget.WallClock; d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	var failure error // 店舗の候補が見つからなかった以外の、最初の発掘元の失敗
+	noResults := 0
+	for _, src := range sources {
+		if ctx.Err() != nil {
+			break
+		}
+		candidates, err := src.Discover(ctx, w.topic)
+		if err != nil {
+			// 1つの発掘元が失敗しても、他の発掘元の候補でトレンドを発掘する
+			code := apperr.Count("discovery.source."+src.Name(), err)
+			if errors.Is(err, apperr.ErrNoResults) {
+				noResults++
+				w.logger.Info("発掘元で店舗の候補が見つかりませんでした", "source", src.Name(), "error", err)
+				continue
+			}
+			w.logger.Error("発掘元での店舗の候補の取得に失敗しました", "source", src.Name(), "error", err, "code", code)
+			if failure == nil {
+				failure = fmt.Errorf("discover candidates from %s: %w", src.Name(), err)
+			}
+			continue
+		}
+		w.logger.Debug("発掘元で店舗の候補を取得しました", "source", src.Name(), "candidates", len(candidates))
+		w.candidates = append(w.candidates, candidates...)
+	}
+	if len(w.candidates) == 0 && ctx.Err() == nil {
+		switch {
+		case failure != nil:
+			// 候補がないのは発掘元の失敗のためで、店舗が見つからなかったのではないためトピックを失敗とする
+			w.err = failure
+		case noResults > 0:
+			w.result.ErrorCode = string(apperr.CodeNoResults)
+		}
+	}
+	if ctx.Err() != nil && w.ctx.Err() == nil {
+		// 時間の予算を過ぎても、それまでに見つけた候補でトレンドを発掘する
+		w.budget.exhaust(BudgetWallClock)
+		w.logger.Warn("店舗の候補を探す時間の予算を使い切りました", "budget", BudgetWallClock, "wall_clock", p.opts.Budget.WallClock, "candidates", len(w.candidates))
+	}
+	w.result.BudgetExhausted = w.budget.exhaustedList()
+}
+
+func (p *pipeline) parse(w *topicWork) {
+	featured, mentions := mergeCandidates(w.logger, w.candidates)
+	w.combinedTitles, w.topTitle = gptTitles(featured)
+	w.mentions = mentions
+	w.candidates = nil
+	w.result.Stores = len(mentions.stores)
+	p.report(w, activity.Event{Type: activity.StoresFound, Stores: len(mentions.stores)})
+}
+
+func (p *pipeline) enrich(w *topicWork) {
+	storeRepo := p.storeRepo.WithContext(w.ctx)
+	// 言及はトレンドの有無に関わらず週ごとに蓄積する
+	saveMentions(w.logger, storeRepo, p.opts.RunID, w.topic.ID, p.week, w.mentions)
+	w.result.Mentions, w.result.TopTitle = w.mentions.total(), w.topTitle
+	// 初めて言及された店舗はスコアの計算を待たずに知らせる
+	notifyNewStores(w.ctx, w.logger, p.db.WithContext(w.ctx), storeRepo, p.watchRepo.WithContext(w.ctx), p.opts.Notifier, w.topic, p.week, p.opts.RunID)
+	if top, err := storeRepo.MostMentioned(w.topic.ID, p.week, 5); err != nil {
+		w.logger.Error("言及数ランキング取得失敗", "error", err)
+	} else {
+		for i, s := range top {
+			w.logger.Info("言及数ランキング", "rank", i+1, "store", s.Name, "mentions", s.Mentions, "url", s.URL)
+		}
+	}
+
+	if w.topTitle == "" || w.combinedTitles == "" {
+		w.logger.Warn("Brave検索結果から有効な店舗名が見つかりませんでした")
+		w.finish(model.TopicOutcomeNoStores, nil)
+		return
+	}
+
+	var existing model.TopicTrend
+	// 今週のトレンドは同じ店舗の組み合わせでも置き換えるため、やり直した実行でも照合しない
+	if err := p.db.WithContext(w.ctx).Where("topic_id = ? AND top_title = ? AND week <> ?", w.topic.ID, w.topTitle, p.week).First(&existing).Error; err == nil {
+		w.logger.Info("同じ店舗の組み合わせのトレンドが既に存在するためスキップ", "top_title", w.topTitle)
+		w.finish(model.TopicOutcomeDuplicate, &existing.ID)
+		return
+	}
+
+	// 今週これまでに蓄積した言及元ページ数を合成スコアに反映する
+	w.mentionCount = w.mentions.total()
+	if n, err := storeRepo.CountMentions(w.topic.ID, p.week); err != nil {
+		w.logger.Error("言及数取得失敗、今回の検索分のみで計算します", "error", err)
+	} else {
+		w.mentionCount = int(n)
+	}
+}
+
+func (p *pipeline) score(w *topicWork) {
+	gptScore, err := analyzeWithGPT(w.ctx, w.logger, p.opts.OpenAIAPIKey, w.combinedTitles)
+	if err != nil {
+		// GPTのスコアが0点のトレンドを保存しないよう、トピックを失敗とする
+		w.err = err
+		return
+	}
+	w.gptScore = gptScore
+	w.score = compositeScore(w.gptScore, w.mentionCount)
+
+	prev, err := p.trendRepo.WithContext(w.ctx).LatestBefore(w.topic.ID, p.week)
+	if err != nil {
+		w.logger.Error("前週のトレンド取得失敗", "error", err)
+	}
+	if prev != nil {
+		d := w.score - prev.Score
+		w.delta = &d
+	}
+	w.prev = prev
+}
+
+func (p *pipeline) persist(w *topicWork) {
+	db := p.db.WithContext(w.ctx)
+	trend := model.TopicTrend{
+		TopicID:      w.topic.ID,
+		Week:         p.week,
+		Score:        w.score,
+		GPTScore:     w.gptScore,
+		Delta:        w.delta,
+		MentionCount: w.mentionCount,
+		TopTitle:     w.topTitle,
+		RunID:        p.opts.RunID,
+		Prompt:       gptPrompt(w.combinedTitles),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	// トレンドはトピック・週ごとに1件とし、やり直した実行では今週のトレンドを置き換える。
+	// trend.createdのイベントはトレンドと同じトランザクションで記録し、保存されなかったトレンドのイベントを配信しないようにする
+	created, err := p.trendRepo.WithContext(w.ctx).SaveWeekly(&trend, func(tx *gorm.DB) error {
+		return outbox.Publish(tx, model.EventTrendCreated, trendCreatedEvent{
+			TrendID: trend.ID, TenantID: w.topic.TenantID, TopicID: w.topic.ID, Topic: w.topic.Topic, Week: p.week.Format("2006-01-02"), Score: w.score, GPTScore: w.gptScore,
+			Delta: w.delta, MentionCount: w.mentionCount, TopTitle: w.topTitle, RunID: p.opts.RunID,
+		})
+	})
+	if err != nil {
+		w.err = fmt.Errorf("save trend: %w", err)
+		return
+	}
+	w.finish(model.TopicOutcomeTrendSaved, &trend.ID)
+	p.report(w, activity.Event{Type: activity.TrendSaved, TrendID: trend.ID, Score: &w.score})
+	if !created {
+		// 通知は今週のトレンドを初めて保存したときに送り済みのため、やり直しでは送らない
+		w.logger.Info("今週のトレンドを置き換えました", "trend_id", trend.ID, "top_title", w.topTitle, "score", w.score, "gpt_score", w.gptScore, "mentions", w.mentionCount)
+		return
+	}
+	w.logger.Info("トレンド保存完了", "top_title", w.topTitle, "score", w.score, "gpt_score", w.gptScore, "mentions", w.mentionCount)
+
+	checkWatches(w.logger, p.watchRepo.WithContext(w.ctx), p.digest, w.topic, trend, w.prev)
+	notifyPreferences(w.ctx, w.logger, repository.NewNotificationPreferenceRepository(db), p.opts.Notifier, p.prefDigest, w.topic, trend)
+}
+
+// stageStats は1回の実行での段階ごとの処理の集計です。
+type stageStats struct {
+	items   atomic.Int64
+	busy    atomic.Int64 // 処理にかかった時間の合計 (ナノ秒)
+	blocked atomic.Int64 // 次の段階が空くのを待った時間の合計 (ナノ秒)
+}
+
+// run はtopicsをパイプラインで発掘し、各段階を通り終えたトピックごとにdoneを呼び出します。
+// 段階ごとにworkers[段階]個(指定がなければdefaultWorkers個)のワーカーが並行して処理し、段階の間はワーカー数分のバッファを持つチャネルでつなぎます。
+// 後ろの段階が詰まると前の段階はチャネルへの送信で待つため、GPTの応答が遅くても取得済みの結果が際限なく溜まることはありません。
+// ctxが終了すると新しいトピックの投入をやめ、投入済みのトピックの処理が終わるのを待って返ります。
+func (p *pipeline) run(ctx context.Context, logger *slog.Logger, jobID uint, topics []model.EntityTopic, workers map[string]int, defaultWorkers int, done func(*topicWork)) {
+	stats := make(map[string]*stageStats, len(Stages))
+	g := new(errgroup.Group)
+
+	source := make(chan *topicWork)
+	in := source
+	for _, name := range Stages {
+		n := workers[name]
+		if n < 1 {
+			n = max(defaultWorkers, 1)
+		}
+		st := &stageStats{}
+		stats[name] = st
+		out := make(chan *topicWork, n)
+		runStage(g, ctx, name, n, p.stage(name), st, in, out)
+		in = out
+	}
+	sink := in
+	g.Go(func() error {
+		for w := range sink {
+			w.span.End()
+			done(w)
+			p.report(w, activity.Event{Type: activity.TopicFinished, Outcome: w.result.Outcome, Error: w.result.Error})
+		}
+		return nil
+	})
+
+	for _, topic := range topics {
+		if ctx.Err() != nil {
+			break
+		}
+		w := &topicWork{topic: topic, entityType: p.entityTypes[topic.ID], logger: logger.With("topic_id", topic.ID, "topic", topic.Topic),
+			result: model.JobTopic{JobID: jobID, TopicID: topic.ID, StartedAt: time.Now()}}
+		w.ctx, w.span = tracing.Tracer().Start(ctx, "discover_topic",
+			trace.WithAttributes(
+				attribute.Int("topic_id", int(topic.ID)),
+				attribute.String("topic", topic.Topic),
+			))
+		w.budget = newTopicBudget(p.opts.Budget)
+		w.ctx = withBudget(w.ctx, w.budget)
+		if p.opts.Dedup != nil {
+			// 同じ実行の同じトピックの中でのみ重複を排除する。言及はトピックごとに集計するため
+			w.ctx = withDedup(w.ctx, dedup.Scoped(p.opts.Dedup, p.opts.RunID+":"+strconv.FormatUint(uint64(topic.ID), 10)))
+		}
+		source <- w
+	}
+	close(source)
+	g.Wait()
+
+	for _, name := range Stages {
+		st := stats[name]
+		logger.Info("パイプラインの段階ごとの処理時間", "stage", name, "topics", st.items.Load(),
+			"busy", time.Duration(st.busy.Load()).Round(time.Millisecond), "blocked", time.Duration(st.blocked.Load()).Round(time.Millisecond))
+	}
+}
+
+// runStageはinから受け取ったトピックをn個のワーカーでprocessに渡し、outに送ります。inが閉じて処理が終わるとoutを閉じます。
+// 失敗したか結果が決まったトピックはprocessに渡さずにそのままoutに送ります。
+// ワーカーのgoroutineにはpprofのラベルstageを付けるため、CPUプロファイルを段階ごとに絞り込めます。
+func runStage(g *errgroup.Group, ctx context.Context, name string, n int, process func(*topicWork), st *stageStats, in <-chan *topicWork, out chan<- *topicWork) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		g.Go(func() error {
+			defer wg.Done()
+			pprof.Do(ctx, pprof.Labels("stage", name), func(context.Context) {
+				for w := range in {
+					if !w.done && w.err == nil {
+						stageCtx, span := tracing.Tracer().Start(w.ctx, "discover_"+name)
+						// 段階の中のリクエストやクエリを段階のスパンの子にするため、処理中のみctxを差し替える
+						topicCtx := w.ctx
+						w.ctx = stageCtx
+						start := time.Now()
+						process(w)
+						elapsed := time.Since(start)
+						w.ctx = topicCtx
+						span.End()
+						st.items.Add(1)
+						st.busy.Add(int64(elapsed))
+						pipelineMetrics.Add(name+".topics", 1)
+						pipelineMetrics.Add(name+".busy_ms", elapsed.Milliseconds())
+					}
+					start := time.Now()
+					out <- w
+					blocked := time.Since(start)
+					st.blocked.Add(int64(blocked))
+					pipelineMetrics.Add(name+".blocked_ms", blocked.Milliseconds())
+				}
+			})
+			return nil
+		})
+	}
+	g.Go(func() error {
+		wg.Wait()
+		close(out)
+		return nil
+	})
+}
@@ -0,0 +1,47 @@
+package discovery
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"excavation_service/internal/app/model"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func TestRunStageSkipsFinishedTopics(t *testing.T) {
+	var processed atomic.Int32
+	g := new(errgroup.Group)
+	in := make(chan *topicWork)
+	out := make(chan *topicWork, 2)
+	st := &stageStats{}
+	runStage(g, context.Background(), StageScore, 2, func(w *topicWork) {
+		processed.Add(1)
+		w.score = 1
+	}, st, in, out)
+
+	go func() {
+		in <- &topicWork{ctx: context.Background(), topic: model.EntityTopic{ID: 1}}
+		in <- &topicWork{ctx: context.Background(), topic: model.EntityTopic{ID: 2}, done: true}
+		in <- &topicWork{ctx: context.Background(), topic: model.EntityTopic{ID: 3}}
+		close(in)
+	}()
+	var got []*topicWork
+	for w := range out {
+		got = append(got, w)
+	}
+	g.Wait()
+
+	if len(got) != 3 {
+		t.Fatalf("passed %d topics, want 3", len(got))
+	}
+	if n := processed.Load(); n != 2 || st.items.Load() != 2 {
+		t.Errorf("processed %d topics (stats %d), want 2", n, st.items.Load())
+	}
+	for _, w := range got {
+		if (w.score == 1) == w.done {
+			t.Errorf("topic %d: score = %v, done = %v", w.topic.ID, w.score, w.done)
+		}
+	}
+}
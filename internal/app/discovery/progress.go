@@ -0,0 +1,107 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+)
+
+// progress は実行中の発掘の進捗を集計し、ジョブとして記録します。
+// トピックの処理と定期的な出力の両方から更新されるため、muで保護します。
+type progress struct {
+	mu           sync.Mutex
+	job          *model.Job
+	jobs         *repository.JobRepository
+	pagesAtStart int64 // 実行開始時点の取得ページ数。expvarの値はプロセス全体の累計のため差分を取る
+}
+
+func newProgress(jobs *repository.JobRepository, job *model.Job) *progress {
+	return &progress{job: job, jobs: jobs, pagesAtStart: metricValue("pages_fetched")}
+}
+
+// topicDoneはトピックの処理が終わったことを記録し、ジョブの進捗を更新します。storesはトピックで言及された店舗の数です。
+func (p *progress) topicDone(failed bool, stores int, now time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.job.TopicsDone++
+	p.job.StoresFound += stores
+	if failed {
+		p.job.TopicsFailed++
+	}
+	return p.saveLocked(now)
+}
+
+// saveは取得ページ数と終了見込みを更新してジョブを保存します。
+func (p *progress) save(now time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.saveLocked(now)
+}
+
+func (p *progress) saveLocked(now time.Time) error {
+	p.job.PagesFetched = metricValue("pages_fetched") - p.pagesAtStart
+	p.job.EstimatedFinishAt = nil
+	if eta, ok := estimateRemaining(p.job.TopicsDone, p.job.TopicsTotal, now.Sub(p.job.StartedAt)); ok {
+		finish := now.Add(eta)
+		p.job.EstimatedFinishAt = &finish
+	}
+	p.job.UpdatedAt = now
+	return p.jobs.Save(p.job)
+}
+
+// finishはジョブを終了した状態で保存します。
+func (p *progress) finish(errMsg string, now time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.job.PagesFetched = metricValue("pages_fetched") - p.pagesAtStart
+	p.job.UpdatedAt = now
+	return p.jobs.Finish(p.job, errMsg, now)
+}
+
+// lineは対話的な実行で表示する進捗の1行を返します。
+func (p *progress) line(now time.Time) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	elapsed := now.Sub(p.job.StartedAt)
+	pages := metricValue("pages_fetched") - p.pagesAtStart
+	s := fmt.Sprintf("[%d/%d topics] %d pages fetched, elapsed %s", p.job.TopicsDone, p.job.TopicsTotal, pages, elapsed.Round(time.Second))
+	if eta, ok := estimateRemaining(p.job.TopicsDone, p.job.TopicsTotal, elapsed); ok {
+		s += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	return s
+}
+
+// reportはintervalごとに進捗をoutに出力し、ジョブを更新します。ctxがキャンセルされると終了します。
+// outがnilの場合はジョブの更新のみ行います。
+func (p *progress) report(ctx context.Context, logger *slog.Logger, out io.Writer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if out != nil {
+				fmt.Fprintln(out, p.line(now))
+			}
+			if err := p.save(now); err != nil {
+				logger.Warn("ジョブの進捗の更新に失敗しました", "error", err)
+			}
+		}
+	}
+}
+
+// estimateRemainingは終わったトピックの平均所要時間から残りの所要時間を見積もります。
+// まだ1件も終わっていない場合はokがfalseです。
+func estimateRemaining(done, total int, elapsed time.Duration) (eta time.Duration, ok bool) {
+	if done <= 0 || total <= done {
+		return 0, done > 0
+	}
+	return elapsed / time.Duration(done) * time.Duration(total-done), true
+}
@@ -0,0 +1,25 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateRemaining(t *testing.T) {
+	tests := []struct {
+		done, total int
+		elapsed     time.Duration
+		want        time.Duration
+		wantOK      bool
+	}{
+		{done: 0, total: 10, elapsed: time.Minute, wantOK: false},
+		{done: 2, total: 10, elapsed: time.Minute, want: 4 * time.Minute, wantOK: true},
+		{done: 10, total: 10, elapsed: time.Minute, want: 0, wantOK: true},
+	}
+	for _, tt := range tests {
+		got, ok := estimateRemaining(tt.done, tt.total, tt.elapsed)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("estimateRemaining(%d, %d, %s) = %s, %v; want %s, %v", tt.done, tt.total, tt.elapsed, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
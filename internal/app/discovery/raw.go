@@ -0,0 +1,39 @@
+package discovery
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"excavation_service/internal/app/analytics"
+)
+
+type rawRecorderKey struct{}
+
+// withRawRecorderは検索APIのレスポンスをrecorderに記録するctxを返します。
+func withRawRecorder(ctx context.Context, recorder *analytics.RawRecorder) context.Context {
+	return context.WithValue(ctx, rawRecorderKey{}, recorder)
+}
+
+// rawRecordingはctxに検索APIのレスポンスのレコーダーがあるか判定します。
+func rawRecording(ctx context.Context) bool {
+	_, ok := ctx.Value(rawRecorderKey{}).(*analytics.RawRecorder)
+	return ok
+}
+
+// recordRawResponseはctxにレコーダーがあれば検索APIのレスポンスを記録します。
+func recordRawResponse(ctx context.Context, query, url string, status int, body []byte) {
+	if recorder, ok := ctx.Value(rawRecorderKey{}).(*analytics.RawRecorder); ok {
+		recorder.Record(query, url, status, body, time.Now())
+	}
+}
+
+// flushRawResponsesは記録した検索APIのレスポンスをopts.RawArchiveに書き出します。
+func flushRawResponses(ctx context.Context, logger *slog.Logger, opts Options, recorder *analytics.RawRecorder, startedAt time.Time) {
+	n, err := recorder.Flush(ctx, opts.RawArchive, startedAt)
+	if err != nil {
+		logger.Error("検索APIのレスポンスの書き出しに失敗しました", "error", err)
+		return
+	}
+	logger.Info("検索APIのレスポンスを書き出しました", "responses", n)
+}
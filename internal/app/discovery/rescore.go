@@ -0,0 +1,77 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+
+	"gorm.io/gorm"
+)
+
+// RescoreOptions はRescoreで計算し直すトレンドと、GPTによる再スコアリングの設定です。
+type RescoreOptions struct {
+	TopicID      uint   // 0でなければこのトピックのトレンドのみ計算し直す
+	Model        string // 空でなければ、保存済みのプロンプトをこのモデルのGPTに渡してGPTスコアから計算し直す
+	OpenAIAPIKey string // Modelを指定する場合に必要
+}
+
+// Rescoreは指定した週のトレンドのスコアを、保存済みの入力から計算し直します。前週比も合わせて更新します。
+// Modelを指定しなければ保存済みのGPTスコアと言及数から合成スコアのみを計算し直すため、合成スコアの重みを変更した後などに使います。
+// Modelを指定すると、トレンドに保存されたプロンプトでGPTのスコアリングのみをやり直します。どちらもクロールは行いません。
+// 返り値は更新したトレンドの数です。
+func Rescore(ctx context.Context, logger *slog.Logger, db *gorm.DB, w time.Time, opts RescoreOptions) (int, error) {
+	db = db.WithContext(ctx)
+	trendRepo := repository.NewTrendRepository(db)
+
+	var trends []model.TopicTrend
+	q := db.Where("week = ?", w).Order("id")
+	if opts.TopicID != 0 {
+		q = q.Where("topic_id = ?", opts.TopicID)
+	}
+	if err := q.Find(&trends).Error; err != nil {
+		return 0, fmt.Errorf("list trends: %w", err)
+	}
+
+	updated := 0
+	for _, t := range trends {
+		updates := map[string]interface{}{}
+		gptScore := t.GPTScore
+		if opts.Model != "" {
+			system, input, ok := splitGPTPrompt(t.Prompt)
+			if !ok {
+				// プロンプトを保存する前に作成されたトレンドは入力を復元できない
+				logger.Warn("保存済みのプロンプトがないためスキップします", "trend_id", t.ID, "topic_id", t.TopicID)
+				continue
+			}
+			s, err := scoreWithGPT(ctx, logger, opts.OpenAIAPIKey, opts.Model, system, input)
+			if err != nil {
+				return updated, fmt.Errorf("score trend %d with %s: %w", t.ID, opts.Model, err)
+			}
+			gptScore = s
+			updates["gpt_score"] = gptScore
+		}
+
+		score := compositeScore(gptScore, t.MentionCount)
+		prev, err := trendRepo.LatestBefore(t.TopicID, t.Week)
+		if err != nil {
+			return updated, fmt.Errorf("previous trend of topic %d: %w", t.TopicID, err)
+		}
+		var delta *float64
+		if prev != nil {
+			d := score - prev.Score
+			delta = &d
+		}
+		updates["score"], updates["delta"], updates["updated_at"] = score, delta, time.Now()
+		if err := db.Model(&model.TopicTrend{}).Where("id = ?", t.ID).Updates(updates).Error; err != nil {
+			return updated, fmt.Errorf("update trend %d: %w", t.ID, err)
+		}
+		updated++
+		logger.Info("スコアを再計算しました", "trend_id", t.ID, "topic_id", t.TopicID,
+			"old_gpt_score", t.GPTScore, "gpt_score", gptScore, "old_score", t.Score, "score", score)
+	}
+	return updated, nil
+}
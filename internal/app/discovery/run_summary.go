@@ -0,0 +1,154 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+
+	"gorm.io/gorm"
+)
+
+// runSummaryTopN は実行結果の通知に載せるスコア上位のトピックの数です。
+const runSummaryTopN = 5
+
+// notifyRunSummaryは実行で保存したトレンドと新しく見つかった店舗をまとめ、opts.RunNotifierに送ります。
+// 通知に失敗しても実行の結果には影響させず、ログに出力するのみとします。
+func notifyRunSummary(ctx context.Context, logger *slog.Logger, db *gorm.DB, opts Options, job model.Job, topics []model.EntityTopic, trendIDs []uint, budgetExhausted, failures map[uint]string) {
+	var trends []model.TopicTrend
+	if len(trendIDs) > 0 {
+		if err := db.Where("id IN ?", trendIDs).Find(&trends).Error; err != nil {
+			logger.Error("実行結果の通知のトレンド取得失敗", "error", err)
+			return
+		}
+	}
+	stores, err := repository.NewStoreRepository(db).NewStoresByRun(job.RunID, job.StartedAt)
+	if err != nil {
+		logger.Error("実行結果の通知の店舗取得失敗", "error", err)
+		return
+	}
+
+	for _, msg := range runSummaryMessages(job, topics, trends, stores, budgetExhausted, failures, opts.RunNotifyAreas) {
+		if err := opts.RunNotifier.Send(ctx, msg); err != nil {
+			logger.Error("実行結果の通知に失敗しました", "to", msg.Recipient, "error", err)
+		}
+	}
+}
+
+// runSummaryMessagesは実行結果の通知を作成します。既定の通知先(Recipientが空)には全体を、
+// areasの各エリアにはトピック名にそのエリアを含むトピックのトレンドと店舗のみを送ります。該当するものがないエリアには送りません。
+// 失敗したトピック(failures)とクロールの予算を使い切ったトピック(budgetExhausted)は既定の通知先にのみ載せます。
+func runSummaryMessages(job model.Job, topics []model.EntityTopic, trends []model.TopicTrend, stores []repository.NewStore, budgetExhausted, failures map[uint]string, areas []string) []notify.Message {
+	topicNames := make(map[uint]string, len(topics))
+	for _, t := range topics {
+		topicNames[t.ID] = t.Topic
+	}
+	sort.SliceStable(trends, func(i, j int) bool { return trends[i].Score > trends[j].Score })
+
+	subject := fmt.Sprintf("発掘の実行結果 %s週", week.Of(job.StartedAt).Format(week.Layout))
+	stats := fmt.Sprintf("%d件のトピックを発掘し、%d件のトレンドを保存しました (失敗%d件、run_id %s)",
+		job.TopicsTotal, len(trends), job.TopicsFailed, job.RunID)
+	messages := []notify.Message{{
+		Subject: subject,
+		Body:    stats + "\n" + runSummaryBody(topicNames, trends, stores) + failureSummary(topics, failures) + budgetSummary(topics, budgetExhausted),
+	}}
+
+	sorted := append([]string(nil), areas...)
+	sort.Strings(sorted)
+	for _, area := range sorted {
+		inArea := func(topicID uint) bool { return strings.Contains(topicNames[topicID], area) }
+		var areaTrends []model.TopicTrend
+		for _, t := range trends {
+			if inArea(t.TopicID) {
+				areaTrends = append(areaTrends, t)
+			}
+		}
+		var areaStores []repository.NewStore
+		for _, s := range stores {
+			if inArea(s.TopicID) {
+				areaStores = append(areaStores, s)
+			}
+		}
+		if len(areaTrends) == 0 && len(areaStores) == 0 {
+			continue
+		}
+		messages = append(messages, notify.Message{
+			Recipient: area,
+			Subject:   fmt.Sprintf("%s (%s)", subject, area),
+			Body:      runSummaryBody(topicNames, areaTrends, areaStores),
+		})
+	}
+	return messages
+}
+
+// failureSummaryは失敗したトピックを失敗の分類のコード(apperr.Code)ごとにまとめた一覧を返します。失敗したトピックがなければ空文字列です。
+// 同じコードの失敗が多い場合は、レート制限や認証など外部のAPI側の問題であることが分かります。
+func failureSummary(topics []model.EntityTopic, failures map[uint]string) string {
+	if len(failures) == 0 {
+		return ""
+	}
+	byCode := make(map[string][]string)
+	for _, t := range topics {
+		if code, ok := failures[t.ID]; ok {
+			byCode[code] = append(byCode[code], t.Topic)
+		}
+	}
+	codes := make([]string, 0, len(byCode))
+	for code := range byCode {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\n失敗したトピック (%d件)", len(failures))
+	for _, code := range codes {
+		fmt.Fprintf(&b, "\n- %s (%d件): %s", code, len(byCode[code]), strings.Join(byCode[code], ", "))
+	}
+	return b.String()
+}
+
+// budgetSummaryはクロールの予算を使い切ったトピックと使い切った予算の種類の一覧を返します。該当するトピックがなければ空文字列です。
+func budgetSummary(topics []model.EntityTopic, budgetExhausted map[uint]string) string {
+	if len(budgetExhausted) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\nクロールの予算を使い切ったトピック (%d件)", len(budgetExhausted))
+	for _, t := range topics {
+		if kinds, ok := budgetExhausted[t.ID]; ok {
+			fmt.Fprintf(&b, "\n- %s: %s", t.Topic, kinds)
+		}
+	}
+	return b.String()
+}
+
+// runSummaryBodyはスコアの高い順に並んだtrendsの上位と、新しく見つかった店舗の一覧を返します。
+func runSummaryBody(topicNames map[uint]string, trends []model.TopicTrend, stores []repository.NewStore) string {
+	var b strings.Builder
+	if len(trends) > 0 {
+		b.WriteString("\nスコア上位のトピック\n")
+		for i, t := range trends {
+			if i == runSummaryTopN {
+				break
+			}
+			fmt.Fprintf(&b, "%d. %s: %.1f", i+1, topicNames[t.TopicID], t.Score)
+			if t.Delta != nil {
+				fmt.Fprintf(&b, " (前週比%+.1f)", *t.Delta)
+			}
+			fmt.Fprintf(&b, " %s\n", t.TopTitle)
+		}
+	}
+	if len(stores) > 0 {
+		fmt.Fprintf(&b, "\n新しく見つかった店舗 (%d件)\n", len(stores))
+		for _, s := range stores {
+			fmt.Fprintf(&b, "- %s (%s) %s\n", s.Name, topicNames[s.TopicID], s.URL)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,54 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+)
+
+func TestRunSummaryMessages(t *testing.T) {
+	job := model.Job{RunID: "abc123", TopicsTotal: 3, TopicsFailed: 1, StartedAt: time.Date(2024, 6, 5, 3, 0, 0, 0, time.Local)}
+	topics := []model.EntityTopic{
+		{ID: 1, Topic: "渋谷 カレー"},
+		{ID: 2, Topic: "新宿 ラーメン"},
+		{ID: 3, Topic: "池袋 焼鳥"},
+	}
+	delta := 5.0
+	trends := []model.TopicTrend{
+		{TopicID: 2, Score: 40, TopTitle: "中華そば 青葉"},
+		{TopicID: 1, Score: 72, Delta: &delta, TopTitle: "スパイス食堂 ほし"},
+	}
+	stores := []repository.NewStore{
+		{StoreID: 10, Name: "スパイス食堂 ほし", URL: "https://tabelog.com/tokyo/A1303/A130301/13000001", TopicID: 1},
+	}
+
+	exhausted := map[uint]string{3: "matome_pages,wall_clock"}
+	failures := map[uint]string{3: "upstream_rate_limited"}
+
+	msgs := runSummaryMessages(job, topics, trends, stores, exhausted, failures, []string{"渋谷", "池袋"})
+	if len(msgs) != 2 {
+		t.Fatalf("messages = %d, want 2 (overall and 渋谷; 池袋 has nothing)", len(msgs))
+	}
+
+	overall := msgs[0]
+	if overall.Recipient != "" || overall.Subject != "発掘の実行結果 2024-06-03週" {
+		t.Errorf("overall = %q %q", overall.Recipient, overall.Subject)
+	}
+	if i, j := strings.Index(overall.Body, "1. 渋谷 カレー: 72.0 (前週比+5.0)"), strings.Index(overall.Body, "2. 新宿 ラーメン: 40.0"); i < 0 || j < i {
+		t.Errorf("overall body is not sorted by score:\n%s", overall.Body)
+	}
+	if !strings.Contains(overall.Body, "失敗したトピック (1件)\n- upstream_rate_limited (1件): 池袋 焼鳥") {
+		t.Errorf("overall body does not list failures by code:\n%s", overall.Body)
+	}
+	if !strings.Contains(overall.Body, "クロールの予算を使い切ったトピック (1件)\n- 池袋 焼鳥: matome_pages,wall_clock") {
+		t.Errorf("overall body does not list exhausted budgets:\n%s", overall.Body)
+	}
+
+	area := msgs[1]
+	if area.Recipient != "渋谷" || strings.Contains(area.Body, "新宿") || !strings.Contains(area.Body, "- スパイス食堂 ほし (渋谷 カレー)") {
+		t.Errorf("area message = %q:\n%s", area.Recipient, area.Body)
+	}
+}
@@ -0,0 +1,185 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"excavation_service/internal/app/model"
+)
+
+// maxFeaturedStores はスコアリングでGPTに渡す店舗の最大数です。
+const maxFeaturedStores = 3
+
+// StoreCandidate は発掘元がトピックについて見つけた店舗の候補です。
+type StoreCandidate struct {
+	Name     string
+	URL      string            // 正規化された店舗ページのURL。店舗を識別するキーになる
+	Featured bool              // スコアリングでGPTに渡す店舗か
+	Sources  map[string]string // key: 言及元ページのURL, value: 言及元の種別 (model.SourceType*)
+}
+
+// Source はトピックから店舗の候補を見つける発掘元です。
+// Discoverが返す候補はFeaturedのものを優先度の高い順に先頭に並べます。
+// ctxにはトピックのロガーが入っているため、ログはsourceLogger(ctx)に出力します。
+type Source interface {
+	Name() string
+	Discover(ctx context.Context, topic model.EntityTopic) ([]StoreCandidate, error)
+}
+
+// SourceFactory は実行の設定から発掘元を作成します。
+type SourceFactory func(opts Options) Source
+
+var (
+	sourcesMu       sync.Mutex
+	sourceFactories = make(map[string]SourceFactory)
+)
+
+// RegisterSourceは発掘元を名前nameで登録します。発掘元を実装したファイルのinitで呼び出します。
+// 同じ名前を2回登録した場合はpanicします。
+func RegisterSource(name string, factory SourceFactory) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if _, dup := sourceFactories[name]; dup {
+		panic("discovery: source registered twice: " + name)
+	}
+	sourceFactories[name] = factory
+}
+
+// SourceNamesは登録されている発掘元の名前を名前順に返します。
+func SourceNames() []string {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	names := make([]string, 0, len(sourceFactories))
+	for name := range sourceFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultSources はOptions.Sourcesでエンティティの種別に発掘元を指定しない場合に使う発掘元です。
+var DefaultSources = []string{TabelogSource}
+
+// newSourcesはエンティティの種別ごとに使う発掘元を作成します。key: エンティティの種別。""は指定のない種別に使う発掘元です。
+// 登録されていない発掘元が指定されている場合はエラーを返します。
+func newSources(opts Options) (map[string][]Source, error) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	created := make(map[string]Source)
+	build := func(names []string) ([]Source, error) {
+		var sources []Source
+		for _, name := range names {
+			if s, ok := created[name]; ok {
+				sources = append(sources, s)
+				continue
+			}
+			factory, ok := sourceFactories[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown discovery source %q (available: %s)", name, strings.Join(sortedKeys(sourceFactories), ", "))
+			}
+			created[name] = factory(opts)
+			sources = append(sources, created[name])
+		}
+		return sources, nil
+	}
+
+	byType := make(map[string][]Source)
+	defaults, err := build(DefaultSources)
+	if err != nil {
+		return nil, err
+	}
+	byType[""] = defaults
+	for entityType, names := range opts.Sources {
+		sources, err := build(names)
+		if err != nil {
+			return nil, fmt.Errorf("sources for %s: %w", entityType, err)
+		}
+		byType[entityType] = sources
+	}
+	return byType, nil
+}
+
+func sortedKeys(m map[string]SourceFactory) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type sourceLoggerKey struct{}
+
+// withSourceLoggerは発掘元がログを出力するロガーを持つctxを返します。
+func withSourceLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, sourceLoggerKey{}, logger)
+}
+
+// sourceLoggerはctxのロガーを返します。ctxにない場合は既定のロガーを返します。
+func sourceLogger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(sourceLoggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// candidatesは集計した言及を店舗の候補として返します。featuredの店舗をその順に先頭に、残りの店舗をURL順に並べます。
+func (c *mentionCollector) candidates(featured []StoreCandidate) []StoreCandidate {
+	candidates := make([]StoreCandidate, 0, len(c.stores))
+	isFeatured := make(map[string]bool, len(featured))
+	for _, f := range featured {
+		if isFeatured[f.URL] {
+			continue
+		}
+		isFeatured[f.URL] = true
+		candidate := StoreCandidate{Name: f.Name, URL: f.URL, Featured: true}
+		if sm, ok := c.stores[f.URL]; ok {
+			candidate.Sources = sm.Sources
+		}
+		candidates = append(candidates, candidate)
+	}
+	urls := make([]string, 0, len(c.stores))
+	for u := range c.stores {
+		if !isFeatured[u] {
+			urls = append(urls, u)
+		}
+	}
+	sort.Strings(urls)
+	for _, u := range urls {
+		sm := c.stores[u]
+		candidates = append(candidates, StoreCandidate{Name: sm.Name, URL: u, Sources: sm.Sources})
+	}
+	return candidates
+}
+
+// mergeCandidatesは発掘元ごとの店舗の候補を言及の集計にまとめ、GPTに渡す店舗を候補の順に最大maxFeaturedStores件返します。
+// 複数の発掘元が同じ店舗を返した場合、言及元ページは合わせて数えます。
+func mergeCandidates(logger *slog.Logger, candidates []StoreCandidate) ([]StoreCandidate, *mentionCollector) {
+	mentions := newMentionCollector(logger)
+	var featured []StoreCandidate
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		for sourceURL, sourceType := range c.Sources {
+			mentions.add(c.URL, c.Name, sourceURL, sourceType)
+		}
+		if c.Featured && !seen[c.URL] && len(featured) < maxFeaturedStores {
+			seen[c.URL] = true
+			featured = append(featured, c)
+		}
+	}
+	return featured, mentions
+}
+
+// gptTitlesはスコアリングでGPTに渡す店舗名の一覧と、トレンドの重複の判定に使う店舗の組み合わせを返します。
+func gptTitles(featured []StoreCandidate) (combinedTitles, topTitle string) {
+	names := make([]string, len(featured))
+	for i, f := range featured {
+		names[i] = f.Name
+		combinedTitles += f.Name + "; "
+	}
+	return combinedTitles, strings.Join(names, "; ")
+}
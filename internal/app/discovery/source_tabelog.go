@@ -0,0 +1,36 @@
+package discovery
+
+import (
+	"context"
+
+	"excavation_service/internal/app/model"
+)
+
+// TabelogSource はBrave Searchで食べログのページを検索し、まとめ記事・リストページ・店舗ページから店舗を見つける発掘元です。
+// 食べログ外の検索結果(ニュース・SNS)は見つけた店舗への言及としてのみ数えます。
+const TabelogSource = "tabelog"
+
+func init() {
+	RegisterSource(TabelogSource, func(opts Options) Source {
+		return &tabelogSource{apiKey: opts.BraveAPIKey}
+	})
+}
+
+type tabelogSource struct {
+	apiKey string
+}
+
+func (s *tabelogSource) Name() string {
+	return TabelogSource
+}
+
+func (s *tabelogSource) Discover(ctx context.Context, topic model.EntityTopic) ([]StoreCandidate, error) {
+	logger := sourceLogger(ctx)
+	// fetchBraveResults関数内で「食べログ」を付加します。
+	results, err := fetchBraveResults(ctx, logger, s.apiKey, topic.Topic)
+	if err != nil {
+		return nil, err
+	}
+	featured, mentions := collectStores(ctx, logger, results)
+	return mentions.candidates(featured), nil
+}
@@ -0,0 +1,45 @@
+package discovery
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"excavation_service/internal/app/model"
+)
+
+func TestMergeCandidates(t *testing.T) {
+	tabelog := []StoreCandidate{
+		{Name: "店A", URL: "https://tabelog.com/a", Featured: true, Sources: map[string]string{"https://tabelog.com/matome/1": model.SourceTypeMatome}},
+		{Name: "店B", URL: "https://tabelog.com/b", Featured: true, Sources: map[string]string{"https://tabelog.com/matome/1": model.SourceTypeMatome}},
+		{Name: "店C", URL: "https://tabelog.com/c", Sources: map[string]string{"https://news.example.com/1": model.SourceTypeNews}},
+	}
+	other := []StoreCandidate{
+		{Name: "店A", URL: "https://tabelog.com/a", Featured: true, Sources: map[string]string{"https://example.com/onsen": model.SourceTypeSearch}},
+		{Name: "店D", URL: "https://tabelog.com/d", Featured: true},
+		{Name: "店E", URL: "https://tabelog.com/e", Featured: true},
+	}
+	featured, mentions := mergeCandidates(slog.Default(), append(tabelog, other...))
+
+	combined, top := gptTitles(featured)
+	if top != "店A; 店B; 店D" || combined != "店A; 店B; 店D; " {
+		t.Errorf("gptTitles() = %q, %q, want the first %d featured stores", combined, top, maxFeaturedStores)
+	}
+	if n := mentions.total(); n != 4 {
+		t.Errorf("mentions = %d, want 4 (店Aの言及元は発掘元をまたいで数える)", n)
+	}
+}
+
+func TestNewSourcesUnknown(t *testing.T) {
+	_, err := newSources(Options{Sources: map[string][]string{model.EntityTypeOnsen: {"no-such-source"}}})
+	if err == nil || !strings.Contains(err.Error(), "no-such-source") {
+		t.Errorf("err = %v, want unknown source error", err)
+	}
+	sources, err := newSources(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources[""]) != 1 || sources[""][0].Name() != TabelogSource {
+		t.Errorf("default sources = %v, want %s", sources[""], TabelogSource)
+	}
+}
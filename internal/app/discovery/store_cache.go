@@ -0,0 +1,58 @@
+package discovery
+
+import (
+	"container/list"
+	"sync"
+)
+
+// storeCacheSize は1回の実行でキャッシュする店舗の詳細情報の数です。
+const storeCacheSize = 1000
+
+// storeCache は解析済みの店舗の詳細情報を店舗ID(storeKey)ごとに保持するLRUキャッシュです。
+// 同じ店舗のページを実行中に何度も取得・解析しないために使います。複数のgoroutineから使えます。
+type storeCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // 先頭ほど最近使った店舗。要素は*storeCacheEntry
+	entries map[string]*list.Element
+}
+
+type storeCacheEntry struct {
+	key  string
+	done chan struct{} // 解析が終わると閉じる
+	data *StoreData    // 除外対象の店舗の場合はnil
+}
+
+func newStoreCache(size int) *storeCache {
+	return &storeCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// getOrLoadはkeyの店舗の詳細情報を返します。キャッシュにない場合はloadで取得してキャッシュします。
+// 同じkeyを同時に要求された場合も、loadは1回のみ呼び出し、他の呼び出し元はその結果を待ちます。
+func (c *storeCache) getOrLoad(key string, load func() *StoreData) *StoreData {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		entry := el.Value.(*storeCacheEntry)
+		c.mu.Unlock()
+		<-entry.done
+		return entry.data
+	}
+	entry := &storeCacheEntry{key: key, done: make(chan struct{})}
+	c.entries[key] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*storeCacheEntry).key)
+	}
+	c.mu.Unlock()
+
+	entry.data = load()
+	close(entry.done)
+	return entry.data
+}
+
+// storeDetailsはcacheを使って店舗ページから店舗の詳細情報を収集します。除外対象の店舗の場合はnilを返します。
+func storeDetails(cache *storeCache, storeName, urlStr string) *StoreData {
+	return cache.getOrLoad(storeKey(urlStr), func() *StoreData { return collectStoreInfo(storeName, urlStr) })
+}
@@ -0,0 +1,64 @@
+package discovery
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStoreCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newStoreCache(2)
+	loads := map[string]int{}
+	get := func(key string) {
+		cache.getOrLoad(key, func() *StoreData {
+			loads[key]++
+			return &StoreData{Name: key}
+		})
+	}
+	get("a")
+	get("b")
+	get("a") // bよりaを最近使ったことにする
+	get("c") // bを追い出す
+	get("a")
+	get("b")
+	if loads["a"] != 1 || loads["b"] != 2 || loads["c"] != 1 {
+		t.Errorf("loads = %v, want a:1 b:2 c:1", loads)
+	}
+}
+
+func TestStoreCacheLoadsOnceConcurrently(t *testing.T) {
+	cache := newStoreCache(10)
+	var loads atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := cache.getOrLoad("tabelog:13000001", func() *StoreData {
+				loads.Add(1)
+				return nil // 除外対象の店舗もキャッシュする
+			})
+			if data != nil {
+				t.Errorf("data = %+v, want nil", data)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loaded %d times, want 1", n)
+	}
+}
+
+func TestStoreKey(t *testing.T) {
+	tests := map[string]string{
+		"https://tabelog.com/tokyo/A1301/A130101/13000001":    "tabelog:13000001",
+		"https://s.tabelog.com/tokyo/A1301/A130101/13000001/": "tabelog:13000001",
+		"https://tabelog.com/tokyo/A1301/A130101/rstLst":      "url:https://tabelog.com/tokyo/A1301/A130101/rstLst",
+		"https://example.com/gourmet/13000001":                "url:https://example.com/gourmet/13000001",
+	}
+	for u, want := range tests {
+		if got := storeKey(u); got != want {
+			t.Errorf("storeKey(%q) = %q, want %q", u, got, want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"excavation_service/internal/app/httpx"
+)
+
+const (
+	// crawlerMaxIdleConnsPerHost はホストごとに再利用のため保持する接続の数です。
+	// 1回の実行で食べログのページを数千件取得するため、既定の2では接続を使い捨てることになる
+	crawlerMaxIdleConnsPerHost = 16
+	// dnsCacheTTL は名前解決の結果を再利用する期間です。
+	dnsCacheTTL = 5 * time.Minute
+)
+
+// connMetrics はクローラーの接続の再利用と名前解決のキャッシュの状況を集計します。PPROF_ADDR設定時は/debug/varsからも参照できます。
+var connMetrics = expvar.NewMap("crawler_conns")
+
+// 外部へのリクエストに使うクライアントの設定。タイムアウトは1回の試行ごと
+var (
+	// 食べログなどのページ。リダイレクト先もクロールの対象のドメイン(DomainPolicy)に限る
+	crawlerHTTP = httpx.Options{Name: "crawler", Timeout: 30 * time.Second, Retries: 2, RetryWait: time.Second,
+		MaxBodyBytes: maxPageBytes, Limiter: crawlerLimiter{}, CheckRedirect: checkRedirect}
+	braveHTTP = httpx.Options{Name: "brave", Timeout: 15 * time.Second, Retries: 2, RetryWait: 2 * time.Second,
+		MaxBodyBytes: maxBraveResponseBytes, Limiter: crawlerLimiter{}}
+	gptHTTP = httpx.Options{Name: "gpt", Timeout: 30 * time.Second, Retries: 2, RetryWait: 2 * time.Second,
+		MaxBodyBytes: maxGPTResponseBytes}
+)
+
+// newCrawlerTransportは食べログ・Braveへのリクエストに使うTransportを返します。
+// 接続を使い回し、名前解決の結果をキャッシュし、対応するサーバーとはHTTP/2で通信します。
+// レスポンスのボディの展開はdecodeBodiesで行います。
+func newCrawlerTransport() http.RoundTripper {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	dns := newDNSCache(net.DefaultResolver.LookupHost, dnsCacheTTL)
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = 100
+	t.MaxIdleConnsPerHost = crawlerMaxIdleConnsPerHost
+	t.IdleConnTimeout = 90 * time.Second
+	t.ForceAttemptHTTP2 = true // DialContextを置き換えてもHTTP/2を使う
+	t.DisableCompression = true
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		ips, err := dns.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var errs []error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+		return nil, errors.Join(errs...)
+	}
+	return decodeBodies(countConns(t))
+}
+
+// dnsCache はホスト名の名前解決の結果をttlの間保持します。解決に失敗した結果は保持しません。
+type dnsCache struct {
+	resolve func(ctx context.Context, host string) ([]string, error)
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newDNSCache(resolve func(ctx context.Context, host string) ([]string, error), ttl time.Duration) *dnsCache {
+	return &dnsCache{resolve: resolve, ttl: ttl, now: time.Now, entries: make(map[string]dnsEntry)}
+}
+
+// lookupはhostのIPアドレスを返します。キャッシュが有効な間は名前解決をしません。
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		connMetrics.Add("dns.cache_hit", 1)
+		return entry.addrs, nil
+	}
+	connMetrics.Add("dns.cache_miss", 1)
+	addrs, err := c.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// countConnsはリクエストごとに接続を再利用したか、どのプロトコルで通信したかを記録するRoundTripperを返します。
+func countConns(base http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				if info.Reused {
+					connMetrics.Add("conns.reused", 1)
+				} else {
+					connMetrics.Add("conns.new", 1)
+				}
+			},
+		}
+		resp, err := base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+		if err == nil {
+			connMetrics.Add("responses."+resp.Proto, 1)
+		}
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func connMetricValue(key string) int64 {
+	if v, ok := connMetrics.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// logConnectionSummaryはプロセス開始からの接続の再利用率と名前解決のキャッシュのヒット率をログに出力します。
+func logConnectionSummary(logger *slog.Logger) {
+	reused, opened := connMetricValue("conns.reused"), connMetricValue("conns.new")
+	hit, miss := connMetricValue("dns.cache_hit"), connMetricValue("dns.cache_miss")
+	logger.Info("クローラーの接続の再利用状況",
+		"conns_reused", reused, "conns_new", opened, "conn_reuse_rate", rate(reused, opened),
+		"dns_cache_hit", hit, "dns_cache_miss", miss, "dns_cache_hit_rate", rate(hit, miss),
+		"http2_responses", connMetricValue("responses.HTTP/2.0"))
+}
+
+// hostLimiter はホストごとの同時リクエスト数を制限します。
+// トピックを並行して発掘しても、1つのホストにリクエストが集中しないようにするために使います。
+type hostLimiter struct {
+	limit int
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{limit: limit, hosts: make(map[string]chan struct{})}
+}
+
+// acquireはhostへのリクエストの枠が空くまで待って確保し、枠を返す関数を返します。
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	l.mu.Lock()
+	sem, ok := l.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, l.limit)
+		l.hosts[host] = sem
+	}
+	l.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type hostLimiterKey struct{}
+
+// withHostLimiterはクローラーのリクエストをlimiterで制限するctxを返します。
+func withHostLimiter(ctx context.Context, limiter *hostLimiter) context.Context {
+	return context.WithValue(ctx, hostLimiterKey{}, limiter)
+}
+
+// crawlerLimiter はリクエストのctxのhostLimiterでホストごとの同時リクエスト数を制限するhttpx.Limiterです。
+// ctxにhostLimiterがなければ制限しません。枠はレスポンスのボディを閉じるまで確保します。
+type crawlerLimiter struct{}
+
+func (crawlerLimiter) Acquire(ctx context.Context, req *http.Request) (func(), error) {
+	limiter, ok := ctx.Value(hostLimiterKey{}).(*hostLimiter)
+	if !ok {
+		return func() {}, nil
+	}
+	return limiter.acquire(ctx, req.URL.Host)
+}
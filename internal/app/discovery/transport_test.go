@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDNSCache(t *testing.T) {
+	lookups := 0
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	cache := newDNSCache(func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		return []string{"192.0.2.1"}, nil
+	}, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.lookup(context.Background(), "tabelog.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("lookups = %d within ttl, want 1", lookups)
+	}
+	now = now.Add(time.Minute)
+	if _, err := cache.lookup(context.Background(), "tabelog.com"); err != nil {
+		t.Fatal(err)
+	}
+	if lookups != 2 {
+		t.Errorf("lookups = %d after ttl, want 2", lookups)
+	}
+}
+
+func TestCrawlerTransportReusesConnections(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: newCrawlerTransport()}
+	reused, opened := connMetricValue("conns.reused"), connMetricValue("conns.new")
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if n := connMetricValue("conns.new") - opened; n != 1 {
+		t.Errorf("new conns = %d, want 1", n)
+	}
+	if n := connMetricValue("conns.reused") - reused; n != 2 {
+		t.Errorf("reused conns = %d, want 2", n)
+	}
+}
+
+func TestHostLimiter(t *testing.T) {
+	limiter := newHostLimiter(1)
+	release, err := limiter.acquire(context.Background(), "tabelog.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 別のホストは制限を受けない
+	other, err := limiter.acquire(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(ctx, "tabelog.com"); err == nil {
+		t.Error("acquired a second slot for the same host, want to wait")
+	}
+	release()
+	if _, err := limiter.acquire(context.Background(), "tabelog.com"); err != nil {
+		t.Errorf("acquire after release: %v", err)
+	}
+}
@@ -0,0 +1,961 @@
+// Package discovery は検索結果から話題の店舗を発掘し、トピックの週ごとのトレンドとして記録します。
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/analytics"
+	"excavation_service/internal/app/apperr"
+	"excavation_service/internal/app/clock"
+	"excavation_service/internal/app/dedup"
+	"excavation_service/internal/app/httpx"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/objectstore"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/search"
+	"excavation_service/internal/app/scraperules"
+	"excavation_service/internal/app/tracing"
+	"excavation_service/internal/app/week"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// scrapeRulesはページ解析のセレクタや除外パターンです。SCRAPE_RULES_FILEを設定すると実行中でもファイルの変更が反映されます。
+var scrapeRules = scraperules.NewStore()
+
+// crawlerTransport は食べログのページとBraveの検索APIへのリクエストで共有するTransportです。
+// 1回の実行で数千件のページを取得するため、接続の再利用と名前解決のキャッシュを行います。
+var crawlerTransport = newCrawlerTransport()
+
+// 食べログのページ・Braveの検索API・GPTへのリクエストに使うクライアントです。設定はcrawlerHTTP・braveHTTP・gptHTTPです。
+var (
+	httpClient  = httpx.New(crawlerTransport, crawlerHTTP)
+	braveClient = httpx.New(crawlerTransport, braveHTTP)
+	gptClient   = httpx.New(nil, gptHTTP)
+)
+
+// SetTransportは食べログ・Brave・GPTへのリクエストに使うTransportを置き換えます。
+// オフラインモードでフィクスチャを返すサーバーにリクエストを振り向けるために使います。
+// 再試行などのクライアントの設定は変わらず、圧縮されたレスポンスはrtに関わらず展開します。
+func SetTransport(rt http.RoundTripper) {
+	httpClient.Transport = httpx.Wrap(decodeBodies(rt), crawlerHTTP)
+	braveClient.Transport = httpx.Wrap(decodeBodies(rt), braveHTTP)
+	gptClient.Transport = httpx.Wrap(rt, gptHTTP)
+}
+
+// fetchPageはurlStrのページを取得します。ボディはmaxPageBytesまでしか読めません。
+// クロールの対象外のドメイン(DomainPolicy)のページはリクエストを送らずにエラーを返します。
+func fetchPage(ctx context.Context, urlStr string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDomain(ctx, req.URL); err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err == nil {
+		// 進捗の表示に使う
+		extractionMetrics.Add("pages_fetched", 1)
+	}
+	return resp, err
+}
+
+// isStorePageはURLが食べログの店舗ページであるかを判定します。
+// 英語ページ、リストページ、まとめページ、レビューページなどは店舗ページとはみなしません。
+func isStorePage(u *url.URL) (ok bool) {
+	defer func() { recordStorePage(ok) }()
+
+	host := u.Host
+	path := u.Path
+
+	// Tabelog のみ対象
+	if !strings.Contains(host, "tabelog.com") {
+		slog.Debug("isStorePage: Tabelog以外", "url", u.String())
+		return false
+	}
+
+	// 英語ページは除外
+	if strings.Contains(path, "/en/") {
+		slog.Debug("isStorePage: 英語ページ除外", "url", u.String())
+		return false
+	}
+
+	// 除外すべきパスパターン（店舗情報ではないページ）と店舗ページのURLのパターンは解析ルールで管理する
+	rules := scrapeRules.Current()
+	if pattern := rules.ExcludedPath(path); pattern != "" {
+		slog.Debug("isStorePage: 除外パターンに一致", "pattern", pattern, "url", u.String())
+		return false
+	}
+
+	if rules.MatchStorePage(u.String()) {
+		slog.Debug("isStorePage: 店舗ページとして判定", "url", u.String())
+		return true
+	}
+
+	slog.Debug("isStorePage: 店舗ページではない (全てのチェックに失敗)", "url", u.String())
+	return false
+}
+
+// extractStoreNameはタイトル文字列から店舗名を抽出・整形します。
+func extractStoreName(title string) (name string) {
+	defer func() { recordStoreName(name) }()
+
+	originalTitle := title
+	slog.Debug("extractStoreName: 抽出開始", "title", originalTitle)
+
+	// セパレータによる分割
+	if strings.Contains(title, "|") {
+		title = strings.Split(title, "|")[0]
+	}
+	if strings.Contains(title, "-") {
+		title = strings.Split(title, "-")[0]
+	}
+	if strings.Contains(title, "/") {
+		title = strings.Split(title, "/")[0]
+	}
+
+	// ユーザー名や特定のワードを除去
+	title = regexp.MustCompile(`\s*(?:さん|氏|様|ちゃん|君)\s*`).ReplaceAllString(title, "")
+	title = regexp.MustCompile(`（名無し）`).ReplaceAllString(title, "")
+	title = regexp.MustCompile(`\s*\([^\)]*?\)`).ReplaceAllString(title, "") // 括弧と中身を除去
+	title = regexp.MustCompile(`\s*\[[^\]]*?\]`).ReplaceAllString(title, "") // 角括弧と中身を除去
+	title = regexp.MustCompile(`【[^】]*?】`).ReplaceAllString(title, "")     // 黒い角括弧と中身を除去
+	title = regexp.MustCompile(`《[^》]*?》`).ReplaceAllString(title, "")     // 二重山括弧と中身を除去
+
+	for _, user := range scrapeRules.Current().ExcludedNames {
+		title = strings.ReplaceAll(title, user, "")
+	}
+
+	// 一般的なレビュアー名やブログ、まとめ記事関連のワード
+	title = regexp.MustCompile(`\b[a-zA-Z0-9_]{3,15}\b`).ReplaceAllString(title, "") // 半角英数字のユーザー名など
+	title = regexp.MustCompile(`\b(?:イコット|クチコミ|食べログ)\b`).ReplaceAllString(title, "")
+	title = regexp.MustCompile(`人気店\d*選`).ReplaceAllString(title, "")
+	title = regexp.MustCompile(`おすすめランチ`).ReplaceAllString(title, "")
+	title = regexp.MustCompile(`の[お店|グルメ|ランチ|名店|人気店]`).ReplaceAllString(title, "")
+	title = regexp.MustCompile(`[\d]+選`).ReplaceAllString(title, "")
+	title = regexp.MustCompile(`【最新版】`).ReplaceAllString(title, "")
+	title = regexp.MustCompile(`～[^～]*～`).ReplaceAllString(title, "") // 全角チルダで囲まれた部分
+	title = regexp.MustCompile(`食べログまとめ`).ReplaceAllString(title, "")
+
+	// その他のクリーンアップ
+	title = strings.ReplaceAll(title, "...", "")
+	title = strings.ReplaceAll(title, "　", " ") // 全角スペースを半角に
+	title = strings.ReplaceAll(title, "｜", "")
+	title = strings.ReplaceAll(title, "ー", "") // 長音記号
+	title = strings.ReplaceAll(title, "～", "")
+	title = strings.ReplaceAll(title, "『", "")
+	title = strings.ReplaceAll(title, "』", "")
+	title = strings.ReplaceAll(title, "「", "")
+	title = strings.ReplaceAll(title, "」", "")
+	title = strings.ReplaceAll(title, `"`, "")
+
+	// 複数スペースを1つにまとめ、前後のスペースを除去
+	title = strings.TrimSpace(regexp.MustCompile(`\s+`).ReplaceAllString(title, " "))
+
+	// 短すぎる、または日本語・英数字が全く含まれないタイトルは無効と判断
+	// \p{Han}: 漢字, \p{Hiragana}: ひらがな, \p{Katakana}: カタカナ
+	if len(title) < 2 || !regexp.MustCompile(`[a-zA-Z0-9\p{Han}\p{Hiragana}\p{Katakana}]`).MatchString(title) {
+		slog.Debug("extractStoreName: 短すぎる、または有効な文字を含まない店舗名のため除外", "title", originalTitle, "result", title)
+		return ""
+	}
+	slog.Debug("extractStoreName: 抽出完了", "title", originalTitle, "result", title)
+	return title
+}
+
+// fetchStoreLinksFromMatomeは食べログのまとめ記事から店舗のリンクとタイトルを抽出します。
+// 返り値は、キーが正規化されたURL、値が店舗名のmapです。
+// ページ内で見つかった店舗はすべてmentionsにまとめ記事からの言及として記録します。
+func fetchStoreLinksFromMatome(ctx context.Context, logger *slog.Logger, urlStr string, seen dedup.Set, mentions *mentionCollector) map[string]string {
+	storeLinks := make(map[string]string)
+	logger.Debug("まとめ記事を取得中", "url", urlStr)
+	resp, err := fetchPage(ctx, urlStr)
+	if err != nil {
+		logger.Error("まとめ記事取得失敗", "url", urlStr, "error", err)
+		return storeLinks
+	}
+	defer resp.Body.Close()
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		logger.Error("まとめ記事解析失敗", "url", urlStr, "error", err)
+		return storeLinks
+	}
+
+	rules := scrapeRules.Current()
+	if skipByLanguage(logger, rules, pageTypeMatome, urlStr, doc) {
+		return storeLinks
+	}
+
+	baseURL, _ := url.Parse(urlStr)
+
+	links := doc.Find(rules.MatomeSelector)
+	recordPage(pageTypeMatome, links.Length())
+	links.Each(func(i int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		if !exists {
+			return
+		}
+		parsed, err := url.Parse(href)
+		if err != nil {
+			logger.Debug("まとめ記事: href解析失敗", "href", href, "error", err)
+			return
+		}
+		resolved := canonicalURL(baseURL.ResolveReference(parsed))
+
+		// 食べログの店舗ページのみを対象とする (isStorePageで厳格に判定)
+		if isStorePage(resolved) {
+			// URLを正規化して重複チェック (末尾のスラッシュを削除)
+			normalizedURL := strings.TrimSuffix(resolved.String(), "/")
+
+			text := strings.TrimSpace(s.Text())
+			cleanText := extractStoreName(text)
+			if cleanText == "" {
+				logger.Debug("まとめ記事: 無効なタイトルをスキップ", "url", resolved.String(), "text", text)
+				return
+			}
+			// 既に他のページで見つかった店舗でも、このページからの言及としては記録する
+			mentions.add(normalizedURL, cleanText, urlStr, model.SourceTypeMatome)
+
+			if markSeen(ctx, logger, seen, normalizedURL) { // 既に処理した店舗でなければ記録
+				storeLinks[normalizedURL] = cleanText // key: Normalized URL, value: Name
+				logger.Debug("まとめ記事: 店舗発見", "store", cleanText, "url", resolved.String())
+			} else {
+				logger.Debug("まとめ記事: 重複URLのためスキップ", "url", normalizedURL)
+			}
+		}
+	})
+	return storeLinks
+}
+
+// fetchLinksFromListingPageは食べログのリストページから店舗のリンクとタイトルを抽出します。
+// 返り値は、キーが正規化されたURL、値が店舗名のmapです。
+// ページ内で見つかった店舗はすべてmentionsにリストページからの言及として記録します。
+func fetchLinksFromListingPage(ctx context.Context, logger *slog.Logger, urlStr string, seen dedup.Set, mentions *mentionCollector) map[string]string {
+	storeLinks := make(map[string]string)
+	logger.Debug("リストページを取得中", "url", urlStr)
+	resp, err := fetchPage(ctx, urlStr)
+	if err != nil {
+		logger.Error("リストページ取得失敗", "url", urlStr, "error", err)
+		return storeLinks
+	}
+	defer resp.Body.Close()
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		logger.Error("リストページ解析失敗", "url", urlStr, "error", err)
+		return storeLinks
+	}
+
+	rules := scrapeRules.Current()
+	if skipByLanguage(logger, rules, pageTypeListing, urlStr, doc) {
+		return storeLinks
+	}
+
+	baseURL, _ := url.Parse(urlStr)
+
+	links := doc.Find(rules.ListingSelector)
+	recordPage(pageTypeListing, links.Length())
+	links.Each(func(i int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		if !exists {
+			return
+		}
+		parsed, err := url.Parse(href)
+		if err != nil {
+			logger.Debug("リストページ: href解析失敗", "href", href, "error", err)
+			return
+		}
+		resolved := canonicalURL(baseURL.ResolveReference(parsed))
+
+		// 食べログの店舗ページのみを対象とする (isStorePageで厳格に判定)
+		if isStorePage(resolved) {
+			// URLを正規化して重複チェック (末尾のスラッシュを削除)
+			normalizedURL := strings.TrimSuffix(resolved.String(), "/")
+
+			text := strings.TrimSpace(s.Text())
+			cleanText := extractStoreName(text)
+			if cleanText == "" {
+				logger.Debug("リストページ: 無効なタイトルをスキップ", "url", resolved.String(), "text", text)
+				return
+			}
+			// 既に他のページで見つかった店舗でも、このページからの言及としては記録する
+			mentions.add(normalizedURL, cleanText, urlStr, model.SourceTypeListing)
+
+			if markSeen(ctx, logger, seen, normalizedURL) { // 既に処理した店舗でなければ記録
+				storeLinks[normalizedURL] = cleanText // key: Normalized URL, value: Name
+				logger.Debug("リストページ: 店舗発見", "store", cleanText, "url", resolved.String())
+			} else {
+				logger.Debug("リストページ: 重複URLのためスキップ", "url", normalizedURL)
+			}
+		}
+	})
+	return storeLinks
+}
+
+// StoreData は店舗の情報を保持する構造体です。保存する前にvalidateStoreで検証します。
+type StoreData struct {
+	Name         string
+	URL          string
+	BudgetLunch  string
+	BudgetDinner string
+	Genre        string
+	IsChain      bool
+	Address      string
+	Rating       *float64 // 食べログの評価 (0〜5)。取得できなければnil
+}
+
+// collectStoreInfoは個別の店舗ページから店舗名、予算、ジャンルなどの情報を収集します。
+// チェーン店や安価な店舗の除外はここで行うべきですが、現在の実装ではHTML解析による情報取得とフィルタリングは未実装です。
+// この関数は現在、storeNameとURLを受け取ってStoreData構造体を返すダミー実装です。
+func collectStoreInfo(storeName, urlStr string) *StoreData {
+	slog.Debug("collectStoreInfo: 収集開始", "store", storeName, "url", urlStr)
+
+	// TODO: ここにrequests.Get(url)とgoqueryを使ったHTML解析を追加し、
+	// 予算、ジャンル、チェーン店かの情報を取得するロジックを実装する必要があります。
+	// 例:
+	// resp, err := http.Get(urlStr)
+	// if err != nil { /* エラーハンドリング */ return nil }
+	// defer resp.Body.Close()
+	// doc, err := goquery.NewDocumentFromReader(resp.Body)
+	// // doc.Find(".rdheader-subinfo__item--genre").Text() などで情報を抽出
+
+	// 仮のデータ構造
+	storeData := &StoreData{
+		Name:         storeName,
+		URL:          urlStr,
+		BudgetLunch:  "不明",  // 仮のデータ
+		BudgetDinner: "不明", // 仮のデータ
+		Genre:        "不明", // 仮のデータ
+		IsChain:      false,  // 仮のデータ
+	}
+
+	// TODO: 以下にチェーン店や安価な店舗を除外するロジックを追加（HTML解析で値が取得できた場合）
+	// if storeData.IsChain {
+	//     slog.Info("collectStoreInfo: チェーン店のため除外", "store", storeData.Name)
+	//     return nil
+	// }
+	// if storeData.BudgetLunch != "不明" { // 例: 昼予算が特定の金額未満の場合
+	//     // budget_lunchを数値に変換する処理が必要
+	//     // if parsedBudget < 1000 { ... }
+	//     slog.Info("collectStoreInfo: 安価な店舗（昼予算）のため除外", "store", storeData.Name)
+	//     return nil
+	// }
+
+	slog.Debug("collectStoreInfo: 店舗情報を収集しました", "store", storeName)
+	return storeData
+}
+
+// SearchBrave はBrave Search APIを使用して、指定されたクエリで検索し、関連する店舗のタイトルとURLを返します。
+// あわせて、検索結果から辿ったページごとの店舗への言及を集計して返します。検索に失敗した場合はfetchBraveResultsの誤りを返します。
+func SearchBrave(ctx context.Context, logger *slog.Logger, apiKey, query string) (string, string, *mentionCollector, error) {
+	results, err := fetchBraveResults(ctx, logger, apiKey, query)
+	if err != nil {
+		return "", "", nil, err
+	}
+	featured, mentions := collectStores(ctx, logger, results)
+	combinedTitles, topTitle := gptTitles(featured)
+	return combinedTitles, topTitle, mentions, nil
+}
+
+// braveOp はBrave Search APIでの検索の誤りとメトリクスに使う処理の名前です。
+const braveOp = "brave search"
+
+// fetchBraveResultsはBrave Search APIで検索し、検索結果を返します。
+// 失敗した場合はapperrで分類した誤りを返し、検索結果が0件の場合はapperr.ErrNoResultsを返します。
+func fetchBraveResults(ctx context.Context, logger *slog.Logger, apiKey, query string) ([]interface{}, error) {
+	// 検索クエリを調整: queryが既に「食べログ」を含んでいる場合、重複して追加しない
+	adjustedQuery := query
+	if !strings.Contains(strings.ToLower(query), "食べログ") {
+		adjustedQuery = adjustedQuery + " 食べログ"
+	}
+
+	encodedQuery := url.QueryEscape(adjustedQuery)
+	// count=20 を追加して、より多くの検索結果を取得
+	apiURL := "https://api.brave.com/res/v1/web/search?q=" + encodedQuery + "&count=20"
+	logger.Debug("Brave検索", "query", adjustedQuery, "url", apiURL)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create brave request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Subscription-Token", apiKey)
+
+	resp, err := braveClient.Do(req)
+	if err != nil {
+		return nil, apperr.Upstream(braveOp, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		recordRawResponse(ctx, adjustedQuery, apiURL, resp.StatusCode, body)
+		return nil, apperr.Wrap(apperr.FromStatus(resp.StatusCode), braveOp, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(body)))
+	}
+
+	// ボディは読みながら解析し、生のレスポンスを記録する場合のみ保持する
+	var body io.Reader = resp.Body
+	var raw *bytes.Buffer
+	if rawRecording(ctx) {
+		raw = new(bytes.Buffer)
+		body = io.TeeReader(body, raw)
+	}
+	var data map[string]interface{}
+	err = json.NewDecoder(body).Decode(&data)
+	if raw != nil {
+		io.Copy(io.Discard, body) // 解析に使わなかった末尾も記録する
+		recordRawResponse(ctx, adjustedQuery, apiURL, resp.StatusCode, raw.Bytes())
+	}
+	if err != nil {
+		return nil, apperr.Wrap(apperr.ErrParseFailed, braveOp, err)
+	}
+
+	// 検索結果がない場合、Braveはwebセクションやresultsを返さない
+	webResults, ok := data["web"].(map[string]interface{})
+	if !ok {
+		return nil, apperr.Wrap(apperr.ErrNoResults, braveOp, fmt.Errorf("query %q", adjustedQuery))
+	}
+	resultsRaw, ok := webResults["results"]
+	if !ok {
+		return nil, apperr.Wrap(apperr.ErrNoResults, braveOp, fmt.Errorf("query %q", adjustedQuery))
+	}
+
+	results, ok := resultsRaw.([]interface{})
+	if !ok {
+		return nil, apperr.Wrap(apperr.ErrParseFailed, braveOp, fmt.Errorf("results is %T", resultsRaw))
+	}
+	if len(results) == 0 {
+		return nil, apperr.Wrap(apperr.ErrNoResults, braveOp, fmt.Errorf("query %q", adjustedQuery))
+	}
+	return results, nil
+}
+
+// collectStoresはBraveの検索結果から、GPTに渡す店舗(最大maxFeaturedStores件)を見つけた順に返します。
+// 食べログのまとめ記事やリストページは取得して店舗を抽出し、辿ったページごとの店舗への言及を集計して返します。
+func collectStores(ctx context.Context, logger *slog.Logger, results []interface{}) ([]StoreCandidate, *mentionCollector) {
+	mentions := newMentionCollector(logger)
+	if results == nil {
+		return nil, mentions
+	}
+	recordPage(pageTypeSearch, len(results))
+
+	var featured []StoreCandidate // GPTに渡す店舗
+	seen := dedupSet(ctx) // 処理済みの店舗とページを管理 (食べログの店舗は店舗ID、それ以外は正規化されたURLをキーとする)
+	collectedCount := 0
+	maxTitles := maxFeaturedStores
+
+	// 処理する検索結果とたどるページの数はトピックごとのクロールの予算(Budget)で制限する
+	budget := budgetOf(ctx)
+	if budget == nil {
+		budget = newTopicBudget(Budget{})
+	}
+
+	// 食べログ外のページ。店舗が出揃った後に店舗名の言及を探す
+	type textSource struct {
+		URL  string
+		Type string
+		Text string
+	}
+	var textSources []textSource
+
+	// GPTに渡す店舗がmaxTitlesに達した後も、言及数の集計のため残りの結果を処理する
+	for i, item := range results {
+		if ctx.Err() != nil {
+			// fetchの時間の予算を過ぎたか中断された。それまでに見つけた店舗を返す
+			logger.Info("検索結果の処理を打ち切りました", "processed", i, "results", len(results), "reason", ctx.Err())
+			break
+		}
+		if !budget.take(BudgetSearchResults) {
+			logger.Info("検索結果の予算を使い切りました", "budget", BudgetSearchResults, "processed", i, "results", len(results))
+			break
+		}
+
+		r, ok := item.(map[string]interface{})
+		if !ok {
+			logger.Debug("検索結果: 不正な形式の結果をスキップ", "item", item)
+			continue
+		}
+		title, titleOk := r["title"].(string)
+		urlStr, urlOk := r["url"].(string)
+
+		if !titleOk || !urlOk {
+			logger.Debug("検索結果: タイトルかURLがない結果をスキップ", "item", r)
+			continue
+		}
+		logger.Debug("検索結果を処理中", "index", i, "url", urlStr, "title", title)
+
+		parsedURL, err := url.Parse(urlStr)
+		if err != nil {
+			logger.Debug("検索結果: URL解析失敗", "url", urlStr, "error", err)
+			continue
+		}
+		// 計測用のパラメータやスマートフォン版のURLで同じページを何度も取得しないよう、正規化したURLを使う
+		parsedURL = canonicalURL(parsedURL)
+		urlStr = parsedURL.String()
+
+		// 末尾のスラッシュを削除して重複チェックに使用
+		normalizedURL := strings.TrimSuffix(urlStr, "/")
+
+		if seenBefore(ctx, logger, seen, normalizedURL) {
+			logger.Debug("検索結果: 重複URLのためスキップ", "url", normalizedURL)
+			continue
+		}
+
+		// 食べログ以外のURLは店舗の抽出対象外。ニュース・SNSからの言及としてのみ扱う
+		if !strings.Contains(parsedURL.Host, "tabelog.com") {
+			description, _ := r["description"].(string)
+			sourceType := classifySource(parsedURL)
+			textSources = append(textSources, textSource{URL: normalizedURL, Type: sourceType, Text: title + " " + description})
+			markSeen(ctx, logger, seen, normalizedURL)
+			logger.Debug("検索結果: 食べログ外のページを言及の照合用に保持", "source_type", sourceType, "url", urlStr)
+			continue
+		}
+
+		// 食べログの「まとめ記事」の場合
+		if strings.Contains(parsedURL.Path, "/matome/") {
+			logger.Debug("検索結果: 食べログまとめ記事", "url", urlStr)
+			if !budget.take(BudgetMatomePages) {
+				logger.Debug("検索結果: まとめ記事の予算を使い切ったためスキップ", "budget", BudgetMatomePages, "url", urlStr)
+				continue
+			}
+			markSeen(ctx, logger, seen, normalizedURL)
+			// `seen` を `fetchStoreLinksFromMatome` に渡して、その中で重複を管理
+			storeTitlesFromMatome := fetchStoreLinksFromMatome(ctx, logger, urlStr, seen, mentions)
+			for storeURL, storeTitle := range storeTitlesFromMatome {
+				// ここではもう`seen`で重複チェック済み
+				if collectedCount < maxTitles {
+					featured = append(featured, StoreCandidate{Name: storeTitle, URL: storeURL})
+					collectedCount++
+					logger.Debug("まとめ記事の店舗を追加", "store", storeTitle)
+				}
+			}
+		} else if strings.Contains(parsedURL.Path, "/rstLst/") { // 食べログのリストページ
+			logger.Debug("検索結果: 食べログリストページ", "url", urlStr)
+			if !budget.take(BudgetListingPages) {
+				logger.Debug("検索結果: リストページの予算を使い切ったためスキップ", "budget", BudgetListingPages, "url", urlStr)
+				continue
+			}
+			markSeen(ctx, logger, seen, normalizedURL)
+			// `seen` を `fetchLinksFromListingPage` に渡して、その中で重複を管理
+			storesFromListing := fetchLinksFromListingPage(ctx, logger, urlStr, seen, mentions)
+			for storeURL, storeTitle := range storesFromListing {
+				// ここではもう`seen`で重複チェック済み
+				if collectedCount < maxTitles {
+					featured = append(featured, StoreCandidate{Name: storeTitle, URL: storeURL})
+					collectedCount++
+					logger.Debug("リストページの店舗を追加", "store", storeTitle)
+				}
+			}
+		} else if isStorePage(parsedURL) { // 食べログの直接の店舗ページ
+			logger.Debug("検索結果: 食べログ店舗ページ", "url", urlStr)
+			cleanTitle := extractStoreName(title)
+			if cleanTitle != "" {
+				mentions.add(normalizedURL, cleanTitle, normalizedURL, model.SourceTypeSearch)
+			}
+			if cleanTitle != "" && collectedCount < maxTitles {
+				featured = append(featured, StoreCandidate{Name: cleanTitle, URL: normalizedURL})
+				markSeen(ctx, logger, seen, normalizedURL) // 直接の店舗ページも処理済みとして記録
+				collectedCount++
+				logger.Debug("店舗ページの店舗を追加", "store", cleanTitle)
+			} else {
+				if cleanTitle == "" {
+					logger.Debug("検索結果: 店舗名を抽出できないためスキップ", "url", urlStr, "title", title)
+				}
+			}
+		} else {
+			logger.Debug("検索結果: 対象外の食べログページをスキップ", "url", urlStr)
+		}
+	}
+
+	for _, ts := range textSources {
+		mentions.matchText(ts.URL, ts.Type, ts.Text)
+	}
+
+	if len(featured) == 0 {
+		logger.Info("検索結果から有効な店舗名を収集できませんでした")
+		return nil, mentions
+	}
+
+	_, topTitle := gptTitles(featured)
+	logger.Info("検索結果から店舗を収集しました", "stores", len(featured), "top_title", topTitle)
+	return featured, mentions
+}
+
+// NewRunIDはバッチ実行を識別するためのランダムなIDを生成します。
+func NewRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Options はトレンド発掘の1回の実行の設定です。
+type Options struct {
+	RunID        string
+	TopicIDs     []uint // 発掘するトピック。空の場合は有効なすべてのトピック
+	TenantID     uint   // 0でなければこのテナントのトピックのみ発掘する
+	BraveAPIKey  string
+	OpenAIAPIKey string
+
+	// ScrapeRulesFileを設定すると、実行中でもファイルの変更が解析ルールに反映される
+	ScrapeRulesFile           string
+	ScrapeRulesReloadInterval time.Duration
+
+	Notifier notify.Notifier // ウォッチの通知先。nilの場合はログに出力する
+
+	// 実行の終了時に結果(スコア上位のトピックと新しく見つかった店舗)をRunNotifierに送る。nilの場合は送らない。
+	// 既定の通知先には全体を、RunNotifyAreasの各エリアにはトピック名にそのエリアを含むものを送る
+	RunNotifier    notify.Notifier
+	RunNotifyAreas []string
+
+	// エンティティの種別ごとに使う発掘元の名前(RegisterSourceで登録したもの)。指定のない種別はDefaultSourcesを使う
+	Sources map[string][]string
+
+	// 検索結果の店舗とページの重複排除に使うセット。実行とトピックごとにキーを分けて使う。
+	// nilの場合はトピックごとにメモリ上で重複を排除する
+	Dedup dedup.Set
+
+	StoreIndex search.StoreIndex // 実行の終了時に言及のあった店舗を登録する検索インデックス。nilの場合は登録しない
+
+	// 検索APIのレスポンスをそのまま記録し、実行の終了時にJSONLとして書き出す先。nilの場合は記録しない
+	RawArchive objectstore.Store
+
+	// パイプラインの段階(Stages)ごとに同時に処理するトピックの数。指定のない段階はConcurrencyを使い、1以下の場合は1つずつ処理する
+	StageWorkers map[string]int
+	Concurrency  int
+	// 食べログなどのホストごとの同時リクエスト数の上限。0の場合は制限しない
+	HostConcurrency int
+
+	// トピックごとのクロールの上限。ゼロ値の場合は検索結果をDefaultMaxSearchResults件まで処理し、他は制限しない。
+	// 使い切った予算はトピックの結果(JobTopic.BudgetExhausted)と実行結果の通知に記録する
+	Budget Budget
+
+	// クローラーが取得してよいページのドメイン。ゼロ値の場合はDefaultAllowedDomainsのみ取得する
+	Domains DomainPolicy
+
+	// ProgressIntervalごとに進捗の行をProgressOutputに出力し、ジョブの進捗を更新する。
+	// ProgressOutputがnilの場合はジョブの更新のみ行い、ProgressIntervalが0の場合はトピックの完了時のみ更新する
+	ProgressInterval time.Duration
+	ProgressOutput   io.Writer
+
+	// 実行の週(トレンドを記録する週)を決める時計。nilの場合はclock.Real
+	Clock clock.Clock
+}
+
+// Runはトピックの今週のトレンドを発掘して保存します。
+// 検索結果から店舗への言及を集計して保存し、GPTのスコアと言及数からトレンドのスコアを計算します。
+// 他の週に同じ店舗の組み合わせのトレンドが存在する場合や、有効な店舗が見つからなかった場合はトレンドを保存しません。
+// トレンドはトピック・週ごとに1件とし、同じ週に実行し直した場合は今週のトレンドを置き換えます。店舗と言及も重複して保存しません。
+// 実行はjobsテーブルにジョブとして記録し、進捗を更新します。一部のトピックが失敗しても残りのトピックの処理を続けます。
+func Run(ctx context.Context, logger *slog.Logger, db *gorm.DB, opts Options) error {
+	if opts.ScrapeRulesFile != "" {
+		if err := scrapeRules.Load(opts.ScrapeRulesFile); err != nil {
+			return fmt.Errorf("load scrape rules: %w", err)
+		}
+		watchCtx, stopWatch := context.WithCancel(ctx)
+		defer stopWatch()
+		go scrapeRules.Watch(watchCtx, logger, opts.ScrapeRulesFile, opts.ScrapeRulesReloadInterval)
+	}
+	// 解析結果と接続の再利用状況の集計は実行の最後に出力する。解析結果はこの実行の分だけを出す
+	defer logExtractionSummary(logger, snapshotExtraction())
+	defer logConnectionSummary(logger)
+
+	// 自動マイグレーション (必要に応じてコメント解除)
+	// db.AutoMigrate(&model.EntityTopic{}, &model.TopicTrend{}, &model.Store{}, &model.StoreMention{})
+
+	// 実行の途中で週が替わっても、トピックの優先度とトレンドは開始時の週で扱う
+	runWeek := week.Of(clock.OrReal(opts.Clock).Now())
+	topics, err := loadTopics(logger, db.WithContext(ctx), runWeek, opts.TopicIDs, opts.TenantID)
+	if err != nil {
+		return err
+	}
+	sources, err := newSources(opts)
+	if err != nil {
+		return err
+	}
+	topicIDs := make([]uint, len(topics))
+	for i, t := range topics {
+		topicIDs[i] = t.ID
+	}
+	entityTypes, err := repository.NewTopicRepository(db).WithContext(ctx).EntityTypes(topicIDs)
+	if err != nil {
+		return fmt.Errorf("load entity types: %w", err)
+	}
+
+	// 実行全体を1つのトレースにまとめ、外部へのリクエストとクエリをその子スパンとして記録する
+	ctx, span := tracing.Tracer().Start(ctx, "trend_discovery",
+		trace.WithAttributes(
+			attribute.String("run_id", opts.RunID),
+			attribute.Int("topics", len(topics)),
+		))
+	defer span.End()
+	db = db.WithContext(ctx)
+
+	// 中断された場合もジョブの終了を記録できるよう、ジョブの更新はキャンセルの影響を受けないようにする
+	jobRepo := repository.NewJobRepository(db).WithContext(context.WithoutCancel(ctx))
+	job := model.Job{
+		Kind:        model.JobKindDiscover,
+		RunID:       opts.RunID,
+		Status:      model.JobStatusRunning,
+		TopicsTotal: len(topics),
+		StartedAt:   time.Now(),
+	}
+	if err := jobRepo.Create(&job); err != nil {
+		return fmt.Errorf("create job: %w", err)
+	}
+	progress := newProgress(jobRepo, &job)
+	if opts.RawArchive != nil {
+		recorder := analytics.NewRawRecorder(job.RunID)
+		ctx = withRawRecorder(ctx, recorder)
+		// 中断された場合も、それまでのレスポンスは書き出す
+		defer flushRawResponses(context.WithoutCancel(ctx), logger, opts, recorder, job.StartedAt)
+	}
+	if opts.ProgressInterval > 0 {
+		reportCtx, stopReport := context.WithCancel(ctx)
+		defer stopReport()
+		go progress.report(reportCtx, logger, opts.ProgressOutput, opts.ProgressInterval)
+	}
+
+	storeRepo := repository.NewStoreRepository(db)
+	trendRepo := repository.NewTrendRepository(db)
+	watchRepo := repository.NewWatchRepository(db)
+
+	// ウォッチの通知は実行の最後に通知先ごとにまとめて送る
+	if opts.Notifier == nil {
+		opts.Notifier = notify.LogNotifier{}
+	}
+	digest := notify.NewDigest(opts.Notifier, "ウォッチ中のトピックのトレンド通知")
+	defer func() {
+		if err := digest.Flush(ctx); err != nil {
+			logger.Error("ウォッチ通知の送信に失敗しました", "error", err)
+		}
+	}()
+	// 通知の設定で送り方がダイジェストのものも、実行の最後に通知先ごとにまとめて送る
+	prefDigest := notify.NewDigest(opts.Notifier, "トピックのトレンド通知")
+	defer func() {
+		if err := prefDigest.Flush(ctx); err != nil {
+			logger.Error("トレンド通知の送信に失敗しました", "error", err)
+		}
+	}()
+
+	if opts.HostConcurrency > 0 {
+		ctx = withHostLimiter(ctx, newHostLimiter(opts.HostConcurrency))
+	}
+	ctx = withDomainPolicy(ctx, opts.Domains)
+
+	// トピックは段階ごとに並行して発掘する。失敗したトピックがあっても残りのトピックの処理は続ける。
+	// 結果の記録はパイプラインの最後の1つのgoroutineで行う
+	var (
+		failed          int
+		savedTrendIDs   []uint
+		budgetExhausted = make(map[uint]string) // key: トピックのID, value: 使い切った予算の種類
+		failures        = make(map[uint]string) // key: トピックのID, value: 失敗の分類のコード
+	)
+	p := &pipeline{db: db, storeRepo: storeRepo, trendRepo: trendRepo, watchRepo: watchRepo,
+		digest: digest, prefDigest: prefDigest, week: runWeek, opts: opts, sources: sources, entityTypes: entityTypes}
+	p.run(ctx, logger, job.ID, topics, opts.StageWorkers, opts.Concurrency, func(w *topicWork) {
+		if w.err != nil {
+			failed++
+			code := apperr.Count("discovery.topic", w.err)
+			w.logger.Error("トピックの発掘に失敗しました", "error", w.err, "code", code)
+			w.result.Outcome, w.result.Error, w.result.ErrorCode = model.TopicOutcomeFailed, w.err.Error(), string(code)
+			failures[w.topic.ID] = string(code)
+		}
+		w.result.FinishedAt = time.Now()
+		if w.result.Outcome == model.TopicOutcomeTrendSaved {
+			savedTrendIDs = append(savedTrendIDs, *w.result.TrendID)
+		}
+		if w.result.BudgetExhausted != "" {
+			budgetExhausted[w.topic.ID] = w.result.BudgetExhausted
+		}
+		if err := jobRepo.RecordTopic(&w.result); err != nil {
+			w.logger.Warn("トピックの結果の記録に失敗しました", "error", err)
+		}
+		if err := progress.topicDone(w.err != nil, w.result.Stores, time.Now()); err != nil {
+			logger.Warn("ジョブの進捗の更新に失敗しました", "error", err)
+		}
+	})
+
+	var runErr error
+	switch {
+	case ctx.Err() != nil:
+		runErr = fmt.Errorf("interrupted after %d of %d topics: %w", job.TopicsDone, len(topics), ctx.Err())
+	case failed > 0:
+		runErr = fmt.Errorf("%d of %d topics failed", failed, len(topics))
+	}
+	errMsg := ""
+	if runErr != nil {
+		errMsg = runErr.Error()
+	}
+	if err := progress.finish(errMsg, time.Now()); err != nil {
+		logger.Warn("ジョブの終了の記録に失敗しました", "error", err)
+	}
+	if opts.ProgressOutput != nil {
+		fmt.Fprintln(opts.ProgressOutput, progress.line(time.Now()))
+	}
+	if len(budgetExhausted) > 0 {
+		logger.Info("クロールの予算を使い切ったトピックがあります", "topics", len(budgetExhausted))
+	}
+	// 中断された場合も失敗を通知できるよう、キャンセルの影響を受けないようにする
+	publishRunEvents(context.WithoutCancel(ctx), logger, db.WithContext(context.WithoutCancel(ctx)), job)
+	if opts.StoreIndex != nil {
+		// 中断された場合も、それまでに言及を記録した店舗は検索できるようにする
+		search.IndexRun(context.WithoutCancel(ctx), logger, db, opts.StoreIndex, job.RunID)
+	}
+	if opts.RunNotifier != nil && ctx.Err() == nil {
+		notifyRunSummary(ctx, logger, db, opts, job, topics, savedTrendIDs, budgetExhausted, failures)
+	}
+	return runErr
+}
+
+// loadTopicsは発掘するトピックを返します。idsが空の場合は有効なすべてのトピックをw週の優先度の高い順(repository.ByPriority)に返します。
+// パイプラインは返した順にトピックを処理するため、クロールやGPTの予算が尽きる場合も優先度の高いトピックから発掘されます。
+// 指定されたトピックが無効化されている場合はスキップします。
+// tenantIDが0でなければそのテナントのトピックのみ返し、他のテナントのトピックが指定された場合はエラーとします。
+func loadTopics(logger *slog.Logger, db *gorm.DB, w time.Time, ids []uint, tenantID uint) ([]model.EntityTopic, error) {
+	var topics []model.EntityTopic
+	if tenantID != 0 {
+		db = db.Where("tenant_id = ?", tenantID)
+	}
+	if len(ids) == 0 {
+		if err := db.Where("disabled_at IS NULL").Scopes(repository.ByPriority(w)).Find(&topics).Error; err != nil {
+			return nil, fmt.Errorf("load topics: %w", err)
+		}
+		return topics, nil
+	}
+
+	for _, id := range ids {
+		var topic model.EntityTopic
+		if err := db.First(&topic, id).Error; err != nil {
+			return nil, fmt.Errorf("load topic %d: %w", id, err)
+		}
+		if topic.DisabledAt != nil {
+			logger.Info("トピックが無効化されているため発掘をスキップします", "topic_id", topic.ID, "topic", topic.Topic, "disabled_at", topic.DisabledAt)
+			continue
+		}
+		topics = append(topics, topic)
+	}
+	return topics, nil
+}
+
+// gptSystemPromptはスコアリングでGPTに渡す指示です。変更した場合も、トレンドには実際に使ったプロンプトが保存されます。
+const gptSystemPrompt = "以下の店舗名のリストから、話題性を100点満点でスコアリングしてください。JSONで {\"score\": 数値 } の形で返してください。"
+
+// DefaultGPTModel はスコアリングに使うGPTのモデルです。
+const DefaultGPTModel = "gpt-3.5-turbo"
+
+// gptPromptはトレンドに保存する、GPTに渡したプロンプトの全文を返します。
+func gptPrompt(input string) string {
+	return gptSystemPrompt + "\n\n" + input
+}
+
+// splitGPTPromptはgptPromptで保存したプロンプトを、GPTに渡した指示と入力に分けます。
+func splitGPTPrompt(prompt string) (system, input string, ok bool) {
+	return strings.Cut(prompt, "\n\n")
+}
+
+// analyzeWithGPTは与えられた入力文字列を既定のモデルのGPTに渡し、スコアを返します。
+func analyzeWithGPT(ctx context.Context, logger *slog.Logger, apiKey, input string) (float64, error) {
+	return scoreWithGPT(ctx, logger, apiKey, DefaultGPTModel, gptSystemPrompt, input)
+}
+
+// gptOp はGPTによるスコアリングの誤りとメトリクスに使う処理の名前です。
+const gptOp = "gpt score"
+
+// scoreWithGPTはsystemの指示とinputをmodelのGPTに渡し、応答のJSONからスコアを取り出します。
+// 失敗した場合はapperrで分類した誤りを返します。
+func scoreWithGPT(ctx context.Context, logger *slog.Logger, apiKey, model, system, input string) (float64, error) {
+	if strings.TrimSpace(input) == "" {
+		logger.Debug("GPT入力が空のためスコア0を返します")
+		return 0, nil
+	}
+
+	payload := map[string]interface{}{
+		"model": model,
+		"messages": []map[string]string{
+			{
+				"role":    "system",
+				"content": system,
+			},
+			{
+				"role":    "user",
+				"content": input,
+			},
+		},
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("encode gpt request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return 0, fmt.Errorf("create gpt request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := gptClient.Do(req)
+	if err != nil {
+		return 0, apperr.Upstream(gptOp, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return 0, apperr.Wrap(apperr.FromStatus(resp.StatusCode), gptOp, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(body)))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content *string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, apperr.Wrap(apperr.ErrParseFailed, gptOp, fmt.Errorf("decode response: %w", err))
+	}
+	if len(result.Choices) == 0 {
+		return 0, apperr.Wrap(apperr.ErrParseFailed, gptOp, errors.New("response has no choices"))
+	}
+	if result.Choices[0].Message.Content == nil {
+		return 0, apperr.Wrap(apperr.ErrParseFailed, gptOp, errors.New("response has no message content"))
+	}
+	content := *result.Choices[0].Message.Content
+	logger.Debug("GPTの応答", "model", model, "content", content)
+
+	// GPTのJSON出力を解析
+	var parsed map[string]float64
+	if err := json.Unmarshal([]byte(content), &parsed); err != nil {
+		return 0, apperr.Wrap(apperr.ErrParseFailed, gptOp, fmt.Errorf("decode output %q: %w", content, err))
+	}
+
+	score, ok := parsed["score"]
+	if !ok {
+		return 0, apperr.Wrap(apperr.ErrParseFailed, gptOp, fmt.Errorf("output has no score: %q", content))
+	}
+	logger.Debug("GPTスコア", "score", score)
+
+	return score, nil
+}
@@ -0,0 +1,110 @@
+package discovery
+
+import (
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/storematch"
+)
+
+// 店舗の情報の検証に失敗した理由。QuarantinedStore.Reasonsに記録する
+const (
+	ReasonNameEmpty   = "name_empty"           // 正規化すると店舗名が空になる
+	ReasonBudgetRange = "budget_range"         // 予算が範囲外か、下限が上限を超えている
+	ReasonRatingRange = "rating_range"         // 評価が0〜5の範囲外
+	ReasonAddress     = "address_not_japanese" // 住所が日本の住所に見えない
+)
+
+// maxBudgetYen は予算として妥当とみなす金額の上限です。
+const maxBudgetYen = 1_000_000
+
+// quarantineMetrics は隔離した店舗の数を処理と理由ごとに集計します。
+var quarantineMetrics = expvar.NewMap("stores_quarantined")
+
+var (
+	// budgetAmountPattern は予算の金額です。例: "￥3,000～￥3,999" の "3,000" と "3,999"
+	budgetAmountPattern = regexp.MustCompile(`[0-9０-９][0-9０-９,，]*`)
+	// postalCodePattern は住所の先頭の郵便番号です。
+	postalCodePattern = regexp.MustCompile(`^〒?\s*[0-9０-９]{3}[-－ー]?[0-9０-９]{4}\s*`)
+	// prefecturePattern は住所の先頭の都道府県です。
+	prefecturePattern = regexp.MustCompile(`^(?:北海道|東京都|京都府|大阪府|(?:青森|岩手|宮城|秋田|山形|福島|茨城|栃木|群馬|埼玉|千葉|神奈川|新潟|富山|石川|福井|山梨|長野|岐阜|静岡|愛知|三重|滋賀|兵庫|奈良|和歌山|鳥取|島根|岡山|広島|山口|徳島|香川|愛媛|高知|福岡|佐賀|長崎|熊本|大分|宮崎|鹿児島|沖縄)県)`)
+)
+
+// validateStoreは店舗の情報を検証し、失敗した検証の理由(Reason*)を返します。すべて通れば空です。
+// 予算・評価・住所は取得できなかった場合(空や"不明")は検証しません。
+func validateStore(s StoreData) []string {
+	var reasons []string
+	if storematch.Normalize(s.Name) == "" {
+		reasons = append(reasons, ReasonNameEmpty)
+	}
+	if !validBudget(s.BudgetLunch) || !validBudget(s.BudgetDinner) {
+		reasons = append(reasons, ReasonBudgetRange)
+	}
+	if s.Rating != nil && (*s.Rating < 0 || *s.Rating > 5) {
+		reasons = append(reasons, ReasonRatingRange)
+	}
+	if s.Address != "" && !japaneseAddress(s.Address) {
+		reasons = append(reasons, ReasonAddress)
+	}
+	return reasons
+}
+
+// validBudgetは予算の表記("￥3,000～￥3,999"、"～￥999"、"￥10,000～"など)の金額が1円以上maxBudgetYen以下で、
+// 下限が上限を超えていないかを返します。金額のない表記は取得できなかったものとして妥当とします。
+func validBudget(budget string) bool {
+	var amounts []int
+	for _, m := range budgetAmountPattern.FindAllString(budget, -1) {
+		n, err := strconv.Atoi(strings.NewReplacer(",", "", "，", "").Replace(toHalfwidthDigits(m)))
+		if err != nil || n < 1 || n > maxBudgetYen {
+			return false
+		}
+		amounts = append(amounts, n)
+	}
+	switch {
+	case len(amounts) > 2:
+		return false
+	case len(amounts) == 2:
+		return amounts[0] <= amounts[1]
+	}
+	return true
+}
+
+// japaneseAddressはaddressが都道府県から始まる日本の住所に見えるかを返します。先頭の郵便番号は無視します。
+func japaneseAddress(address string) bool {
+	address = postalCodePattern.ReplaceAllString(strings.TrimSpace(address), "")
+	return prefecturePattern.MatchString(address)
+}
+
+// toHalfwidthDigitsは全角数字を半角数字にします。
+func toHalfwidthDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '０' && r <= '９' {
+			return r - '０' + '0'
+		}
+		return r
+	}, s)
+}
+
+// quarantineStoreは検証に失敗した店舗の情報を理由とともに隔離のテーブルに記録します。
+// 記録に失敗してもエラーはログに出力するのみで、隔離した情報は保存しません。
+func quarantineStore(logger *slog.Logger, repo *repository.StoreRepository, stage, runID string, s StoreData, reasons []string) {
+	for _, reason := range reasons {
+		quarantineMetrics.Add(stage+"."+reason, 1)
+	}
+	logger.Warn("店舗の情報の検証に失敗したため隔離しました", "store", s.Name, "url", s.URL, "stage", stage, "reasons", reasons)
+	data, err := json.Marshal(s)
+	if err != nil {
+		logger.Error("隔離する店舗の情報の変換に失敗しました", "url", s.URL, "error", err)
+		return
+	}
+	q := model.QuarantinedStore{URL: s.URL, Name: s.Name, Stage: stage, Reasons: strings.Join(reasons, ","), Data: string(data), RunID: runID}
+	if err := repo.Quarantine(&q); err != nil {
+		logger.Error("店舗の情報の隔離に失敗しました", "url", s.URL, "error", err)
+	}
+}
@@ -0,0 +1,31 @@
+package discovery
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValidateStore(t *testing.T) {
+	rating := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name string
+		s    StoreData
+		want []string
+	}{
+		{"valid", StoreData{Name: "鮨 さいとう", BudgetLunch: "￥10,000～￥14,999", BudgetDinner: "￥30,000～", Rating: rating(4.5), Address: "東京都港区六本木1-4-5"}, nil},
+		{"unknown fields", StoreData{Name: "鮨 さいとう", BudgetLunch: "不明", BudgetDinner: "-"}, nil},
+		{"postal code", StoreData{Name: "a", Address: "〒106-0032 東京都港区六本木"}, nil},
+		{"empty name", StoreData{Name: " ・ 本店"}, []string{ReasonNameEmpty}},
+		{"inverted budget", StoreData{Name: "a", BudgetDinner: "￥5,000～￥3,999"}, []string{ReasonBudgetRange}},
+		{"zero budget", StoreData{Name: "a", BudgetLunch: "～￥0"}, []string{ReasonBudgetRange}},
+		{"huge budget", StoreData{Name: "a", BudgetDinner: "￥2,000,000～"}, []string{ReasonBudgetRange}},
+		{"rating", StoreData{Name: "a", Rating: rating(7)}, []string{ReasonRatingRange}},
+		{"foreign address", StoreData{Name: "a", Address: "123 Main St, Springfield"}, []string{ReasonAddress}},
+		{"several", StoreData{Name: "", Rating: rating(-1), Address: "Seoul"}, []string{ReasonNameEmpty, ReasonRatingRange, ReasonAddress}},
+	}
+	for _, tt := range tests {
+		if got := validateStore(tt.s); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: validateStore = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
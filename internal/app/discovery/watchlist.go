@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+
+	"gorm.io/gorm"
+)
+
+// checkWatchesはトピックに設定されたウォッチの閾値を今回のトレンドが超えたか判定し、超えたものをdigestに追加します。
+// prevは前週までの最新のトレンドで、存在しなければnilです。同じ週に同じウォッチが2回通知されることはありません。
+// 通知済みの記録はdigestの送信に成功してから行うため、送信に失敗したウォッチは次の実行で再び通知します。
+func checkWatches(logger *slog.Logger, repo *repository.WatchRepository, digest *notify.Digest, topic model.EntityTopic, trend model.TopicTrend, prev *model.TopicTrend) {
+	watches, err := repo.ListByTopic(topic.ID)
+	if err != nil {
+		logger.Error("ウォッチ取得失敗", "error", err)
+		return
+	}
+
+	for _, w := range watches {
+		if w.LastNotifiedWeek != nil && week.Same(*w.LastNotifiedWeek, trend.Week) {
+			logger.Debug("今週は通知済みのためスキップ", "watch_id", w.ID)
+			continue
+		}
+
+		reasons := watchReasons(w, trend, prev)
+		if len(reasons) == 0 {
+			continue
+		}
+		for _, reason := range reasons {
+			digest.Add(w.Subscriber, fmt.Sprintf("%s: %s", topic.Topic, reason))
+		}
+		digest.OnDelivered(w.Subscriber, func(ctx context.Context) {
+			// トピックの処理が終わった後に呼ばれるため、Flushのctxでクエリを発行する
+			if err := repo.WithContext(ctx).MarkNotified(w.ID, trend.Week); err != nil {
+				logger.Error("ウォッチの通知済み記録失敗", "watch_id", w.ID, "error", err)
+			}
+		})
+		logger.Info("ウォッチの閾値を超えました", "watch_id", w.ID, "subscriber", w.Subscriber)
+	}
+}
+
+// watchReasonsはウォッチの閾値を超えた理由を返します。超えていなければ空です。
+func watchReasons(w model.Watch, trend model.TopicTrend, prev *model.TopicTrend) []string {
+	var reasons []string
+
+	// スコアは閾値を下から上に超えたときのみ通知する
+	if w.MinScore != nil && trend.Score >= *w.MinScore && (prev == nil || prev.Score < *w.MinScore) {
+		reasons = append(reasons, fmt.Sprintf("スコアが%.1fを超えました (%.1f)", *w.MinScore, trend.Score))
+	}
+
+	if w.MinDelta != nil && prev != nil {
+		delta := trend.Score - prev.Score
+		if math.Abs(delta) >= *w.MinDelta {
+			reasons = append(reasons, fmt.Sprintf("スコアが前週比%+.1f変化しました (%.1f → %.1f)", delta, prev.Score, trend.Score))
+		}
+	}
+	return reasons
+}
+
+// notifyNewStoresはトピックにウォッチがあれば、実行でトピックに初めて言及された店舗をwatch.new_storeのWebhookで送り、
+// NotifyNewStoresのウォッチの通知先に通知します。トレンドの通知と異なり、トピックごとにすぐ送ります。
+func notifyNewStores(ctx context.Context, logger *slog.Logger, db *gorm.DB, storeRepo *repository.StoreRepository, watchRepo *repository.WatchRepository,
+	notifier notify.Notifier, topic model.EntityTopic, week time.Time, runID string) {
+	watches, err := watchRepo.ListByTopic(topic.ID)
+	if err != nil {
+		logger.Error("ウォッチ取得失敗", "error", err)
+		return
+	}
+	if len(watches) == 0 {
+		return
+	}
+	stores, err := storeRepo.FirstSeenByRun(topic.ID, runID)
+	if err != nil {
+		logger.Error("新しい店舗の取得に失敗しました", "error", err)
+		return
+	}
+	if len(stores) == 0 {
+		return
+	}
+
+	for _, s := range stores {
+		publish(ctx, logger, db, model.EventWatchNewStore, watchNewStoreEvent{
+			StoreID: s.StoreID, Name: s.Name, URL: s.URL, TopicID: topic.ID, Topic: topic.Topic, Week: week.Format("2006-01-02"), RunID: runID,
+		})
+	}
+	digest := notify.NewDigest(notifier, fmt.Sprintf("「%s」で新しい店舗が見つかりました", topic.Topic))
+	// 同じ通知先の複数のウォッチには1回だけ通知する
+	notified := make(map[string]bool)
+	for _, w := range watches {
+		if !w.NotifyNewStores || notified[w.Subscriber] {
+			continue
+		}
+		notified[w.Subscriber] = true
+		for _, s := range stores {
+			digest.Add(w.Subscriber, fmt.Sprintf("%s %s", s.Name, s.URL))
+		}
+	}
+	if err := digest.Flush(ctx); err != nil {
+		logger.Error("新しい店舗の通知の送信に失敗しました", "error", err)
+	}
+	logger.Info("ウォッチ中のトピックで新しい店舗が見つかりました", "stores", len(stores))
+}
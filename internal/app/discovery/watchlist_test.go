@@ -0,0 +1,39 @@
+package discovery
+
+import (
+	"slices"
+	"testing"
+
+	"excavation_service/internal/app/model"
+)
+
+func TestWatchReasons(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name  string
+		watch model.Watch
+		score float64
+		prev  *model.TopicTrend
+		want  []string
+	}{
+		{"score crossed", model.Watch{MinScore: f(60)}, 65, &model.TopicTrend{Score: 55}, []string{"スコアが60.0を超えました (65.0)"}},
+		{"score already above", model.Watch{MinScore: f(60)}, 65, &model.TopicTrend{Score: 62}, nil},
+		{"score below", model.Watch{MinScore: f(60)}, 59.9, &model.TopicTrend{Score: 40}, nil},
+		// 前週のトレンドがなければ閾値以上のスコアだけで通知する
+		{"first week score", model.Watch{MinScore: f(60)}, 60, nil, []string{"スコアが60.0を超えました (60.0)"}},
+		{"delta up", model.Watch{MinDelta: f(10)}, 70, &model.TopicTrend{Score: 55}, []string{"スコアが前週比+15.0変化しました (55.0 → 70.0)"}},
+		{"delta down", model.Watch{MinDelta: f(10)}, 40, &model.TopicTrend{Score: 50}, []string{"スコアが前週比-10.0変化しました (50.0 → 40.0)"}},
+		{"delta small", model.Watch{MinDelta: f(10)}, 45, &model.TopicTrend{Score: 50}, nil},
+		// 前週比は前週のトレンドがなければ判定しない
+		{"first week delta", model.Watch{MinDelta: f(10)}, 90, nil, nil},
+		{"both", model.Watch{MinScore: f(60), MinDelta: f(10)}, 75, &model.TopicTrend{Score: 50},
+			[]string{"スコアが60.0を超えました (75.0)", "スコアが前週比+25.0変化しました (50.0 → 75.0)"}},
+		{"no thresholds", model.Watch{}, 99, nil, nil},
+	}
+	for _, tt := range tests {
+		got := watchReasons(tt.watch, model.TopicTrend{Score: tt.score}, tt.prev)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: watchReasons() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
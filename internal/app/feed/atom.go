@@ -0,0 +1,119 @@
+// Package feed は週ごとのトレンドをフィードリーダーや後段の処理から読めるAtomフィードにします。
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/i18n"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+)
+
+// ContentType はAtomフィードのContent-Typeです。
+const ContentType = "application/atom+xml; charset=utf-8"
+
+// Feed はAtomフィードです (RFC 4287)。
+type Feed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    Link     `xml:"link"`
+	Author  Person   `xml:"author"`
+	Entries []Entry  `xml:"entry"`
+}
+
+type Link struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type Person struct {
+	Name string `xml:"name"`
+}
+
+type Text struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type Entry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Link    *Link  `xml:"link,omitempty"`
+	Summary Text   `xml:"summary"`
+	Content Text   `xml:"content"`
+}
+
+// Buildはトレンドの一覧からフィードを作成します。selfURLはフィード自身のURLで、フィードのIDにも使います。
+// storesのkeyはトレンドのID、valueはその週にトピックで言及の多かった店舗です。
+// エントリーの文言はlangで書きます。トレンドがない場合、フィードの更新日時はnowです。
+func Build(lang i18n.Lang, title, selfURL string, trends []repository.FeedTrend, stores map[uint][]repository.StoreMentionCount, now time.Time) *Feed {
+	f := &Feed{
+		ID:      selfURL,
+		Title:   title,
+		Link:    Link{Rel: "self", Href: selfURL},
+		Author:  Person{Name: "excavation"},
+		Entries: make([]Entry, 0, len(trends)),
+	}
+	updated := time.Time{}
+	for _, t := range trends {
+		if t.UpdatedAt.After(updated) {
+			updated = t.UpdatedAt
+		}
+		f.Entries = append(f.Entries, entry(lang, t, stores[t.ID]))
+	}
+	if updated.IsZero() {
+		updated = now
+	}
+	f.Updated = updated.UTC().Format(time.RFC3339)
+	return f
+}
+
+// entryはトピックのある週のトレンドを1件のエントリーにします。リンク先は最も言及の多かった店舗です。
+func entry(lang i18n.Lang, t repository.FeedTrend, stores []repository.StoreMentionCount) Entry {
+	score := i18n.Textf(lang, "feed.score", t.Score)
+	if t.Delta != nil {
+		score += i18n.Textf(lang, "feed.delta", *t.Delta)
+	}
+	summary := i18n.Textf(lang, "feed.summary", score, t.MentionCount)
+	if t.TopTitle != "" {
+		summary += i18n.Textf(lang, "feed.top_title", t.TopTitle)
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "<p>%s</p>", html.EscapeString(summary))
+	if len(stores) > 0 {
+		fmt.Fprintf(&content, "<p>%s</p><ul>", html.EscapeString(i18n.Text(lang, "feed.stores")))
+		for _, s := range stores {
+			fmt.Fprintf(&content, `<li><a href="%s">%s</a> (%s)</li>`, html.EscapeString(s.URL), html.EscapeString(s.Name), i18n.Textf(lang, "feed.mentions", s.Mentions))
+		}
+		content.WriteString("</ul>")
+	}
+
+	e := Entry{
+		ID:      fmt.Sprintf("urn:excavation:topic-trend:%d", t.ID),
+		Title:   i18n.Textf(lang, "feed.entry_title", t.Topic, t.Week.Format(week.Layout)),
+		Updated: t.UpdatedAt.UTC().Format(time.RFC3339),
+		Summary: Text{Type: "text", Body: summary},
+		Content: Text{Type: "html", Body: content.String()},
+	}
+	if len(stores) > 0 {
+		e.Link = &Link{Rel: "alternate", Href: stores[0].URL}
+	}
+	return e
+}
+
+// MarshalはフィードをXML宣言付きのXMLにします。
+func (f *Feed) Marshal() ([]byte, error) {
+	b, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
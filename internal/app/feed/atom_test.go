@@ -0,0 +1,64 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/i18n"
+	"excavation_service/internal/app/repository"
+)
+
+func TestBuild(t *testing.T) {
+	delta := 4.5
+	w := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	trends := []repository.FeedTrend{
+		{ID: 7, TopicID: 1, Topic: "渋谷 カレー", Week: w, Score: 72, Delta: &delta, MentionCount: 12, TopTitle: "スパイス & ハーブ", UpdatedAt: w.Add(26 * time.Hour)},
+		{ID: 8, TopicID: 2, Topic: "渋谷 ラーメン", Week: w, Score: 40, UpdatedAt: w.Add(25 * time.Hour)},
+	}
+	stores := map[uint][]repository.StoreMentionCount{
+		7: {{StoreID: 3, Name: "食堂 <ほし>", URL: "https://tabelog.com/tokyo/A1/A2/3?a=1&b=2", Mentions: 5}},
+	}
+	b, err := Build(i18n.Ja, "週間トレンド (渋谷)", "https://example.com/feeds/trends.xml?area=渋谷", trends, stores, time.Now()).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Feed
+	if err := xml.Unmarshal(b, &got); err != nil {
+		t.Fatalf("invalid xml: %v\n%s", err, b)
+	}
+	if got.Updated != "2024-06-04T02:00:00Z" || len(got.Entries) != 2 {
+		t.Fatalf("feed = %+v", got)
+	}
+	e := got.Entries[0]
+	if e.ID != "urn:excavation:topic-trend:7" || e.Title != "渋谷 カレー (2024-06-03週)" {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Link == nil || e.Link.Href != "https://tabelog.com/tokyo/A1/A2/3?a=1&b=2" {
+		t.Errorf("entry link = %+v", e.Link)
+	}
+	if e.Summary.Body != "スコア 72.0 (前週比+4.5)、言及元ページ 12件。話題: スパイス & ハーブ" {
+		t.Errorf("summary = %q", e.Summary.Body)
+	}
+	if !strings.Contains(e.Content.Body, `<a href="https://tabelog.com/tokyo/A1/A2/3?a=1&amp;b=2">食堂 &lt;ほし&gt;</a>`) {
+		t.Errorf("content = %q", e.Content.Body)
+	}
+	if got.Entries[1].Link != nil {
+		t.Errorf("entry without stores has link %+v", got.Entries[1].Link)
+	}
+
+	en := Build(i18n.En, "Weekly trends", "https://example.com/feeds/trends.xml", trends, stores, time.Now()).Entries[0]
+	if en.Title != "渋谷 カレー (week of 2024-06-03)" || en.Summary.Body != "Score 72.0 (+4.5 from last week), mentioned on 12 pages. Top story: スパイス & ハーブ" {
+		t.Errorf("english entry = %+v", en)
+	}
+}
+
+func TestBuildEmpty(t *testing.T) {
+	now := time.Date(2024, 6, 5, 9, 0, 0, 0, time.UTC)
+	f := Build(i18n.Ja, "週間トレンド", "https://example.com/feeds/trends.xml", nil, nil, now)
+	if f.Updated != "2024-06-05T09:00:00Z" || len(f.Entries) != 0 {
+		t.Errorf("feed = %+v", f)
+	}
+}
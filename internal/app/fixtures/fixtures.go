@@ -0,0 +1,121 @@
+// Package fixtures はオフラインでの開発のため、外部サービスへのリクエストにフィクスチャのファイルを返すHTTPサーバーを提供します。
+//
+// フィクスチャは<ホスト名>/<パス>に置きます。クエリ文字列とメソッドは区別しません。
+// パスが/で終わる場合は<パス>/index.html、拡張子がない場合は<パス>.jsonと<パス>.htmlも探します。
+//
+//	api.brave.com/res/v1/web/search.json        Brave Searchの検索結果
+//	api.openai.com/v1/chat/completions.json     GPTの応答
+//	tabelog.com/matome/12345/index.html         食べログのまとめ記事
+package fixtures
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+)
+
+//go:embed bundle
+var bundle embed.FS
+
+// Bundleは開発用に同梱したフィクスチャです。検索結果から、まとめ記事・リストページ・店舗ページ・ニュースを辿れます。
+func Bundle() fs.FS {
+	sub, err := fs.Sub(bundle, "bundle")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// Server はフィクスチャを返すローカルのHTTPサーバーです。
+type Server struct {
+	URL string // http://127.0.0.1:<port>
+
+	fsys   fs.FS
+	logger *slog.Logger
+	srv    *http.Server
+}
+
+// Startはfsysのフィクスチャを返すサーバーを127.0.0.1の空いているポートで起動します。
+func Start(logger *slog.Logger, fsys fs.FS) (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{URL: "http://" + ln.Addr().String(), fsys: fsys, logger: logger}
+	s.srv = &http.Server{Handler: s}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("フィクスチャサーバーが停止しました", "error", err)
+		}
+	}()
+	logger.Info("オフラインモード: フィクスチャサーバーを起動しました", "url", s.URL)
+	return s, nil
+}
+
+// Closeはサーバーを停止します。
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// ServeHTTPは/<ホスト名>/<パス>へのリクエストに、対応するフィクスチャを返します。
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, body, err := s.lookup(strings.TrimPrefix(r.URL.Path, "/"))
+	if err != nil {
+		s.logger.Warn("フィクスチャがありません", "path", r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+	s.logger.Debug("フィクスチャを返します", "path", r.URL.Path, "fixture", name)
+	switch path.Ext(name) {
+	case ".json":
+		w.Header().Set("Content-Type", "application/json")
+	case ".html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.Write(body)
+}
+
+func (s *Server) lookup(p string) (name string, body []byte, err error) {
+	var candidates []string
+	if strings.HasSuffix(p, "/") {
+		candidates = append(candidates, p+"index.html")
+	} else {
+		candidates = append(candidates, p)
+		if path.Ext(p) == "" {
+			candidates = append(candidates, p+".json", p+".html", p+"/index.html")
+		}
+	}
+	for _, c := range candidates {
+		body, err := fs.ReadFile(s.fsys, c)
+		if err == nil {
+			return c, body, nil
+		}
+	}
+	return "", nil, fs.ErrNotExist
+}
+
+// Transportはすべてのリクエストをサーバーの/<ホスト名>/<パス>に振り向けるRoundTripperを返します。
+func (s *Server) Transport() http.RoundTripper {
+	return rewriteTransport{target: s.URL}
+}
+
+type rewriteTransport struct {
+	target string
+}
+
+func (t rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rewritten := r.Clone(r.Context())
+	u := *r.URL
+	u.Path = "/" + r.URL.Hostname() + r.URL.Path
+	u.RawPath = ""
+	u.Scheme = "http"
+	u.Host = strings.TrimPrefix(t.target, "http://")
+	rewritten.URL = &u
+	rewritten.Host = ""
+	return http.DefaultTransport.RoundTrip(rewritten)
+}
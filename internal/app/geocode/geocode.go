@@ -0,0 +1,171 @@
+// Package geocode は店舗の住所を緯度・経度に変換します。
+//
+// 変換には国土地理院の住所検索API(既定、APIキー不要)、Nominatim(OpenStreetMap)、Google Geocoding APIのいずれかを使います。
+// 外部のAPIへのリクエストはNewRateLimitedで間隔を空け、結果(見つからなかった住所を含む)はNewCachedでDBにキャッシュします。
+package geocode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tracing"
+
+	"gorm.io/gorm"
+)
+
+// 住所の変換に使うサービス
+const (
+	ProviderGSI       = "gsi"
+	ProviderNominatim = "nominatim"
+	ProviderGoogle    = "google"
+)
+
+// ErrNotFound は住所に該当する位置が見つからなかったことを表します。
+var ErrNotFound = errors.New("address not found")
+
+// Location は緯度・経度です。
+type Location struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Geocoder は住所を緯度・経度に変換します。該当する位置がなければErrNotFoundを返します。
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (Location, error)
+}
+
+// Newはproviderのサービスで変換するGeocoderを作成します。
+// googleAPIKeyはGoogle、userAgentはNominatim(利用規約でアプリケーションを識別できる値が必要)で使います。
+func New(provider, googleAPIKey, userAgent string) (Geocoder, error) {
+	switch provider {
+	case ProviderGSI:
+		return NewGSI(), nil
+	case ProviderNominatim:
+		return NewNominatim(userAgent), nil
+	case ProviderGoogle:
+		if googleAPIKey == "" {
+			return nil, fmt.Errorf("google geocoder requires an API key")
+		}
+		return NewGoogle(googleAPIKey), nil
+	}
+	return nil, fmt.Errorf("unknown geocoder %q", provider)
+}
+
+var httpClient = tracing.NewHTTPClient(10 * time.Second)
+
+// Normalizeはキャッシュのキーに使うため、住所の前後と連続する空白を取り除きます。
+func Normalize(address string) string {
+	return strings.Join(strings.Fields(address), " ")
+}
+
+// RateLimited は変換の間隔をinterval以上空けるGeocoderです。複数のgoroutineから使えます。
+type RateLimited struct {
+	geocoder Geocoder
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func NewRateLimited(geocoder Geocoder, interval time.Duration) *RateLimited {
+	return &RateLimited{geocoder: geocoder, interval: interval}
+}
+
+func (g *RateLimited) Geocode(ctx context.Context, address string) (Location, error) {
+	g.mu.Lock()
+	now := time.Now()
+	at := now
+	if g.next.After(now) {
+		at = g.next
+	}
+	g.next = at.Add(g.interval)
+	g.mu.Unlock()
+
+	if wait := at.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return Location{}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return g.geocoder.Geocode(ctx, address)
+}
+
+// Cache は住所の変換結果のキャッシュです。
+type Cache interface {
+	// Getは住所の変換結果を返します。キャッシュになければokはfalse、見つからなかった住所はlocがnilです。
+	Get(ctx context.Context, address string) (loc *Location, ok bool, err error)
+	// Putは住所の変換結果を保存します。見つからなかった住所はlocをnilにします。
+	Put(ctx context.Context, address string, loc *Location) error
+}
+
+// Cached は変換結果をキャッシュし、同じ住所をサービスに再び問い合わせないGeocoderです。
+// 見つからなかった住所もキャッシュします。キャッシュの読み書きに失敗した場合はサービスで変換します。
+type Cached struct {
+	geocoder Geocoder
+	cache    Cache
+}
+
+func NewCached(geocoder Geocoder, cache Cache) *Cached {
+	return &Cached{geocoder: geocoder, cache: cache}
+}
+
+func (g *Cached) Geocode(ctx context.Context, address string) (Location, error) {
+	address = Normalize(address)
+	if loc, ok, err := g.cache.Get(ctx, address); err == nil && ok {
+		if loc == nil {
+			return Location{}, ErrNotFound
+		}
+		return *loc, nil
+	}
+
+	loc, err := g.geocoder.Geocode(ctx, address)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		_ = g.cache.Put(ctx, address, nil)
+	case err == nil:
+		_ = g.cache.Put(ctx, address, &loc)
+	}
+	return loc, err
+}
+
+// DBCache はgeocode_cacheテーブルに変換結果を保存するCacheです。
+type DBCache struct {
+	repo     *repository.GeocodeCacheRepository
+	provider string
+}
+
+// NewDBCacheはproviderの変換結果を保存するDBCacheを作成します。サービスごとに結果を分けて保存します。
+func NewDBCache(db *gorm.DB, provider string) *DBCache {
+	return &DBCache{repo: repository.NewGeocodeCacheRepository(db), provider: provider}
+}
+
+func (c *DBCache) Get(ctx context.Context, address string) (*Location, bool, error) {
+	entry, err := c.repo.WithContext(ctx).Get(c.provider, address)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if entry.Latitude == nil || entry.Longitude == nil {
+		return nil, true, nil
+	}
+	return &Location{Lat: *entry.Latitude, Lon: *entry.Longitude}, true, nil
+}
+
+func (c *DBCache) Put(ctx context.Context, address string, loc *Location) error {
+	entry := model.GeocodeCache{Provider: c.provider, Address: address}
+	if loc != nil {
+		entry.Latitude, entry.Longitude = &loc.Lat, &loc.Lon
+	}
+	return c.repo.WithContext(ctx).Put(&entry)
+}
@@ -0,0 +1,126 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProviders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/gsi":
+			if q.Get("q") == "東京都渋谷区神南1-2-3" {
+				io.WriteString(w, `[{"geometry":{"coordinates":[139.7,35.66],"type":"Point"},"type":"Feature","properties":{"title":"東京都渋谷区神南一丁目"}}]`)
+				return
+			}
+			io.WriteString(w, `[]`)
+		case "/nominatim":
+			if r.Header.Get("User-Agent") != "excavation-test" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if q.Get("q") == "東京都渋谷区神南1-2-3" {
+				io.WriteString(w, `[{"lat":"35.66","lon":"139.7"}]`)
+				return
+			}
+			io.WriteString(w, `[]`)
+		case "/google":
+			switch {
+			case q.Get("key") != "key":
+				io.WriteString(w, `{"status":"REQUEST_DENIED","error_message":"The provided API key is invalid."}`)
+			case q.Get("address") == "東京都渋谷区神南1-2-3":
+				io.WriteString(w, `{"status":"OK","results":[{"geometry":{"location":{"lat":35.66,"lng":139.7}}}]}`)
+			default:
+				io.WriteString(w, `{"status":"ZERO_RESULTS","results":[]}`)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	geocoders := map[string]Geocoder{
+		"gsi":       &GSI{baseURL: srv.URL + "/gsi"},
+		"nominatim": &Nominatim{baseURL: srv.URL + "/nominatim", userAgent: "excavation-test"},
+		"google":    &Google{baseURL: srv.URL + "/google", apiKey: "key"},
+	}
+	for name, g := range geocoders {
+		loc, err := g.Geocode(context.Background(), "東京都渋谷区神南1-2-3")
+		if err != nil || loc != (Location{Lat: 35.66, Lon: 139.7}) {
+			t.Errorf("%s: Geocode() = %+v, %v", name, loc, err)
+		}
+		if _, err := g.Geocode(context.Background(), "存在しない住所"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: Geocode(unknown) error = %v, want ErrNotFound", name, err)
+		}
+	}
+
+	if _, err := (&Google{baseURL: srv.URL + "/google", apiKey: "wrong"}).Geocode(context.Background(), "東京都"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Geocode() with an invalid key error = %v, want a request error", err)
+	}
+}
+
+type fakeGeocoder struct {
+	calls int
+	locs  map[string]Location
+}
+
+func (g *fakeGeocoder) Geocode(ctx context.Context, address string) (Location, error) {
+	g.calls++
+	loc, ok := g.locs[address]
+	if !ok {
+		return Location{}, ErrNotFound
+	}
+	return loc, nil
+}
+
+type memoryCache map[string]*Location
+
+func (c memoryCache) Get(ctx context.Context, address string) (*Location, bool, error) {
+	loc, ok := c[address]
+	return loc, ok, nil
+}
+
+func (c memoryCache) Put(ctx context.Context, address string, loc *Location) error {
+	c[address] = loc
+	return nil
+}
+
+func TestCached(t *testing.T) {
+	provider := &fakeGeocoder{locs: map[string]Location{"東京都渋谷区神南1-2-3": {Lat: 35.66, Lon: 139.7}}}
+	g := NewCached(provider, memoryCache{})
+	for range 2 {
+		if loc, err := g.Geocode(context.Background(), "  東京都渋谷区神南1-2-3 "); err != nil || loc.Lat != 35.66 {
+			t.Fatalf("Geocode() = %+v, %v", loc, err)
+		}
+		// 見つからなかった住所もキャッシュする
+		if _, err := g.Geocode(context.Background(), "存在しない住所"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Geocode(unknown) error = %v, want ErrNotFound", err)
+		}
+	}
+	if provider.calls != 2 {
+		t.Errorf("provider called %d times, want 2", provider.calls)
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	g := NewRateLimited(&fakeGeocoder{}, 20*time.Millisecond)
+	start := time.Now()
+	for range 3 {
+		g.Geocode(context.Background(), "東京都")
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 40ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g.Geocode(ctx, "東京都"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Geocode() with a canceled context error = %v", err)
+	}
+}
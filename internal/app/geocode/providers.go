@@ -0,0 +1,137 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// GSI は国土地理院の住所検索APIで変換するGeocoderです。APIキーは不要です。
+type GSI struct {
+	baseURL string
+}
+
+func NewGSI() *GSI {
+	return &GSI{baseURL: "https://msearch.gsi.go.jp/address-search/AddressSearch"}
+}
+
+func (g *GSI) Geocode(ctx context.Context, address string) (Location, error) {
+	// 結果はGeoJSONのFeatureの配列で、座標は[経度, 緯度]の順
+	var features []struct {
+		Geometry struct {
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+	}
+	if err := getJSON(ctx, g.baseURL+"?q="+url.QueryEscape(address), nil, &features); err != nil {
+		return Location{}, fmt.Errorf("gsi: %w", err)
+	}
+	if len(features) == 0 || len(features[0].Geometry.Coordinates) < 2 {
+		return Location{}, ErrNotFound
+	}
+	c := features[0].Geometry.Coordinates
+	return Location{Lat: c[1], Lon: c[0]}, nil
+}
+
+// Nominatim はOpenStreetMapのNominatimで変換するGeocoderです。
+// 公開サーバーの利用規約により、リクエストは1秒に1回までとし、アプリケーションを識別できるUser-Agentを送ります。
+type Nominatim struct {
+	baseURL   string
+	userAgent string
+}
+
+func NewNominatim(userAgent string) *Nominatim {
+	return &Nominatim{baseURL: "https://nominatim.openstreetmap.org/search", userAgent: userAgent}
+}
+
+func (g *Nominatim) Geocode(ctx context.Context, address string) (Location, error) {
+	q := url.Values{"q": {address}, "format": {"jsonv2"}, "limit": {"1"}, "countrycodes": {"jp"}}
+	var places []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := getJSON(ctx, g.baseURL+"?"+q.Encode(), http.Header{"User-Agent": {g.userAgent}}, &places); err != nil {
+		return Location{}, fmt.Errorf("nominatim: %w", err)
+	}
+	if len(places) == 0 {
+		return Location{}, ErrNotFound
+	}
+	lat, err := strconv.ParseFloat(places[0].Lat, 64)
+	if err != nil {
+		return Location{}, fmt.Errorf("nominatim: invalid lat %q", places[0].Lat)
+	}
+	lon, err := strconv.ParseFloat(places[0].Lon, 64)
+	if err != nil {
+		return Location{}, fmt.Errorf("nominatim: invalid lon %q", places[0].Lon)
+	}
+	return Location{Lat: lat, Lon: lon}, nil
+}
+
+// Google はGoogle Geocoding APIで変換するGeocoderです。
+type Google struct {
+	baseURL string
+	apiKey  string
+}
+
+func NewGoogle(apiKey string) *Google {
+	return &Google{baseURL: "https://maps.googleapis.com/maps/api/geocode/json", apiKey: apiKey}
+}
+
+func (g *Google) Geocode(ctx context.Context, address string) (Location, error) {
+	q := url.Values{"address": {address}, "key": {g.apiKey}, "language": {"ja"}, "region": {"jp"}}
+	var data struct {
+		Status       string `json:"status"`
+		ErrorMessage string `json:"error_message"`
+		Results      []struct {
+			Geometry struct {
+				Location struct {
+					Lat float64 `json:"lat"`
+					Lng float64 `json:"lng"`
+				} `json:"location"`
+			} `json:"geometry"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, g.baseURL+"?"+q.Encode(), nil, &data); err != nil {
+		return Location{}, fmt.Errorf("google: %w", err)
+	}
+	switch data.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return Location{}, ErrNotFound
+	default:
+		return Location{}, fmt.Errorf("google: status %s: %s", data.Status, data.ErrorMessage)
+	}
+	if len(data.Results) == 0 {
+		return Location{}, ErrNotFound
+	}
+	loc := data.Results[0].Geometry.Location
+	return Location{Lat: loc.Lat, Lon: loc.Lng}, nil
+}
+
+// getJSONはurlStrにGETでリクエストし、JSONの応答をoutに読み込みます。
+func getJSON(ctx context.Context, urlStr string, header http.Header, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, body)
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,74 @@
+// Package geojson は発掘した店舗をGeoJSON(RFC 7946)にし、QGISやMapbox、Googleマイマップなどの地図のツールで直接読めるようにします。
+package geojson
+
+import (
+	"encoding/json"
+
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+)
+
+// ContentType はGeoJSONのContent-Typeです。
+const ContentType = "application/geo+json"
+
+// FeatureCollection はGeoJSONの地物の集まりです。
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature はGeoJSONの地物です。
+type Feature struct {
+	Type       string          `json:"type"`
+	ID         uint            `json:"id"`
+	Geometry   Point           `json:"geometry"`
+	Properties StoreProperties `json:"properties"`
+}
+
+// Point はGeoJSONの点です。座標は[経度, 緯度]の順です。
+type Point struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// StoreProperties は店舗の地物の属性です。
+type StoreProperties struct {
+	Name              string   `json:"name"`
+	URL               string   `json:"url"`
+	Address           string   `json:"address,omitempty"`
+	Topics            []string `json:"topics"`
+	Score             *float64 `json:"score"` // 言及していたトピックの最新のトレンドの最高スコア。トレンドがなければnull
+	Mentions          int64    `json:"mentions"`
+	LastMentionedWeek string   `json:"last_mentioned_week"`
+}
+
+// Storesは店舗をGeoJSONのFeatureCollectionにします。
+func Stores(stores []repository.StoreFeature) FeatureCollection {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, 0, len(stores))}
+	for _, s := range stores {
+		topics := []string(s.Topics)
+		if topics == nil {
+			topics = []string{}
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:     "Feature",
+			ID:       s.ID,
+			Geometry: Point{Type: "Point", Coordinates: [2]float64{s.Longitude, s.Latitude}},
+			Properties: StoreProperties{
+				Name:              s.Name,
+				URL:               s.URL,
+				Address:           s.Address,
+				Topics:            topics,
+				Score:             s.Score,
+				Mentions:          s.Mentions,
+				LastMentionedWeek: s.LastMentionedWeek.Format(week.Layout),
+			},
+		})
+	}
+	return fc
+}
+
+// Marshalはfcを地図のツールに渡すJSONにします。
+func (fc FeatureCollection) Marshal() ([]byte, error) {
+	return json.Marshal(fc)
+}
@@ -0,0 +1,57 @@
+package geojson
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/repository"
+)
+
+func TestStores(t *testing.T) {
+	score := 72.5
+	stores := []repository.StoreFeature{
+		{ID: 3, Name: "スパイス食堂 ほし", URL: "https://tabelog.com/3", Address: "東京都荒川区西日暮里5-1-1", Latitude: 35.73, Longitude: 139.77,
+			Topics: []string{"西日暮里 カレー"}, Score: &score, Mentions: 4, LastMentionedWeek: time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)},
+		{ID: 5, Name: "中華そば つき", URL: "https://tabelog.com/5", Latitude: 35.66, Longitude: 139.7, LastMentionedWeek: time.Date(2024, 5, 27, 0, 0, 0, 0, time.UTC)},
+	}
+	body, err := Stores(stores).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Type     string
+		Features []struct {
+			Type     string
+			ID       uint
+			Geometry struct {
+				Type        string
+				Coordinates []float64
+			}
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != "FeatureCollection" || len(got.Features) != 2 {
+		t.Fatalf("got %s", body)
+	}
+	f := got.Features[0]
+	if f.Type != "Feature" || f.ID != 3 || f.Geometry.Type != "Point" || len(f.Geometry.Coordinates) != 2 ||
+		f.Geometry.Coordinates[0] != 139.77 || f.Geometry.Coordinates[1] != 35.73 {
+		t.Errorf("feature = %+v, want a point at [lon, lat]", f)
+	}
+	if f.Properties["score"] != 72.5 || f.Properties["last_mentioned_week"] != "2024-06-03" {
+		t.Errorf("properties = %v", f.Properties)
+	}
+	// トレンドのない店舗のスコアはnull、トピックは空の配列にする
+	p := got.Features[1].Properties
+	if v, ok := p["score"]; !ok || v != nil {
+		t.Errorf("score = %v, want null", v)
+	}
+	if topics, ok := p["topics"].([]interface{}); !ok || len(topics) != 0 {
+		t.Errorf("topics = %v, want []", p["topics"])
+	}
+}
@@ -0,0 +1,136 @@
+// Package googleauth はGoogleアカウントでのサインイン(OAuth 2.0の認可コードフローとPKCE)を提供します。
+//
+// Google Cloudのコンソールで「ウェブアプリケーション」のOAuthクライアントを作成し、承認済みのリダイレクトURIに
+// APIサーバーの/auth/google/callbackを登録してください。サインインで得るのはユーザーIDと確認済みのメールアドレスのみです。
+package googleauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/tracing"
+)
+
+const (
+	defaultAuthURL     = "https://accounts.google.com/o/oauth2/v2/auth"
+	defaultTokenURL    = "https://oauth2.googleapis.com/token"
+	defaultUserInfoURL = "https://openidconnect.googleapis.com/v1/userinfo"
+	scope              = "openid email"
+)
+
+// UserInfo はサインインしたGoogleアカウントの情報です。
+type UserInfo struct {
+	Subject       string `json:"sub"` // GoogleアカウントのユーザーID。メールアドレスと違い変わらない
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// Client はGoogleのOAuthクライアントです。
+type Client struct {
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	authURL      string
+	tokenURL     string
+	userInfoURL  string
+	http         *http.Client
+}
+
+// NewClientはOAuthクライアントのIDとシークレット、サインイン後に戻るURL(redirectURL)のクライアントを返します。
+func NewClient(clientID, clientSecret, redirectURL string) *Client {
+	return &Client{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		authURL:      defaultAuthURL,
+		tokenURL:     defaultTokenURL,
+		userInfoURL:  defaultUserInfoURL,
+		http:         tracing.NewHTTPClient(10 * time.Second),
+	}
+}
+
+// NewVerifierはstateやPKCEのcode_verifierに使う推測できないランダムな文字列を返します。
+func NewVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthCodeURLはサインインのためにブラウザーをリダイレクトするGoogleのURLを返します。
+// stateとverifierはサインイン後のExchangeまでブラウザーごとに保持してください。
+func (c *Client) AuthCodeURL(state, verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"client_id":             {c.clientID},
+		"redirect_uri":          {c.redirectURL},
+		"response_type":         {"code"},
+		"scope":                 {scope},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+		"prompt":                {"select_account"},
+	}
+	return c.authURL + "?" + q.Encode()
+}
+
+// ExchangeはGoogleからリダイレクトで渡された認可コードcodeをアクセストークンと交換し、サインインしたアカウントの情報を返します。
+func (c *Client) Exchange(ctx context.Context, code, verifier string) (*UserInfo, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"code_verifier": {verifier},
+		"client_id":     {c.clientID},
+		"client_secret": {c.clientSecret},
+		"redirect_uri":  {c.redirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := c.do(req, &token); err != nil {
+		return nil, fmt.Errorf("exchange google authorization code: %w", err)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, c.userInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	var info UserInfo
+	if err := c.do(req, &info); err != nil {
+		return nil, fmt.Errorf("get google userinfo: %w", err)
+	}
+	if info.Subject == "" {
+		return nil, fmt.Errorf("get google userinfo: sub is missing")
+	}
+	return &info, nil
+}
+
+func (c *Client) do(req *http.Request, out interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,65 @@
+package googleauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestSignIn(t *testing.T) {
+	const verifier = "verifier-0123456789"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			r.ParseForm()
+			if r.PostForm.Get("code") != "code-1" || r.PostForm.Get("code_verifier") != verifier || r.PostForm.Get("client_secret") != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":"invalid_grant"}`)
+				return
+			}
+			io.WriteString(w, `{"access_token":"tok","token_type":"Bearer","expires_in":3599}`)
+		case "/userinfo":
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, `{"sub":"1234","email":"ops@example.com","email_verified":true}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient("client-id", "secret", "https://api.example.com/auth/google/callback")
+	client.tokenURL, client.userInfoURL = srv.URL+"/token", srv.URL+"/userinfo"
+
+	u, err := url.Parse(client.AuthCodeURL("state-1", verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	sum := sha256.Sum256([]byte(verifier))
+	if q.Get("state") != "state-1" || q.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(sum[:]) || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("auth url query = %v, want the state and the S256 challenge of the verifier", q)
+	}
+	if q.Get("redirect_uri") != "https://api.example.com/auth/google/callback" || q.Get("scope") != "openid email" {
+		t.Errorf("auth url query = %v, want the redirect url and the openid email scope", q)
+	}
+
+	info, err := client.Exchange(context.Background(), "code-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *info != (UserInfo{Subject: "1234", Email: "ops@example.com", EmailVerified: true}) {
+		t.Errorf("userinfo = %+v", info)
+	}
+
+	if _, err := client.Exchange(context.Background(), "code-1", "other-verifier"); err == nil {
+		t.Error("Exchange with a wrong verifier succeeded, want an error")
+	}
+}
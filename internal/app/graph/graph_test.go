@@ -0,0 +1,135 @@
+package graph
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+	"excavation_service/internal/app/testdb"
+	"excavation_service/internal/app/week"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// postは、テナントtnのAPIキーで認証した後と同じコンテキストでクエリを送り、応答を返します。
+func post(t *testing.T, h http.Handler, tn *model.Tenant, query string) response {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if tn != nil {
+		req = req.WithContext(tenant.NewContext(req.Context(), tn))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var resp response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	return resp
+}
+
+// TestArgumentsは件数と種別の引数の誤りと、件数の積が大きすぎるクエリをDBを読まずに拒否することを確認します。
+func TestArguments(t *testing.T) {
+	h := NewHandler(repository.NewTopicRepository(nil), repository.NewTrendRepository(nil))
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"limit zero", `{ entities(limit: 0) { id } }`, "limit must be between 1 and 200"},
+		{"limit too large", `{ entities(limit: 201) { id } }`, "limit must be between 1 and 200"},
+		{"unknown type", `{ entities(type: "hotel") { id } }`, "type must be any of onsen, restaurant, brand"},
+		{"too complex", `{ entities(limit: 200) { topics(limit: 200) { trends(limit: 200) { score } } } }`, "operation has complexity"},
+	}
+	for _, tt := range tests {
+		resp := post(t, h, nil, tt.query)
+		if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, tt.want) {
+			t.Errorf("%s: errors = %+v, want %q", tt.name, resp.Errors, tt.want)
+		}
+	}
+}
+
+// TestTenantQueryはエンティティとそのトピック、新しい週から順のトレンドを1回のクエリで読めること、
+// ほかのテナントのエンティティは一覧にも含まれず、IDを指定してもnullになることを確認します。
+func TestTenantQuery(t *testing.T) {
+	db := testdb.Postgres(t)
+	h := NewHandler(repository.NewTopicRepository(db), repository.NewTrendRepository(db))
+
+	thisWeek := week.Of(time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC))
+	curry := testdb.Topic(t, db, "スパイス食堂", "渋谷 カレー")
+	testdb.Trend(t, db, curry.ID, thisWeek.AddDate(0, 0, -7), 60)
+	testdb.Trend(t, db, curry.ID, thisWeek, 72)
+
+	other := model.Tenant{Slug: "other", Name: "取引先"}
+	if err := db.Create(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+	ramen := testdb.Topic(t, db, "中華そば 青葉", "中野 ラーメン")
+	if err := db.Model(&model.Entity{}).Where("id = ?", ramen.EntityID).Update("tenant_id", other.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&model.EntityTopic{}).Where("id = ?", ramen.ID).Update("tenant_id", other.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	defaultTenant := &model.Tenant{ID: model.DefaultTenantID}
+	resp := post(t, h, defaultTenant, `{
+		entities { name topics { topic latestWeek trends(limit: 2) { week score } } }
+		other: entity(id: `+strconv.FormatUint(uint64(ramen.EntityID), 10)+`) { name }
+	}`)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("errors = %+v", resp.Errors)
+	}
+	var data struct {
+		Entities []struct {
+			Name   string
+			Topics []struct {
+				Topic      string
+				LatestWeek string
+				Trends     []struct {
+					Week  string
+					Score float64
+				}
+			}
+		}
+		Other *struct{ Name string }
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Entities) != 1 || data.Entities[0].Name != "スパイス食堂" || len(data.Entities[0].Topics) != 1 {
+		t.Fatalf("entities = %+v, want only the default tenant's entity with its topic", data.Entities)
+	}
+	topic := data.Entities[0].Topics[0]
+	lastWeek := thisWeek.AddDate(0, 0, -7).Format(week.Layout)
+	if topic.LatestWeek != thisWeek.Format(week.Layout) || len(topic.Trends) != 2 ||
+		topic.Trends[0].Week != thisWeek.Format(week.Layout) || topic.Trends[0].Score != 72 || topic.Trends[1].Week != lastWeek {
+		t.Errorf("topic = %+v, want the trends of %s and %s, newest first", topic, thisWeek.Format(week.Layout), lastWeek)
+	}
+	if data.Other != nil {
+		t.Errorf("other tenant's entity = %+v, want null", data.Other)
+	}
+
+	resp = post(t, h, &other, `{ entities { name } }`)
+	if len(resp.Errors) > 0 || !strings.Contains(string(resp.Data), "中華そば 青葉") || strings.Contains(string(resp.Data), "スパイス食堂") {
+		t.Errorf("other tenant's entities = %s %+v, want only its own entity", resp.Data, resp.Errors)
+	}
+}
@@ -0,0 +1,83 @@
+// Package graph はエンティティ・トピック・トレンドをまとめて取得するGraphQL APIを提供します。
+// フロントエンドがエンティティとそのトピック、最新のトレンドを1回のリクエストで取得するために使います。
+// スキーマはschema.graphqlsで、generated.goとschema.resolvers.goの枠はgqlgenで生成します。
+package graph
+
+//go:generate go tool gqlgen generate --config gqlgen.yml
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// 一覧の1回の件数の上限。RESTのAPIのID順の一覧と同じです
+const maxListLimit = 200
+
+// maxComplexity は1回のクエリの複雑さの上限です。一覧のフィールドは件数(limit)倍に数えるため、
+// 入れ子の一覧の件数の積がおよそこの値を超えるクエリは実行せずに拒否します。
+const maxComplexity = 50000
+
+// Resolver はGraphQLのクエリを解決します。データはリクエストのテナントのもののみ返します。
+type Resolver struct {
+	topics *repository.TopicRepository
+	trends *repository.TrendRepository
+}
+
+// tenantTopicsはリクエストのテナントのエンティティ・トピックを扱うリポジトリを返します。
+func (r *Resolver) tenantTopics(ctx context.Context) *repository.TopicRepository {
+	return r.topics.WithContext(ctx).ForTenant(tenant.ID(ctx))
+}
+
+// tenantTrendsはリクエストのテナントのトレンドを扱うリポジトリを返します。
+func (r *Resolver) tenantTrends(ctx context.Context) *repository.TrendRepository {
+	return r.trends.WithContext(ctx).ForTenant(tenant.ID(ctx))
+}
+
+// NewHandlerはGraphQLのクエリをPOSTのJSONで受け付けるハンドラーを返します。
+// テナントはリクエストのコンテキストから読むため、handler.TenantAuthの後に登録します。
+func NewHandler(topics *repository.TopicRepository, trends *repository.TrendRepository) http.Handler {
+	c := Config{Resolvers: &Resolver{topics: topics, trends: trends}}
+	c.Complexity.Query.Entities = func(child int, _ *string, _ *string, limit *int, _ *uint) int {
+		return listComplexity(child, limit)
+	}
+	c.Complexity.Entity.Topics = func(child int, limit *int, _ *uint) int { return listComplexity(child, limit) }
+	c.Complexity.Topic.Trends = func(child int, limit *int) int { return listComplexity(child, limit) }
+
+	srv := handler.New(NewExecutableSchema(c))
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(maxComplexity))
+	return srv
+}
+
+// listComplexityは件数limitの一覧の複雑さを、要素の複雑さchildの件数倍として返します。
+func listComplexity(child int, limit *int) int {
+	if limit == nil || *limit <= 0 {
+		return child
+	}
+	return child * *limit
+}
+
+// listLimitは一覧の件数の引数を検証します。
+func listLimit(limit *int) (int, error) {
+	if limit == nil || *limit <= 0 || *limit > maxListLimit {
+		return 0, errors.New("limit must be between 1 and 200")
+	}
+	return *limit, nil
+}
+
+// afterCursorは一覧の引数after(前のページの最後のID)をID順の一覧のcursorに変換します。
+func afterCursor(after *uint) repository.Cursor {
+	if after == nil {
+		return repository.Cursor{}
+	}
+	return repository.Cursor{ID: *after}
+}
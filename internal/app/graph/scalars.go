@@ -0,0 +1,28 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"excavation_service/internal/app/week"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// MarshalDateは週・日付をweek.Layoutの文字列で返します。RESTのAPIの週と同じ形式です。
+func MarshalDate(t time.Time) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(t.Format(week.Layout)))
+	})
+}
+
+// UnmarshalDateはweek.Layoutの文字列を週のタイムゾーンの日付として読みます。
+func UnmarshalDate(v any) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("date must be a string in the format %s", week.Layout)
+	}
+	return time.ParseInLocation(week.Layout, s, week.Location())
+}
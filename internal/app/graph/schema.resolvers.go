@@ -0,0 +1,95 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.78
+
+import (
+	"context"
+	"errors"
+	"excavation_service/internal/app/logging"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Topics is the resolver for the topics field.
+func (r *entityResolver) Topics(ctx context.Context, obj *model.Entity, limit *int, after *uint) ([]repository.TopicListItem, error) {
+	n, err := listLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	items, err := r.tenantTopics(ctx).ListEntityTopics(obj.ID, afterCursor(after), n)
+	if err != nil {
+		logging.FromContext(ctx).Error("トピックの一覧取得失敗", "error", err)
+		return nil, errors.New("failed to list topics")
+	}
+	return items, nil
+}
+
+// Entities is the resolver for the entities field.
+func (r *queryResolver) Entities(ctx context.Context, typeArg *string, query *string, limit *int, after *uint) ([]model.Entity, error) {
+	n, err := listLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	var f repository.EntityFilter
+	if typeArg != nil {
+		if !slices.Contains(model.EntityTypes, *typeArg) {
+			return nil, errors.New("type must be any of " + strings.Join(model.EntityTypes, ", "))
+		}
+		f.Type = *typeArg
+	}
+	if query != nil {
+		f.Query = strings.TrimSpace(*query)
+	}
+	entities, err := r.tenantTopics(ctx).ListEntities(f, afterCursor(after), n)
+	if err != nil {
+		logging.FromContext(ctx).Error("エンティティの一覧取得失敗", "error", err)
+		return nil, errors.New("failed to list entities")
+	}
+	return entities, nil
+}
+
+// Entity is the resolver for the entity field.
+func (r *queryResolver) Entity(ctx context.Context, id uint) (*model.Entity, error) {
+	entity, err := r.tenantTopics(ctx).GetEntity(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("エンティティの取得失敗", "error", err)
+		return nil, errors.New("failed to get entity")
+	}
+	return entity, nil
+}
+
+// Trends is the resolver for the trends field.
+func (r *topicResolver) Trends(ctx context.Context, obj *repository.TopicListItem, limit *int) ([]repository.TrendExportRow, error) {
+	n, err := listLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.tenantTrends(ctx).ListPage(repository.TrendExportFilter{TopicID: obj.ID}, nil, repository.Cursor{}, n)
+	if err != nil {
+		logging.FromContext(ctx).Error("トレンドの一覧取得失敗", "error", err)
+		return nil, errors.New("failed to list trends")
+	}
+	return rows, nil
+}
+
+// Entity returns EntityResolver implementation.
+func (r *Resolver) Entity() EntityResolver { return &entityResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// Topic returns TopicResolver implementation.
+func (r *Resolver) Topic() TopicResolver { return &topicResolver{r} }
+
+type entityResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type topicResolver struct{ *Resolver }
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"excavation_service/internal/app/activity"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+// maxActivityMessageBytes はクライアントから受け取るメッセージの大きさの上限です。クライアントからのメッセージは使いません。
+const maxActivityMessageBytes = 1 << 10
+
+// ActivityHandler は発掘の実行中の進捗をWebSocketで配信するエンドポイントを提供します。
+// ブラウザーからの接続はoriginsのOriginのもののみ受け付けます。
+type ActivityHandler struct {
+	hub     *activity.Hub
+	origins []string // 小文字のオリジン(例: https://ops.example.com)
+}
+
+func NewActivityHandler(hub *activity.Hub, origins []string) *ActivityHandler {
+	return &ActivityHandler{hub: hub, origins: origins}
+}
+
+// handshakeはWebSocketの接続のOriginを確認し、アクセストークンを渡したサブプロトコルを選びます。
+// 他のサイトのページからの接続を拒否するため、Originのある(ブラウザーからの)接続はoriginsのもののみ受け付けます。
+// Originのない接続はブラウザー以外のクライアントで、APIキーかアクセストークンのヘッダーで認証済みです。
+func (h *ActivityHandler) handshake(config *websocket.Config, req *http.Request) error {
+	if origin := req.Header.Get(echo.HeaderOrigin); origin != "" && !slices.Contains(h.origins, strings.ToLower(origin)) {
+		return errors.New("origin not allowed")
+	}
+	// 応答にはトークンを含めず、AccessTokenProtocolのみ返す
+	if slices.Contains(config.Protocol, AccessTokenProtocol) {
+		config.Protocol = []string{AccessTokenProtocol}
+	} else {
+		config.Protocol = nil
+	}
+	return nil
+}
+
+// Streamは接続している間、発掘の進捗(activity.Event)を1件ずつJSONのテキストメッセージで送ります。
+// 進捗はすべてのテナントのトピックのものを送るため、運用者のみ使えます。 GET /api/v1/admin/activity
+// ブラウザーからはアクセストークンをSec-WebSocket-Protocolかクエリのaccess_tokenで渡します(webSocketToken)。
+func (h *ActivityHandler) Stream(c echo.Context) error {
+	events, unsubscribe := h.hub.Subscribe()
+	defer unsubscribe()
+	server := websocket.Server{Handshake: h.handshake, Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		ws.MaxPayloadBytes = maxActivityMessageBytes
+		// クライアントの切断を検知するため、受け取ったメッセージは読み捨てる
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			var discard string
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+		}()
+		for {
+			select {
+			case <-closed:
+				return
+			case payload, ok := <-events:
+				if !ok {
+					// APIサーバーの終了
+					return
+				}
+				if err := websocket.Message.Send(ws, string(payload)); err != nil {
+					return
+				}
+			}
+		}
+	}}
+	server.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
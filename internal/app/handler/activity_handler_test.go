@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"excavation_service/internal/app/activity"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+// TestActivityHandshakeはダッシュボードのOrigin以外からの接続を拒否し、アクセストークンのサブプロトコルのみ応答に返すことを確認します。
+func TestActivityHandshake(t *testing.T) {
+	e := echo.New()
+	e.GET("/activity", NewActivityHandler(activity.NewHub(), []string{"https://ops.example.com"}).Stream)
+	server := httptest.NewServer(e)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/activity"
+
+	tests := []struct {
+		name      string
+		origin    string
+		protocols []string
+		wantErr   bool
+		want      []string // 応答のサブプロトコル
+	}{
+		{name: "dashboard", origin: "https://OPS.example.com", protocols: []string{AccessTokenProtocol, "a.b.c"}, want: []string{AccessTokenProtocol}},
+		{name: "no protocol", origin: "https://ops.example.com"},
+		{name: "other site", origin: "https://evil.example.com", protocols: []string{AccessTokenProtocol, "a.b.c"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := websocket.NewConfig(url, tt.origin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.Protocol = tt.protocols
+			ws, err := websocket.DialConfig(config)
+			if tt.wantErr {
+				if err == nil {
+					ws.Close()
+					t.Fatal("DialConfig() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DialConfig() error = %v", err)
+			}
+			defer ws.Close()
+			if got := ws.Config().Protocol; !slices.Equal(got, tt.want) {
+				t.Errorf("protocol = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		header map[string]string
+		want   string
+	}{
+		{"protocol", "/activity", map[string]string{"Upgrade": "websocket", "Sec-WebSocket-Protocol": "access_token, a.b.c"}, "a.b.c"},
+		{"query", "/activity?access_token=a.b.c", map[string]string{"Upgrade": "websocket"}, "a.b.c"},
+		{"protocol without token", "/activity", map[string]string{"Upgrade": "websocket", "Sec-WebSocket-Protocol": "access_token"}, ""},
+		// WebSocket以外のリクエストはヘッダーで渡す
+		{"not websocket", "/activity?access_token=a.b.c", nil, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		if got := webSocketToken(req); got != tt.want {
+			t.Errorf("%s: webSocketToken() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
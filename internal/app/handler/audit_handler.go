@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+)
+
+// AuditHandler は管理操作の監査ログを参照するエンドポイントを提供します。
+type AuditHandler struct {
+	repo *repository.AuditRepository
+}
+
+func NewAuditHandler(repo *repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{repo: repo}
+}
+
+// Listは新しい順に監査ログを返します。
+// GET /api/v1/admin/audit-logs?actor=cli:alice&action=topic.disable&target_type=topic&target_id=12&since=2024-06-01&limit=50&cursor=...
+// 次のページがあれば、そのcursorをX-Next-Cursorヘッダーで返します。
+func (h *AuditHandler) List(c echo.Context) error {
+	f := repository.AuditFilter{
+		Actor:      c.QueryParam("actor"),
+		Action:     c.QueryParam("action"),
+		TargetType: c.QueryParam("target_type"),
+		TargetID:   c.QueryParam("target_id"),
+		Limit:      defaultAuditLimit,
+	}
+	if s := c.QueryParam("since"); s != "" {
+		since, err := week.ParseDay(s)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "since must be YYYY-MM-DD")
+		}
+		f.Since = since
+	}
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 200")
+		}
+		f.Limit = n
+	}
+	cursor, err := pageCursor(c)
+	if err != nil {
+		return err
+	}
+	f.After = cursor
+
+	// 1件多く読み、次のページがあるかを判定する
+	limit := f.Limit
+	f.Limit++
+	logs, err := h.repo.WithContext(c.Request().Context()).List(f)
+	if err != nil {
+		logger(c).Error("監査ログ一覧取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list audit logs")
+	}
+	if len(logs) > limit {
+		logs = logs[:limit]
+		setNextCursor(c, repository.Cursor{At: logs[limit-1].CreatedAt, ID: logs[limit-1].ID})
+	}
+	if logs == nil {
+		logs = []model.AuditLog{}
+	}
+	return c.JSON(http.StatusOK, logs)
+}
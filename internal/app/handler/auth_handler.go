@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"excavation_service/internal/app/authtoken"
+	"excavation_service/internal/app/tenant"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AuthHandler はAPIキーと引き換えにアクセストークンを発行するエンドポイントを提供します。
+type AuthHandler struct {
+	tokens *authtoken.Signer
+}
+
+func NewAuthHandler(tokens *authtoken.Signer) *AuthHandler {
+	return &AuthHandler{tokens: tokens}
+}
+
+type tokenResponse struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int       `json:"expires_in"` // 有効期限までの秒数
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Tokenはリクエストのテナントとそのキーのアクセストークンを発行します。 POST /api/v1/auth/token
+// アクセストークンで新しいトークンを発行し続けられないよう、APIキーでのみ発行します。
+func (h *AuthHandler) Token(c echo.Context) error {
+	if authtoken.IsToken(apiKey(c.Request())) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "an api key is required to issue an access token")
+	}
+	ctx := c.Request().Context()
+	now := time.Now()
+	token, expires, err := h.tokens.Issue(tenant.ID(ctx), tenant.APIKeyID(ctx), now)
+	if err != nil {
+		logger(c).Error("アクセストークンの発行失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to issue access token")
+	}
+	logger(c).Info("アクセストークンを発行しました", "tenant_id", tenant.ID(ctx), "api_key_id", tenant.APIKeyID(ctx), "expires_at", expires)
+	return c.JSON(http.StatusOK, tokenResponse{AccessToken: token, TokenType: "Bearer", ExpiresIn: int(expires.Sub(now).Seconds()), ExpiresAt: expires})
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/i18n"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// DigestHandler は週次ダイジェストメールの購読と配信停止のエンドポイントを提供します。
+type DigestHandler struct {
+	repo *repository.DigestRepository
+}
+
+func NewDigestHandler(repo *repository.DigestRepository) *DigestHandler {
+	return &DigestHandler{repo: repo}
+}
+
+type subscribeRequest struct {
+	Email      string `json:"email"`
+	LineUserID string `json:"line_user_id"` // 指定するとメールの代わりにLINEで受け取る
+}
+
+// Subscribeは週次ダイジェストを購読します。配信を停止していた場合は再開します。
+// 購読済みのemailで呼ぶと、受け取り方(メールかLINEか)をリクエストの内容に更新します。
+// 宛先の本人の確認は行わないため、運用者のwriteのスコープのAPIキーのみ呼び出せます。 POST /api/v1/digest/subscriptions
+func (h *DigestHandler) Subscribe(c echo.Context) error {
+	var req subscribeRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+		return echo.NewHTTPError(http.StatusBadRequest, "email must be a valid address")
+	}
+	req.LineUserID = strings.TrimSpace(req.LineUserID)
+	if req.LineUserID != "" && !notify.ValidLINEUserID(req.LineUserID) {
+		return echo.NewHTTPError(http.StatusBadRequest, "line_user_id must be a LINE user ID")
+	}
+
+	sub, err := h.repo.WithContext(c.Request().Context()).Subscribe(req.Email, req.LineUserID)
+	if err != nil {
+		logger(c).Error("ダイジェストの購読登録失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to subscribe")
+	}
+	return c.JSON(http.StatusCreated, sub)
+}
+
+// Unsubscribeは配信停止のリンクのtokenの購読を停止します。
+// メールのリンク(GET)とワンクリックでの配信停止(POST、RFC 8058)の両方で呼ばれます。
+// GET/POST /api/v1/digest/unsubscribe?token=...
+func (h *DigestHandler) Unsubscribe(c echo.Context) error {
+	token := c.QueryParam("token")
+	if token == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "token is required")
+	}
+	if _, err := h.repo.WithContext(c.Request().Context()).Unsubscribe(token, time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "subscription not found")
+		}
+		logger(c).Error("ダイジェストの配信停止失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to unsubscribe")
+	}
+	return c.String(http.StatusOK, i18n.Text(lang(c, i18n.Ja), "digest.unsubscribed"))
+}
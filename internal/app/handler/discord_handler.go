@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"excavation_service/internal/app/discord"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxInteractionBytes はDiscordから受け取るInteractionの本文の上限です。
+const maxInteractionBytes = 64 << 10
+
+// DiscordHandler はDiscordのスラッシュコマンドに応答するエンドポイントを提供します。
+type DiscordHandler struct {
+	trends    *repository.TrendRepository
+	publicKey ed25519.PublicKey
+}
+
+func NewDiscordHandler(trends *repository.TrendRepository, publicKey ed25519.PublicKey) *DiscordHandler {
+	return &DiscordHandler{trends: trends, publicKey: publicKey}
+}
+
+// RegisterDiscordはDiscordのInteractions Endpointを登録します。publicKeyはDiscordのアプリケーションの公開鍵です。
+// ランキングは既定のテナントのトピックのみ返します。
+func RegisterDiscord(e *echo.Echo, db *gorm.DB, publicKey ed25519.PublicKey) {
+	h := NewDiscordHandler(repository.NewTrendRepository(db).ForTenant(model.DefaultTenantID), publicKey)
+	e.POST("/api/v1/discord/interactions", h.Interactions)
+}
+
+// InteractionsはDiscordから送られたInteractionに応答します。 POST /api/v1/discord/interactions
+// 署名を検証できないリクエストには401を返します(Discordは登録時に不正な署名のリクエストで確認します)。
+func (h *DiscordHandler) Interactions(c echo.Context) error {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxInteractionBytes))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	req := c.Request()
+	if !discord.Verify(h.publicKey, req.Header.Get("X-Signature-Ed25519"), req.Header.Get("X-Signature-Timestamp"), body) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid request signature")
+	}
+	var interaction discord.Interaction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	switch interaction.Type {
+	case discord.InteractionPing:
+		return c.JSON(http.StatusOK, discord.Response{Type: discord.ResponsePong})
+	case discord.InteractionApplicationCommand:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "unsupported interaction type")
+	}
+	if interaction.Data.Name != discord.TrendCommand {
+		return c.JSON(http.StatusOK, discord.Reply("対応していないコマンドです。", true))
+	}
+	query := interaction.Query()
+	if query == "" {
+		return c.JSON(http.StatusOK, discord.Reply("検索語を指定してください (例: /trend 西日暮里)", true))
+	}
+	items, err := h.trends.WithContext(req.Context()).SearchLatest(query, discord.TrendReplyLimit)
+	if err != nil {
+		logger(c).Error("Discordのコマンドのトレンド検索失敗", "query", query, "error", err)
+		return c.JSON(http.StatusOK, discord.Reply("トレンドを取得できませんでした。しばらくしてから再度お試しください。", true))
+	}
+	return c.JSON(http.StatusOK, discord.Reply(discord.TrendReply(query, items), false))
+}
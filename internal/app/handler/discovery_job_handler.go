@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/queue"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// DiscoveryJobHandler は発掘をscheduleの次の確認を待たずに依頼する管理用エンドポイントを提供します。
+// 依頼はscheduleと同じくキューに送り、consumeが受け取って発掘します。
+type DiscoveryJobHandler struct {
+	topics *repository.TopicRepository
+	audit  *repository.AuditRepository
+	queue  queue.Sender // nilの場合はキューが設定されていない
+}
+
+func NewDiscoveryJobHandler(topics *repository.TopicRepository, audit *repository.AuditRepository, q queue.Sender) *DiscoveryJobHandler {
+	return &DiscoveryJobHandler{topics: topics, audit: audit, queue: q}
+}
+
+type discoveryJobRequest struct {
+	TopicID uint `json:"topic_id"`
+	All     bool `json:"all"`
+}
+
+type discoveryJobResponse struct {
+	TopicIDs []uint `json:"topic_ids"`
+	Enqueued int    `json:"enqueued"`
+}
+
+// Enqueueはトピックの発掘の依頼をキューに送ります。topic_idでトピックを指定するか、allで有効なすべてのトピックを優先度の高い順に依頼します。
+// 今週のトレンドがあるトピックも依頼し、発掘すると同じ週のトレンドを置き換えます。依頼は監査ログに記録します。
+// POST /api/v1/admin/jobs/trend-discovery
+func (h *DiscoveryJobHandler) Enqueue(c echo.Context) error {
+	if h.queue == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "topic queue is not configured")
+	}
+	var req discoveryJobRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	switch {
+	case req.TopicID != 0 && req.All:
+		return echo.NewHTTPError(http.StatusBadRequest, "specify either topic_id or all, not both")
+	case req.TopicID == 0 && !req.All:
+		return echo.NewHTTPError(http.StatusBadRequest, "topic_id or all is required")
+	}
+
+	// 運用者はすべてのテナントのトピックを依頼できる
+	ctx := c.Request().Context()
+	topics := h.topics.WithContext(ctx)
+	var topicIDs []uint
+	if req.All {
+		ids, err := topics.ListEnabled(week.Current())
+		if err != nil {
+			logger(c).Error("トピックの一覧取得失敗", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to list topics")
+		}
+		topicIDs = ids
+	} else {
+		topic, err := topics.GetTopic(req.TopicID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "topic not found")
+			}
+			logger(c).Error("トピックの取得失敗", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get topic")
+		}
+		if topic.DisabledAt != nil {
+			return echo.NewHTTPError(http.StatusConflict, "topic is disabled")
+		}
+		topicIDs = []uint{topic.ID}
+	}
+
+	// 送れなかった依頼があれば残りは送らず、送った依頼のみ記録する
+	sent := make([]uint, 0, len(topicIDs))
+	var sendErr error
+	for _, id := range topicIDs {
+		body, err := json.Marshal(queue.TopicRequest{TopicID: id})
+		if err == nil {
+			err = h.queue.Send(ctx, string(body))
+		}
+		if err != nil {
+			sendErr = err
+			break
+		}
+		sent = append(sent, id)
+	}
+	if len(sent) > 0 {
+		after := map[string]interface{}{"topic_ids": sent, "all": req.All}
+		if err := h.audit.WithContext(ctx).Record(apiActor(c), model.AuditRunEnqueue, "topic_queue", nil, nil, after); err != nil {
+			logger(c).Error("監査ログの記録失敗", "error", err)
+		}
+	}
+	if sendErr != nil {
+		logger(c).Error("発掘の依頼の送信失敗", "sent", len(sent), "error", sendErr)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to enqueue trend discovery")
+	}
+	logger(c).Info("発掘の依頼をキューに送りました", "topics", len(sent), "all", req.All)
+	return c.JSON(http.StatusAccepted, discoveryJobResponse{TopicIDs: sent, Enqueued: len(sent)})
+}
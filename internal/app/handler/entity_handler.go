@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// EntityHandler はエンティティ(温泉・飲食店・ブランドなど、トピックをまとめる対象)のエンドポイントを提供します。
+// エンティティはリクエストのテナントのもののみ扱います。
+type EntityHandler struct {
+	topics *repository.TopicRepository
+}
+
+func NewEntityHandler(topics *repository.TopicRepository) *EntityHandler {
+	return &EntityHandler{topics: topics}
+}
+
+// tenantRepoはリクエストのテナントのエンティティを扱うリポジトリを返します。
+func (h *EntityHandler) tenantRepo(c echo.Context) *repository.TopicRepository {
+	ctx := c.Request().Context()
+	return h.topics.WithContext(ctx).ForTenant(tenant.ID(ctx))
+}
+
+type entityRequest struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// validateはエンティティのリクエストを検証し、エラーの内容を返します。問題がなければ空文字列です。
+func (req *entityRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	switch {
+	case req.Name == "":
+		return "name is required"
+	case !slices.Contains(model.EntityTypes, req.Type):
+		return "type must be any of " + strings.Join(model.EntityTypes, ", ")
+	}
+	return ""
+}
+
+type entityResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newEntityResponse(e model.Entity) entityResponse {
+	return entityResponse{ID: e.ID, Name: e.Name, Type: e.Type, CreatedAt: e.CreatedAt, UpdatedAt: e.UpdatedAt}
+}
+
+// Listはエンティティの一覧を返します。 GET /api/v1/entities?type=onsen&q=箱根&sort=-created_at&limit=100&cursor=...
+// qは名前の部分一致、sortはカンマ区切りの並べ替える項目(-を付けると降順)で、省略した場合はID順です。
+// 次のページがあれば、そのcursorをX-Next-Cursorヘッダーで返します。次のページは同じsortとともにcursorに指定して取得します。
+func (h *EntityHandler) List(c echo.Context) error {
+	f := repository.EntityFilter{Type: c.QueryParam("type"), Query: strings.TrimSpace(c.QueryParam("q"))}
+	if f.Type != "" && !slices.Contains(model.EntityTypes, f.Type) {
+		return echo.NewHTTPError(http.StatusBadRequest, "type must be any of "+strings.Join(model.EntityTypes, ", "))
+	}
+	var err error
+	if f.Sort, err = parseSort(c, repository.EntitySortFields); err != nil {
+		return err
+	}
+	limit, err := listLimit(c)
+	if err != nil {
+		return err
+	}
+	cursor, err := pageCursor(c)
+	if err != nil {
+		return err
+	}
+	entities, err := h.tenantRepo(c).ListEntities(f, cursor, limit+1)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+	}
+	if err != nil {
+		logger(c).Error("エンティティの一覧取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list entities")
+	}
+	if len(entities) > limit {
+		entities = entities[:limit]
+		setNextCursor(c, repository.EntityCursor(entities[limit-1], f.Sort))
+	}
+	resp := make([]entityResponse, len(entities))
+	for i, e := range entities {
+		resp[i] = newEntityResponse(e)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Getはエンティティを返します。 GET /api/v1/entities/:id
+func (h *EntityHandler) Get(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	entity, err := h.tenantRepo(c).GetEntity(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "entity not found")
+		}
+		logger(c).Error("エンティティの取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get entity")
+	}
+	return c.JSON(http.StatusOK, newEntityResponse(*entity))
+}
+
+// Createはエンティティを登録します。同じ名前と種別のエンティティは1つまでです。 POST /api/v1/entities
+func (h *EntityHandler) Create(c echo.Context) error {
+	var req entityRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if msg := req.validate(); msg != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, msg)
+	}
+
+	entity := model.Entity{Name: req.Name, Type: req.Type}
+	if err := h.tenantRepo(c).CreateEntity(&entity); err != nil {
+		if errors.Is(err, repository.ErrEntityExists) {
+			return echo.NewHTTPError(http.StatusConflict, "an entity with this name and type already exists")
+		}
+		logger(c).Error("エンティティの登録失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create entity")
+	}
+	return c.JSON(http.StatusCreated, newEntityResponse(entity))
+}
+
+// Updateはエンティティの名前と種別を置き換えます。 PUT /api/v1/entities/:id
+func (h *EntityHandler) Update(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	var req entityRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if msg := req.validate(); msg != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, msg)
+	}
+
+	repo := h.tenantRepo(c)
+	entity := model.Entity{ID: uint(id), Name: req.Name, Type: req.Type}
+	if err := repo.UpdateEntity(&entity); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "entity not found")
+		case errors.Is(err, repository.ErrEntityExists):
+			return echo.NewHTTPError(http.StatusConflict, "an entity with this name and type already exists")
+		}
+		logger(c).Error("エンティティの更新失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update entity")
+	}
+	updated, err := repo.GetEntity(uint(id))
+	if err != nil {
+		logger(c).Error("エンティティの取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get entity")
+	}
+	return c.JSON(http.StatusOK, newEntityResponse(*updated))
+}
+
+// Deleteはエンティティを削除します。トピックのあるエンティティは、トレンドを誤って消さないよう削除できません。
+// DELETE /api/v1/entities/:id
+func (h *EntityHandler) Delete(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	if err := h.tenantRepo(c).DeleteEntity(uint(id)); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "entity not found")
+		case errors.Is(err, repository.ErrEntityHasTopics):
+			return echo.NewHTTPError(http.StatusConflict, "entity has topics")
+		}
+		logger(c).Error("エンティティの削除失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete entity")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+
+	"excavation_service/internal/app/i18n"
+	"excavation_service/internal/app/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+type entityTypeResponse struct {
+	Type  string `json:"type"`
+	Label string `json:"label"`
+}
+
+// EntityTypesはエンティティの種別とその表示名の一覧を返します。表示名はAccept-Languageの言語(既定は日本語)です。
+// GET /api/v1/entity-types
+func EntityTypes(c echo.Context) error {
+	l := lang(c, i18n.Ja)
+	types := make([]entityTypeResponse, len(model.EntityTypes))
+	for i, t := range model.EntityTypes {
+		types[i] = entityTypeResponse{Type: t, Label: i18n.EntityTypeLabel(l, t)}
+	}
+	return c.JSON(http.StatusOK, types)
+}
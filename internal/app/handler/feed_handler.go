@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/feed"
+	"excavation_service/internal/app/i18n"
+	"excavation_service/internal/app/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	feedWeeks       = 4  // フィードに載せる週の数 (トレンドが存在する最新の週から)
+	feedLimit       = 50 // フィードに載せるエントリーの最大数
+	feedStoresLimit = 3  // エントリーごとに載せる店舗の数
+	feedMaxAge      = 15 * time.Minute
+)
+
+// FeedHandler はトレンドのフィードを提供します。
+type FeedHandler struct {
+	trends *repository.TrendRepository
+	stores *repository.StoreRepository
+}
+
+func NewFeedHandler(trends *repository.TrendRepository, stores *repository.StoreRepository) *FeedHandler {
+	return &FeedHandler{trends: trends, stores: stores}
+}
+
+// Trendsは最近の週のスコアの高いトピックと、言及の多かった店舗(食べログなどの店舗ページへのリンク)をAtomフィードで返します。
+// areaを指定するとトピック名にそのエリアを含むトピック、min_scoreを指定するとスコアがその値以上のトレンドのみ載せます。
+// GET /feeds/trends.atom?area=渋谷&min_score=70 (/feeds/trends.xmlも同じ)
+func (h *FeedHandler) Trends(c echo.Context) error {
+	ctx := c.Request().Context()
+	area := strings.TrimSpace(c.QueryParam("area"))
+	var minScore float64
+	if s := c.QueryParam("min_score"); s != "" {
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "min_score must be a number")
+		}
+		minScore = n
+	}
+	l := lang(c, i18n.Ja)
+	title := i18n.Text(l, "feed.title")
+	if area != "" {
+		title += " (" + area + ")"
+	}
+
+	trendRepo := h.trends.WithContext(ctx)
+	latest, err := trendRepo.LatestWeek()
+	if err != nil {
+		logger(c).Error("最新週の取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to build feed")
+	}
+	var trends []repository.FeedTrend
+	if latest != nil {
+		trends, err = trendRepo.Feed(latest.AddDate(0, 0, -7*(feedWeeks-1)), area, minScore, feedLimit)
+		if err != nil {
+			logger(c).Error("フィードのトレンド取得失敗", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to build feed")
+		}
+	}
+	storeRepo := h.stores.WithContext(ctx)
+	stores := make(map[uint][]repository.StoreMentionCount, len(trends))
+	for _, t := range trends {
+		if stores[t.ID], err = storeRepo.MostMentioned(t.TopicID, t.Week, feedStoresLimit); err != nil {
+			logger(c).Error("フィードの店舗取得失敗", "topic_id", t.TopicID, "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to build feed")
+		}
+	}
+
+	selfURL := c.Scheme() + "://" + c.Request().Host + c.Request().URL.RequestURI()
+	body, err := feed.Build(l, title, selfURL, trends, stores, time.Now()).Marshal()
+	if err != nil {
+		logger(c).Error("フィードの生成失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to build feed")
+	}
+	// トレンドは週に1回しか変わらないため、フィードリーダーからの頻繁な取得はキャッシュで返す
+	c.Response().Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(feedMaxAge.Seconds())))
+	return c.Blob(http.StatusOK, feed.ContentType, body)
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"excavation_service/internal/app/authtoken"
+	"excavation_service/internal/app/googleauth"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/ratelimit"
+	"excavation_service/internal/app/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	googleStateCookie    = "google_signin_state"
+	googleVerifierCookie = "google_signin_verifier"
+	googleCookiePath     = "/auth/google"
+	googleSignInTimeout  = 10 * time.Minute // サインインの開始からGoogleから戻るまでの猶予
+)
+
+// GoogleSignIn はGoogleアカウントでのサインインのクライアントです。googleauth.Clientが満たします。
+type GoogleSignIn interface {
+	AuthCodeURL(state, verifier string) string
+	Exchange(ctx context.Context, code, verifier string) (*googleauth.UserInfo, error)
+}
+
+// GoogleSignInHandler はGoogleアカウントでサインインし、紐付いたAPIキーのアクセストークンを発行するエンドポイントを提供します。
+// アカウントとAPIキーの紐付けは運用者が excavation tenants keys link-google で行います。
+type GoogleSignInHandler struct {
+	google     GoogleSignIn
+	identities *repository.OAuthIdentityRepository
+	tenants    *repository.TenantRepository
+	tokens     *authtoken.Signer
+}
+
+func NewGoogleSignInHandler(google GoogleSignIn, identities *repository.OAuthIdentityRepository, tenants *repository.TenantRepository, tokens *authtoken.Signer) *GoogleSignInHandler {
+	return &GoogleSignInHandler{google: google, identities: identities, tenants: tenants, tokens: tokens}
+}
+
+// RegisterGoogleSignInはGoogleアカウントでのサインインのエンドポイントを登録します。
+// APIキーなしで呼ばれるため、limiterがnilでなければ送信元のIPアドレスごとにリクエスト数を制限します。
+func RegisterGoogleSignIn(e *echo.Echo, db *gorm.DB, google GoogleSignIn, tokens *authtoken.Signer, limiter *ratelimit.Limiter) {
+	h := NewGoogleSignInHandler(google, repository.NewOAuthIdentityRepository(db), repository.NewTenantRepository(db), tokens)
+	var middlewares []echo.MiddlewareFunc
+	if limiter != nil {
+		middlewares = append(middlewares, RateLimit(limiter))
+	}
+	g := e.Group(googleCookiePath, middlewares...)
+	g.GET("/login", h.Login)
+	g.GET("/callback", h.Callback)
+}
+
+// LoginはGoogleのサインインの画面にリダイレクトします。 GET /auth/google/login
+// stateとPKCEのverifierはブラウザーのCookieに保持し、Callbackで照合します。
+func (h *GoogleSignInHandler) Login(c echo.Context) error {
+	state, err := googleauth.NewVerifier()
+	if err != nil {
+		logger(c).Error("Googleのサインインの開始失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start google sign-in")
+	}
+	verifier, err := googleauth.NewVerifier()
+	if err != nil {
+		logger(c).Error("Googleのサインインの開始失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start google sign-in")
+	}
+	c.SetCookie(googleCookie(googleStateCookie, state, int(googleSignInTimeout.Seconds())))
+	c.SetCookie(googleCookie(googleVerifierCookie, verifier, int(googleSignInTimeout.Seconds())))
+	return c.Redirect(http.StatusFound, h.google.AuthCodeURL(state, verifier))
+}
+
+// CallbackはGoogleから戻ったブラウザーの認可コードでアカウントを確認し、紐付いたAPIキーのアクセストークンを発行します。
+// GET /auth/google/callback
+// メールアドレスが確認済みで、APIキーに紐付いたアカウントのみ受け付けます。紐付いたAPIキーが失効していれば拒否します。
+func (h *GoogleSignInHandler) Callback(c echo.Context) error {
+	state, _ := c.Cookie(googleStateCookie)
+	verifier, _ := c.Cookie(googleVerifierCookie)
+	c.SetCookie(googleCookie(googleStateCookie, "", -1))
+	c.SetCookie(googleCookie(googleVerifierCookie, "", -1))
+	if state == nil || verifier == nil || subtle.ConstantTimeCompare([]byte(state.Value), []byte(c.QueryParam("state"))) != 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid sign-in state")
+	}
+	if c.QueryParam("error") != "" || c.QueryParam("code") == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "google sign-in was not completed")
+	}
+
+	ctx := c.Request().Context()
+	info, err := h.google.Exchange(ctx, c.QueryParam("code"), verifier.Value)
+	if err != nil {
+		logger(c).Warn("Googleの認可コードの交換失敗", "error", err)
+		return echo.NewHTTPError(http.StatusBadGateway, "failed to sign in with google")
+	}
+	if !info.EmailVerified {
+		return echo.NewHTTPError(http.StatusForbidden, "google account email is not verified")
+	}
+	now := time.Now()
+	identity, err := h.identities.WithContext(ctx).SignIn(model.OAuthProviderGoogle, info.Subject, info.Email, now)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger(c).Info("APIキーに紐付いていないGoogleアカウントのサインインを拒否しました", "email", info.Email)
+		return echo.NewHTTPError(http.StatusForbidden, "google account is not linked to an api key")
+	}
+	if err != nil {
+		logger(c).Error("Googleアカウントの紐付けの取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to authenticate")
+	}
+	t, key, err := h.tenants.WithContext(ctx).AuthenticateKey(identity.APIKeyID, now)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger(c).Info("失効したAPIキーに紐付いたGoogleアカウントのサインインを拒否しました", "email", info.Email, "api_key_id", identity.APIKeyID)
+		return echo.NewHTTPError(http.StatusForbidden, "google account is not linked to an api key")
+	}
+	if err != nil {
+		logger(c).Error("APIキーの取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to authenticate")
+	}
+	token, expires, err := h.tokens.Issue(t.ID, key.ID, now)
+	if err != nil {
+		logger(c).Error("アクセストークンの発行失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to issue access token")
+	}
+	logger(c).Info("Googleアカウントでサインインしアクセストークンを発行しました", "tenant_id", t.ID, "api_key_id", key.ID, "identity_id", identity.ID, "expires_at", expires)
+	return c.JSON(http.StatusOK, tokenResponse{AccessToken: token, TokenType: "Bearer", ExpiresIn: int(expires.Sub(now).Seconds()), ExpiresAt: expires})
+}
+
+// googleCookieはサインインの途中の値を保持するCookieを返します。maxAgeが負ならCookieを削除します。
+// Googleからのリダイレクト(トップレベルのGET)で送られるようSameSite=Laxにします。
+func googleCookie(name, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     googleCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
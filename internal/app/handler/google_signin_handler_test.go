@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/authtoken"
+	"excavation_service/internal/app/googleauth"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/testdb"
+
+	"github.com/labstack/echo/v4"
+)
+
+// fakeGoogle は認可コードごとに決まったアカウントでサインインさせるGoogleSignInです。
+type fakeGoogle struct {
+	accounts map[string]googleauth.UserInfo
+}
+
+func (f fakeGoogle) AuthCodeURL(state, verifier string) string {
+	return "https://accounts.example.com/auth?" + url.Values{"state": {state}}.Encode()
+}
+
+func (f fakeGoogle) Exchange(ctx context.Context, code, verifier string) (*googleauth.UserInfo, error) {
+	info, ok := f.accounts[code]
+	if !ok || verifier == "" {
+		return nil, errors.New("invalid_grant")
+	}
+	return &info, nil
+}
+
+// TestGoogleSignInはサインインの開始からトークンの発行までと、紐付いていないアカウント・失効したAPIキーの拒否を確認します。
+func TestGoogleSignIn(t *testing.T) {
+	db := testdb.Postgres(t)
+	tenants := repository.NewTenantRepository(db)
+	identities := repository.NewOAuthIdentityRepository(db)
+	_, linkedKey := createKey(t, tenants, model.DefaultTenantID, model.ScopeRead)
+	_, revokedKey := createKey(t, tenants, model.DefaultTenantID, model.ScopeRead)
+	if _, err := identities.Link(model.OAuthProviderGoogle, "Ops@Example.com", linkedKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := identities.Link(model.OAuthProviderGoogle, "former@example.com", revokedKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := tenants.RevokeAPIKey(revokedKey.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := authtoken.NewSigner("0123456789abcdef0123456789abcdef", 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	google := fakeGoogle{accounts: map[string]googleauth.UserInfo{
+		"linked":     {Subject: "1", Email: "ops@example.com", EmailVerified: true},
+		"unverified": {Subject: "2", Email: "ops@example.com"},
+		"unlinked":   {Subject: "3", Email: "someone@example.com", EmailVerified: true},
+		"revoked":    {Subject: "4", Email: "former@example.com", EmailVerified: true},
+		// 紐付け後に同じメールアドレスでサインインした別のアカウント
+		"other": {Subject: "5", Email: "ops@example.com", EmailVerified: true},
+	}}
+	e := echo.New()
+	RegisterGoogleSignIn(e, db, google, signer, nil)
+
+	// 開始するとstateとverifierのCookieを設定してGoogleにリダイレクトする
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/google/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status = %d, want 302", rec.Code)
+	}
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	state := location.Query().Get("state")
+	cookies := rec.Result().Cookies()
+	if state == "" || len(cookies) != 2 {
+		t.Fatalf("login redirect = %s, cookies = %v, want a state and two cookies", location, cookies)
+	}
+	for _, c := range cookies {
+		if !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteLaxMode {
+			t.Errorf("cookie %s = %+v, want HttpOnly, Secure and SameSite=Lax", c.Name, c)
+		}
+	}
+
+	callback := func(code, callbackState string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth/google/callback?"+url.Values{"code": {code}, "state": {callbackState}}.Encode(), nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name  string
+		code  string
+		state string
+		want  int
+	}{
+		{"state mismatch", "linked", "forged", http.StatusBadRequest},
+		{"invalid code", "expired", state, http.StatusBadGateway},
+		{"unverified email", "unverified", state, http.StatusForbidden},
+		{"unlinked account", "unlinked", state, http.StatusForbidden},
+		{"revoked key", "revoked", state, http.StatusForbidden},
+		{"linked account", "linked", state, http.StatusOK},
+		{"other account with the linked email", "other", state, http.StatusForbidden},
+		{"linked account again", "linked", state, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := callback(tt.code, tt.state)
+			if rec.Code != tt.want {
+				t.Fatalf("callback status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var resp tokenResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			claims, err := signer.Verify(resp.AccessToken, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.TenantID != model.DefaultTenantID || claims.APIKeyID != linkedKey.ID {
+				t.Errorf("claims = %+v, want the default tenant and api key %d", claims, linkedKey.ID)
+			}
+		})
+	}
+
+	// Cookieのないブラウザーからのコールバックは拒否する
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/google/callback?code=linked&state="+state, nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("callback without cookies status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HealthChecker は依存先(DB)に接続できているかを返します。
+type HealthChecker interface {
+	// Healthyは定期的な接続確認の直近の結果です。
+	Healthy() bool
+	// Pingは今接続できるかを確認します。
+	Ping(ctx context.Context) error
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+	DB     string `json:"db"`
+}
+
+// RegisterHealthはKubernetesのプローブやロードバランサーなどから使うヘルスチェックを登録します。
+//
+// GET /healthz はプロセスが応答できれば200を返すライブネスチェックです。DBの状態は本文に含めますが、
+// DBの障害でコンテナが再起動を繰り返さないよう、DBに接続できなくても503にはしません。
+// GET /readyz はDBに接続できることをその場で確認するレディネスチェックで、接続できなければ503を返して
+// リクエストの振り分け先から外されるようにします。
+// 発掘で使うBrave SearchやOpenAIはAPIサーバーのリクエストでは呼び出さないため、レディネスでは確認しません。
+func RegisterHealth(e *echo.Echo, db HealthChecker) {
+	e.GET("/healthz", func(c echo.Context) error {
+		resp := healthResponse{Status: "ok", DB: "up"}
+		if !db.Healthy() {
+			resp.DB = "down"
+		}
+		return c.JSON(http.StatusOK, resp)
+	})
+	e.GET("/readyz", func(c echo.Context) error {
+		if err := db.Ping(c.Request().Context()); err != nil {
+			logger(c).Warn("レディネスチェックでDBに接続できません", "error", err)
+			return c.JSON(http.StatusServiceUnavailable, healthResponse{Status: "unavailable", DB: "down"})
+		}
+		return c.JSON(http.StatusOK, healthResponse{Status: "ok", DB: "up"})
+	})
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultJobsLimit = 20
+	maxJobsLimit     = 100
+)
+
+// JobHandler はバッチ処理の実行(ジョブ)の進捗を参照するエンドポイントを提供します。
+type JobHandler struct {
+	repo *repository.JobRepository
+}
+
+func NewJobHandler(repo *repository.JobRepository) *JobHandler {
+	return &JobHandler{repo: repo}
+}
+
+// jobDetail はジョブと、失敗したトピックのエラーです。
+type jobDetail struct {
+	model.Job
+	Errors []jobError `json:"errors"`
+}
+
+type jobError struct {
+	TopicID   uint   `json:"topic_id"`
+	Topic     string `json:"topic"`
+	Error     string `json:"error"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// Listは新しい順にジョブを返します。 GET /api/v1/admin/jobs?kind=discover&status=running&limit=20&cursor=...
+// 次のページがあれば、そのcursorをX-Next-Cursorヘッダーで返します。
+func (h *JobHandler) List(c echo.Context) error {
+	limit := defaultJobsLimit
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxJobsLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 100")
+		}
+		limit = n
+	}
+	cursor, err := pageCursor(c)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := h.repo.WithContext(c.Request().Context()).List(c.QueryParam("kind"), c.QueryParam("status"), cursor, limit+1)
+	if err != nil {
+		logger(c).Error("ジョブ一覧取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list jobs")
+	}
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+		setNextCursor(c, repository.Cursor{At: jobs[limit-1].StartedAt, ID: jobs[limit-1].ID})
+	}
+	if jobs == nil {
+		jobs = []model.Job{}
+	}
+	return c.JSON(http.StatusOK, jobs)
+}
+
+// Getはジョブの進捗と、失敗したトピックのエラーを返します。 GET /api/v1/admin/jobs/:id
+func (h *JobHandler) Get(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	jobs := h.repo.WithContext(c.Request().Context())
+	job, err := jobs.Get(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "job not found")
+		}
+		logger(c).Error("ジョブ取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get job")
+	}
+	items, err := jobs.ListTopics(job.ID)
+	if err != nil {
+		logger(c).Error("ジョブのトピック取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get job")
+	}
+	detail := jobDetail{Job: *job, Errors: []jobError{}}
+	for _, item := range items {
+		if item.Outcome == model.TopicOutcomeFailed {
+			detail.Errors = append(detail.Errors, jobError{TopicID: item.TopicID, Topic: item.Topic, Error: item.Error, ErrorCode: item.ErrorCode})
+		}
+	}
+	return c.JSON(http.StatusOK, detail)
+}
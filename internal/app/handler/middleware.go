@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"excavation_service/internal/app/apperr"
+	"excavation_service/internal/app/i18n"
+	"excavation_service/internal/app/logging"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequestLoggerはリクエストIDと、リクエストIDを付与したロガーをリクエストのコンテキストに格納するミドルウェアを返します。
+// リクエストIDはmiddleware.RequestIDが設定したX-Request-IDヘッダーの値で、このミドルウェアより前に登録してください。
+// コンテキストのリクエストIDは、DBのクエリのログや外部API(検索API・GPT)へのリクエストにも引き継ぎます。
+func RequestLogger() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id := c.Response().Header().Get(echo.HeaderXRequestID)
+			req := c.Request()
+			logger := logging.FromContext(req.Context()).With("request_id", id)
+			ctx := logging.WithRequestID(logging.NewContext(req.Context(), logger), id)
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// quietPaths はロードバランサーなどが頻繁に呼び出すため、アクセスログをdebugで出力するパスです。
+var quietPaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// AccessLogはリクエストの完了時にメソッド・パス・ステータス・処理時間などをアクセスログとして出力するミドルウェアを返します。
+// ログはLOG_FORMAT=jsonならJSONで出力します。RequestLoggerの後に登録してください。
+func AccessLog() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			// 応答のステータスを記録するため、エラーはここで応答にする
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			req, resp := c.Request(), c.Response()
+			level := slog.LevelInfo
+			if quietPaths[req.URL.Path] {
+				level = slog.LevelDebug
+			}
+			logger(c).Log(req.Context(), level, "リクエスト",
+				"method", req.Method,
+				"path", req.URL.Path,
+				"route", c.Path(),
+				"status", resp.Status,
+				"latency_ms", time.Since(start).Milliseconds(),
+				"bytes_in", max(req.ContentLength, 0),
+				"bytes_out", resp.Size,
+				"remote_ip", c.RealIP(),
+			)
+			return nil
+		}
+	}
+}
+
+// loggerはリクエストIDが付与されたリクエストのロガーを返します。
+func logger(c echo.Context) *slog.Logger {
+	return logging.FromContext(c.Request().Context())
+}
+
+// LanguageはAccept-Languageヘッダーから応答の言語を選び、リクエストのコンテキストに格納するミドルウェアを返します。
+// 対応している言語が指定されていなければ格納せず、文言ごとの既定の言語で応答します。
+func Language() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Response().Header().Add(echo.HeaderVary, "Accept-Language")
+			if lang, ok := i18n.Negotiate(c.Request().Header.Get("Accept-Language")); ok {
+				req := c.Request()
+				c.SetRequest(req.WithContext(i18n.NewContext(req.Context(), lang)))
+			}
+			return next(c)
+		}
+	}
+}
+
+// LocalizedErrorHandlerはエラーの応答のメッセージをリクエストの言語に翻訳してからnextで応答するエラーハンドラーを返します。
+// メッセージは英語で書き、Accept-Languageで他の言語が指定された場合のみ翻訳します。
+// apperrで分類した誤りは分類に応じたステータス(apperr.HTTPStatus)で返し、応答に分類のコードを含めます。
+func LocalizedErrorHandler(next echo.HTTPErrorHandler) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		lang := i18n.FromContext(c.Request().Context(), i18n.En)
+		var he *echo.HTTPError
+		if !errors.As(err, &he) {
+			if code := apperr.CodeOf(err); code != apperr.CodeInternal {
+				c.Response().Header().Set(headerContentLanguage, string(lang))
+				body := codedError{Message: i18n.Error(lang, codeMessages[code]), Code: code}
+				next(echo.NewHTTPError(apperr.HTTPStatus(code), body).SetInternal(err), c)
+				return
+			}
+		}
+		if errors.As(err, &he) {
+			if msg, ok := he.Message.(string); ok {
+				translated := *he
+				translated.Message = i18n.Error(lang, msg)
+				err = &translated
+				c.Response().Header().Set(headerContentLanguage, string(lang))
+			}
+		}
+		next(err, c)
+	}
+}
+
+// codedError はapperrで分類した誤りの応答です。
+type codedError struct {
+	Message string      `json:"message"`
+	Code    apperr.Code `json:"code"`
+}
+
+// codeMessages はapperrの分類ごとの応答のメッセージです。
+var codeMessages = map[apperr.Code]string{
+	apperr.CodeUpstreamRateLimited: "upstream service is rate limiting requests",
+	apperr.CodeUpstreamAuth:        "upstream service rejected the credentials",
+	apperr.CodeUpstreamRejected:    "upstream service rejected the request",
+	apperr.CodeUpstreamUnavailable: "upstream service is unavailable",
+	apperr.CodeParseFailed:         "failed to parse upstream response",
+	apperr.CodeNoResults:           "no results found",
+	apperr.CodeTimeout:             "request timed out",
+	apperr.CodeCanceled:            "request canceled",
+}
+
+// headerContentLanguage は応答の言語を表すヘッダーです。
+const headerContentLanguage = "Content-Language"
+
+// langはリクエストの言語を返します。Accept-Languageで対応している言語が指定されていなければfallbackです。
+func lang(c echo.Context, fallback i18n.Lang) i18n.Lang {
+	l := i18n.FromContext(c.Request().Context(), fallback)
+	c.Response().Header().Set(headerContentLanguage, string(l))
+	return l
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// PostgreSQLのエラーコード
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+)
+
+// NotificationHandler はトピックごとの通知の設定のエンドポイントを提供します。
+// 通知の設定はリクエストのテナントのトピックのもののみ扱います。
+type NotificationHandler struct {
+	repo   *repository.NotificationPreferenceRepository
+	topics *repository.TopicRepository
+}
+
+func NewNotificationHandler(repo *repository.NotificationPreferenceRepository, topics *repository.TopicRepository) *NotificationHandler {
+	return &NotificationHandler{repo: repo, topics: topics}
+}
+
+// tenantRepoはリクエストのテナントの通知の設定を扱うリポジトリを返します。
+func (h *NotificationHandler) tenantRepo(c echo.Context) *repository.NotificationPreferenceRepository {
+	ctx := c.Request().Context()
+	return h.repo.WithContext(ctx).ForTenant(tenant.ID(ctx))
+}
+
+type notificationPreferenceRequest struct {
+	TopicID  uint     `json:"topic_id"`
+	Channel  string   `json:"channel"`
+	MinScore *float64 `json:"min_score"`
+	Mode     string   `json:"mode"`
+}
+
+// validateは通知の設定のリクエストを検証し、エラーの内容を返します。問題がなければ空文字列です。
+// modeを省略した場合はダイジェストで送ります。
+func (req *notificationPreferenceRequest) validate() string {
+	req.Channel = strings.TrimSpace(req.Channel)
+	if req.Mode == "" {
+		req.Mode = model.NotifyDigest
+	}
+	scheme, to, _ := strings.Cut(req.Channel, ":")
+	switch {
+	case req.Channel == "":
+		return "channel is required"
+	case scheme == notify.SlackScheme && !notify.ValidSlackWebhookURL(to):
+		return "channel must be slack:<Slack incoming webhook URL>"
+	case scheme == notify.LINEScheme && !notify.ValidLINEUserID(to):
+		return "channel must be line:<LINE user ID>"
+	case !slices.Contains(model.NotifyModes, req.Mode):
+		return "mode must be any of " + strings.Join(model.NotifyModes, ", ")
+	}
+	return ""
+}
+
+// notificationPreferenceResponse は通知の設定です。SlackのWebhookのURLは鍵の部分を伏せて返します。
+func notificationPreferenceResponse(pref model.NotificationPreference) model.NotificationPreference {
+	pref.Channel = notify.MaskSlackRecipient(pref.Channel)
+	return pref
+}
+
+// Createは通知の設定を登録します。同じトピックと通知先の設定は1つまでです。 POST /api/v1/notification-preferences
+func (h *NotificationHandler) Create(c echo.Context) error {
+	var req notificationPreferenceRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if req.TopicID == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "topic_id is required")
+	}
+	if msg := req.validate(); msg != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, msg)
+	}
+	if err := checkTopic(c, h.topics, req.TopicID); err != nil {
+		return err
+	}
+
+	pref := model.NotificationPreference{TopicID: req.TopicID, Channel: req.Channel, MinScore: req.MinScore, Mode: req.Mode}
+	if err := h.tenantRepo(c).Create(&pref); err != nil {
+		switch pqErrorCode(err) {
+		case pqUniqueViolation:
+			return echo.NewHTTPError(http.StatusConflict, "a preference for this topic and channel already exists")
+		case pqForeignKeyViolation:
+			return echo.NewHTTPError(http.StatusBadRequest, "topic not found")
+		}
+		logger(c).Error("通知の設定の登録失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create notification preference")
+	}
+	return c.JSON(http.StatusCreated, notificationPreferenceResponse(pref))
+}
+
+// Listは通知の設定をID順に返します。 GET /api/v1/notification-preferences?topic_id=...&limit=100&cursor=...
+// 次のページがあれば、そのcursorをX-Next-Cursorヘッダーで返します。
+func (h *NotificationHandler) List(c echo.Context) error {
+	var topicID uint64
+	if s := c.QueryParam("topic_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid topic_id")
+		}
+		topicID = id
+	}
+	limit, err := listLimit(c)
+	if err != nil {
+		return err
+	}
+	cursor, err := pageCursor(c)
+	if err != nil {
+		return err
+	}
+	prefs, err := h.tenantRepo(c).List(uint(topicID), cursor, limit+1)
+	if err != nil {
+		logger(c).Error("通知の設定の一覧取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list notification preferences")
+	}
+	if len(prefs) > limit {
+		prefs = prefs[:limit]
+		setNextCursor(c, repository.Cursor{ID: prefs[limit-1].ID})
+	}
+	resp := make([]model.NotificationPreference, len(prefs))
+	for i, p := range prefs {
+		resp[i] = notificationPreferenceResponse(p)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Getは通知の設定を返します。 GET /api/v1/notification-preferences/:id
+func (h *NotificationHandler) Get(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	pref, err := h.tenantRepo(c).Get(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "notification preference not found")
+		}
+		logger(c).Error("通知の設定の取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get notification preference")
+	}
+	return c.JSON(http.StatusOK, notificationPreferenceResponse(*pref))
+}
+
+// Updateは通知の設定の通知先・最低スコア・送り方を置き換えます。トピックは変更できません。
+// PUT /api/v1/notification-preferences/:id
+func (h *NotificationHandler) Update(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	var req notificationPreferenceRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if msg := req.validate(); msg != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, msg)
+	}
+
+	repo := h.tenantRepo(c)
+	pref := model.NotificationPreference{ID: uint(id), Channel: req.Channel, MinScore: req.MinScore, Mode: req.Mode}
+	if err := repo.Update(&pref); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "notification preference not found")
+		case pqErrorCode(err) == pqUniqueViolation:
+			return echo.NewHTTPError(http.StatusConflict, "a preference for this topic and channel already exists")
+		}
+		logger(c).Error("通知の設定の更新失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update notification preference")
+	}
+	updated, err := repo.Get(uint(id))
+	if err != nil {
+		logger(c).Error("通知の設定の取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get notification preference")
+	}
+	return c.JSON(http.StatusOK, notificationPreferenceResponse(*updated))
+}
+
+// Deleteは通知の設定を削除します。 DELETE /api/v1/notification-preferences/:id
+func (h *NotificationHandler) Delete(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	if err := h.tenantRepo(c).Delete(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "notification preference not found")
+		}
+		logger(c).Error("通知の設定の削除失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete notification preference")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// pqErrorCodeはerrがPostgreSQLのエラーであればそのエラーコードを返します。
+func pqErrorCode(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	return ""
+}
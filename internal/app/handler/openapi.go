@@ -0,0 +1,19 @@
+package handler
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// openAPISpec はエンティティ・トピック・トレンドのエンドポイントのOpenAPI 3の定義です。
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// OpenAPIはAPIのOpenAPIの定義を返します。クライアントのSDKの生成に使えるよう、APIキーなしで取得できます。
+// GET /api/openapi.yaml
+func OpenAPI(c echo.Context) error {
+	return c.Blob(http.StatusOK, "application/yaml", openAPISpec)
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"excavation_service/internal/app/activity"
+	"excavation_service/internal/app/trendstream"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPIPrefixes はopenapi.yamlに定義するエンドポイントの/api/v1からのパスの先頭です。
+var openAPIPrefixes = []string{"/entity-types", "/entities", "/trends", "/rankings", "/topics"}
+
+// TestOpenAPIRoutesはopenapi.yamlがエンティティ・トピック・トレンドのルートと一致していることを確認します。
+func TestOpenAPIRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]yaml.Node `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.yaml: %v", err)
+	}
+	documented := map[string]bool{}
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method != "parameters" {
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+
+	e := echo.New()
+	RegisterRoutes(e, nil, false, nil, nil, trendstream.NewHub(), activity.NewHub(), nil, nil, nil)
+	param := regexp.MustCompile(`:(\w+)`)
+	routes := map[string]bool{}
+	for _, r := range e.Routes() {
+		path, ok := strings.CutPrefix(r.Path, "/api/v1")
+		if !ok || r.Method == echo.RouteNotFound {
+			continue
+		}
+		for _, prefix := range openAPIPrefixes {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				routes[r.Method+" "+param.ReplaceAllString(path, "{$1}")] = true
+			}
+		}
+	}
+	if len(routes) == 0 {
+		t.Fatal("no routes registered")
+	}
+
+	var missing, stale []string
+	for r := range routes {
+		if !documented[r] {
+			missing = append(missing, r)
+		}
+	}
+	for r := range documented {
+		if !routes[r] {
+			stale = append(stale, r)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	if len(missing) > 0 {
+		t.Errorf("routes not in openapi.yaml: %v", missing)
+	}
+	if len(stale) > 0 {
+		t.Errorf("openapi.yaml paths without a route: %v", stale)
+	}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"excavation_service/internal/app/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// headerNextCursor は配列を返す一覧で次のページのcursorを返すヘッダーです。最後のページでは返しません。
+// 応答の本文は配列のままにして、cursorを使わない既存のクライアントが壊れないようにしています。
+const headerNextCursor = "X-Next-Cursor"
+
+// ID順の一覧(エンティティ・トピック・ウォッチ・通知の設定・Webhook)の1ページの件数
+const (
+	defaultListLimit = 100
+	maxListLimit     = 200
+)
+
+// listLimitはクエリのlimit(ID順の一覧の1ページの件数)を読みます。省略した場合はdefaultListLimitです。
+func listLimit(c echo.Context) (int, error) {
+	s := c.QueryParam("limit")
+	if s == "" {
+		return defaultListLimit, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n > maxListLimit {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 200")
+	}
+	return n, nil
+}
+
+// pageCursorはクエリのcursor(前のページの応答のnext_cursorまたはX-Next-Cursor)を読みます。省略した場合は先頭のページです。
+func pageCursor(c echo.Context) (repository.Cursor, error) {
+	s := c.QueryParam("cursor")
+	if s == "" {
+		return repository.Cursor{}, nil
+	}
+	cursor, err := repository.ParseCursor(s)
+	if err != nil {
+		return repository.Cursor{}, echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+	}
+	return cursor, nil
+}
+
+// parseSortはクエリのsortを読みます。allowedにない項目は400のエラーを返します。
+func parseSort(c echo.Context, allowed []string) ([]repository.SortField, error) {
+	sort, err := repository.ParseSort(c.QueryParam("sort"), allowed)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "sort must be any of "+strings.Join(allowed, ", "))
+	}
+	return sort, nil
+}
+
+// setNextCursorは次のページのcursorとして、このページの最後の行lastをX-Next-Cursorヘッダーに設定します。
+// 一覧はlimitより1件多く読み、limitより多く読めた(次のページがある)場合にのみ呼びます。
+func setNextCursor(c echo.Context, last repository.Cursor) {
+	c.Response().Header().Set(headerNextCursor, last.String())
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"gorm.io/gorm"
+)
+
+const publicTrendsLimit = 50
+
+// publicCacheControl はCDNの背後に置く公開エンドポイントのCache-Controlです。
+// トレンドは週に1回しか変わらないため、CDNでは長くキャッシュし、期限切れ後も再検証の間やオリジンのエラー時は古い内容を返させます。
+const publicCacheControl = "public, max-age=300, s-maxage=3600, stale-while-revalidate=86400, stale-if-error=86400"
+
+// PublicHandler は認証なしで公開する読み取り専用のエンドポイントを提供します。
+type PublicHandler struct {
+	trends *repository.TrendRepository
+	stores *repository.StoreRepository
+}
+
+func NewPublicHandler(trends *repository.TrendRepository, stores *repository.StoreRepository) *PublicHandler {
+	return &PublicHandler{trends: trends, stores: stores}
+}
+
+// RegisterPublicは公開エンドポイントを/public/v1に登録します。/api/v1とは別にCDNに載せるため、
+// どのオリジンからも読めるようにし、Cookieなどのユーザーごとの情報は使いません。既定のテナントのトピックのみ返します。
+func RegisterPublic(e *echo.Echo, db *gorm.DB) {
+	h := NewPublicHandler(repository.NewTrendRepository(db).ForTenant(model.DefaultTenantID), repository.NewStoreRepository(db))
+	public := e.Group("/public/v1", middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{http.MethodGet, http.MethodHead},
+	}))
+	public.GET("/trends/latest.json", h.LatestTrends)
+	public.GET("/widget/:file", h.Widget)
+}
+
+type publicTrendsResponse struct {
+	Week   *string                 `json:"week"` // トレンドが1件もなければnull
+	Trends []repository.TopicScore `json:"trends"`
+}
+
+// LatestTrendsはトレンドが存在する最新の週のスコア上位のトピックを返します。 GET /public/v1/trends/latest.json
+// 本文のハッシュをETagとして返し、If-None-Matchが一致すれば304を返します。
+func (h *PublicHandler) LatestTrends(c echo.Context) error {
+	repo := h.trends.WithContext(c.Request().Context())
+	resp := publicTrendsResponse{Trends: []repository.TopicScore{}}
+	latest, err := repo.LatestWeek()
+	if err != nil {
+		logger(c).Error("最新週の取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get trends")
+	}
+	if latest != nil {
+		w := latest.Format(week.Layout)
+		resp.Week = &w
+		top, err := repo.Top(*latest, publicTrendsLimit)
+		if err != nil {
+			logger(c).Error("公開用のトレンド取得失敗", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get trends")
+		}
+		if top != nil {
+			resp.Trends = top
+		}
+	}
+
+	c.Response().Header().Set("Vary", "Origin")
+	return cachedJSON(c, resp, publicCacheControl)
+}
+
+// cachedJSONはvをJSONで返します。本文のハッシュをETagとして返し、If-None-Matchが一致すれば304を返します。
+func cachedJSON(c echo.Context, v any, cacheControl string) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", cacheControl)
+	header.Set("ETag", etag)
+	if etagMatch(c.Request().Header.Get("If-None-Match"), etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.JSONBlob(http.StatusOK, body)
+}
+
+// etagMatchはIf-None-Matchの値ifNoneMatchがetagに一致するか判定します。弱いETag(W/)も一致として扱います。
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, v := range strings.Split(ifNoneMatch, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == etag || v == "*" {
+			return true
+		}
+	}
+	return false
+}
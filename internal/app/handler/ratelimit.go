@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"excavation_service/internal/app/ratelimit"
+	"excavation_service/internal/app/tenant"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RateLimitは送信元のIPアドレスごとのリクエスト数をlimiterのトークンバケットで制限するミドルウェアを返します。
+// 認証でDBに問い合わせる前に制限するため、TenantAuthの前に登録してください。送信元のIPアドレスはEchoのIPExtractorで決めるため、
+// ロードバランサーの後ろで動かす場合は信頼するプロキシのX-Forwarded-Forのみ使うよう設定してください。
+// 上限のヘッダーを付け、トークンのないリクエストには429を返します。バケットの記録に失敗した場合は制限せずに通します。
+func RateLimit(limiter *ratelimit.Limiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := takeRateLimit(c, limiter, "ip:"+c.RealIP()); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// RateLimitAPIKeyは認証したAPIキー(アクセストークンはそのAPIキー)ごとのリクエスト数をlimiterで制限するミドルウェアを返します。
+// 送られてきたキーではなく検証済みのキーで数えるため、TenantAuthの後に登録してください。APIキーのないリクエストは制限しません。
+func RateLimitAPIKey(limiter *ratelimit.Limiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if id := tenant.APIKeyID(c.Request().Context()); id != 0 {
+				if err := takeRateLimit(c, limiter, "key:"+strconv.FormatUint(uint64(id), 10)); err != nil {
+					return err
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// IPExtractorはリクエストの送信元のIPアドレスを決めるEchoのIPExtractorを返します。
+// 接続元がtrustedのいずれかのアドレスの範囲にあればX-Forwarded-Forの信頼できる最も近い送信元を使い、
+// それ以外はクライアントが送るヘッダーを無視して接続元のアドレスを使います。
+func IPExtractor(trusted []*net.IPNet) echo.IPExtractor {
+	if len(trusted) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, n := range trusted {
+		options = append(options, echo.TrustIPRange(n))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// takeRateLimitはkeyのバケットからトークンを取り、上限のヘッダーを付けます。トークンがなければ429のエラーを返します。
+func takeRateLimit(c echo.Context, limiter *ratelimit.Limiter, key string) error {
+	result, err := limiter.Take(c.Request().Context(), key, time.Now())
+	if err != nil {
+		logger(c).Warn("レート制限のバケットの記録に失敗しました", "error", err)
+		return nil
+	}
+	setRateLimitHeaders(c.Response().Header(), result.Limit, result.Remaining, result.Reset)
+	if !result.Allowed {
+		c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(result.RetryAfter.Seconds())+1))
+		return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+	}
+	return nil
+}
+
+// setRateLimitHeadersは上限のヘッダーを付けます。既に他の上限のヘッダーがあれば、残りの少ない方を残します。
+func setRateLimitHeaders(h http.Header, limit, remaining int, reset time.Time) {
+	if v := h.Get(RateLimitRemainingHeader); v != "" {
+		if current, err := strconv.Atoi(v); err == nil && current <= remaining {
+			return
+		}
+	}
+	h.Set(RateLimitLimitHeader, strconv.Itoa(limit))
+	h.Set(RateLimitRemainingHeader, strconv.Itoa(remaining))
+	h.Set(RateLimitResetHeader, strconv.FormatInt(reset.Unix(), 10))
+}
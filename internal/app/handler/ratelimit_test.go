@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"excavation_service/internal/app/ratelimit"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRateLimit(t *testing.T) {
+	_, proxy, _ := net.ParseCIDR("10.0.0.0/8")
+	tests := []struct {
+		name    string
+		trusted []*net.IPNet
+		remote  func(i int) string
+		xff     func(i int) string
+		want    []int
+	}{
+		{
+			// 送られてきたAPIキーやX-Forwarded-Forを変えても、接続元が同じなら同じバケットで数える
+			name:   "spoofed headers",
+			remote: func(int) string { return "192.0.2.1:1234" },
+			xff:    func(i int) string { return fmt.Sprintf("198.51.100.%d", i) },
+			want:   []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:    "trusted proxy",
+			trusted: []*net.IPNet{proxy},
+			remote:  func(int) string { return "10.0.0.5:1234" },
+			xff:     func(i int) string { return fmt.Sprintf("198.51.100.%d", i) },
+			want:    []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			// 信頼していない接続元のX-Forwarded-Forは使わない
+			name:    "untrusted peer",
+			trusted: []*net.IPNet{proxy},
+			remote:  func(int) string { return "192.0.2.1:1234" },
+			xff:     func(i int) string { return fmt.Sprintf("198.51.100.%d", i) },
+			want:    []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.IPExtractor = IPExtractor(tt.trusted)
+			limiter := ratelimit.New(ratelimit.NewMemory(), ratelimit.PerMinute(1, 2))
+			e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, RateLimit(limiter))
+			for i, want := range tt.want {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = tt.remote(i)
+				req.Header.Set(echo.HeaderXForwardedFor, tt.xff(i))
+				req.Header.Set(APIKeyHeader, fmt.Sprintf("exc_random%d", i))
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				if rec.Code != want {
+					t.Errorf("request %d: status = %d, want %d", i, rec.Code, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Package handler はAPIサーバーのHTTPハンドラーとルーティングを提供します。
+package handler
+
+import (
+	"excavation_service/internal/app/activity"
+	"excavation_service/internal/app/authtoken"
+	"excavation_service/internal/app/graph"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/queue"
+	"excavation_service/internal/app/ratelimit"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/search"
+	"excavation_service/internal/app/trendstream"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// RegisterRoutesはAPIのルートをEchoに登録します。/api/v1のリクエストはAPIキーのテナントのデータのみ扱い、
+// requireAPIKeyがfalseならAPIキーのないリクエストを既定のテナントとして扱います。リクエストの利用量はAPIキーごとに記録します。
+// tokensがnilでなければ、APIキーと引き換えにアクセストークンを発行し、APIキーの代わりに受け付けます。
+// データを変更するエンドポイントと運用者向けのエンドポイントは、requireAPIKeyによらずAPIキーかアクセストークンが必要で、
+// データを変更するにはAPIキーにwriteのスコープが必要です。limiterがnilでなければ、送信元のIPアドレスごとと認証したAPIキーごとにリクエスト数を制限します。
+// 新しく保存されたトレンドはstreamから受け取り、Server-Sent Eventsで配信します。
+// 発掘の進捗はprogressから受け取り、運用者向けにWebSocketで配信します。ブラウザーからの接続はdashboardOriginsのOriginのもののみ受け付けます。
+// 運用者が依頼した発掘はtopicQueueに送ります。nilの場合は依頼のエンドポイントが503を返します。
+// /graphqlではエンティティ・トピック・トレンドをGraphQLで読めます。認証は/api/v1と同じです。
+// 既定のテナントの店舗の検索はstores(MeilisearchかPostgreSQLの検索)で行います。nilの場合はPostgreSQLで検索します。
+func RegisterRoutes(e *echo.Echo, db *gorm.DB, requireAPIKey bool, tokens *authtoken.Signer, limiter *ratelimit.Limiter, stream *trendstream.Hub, progress *activity.Hub, topicQueue queue.Sender, stores search.StoreSearcher, dashboardOrigins []string) {
+	// APIの定義はクライアントの生成に使うため、APIキーなしで返す
+	e.GET("/api/openapi.yaml", OpenAPI)
+
+	var middlewares []echo.MiddlewareFunc
+	if limiter != nil {
+		middlewares = append(middlewares, RateLimit(limiter))
+	}
+	middlewares = append(middlewares, TenantAuth(repository.NewTenantRepository(db), repository.NewQuotaRepository(db), requireAPIKey, tokens))
+	if limiter != nil {
+		middlewares = append(middlewares, RateLimitAPIKey(limiter))
+	}
+	middlewares = append(middlewares, RecordUsage(repository.NewUsageRepository(db)))
+	api := e.Group("/api/v1", middlewares...)
+
+	if tokens != nil {
+		api.POST("/auth/token", NewAuthHandler(tokens).Token, authenticated)
+	}
+
+	api.GET("/entity-types", EntityTypes)
+
+	topics := repository.NewTopicRepository(db)
+	entities := NewEntityHandler(topics)
+	api.GET("/entities", entities.List)
+	api.POST("/entities", entities.Create, writable)
+	api.GET("/entities/:id", entities.Get)
+	api.PUT("/entities/:id", entities.Update, writable)
+	api.DELETE("/entities/:id", entities.Delete, writable)
+	entityTopics := NewTopicHandler(db, topics)
+	api.GET("/entities/:id/topics", entityTopics.List)
+	api.POST("/entities/:id/topics", entityTopics.Create, writable)
+	api.GET("/entities/:id/topics/:topic_id", entityTopics.Get)
+	api.PUT("/entities/:id/topics/:topic_id", entityTopics.Update, writable)
+	api.DELETE("/entities/:id/topics/:topic_id", entityTopics.Delete, writable)
+	watches := NewWatchHandler(repository.NewWatchRepository(db), topics)
+	api.GET("/watches", watches.List)
+	api.POST("/watches", watches.Create, writable)
+	api.DELETE("/watches/:id", watches.Delete, writable)
+
+	notifications := NewNotificationHandler(repository.NewNotificationPreferenceRepository(db), topics)
+	api.GET("/notification-preferences", notifications.List)
+	api.POST("/notification-preferences", notifications.Create, writable)
+	api.GET("/notification-preferences/:id", notifications.Get)
+	api.PUT("/notification-preferences/:id", notifications.Update, writable)
+	api.DELETE("/notification-preferences/:id", notifications.Delete, writable)
+
+	trends := NewTrendHandler(repository.NewTrendRepository(db))
+	api.GET("/trends", trends.List)
+	api.GET("/trends/movers", trends.Movers)
+	api.GET("/trends/export", trends.Export)
+	api.GET("/trends/export.csv", trends.Export)
+	api.GET("/trends/stream", NewTrendStreamHandler(stream).Stream)
+	api.GET("/rankings", trends.Ranking)
+	api.GET("/topics/:id/trends", NewTopicTrendHandler(topics, repository.NewTrendRepository(db)).History)
+	// GraphQLはエンティティ・トピック・トレンドを1回のリクエストで読むためのもので、/api/v1と同じ認証と利用量の記録を行う。
+	// 読み取りのみのため、writeのスコープは要らない
+	e.POST("/graphql", echo.WrapHandler(graph.NewHandler(topics, repository.NewTrendRepository(db))), middlewares...)
+	storeHandler := NewStoreHandler(stores, repository.NewStoreRepository(db), repository.NewTrendRepository(db))
+	api.GET("/stores", storeHandler.Search)
+	api.GET("/stores/nearby", storeHandler.Nearby)
+	api.GET("/stores/geojson", storeHandler.GeoJSON)
+	api.GET("/stores/search", NewStoreSearchHandler(repository.NewStoreRepository(db)).Search)
+
+	// フィードは公開のため、既定のテナントのトピックのみ載せる
+	feeds := NewFeedHandler(repository.NewTrendRepository(db).ForTenant(model.DefaultTenantID), repository.NewStoreRepository(db))
+	e.GET("/feeds/trends.atom", feeds.Trends)
+	e.GET("/feeds/trends.xml", feeds.Trends)
+
+	// ダイジェストは既定のテナントのトレンドを送るため、購読の登録は運用者(サイトのサーバーなど)のみ行える。
+	// 配信停止はメールのリンクとワンクリックでの配信停止(RFC 8058)から呼ばれ、APIキーを送らないため、
+	// APIキーの代わりにリンクのtokenで購読を確認する
+	digests := NewDigestHandler(repository.NewDigestRepository(db))
+	api.POST("/digest/subscriptions", digests.Subscribe, operatorOnly, writable)
+	var public []echo.MiddlewareFunc
+	if limiter != nil {
+		public = append(public, RateLimit(limiter))
+	}
+	e.GET("/api/v1/digest/unsubscribe", digests.Unsubscribe, public...)
+	e.POST("/api/v1/digest/unsubscribe", digests.Unsubscribe, public...)
+
+	// Webhookと実行履歴はすべてのテナントのイベントを扱うため、運用者のみ使える
+	webhooks := NewWebhookHandler(repository.NewWebhookRepository(db))
+	api.GET("/webhooks", webhooks.List, operatorOnly)
+	api.POST("/webhooks", webhooks.Create, operatorOnly, writable)
+	api.DELETE("/webhooks/:id", webhooks.Delete, operatorOnly, writable)
+	api.GET("/webhooks/:id/deliveries", webhooks.Deliveries, operatorOnly)
+
+	// /jobsは/admin/jobsの以前のパスで、互換のために残す
+	jobs := NewJobHandler(repository.NewJobRepository(db))
+	api.GET("/jobs", jobs.List, operatorOnly)
+	api.GET("/jobs/:id", jobs.Get, operatorOnly)
+
+	admin := api.Group("/admin", operatorOnly)
+	audit := NewAuditHandler(repository.NewAuditRepository(db))
+	admin.GET("/audit-logs", audit.List)
+
+	usage := NewUsageHandler(repository.NewUsageRepository(db))
+	admin.GET("/usage", usage.Report)
+
+	runs := NewRunHandler(repository.NewJobRepository(db), repository.NewStoreRepository(db))
+	admin.GET("/runs", runs.List)
+	admin.GET("/runs/:id", runs.Get)
+	admin.GET("/runs/:id/mentions", runs.Mentions)
+	admin.GET("/activity", NewActivityHandler(progress, dashboardOrigins).Stream)
+	admin.GET("/jobs", jobs.List)
+	admin.GET("/jobs/:id", jobs.Get)
+	admin.POST("/jobs/trend-discovery", NewDiscoveryJobHandler(topics, repository.NewAuditRepository(db), topicQueue).Enqueue, writable)
+}
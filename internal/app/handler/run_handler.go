@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// RunHandler は過去の発掘の実行を調べるための管理用エンドポイントを提供します。
+// トピックに今週のトレンドがない理由を、DBに入らずに実行ごとのトピックの結果と言及から調べられるようにします。
+type RunHandler struct {
+	jobs   *repository.JobRepository
+	stores *repository.StoreRepository
+}
+
+func NewRunHandler(jobs *repository.JobRepository, stores *repository.StoreRepository) *RunHandler {
+	return &RunHandler{jobs: jobs, stores: stores}
+}
+
+// runSummary は実行とトピックの結果(outcome)ごとの件数です。
+type runSummary struct {
+	model.Job
+	Outcomes map[string]int `json:"outcomes"`
+}
+
+// runTopic はトピックの結果と、そのトピック・週の言及(クロールした項目)へのリンクです。
+type runTopic struct {
+	repository.JobTopicItem
+	MentionsURL string `json:"mentions_url"`
+}
+
+// Listは新しい順に発掘の実行を返します。 GET /api/v1/admin/runs?status=failed&limit=20&cursor=...
+// 次のページがあれば、そのcursorをX-Next-Cursorヘッダーで返します。
+func (h *RunHandler) List(c echo.Context) error {
+	limit := defaultJobsLimit
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxJobsLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 100")
+		}
+		limit = n
+	}
+	cursor, err := pageCursor(c)
+	if err != nil {
+		return err
+	}
+
+	jobs := h.jobs.WithContext(c.Request().Context())
+	list, err := jobs.List(model.JobKindDiscover, c.QueryParam("status"), cursor, limit+1)
+	if err != nil {
+		logger(c).Error("実行一覧取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list runs")
+	}
+	if len(list) > limit {
+		list = list[:limit]
+		setNextCursor(c, repository.Cursor{At: list[limit-1].StartedAt, ID: list[limit-1].ID})
+	}
+	ids := make([]uint, len(list))
+	for i, job := range list {
+		ids[i] = job.ID
+	}
+	counts, err := jobs.CountOutcomes(ids)
+	if err != nil {
+		logger(c).Error("実行の集計失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list runs")
+	}
+
+	runs := make([]runSummary, len(list))
+	for i, job := range list {
+		outcomes := counts[job.ID]
+		if outcomes == nil {
+			outcomes = map[string]int{}
+		}
+		runs[i] = runSummary{Job: job, Outcomes: outcomes}
+	}
+	return c.JSON(http.StatusOK, runs)
+}
+
+// Getは実行とトピックごとの結果を返します。 GET /api/v1/admin/runs/:id (idはrun_id)
+func (h *RunHandler) Get(c echo.Context) error {
+	job, err := h.getJob(c)
+	if err != nil {
+		return err
+	}
+	items, err := h.jobs.WithContext(c.Request().Context()).ListTopics(job.ID)
+	if err != nil {
+		logger(c).Error("実行のトピック取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get run")
+	}
+
+	topics := make([]runTopic, len(items))
+	outcomes := map[string]int{}
+	for i, item := range items {
+		topics[i] = runTopic{
+			JobTopicItem: item,
+			MentionsURL:  fmt.Sprintf("/api/v1/admin/runs/%s/mentions?topic_id=%d", job.RunID, item.TopicID),
+		}
+		outcomes[item.Outcome]++
+	}
+	return c.JSON(http.StatusOK, struct {
+		runSummary
+		Topics []runTopic `json:"topics"`
+	}{runSummary{Job: *job, Outcomes: outcomes}, topics})
+}
+
+// Mentionsは実行の週にトピックで記録された店舗への言及を返します。 GET /api/v1/admin/runs/:id/mentions?topic_id=12
+// 同じ週の他の実行が先に記録した言及も含みます(run_idで区別できます)。
+func (h *RunHandler) Mentions(c echo.Context) error {
+	topicID, err := strconv.ParseUint(c.QueryParam("topic_id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "topic_id is required")
+	}
+	job, err := h.getJob(c)
+	if err != nil {
+		return err
+	}
+	mentions, err := h.stores.WithContext(c.Request().Context()).ListMentions(uint(topicID), week.Of(job.StartedAt))
+	if err != nil {
+		logger(c).Error("言及一覧取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list mentions")
+	}
+	if mentions == nil {
+		mentions = []repository.MentionItem{}
+	}
+	return c.JSON(http.StatusOK, mentions)
+}
+
+// getJobはパスのrun_idの発掘のジョブを返します。見つからなければ404のエラーを返します。
+func (h *RunHandler) getJob(c echo.Context) (*model.Job, error) {
+	job, err := h.jobs.WithContext(c.Request().Context()).GetByRunID(c.Param("id"))
+	if err == nil && job.Kind != model.JobKindDiscover {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "run not found")
+		}
+		logger(c).Error("実行取得失敗", "error", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get run")
+	}
+	return job, nil
+}
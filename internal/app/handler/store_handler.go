@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"excavation_service/internal/app/geojson"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/search"
+	"excavation_service/internal/app/tenant"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultStoreSearchLimit = 20
+	maxStoreSearchLimit     = 100
+
+	defaultNearbyRadius = 1000 // メートル
+	maxNearbyRadius     = 10000
+)
+
+// StoreHandler は店舗のエンドポイントを提供します。店舗はリクエストのテナントのトピックで言及されたもののみ扱います。
+type StoreHandler struct {
+	searcher search.StoreSearcher
+	stores   *repository.StoreRepository
+	trends   *repository.TrendRepository
+}
+
+func NewStoreHandler(searcher search.StoreSearcher, stores *repository.StoreRepository, trends *repository.TrendRepository) *StoreHandler {
+	return &StoreHandler{searcher: searcher, stores: stores, trends: trends}
+}
+
+// Searchは店舗名か言及していたトピック(エリアなど)で店舗を検索します。 GET /api/v1/stores?q=西日暮里 カレー&limit=20
+// 既定のテナントはsearcher(MeilisearchかPostgreSQLの検索)で、他のテナントはPostgreSQLでテナントのトピックに絞って検索します。
+func (h *StoreHandler) Search(c echo.Context) error {
+	q := strings.TrimSpace(c.QueryParam("q"))
+	if q == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "q is required")
+	}
+	limit := defaultStoreSearchLimit
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxStoreSearchLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 100")
+		}
+		limit = n
+	}
+
+	ctx := c.Request().Context()
+	var stores []repository.StoreSummary
+	var err error
+	if tenantID := tenant.ID(ctx); tenantID == model.DefaultTenantID && h.searcher != nil {
+		stores, err = h.searcher.SearchStores(ctx, q, limit)
+	} else {
+		stores, err = h.stores.WithContext(ctx).SearchSummaries(tenantID, q, limit)
+	}
+	if err != nil {
+		logger(c).Error("店舗の検索失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to search stores")
+	}
+	if stores == nil {
+		stores = []repository.StoreSummary{}
+	}
+	return c.JSON(http.StatusOK, stores)
+}
+
+type nearbyStoresResponse struct {
+	Week   *string                  `json:"week"` // トレンドが1件もなければnull
+	Stores []repository.NearbyStore `json:"stores"`
+}
+
+// Nearbyは地点の近くでトレンドになっている店舗を、orderの順に返します。orderはrank(近さと最新の週のスコアを混ぜた順。既定)、
+// score(最新の週のスコアの高い順)、distance(近い順)のいずれかです。緯度・経度を変換済みの店舗のみが対象です。
+// GET /api/v1/stores/nearby?lat=35.66&lng=139.7&radius=1000&order=score&limit=20
+func (h *StoreHandler) Nearby(c echo.Context) error {
+	lat, err := strconv.ParseFloat(c.QueryParam("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return echo.NewHTTPError(http.StatusBadRequest, "lat must be between -90 and 90")
+	}
+	lng, err := strconv.ParseFloat(c.QueryParam("lng"), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return echo.NewHTTPError(http.StatusBadRequest, "lng must be between -180 and 180")
+	}
+	radius := float64(defaultNearbyRadius)
+	if s := c.QueryParam("radius"); s != "" {
+		r, err := strconv.ParseFloat(s, 64)
+		if err != nil || r <= 0 || r > maxNearbyRadius {
+			return echo.NewHTTPError(http.StatusBadRequest, "radius must be between 1 and 10000")
+		}
+		radius = r
+	}
+	limit := defaultStoreSearchLimit
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxStoreSearchLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 100")
+		}
+		limit = n
+	}
+	order := c.QueryParam("order")
+	if order == "" {
+		order = repository.NearbyOrderRank
+	}
+	if !slices.Contains(repository.NearbyOrders, order) {
+		return echo.NewHTTPError(http.StatusBadRequest, "order must be any of "+strings.Join(repository.NearbyOrders, ", "))
+	}
+
+	ctx := c.Request().Context()
+	resp := nearbyStoresResponse{Stores: []repository.NearbyStore{}}
+	latest, err := h.trends.WithContext(ctx).ForTenant(tenant.ID(ctx)).LatestWeek()
+	if err != nil {
+		logger(c).Error("最新週取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to search nearby stores")
+	}
+	if latest != nil {
+		w := latest.Format(week.Layout)
+		resp.Week = &w
+		stores, err := h.stores.WithContext(ctx).Nearby(tenant.ID(ctx), lat, lng, radius, *latest, order, limit)
+		if err != nil {
+			logger(c).Error("近くの店舗の検索失敗", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to search nearby stores")
+		}
+		if stores != nil {
+			resp.Stores = stores
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GeoJSONは緯度・経度を変換済みの店舗をGeoJSONで返します。areaを指定するとトピック名にそのエリアを含むトピック、
+// topic_idを指定するとそのトピックで言及された店舗のみ返します。 GET /api/v1/stores/geojson?area=渋谷
+func (h *StoreHandler) GeoJSON(c echo.Context) error {
+	var topicID uint64
+	if s := c.QueryParam("topic_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid topic_id")
+		}
+		topicID = id
+	}
+	area := strings.TrimSpace(c.QueryParam("area"))
+
+	ctx := c.Request().Context()
+	stores, err := h.stores.WithContext(ctx).Features(tenant.ID(ctx), area, uint(topicID))
+	if err != nil {
+		logger(c).Error("GeoJSONの店舗取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to export stores")
+	}
+	body, err := geojson.Stores(stores).Marshal()
+	if err != nil {
+		logger(c).Error("GeoJSONの生成失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to export stores")
+	}
+	return c.Blob(http.StatusOK, geojson.ContentType, body)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxStoreAppearances は店舗名の検索で店舗ごとに返すトレンドの数です。それより古いトレンドはtrend_countにのみ数えます。
+const maxStoreAppearances = 10
+
+// StoreSearchHandler は店舗名で店舗を探し、その店舗がテナントのトレンドに登場したかを返すエンドポイントを提供します。
+// 店舗の一般的な検索(GET /api/v1/stores)と違い、店舗名のみを対象にし、店舗名の表記の揺れにも一致します。
+type StoreSearchHandler struct {
+	stores *repository.StoreRepository
+}
+
+func NewStoreSearchHandler(stores *repository.StoreRepository) *StoreSearchHandler {
+	return &StoreSearchHandler{stores: stores}
+}
+
+type storeAppearance struct {
+	TrendID  uint    `json:"trend_id"`
+	TopicID  uint    `json:"topic_id"`
+	Topic    string  `json:"topic"`
+	Week     string  `json:"week"`
+	Score    float64 `json:"score"`
+	Mentions int64   `json:"mentions"`
+}
+
+type storeNameMatch struct {
+	ID         uint              `json:"id"`
+	Name       string            `json:"name"`
+	URL        string            `json:"url"`
+	Similarity float64           `json:"similarity"`
+	TrendCount int               `json:"trend_count"` // 店舗が言及されていたトレンドの数。0であればまだトレンドに登場していない
+	Trends     []storeAppearance `json:"trends"`      // 新しい週から最大10件
+}
+
+// Searchは店舗名がqを含むか表記の近い店舗を、店舗が言及されていたトレンドとともに返します。
+// GET /api/v1/stores/search?q=らーめん一番&limit=20
+func (h *StoreSearchHandler) Search(c echo.Context) error {
+	q := strings.TrimSpace(c.QueryParam("q"))
+	if q == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "q is required")
+	}
+	limit := defaultStoreSearchLimit
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxStoreSearchLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 100")
+		}
+		limit = n
+	}
+
+	ctx := c.Request().Context()
+	stores := h.stores.WithContext(ctx)
+	matches, err := stores.SearchNames(tenant.ID(ctx), q, limit)
+	if err != nil {
+		logger(c).Error("店舗名の検索失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to search stores")
+	}
+	ids := make([]uint, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ID
+	}
+	appearances, err := stores.Appearances(tenant.ID(ctx), ids)
+	if err != nil {
+		logger(c).Error("店舗のトレンドの取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to search stores")
+	}
+	byStore := map[uint][]repository.StoreAppearance{}
+	for _, a := range appearances {
+		byStore[a.StoreID] = append(byStore[a.StoreID], a)
+	}
+
+	resp := make([]storeNameMatch, len(matches))
+	for i, m := range matches {
+		found := byStore[m.ID]
+		resp[i] = storeNameMatch{ID: m.ID, Name: m.Name, URL: m.URL, Similarity: m.Similarity, TrendCount: len(found), Trends: []storeAppearance{}}
+		for _, a := range found[:min(len(found), maxStoreAppearances)] {
+			resp[i].Trends = append(resp[i].Trends, storeAppearance{
+				TrendID: a.TrendID, TopicID: a.TopicID, Topic: a.Topic, Week: a.Week.Format(week.Layout), Score: a.Score, Mentions: a.Mentions,
+			})
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/authtoken"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/quota"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// APIKeyHeader はAPIキーを渡すヘッダーです。Authorization: Bearer <APIキー> でも渡せます。
+// アクセストークンもAPIキーと同じくどちらのヘッダーでも渡せます。
+const APIKeyHeader = "X-API-Key"
+
+// ブラウザーのWebSocketはヘッダーを付けられないため、WebSocketの接続ではアクセストークンのみ次のどちらかでも渡せます。
+// APIキーは有効期限がなく、URLに含めるとプロキシのログなどに残るため受け付けません。
+//   - Sec-WebSocket-Protocol: new WebSocket(url, ["access_token", トークン])のように、AccessTokenProtocolの次にトークンを指定する。
+//     サーバーはAccessTokenProtocolを選んで応答する
+//   - クエリのaccess_token: URLはログに残りやすいため、サブプロトコルを使えないクライアントのみ使う
+const (
+	AccessTokenProtocol = "access_token"
+	accessTokenParam    = "access_token"
+)
+
+// リクエスト数の上限を伝えるヘッダー。最も厳しい上限とその残り、リクエスト数が戻る時刻(Unix時間の秒)を返します。
+// RateLimitの短い間隔の上限と1日・1か月の上限の両方があれば、残りの少ない方を返します。
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// TenantAuthはAPIキーからリクエストのテナントを決め、リクエストのコンテキストに格納するミドルウェアを返します。
+// APIキーのないリクエストは、requireKeyがfalseなら既定のテナントとして扱い、trueなら401を返します。
+// tokensがnilでなければ、APIキーの代わりにtokensで発行したアクセストークンも受け付けます。WebSocketの接続ではwebSocketTokenのトークンも使います。
+// トークンのAPIキーが発行後に失効していれば401を返します。
+// データを取得するリクエスト(GET・HEAD)は、APIキーにreadのスコープがなければ403を返します。
+// テナントやAPIキーに1日・1か月のリクエスト数の上限があれば、上限のヘッダーを付け、上限を超えたリクエストに429を返します。
+func TenantAuth(repo *repository.TenantRepository, quotas *repository.QuotaRepository, requireKey bool, tokens *authtoken.Signer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			tenants := repo.WithContext(req.Context())
+			// 1日・1か月の上限はTIMEZONEの0時に戻る
+			now := time.Now().In(week.Location())
+
+			var t *model.Tenant
+			var k *model.APIKey
+			var err error
+			key := apiKey(req)
+			if key == "" {
+				if key = webSocketToken(req); key != "" && !authtoken.IsToken(key) {
+					return echo.NewHTTPError(http.StatusUnauthorized, "invalid access token")
+				}
+			}
+			switch {
+			case key != "" && tokens != nil && authtoken.IsToken(key):
+				claims, verr := tokens.Verify(key, now)
+				if errors.Is(verr, authtoken.ErrExpired) {
+					return echo.NewHTTPError(http.StatusUnauthorized, "access token expired")
+				} else if verr != nil {
+					return echo.NewHTTPError(http.StatusUnauthorized, "invalid access token")
+				}
+				t, k, err = tenants.AuthenticateKey(claims.APIKeyID, now)
+				if errors.Is(err, gorm.ErrRecordNotFound) || err == nil && t.ID != claims.TenantID {
+					return echo.NewHTTPError(http.StatusUnauthorized, "invalid access token")
+				}
+			case key != "":
+				if !tenant.ValidAPIKey(key) {
+					return echo.NewHTTPError(http.StatusUnauthorized, "invalid api key")
+				}
+				t, k, err = tenants.Authenticate(tenant.HashAPIKey(key), now)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return echo.NewHTTPError(http.StatusUnauthorized, "invalid api key")
+				}
+			case requireKey:
+				return echo.NewHTTPError(http.StatusUnauthorized, "api key is required")
+			default:
+				t, err = tenants.Get(model.DefaultTenantID)
+			}
+			if err != nil {
+				logger(c).Error("テナントの取得失敗", "error", err)
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to authenticate")
+			}
+
+			result, limited, err := quota.Check(quotas.WithContext(req.Context()), quota.Limits(t, k), now)
+			if err != nil {
+				logger(c).Error("リクエスト数の記録失敗", "tenant_id", t.ID, "error", err)
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to authenticate")
+			}
+			if limited {
+				h := c.Response().Header()
+				if result.Exceeded {
+					// 上限を超えた場合は、RateLimitの短い間隔の上限よりも先にこの上限を伝える
+					h.Del(RateLimitRemainingHeader)
+				}
+				setRateLimitHeaders(h, result.Limit, result.Remaining, result.Reset)
+				if result.Exceeded {
+					h.Set(echo.HeaderRetryAfter, strconv.Itoa(int(result.Reset.Sub(now).Seconds())+1))
+					if result.Period == quota.Month {
+						return echo.NewHTTPError(http.StatusTooManyRequests, "monthly request quota exceeded")
+					}
+					return echo.NewHTTPError(http.StatusTooManyRequests, "daily request quota exceeded")
+				}
+			}
+			ctx := tenant.NewContext(req.Context(), t)
+			if k != nil {
+				ctx = tenant.WithScopes(tenant.WithAPIKey(ctx, k.ID), k.Scopes)
+				if (req.Method == http.MethodGet || req.Method == http.MethodHead) && !k.HasScope(model.ScopeRead) {
+					return echo.NewHTTPError(http.StatusForbidden, "api key does not have the read scope")
+				}
+			}
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// apiKeyはリクエストのAPIキーを返します。渡されていなければ空文字列です。
+func apiKey(req *http.Request) string {
+	if key := req.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(req.Header.Get(echo.HeaderAuthorization), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// webSocketTokenはWebSocketの接続のSec-WebSocket-Protocolかクエリで渡されたアクセストークンを返します。
+// WebSocketの接続でないか、渡されていなければ空文字列です。
+func webSocketToken(req *http.Request) string {
+	if !strings.EqualFold(req.Header.Get(echo.HeaderUpgrade), "websocket") {
+		return ""
+	}
+	var protocols []string
+	for _, h := range req.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(h, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+	if i := slices.Index(protocols, AccessTokenProtocol); i >= 0 && i+1 < len(protocols) {
+		return protocols[i+1]
+	}
+	return req.URL.Query().Get(accessTokenParam)
+}
+
+// RecordUsageはリクエストの利用量をテナントとAPIキーごとに記録するミドルウェアを返します。TenantAuthの後に登録してください。
+// 記録に失敗してもリクエストは失敗させず、ログに出力するのみとします。
+func RecordUsage(repo *repository.UsageRepository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// 応答のステータスとサイズを記録するため、エラーはここで応答にする
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			req, resp := c.Request(), c.Response()
+			ctx := req.Context()
+			usage := model.APIUsage{
+				TenantID:      tenant.ID(ctx),
+				APIKeyID:      tenant.APIKeyID(ctx),
+				Day:           week.Day(time.Now()),
+				Endpoint:      req.Method + " " + c.Path(),
+				Requests:      1,
+				RequestBytes:  max(req.ContentLength, 0),
+				ResponseBytes: resp.Size,
+			}
+			if resp.Status >= http.StatusBadRequest {
+				usage.Errors = 1
+			}
+			// クライアントが切断した場合も記録する
+			if err := repo.WithContext(context.WithoutCancel(ctx)).Record(usage); err != nil {
+				logger(c).Warn("APIの利用量の記録に失敗しました", "error", err)
+			}
+			return nil
+		}
+	}
+}
+
+// authenticatedはAPIキーかアクセストークンのないリクエストに401を返すミドルウェアです。
+// REQUIRE_API_KEY=falseでAPIキーのないリクエストを既定のテナントとして受け付ける場合も、
+// データを変更するエンドポイントは誰でも呼び出せないようにするために使います。
+func authenticated(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if tenant.APIKeyID(c.Request().Context()) == 0 {
+			return echo.NewHTTPError(http.StatusUnauthorized, "authentication is required")
+		}
+		return next(c)
+	}
+}
+
+// writableはAPIキーかアクセストークンのないリクエストに401を、APIキーにwriteのスコープがなければ403を返すミドルウェアです。
+// データを変更するエンドポイントに使います。
+func writable(next echo.HandlerFunc) echo.HandlerFunc {
+	return authenticated(func(c echo.Context) error {
+		if !tenant.HasScope(c.Request().Context(), model.ScopeWrite) {
+			return echo.NewHTTPError(http.StatusForbidden, "api key does not have the write scope")
+		}
+		return next(c)
+	})
+}
+
+// operatorOnlyは既定のテナント(デプロイの運用者)のAPIキーかアクセストークンのないリクエストに401か403を返すミドルウェアです。
+// Webhookや実行履歴のように、テナントで分けられないデプロイ全体の情報を扱うエンドポイントに使います。
+func operatorOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return authenticated(func(c echo.Context) error {
+		if tenant.ID(c.Request().Context()) != model.DefaultTenantID {
+			return echo.NewHTTPError(http.StatusForbidden, "this endpoint is only available to the operator")
+		}
+		return next(c)
+	})
+}
+
+// checkTopicはトピックがリクエストのテナントのものか確認します。他のテナントのトピックは存在しないものとして400を返します。
+func checkTopic(c echo.Context, topics *repository.TopicRepository, topicID uint) error {
+	ctx := c.Request().Context()
+	if _, err := topics.WithContext(ctx).ForTenant(tenant.ID(ctx)).GetTopic(topicID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusBadRequest, "topic not found")
+		}
+		logger(c).Error("トピックの取得失敗", "topic_id", topicID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get topic")
+	}
+	return nil
+}
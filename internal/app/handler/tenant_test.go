@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/authtoken"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+	"excavation_service/internal/app/testdb"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
+}
+
+// createKeyはテナントtenantIDのscopesのAPIキーを作成し、キーそのものと保存したAPIキーを返します。
+func createKey(t *testing.T, repo *repository.TenantRepository, tenantID uint, scopes ...string) (string, *model.APIKey) {
+	t.Helper()
+	key, apiKey, err := tenant.NewAPIKey(tenantID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKey.Scopes = scopes
+	if err := repo.CreateAPIKey(apiKey); err != nil {
+		t.Fatal(err)
+	}
+	return key, apiKey
+}
+
+// authTestServerはRegisterRoutesと同じくTenantAuthの後にauthenticated・writable・operatorOnlyを付けたエンドポイントを用意します。
+func authTestServer(db *gorm.DB, requireKey bool, tokens *authtoken.Signer) *echo.Echo {
+	e := echo.New()
+	api := e.Group("/api", TenantAuth(repository.NewTenantRepository(db), repository.NewQuotaRepository(db), requireKey, tokens))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	api.GET("/items", ok)
+	api.POST("/items", ok, writable)
+	api.POST("/auth/token", ok, authenticated)
+	api.GET("/admin/jobs", ok, operatorOnly)
+	return e
+}
+
+// TestTenantAuthはAPIキーとアクセストークンの認証と、スコープ・運用者の確認の結果のステータスを確認します。
+func TestTenantAuth(t *testing.T) {
+	db := testdb.Postgres(t)
+	repo := repository.NewTenantRepository(db)
+	other := model.Tenant{Slug: "other", Name: "取引先"}
+	if err := repo.Create(&other); err != nil {
+		t.Fatal(err)
+	}
+	operatorKey, operatorAPIKey := createKey(t, repo, model.DefaultTenantID, model.APIKeyScopes...)
+	readOnlyKey, _ := createKey(t, repo, model.DefaultTenantID, model.ScopeRead)
+	otherKey, _ := createKey(t, repo, other.ID, model.APIKeyScopes...)
+	_, revokedAPIKey := createKey(t, repo, model.DefaultTenantID, model.APIKeyScopes...)
+
+	signer, err := authtoken.NewSigner("0123456789abcdef0123456789abcdef", 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	issue := func(tenantID, apiKeyID uint, at time.Time) string {
+		token, _, err := signer.Issue(tenantID, apiKeyID, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	validToken := issue(model.DefaultTenantID, operatorAPIKey.ID, now)
+	expiredToken := issue(model.DefaultTenantID, operatorAPIKey.ID, now.Add(-time.Hour))
+	revokedToken := issue(model.DefaultTenantID, revokedAPIKey.ID, now)
+	// 運用者のAPIキーに取引先のテナントを組み合わせたトークン
+	mismatchToken := issue(other.ID, operatorAPIKey.ID, now)
+	if err := repo.RevokeAPIKey(revokedAPIKey.ID, now); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		requireKey bool
+		method     string
+		path       string
+		key        string
+		want       int
+	}{
+		{"api key", true, http.MethodGet, "/api/items", operatorKey, http.StatusOK},
+		{"access token", true, http.MethodPost, "/api/items", validToken, http.StatusOK},
+		{"missing key", true, http.MethodGet, "/api/items", "", http.StatusUnauthorized},
+		{"unknown key", true, http.MethodGet, "/api/items", tenant.KeyPrefix + strings.Repeat("0", 48), http.StatusUnauthorized},
+		{"expired token", true, http.MethodGet, "/api/items", expiredToken, http.StatusUnauthorized},
+		{"revoked key token", true, http.MethodGet, "/api/items", revokedToken, http.StatusUnauthorized},
+		{"tenant mismatch", true, http.MethodGet, "/api/items", mismatchToken, http.StatusUnauthorized},
+		{"read-only key write", true, http.MethodPost, "/api/items", readOnlyKey, http.StatusForbidden},
+		{"read-only key read", true, http.MethodGet, "/api/items", readOnlyKey, http.StatusOK},
+		{"non-operator admin", true, http.MethodGet, "/api/admin/jobs", otherKey, http.StatusForbidden},
+		{"operator admin", true, http.MethodGet, "/api/admin/jobs", operatorKey, http.StatusOK},
+		// REQUIRE_API_KEY=falseでも、APIキーのないリクエストはデータを変更できない
+		{"no key read", false, http.MethodGet, "/api/items", "", http.StatusOK},
+		{"no key write", false, http.MethodPost, "/api/items", "", http.StatusUnauthorized},
+		{"no key token", false, http.MethodPost, "/api/auth/token", "", http.StatusUnauthorized},
+		{"no key admin", false, http.MethodGet, "/api/admin/jobs", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := authTestServer(db, tt.requireKey, signer)
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.key)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("%s %s: status = %d, want %d (%s)", tt.method, tt.path, rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+	"excavation_service/internal/app/topics"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// TopicHandler はエンティティのトピック(発掘で使う検索クエリ)のエンドポイントを提供します。
+// トピックはリクエストのテナントのエンティティのもののみ扱い、作成・変更・削除はCLIと同じく監査ログに記録します。
+type TopicHandler struct {
+	db     *gorm.DB
+	topics *repository.TopicRepository
+}
+
+func NewTopicHandler(db *gorm.DB, topics *repository.TopicRepository) *TopicHandler {
+	return &TopicHandler{db: db, topics: topics}
+}
+
+type topicRequest struct {
+	Topic    *string `json:"topic"`
+	Priority *int    `json:"priority"`
+	Disabled *bool   `json:"disabled"`
+}
+
+type topicResponse struct {
+	ID         uint       `json:"id"`
+	EntityID   uint       `json:"entity_id"`
+	Topic      string     `json:"topic"`
+	Priority   int        `json:"priority"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	LatestWeek string     `json:"latest_week,omitempty"` // 直近のトレンドの週。一覧でのみ返す
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newTopicResponse(t model.EntityTopic) topicResponse {
+	return topicResponse{ID: t.ID, EntityID: t.EntityID, Topic: t.Topic, Priority: t.Priority, DisabledAt: t.DisabledAt, CreatedAt: t.CreatedAt}
+}
+
+// apiActorは監査ログに記録するAPIのリクエストの操作者です。APIキーで認証したリクエストはapi_key:<APIキーのID>です。
+func apiActor(c echo.Context) string {
+	actor := "api"
+	if id := tenant.APIKeyID(c.Request().Context()); id != 0 {
+		actor = fmt.Sprintf("api_key:%d", id)
+	}
+	return actor
+}
+
+// entityIDはパスのエンティティのIDを返し、リクエストのテナントのエンティティであることを確認します。
+func (h *TopicHandler) entityID(c echo.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	ctx := c.Request().Context()
+	if _, err := h.topics.WithContext(ctx).ForTenant(tenant.ID(ctx)).GetEntity(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, echo.NewHTTPError(http.StatusNotFound, "entity not found")
+		}
+		logger(c).Error("エンティティの取得失敗", "error", err)
+		return 0, echo.NewHTTPError(http.StatusInternalServerError, "failed to get entity")
+	}
+	return uint(id), nil
+}
+
+// topicIDはパスのトピックのIDを返します。
+func topicID(c echo.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("topic_id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid topic_id")
+	}
+	return uint(id), nil
+}
+
+// Listはエンティティのトピックの一覧をID順に返します。無効化したトピックも含みます。
+// GET /api/v1/entities/:id/topics?limit=100&cursor=... 次のページがあれば、そのcursorをX-Next-Cursorヘッダーで返します。
+func (h *TopicHandler) List(c echo.Context) error {
+	entityID, err := h.entityID(c)
+	if err != nil {
+		return err
+	}
+	limit, err := listLimit(c)
+	if err != nil {
+		return err
+	}
+	cursor, err := pageCursor(c)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	items, err := h.topics.WithContext(ctx).ForTenant(tenant.ID(ctx)).ListEntityTopics(entityID, cursor, limit+1)
+	if err != nil {
+		logger(c).Error("トピックの一覧取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list topics")
+	}
+	if len(items) > limit {
+		items = items[:limit]
+		setNextCursor(c, repository.Cursor{ID: items[limit-1].ID})
+	}
+	resp := make([]topicResponse, len(items))
+	for i, item := range items {
+		resp[i] = topicResponse{ID: item.ID, EntityID: item.EntityID, Topic: item.Topic, Priority: item.Priority, DisabledAt: item.DisabledAt, CreatedAt: item.CreatedAt}
+		if item.LatestWeek != nil {
+			resp[i].LatestWeek = item.LatestWeek.Format(week.Layout)
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Getはエンティティのトピックを返します。 GET /api/v1/entities/:id/topics/:topic_id
+func (h *TopicHandler) Get(c echo.Context) error {
+	entityID, err := h.entityID(c)
+	if err != nil {
+		return err
+	}
+	id, err := topicID(c)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	topic, err := h.topics.WithContext(ctx).ForTenant(tenant.ID(ctx)).GetTopic(id)
+	if err == nil && topic.EntityID != entityID {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "topic not found")
+		}
+		logger(c).Error("トピックの取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get topic")
+	}
+	return c.JSON(http.StatusOK, newTopicResponse(*topic))
+}
+
+// Createはエンティティにトピックを登録します。topicを省略した場合はエンティティ名を使います。
+// POST /api/v1/entities/:id/topics
+func (h *TopicHandler) Create(c echo.Context) error {
+	entityID, err := h.entityID(c)
+	if err != nil {
+		return err
+	}
+	var req topicRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	ctx := c.Request().Context()
+	var topicText string
+	if req.Topic != nil {
+		topicText = strings.TrimSpace(*req.Topic)
+	}
+	if topicText == "" {
+		// CLIのtopics addと同じく、省略時はエンティティ名で検索する
+		entity, err := h.topics.WithContext(ctx).ForTenant(tenant.ID(ctx)).GetEntity(entityID)
+		if err != nil {
+			logger(c).Error("エンティティの取得失敗", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get entity")
+		}
+		topicText = entity.Name
+	}
+	var priority int
+	if req.Priority != nil {
+		priority = *req.Priority
+	}
+
+	topic, err := topics.Create(ctx, h.db, apiActor(c), tenant.ID(ctx), entityID, topicText, priority)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "entity not found")
+		case errors.Is(err, topics.ErrTopicExists):
+			return echo.NewHTTPError(http.StatusConflict, "topic already exists")
+		case errors.Is(err, topics.ErrQuotaExceeded):
+			return echo.NewHTTPError(http.StatusForbidden, "topic quota of the tenant exceeded")
+		}
+		logger(c).Error("トピックの登録失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create topic")
+	}
+	return c.JSON(http.StatusCreated, newTopicResponse(*topic))
+}
+
+// Updateはトピックの文字列・優先度・無効化を変更します。省略した項目は変更しません。
+// PUT /api/v1/entities/:id/topics/:topic_id
+func (h *TopicHandler) Update(c echo.Context) error {
+	entityID, err := h.entityID(c)
+	if err != nil {
+		return err
+	}
+	id, err := topicID(c)
+	if err != nil {
+		return err
+	}
+	var req topicRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	changes := topics.Changes{Priority: req.Priority, Disabled: req.Disabled}
+	if req.Topic != nil {
+		t := strings.TrimSpace(*req.Topic)
+		if t == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "topic must not be empty")
+		}
+		changes.Topic = &t
+	}
+
+	ctx := c.Request().Context()
+	topic, err := topics.Update(ctx, h.db, apiActor(c), tenant.ID(ctx), entityID, id, changes, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "topic not found")
+		case errors.Is(err, topics.ErrTopicExists):
+			return echo.NewHTTPError(http.StatusConflict, "topic already exists")
+		}
+		logger(c).Error("トピックの更新失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update topic")
+	}
+	return c.JSON(http.StatusOK, newTopicResponse(*topic))
+}
+
+// Deleteはトピックを削除します。トピックのトレンドや言及も削除されるため、発掘を止めるだけなら無効化(disabled)してください。
+// DELETE /api/v1/entities/:id/topics/:topic_id
+func (h *TopicHandler) Delete(c echo.Context) error {
+	entityID, err := h.entityID(c)
+	if err != nil {
+		return err
+	}
+	id, err := topicID(c)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	if err := topics.Delete(ctx, h.db, apiActor(c), tenant.ID(ctx), entityID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "topic not found")
+		}
+		logger(c).Error("トピックの削除失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete topic")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
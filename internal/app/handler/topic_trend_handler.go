@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// TopicTrendHandler はトピックのトレンドのスコアの推移(グラフ用の時系列)のエンドポイントを提供します。
+type TopicTrendHandler struct {
+	topics *repository.TopicRepository
+	trends *repository.TrendRepository
+}
+
+func NewTopicTrendHandler(topics *repository.TopicRepository, trends *repository.TrendRepository) *TopicTrendHandler {
+	return &TopicTrendHandler{topics: topics, trends: trends}
+}
+
+type trendPoint struct {
+	Period       string  `json:"period"` // 週の月曜日、または月の初日(YYYY-MM-DD)
+	Score        float64 `json:"score"`
+	GPTScore     float64 `json:"gpt_score"`
+	MentionCount int     `json:"mention_count"`
+	Weeks        int     `json:"weeks"`
+}
+
+type topicTrendsResponse struct {
+	TopicID  uint         `json:"topic_id"`
+	EntityID uint         `json:"entity_id"`
+	Topic    string       `json:"topic"`
+	Interval string       `json:"interval"`
+	Points   []trendPoint `json:"points"`
+}
+
+// Historyはトピックのトレンドのスコアの推移を古い順に返します。intervalがmonthの場合は月ごとの平均に間引きます。
+// GET /api/v1/topics/:id/trends?interval=month&since=YYYY-MM-DD&until=YYYY-MM-DD (いずれも省略可。intervalの既定はweek)
+// 週のない(トレンドを保存していない)期間の点は返さないため、グラフでは点の間を補間してください。
+func (h *TopicTrendHandler) History(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	interval := c.QueryParam("interval")
+	if interval == "" {
+		interval = repository.IntervalWeek
+	}
+	if !slices.Contains(repository.HistoryIntervals, interval) {
+		return echo.NewHTTPError(http.StatusBadRequest, "interval must be any of "+strings.Join(repository.HistoryIntervals, ", "))
+	}
+	since, until, err := trendWeeks(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	topic, err := h.topics.WithContext(ctx).ForTenant(tenant.ID(ctx)).GetTopic(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "topic not found")
+		}
+		logger(c).Error("トピックの取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get topic")
+	}
+	points, err := h.trends.WithContext(ctx).ForTenant(tenant.ID(ctx)).History(topic.ID, interval, since, until)
+	if err != nil {
+		logger(c).Error("トレンドの推移の取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get trends")
+	}
+
+	resp := topicTrendsResponse{TopicID: topic.ID, EntityID: topic.EntityID, Topic: topic.Topic, Interval: interval, Points: make([]trendPoint, len(points))}
+	for i, p := range points {
+		resp.Points[i] = trendPoint{Period: p.Period.Format(week.Layout), Score: p.Score, GPTScore: p.GPTScore, MentionCount: p.MentionCount, Weeks: p.Weeks}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
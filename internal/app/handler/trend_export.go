@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"encoding/csv"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+)
+
+// exportPageSize はCSVの書き出しで1回のクエリで読むトレンドの数です。
+const exportPageSize = 1000
+
+// トレンドの書き出しの形式
+const (
+	exportFormatCSV   = "csv"
+	exportFormatExcel = "excel" // Excelでそのまま開けるCSV。BOMを付けないとExcelはShift_JISとして読み、日本語が文字化けする
+)
+
+var exportFormats = []string{exportFormatCSV, exportFormatExcel}
+
+// utf8BOM はExcelにUTF-8であることを伝えるため、excel形式のCSVの先頭に書くバイト列です。
+const utf8BOM = "\ufeff"
+
+var trendExportHeader = []string{
+	"id", "topic_id", "topic", "entity_name", "entity_type", "week",
+	"score", "gpt_score", "delta", "mention_count", "top_title", "created_at",
+}
+
+// ExportはトレンドをID順にCSVで返します。formatがexcelの場合はExcelでそのまま開けるよう、BOMと改行CRLFを付けます。
+// GET /api/v1/trends/export?format=csv&since=YYYY-MM-DD&until=YYYY-MM-DD&topic_id=1&type=onsen&min_score=70&max_score=100
+// (いずれも省略可。formatの既定はcsv、since・untilはトレンドの週、typeはエンティティの種別)。/api/v1/trends/export.csvも同じです。
+// 件数が多くてもメモリに溜め込まず、IDをキーにページごとに読みながらチャンク転送で書き出します。
+func (h *TrendHandler) Export(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = exportFormatCSV
+	}
+	if !slices.Contains(exportFormats, format) {
+		return echo.NewHTTPError(http.StatusBadRequest, "format must be any of "+strings.Join(exportFormats, ", "))
+	}
+	f, err := trendFilter(c)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	trends := h.repo.WithContext(ctx).ForTenant(tenant.ID(ctx))
+
+	// 最初のページを読んでから応答を始め、クエリの失敗は500で返せるようにする
+	page, err := trends.ExportPage(f, 0, exportPageSize)
+	if err != nil {
+		logger(c).Error("書き出すトレンドの取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to export trends")
+	}
+
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	resp.Header().Set(echo.HeaderContentDisposition, `attachment; filename="trends.csv"`)
+	resp.WriteHeader(http.StatusOK)
+	w := csv.NewWriter(resp)
+	if format == exportFormatExcel {
+		resp.Write([]byte(utf8BOM))
+		w.UseCRLF = true
+	}
+	w.Write(trendExportHeader)
+
+	rows := 0
+	for len(page) > 0 {
+		for _, t := range page {
+			w.Write(trendExportRecord(t, format == exportFormatExcel))
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			// クライアントが切断した
+			logger(c).Warn("トレンドのCSVの書き出しを中断しました", "rows", rows, "error", err)
+			return nil
+		}
+		resp.Flush()
+		rows += len(page)
+		if len(page) < exportPageSize {
+			break
+		}
+		if page, err = trends.ExportPage(f, page[len(page)-1].ID, exportPageSize); err != nil {
+			// 応答を始めた後はステータスを変えられないため、接続を切って不完全なCSVであることをクライアントに伝える
+			logger(c).Error("書き出すトレンドの取得失敗", "rows", rows, "error", err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+	logger(c).Info("トレンドをCSVで書き出しました", "format", format, "rows", rows)
+	return nil
+}
+
+// trendFilterはsince・until・topic_id・type・min_score・max_scoreのクエリパラメータからトレンドの条件を返します。
+// sinceとuntilはトレンドの週、typeはエンティティの種別、min_score・max_scoreはスコアの範囲です。
+func trendFilter(c echo.Context) (repository.TrendExportFilter, error) {
+	var f repository.TrendExportFilter
+	var err error
+	if f.Since, f.Until, err = trendWeeks(c); err != nil {
+		return f, err
+	}
+	if s := c.QueryParam("topic_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || id == 0 {
+			return f, echo.NewHTTPError(http.StatusBadRequest, "invalid topic_id")
+		}
+		f.TopicID = uint(id)
+	}
+	if f.EntityType = c.QueryParam("type"); f.EntityType != "" && !slices.Contains(model.EntityTypes, f.EntityType) {
+		return f, echo.NewHTTPError(http.StatusBadRequest, "type must be any of "+strings.Join(model.EntityTypes, ", "))
+	}
+	if s := c.QueryParam("min_score"); s != "" {
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return f, echo.NewHTTPError(http.StatusBadRequest, "min_score must be a number")
+		}
+		f.MinScore = &n
+	}
+	if s := c.QueryParam("max_score"); s != "" {
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return f, echo.NewHTTPError(http.StatusBadRequest, "max_score must be a number")
+		}
+		f.MaxScore = &n
+	}
+	return f, nil
+}
+
+// trendWeeksはsince・untilのクエリパラメータからトレンドの週の範囲を返します。省略した方はゼロ値です。
+func trendWeeks(c echo.Context) (since, until time.Time, err error) {
+	if s := c.QueryParam("since"); s != "" {
+		if since, err = week.Parse(s); err != nil {
+			return since, until, echo.NewHTTPError(http.StatusBadRequest, "since must be YYYY-MM-DD")
+		}
+	}
+	if s := c.QueryParam("until"); s != "" {
+		if until, err = week.Parse(s); err != nil {
+			return since, until, echo.NewHTTPError(http.StatusBadRequest, "until must be YYYY-MM-DD")
+		}
+	}
+	if !since.IsZero() && !until.IsZero() && since.After(until) {
+		return since, until, echo.NewHTTPError(http.StatusBadRequest, "since must not be after until")
+	}
+	return since, until, nil
+}
+
+// trendExportRecordはトレンドのCSVの行です。excelの場合は、検索結果のページのタイトルやトピックのような文字列の列を
+// Excelが数式として実行しないようにします(excelText)。
+func trendExportRecord(t repository.TrendExportRow, excel bool) []string {
+	delta := ""
+	if t.Delta != nil {
+		delta = strconv.FormatFloat(*t.Delta, 'f', -1, 64)
+	}
+	text := func(s string) string { return s }
+	if excel {
+		text = excelText
+	}
+	return []string{
+		strconv.FormatUint(uint64(t.ID), 10),
+		strconv.FormatUint(uint64(t.TopicID), 10),
+		text(t.Topic),
+		text(t.EntityName),
+		text(t.EntityType),
+		t.Week.Format(week.Layout),
+		strconv.FormatFloat(t.Score, 'f', -1, 64),
+		strconv.FormatFloat(t.GPTScore, 'f', -1, 64),
+		delta,
+		strconv.Itoa(t.MentionCount),
+		text(t.TopTitle),
+		t.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// excelTextは数式として解釈される文字(=、+、-、@、タブ、CR)で始まる文字列の先頭に'を付け、Excelで文字列として表示させます(CSVインジェクションの対策)。
+func excelText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
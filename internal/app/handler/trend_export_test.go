@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/repository"
+)
+
+func TestTrendExportRecordExcel(t *testing.T) {
+	delta := -3.5
+	row := repository.TrendExportRow{ID: 1, TopicID: 2, Topic: "+西日暮里 カレー", EntityName: "@西日暮里", EntityType: "restaurant",
+		Week: time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), Score: 80, GPTScore: 75, Delta: &delta, MentionCount: 4,
+		TopTitle: `=HYPERLINK("http://example.com","x")`, CreatedAt: time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)}
+
+	for _, tt := range []struct {
+		excel bool
+		want  map[int]string // key: 列の位置
+	}{
+		{false, map[int]string{2: "+西日暮里 カレー", 3: "@西日暮里", 4: "restaurant", 8: "-3.5", 10: `=HYPERLINK("http://example.com","x")`}},
+		// 文字列の列のみ'を付け、負の数の列はそのまま数値として読ませる
+		{true, map[int]string{2: "'+西日暮里 カレー", 3: "'@西日暮里", 4: "restaurant", 8: "-3.5", 10: `'=HYPERLINK("http://example.com","x")`}},
+	} {
+		record := trendExportRecord(row, tt.excel)
+		for i, want := range tt.want {
+			if record[i] != want {
+				t.Errorf("excel=%v: record[%d] = %q, want %q", tt.excel, i, record[i], want)
+			}
+		}
+	}
+}
+
+func TestExcelText(t *testing.T) {
+	for in, want := range map[string]string{
+		"":            "",
+		"ほし":          "ほし",
+		"=1+1":        "'=1+1",
+		"-2+3":        "'-2+3",
+		"\t=1":        "'\t=1",
+		"\r=1":        "'\r=1",
+		"カレー =1":      "カレー =1",
+		"@SUM(A1:A2)": "'@SUM(A1:A2)",
+	} {
+		if got := excelText(in); got != want {
+			t.Errorf("excelText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultMoversLimit  = 10
+	maxMoversLimit      = 100
+	defaultTrendsLimit  = 50
+	maxTrendsLimit      = 200
+	defaultRankingLimit = 50
+	maxRankingLimit     = 200
+)
+
+// TrendHandler はトピックのトレンドに関するエンドポイントを提供します。リクエストのテナントのトピックのみ返します。
+type TrendHandler struct {
+	repo *repository.TrendRepository
+}
+
+func NewTrendHandler(repo *repository.TrendRepository) *TrendHandler {
+	return &TrendHandler{repo: repo}
+}
+
+type moversResponse struct {
+	Week    string                  `json:"week"`
+	Gainers []repository.TopicMover `json:"gainers"`
+	Losers  []repository.TopicMover `json:"losers"`
+}
+
+// Moversは前週比でスコアが大きく上昇・下落したトピックを返します。
+// GET /api/v1/trends/movers?week=YYYY-MM-DD&limit=10 (weekを省略した場合はトレンドが存在する最新の週)
+func (h *TrendHandler) Movers(c echo.Context) error {
+	ctx := c.Request().Context()
+	trends := h.repo.WithContext(ctx).ForTenant(tenant.ID(ctx))
+	limit := defaultMoversLimit
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxMoversLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 100")
+		}
+		limit = n
+	}
+
+	var w time.Time
+	if s := c.QueryParam("week"); s != "" {
+		parsed, err := week.Parse(s)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "week must be in YYYY-MM-DD format")
+		}
+		w = parsed
+	} else {
+		latest, err := trends.LatestWeek()
+		if err != nil {
+			logger(c).Error("最新週の取得失敗", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get movers")
+		}
+		if latest == nil {
+			return c.JSON(http.StatusOK, moversResponse{Gainers: []repository.TopicMover{}, Losers: []repository.TopicMover{}})
+		}
+		w = *latest
+	}
+
+	gainers, losers, err := trends.Movers(w, limit)
+	if err != nil {
+		logger(c).Error("変動トピックの取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get movers")
+	}
+	if gainers == nil {
+		gainers = []repository.TopicMover{}
+	}
+	if losers == nil {
+		losers = []repository.TopicMover{}
+	}
+	return c.JSON(http.StatusOK, moversResponse{Week: w.Format(week.Layout), Gainers: gainers, Losers: losers})
+}
+
+type trendItem struct {
+	ID           uint      `json:"id"`
+	TopicID      uint      `json:"topic_id"`
+	Topic        string    `json:"topic"`
+	EntityName   string    `json:"entity_name"`
+	EntityType   string    `json:"entity_type"`
+	Week         string    `json:"week"`
+	Score        float64   `json:"score"`
+	GPTScore     float64   `json:"gpt_score"`
+	Delta        *float64  `json:"delta,omitempty"`
+	MentionCount int       `json:"mention_count"`
+	TopTitle     string    `json:"top_title"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type trendsResponse struct {
+	Trends     []trendItem `json:"trends"`
+	NextCursor string      `json:"next_cursor,omitempty"` // 次のページのcursor。最後のページでは省略する
+}
+
+// Listはトレンドを新しい週から順(同じ週は保存の新しい順)にページごとに返します。
+// GET /api/v1/trends?topic_id=1&since=YYYY-MM-DD&until=YYYY-MM-DD&type=onsen&min_score=70&max_score=100&sort=-score,week&limit=50&cursor=...
+// (いずれも省略可。絞り込みはCSVの書き出しと同じ)。sortはカンマ区切りの並べ替える項目(-を付けると降順)で、省略した場合は-weekです。
+// 次のページは応答のnext_cursorを、同じsortとともにcursorに指定して取得します。cursorは並び順の項目の値とIDを符号化した
+// 不透明な文字列で、(並び順の項目, ID)をキーに読むため、後ろのページでも遅くなりません。
+func (h *TrendHandler) List(c echo.Context) error {
+	f, err := trendFilter(c)
+	if err != nil {
+		return err
+	}
+	limit := defaultTrendsLimit
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxTrendsLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 200")
+		}
+		limit = n
+	}
+	sort, err := parseSort(c, repository.TrendSortFields)
+	if err != nil {
+		return err
+	}
+	cursor, err := pageCursor(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	// 1件多く読み、次のページがあるかを判定する
+	rows, err := h.repo.WithContext(ctx).ForTenant(tenant.ID(ctx)).ListPage(f, sort, cursor, limit+1)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+	}
+	if err != nil {
+		logger(c).Error("トレンドの一覧取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list trends")
+	}
+	var resp trendsResponse
+	if len(rows) > limit {
+		rows = rows[:limit]
+		resp.NextCursor = rows[limit-1].Cursor(sort).String()
+	}
+	resp.Trends = make([]trendItem, len(rows))
+	for i, t := range rows {
+		resp.Trends[i] = trendItem{
+			ID: t.ID, TopicID: t.TopicID, Topic: t.Topic, EntityName: t.EntityName, EntityType: t.EntityType,
+			Week: t.Week.Format(week.Layout), Score: t.Score, GPTScore: t.GPTScore, Delta: t.Delta,
+			MentionCount: t.MentionCount, TopTitle: t.TopTitle, CreatedAt: t.CreatedAt,
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+type rankingResponse struct {
+	Week    string                   `json:"week"`     // 週の月曜日(YYYY-MM-DD)
+	ISOWeek string                   `json:"iso_week"` // ISO 8601の年と週番号(YYYY-WW)
+	Ranking []repository.RankedTopic `json:"ranking"`
+}
+
+// Rankingは指定週のトピックをスコアの高い順に、エンティティの名前を付けて返します。無効化されたトピックは含めません。
+// GET /api/v1/rankings?week=YYYY-WW&limit=50 (weekはYYYY-MM-DDでも指定でき、省略した場合はトレンドが存在する最新の週)
+func (h *TrendHandler) Ranking(c echo.Context) error {
+	ctx := c.Request().Context()
+	trends := h.repo.WithContext(ctx).ForTenant(tenant.ID(ctx))
+	limit := defaultRankingLimit
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxRankingLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 200")
+		}
+		limit = n
+	}
+
+	var w time.Time
+	if s := c.QueryParam("week"); s != "" {
+		parsed, err := week.ParseISO(s)
+		if err != nil {
+			if parsed, err = week.Parse(s); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "week must be YYYY-WW or YYYY-MM-DD")
+			}
+		}
+		w = parsed
+	} else {
+		latest, err := trends.LatestWeek()
+		if err != nil {
+			logger(c).Error("最新週の取得失敗", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get ranking")
+		}
+		if latest == nil {
+			return c.JSON(http.StatusOK, rankingResponse{Ranking: []repository.RankedTopic{}})
+		}
+		w = *latest
+	}
+
+	ranking, err := trends.Ranking(w, limit)
+	if err != nil {
+		logger(c).Error("ランキングの取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get ranking")
+	}
+	if ranking == nil {
+		ranking = []repository.RankedTopic{}
+	}
+	return c.JSON(http.StatusOK, rankingResponse{Week: w.Format(week.Layout), ISOWeek: week.ISO(w), Ranking: ranking})
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/tenant"
+	"excavation_service/internal/app/trendstream"
+
+	"github.com/labstack/echo/v4"
+)
+
+// streamKeepAlive ごとにコメントを送り、プロキシやロードバランサーに無通信の接続として切られないようにします。
+const streamKeepAlive = 15 * time.Second
+
+// TrendStreamHandler は新しく保存されたトレンドをServer-Sent Eventsで配信するエンドポイントを提供します。
+type TrendStreamHandler struct {
+	hub *trendstream.Hub
+}
+
+func NewTrendStreamHandler(hub *trendstream.Hub) *TrendStreamHandler {
+	return &TrendStreamHandler{hub: hub}
+}
+
+// Streamは接続している間、リクエストのテナントのトピックのトレンドが保存されるたびにイベントを送ります。
+// イベントの名前はtrend.created、IDはトレンドのID、データはtrend.createdのWebhookと同じJSONです。
+// トレンドはoutboxから渡されるため、OUTBOX_RELAY_INTERVALの間隔で遅れて届きます。 GET /api/v1/trends/stream
+func (h *TrendStreamHandler) Stream(c echo.Context) error {
+	ctx := c.Request().Context()
+	events, unsubscribe := h.hub.Subscribe(tenant.ID(ctx))
+	defer unsubscribe()
+
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, "text/event-stream")
+	resp.Header().Set(echo.HeaderCacheControl, "no-cache")
+	// nginxが応答をバッファに溜めずにすぐ送るようにする
+	resp.Header().Set("X-Accel-Buffering", "no")
+	resp.WriteHeader(http.StatusOK)
+	resp.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				// APIサーバーの終了
+				return nil
+			}
+			// SSEのdataは1行で送る
+			var data bytes.Buffer
+			if err := json.Compact(&data, event.Data); err != nil {
+				logger(c).Warn("トレンドのイベントの内容が不正です", "trend_id", event.TrendID, "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(resp, "id: %d\nevent: %s\ndata: %s\n\n", event.TrendID, model.EventTrendCreated, data.Bytes()); err != nil {
+				return nil
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(resp, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+		}
+		resp.Flush()
+	}
+}
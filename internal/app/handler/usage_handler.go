@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultUsageDays は期間を指定しなかった場合に集計する日数です。
+const defaultUsageDays = 30
+
+// UsageHandler はAPIキーごとのAPIの利用量を参照するエンドポイントを提供します。
+type UsageHandler struct {
+	repo *repository.UsageRepository
+}
+
+func NewUsageHandler(repo *repository.UsageRepository) *UsageHandler {
+	return &UsageHandler{repo: repo}
+}
+
+type usageTotal struct {
+	Requests      int64 `json:"requests"`
+	Errors        int64 `json:"errors"`
+	RequestBytes  int64 `json:"request_bytes"`
+	ResponseBytes int64 `json:"response_bytes"`
+}
+
+type usageResponse struct {
+	Since string                `json:"since"`
+	Until string                `json:"until"`
+	Total usageTotal            `json:"total"`
+	Usage []repository.UsageRow `json:"usage"`
+}
+
+// Reportは期間内のAPIの利用量をテナント、APIキー、エンドポイントごとに返します。
+// GET /api/v1/admin/usage?since=2024-06-01&until=2024-06-30&tenant_id=2&api_key_id=5 (期間の既定は今日までの30日間)
+func (h *UsageHandler) Report(c echo.Context) error {
+	f := repository.UsageFilter{Until: week.Day(time.Now())}
+	if s := c.QueryParam("until"); s != "" {
+		until, err := week.ParseDay(s)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "until must be YYYY-MM-DD")
+		}
+		f.Until = until
+	}
+	f.Since = f.Until.AddDate(0, 0, -(defaultUsageDays - 1))
+	if s := c.QueryParam("since"); s != "" {
+		since, err := week.ParseDay(s)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "since must be YYYY-MM-DD")
+		}
+		f.Since = since
+	}
+	if f.Since.After(f.Until) {
+		return echo.NewHTTPError(http.StatusBadRequest, "since must not be after until")
+	}
+	if s := c.QueryParam("tenant_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid tenant_id")
+		}
+		f.TenantID = uint(id)
+	}
+	if s := c.QueryParam("api_key_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid api_key_id")
+		}
+		keyID := uint(id)
+		f.APIKeyID = &keyID
+	}
+
+	rows, err := h.repo.WithContext(c.Request().Context()).Report(f)
+	if err != nil {
+		logger(c).Error("APIの利用量の集計失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get usage")
+	}
+	resp := usageResponse{Since: f.Since.Format(week.Layout), Until: f.Until.Format(week.Layout), Usage: rows}
+	if resp.Usage == nil {
+		resp.Usage = []repository.UsageRow{}
+	}
+	for _, r := range rows {
+		resp.Total.Requests += r.Requests
+		resp.Total.Errors += r.Errors
+		resp.Total.RequestBytes += r.RequestBytes
+		resp.Total.ResponseBytes += r.ResponseBytes
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/notify"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tenant"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// WatchHandler はウォッチ(閾値アラート)のエンドポイントを提供します。
+// ウォッチはリクエストのテナントのトピックのもののみ扱います。
+type WatchHandler struct {
+	repo   *repository.WatchRepository
+	topics *repository.TopicRepository
+}
+
+func NewWatchHandler(repo *repository.WatchRepository, topics *repository.TopicRepository) *WatchHandler {
+	return &WatchHandler{repo: repo, topics: topics}
+}
+
+type createWatchRequest struct {
+	TopicID    uint     `json:"topic_id"`
+	Subscriber string   `json:"subscriber"`
+	MinScore   *float64 `json:"min_score"`
+	MinDelta   *float64 `json:"min_delta"`
+	// トピックで初めて言及された店舗を通知する
+	NotifyNewStores bool `json:"notify_new_stores"`
+}
+
+// Createはウォッチを登録します。 POST /api/v1/watches
+func (h *WatchHandler) Create(c echo.Context) error {
+	var req createWatchRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	req.Subscriber = strings.TrimSpace(req.Subscriber)
+	if req.TopicID == 0 || req.Subscriber == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "topic_id and subscriber are required")
+	}
+	if id, ok := strings.CutPrefix(req.Subscriber, notify.LINEScheme+":"); ok && !notify.ValidLINEUserID(id) {
+		return echo.NewHTTPError(http.StatusBadRequest, "subscriber must be line:<LINE user ID>")
+	}
+	if req.MinScore == nil && req.MinDelta == nil && !req.NotifyNewStores {
+		return echo.NewHTTPError(http.StatusBadRequest, "at least one of min_score, min_delta or notify_new_stores is required")
+	}
+	if req.MinDelta != nil && *req.MinDelta <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "min_delta must be positive")
+	}
+	if err := checkTopic(c, h.topics, req.TopicID); err != nil {
+		return err
+	}
+
+	watch := model.Watch{
+		TopicID:    req.TopicID,
+		Subscriber: req.Subscriber,
+		MinScore:   req.MinScore,
+		MinDelta:   req.MinDelta,
+
+		NotifyNewStores: req.NotifyNewStores,
+	}
+	if err := h.repo.WithContext(c.Request().Context()).Create(&watch); err != nil {
+		logger(c).Error("ウォッチ登録失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create watch")
+	}
+	return c.JSON(http.StatusCreated, watch)
+}
+
+// ListはウォッチをID順に返します。 GET /api/v1/watches?subscriber=...&limit=100&cursor=...
+// 次のページがあれば、そのcursorをX-Next-Cursorヘッダーで返します。
+func (h *WatchHandler) List(c echo.Context) error {
+	limit, err := listLimit(c)
+	if err != nil {
+		return err
+	}
+	cursor, err := pageCursor(c)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	watches, err := h.repo.WithContext(ctx).ForTenant(tenant.ID(ctx)).List(c.QueryParam("subscriber"), cursor, limit+1)
+	if err != nil {
+		logger(c).Error("ウォッチ一覧取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list watches")
+	}
+	if len(watches) > limit {
+		watches = watches[:limit]
+		setNextCursor(c, repository.Cursor{ID: watches[limit-1].ID})
+	}
+	return c.JSON(http.StatusOK, watches)
+}
+
+// Deleteはウォッチを削除します。 DELETE /api/v1/watches/:id
+func (h *WatchHandler) Delete(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	ctx := c.Request().Context()
+	if err := h.repo.WithContext(ctx).ForTenant(tenant.ID(ctx)).Delete(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "watch not found")
+		}
+		logger(c).Error("ウォッチ削除失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete watch")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/webhook"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 200
+)
+
+// WebhookHandler はWebhookの登録と配送ログのエンドポイントを提供します。
+type WebhookHandler struct {
+	repo *repository.WebhookRepository
+}
+
+func NewWebhookHandler(repo *repository.WebhookRepository) *WebhookHandler {
+	return &WebhookHandler{repo: repo}
+}
+
+type createWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// createWebhookResponse は登録したWebhookです。署名の鍵(secret)は登録時にのみ返します。
+type createWebhookResponse struct {
+	model.Webhook
+	Secret string `json:"secret"`
+}
+
+// CreateはWebhookを登録します。 POST /api/v1/webhooks
+func (h *WebhookHandler) Create(c echo.Context) error {
+	var req createWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "url must be an http(s) URL")
+	}
+	if len(req.Events) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "events is required")
+	}
+	for _, e := range req.Events {
+		if !slices.Contains(model.WebhookEvents, e) {
+			return echo.NewHTTPError(http.StatusBadRequest, "events must be any of "+strings.Join(model.WebhookEvents, ", "))
+		}
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		logger(c).Error("Webhookの鍵の生成失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create webhook")
+	}
+	w := model.Webhook{URL: u.String(), Events: slices.Compact(slices.Sorted(slices.Values(req.Events))), Secret: secret}
+	if err := h.repo.WithContext(c.Request().Context()).Create(&w); err != nil {
+		logger(c).Error("Webhook登録失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create webhook")
+	}
+	return c.JSON(http.StatusCreated, createWebhookResponse{Webhook: w, Secret: secret})
+}
+
+// ListはWebhookをID順に返します。 GET /api/v1/webhooks?limit=100&cursor=...
+// 次のページがあれば、そのcursorをX-Next-Cursorヘッダーで返します。
+func (h *WebhookHandler) List(c echo.Context) error {
+	limit, err := listLimit(c)
+	if err != nil {
+		return err
+	}
+	cursor, err := pageCursor(c)
+	if err != nil {
+		return err
+	}
+	webhooks, err := h.repo.WithContext(c.Request().Context()).List(cursor, limit+1)
+	if err != nil {
+		logger(c).Error("Webhook一覧取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list webhooks")
+	}
+	if len(webhooks) > limit {
+		webhooks = webhooks[:limit]
+		setNextCursor(c, repository.Cursor{ID: webhooks[limit-1].ID})
+	}
+	if webhooks == nil {
+		webhooks = []model.Webhook{}
+	}
+	return c.JSON(http.StatusOK, webhooks)
+}
+
+// DeleteはWebhookとその配送ログを削除します。 DELETE /api/v1/webhooks/:id
+func (h *WebhookHandler) Delete(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	if err := h.repo.WithContext(c.Request().Context()).Delete(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "webhook not found")
+		}
+		logger(c).Error("Webhook削除失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete webhook")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DeliveriesはWebhookの配送ログを新しい順に返します。 GET /api/v1/webhooks/:id/deliveries?status=failed&limit=50&cursor=...
+// 次のページがあれば、そのcursorをX-Next-Cursorヘッダーで返します。
+func (h *WebhookHandler) Deliveries(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	limit := defaultDeliveriesLimit
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxDeliveriesLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 200")
+		}
+		limit = n
+	}
+	cursor, err := pageCursor(c)
+	if err != nil {
+		return err
+	}
+
+	repo := h.repo.WithContext(c.Request().Context())
+	if _, err := repo.Get(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "webhook not found")
+		}
+		logger(c).Error("Webhook取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list deliveries")
+	}
+	deliveries, err := repo.ListDeliveries(uint(id), c.QueryParam("status"), cursor, limit+1)
+	if err != nil {
+		logger(c).Error("配送ログ取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list deliveries")
+	}
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		setNextCursor(c, repository.Cursor{At: deliveries[limit-1].CreatedAt, ID: deliveries[limit-1].ID})
+	}
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+	return c.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"excavation_service/internal/app/i18n"
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/week"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	widgetStoresLimit = 5
+	widgetMaxAreaLen  = 50
+)
+
+// widgetCacheControl はウィジェットのCache-Controlです。パートナーのサイトの閲覧者のブラウザから直接読まれるため、
+// 公開エンドポイントより長くブラウザにキャッシュさせ、オリジンの障害時もパートナーのサイトの表示が崩れないよう1週間は古い内容を返させます。
+const widgetCacheControl = "public, max-age=900, s-maxage=3600, stale-while-revalidate=86400, stale-if-error=604800"
+
+// widgetSchemaVersion はウィジェットの応答の形式のバージョンです。パートナーのサイトに埋め込まれ、すぐには改修されないため、
+// 既存のフィールドの名前・型・意味は変えずにフィールドの追加のみ行います。互換性のない変更は別のバージョンのエンドポイントで提供します。
+const widgetSchemaVersion = 1
+
+type widgetResponse struct {
+	SchemaVersion int           `json:"schema_version"`
+	Area          string        `json:"area"`
+	Week          *string       `json:"week"` // トレンドが1件もなければnull
+	Stores        []widgetStore `json:"stores"`
+}
+
+type widgetStore struct {
+	Rank     int     `json:"rank"`
+	Name     string  `json:"name"`
+	URL      string  `json:"url"`
+	Topic    string  `json:"topic"`
+	Score    float64 `json:"score"`
+	Summary  string  `json:"summary"`   // 1行の紹介文。Accept-Languageの言語(既定は日本語)
+	ImageURL *string `json:"image_url"` // 店舗ページの画像。未取得ならnull
+}
+
+// Widgetはパートナーのサイトに埋め込むウィジェット向けに、エリアで最新の週にトレンドになっている店舗の上位を返します。
+// GET /public/v1/widget/:area.json エリアはトピック名に含まれる地名です(例: 渋谷)。
+func (h *PublicHandler) Widget(c echo.Context) error {
+	area, ok := strings.CutSuffix(c.Param("file"), ".json")
+	if !ok {
+		return echo.ErrNotFound
+	}
+	if area == "" || utf8.RuneCountInString(area) > widgetMaxAreaLen {
+		return echo.NewHTTPError(http.StatusBadRequest, "area must be 1 to 50 characters")
+	}
+
+	ctx := c.Request().Context()
+	l := lang(c, i18n.Ja)
+	resp := widgetResponse{SchemaVersion: widgetSchemaVersion, Area: area, Stores: []widgetStore{}}
+	latest, err := h.trends.WithContext(ctx).LatestWeek()
+	if err != nil {
+		logger(c).Error("最新週の取得失敗", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get trends")
+	}
+	if latest != nil {
+		w := latest.Format(week.Layout)
+		resp.Week = &w
+		stores, err := h.stores.WithContext(ctx).TopInArea(model.DefaultTenantID, area, *latest, widgetStoresLimit)
+		if err != nil {
+			logger(c).Error("ウィジェットの店舗取得失敗", "area", area, "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get trends")
+		}
+		for i, s := range stores {
+			item := widgetStore{
+				Rank:    i + 1,
+				Name:    s.Name,
+				URL:     s.URL,
+				Topic:   s.Topic,
+				Score:   math.Round(s.Score*10) / 10,
+				Summary: i18n.Textf(l, "widget.summary", s.Topic, s.Score, s.Mentions),
+			}
+			if s.ImageURL != "" {
+				item.ImageURL = &s.ImageURL
+			}
+			resp.Stores = append(resp.Stores, item)
+		}
+	}
+
+	c.Response().Header().Add(echo.HeaderVary, "Origin")
+	return cachedJSON(c, resp, widgetCacheControl)
+}
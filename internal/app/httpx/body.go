@@ -0,0 +1,45 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrBodyTooLarge はレスポンスのボディが上限(Options.MaxBodyBytes)を超えたことを表します。
+// 上限を超えたレスポンスは途中で読むのをやめてエラーにし、大きなページでメモリを使い切らないようにします。
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// LimitBodyはbodyからmaxバイトまで読むReadCloserを返します。maxバイトを超えて読もうとするとErrBodyTooLargeを返します。
+// io.LimitReaderと違い、上限で切れたボディを正常に読み終えたものと区別できます。
+func LimitBody(body io.ReadCloser, max int64) io.ReadCloser {
+	return &limitedBody{r: io.LimitReader(body, max+1), closer: body, max: max}
+}
+
+type limitedBody struct {
+	r      io.Reader
+	closer io.Closer
+	max    int64
+	read   int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.read > l.max {
+		return 0, l.tooLarge()
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		// 上限を超えた分は返さない
+		return n - int(l.read-l.max), l.tooLarge()
+	}
+	return n, err
+}
+
+func (l *limitedBody) tooLarge() error {
+	return fmt.Errorf("%w (over %d bytes)", ErrBodyTooLarge, l.max)
+}
+
+func (l *limitedBody) Close() error {
+	return l.closer.Close()
+}
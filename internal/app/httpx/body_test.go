@@ -0,0 +1,20 @@
+package httpx
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	b, err := io.ReadAll(LimitBody(io.NopCloser(strings.NewReader("12345")), 5))
+	if err != nil || string(b) != "12345" {
+		t.Errorf("body at the limit = %q, %v", b, err)
+	}
+
+	b, err = io.ReadAll(LimitBody(io.NopCloser(strings.NewReader("123456")), 5))
+	if !errors.Is(err, ErrBodyTooLarge) || string(b) != "12345" {
+		t.Errorf("body over the limit = %q, %v, want ErrBodyTooLarge", b, err)
+	}
+}
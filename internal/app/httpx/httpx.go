@@ -0,0 +1,213 @@
+// Package httpx は外部のAPIやサイトへのリクエストに使うHTTPクライアントを作成します。
+// タイムアウト、一時的な失敗の再試行、レート制限のフック、メトリクスとログ、レスポンスのボディの上限をまとめて設定でき、
+// 検索API・GPT・クローラーのクライアントで同じ振る舞いを共有します。リクエストごとにトレースのスパンも記録します。
+package httpx
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"excavation_service/internal/app/logging"
+	"excavation_service/internal/app/tracing"
+)
+
+// metrics はクライアントごとのリクエスト数・失敗数・再試行数・ステータスコードごとの件数・処理時間(ミリ秒)を集計します。
+// キーは"<Options.Name>.<項目>"です。PPROF_ADDR設定時は/debug/varsからも参照できます。
+var metrics = expvar.NewMap("httpx")
+
+// RequestIDHeader はリクエストのコンテキストにAPIのリクエストのID(logging.RequestID)があれば付けるヘッダーです。
+// 外部API(GPTなど)の側のログと、どのAPIのリクエストの処理で送ったかを結び付けるために使います。
+const RequestIDHeader = "X-Request-ID"
+
+// maxRetryAfter はRetry-Afterで指定されても待つ時間の上限です。
+const maxRetryAfter = 30 * time.Second
+
+// Limiter はリクエストを送る前に呼び出すレート制限のフックです。
+type Limiter interface {
+	// Acquireはreqを送ってよくなるまで待ち、レスポンスのボディを閉じたときに呼ぶ関数を返します。
+	Acquire(ctx context.Context, req *http.Request) (release func(), err error)
+}
+
+// Options はクライアントの設定です。ゼロ値の項目はその機能を使いません。
+type Options struct {
+	Name         string        // メトリクスとログに使うクライアントの名前 (例: brave, gpt, crawler)
+	Timeout      time.Duration // 1回の試行(ボディの読み取りを含む)のタイムアウト
+	Retries      int           // 接続の失敗・429・502・503・504を再試行する回数
+	RetryWait    time.Duration // 最初の再試行までの待ち時間。再試行ごとに倍にする。Retry-Afterがあればそれに従う
+	MaxBodyBytes int64         // レスポンスのボディの上限。超えて読むとErrBodyTooLarge
+	Limiter      Limiter
+	Logger       *slog.Logger // nilの場合はslog.Default()
+
+	// リダイレクトを辿るかの判定。nilの場合はhttp.Clientの既定(10回まで)
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+}
+
+// Newはoptsの設定でbaseを使ってリクエストを送るクライアントを返します。baseがnilの場合はhttp.DefaultTransportを使います。
+func New(base http.RoundTripper, opts Options) *http.Client {
+	return &http.Client{Transport: Wrap(base, opts), CheckRedirect: opts.CheckRedirect}
+}
+
+// Wrapはoptsの設定でbaseを使ってリクエストを送るRoundTripperを返します。
+// テストやオフラインモードでbaseを差し替えるときに、クライアントの設定を保ったまま置き換えるために使います。
+// Timeoutは試行ごとに適用するため、再試行の待ち時間を含めた全体の時間はリクエストのctxで制限します。
+func Wrap(base http.RoundTripper, opts Options) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &transport{base: tracing.WrapTransport(base), opts: opts}
+}
+
+type transport struct {
+	base http.RoundTripper
+	opts Options
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := t.opts.Name
+	logger := t.opts.Logger
+	if id := logging.RequestID(req.Context()); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	for attempt := 0; ; attempt++ {
+		metrics.Add(name+".requests", 1)
+		start := time.Now()
+		resp, err := t.try(req, attempt)
+		elapsed := time.Since(start)
+		metrics.Add(name+".latency_ms", elapsed.Milliseconds())
+		if err != nil {
+			metrics.Add(name+".errors", 1)
+		} else {
+			metrics.Add(name+".status."+strconv.Itoa(resp.StatusCode), 1)
+		}
+
+		wait, retry := t.retryAfter(req, resp, err, attempt)
+		if !retry {
+			if err != nil {
+				logger.Debug("HTTPリクエストに失敗しました", "client", name, "method", req.Method, "host", req.URL.Host, "elapsed", elapsed, "error", err)
+			} else {
+				logger.Debug("HTTPリクエスト", "client", name, "method", req.Method, "host", req.URL.Host, "status", resp.StatusCode, "elapsed", elapsed)
+			}
+			return resp, err
+		}
+
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			// 接続を再利用できるよう、捨てるレスポンスのボディは読み切ってから閉じる
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		metrics.Add(name+".retries", 1)
+		logger.Warn("HTTPリクエストを再試行します", "client", name, "method", req.Method, "host", req.URL.Host,
+			"status", status, "error", err, "attempt", attempt+1, "retry_in", wait)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// tryはreqを1回送ります。2回目以降の試行ではボディを作り直します。
+// レート制限の枠とタイムアウトはレスポンスのボディを閉じるまで保持します。レート制限の枠を待つ時間はタイムアウトに含めません。
+func (t *transport) try(req *http.Request, attempt int) (*http.Response, error) {
+	ctx := req.Context()
+	var cleanup []func()
+	done := func() {
+		for _, f := range cleanup {
+			f()
+		}
+	}
+	if t.opts.Limiter != nil {
+		release, err := t.opts.Limiter.Acquire(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		cleanup = append(cleanup, release)
+	}
+	if t.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.opts.Timeout)
+		cleanup = append(cleanup, cancel)
+	}
+
+	r := req.WithContext(ctx)
+	if id := logging.RequestID(ctx); id != "" && r.Header.Get(RequestIDHeader) == "" {
+		// RoundTripperは渡されたリクエストを変更してはならないため、ヘッダーは複製してから付ける
+		if r.Header = r.Header.Clone(); r.Header == nil {
+			r.Header = http.Header{}
+		}
+		r.Header.Set(RequestIDHeader, id)
+	}
+	if attempt > 0 && req.Body != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			done()
+			return nil, err
+		}
+		r.Body = body
+	}
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	var body io.ReadCloser = resp.Body
+	if t.opts.MaxBodyBytes > 0 {
+		body = LimitBody(body, t.opts.MaxBodyBytes)
+	}
+	resp.Body = &closeHook{ReadCloser: body, close: done}
+	return resp, nil
+}
+
+// retryAfterは失敗した試行を再試行するかと、再試行までの待ち時間を返します。
+// ボディを作り直せないリクエストと、リクエストのctxが終了した場合は再試行しません。
+func (t *transport) retryAfter(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= t.opts.Retries || req.Context().Err() != nil || (req.Body != nil && req.GetBody == nil) {
+		return 0, false
+	}
+	wait := t.opts.RetryWait << attempt
+	if err != nil {
+		return wait, transient(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+	default:
+		return 0, false
+	}
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+		wait = min(time.Duration(s)*time.Second, maxRetryAfter)
+	}
+	return wait, true
+}
+
+// transientはerrが接続の失敗やタイムアウトなど、再試行すれば成功し得る失敗かを返します。
+// レスポンスの展開の失敗など、同じリクエストで再び失敗する誤りは再試行しません。
+func transient(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// closeHook はボディを閉じたときにcloseを1回だけ呼びます。
+type closeHook struct {
+	io.ReadCloser
+	once  sync.Once
+	close func()
+}
+
+func (b *closeHook) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.close)
+	return err
+}
@@ -0,0 +1,115 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/logging"
+)
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/flaky":
+			if calls.Add(1) < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			// 再試行でもリクエストのボディを送り直す
+			w.Write(body)
+		default:
+			calls.Add(1)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := New(nil, Options{Name: "test", Retries: 2, RetryWait: time.Hour})
+	resp, err := client.Post(srv.URL+"/flaky", "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "payload" || calls.Load() != 3 {
+		t.Errorf("status = %d, body = %q, calls = %d; want 200 payload after 3 calls", resp.StatusCode, body, calls.Load())
+	}
+
+	// 再試行しても変わらない失敗は再試行しない
+	calls.Store(0)
+	resp, err = client.Get(srv.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || calls.Load() != 1 {
+		t.Errorf("status = %d, calls = %d; want 404 without retry", resp.StatusCode, calls.Load())
+	}
+}
+
+type countingLimiter struct{ held atomic.Int32 }
+
+func (l *countingLimiter) Acquire(ctx context.Context, req *http.Request) (func(), error) {
+	l.held.Add(1)
+	return func() { l.held.Add(-1) }, nil
+}
+
+func TestLimiterAndBodyLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "0123456789")
+	}))
+	defer srv.Close()
+
+	limiter := &countingLimiter{}
+	client := New(nil, Options{Name: "test", MaxBodyBytes: 5, Limiter: limiter})
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 枠はボディを閉じるまで保持する
+	if limiter.held.Load() != 1 {
+		t.Errorf("held = %d before closing the body, want 1", limiter.held.Load())
+	}
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("read error = %v, want ErrBodyTooLarge", err)
+	}
+	resp.Body.Close()
+	if limiter.held.Load() != 0 {
+		t.Errorf("held = %d after closing the body, want 0", limiter.held.Load())
+	}
+}
+
+func TestRequestIDHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get(RequestIDHeader))
+	}))
+	defer srv.Close()
+
+	client := New(nil, Options{Name: "test"})
+	for _, id := range []string{"", "req-123"} {
+		req, _ := http.NewRequestWithContext(logging.WithRequestID(context.Background(), id), http.MethodGet, srv.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != id {
+			t.Errorf("%s = %q, want %q", RequestIDHeader, body, id)
+		}
+		// 呼び出し側のリクエストは変更しない
+		if req.Header.Get(RequestIDHeader) != "" {
+			t.Errorf("request header was modified")
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package i18n
+
+// texts はキーで引く文言のカタログです。
+var texts = map[string]map[Lang]string{
+	"entity_type.onsen":      {Ja: "温泉", En: "Hot spring"},
+	"entity_type.restaurant": {Ja: "飲食店", En: "Restaurant"},
+	"entity_type.brand":      {Ja: "ブランド", En: "Brand"},
+
+	"feed.title":       {Ja: "週間トレンド", En: "Weekly trends"},
+	"feed.entry_title": {Ja: "%s (%s週)", En: "%s (week of %s)"},
+	"feed.score":       {Ja: "スコア %.1f", En: "Score %.1f"},
+	"feed.delta":       {Ja: " (前週比%+.1f)", En: " (%+.1f from last week)"},
+	"feed.summary":     {Ja: "%s、言及元ページ %d件", En: "%s, mentioned on %d pages"},
+	"feed.top_title":   {Ja: "。話題: %s", En: ". Top story: %s"},
+	"feed.stores":      {Ja: "注目の店舗", En: "Featured stores"},
+	"feed.mentions":    {Ja: "言及%d件", En: "%d mentions"},
+
+	"widget.summary": {Ja: "「%s」で話題 (スコア %.1f、言及元ページ %d件)", En: "Trending in \"%s\" (score %.1f, mentioned on %d pages)"},
+
+	"digest.unsubscribed": {Ja: "週次ダイジェストの配信を停止しました。", En: "You have been unsubscribed from the weekly digest."},
+}
+
+// errorMessages はAPIのエラーメッセージ(英語)の日本語訳です。
+var errorMessages = map[string]string{
+	"invalid request body": "リクエストの本文が不正です",
+	"invalid cursor":       "cursorが不正です",
+	"invalid id":           "IDが不正です",
+	"invalid topic_id":     "topic_idが不正です",
+
+	"limit must be between 1 and 100":      "limitは1から100の範囲で指定してください",
+	"limit must be between 1 and 200":      "limitは1から200の範囲で指定してください",
+	"radius must be between 1 and 10000":   "radiusは1から10000の範囲で指定してください",
+	"lat must be between -90 and 90":       "latは-90から90の範囲で指定してください",
+	"lng must be between -180 and 180":     "lngは-180から180の範囲で指定してください",
+	"week must be in YYYY-MM-DD format":    "weekはYYYY-MM-DDの形式で指定してください",
+	"week must be YYYY-WW or YYYY-MM-DD":   "weekはYYYY-WWかYYYY-MM-DDの形式で指定してください",
+	"until must be YYYY-MM-DD":             "untilはYYYY-MM-DDの形式で指定してください",
+	"since must not be after until":        "sinceにはuntil以前の日付を指定してください",
+	"invalid tenant_id":                    "tenant_idが不正です",
+	"invalid api_key_id":                   "api_key_idが不正です",
+	"since must be YYYY-MM-DD":             "sinceはYYYY-MM-DDの形式で指定してください",
+	"q is required":                        "qを指定してください",
+	"area must be 1 to 50 characters":      "areaは1から50文字で指定してください",
+	"token is required":                    "tokenを指定してください",
+	"name is required":                     "nameを指定してください",
+	"topic_id is required":                 "topic_idを指定してください",
+	"events is required":                   "eventsを指定してください",
+	"channel is required":                  "channelを指定してください",
+	"topic_id and subscriber are required": "topic_idとsubscriberを指定してください",
+	"topic_id or all is required":          "topic_idかallを指定してください",
+	"at least one of min_score, min_delta or notify_new_stores is required": "min_score、min_delta、notify_new_storesのいずれかを指定してください",
+	"min_delta must be positive":                             "min_deltaは正の値で指定してください",
+	"min_score must be a number":                             "min_scoreには数値を指定してください",
+	"max_score must be a number":                             "max_scoreには数値を指定してください",
+	"subscriber must be line:<LINE user ID>":                 "subscriberはline:<LINEのユーザーID>の形式で指定してください",
+	"line_user_id must be a LINE user ID":                    "line_user_idにはLINEのユーザーIDを指定してください",
+	"email must be a valid address":                          "emailには有効なメールアドレスを指定してください",
+	"url must be an http(s) URL":                             "urlにはhttp(s)のURLを指定してください",
+	"channel must be slack:<Slack incoming webhook URL>":     "channelはslack:<SlackのIncoming WebhookのURL>の形式で指定してください",
+	"channel must be line:<LINE user ID>":                    "channelはline:<LINEのユーザーID>の形式で指定してください",
+	"an entity with this name and type already exists":       "この名前と種別のエンティティは既に登録されています",
+	"entity has topics":                                      "トピックのあるエンティティは削除できません",
+	"topic already exists":                                   "このトピックは既に登録されています",
+	"topic must not be empty":                                "topicに空の文字列は指定できません",
+	"topic quota of the tenant exceeded":                     "テナントのトピック数の上限に達しています",
+	"specify either topic_id or all, not both":               "topic_idとallはどちらか一方を指定してください",
+	"topic is disabled":                                      "トピックは無効化されています",
+	"a preference for this topic and channel already exists": "このトピックと通知先の設定は既に登録されています",
+
+	"entity not found":                  "エンティティが見つかりません",
+	"topic not found":                   "トピックが見つかりません",
+	"watch not found":                   "ウォッチが見つかりません",
+	"webhook not found":                 "Webhookが見つかりません",
+	"notification preference not found": "通知の設定が見つかりません",
+	"subscription not found":            "購読が見つかりません",
+	"run not found":                     "実行が見つかりません",
+	"job not found":                     "ジョブが見つかりません",
+
+	"invalid api key":                                 "APIキーが不正です",
+	"api key is required":                             "APIキーを指定してください",
+	"invalid access token":                            "アクセストークンが不正です",
+	"access token expired":                            "アクセストークンの有効期限が切れています",
+	"authentication is required":                      "APIキーかアクセストークンを指定してください",
+	"api key does not have the read scope":            "APIキーにデータの取得(read)が許可されていません",
+	"api key does not have the write scope":           "APIキーにデータの変更(write)が許可されていません",
+	"an api key is required to issue an access token": "アクセストークンの発行にはAPIキーを指定してください",
+	"daily request quota exceeded":                    "1日のリクエスト数の上限を超えました",
+	"monthly request quota exceeded":                  "1か月のリクエスト数の上限を超えました",
+	"rate limit exceeded":                             "短時間のリクエストが多すぎます。しばらく待ってから再度お試しください",
+	"this endpoint is only available to the operator": "このエンドポイントは運用者のみ利用できます",
+	"invalid request signature":                       "リクエストの署名が不正です",
+	"unsupported interaction type":                    "対応していないInteractionの種類です",
+	"topic queue is not configured":                   "発掘の依頼のキューが設定されていません",
+	"invalid sign-in state":                           "サインインの状態が不正です。もう一度サインインしてください",
+	"google sign-in was not completed":                "Googleでのサインインが完了しませんでした",
+	"google account email is not verified":            "Googleアカウントのメールアドレスが確認されていません",
+	"google account is not linked to an api key":      "GoogleアカウントがAPIキーに紐付いていません",
+
+	"failed to authenticate":                   "認証に失敗しました",
+	"failed to build feed":                     "フィードの作成に失敗しました",
+	"failed to create entity":                  "エンティティの登録に失敗しました",
+	"failed to create notification preference": "通知の設定の登録に失敗しました",
+	"failed to create topic":                   "トピックの登録に失敗しました",
+	"failed to create watch":                   "ウォッチの登録に失敗しました",
+	"failed to create webhook":                 "Webhookの登録に失敗しました",
+	"failed to delete entity":                  "エンティティの削除に失敗しました",
+	"failed to delete notification preference": "通知の設定の削除に失敗しました",
+	"failed to delete topic":                   "トピックの削除に失敗しました",
+	"failed to delete watch":                   "ウォッチの削除に失敗しました",
+	"failed to delete webhook":                 "Webhookの削除に失敗しました",
+	"failed to enqueue trend discovery":        "発掘の依頼の送信に失敗しました",
+	"failed to export stores":                  "店舗の書き出しに失敗しました",
+	"failed to get entity":                     "エンティティの取得に失敗しました",
+	"failed to get job":                        "ジョブの取得に失敗しました",
+	"failed to get movers":                     "変動の大きいトピックの取得に失敗しました",
+	"failed to get notification preference":    "通知の設定の取得に失敗しました",
+	"failed to get ranking":                    "ランキングの取得に失敗しました",
+	"failed to get run":                        "実行の取得に失敗しました",
+	"failed to get usage":                      "利用量の取得に失敗しました",
+	"failed to get topic":                      "トピックの取得に失敗しました",
+	"failed to export trends":                  "トレンドの書き出しに失敗しました",
+	"failed to get trends":                     "トレンドの取得に失敗しました",
+	"failed to issue access token":             "アクセストークンの発行に失敗しました",
+	"failed to list audit logs":                "監査ログの取得に失敗しました",
+	"failed to list deliveries":                "配送の取得に失敗しました",
+	"failed to list entities":                  "エンティティの一覧の取得に失敗しました",
+	"failed to list jobs":                      "ジョブの一覧の取得に失敗しました",
+	"failed to list mentions":                  "言及の取得に失敗しました",
+	"failed to list notification preferences":  "通知の設定の一覧の取得に失敗しました",
+	"failed to list runs":                      "実行の一覧の取得に失敗しました",
+	"failed to list topics":                    "トピックの一覧の取得に失敗しました",
+	"failed to list trends":                    "トレンドの一覧の取得に失敗しました",
+	"failed to list watches":                   "ウォッチの一覧の取得に失敗しました",
+	"failed to list webhooks":                  "Webhookの一覧の取得に失敗しました",
+	"failed to search nearby stores":           "近くの店舗の検索に失敗しました",
+	"failed to search stores":                  "店舗の検索に失敗しました",
+	"failed to sign in with google":            "Googleでのサインインに失敗しました",
+	"failed to start google sign-in":           "Googleでのサインインの開始に失敗しました",
+	"failed to subscribe":                      "購読の登録に失敗しました",
+	"failed to unsubscribe":                    "配信の停止に失敗しました",
+	"failed to update entity":                  "エンティティの更新に失敗しました",
+	"failed to update notification preference": "通知の設定の更新に失敗しました",
+	"failed to update topic":                   "トピックの更新に失敗しました",
+
+	// apperrで分類した誤りのメッセージ
+	"upstream service is rate limiting requests": "外部のサービスのレート制限に達しました",
+	"upstream service rejected the credentials":  "外部のサービスの認証に失敗しました",
+	"upstream service rejected the request":      "外部のサービスがリクエストを受け付けませんでした",
+	"upstream service is unavailable":            "外部のサービスを利用できません",
+	"failed to parse upstream response":          "外部のサービスの応答を解析できませんでした",
+	"no results found":                           "結果が見つかりませんでした",
+	"request timed out":                          "リクエストがタイムアウトしました",
+	"request canceled":                           "リクエストが中断されました",
+
+	// echoが返すメッセージ
+	"Not Found":                "見つかりません",
+	"Method Not Allowed":       "許可されていないメソッドです",
+	"Internal Server Error":    "サーバーでエラーが発生しました",
+	"Request Entity Too Large": "リクエストが大きすぎます",
+}
+
+// errorPatterns は可変の部分を含むエラーメッセージの日本語訳です。
+var errorPatterns = []errorPattern{
+	pattern("type must be any of {}", "typeには{}のいずれかを指定してください"),
+	pattern("events must be any of {}", "eventsには{}のいずれかを指定してください"),
+	pattern("mode must be any of {}", "modeには{}のいずれかを指定してください"),
+	pattern("format must be any of {}", "formatには{}のいずれかを指定してください"),
+	pattern("order must be any of {}", "orderには{}のいずれかを指定してください"),
+	pattern("interval must be any of {}", "intervalには{}のいずれかを指定してください"),
+	pattern("sort must be any of {}", "sortには{}のいずれか(-を付けると降順)をカンマ区切りで指定してください"),
+}
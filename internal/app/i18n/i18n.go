@@ -0,0 +1,125 @@
+// Package i18n はAPIの利用者向けの文言(エラーメッセージ、エンティティの種別の名前、ページの文言など)を
+// 日本語と英語で提供し、Accept-Languageヘッダーから応答の言語を選びます。
+//
+// Accept-Languageがない場合は文言ごとに従来の言語で返します。エラーメッセージは英語、
+// エンティティの種別の名前やフィードのタイトルなど画面に表示する文言は日本語です。
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lang は応答の言語です。
+type Lang string
+
+const (
+	Ja Lang = "ja"
+	En Lang = "en"
+)
+
+// Supported は対応している言語の一覧です。
+var Supported = []Lang{Ja, En}
+
+// Negotiateは Accept-Language ヘッダーの値から対応している言語のうち最も優先度の高いものを返します。
+// ヘッダーが空か、対応している言語を含まない場合はokがfalseです。
+func Negotiate(acceptLanguage string) (lang Lang, ok bool) {
+	type candidate struct {
+		lang Lang
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		for _, l := range Supported {
+			if q > 0 && primary == string(l) {
+				candidates = append(candidates, candidate{l, q})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	// 優先度が同じ場合はヘッダーに先に書かれた言語を選ぶ
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang, true
+}
+
+type ctxKey struct{}
+
+// NewContextはリクエストの言語を格納したctxを返します。
+func NewContext(ctx context.Context, lang Lang) context.Context {
+	return context.WithValue(ctx, ctxKey{}, lang)
+}
+
+// FromContextはctxに格納された言語を返します。格納されていなければfallbackを返します。
+func FromContext(ctx context.Context, fallback Lang) Lang {
+	if lang, ok := ctx.Value(ctxKey{}).(Lang); ok {
+		return lang
+	}
+	return fallback
+}
+
+// Textはkeyの文言をlangで返します。カタログにない場合はkeyをそのまま返します。
+func Text(lang Lang, key string) string {
+	if m, ok := texts[key]; ok {
+		if s, ok := m[lang]; ok {
+			return s
+		}
+	}
+	return key
+}
+
+// Textfはkeyの文言をlangで取り出し、argsで書式を埋めて返します。
+func Textf(lang Lang, key string, args ...any) string {
+	return fmt.Sprintf(Text(lang, key), args...)
+}
+
+// EntityTypeLabelはエンティティの種別の名前をlangで返します。未知の種別はそのまま返します。
+func EntityTypeLabel(lang Lang, entityType string) string {
+	if m, ok := texts["entity_type."+entityType]; ok {
+		return m[lang]
+	}
+	return entityType
+}
+
+// Errorは英語のエラーメッセージmsgをlangに翻訳します。カタログにないメッセージはそのまま返します。
+func Error(lang Lang, msg string) string {
+	if lang == En {
+		return msg
+	}
+	if s, ok := errorMessages[msg]; ok {
+		return s
+	}
+	for _, p := range errorPatterns {
+		if m := p.re.FindStringSubmatch(msg); m != nil {
+			return strings.ReplaceAll(p.ja, "{}", m[1])
+		}
+	}
+	return msg
+}
+
+// errorPattern は可変の部分({})を含むエラーメッセージの翻訳です。
+type errorPattern struct {
+	re *regexp.Regexp
+	ja string
+}
+
+// patternはエラーメッセージのテンプレートenを、{}の部分を取り出す正規表現に変換します。
+func pattern(en, ja string) errorPattern {
+	before, after, _ := strings.Cut(en, "{}")
+	return errorPattern{re: regexp.MustCompile("^" + regexp.QuoteMeta(before) + "(.+)" + regexp.QuoteMeta(after) + "$"), ja: ja}
+}
@@ -0,0 +1,76 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Lang
+		ok     bool
+	}{
+		{"", "", false},
+		{"ja", Ja, true},
+		{"en-US,en;q=0.9,ja;q=0.8", En, true},
+		{"ja-JP,ja;q=0.9,en-US;q=0.8", Ja, true},
+		{"fr-FR,en;q=0.5,ja;q=0.7", Ja, true},
+		{"fr, de;q=0.5", "", false},
+		{"ja;q=0, en", En, true},
+		{"*", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Negotiate(tt.header)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Negotiate(%q) = %q, %v, want %q, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	if got := Error(En, "invalid id"); got != "invalid id" {
+		t.Errorf("Error(en) = %q", got)
+	}
+	if got := Error(Ja, "invalid id"); got != "IDが不正です" {
+		t.Errorf("Error(ja) = %q", got)
+	}
+	if got := Error(Ja, "mode must be any of immediate, digest"); got != "modeにはimmediate, digestのいずれかを指定してください" {
+		t.Errorf("Error(ja) with pattern = %q", got)
+	}
+	if got := Error(Ja, "something new"); got != "something new" {
+		t.Errorf("Error(ja) for unknown message = %q, want it unchanged", got)
+	}
+}
+
+// TestHandlerErrorsTranslatedはハンドラーが返すエラーメッセージがすべて日本語に翻訳できることを確認します。
+func TestHandlerErrorsTranslated(t *testing.T) {
+	files, err := filepath.Glob("../handler/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`(?m)NewHTTPError\(http\.Status\w+, "([^"]+)"\)|return "([^"]+)"$`)
+	for _, f := range files {
+		src, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range re.FindAllStringSubmatch(string(src), -1) {
+			msg := m[1] + m[2]
+			if Error(Ja, msg) == msg {
+				t.Errorf("%s: no Japanese translation for %q", filepath.Base(f), msg)
+			}
+		}
+	}
+}
+
+func TestEntityTypeLabel(t *testing.T) {
+	if got := EntityTypeLabel(En, "onsen"); got != "Hot spring" {
+		t.Errorf("EntityTypeLabel(en, onsen) = %q", got)
+	}
+	if got := EntityTypeLabel(Ja, "unknown"); got != "unknown" {
+		t.Errorf("EntityTypeLabel(ja, unknown) = %q", got)
+	}
+}
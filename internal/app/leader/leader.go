@@ -0,0 +1,145 @@
+// Package leader は同じ処理を複数のプロセスで冗長に動かすときに、1つのプロセス(リーダー)のみが処理を行うよう選出します。
+// リーダー以外のプロセスは待機し、リーダーが停止したり接続を失ったりすると、待機していたプロセスのいずれかがリーダーになります。
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"log/slog"
+	"time"
+)
+
+// Locker はリーダーの地位を表すロックです。PostgreSQLのアドバイザリーロックで実装します。
+type Locker interface {
+	// TryLockはロックを取得できればそのセッションを返します。他のプロセスが保持していればnil, nilを返します。
+	TryLock(ctx context.Context) (Session, error)
+}
+
+// Session は取得したロックです。
+type Session interface {
+	// Checkはロックをまだ保持しているか確認し、失っていればエラーを返します。
+	Check(ctx context.Context) error
+	// Unlockはロックを解放します。
+	Unlock(ctx context.Context) error
+}
+
+// Runはリーダーに選ばれるまでintervalごとにロックの取得を試み、選ばれている間leadを実行します。
+// leadに渡すctxはリーダーの地位を失うとキャンセルされます。ロックはintervalごとに保持しているか確認します。
+// leadが終了するか地位を失うとロックを解放して再びロックの取得を試みます。ctxがキャンセルされると終了します。
+func Run(ctx context.Context, logger *slog.Logger, locker Locker, interval time.Duration, lead func(ctx context.Context)) {
+	waiting := false
+	for {
+		session, err := locker.TryLock(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			logger.Error("リーダーのロックの取得に失敗しました", "error", err)
+		case session == nil:
+			if !waiting {
+				logger.Info("他のプロセスがリーダーのため待機します")
+				waiting = true
+			}
+		default:
+			waiting = false
+			logger.Info("リーダーに選ばれました")
+			runAsLeader(ctx, logger, session, interval, lead)
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// runAsLeaderはロックを保持している間leadを実行し、終了後にロックを解放します。
+func runAsLeader(ctx context.Context, logger *slog.Logger, session Session, interval time.Duration, lead func(ctx context.Context)) {
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-ticker.C:
+			if err := session.Check(leadCtx); err != nil && ctx.Err() == nil {
+				logger.Warn("リーダーのロックを失いました。処理を中断します", "error", err)
+				cancel()
+				<-done
+				running = false
+			}
+		}
+	}
+	cancel()
+
+	// ctxがキャンセルされていても、次のリーダーがすぐ選ばれるようロックは解放する
+	if err := session.Unlock(context.WithoutCancel(ctx)); err != nil {
+		logger.Warn("リーダーのロックの解放に失敗しました。接続を閉じると解放されます", "error", err)
+	}
+	logger.Info("リーダーを降りました")
+}
+
+// Postgres はPostgreSQLのセッションレベルのアドバイザリーロックによるLockerです。
+// ロックはDBへの1つの接続に結び付くため、プロセスが停止したり接続が切れたりするとPostgreSQLが解放します。
+type Postgres struct {
+	db  *sql.DB
+	key int64
+}
+
+// NewPostgresは名前nameのロックを作成します。同じ名前のロックを取得できるのは1つの接続のみです。
+func NewPostgres(db *sql.DB, name string) *Postgres {
+	return &Postgres{db: db, key: lockKey(name)}
+}
+
+// lockKeyはロックの名前をアドバイザリーロックのキーにします。
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("excavation:leader:" + name))
+	return int64(h.Sum64())
+}
+
+func (p *Postgres) TryLock(ctx context.Context) (Session, error) {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", p.key).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !locked {
+		conn.Close()
+		return nil, nil
+	}
+	return &pgSession{conn: conn, key: p.key}, nil
+}
+
+type pgSession struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// Checkはロックを取得した接続がまだ使えるか確認します。
+// ロックは接続を閉じるかUnlockするまで保持されるため、接続が使えればロックを保持しています。
+func (s *pgSession) Check(ctx context.Context) error {
+	return s.conn.PingContext(ctx)
+}
+
+func (s *pgSession) Unlock(ctx context.Context) error {
+	defer s.conn.Close()
+	_, err := s.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", s.key)
+	return err
+}
@@ -0,0 +1,103 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memLocker は1つのプロセス内で共有するロックです。
+type memLocker struct {
+	mu   sync.Mutex
+	held bool
+	lost bool // trueにするとCheckが失敗する
+}
+
+func (l *memLocker) TryLock(ctx context.Context) (Session, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held {
+		return nil, nil
+	}
+	l.held, l.lost = true, false
+	return &memSession{l: l}, nil
+}
+
+type memSession struct{ l *memLocker }
+
+func (s *memSession) Check(ctx context.Context) error {
+	s.l.mu.Lock()
+	defer s.l.mu.Unlock()
+	if s.l.lost {
+		return errors.New("lost")
+	}
+	return nil
+}
+
+func (s *memSession) Unlock(ctx context.Context) error {
+	s.l.mu.Lock()
+	defer s.l.mu.Unlock()
+	s.l.held = false
+	return nil
+}
+
+func TestRunElectsOneLeaderAndFailsOver(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	locker := &memLocker{}
+	leading := make(chan int, 10)
+	var mu sync.Mutex
+	active := 0
+
+	ctxs := make([]context.CancelFunc, 2)
+	var wg sync.WaitGroup
+	for i := range ctxs {
+		ctx, cancel := context.WithCancel(context.Background())
+		ctxs[i] = cancel
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			Run(ctx, logger, locker, 5*time.Millisecond, func(ctx context.Context) {
+				mu.Lock()
+				active++
+				if active > 1 {
+					t.Error("two leaders at the same time")
+				}
+				mu.Unlock()
+				leading <- i
+				<-ctx.Done()
+				mu.Lock()
+				active--
+				mu.Unlock()
+			})
+		}(i)
+	}
+
+	first := <-leading
+	// リーダーを停止すると、待機していたプロセスがリーダーになる
+	ctxs[first]()
+	second := <-leading
+	if second == first {
+		t.Fatalf("leader %d was elected again after it stopped", first)
+	}
+
+	// ロックを失ったリーダーは処理を中断し、再びロックを取得する
+	locker.mu.Lock()
+	locker.lost = true
+	locker.mu.Unlock()
+	if again := <-leading; again != second {
+		t.Errorf("leader = %d, want %d", again, second)
+	}
+
+	ctxs[second]()
+	wg.Wait()
+}
+
+func TestLockKeyIsStable(t *testing.T) {
+	if lockKey("scheduler") != lockKey("scheduler") || lockKey("scheduler") == lockKey("relay") {
+		t.Error("lock keys should be stable per name and differ between names")
+	}
+}
@@ -0,0 +1,88 @@
+// Package logging はslogによる構造化ログの設定をまとめたパッケージです。
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Output はログの出力先です。データを標準出力に書き出すコマンドでは、Setupの前にos.Stderrに変更します。
+var Output io.Writer = os.Stdout
+
+// Setupはロガーを作成し、slogとlogパッケージの既定のロガーに設定します。
+//
+//	level:  debug, info, warn, error (空の場合はinfo)
+//	format: text, json (空の場合はtext)
+//
+// logパッケージ経由の出力もINFOレベルとしてこのロガーに流れます。
+// メッセージと属性に含まれる秘密情報(AddSecretsで登録した値、URLのパスワード、APIキーなど)はRedactedに置き換えます。
+func Setup(level, format string) *slog.Logger {
+	lv, levelOK := parseLevel(level)
+	opts := &slog.HandlerOptions{Level: lv}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(Output, opts)
+	} else {
+		handler = slog.NewTextHandler(Output, opts)
+	}
+
+	logger := slog.New(redactHandler{handler})
+	slog.SetDefault(logger)
+	if !levelOK {
+		logger.Warn("ログレベルが不正なためinfoで出力します", "log_level", level)
+	}
+	return logger
+}
+
+// Fatalはエラーを出力してプロセスを終了します。
+func Fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+func parseLevel(s string) (slog.Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "", "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return slog.LevelInfo, false
+}
+
+type ctxKey struct{}
+
+// NewContextはloggerを格納したctxを返します。リクエストIDなどを付与したロガーを下流の処理に引き継ぐために使います。
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContextはctxに格納されたロガーを返します。格納されていなければ既定のロガーを返します。
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+type requestIDKey struct{}
+
+// WithRequestIDはAPIのリクエストのIDを格納したctxを返します。
+// IDはDBのクエリのログや外部APIへのリクエストのヘッダーに付け、1つのリクエストの処理をまとめて追えるようにします。
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDはctxに格納されたリクエストのIDを返します。格納されていなければ空文字列です。
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 監査ログの操作
+const (
+	AuditTopicCreate   = "topic.create"
+	AuditTopicDisable  = "topic.disable"
+	AuditTopicEnable   = "topic.enable"
+	AuditTopicPriority = "topic.priority" // 発掘の優先度の変更
+	AuditTopicRename   = "topic.rename"   // トピック(検索クエリ)の文字列の変更
+	AuditTopicDelete   = "topic.delete"   // トピックとそのトレンド・言及の削除
+	AuditRunDiscover   = "run.discover"   // 手動で起動したトレンドの発掘
+	AuditRunEnqueue    = "run.enqueue"    // APIから依頼した発掘
+	AuditRunScore      = "run.score"      // スコアの再計算
+	AuditDataPurge     = "data.purge"
+	AuditTenantCreate  = "tenant.create"
+	AuditTenantQuota   = "tenant.quota" // トピック数・リクエスト数の上限の変更
+	AuditAPIKeyCreate  = "api_key.create"
+	AuditAPIKeyRevoke  = "api_key.revoke"
+	AuditAPIKeyQuota   = "api_key.quota"      // リクエスト数の上限の変更
+	AuditAPIKeyScopes  = "api_key.scopes"     // 許可する操作の変更
+	AuditAPIKeyLink    = "api_key.link"       // Googleアカウントの紐付け
+	AuditAPIKeyUnlink  = "api_key.unlink"     // Googleアカウントの紐付けの解除
+	AuditStoreLink     = "store_link.resolve" // 確認待ちの店舗の組の紐付け・却下
+)
+
+// AuditLog は管理操作の記録です。Before、Afterには変更前後の対象をJSONで保存します。
+type AuditLog struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	Actor      string          `gorm:"not null;index" json:"actor"` // 操作した人 (CLIではcli:<ユーザー名>、APIではapi_key:<APIキーのID>)
+	Action     string          `gorm:"not null" json:"action"`
+	TargetType string          `gorm:"not null" json:"target_type"` // "topic", "run", "table" など
+	TargetID   string          `json:"target_id,omitempty"`
+	Before     json.RawMessage `gorm:"type:jsonb" json:"before,omitempty"`
+	After      json.RawMessage `gorm:"type:jsonb" json:"after,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+)
+
+// DigestSubscription は週次ダイジェストメールの購読です。LineUserIDを設定した購読者にはメールの代わりにLINEで送ります。
+// Tokenは配信停止のリンクに含める推測できない値で、配信を停止しても行は残します(UnsubscribedAtを設定)。
+type DigestSubscription struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Email          string     `gorm:"not null;uniqueIndex" json:"email"`
+	Token          string     `gorm:"not null;uniqueIndex" json:"-"`
+	LineUserID     string     `gorm:"not null;default:''" json:"line_user_id,omitempty"`
+	LastSentWeek   *time.Time `gorm:"type:date" json:"last_sent_week,omitempty"` // 同じ週のダイジェストを2回送らないために記録する
+	UnsubscribedAt *time.Time `json:"unsubscribed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -4,8 +4,19 @@ import (
     "time"
 )
 
+// エンティティの種別
+const (
+    EntityTypeOnsen      = "onsen"
+    EntityTypeRestaurant = "restaurant"
+    EntityTypeBrand      = "brand"
+)
+
+// EntityTypes はエンティティの種別の一覧です。
+var EntityTypes = []string{EntityTypeOnsen, EntityTypeRestaurant, EntityTypeBrand}
+
 type Entity struct {
     ID        uint      `gorm:"primaryKey"`
+    TenantID  uint      `gorm:"not null;default:1;index"` // 0の場合は既定のテナント
     Name      string    `gorm:"not null"`
     Type      string    `gorm:"not null"` // "onsen", "restaurant", "brand"
     CreatedAt time.Time
@@ -16,17 +27,26 @@ type Entity struct {
 type EntityTopic struct {
     ID        uint      `gorm:"primaryKey"`
     EntityID  uint      `gorm:"not null;index"`
+    TenantID  uint      `gorm:"not null;default:1;index"` // エンティティと同じテナント。テナントごとの絞り込みに使う
     Topic     string    `gorm:"not null"`
+    DisabledAt *time.Time // 無効化した日時。無効なトピックは発掘の対象外
+    Priority  int       `gorm:"not null;default:0"` // 発掘の優先度。大きいほど先に発掘する (repository.ByPriority)
     CreatedAt time.Time
     UpdatedAt time.Time
     Trends    []TopicTrend `gorm:"foreignKey:TopicID"`
 }
 
 type TopicTrend struct {
-    ID        uint      `gorm:"primaryKey"`
-    TopicID   uint      `gorm:"not null;index"`
-    Week      time.Time `gorm:"not null"`
-    Score     float64   `gorm:"not null"`
-    CreatedAt time.Time
-    UpdatedAt time.Time
+    ID           uint      `gorm:"primaryKey"`
+    TopicID      uint      `gorm:"not null;index"`
+    Week         time.Time `gorm:"not null"`
+    Score        float64   `gorm:"not null"` // GPTスコアと言及数を合成したスコア
+    GPTScore     float64
+    Delta        *float64  // 前週比のスコア変化量。前週のトレンドがない場合はnil
+    MentionCount int
+    TopTitle     string
+    RunID        string    // このトレンドを作成したバッチ実行のID
+    Prompt       string    // スコアリングでGPTに渡したプロンプト
+    CreatedAt    time.Time
+    UpdatedAt    time.Time
 }
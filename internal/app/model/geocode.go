@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+)
+
+// GeocodeCache は住所の変換結果のキャッシュです。見つからなかった住所はLatitude・Longitudeがnilです。
+type GeocodeCache struct {
+	Provider  string `gorm:"primaryKey"`
+	Address   string `gorm:"primaryKey"`
+	Latitude  *float64
+	Longitude *float64
+	CreatedAt time.Time
+}
+
+func (GeocodeCache) TableName() string {
+	return "geocode_cache"
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+)
+
+// ジョブの種別
+const (
+	JobKindDiscover = "discover"
+)
+
+// ジョブの状態
+const (
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed" // 一部のトピックの失敗も含む
+)
+
+// Job はバッチ処理の1回の実行です。実行中は進捗を定期的に更新します。
+type Job struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	Kind              string     `gorm:"not null;index" json:"kind"`
+	RunID             string     `gorm:"not null;index" json:"run_id"` // ログのrun_idと同じID
+	Status            string     `gorm:"not null" json:"status"`
+	TopicsTotal       int        `json:"topics_total"`
+	TopicsDone        int        `json:"topics_done"` // 失敗したトピックを含む
+	TopicsFailed      int        `json:"topics_failed"`
+	PagesFetched      int64      `json:"pages_fetched"`
+	StoresFound       int        `json:"stores_found"`                  // 終わったトピックで言及された店舗の数の合計
+	EstimatedFinishAt *time.Time `json:"estimated_finish_at,omitempty"` // 終わったトピックの平均所要時間からの見積もり
+	Error             string     `json:"error,omitempty"`
+	StartedAt         time.Time  `json:"started_at"`
+	FinishedAt        *time.Time `json:"finished_at,omitempty"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// 発掘の実行でのトピックの結果
+const (
+	TopicOutcomeTrendSaved = "trend_saved"
+	TopicOutcomeNoStores   = "no_stores" // 検索結果から有効な店舗が見つからなかった
+	TopicOutcomeDuplicate  = "duplicate" // 同じ店舗の組み合わせのトレンドが既にある
+	TopicOutcomeFailed     = "failed"
+)
+
+// JobTopic はジョブでの1つのトピックの処理の結果です。
+type JobTopic struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	JobID           uint      `gorm:"not null;index" json:"job_id"`
+	TopicID         uint      `gorm:"not null;index" json:"topic_id"`
+	Outcome         string    `gorm:"not null" json:"outcome"`
+	TrendID         *uint     `json:"trend_id,omitempty"` // Outcomeがtrend_savedのときに保存したトレンド
+	Mentions        int       `json:"mentions"`           // この実行の検索で集めた言及元ページ数
+	Stores          int       `json:"stores"`             // この実行の検索で言及された店舗の数
+	TopTitle        string    `json:"top_title,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	ErrorCode       string    `json:"error_code,omitempty"` // Errorの分類 (apperr.Code)。店舗の候補が見つからなかったno_storesではno_results
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at"`
+	BudgetExhausted string    `json:"budget_exhausted,omitempty"` // 使い切ったクロールの予算の種類 (カンマ区切り)
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+// トレンドの通知の送り方
+const (
+	NotifyImmediate = "immediate" // トレンドを保存したときにすぐ送る
+	NotifyDigest    = "digest"    // 発掘の実行の終了時に通知先ごとにまとめて送る
+)
+
+// NotifyModes は通知の設定で選べる送り方の一覧です。
+var NotifyModes = []string{NotifyImmediate, NotifyDigest}
+
+// NotificationPreference はトピックのトレンドをどの通知先にどう送るかの設定です。
+// Channelはウォッチの通知先と同じ形式(メールアドレス、line:<ユーザーID>)か、slack:<Incoming WebhookのURL>です。
+// MinScoreが設定されていれば、スコアがそれ以上のトレンドのみ送ります。
+type NotificationPreference struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TopicID   uint      `gorm:"not null;uniqueIndex:idx_notification_preferences_topic_channel" json:"topic_id"`
+	Channel   string    `gorm:"not null;uniqueIndex:idx_notification_preferences_topic_channel" json:"channel"`
+	MinScore  *float64  `json:"min_score,omitempty"`
+	Mode      string    `gorm:"not null" json:"mode"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
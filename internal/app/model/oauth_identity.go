@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// サインインに使える外部のアカウントのプロバイダー
+const OAuthProviderGoogle = "google"
+
+// OAuthIdentity は外部のアカウント(Googleアカウントなど)と、そのアカウントでサインインしたときに
+// アクセストークンを発行するテナントのAPIキーの紐付けです。社内のユーザーが別の認証情報を持たずにダッシュボードを使うために使います。
+// 運用者がメールアドレスで紐付け、初回のサインインでプロバイダーのユーザーID(Subject)を記録します。
+type OAuthIdentity struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Provider     string     `gorm:"not null" json:"provider"`
+	Email        string     `gorm:"not null" json:"email"`
+	Subject      *string    `json:"subject,omitempty"` // まだサインインしていなければnil
+	TenantID     uint       `gorm:"not null" json:"tenant_id"`
+	APIKeyID     uint       `gorm:"not null" json:"api_key_id"`
+	LastSignInAt *time.Time `json:"last_sign_in_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (OAuthIdentity) TableName() string {
+	return "oauth_identities"
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// outboxのイベントの状態
+const (
+	OutboxPending   = "pending" // 未配信、または再試行待ち
+	OutboxPublished = "published"
+	OutboxFailed    = "failed" // 再試行の上限に達した
+)
+
+// OutboxEvent は配信先に渡す前のイベントです。イベントを発生させた書き込みと同じトランザクションで記録します。
+// Payloadはイベントの内容(Webhookで送るJSONのdata)です。
+type OutboxEvent struct {
+	ID            uint64          `gorm:"primaryKey"`
+	Event         string          `gorm:"not null"`
+	Payload       json.RawMessage `gorm:"type:jsonb;not null"`
+	Status        string          `gorm:"not null"`
+	Attempts      int
+	NextAttemptAt time.Time
+	Error         string // 最後の試行のエラー
+	PublishedAt   *time.Time
+	CreatedAt     time.Time
+}
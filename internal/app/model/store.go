@@ -0,0 +1,92 @@
+package model
+
+import (
+	"time"
+)
+
+// 言及元ページの種別
+const (
+	SourceTypeMatome  = "matome"  // 食べログまとめ記事
+	SourceTypeListing = "listing" // 食べログのリストページ
+	SourceTypeSearch  = "search"  // 検索結果に直接ヒットした店舗ページ
+	SourceTypeNews    = "news"    // ニュース・ブログなど食べログ以外のWebページ
+	SourceTypeSocial  = "social"  // SNSの投稿
+)
+
+// Store は発掘された店舗を表します。URLは末尾スラッシュを除いた正規化済みのものです。
+// Addressは店舗ページから取得した住所で、Latitude・Longitudeはその変換結果です。GeocodedAtは変換を試みた日時で、
+// 住所が見つからなかった場合もLatitude・Longitudeをnilのまま記録します。ImageURLは住所と同時に店舗ページから取得した画像のURLです。
+type Store struct {
+	ID         uint   `gorm:"primaryKey"`
+	URL        string `gorm:"not null;uniqueIndex"`
+	Name       string `gorm:"not null"`
+	Address    string `gorm:"not null;default:''"`
+	ImageURL   string `gorm:"not null;default:''"`
+	Latitude   *float64
+	Longitude  *float64
+	GeocodedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// StoreMention はある週に店舗へ言及していたソースページを表します。
+// 同じ週・同じトピックで同じページからの言及は1件として扱います。
+type StoreMention struct {
+	ID         uint      `gorm:"primaryKey"`
+	StoreID    uint      `gorm:"not null;uniqueIndex:idx_store_mentions_unique"`
+	TopicID    uint      `gorm:"not null;index;uniqueIndex:idx_store_mentions_unique"`
+	Week       time.Time `gorm:"type:date;not null;uniqueIndex:idx_store_mentions_unique"`
+	SourceURL  string    `gorm:"not null;uniqueIndex:idx_store_mentions_unique"`
+	SourceType string    `gorm:"not null"` // "matome", "listing", "search", "news", "social"
+	RunID      string    // この言及を最初に記録したバッチ実行のID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// 店舗の紐付けの状態
+const (
+	StoreLinkLinked   = "linked"   // 同じ店舗として紐付けた
+	StoreLinkPending  = "pending"  // 確からしさが低いため、人の確認を待っている
+	StoreLinkRejected = "rejected" // 人が確認して別の店舗と判断した
+)
+
+// StoreLink は店舗名の表記の揺れで別の店舗として登録された店舗の組です。StoreIDの店舗はCanonicalIDの店舗の重複です。
+// Confidenceは同じ店舗である確からしさ(0〜1)、Methodはその判定の方法(storematch.Method*)です。
+// 確からしさがしきい値未満の組はStatusがpendingのまま、人が確認してlinkedかrejectedにします。
+type StoreLink struct {
+	ID          uint    `gorm:"primaryKey"`
+	StoreID     uint    `gorm:"not null;uniqueIndex:idx_store_links_pair"`
+	CanonicalID uint    `gorm:"not null;uniqueIndex:idx_store_links_pair"`
+	Confidence  float64 `gorm:"not null"`
+	Method      string  `gorm:"not null"`
+	Status      string  `gorm:"not null;index"`
+	ReviewedBy  string  `gorm:"not null;default:''"` // 確認した操作者。自動で紐付けた場合は空文字列
+	ReviewedAt  *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// 検証に失敗した店舗を隔離した処理
+const (
+	QuarantineStageMention = "mention" // 発掘で言及のあった店舗を保存するとき
+	QuarantineStageAddress = "address" // 店舗ページから取得した住所を保存するとき
+	QuarantineStageEnrich  = "enrich"  // 店舗ページから詳細情報を収集したとき
+)
+
+// QuarantinedStore は検証(店舗名・予算・評価・住所)に失敗したため保存しなかった店舗の情報です。
+// ランキングに不正な店舗が混ざらないよう、失敗した情報はstoresに保存せずここに理由とともに記録します。
+// Reasonsは失敗した検証(discovery.Reason*)のカンマ区切り、Dataは検証した店舗の情報(discovery.StoreData)のJSONです。
+type QuarantinedStore struct {
+	ID        uint   `gorm:"primaryKey"`
+	URL       string `gorm:"not null"`
+	Name      string `gorm:"not null"`
+	Stage     string `gorm:"not null"`
+	Reasons   string `gorm:"not null"`
+	Data      string `gorm:"not null"`
+	RunID     string
+	CreatedAt time.Time
+}
+
+func (QuarantinedStore) TableName() string {
+	return "store_quarantine"
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultTenantID は既定のテナントのIDです。テナントを導入する前のデータと、APIキーなしのリクエストはこのテナントに属します。
+// 既定のテナントはデプロイの運用者で、Webhookや実行履歴などデプロイ全体に関わるAPIを使えます。
+const DefaultTenantID uint = 1
+
+// Tenant はトピックのセットを分けて運用する利用者(組織)です。
+// MaxTopicsはトピック数の上限、DailyRequestLimit・MonthlyRequestLimitはテナントのすべてのAPIキーを合わせた
+// 1日・1か月のAPIリクエスト数の上限で、未設定(nil)なら無制限です。
+type Tenant struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	Slug                string    `gorm:"not null;uniqueIndex" json:"slug"`
+	Name                string    `gorm:"not null" json:"name"`
+	MaxTopics           *int      `json:"max_topics,omitempty"`
+	DailyRequestLimit   *int      `json:"daily_request_limit,omitempty"`
+	MonthlyRequestLimit *int      `json:"monthly_request_limit,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// APIキーのスコープ。readはデータを取得するエンドポイント、writeはデータを変更するエンドポイントに必要です。
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// APIKeyScopes はAPIキーに付けられるスコープの一覧です。
+var APIKeyScopes = []string{ScopeRead, ScopeWrite}
+
+// APIKey はテナントのAPIキーです。キーそのものは発行時にのみ表示し、DBにはハッシュのみ保存します。
+// DailyRequestLimit・MonthlyRequestLimitはこのキーでの1日・1か月のリクエスト数の上限で、未設定(nil)なら無制限です。
+// テナントの上限も超えないように判定します。Scopesはこのキーで許可する操作です(例: 取引先に渡す読み取り専用のキーはreadのみ)。
+type APIKey struct {
+	ID                  uint           `gorm:"primaryKey" json:"id"`
+	TenantID            uint           `gorm:"not null;index" json:"tenant_id"`
+	Name                string         `gorm:"not null" json:"name"`
+	Prefix              string         `gorm:"not null" json:"prefix"` // キーの先頭。一覧でキーを見分けるために使う
+	KeyHash             string         `gorm:"not null;uniqueIndex" json:"-"`
+	DailyRequestLimit   *int           `json:"daily_request_limit,omitempty"`
+	MonthlyRequestLimit *int           `json:"monthly_request_limit,omitempty"`
+	Scopes              pq.StringArray `gorm:"type:text[];not null" json:"scopes"`
+	LastUsedAt          *time.Time     `json:"last_used_at,omitempty"`
+	RevokedAt           *time.Time     `json:"revoked_at,omitempty"`
+	CreatedAt           time.Time      `json:"created_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// HasScopeはキーがscopeの操作を許可されているかを返します。
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// APIUsage はAPIキーのあるエンドポイントへの1日のリクエストの利用量です。
+// APIKeyIDが0の行はAPIキーなしで既定のテナントとして受け付けたリクエストです。
+// Endpointはメソッドとルートのパターン(例: "GET /api/v1/watches/:id")です。
+type APIUsage struct {
+	TenantID      uint      `gorm:"primaryKey" json:"tenant_id"`
+	APIKeyID      uint      `gorm:"primaryKey" json:"api_key_id"`
+	Day           time.Time `gorm:"primaryKey;type:date" json:"day"`
+	Endpoint      string    `gorm:"primaryKey" json:"endpoint"`
+	Requests      int       `json:"requests"`
+	Errors        int       `json:"errors"` // ステータスが400以上の応答の数
+	RequestBytes  int64     `json:"request_bytes"`
+	ResponseBytes int64     `json:"response_bytes"`
+}
+
+func (APIUsage) TableName() string {
+	return "api_usage"
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+// 発掘の依頼のキューの状態
+const (
+	TopicQueuePending = "pending" // 取得待ち、または処理中
+	TopicQueueFailed  = "failed"  // 試行の上限に達した
+)
+
+// TopicQueueItem はPostgreSQLのキューに入れた発掘の依頼です。BodyはSQSのメッセージと同じ形式の依頼(queue.TopicRequest)です。
+// 取得した依頼はLeaseUntilまで他のプロセスに渡さず、それまでに削除されなければ再び取得できるようになります。
+// ClaimTokenは最後に取得したときに発行した値で、期限切れの後に別のプロセスが取得した依頼を誤って削除しないために使います。
+type TopicQueueItem struct {
+	ID         uint64 `gorm:"primaryKey"`
+	Body       string `gorm:"not null"`
+	Status     string `gorm:"not null"`
+	Attempts   int
+	ClaimToken string
+	ClaimedBy  string // 最後に取得したプロセス (ホスト名:PID)
+	LeaseUntil *time.Time
+	CreatedAt  time.Time
+}
+
+func (TopicQueueItem) TableName() string {
+	return "topic_queue"
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+// Watch はユーザーがトピックに設定したウォッチ(閾値アラート)を表します。
+// MinScoreはスコアが閾値を下から上に超えたとき、MinDeltaは前週比の変化量(絶対値)が閾値以上のときに通知します。
+// 未設定(nil)の閾値は判定に使いません。NotifyNewStoresがtrueの場合は、トピックで初めて言及された店舗もスコアの計算を待たずに通知します。
+type Watch struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	TopicID          uint       `gorm:"not null;index" json:"topic_id"`
+	Subscriber       string     `gorm:"not null;index" json:"subscriber"` // 通知先 (メールアドレス、チャンネル名など)
+	MinScore         *float64   `json:"min_score,omitempty"`
+	MinDelta         *float64   `json:"min_delta,omitempty"`
+	NotifyNewStores  bool       `gorm:"not null;default:false" json:"notify_new_stores"`
+	LastNotifiedWeek *time.Time `gorm:"type:date" json:"last_notified_week,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
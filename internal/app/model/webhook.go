@@ -0,0 +1,53 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Webhookで通知するイベント
+const (
+	EventTrendCreated    = "trend.created"
+	EventStoreDiscovered = "store.discovered"
+	EventRunFailed       = "run.failed"
+	// ウォッチ中のトピックで店舗が初めて言及されたとき。store.discoveredと異なり、スコアの計算を待たずにトピックごとに送る
+	EventWatchNewStore = "watch.new_store"
+)
+
+// WebhookEvents はWebhookで購読できるイベントの一覧です。
+var WebhookEvents = []string{EventTrendCreated, EventStoreDiscovered, EventRunFailed, EventWatchNewStore}
+
+// Webhook はAPIの利用者が登録した通知先です。Secretは配送する内容の署名(HMAC-SHA256)の鍵で、登録時にのみ返します。
+type Webhook struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	URL       string         `gorm:"not null" json:"url"`
+	Events    pq.StringArray `gorm:"type:text[];not null" json:"events"`
+	Secret    string         `gorm:"not null" json:"-"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// Webhookの配送の状態
+const (
+	DeliveryPending   = "pending" // 未配送、または再試行待ち
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed" // 再試行の上限に達した
+)
+
+// WebhookDelivery はWebhookへの1件の配送です。配送ログとして結果を残します。
+type WebhookDelivery struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	WebhookID      uint            `gorm:"not null;index" json:"webhook_id"`
+	Event          string          `gorm:"not null" json:"event"`
+	Payload        json.RawMessage `gorm:"type:jsonb;not null" json:"payload"`
+	Status         string          `gorm:"not null" json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status,omitempty"` // 最後の試行でのHTTPステータス
+	Error          string          `json:"error,omitempty"`           // 最後の試行のエラー
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
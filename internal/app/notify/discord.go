@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"excavation_service/internal/app/tracing"
+)
+
+// discordMaxContentLength はDiscordのメッセージの最大文字数です。
+const discordMaxContentLength = 2000
+
+// Discord はDiscordのチャンネルのWebhookにメッセージを投稿するNotifierです。Message.Recipientは使いません。
+type Discord struct {
+	webhookURL string
+	client     *http.Client
+}
+
+func NewDiscord(webhookURL string) *Discord {
+	return &Discord{webhookURL: webhookURL, client: tracing.NewHTTPClient(10 * time.Second)}
+}
+
+func (d *Discord) Send(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"content": DiscordContent("**" + msg.Subject + "**\n" + msg.Body),
+		// 本文中の@everyoneなどでメンションしない
+		"allowed_mentions": map[string][]string{"parse": {}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post to discord: %w", err)
+	}
+	defer resp.Body.Close()
+	// wait=falseのWebhookは成功すると204を返す
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("post to discord: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// DiscordContentはsをDiscordのメッセージの上限の文字数に収まるように切り詰めます。
+func DiscordContent(s string) string {
+	if utf8.RuneCountInString(s) <= discordMaxContentLength {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:discordMaxContentLength-1]) + "…"
+}
@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/tracing"
+)
+
+// SMTP はSMTPサーバー経由でメールを送るNotifierです。Message.Recipientはメールアドレスです。
+type SMTP struct {
+	addr     string // host:port
+	username string // 空なら認証しない
+	password string
+	from     string
+}
+
+func NewSMTP(addr, username, password, from string) *SMTP {
+	return &SMTP{addr: addr, username: username, password: password, from: from}
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	body, err := buildMIME(s.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.username != "" {
+		host, _, _ := strings.Cut(s.addr, ":")
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+	if err := smtp.SendMail(s.addr, auth, s.from, []string{msg.Recipient}, body); err != nil {
+		return fmt.Errorf("send mail via smtp: %w", err)
+	}
+	return nil
+}
+
+// buildMIMEはテキストとHTML(あれば)の本文を持つメールを組み立てます。
+func buildMIME(from string, msg Message, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", from)
+	header("To", msg.Recipient)
+	header("Subject", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	for k, v := range unsubscribeHeaders(msg) {
+		header(k, v)
+	}
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=UTF-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		return buf.Bytes(), writeQuotedPrintable(&buf, msg.Body)
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Body},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// unsubscribeHeadersはワンクリックでの配信停止(RFC 8058)のためのヘッダーを返します。
+func unsubscribeHeaders(msg Message) map[string]string {
+	if msg.UnsubscribeURL == "" {
+		return nil
+	}
+	return map[string]string{
+		"List-Unsubscribe":      "<" + msg.UnsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+// sendGridEndpoint はSendGridのメール送信APIです。
+const sendGridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGrid はSendGridのAPIでメールを送るNotifierです。Message.Recipientはメールアドレスです。
+type SendGrid struct {
+	apiKey string
+	from   string
+	client *http.Client
+}
+
+func NewSendGrid(apiKey, from string) *SendGrid {
+	return &SendGrid{apiKey: apiKey, from: from, client: tracing.NewHTTPClient(10 * time.Second)}
+}
+
+func (s *SendGrid) Send(ctx context.Context, msg Message) error {
+	type address struct {
+		Email string `json:"email"`
+	}
+	type content struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+	contents := []content{{"text/plain", msg.Body}}
+	if msg.HTML != "" {
+		contents = append(contents, content{"text/html", msg.HTML})
+	}
+	mail := map[string]interface{}{
+		"personalizations": []map[string]interface{}{{"to": []address{{msg.Recipient}}}},
+		"from":             address{s.from},
+		"subject":          msg.Subject,
+		"content":          contents,
+	}
+	if headers := unsubscribeHeaders(msg); headers != nil {
+		mail["headers"] = headers
+	}
+	payload, err := json.Marshal(mail)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridEndpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send mail via sendgrid: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("send mail via sendgrid: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
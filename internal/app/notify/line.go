@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"excavation_service/internal/app/tracing"
+)
+
+// LINEScheme はLINEのユーザー宛ての通知先の接頭辞です。通知先は "line:<ユーザーID>" の形式で指定します。
+const LINEScheme = "line"
+
+const (
+	linePushURL       = "https://api.line.me/v2/bot/message/push"
+	lineMaxTextLength = 5000 // テキストメッセージの最大文字数
+)
+
+var lineUserIDPattern = regexp.MustCompile(`^U[0-9a-f]{32}$`)
+
+// ValidLINEUserIDはidがLINEのユーザーIDの形式か判定します。
+func ValidLINEUserID(id string) bool {
+	return lineUserIDPattern.MatchString(id)
+}
+
+// LINERecipientはLINEのユーザーuserID宛ての通知先を返します。
+func LINERecipient(userID string) string {
+	return LINEScheme + ":" + userID
+}
+
+// LINE はLINE Messaging APIのプッシュメッセージで通知を送るNotifierです。Message.RecipientはLINEのユーザーIDです。
+// LINE Notifyは2025年3月に終了したため、公式アカウントのチャネルアクセストークンを使います。
+// ユーザーが公式アカウントを友だち追加していないと届きません。
+type LINE struct {
+	token  string
+	url    string
+	client *http.Client
+}
+
+// NewLINEはチャネルアクセストークンtokenで送るLINEのNotifierを作成します。tokenが空の場合は送信時にエラーを返します。
+func NewLINE(token string) *LINE {
+	return &LINE{token: token, url: linePushURL, client: tracing.NewHTTPClient(10 * time.Second)}
+}
+
+func (l *LINE) Send(ctx context.Context, msg Message) error {
+	if l.token == "" {
+		return errors.New("send to line: channel access token is not configured")
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"to":       msg.Recipient,
+		"messages": []map[string]string{{"type": "text", "text": lineText(msg)}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+l.token)
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send to line: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("send to line: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// lineTextは件名と本文をLINEのテキストメッセージにします。上限を超える場合は末尾を切り詰めます。
+func lineText(msg Message) string {
+	text := "【" + msg.Subject + "】\n" + msg.Body
+	if utf8.RuneCountInString(text) <= lineMaxTextLength {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:lineMaxTextLength-1]) + "…"
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+type recorder struct{ to []string }
+
+func (r *recorder) Send(ctx context.Context, msg Message) error {
+	r.to = append(r.to, msg.Recipient)
+	return nil
+}
+
+func TestRouter(t *testing.T) {
+	mail, line := &recorder{}, &recorder{}
+	router := NewRouter(mail).Route(LINEScheme, line)
+	for _, to := range []string{"a@example.com", LINERecipient("U0123"), "#general", "slack:x"} {
+		if err := router.Send(context.Background(), Message{Recipient: to}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(mail.to, ",") != "a@example.com,#general,slack:x" {
+		t.Errorf("fallback recipients = %v", mail.to)
+	}
+	if strings.Join(line.to, ",") != "U0123" {
+		t.Errorf("line recipients = %v", line.to)
+	}
+}
+
+func TestLINESend(t *testing.T) {
+	var got struct {
+		To       string `json:"to"`
+		Messages []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"messages"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	l := NewLINE("token")
+	l.url = srv.URL
+	if err := l.Send(context.Background(), Message{Recipient: "U0123", Subject: "件名", Body: strings.Repeat("あ", 6000)}); err != nil {
+		t.Fatal(err)
+	}
+	if got.To != "U0123" || len(got.Messages) != 1 || got.Messages[0].Type != "text" {
+		t.Fatalf("payload = %+v", got)
+	}
+	text := got.Messages[0].Text
+	if !strings.HasPrefix(text, "【件名】\nあ") || utf8.RuneCountInString(text) != lineMaxTextLength {
+		t.Errorf("text = %.20q... (%d runes)", text, utf8.RuneCountInString(text))
+	}
+
+	if err := NewLINE("").Send(context.Background(), Message{Recipient: "U0123"}); err == nil {
+		t.Error("expected error without token")
+	}
+}
+
+func TestValidLINEUserID(t *testing.T) {
+	if !ValidLINEUserID("U" + strings.Repeat("0a", 16)) {
+		t.Error("valid id rejected")
+	}
+	for _, id := range []string{"", "U0123", "u" + strings.Repeat("0a", 16), "U" + strings.Repeat("0A", 16)} {
+		if ValidLINEUserID(id) {
+			t.Errorf("%q accepted", id)
+		}
+	}
+}
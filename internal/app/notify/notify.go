@@ -0,0 +1,139 @@
+// Package notify はトレンドの通知を送信する仕組みを提供します。
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Message は1件の通知です。
+type Message struct {
+	Recipient string // 通知先 (メールアドレス、チャンネル名など)
+	Subject   string
+	Body      string
+
+	// メールでのみ使う項目
+	HTML           string // 空でなければBodyと併せてHTMLの本文として送る
+	UnsubscribeURL string // 空でなければList-Unsubscribeヘッダーに設定する
+}
+
+// Notifier は通知を送信する実装が満たすインターフェースです。
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogNotifier は通知をログに出力するだけのNotifierです。送信先が設定されていない環境で使います。
+type LogNotifier struct{}
+
+func (LogNotifier) Send(ctx context.Context, msg Message) error {
+	slog.Info("通知", "to", MaskSlackRecipient(msg.Recipient), "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// Digest は通知を通知先ごとにまとめ、Flush時に1通ずつ送信します。
+// 1回のバッチ実行で複数のトピックが閾値を超えても、通知先には1通しか届きません。複数のgoroutineから使えます。
+type Digest struct {
+	notifier Notifier
+	subject  string
+
+	mu        sync.Mutex
+	items     map[string][]string                    // key: 通知先, value: 通知内容の行
+	delivered map[string][]func(ctx context.Context) // key: 通知先, value: 送信に成功したら呼ぶ関数
+}
+
+func NewDigest(notifier Notifier, subject string) *Digest {
+	return &Digest{notifier: notifier, subject: subject, items: make(map[string][]string), delivered: make(map[string][]func(context.Context))}
+}
+
+// Addは通知先recipient宛ての通知内容を1行追加します。
+func (d *Digest) Add(recipient, line string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.items[recipient] = append(d.items[recipient], line)
+}
+
+// OnDeliveredは次のFlushで通知先recipientへの送信に成功したらfnを呼ぶようにします。
+// 送信に失敗した場合は呼ばないため、通知済みの記録は送信後にfnで行います。fnにはFlushのctxを渡します。
+func (d *Digest) OnDelivered(recipient string, fn func(ctx context.Context)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.delivered[recipient] = append(d.delivered[recipient], fn)
+}
+
+// Lenはまとめ待ちの通知先の数を返します。
+func (d *Digest) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.items)
+}
+
+// Flushはまとめた通知を通知先ごとに送信します。送信に失敗した通知先があってもすべての通知先への送信を試み、
+// 失敗した通知先をまとめたエラーを返します。
+func (d *Digest) Flush(ctx context.Context) error {
+	d.mu.Lock()
+	items, delivered := d.items, d.delivered
+	d.items, d.delivered = make(map[string][]string), make(map[string][]func(context.Context))
+	d.mu.Unlock()
+
+	recipients := make([]string, 0, len(items))
+	for r := range items {
+		recipients = append(recipients, r)
+	}
+	sort.Strings(recipients)
+
+	var failed []string
+	for _, r := range recipients {
+		lines := items[r]
+		msg := Message{
+			Recipient: r,
+			Subject:   fmt.Sprintf("%s (%d件)", d.subject, len(lines)),
+			Body:      "- " + strings.Join(lines, "\n- "),
+		}
+		if err := d.notifier.Send(ctx, msg); err != nil {
+			// SlackのWebhookのURLは鍵を含むため、伏せてから出力する
+			slog.Error("通知送信失敗", "to", MaskSlackRecipient(r), "error", err)
+			failed = append(failed, MaskSlackRecipient(r))
+			continue
+		}
+		for _, fn := range delivered[r] {
+			fn(ctx)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to notify %d recipient(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Router は通知先の接頭辞("line:"など)に応じて送信に使うNotifierを選ぶNotifierです。
+// 接頭辞を登録したNotifierには接頭辞を除いた通知先で送り、それ以外の通知先は既定のNotifierで送ります。
+// ユーザーごとに通知の受け取り方を選べるようにするために使います。
+type Router struct {
+	fallback Notifier
+	schemes  map[string]Notifier
+}
+
+func NewRouter(fallback Notifier) *Router {
+	return &Router{fallback: fallback, schemes: make(map[string]Notifier)}
+}
+
+// Routeは接頭辞schemeの通知先をnotifierで送るようにします。
+func (r *Router) Route(scheme string, notifier Notifier) *Router {
+	r.schemes[scheme] = notifier
+	return r
+}
+
+func (r *Router) Send(ctx context.Context, msg Message) error {
+	if scheme, to, ok := strings.Cut(msg.Recipient, ":"); ok {
+		if n, ok := r.schemes[scheme]; ok {
+			msg.Recipient = to
+			return n.Send(ctx, msg)
+		}
+	}
+	return r.fallback.Send(ctx, msg)
+}
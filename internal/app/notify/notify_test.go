@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// failingNotifierはfailの通知先への送信に失敗します。
+type failingNotifier struct{ fail string }
+
+func (n failingNotifier) Send(ctx context.Context, msg Message) error {
+	if msg.Recipient == n.fail {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+// TestDigestOnDeliveredは送信に成功した通知先の関数だけを呼び、Flushの後は呼ばないことを確認します。
+func TestDigestOnDelivered(t *testing.T) {
+	d := NewDigest(failingNotifier{fail: "b@example.com"}, "通知")
+	var delivered []string
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		d.Add(to, "line")
+		d.OnDelivered(to, func(context.Context) { delivered = append(delivered, to) })
+	}
+	if err := d.Flush(context.Background()); err == nil {
+		t.Error("Flush() error = nil, want the failed recipient")
+	}
+	if want := []string{"a@example.com"}; !slices.Equal(delivered, want) {
+		t.Errorf("delivered = %v, want %v", delivered, want)
+	}
+
+	if err := d.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 1 {
+		t.Errorf("delivered = %v after an empty Flush, want no more calls", delivered)
+	}
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/tracing"
+)
+
+// Slack はSlackのIncoming Webhookに通知を投稿するNotifierです。
+// Message.Recipientがエリアの場合はそのエリアのWebhookに、それ以外(空を含む)は既定のWebhookに投稿します。
+// 既定のWebhookが空の場合、エリア以外宛ての通知は投稿せずに捨てます。
+type Slack struct {
+	defaultURL string
+	areas      map[string]string
+	client     *http.Client
+}
+
+// NewSlackはSlackのNotifierを作成します。areasのkeyはエリア、valueはそのエリアの投稿先のWebhookのURLです。
+func NewSlack(defaultURL string, areas map[string]string) *Slack {
+	return &Slack{defaultURL: defaultURL, areas: areas, client: tracing.NewHTTPClient(10 * time.Second)}
+}
+
+// Areasは投稿先を設定したエリアの一覧を返します。
+func (s *Slack) Areas() []string {
+	areas := make([]string, 0, len(s.areas))
+	for a := range s.areas {
+		areas = append(areas, a)
+	}
+	return areas
+}
+
+func (s *Slack) Send(ctx context.Context, msg Message) error {
+	webhookURL, ok := s.areas[msg.Recipient]
+	if !ok {
+		webhookURL = s.defaultURL
+	}
+	if webhookURL == "" {
+		// エリアごとの投稿先のみ設定されている場合、既定の通知先への通知は投稿しない
+		return nil
+	}
+	return postSlack(ctx, s.client, webhookURL, msg)
+}
+
+// SlackScheme はSlackのチャンネル宛ての通知先の接頭辞です。通知先は "slack:<Incoming WebhookのURL>" の形式で指定します。
+const SlackScheme = "slack"
+
+// ValidSlackWebhookURLはrawURLがSlackのIncoming WebhookのURLか判定します。
+func ValidSlackWebhookURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Scheme == "https" && u.Host == "hooks.slack.com" && strings.HasPrefix(u.Path, "/services/")
+}
+
+// MaskSlackRecipientは通知先がSlackのチャンネルの場合、Incoming WebhookのURLの鍵の部分を伏せて返します。
+// WebhookのURLを知っていれば誰でも投稿できるため、APIの応答などに通知先を含める場合に使います。
+func MaskSlackRecipient(recipient string) string {
+	if u, ok := strings.CutPrefix(recipient, SlackScheme+":"); ok && ValidSlackWebhookURL(u) {
+		if i := strings.LastIndex(u, "/"); i >= 0 {
+			return recipient[:len(SlackScheme)+1+i+1] + "****"
+		}
+	}
+	return recipient
+}
+
+// SlackWebhook はMessage.RecipientのIncoming WebhookのURLに投稿するNotifierです。
+// 通知先ごとにチャンネルを選べるよう、RouterでSlackSchemeの通知先に使います。
+type SlackWebhook struct {
+	client *http.Client
+}
+
+func NewSlackWebhook() *SlackWebhook {
+	return &SlackWebhook{client: tracing.NewHTTPClient(10 * time.Second)}
+}
+
+func (s *SlackWebhook) Send(ctx context.Context, msg Message) error {
+	if !ValidSlackWebhookURL(msg.Recipient) {
+		return fmt.Errorf("invalid slack webhook url")
+	}
+	return postSlack(ctx, s.client, msg.Recipient, msg)
+}
+
+// postSlackはwebhookURLに通知を投稿します。
+func postSlack(ctx context.Context, client *http.Client, webhookURL string, msg Message) error {
+	payload, err := json.Marshal(map[string]string{"text": "*" + msg.Subject + "*\n" + msg.Body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		// WebhookのURLは鍵を含むため、エラーに含めない
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("post to slack: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("post to slack: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
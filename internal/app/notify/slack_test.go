@@ -0,0 +1,17 @@
+package notify
+
+import "testing"
+
+func TestMaskSlackRecipient(t *testing.T) {
+	tests := map[string]string{
+		"slack:https://hooks.slack.com/services/T000/B000/XXXXXXXX": "slack:https://hooks.slack.com/services/T000/B000/****",
+		"slack:https://example.com/services/T000/B000/XXXXXXXX":     "slack:https://example.com/services/T000/B000/XXXXXXXX",
+		"line:U0123456789abcdef0123456789abcdef":                    "line:U0123456789abcdef0123456789abcdef",
+		"owner@example.com":                                         "owner@example.com",
+	}
+	for recipient, want := range tests {
+		if got := MaskSlackRecipient(recipient); got != want {
+			t.Errorf("MaskSlackRecipient(%q) = %q, want %q", recipient, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package objectstore はエクスポートしたファイルを置くオブジェクトストレージを抽象化します。
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Store はキーを指定してオブジェクトを書き込めるストレージです。
+type Store interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+}
+
+// S3Store はS3互換のオブジェクトストレージに書き込むStoreです。
+// Endpointを指定するとGCS(https://storage.googleapis.com、HMACキーを使用)やMinIOにも書き込めます。
+type S3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Storeは標準のAWS認証情報(環境変数、共有設定ファイル、IAMロール)を使うS3Storeを作成します。
+func NewS3Store(ctx context.Context, bucket, prefix, endpoint string) (*S3Store, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &S3Store{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(joinKey(s.prefix, key)),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("put s3://%s/%s: %w", s.bucket, joinKey(s.prefix, key), err)
+	}
+	return nil
+}
+
+// LocalStore はローカルディレクトリに書き込むStoreです。開発環境での確認に使います。
+type LocalStore struct {
+	Dir string
+}
+
+func (s LocalStore) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	path := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, body, 0o644)
+}
+
+// Newはbucketが空でなければS3Store(prefix、endpointを使用)、空ならdirのLocalStoreを返します。
+func New(ctx context.Context, bucket, prefix, endpoint, dir string) (Store, error) {
+	if bucket != "" {
+		return NewS3Store(ctx, bucket, prefix, endpoint)
+	}
+	return LocalStore{Dir: dir}, nil
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	if prefix[len(prefix)-1] == '/' {
+		return prefix + key
+	}
+	return prefix + "/" + key
+}
@@ -0,0 +1,146 @@
+// Package outbox はイベントをtransactional outboxで配信します。
+//
+// イベントはPublishで、イベントを発生させた書き込み(トレンドの保存など)と同じトランザクションでoutbox_eventsテーブルに記録します。
+// APIサーバーで動くRelayが記録されたイベントを配信先(Sink)に渡すため、書き込みがロールバックされたイベントは配信されず、
+// 書き込みがコミットされたイベントはプロセスが止まっても失われません。配信先が失敗したイベントは間隔を延ばしながら再試行します。
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxAttempts = 10               // この回数失敗したイベントは再試行しない
+	baseBackoff = 10 * time.Second // 1回目の失敗の後の再試行までの間隔。失敗するたびに2倍にする
+	maxBackoff  = time.Hour
+	batchSize   = 100 // 1回の確認で配信するイベントの最大数
+)
+
+// Publishはイベントをoutboxに記録します。イベントを発生させた書き込みと同じトランザクションのtxを渡します。
+func Publish(tx *gorm.DB, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encode %s payload: %w", event, err)
+	}
+	err = repository.NewOutboxRepository(tx).Enqueue(&model.OutboxEvent{
+		Event:         event,
+		Payload:       payload,
+		Status:        model.OutboxPending,
+		NextAttemptAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("record %s event: %w", event, err)
+	}
+	return nil
+}
+
+// Sink はイベントの配信先です。Deliverはイベントを記録したのと同じDBのtxで、配信済みの記録と同じトランザクションで呼び出されます。
+// DB以外に配信する場合、Deliverが成功した後にトランザクションが失敗すると同じイベントが再び渡されることがあります。
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, tx *gorm.DB, event model.OutboxEvent) error
+}
+
+// Relay はoutboxのイベントを配信先に渡します。複数のプロセスで動かしても同じイベントを同時に配信しません。
+type Relay struct {
+	db    *gorm.DB
+	sinks []Sink
+}
+
+func NewRelay(db *gorm.DB, sinks ...Sink) *Relay {
+	return &Relay{db: db, sinks: sinks}
+}
+
+// Runはintervalごとに未配信のイベントを配信します。ctxがキャンセルされると終了します。
+func (r *Relay) Run(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := r.RelayPending(ctx, logger); err != nil {
+			logger.Error("outboxのイベントの配信に失敗しました", "error", err)
+		}
+	}
+}
+
+// RelayPendingは配信時刻を過ぎた未配信のイベントを古い順に最大batchSize件配信し、試行した件数を返します。
+func (r *Relay) RelayPending(ctx context.Context, logger *slog.Logger) (int, error) {
+	for n := 0; n < batchSize; n++ {
+		ok, err := r.relayNext(ctx, logger, time.Now())
+		if err != nil || !ok {
+			return n, err
+		}
+	}
+	return batchSize, nil
+}
+
+// relayNextは次のイベントを1件配信します。配信するイベントがなければfalseを返します。
+// 配信先の失敗はイベントに記録して再試行するため、エラーはDBの読み書きの失敗のみ返します。
+func (r *Relay) relayNext(ctx context.Context, logger *slog.Logger, now time.Time) (bool, error) {
+	var event *model.OutboxEvent
+	var deliverErr error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewOutboxRepository(tx)
+		var err error
+		if event, err = repo.ClaimNext(now); err != nil {
+			return err
+		}
+		for _, s := range r.sinks {
+			if err := s.Deliver(ctx, tx, *event); err != nil {
+				// 配信先への書き込みを取り消すためロールバックする
+				deliverErr = fmt.Errorf("%s: %w", s.Name(), err)
+				return deliverErr
+			}
+		}
+		return repo.MarkPublished(event.ID, now)
+	})
+	switch {
+	case deliverErr != nil:
+		fail(event, deliverErr, now)
+		logger.Warn("outboxのイベントの配信が失敗しました", "event_id", event.ID, "event", event.Event,
+			"attempts", event.Attempts, "status", event.Status, "error", deliverErr)
+		if err := repository.NewOutboxRepository(r.db).WithContext(ctx).SaveAttempt(event); err != nil {
+			return true, fmt.Errorf("save outbox event %d: %w", event.ID, err)
+		}
+		return true, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+// failは配信に失敗したイベントの試行の回数と次の配信時刻を更新します。
+func fail(event *model.OutboxEvent, err error, now time.Time) {
+	event.Attempts++
+	event.Error = err.Error()
+	if event.Attempts >= maxAttempts {
+		event.Status = model.OutboxFailed
+		return
+	}
+	event.NextAttemptAt = now.Add(backoff(event.Attempts))
+}
+
+// backoffはattempts回失敗したイベントを再試行するまでの間隔を返します。
+func backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
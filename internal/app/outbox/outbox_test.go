@@ -0,0 +1,33 @@
+package outbox
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+)
+
+func TestFail(t *testing.T) {
+	now := time.Date(2024, 6, 5, 9, 0, 0, 0, time.UTC)
+	event := &model.OutboxEvent{Status: model.OutboxPending}
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second}
+	for i, w := range want {
+		fail(event, errors.New("webhook: connection refused"), now)
+		if event.Attempts != i+1 || event.Status != model.OutboxPending || !event.NextAttemptAt.Equal(now.Add(w)) {
+			t.Fatalf("after %d failures: %+v, want a retry in %s", i+1, event, w)
+		}
+	}
+	if event.Error != "webhook: connection refused" {
+		t.Errorf("Error = %q", event.Error)
+	}
+
+	event.Attempts = maxAttempts - 1
+	fail(event, errors.New("webhook: connection refused"), now)
+	if event.Status != model.OutboxFailed {
+		t.Errorf("Status = %q after %d attempts, want %q", event.Status, event.Attempts, model.OutboxFailed)
+	}
+	if got := backoff(20); got != maxBackoff {
+		t.Errorf("backoff(20) = %s, want %s", got, maxBackoff)
+	}
+}
@@ -0,0 +1,175 @@
+// Package preflight はサブコマンドが処理を始める前に、DBへの接続や出力先の書き込み権限などをまとめて確認します。
+// 処理の途中で失敗するのではなく、すべての問題と対処方法を1つのレポートで報告するために使います。
+package preflight
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"excavation_service/internal/app/db"
+)
+
+// Check は処理の前に確認する1つの項目です。
+type Check struct {
+	Name string
+	Hint string // 失敗したときの対処方法
+	Run  func(ctx context.Context) error
+}
+
+// Result はCheckを実行した結果です。Errがnilなら問題ありません。
+type Result struct {
+	Name string
+	Hint string
+	Err  error
+}
+
+// Error は失敗した確認項目をまとめたエラーです。
+type Error struct {
+	Failed []Result
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("preflight checks failed:")
+	for _, r := range e.Failed {
+		fmt.Fprintf(&b, "\n  - %s: %v", r.Name, r.Err)
+		if r.Hint != "" {
+			fmt.Fprintf(&b, "\n    hint: %s", r.Hint)
+		}
+	}
+	return b.String()
+}
+
+// Runはchecksを順にすべて実行し、結果を返します。1つでも失敗していれば*Errorも返します。
+// 先の項目が失敗しても残りの項目は確認するため、問題をまとめて報告できます。
+func Run(ctx context.Context, checks []Check) ([]Result, error) {
+	results := make([]Result, 0, len(checks))
+	var failed []Result
+	for _, c := range checks {
+		r := Result{Name: c.Name, Hint: c.Hint, Err: c.Run(ctx)}
+		results = append(results, r)
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) > 0 {
+		return results, &Error{Failed: failed}
+	}
+	return results, nil
+}
+
+// WriteReportは結果を表にしてwに書き出します。
+func WriteReport(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+	for _, r := range results {
+		status, detail := "ok", ""
+		if r.Err != nil {
+			status, detail = "FAIL", r.Err.Error()
+			if r.Hint != "" {
+				detail += " (" + r.Hint + ")"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, status, detail)
+	}
+	return tw.Flush()
+}
+
+// pingTimeout はDBへの接続を確認する際の待ち時間です。
+const pingTimeout = 5 * time.Second
+
+// Databaseは*sql.DBがDBに接続できることを確認します。
+// sqlDBを返す関数を受け取るのは、接続に失敗した場合でも残りの項目を確認できるようにするためです。
+func Database(sqlDB func() (*sql.DB, error)) Check {
+	return Check{
+		Name: "database",
+		Hint: "check DATABASE_URL and that PostgreSQL is running (docker compose up -d db)",
+		Run: func(ctx context.Context) error {
+			conn, err := sqlDB()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+			defer cancel()
+			return conn.PingContext(ctx)
+		},
+	}
+}
+
+// Migrationsはfsysのマイグレーションがすべて適用されていることを確認します。
+func Migrations(sqlDB func() (*sql.DB, error), fsys fs.FS) Check {
+	return Check{
+		Name: "migrations",
+		Hint: "run `excavation migrate`",
+		Run: func(ctx context.Context) error {
+			conn, err := sqlDB()
+			if err != nil {
+				return errors.New("skipped: database is not available")
+			}
+			pending, err := db.PendingMigrations(ctx, conn, fsys)
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf("%d pending: %s", len(pending), strings.Join(pending, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// WritableDirはdirに書き込めることを確認します。dirがなければ作成します。keyはdirを設定する設定のキーです。
+func WritableDir(key, dir string) Check {
+	return Check{
+		Name: strings.ToLower(key),
+		Hint: fmt.Sprintf("set %s to a writable directory", key),
+		Run: func(ctx context.Context) error {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			f, err := os.CreateTemp(dir, ".preflight-*")
+			if err != nil {
+				return fmt.Errorf("%s is not writable: %w", dir, err)
+			}
+			f.Close()
+			return os.Remove(f.Name())
+		},
+	}
+}
+
+// ReadableFileはpathのファイルを読み込めることを確認します。keyはpathを設定する設定のキーです。
+func ReadableFile(key, path string) Check {
+	return Check{
+		Name: strings.ToLower(key),
+		Hint: fmt.Sprintf("fix or unset %s", key),
+		Run: func(ctx context.Context) error {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			return f.Close()
+		},
+	}
+}
+
+// Settingは設定keyの値が空でないことを確認します。
+func Setting(key, value string) Check {
+	return Check{
+		Name: strings.ToLower(key),
+		Hint: fmt.Sprintf("set env %s or flag --%s", key, strings.ReplaceAll(strings.ToLower(key), "_", "-")),
+		Run: func(ctx context.Context) error {
+			if value == "" {
+				return errors.New("not set")
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,45 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunReportsAllFailures(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	checks := []Check{
+		Setting("BRAVE_API_KEY", ""),
+		WritableDir("ARCHIVE_DIR", filepath.Join(dir, "archive")),
+		WritableDir("EXPORT_DIR", filepath.Join(file, "export")), // ファイルの下にはディレクトリを作れない
+		ReadableFile("SCRAPE_RULES_FILE", filepath.Join(dir, "missing.yaml")),
+	}
+	results, err := Run(context.Background(), checks)
+	if len(results) != len(checks) {
+		t.Fatalf("results = %d, want %d", len(results), len(checks))
+	}
+	var perr *Error
+	if !errors.As(err, &perr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	var failed []string
+	for _, r := range perr.Failed {
+		failed = append(failed, r.Name)
+	}
+	if got, want := strings.Join(failed, ","), "brave_api_key,export_dir,scrape_rules_file"; got != want {
+		t.Errorf("failed = %s, want %s", got, want)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "hint: set env BRAVE_API_KEY or flag --brave-api-key") {
+		t.Errorf("error does not contain hint:\n%s", msg)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "archive")); err != nil {
+		t.Errorf("archive dir was not created: %v", err)
+	}
+}
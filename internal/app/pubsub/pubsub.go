@@ -0,0 +1,167 @@
+// Package pubsub はoutboxのイベントをGoogle Cloud Pub/Subのトピックに発行します。
+//
+// GCP上で動かすことを前提に、認証にはメタデータサーバーから取得するサービスアカウントのアクセストークンを使います。
+// サービスアカウントにはトピックのroles/pubsub.publisherを付与してください。エミュレーターを使う場合は認証しません。
+//
+// トピックに関するイベントはtopic_idを順序指定キー(ordering key)にして発行するため、メッセージの順序指定を有効にした
+// サブスクリプションでは同じトピックのイベントを発行した順に受け取れます。Pub/Subが順序を保証するのは同じリージョンで
+// 発行したメッセージのみのため、エンドポイントにはリージョンのエンドポイントを指定してください。
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/tracing"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultEndpoint  = "https://pubsub.googleapis.com"
+	metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// Sink はイベントをPub/Subのトピックに発行するoutboxの配信先です。
+// メッセージの本文はWebhookと同じくイベントのJSONで、属性eventにイベントの種類を付けます。
+type Sink struct {
+	publishURL string
+	tokenURL   string // 空ならアクセストークンを付けない(エミュレーター)
+	client     *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewSinkはプロジェクトprojectのトピックtopicに発行するSinkを作成します。
+// endpointはPub/SubのAPIのURLで、リージョンのエンドポイント(例: https://asia-northeast1-pubsub.googleapis.com)を指定できます。
+func NewSink(endpoint, project, topic string) *Sink {
+	return &Sink{
+		publishURL: publishURL(endpoint, project, topic),
+		tokenURL:   metadataTokenURL,
+		client:     tracing.NewHTTPClient(10 * time.Second),
+	}
+}
+
+// NewEmulatorSinkはエミュレーター(host:port)のトピックに発行するSinkを作成します。
+func NewEmulatorSink(host, project, topic string) *Sink {
+	return &Sink{publishURL: publishURL("http://"+host, project, topic), client: tracing.NewHTTPClient(10 * time.Second)}
+}
+
+func publishURL(endpoint, project, topic string) string {
+	return endpoint + "/v1/projects/" + url.PathEscape(project) + "/topics/" + url.PathEscape(topic) + ":publish"
+}
+
+func (s *Sink) Name() string {
+	return "pubsub"
+}
+
+type message struct {
+	Data        []byte            `json:"data"` // JSONではbase64で送る
+	Attributes  map[string]string `json:"attributes"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+type envelope struct {
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Deliverはイベントを1件のメッセージとして発行します。発行はDBのトランザクションに含まれないため、
+// 発行の後にトランザクションが失敗すると同じイベントを再び発行します。受け取る側は属性event_idで重複を除いてください。
+func (s *Sink) Deliver(ctx context.Context, tx *gorm.DB, event model.OutboxEvent) error {
+	data, err := json.Marshal(envelope{Event: event.Event, CreatedAt: event.CreatedAt, Data: event.Payload})
+	if err != nil {
+		return fmt.Errorf("encode %s payload: %w", event.Event, err)
+	}
+	msg := message{
+		Data:        data,
+		Attributes:  map[string]string{"event": event.Event, "event_id": strconv.FormatUint(event.ID, 10)},
+		OrderingKey: orderingKey(event.Payload),
+	}
+	body, err := json.Marshal(map[string][]message{"messages": {msg}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.publishURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.tokenURL != "" {
+		token, err := s.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if err := s.do(req, nil); err != nil {
+		return fmt.Errorf("publish to pubsub: %w", err)
+	}
+	return nil
+}
+
+// orderingKeyはイベントのtopic_idから順序指定キーを作ります。トピックに関しないイベントは順序を指定しません(空文字列)。
+func orderingKey(payload json.RawMessage) string {
+	var data struct {
+		TopicID uint `json:"topic_id"`
+	}
+	if json.Unmarshal(payload, &data) != nil || data.TopicID == 0 {
+		return ""
+	}
+	return "topic-" + strconv.FormatUint(uint64(data.TopicID), 10)
+}
+
+// accessTokenはメタデータサーバーから取得したアクセストークンを返します。期限が近ければ取得し直します。
+func (s *Sink) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.token != "" && now.Add(time.Minute).Before(s.expires) {
+		return s.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := s.do(req, &token); err != nil {
+		return "", fmt.Errorf("get google access token: %w", err)
+	}
+	s.token, s.expires = token.AccessToken, now.Add(time.Duration(token.ExpiresIn)*time.Second)
+	return s.token, nil
+}
+
+func (s *Sink) do(req *http.Request, out interface{}) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
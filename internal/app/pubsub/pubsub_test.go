@@ -0,0 +1,67 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+)
+
+func TestDeliver(t *testing.T) {
+	var got struct {
+		Messages []message `json:"messages"`
+	}
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			io.WriteString(w, `{"access_token":"tok","expires_in":3600}`)
+			return
+		}
+		if r.URL.Path != "/v1/projects/proj/topics/events:publish" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		io.WriteString(w, `{"messageIds":["1"]}`)
+	}))
+	defer srv.Close()
+
+	s := NewSink(srv.URL, "proj", "events")
+	s.tokenURL = srv.URL + "/token"
+	event := model.OutboxEvent{ID: 7, Event: model.EventTrendCreated, Payload: json.RawMessage(`{"trend_id":1,"topic_id":42}`), CreatedAt: time.Now()}
+	if err := s.Deliver(context.Background(), nil, event); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer tok" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if len(got.Messages) != 1 {
+		t.Fatalf("messages = %+v", got.Messages)
+	}
+	m := got.Messages[0]
+	if m.OrderingKey != "topic-42" || m.Attributes["event"] != model.EventTrendCreated || m.Attributes["event_id"] != "7" {
+		t.Errorf("message = %+v", m)
+	}
+	var env envelope
+	if err := json.Unmarshal(m.Data, &env); err != nil || env.Event != model.EventTrendCreated || string(env.Data) != `{"trend_id":1,"topic_id":42}` {
+		t.Errorf("data = %s (%v)", m.Data, err)
+	}
+}
+
+func TestOrderingKey(t *testing.T) {
+	if got := orderingKey(json.RawMessage(`{"run_id":"r1","error":"x"}`)); got != "" {
+		t.Errorf("orderingKey without topic_id = %q, want empty", got)
+	}
+	if got := orderingKey(json.RawMessage(`{"topic_id":3}`)); got != "topic-3" {
+		t.Errorf("orderingKey = %q, want topic-3", got)
+	}
+}
@@ -0,0 +1,164 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/topics"
+
+	"gorm.io/gorm"
+)
+
+// receiveRetryDelay はキューからの受信に失敗したときに再び受信するまでの間隔です。
+const receiveRetryDelay = 10 * time.Second
+
+// ErrInvalidRequest は依頼の内容が不正で、再試行しても処理できないことを表します。
+var ErrInvalidRequest = errors.New("invalid topic request")
+
+// TopicRequest は発掘の依頼のメッセージです。登録済みのトピックをtopic_idで指定するか、
+// エンティティ(entity、entity_type、省略可能なtopic)を指定します。エンティティのトピックが未登録なら登録してから発掘します。
+// tenantにテナントのスラッグを指定した場合はそのテナントのトピックのみ扱い、省略した場合は既定のテナントに登録します。
+//
+//	{"topic_id": 12}
+//	{"entity": "西日暮里", "entity_type": "restaurant", "topic": "西日暮里 カレー"}
+//	{"tenant": "example-media", "entity": "草津温泉", "entity_type": "onsen"}
+type TopicRequest struct {
+	Tenant     string `json:"tenant"`
+	TopicID    uint   `json:"topic_id"`
+	Entity     string `json:"entity"`
+	EntityType string `json:"entity_type"`
+	Topic      string `json:"topic"`
+}
+
+// ParseTopicRequestはメッセージの本文を依頼として読み込みます。
+func ParseTopicRequest(body string) (TopicRequest, error) {
+	var req TopicRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return req, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	req.Entity, req.EntityType, req.Topic = strings.TrimSpace(req.Entity), strings.ToLower(strings.TrimSpace(req.EntityType)), strings.TrimSpace(req.Topic)
+	switch {
+	case req.TopicID != 0 && req.Entity != "":
+		return req, fmt.Errorf("%w: specify either topic_id or entity, not both", ErrInvalidRequest)
+	case req.TopicID == 0 && req.Entity == "":
+		return req, fmt.Errorf("%w: topic_id or entity is required", ErrInvalidRequest)
+	}
+	return req, nil
+}
+
+// resolveは依頼されたトピックのIDを返します。エンティティで指定されたトピックが未登録なら登録します。
+func resolve(ctx context.Context, db *gorm.DB, actor string, req TopicRequest) (uint, error) {
+	var tenantID uint // 0の場合はトピックをテナントで絞り込まない
+	if req.Tenant != "" {
+		tenant, err := repository.NewTenantRepository(db).WithContext(ctx).GetBySlug(req.Tenant)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("%w: tenant %q not found", ErrInvalidRequest, req.Tenant)
+		}
+		if err != nil {
+			return 0, err
+		}
+		tenantID = tenant.ID
+	}
+
+	if req.TopicID != 0 {
+		topic, err := repository.NewTopicRepository(db).WithContext(ctx).ForTenant(tenantID).GetTopic(req.TopicID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("%w: topic %d not found", ErrInvalidRequest, req.TopicID)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if topic.DisabledAt != nil {
+			return 0, fmt.Errorf("%w: topic %d is disabled", ErrInvalidRequest, req.TopicID)
+		}
+		return topic.ID, nil
+	}
+
+	// 入力の検証と重複の判定はCSVのインポートと同じ規則で行う
+	row := topics.Row{Entity: req.Entity, Type: req.EntityType, Topic: req.Topic}
+	if tenantID == 0 {
+		tenantID = model.DefaultTenantID
+	}
+	results, err := topics.Import(ctx, db, actor, tenantID, []topics.Row{row}, false)
+	if err != nil {
+		return 0, err
+	}
+	if r := results[0]; r.Status == topics.StatusError {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidRequest, r.Reason)
+	}
+	return results[0].TopicID, nil
+}
+
+// Receiver はメッセージを受け取るキューです。SQSとPostgresが満たします。
+type Receiver interface {
+	Receive(ctx context.Context) ([]Message, error)
+	Delete(ctx context.Context, m Message) error
+}
+
+// Consumeはキューから発掘の依頼を受け取り、依頼ごとにdiscoverでトピックを発掘します。ctxがキャンセルされると終了します。
+// 発掘に成功した依頼と、不正で処理できない依頼はキューから削除します。発掘に失敗した依頼は削除せず、
+// 可視性タイムアウトの後に再試行します(再試行の回数はキューのリドライブポリシーで制限してください)。
+// 登録したトピックの監査ログにはactorを記録します。
+func Consume(ctx context.Context, logger *slog.Logger, db *gorm.DB, q Receiver, actor string, discover func(ctx context.Context, topicID uint) error) {
+	for {
+		msgs, err := q.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Error("キューからの受信に失敗しました", "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(receiveRetryDelay):
+			}
+			continue
+		}
+		for _, m := range msgs {
+			if ctx.Err() != nil {
+				// 処理しなかったメッセージは可視性タイムアウトの後に他の受信者が処理する
+				return
+			}
+			handle(ctx, logger.With("message_id", m.ID), db, q, m, actor, discover)
+		}
+	}
+}
+
+func handle(ctx context.Context, logger *slog.Logger, db *gorm.DB, q Receiver, m Message, actor string, discover func(ctx context.Context, topicID uint) error) {
+	req, err := ParseTopicRequest(m.Body)
+	var topicID uint
+	if err == nil {
+		topicID, err = resolve(ctx, db, actor, req)
+	}
+	if errors.Is(err, ErrInvalidRequest) {
+		logger.Warn("処理できない発掘の依頼を削除します", "body", m.Body, "error", err)
+		deleteMessage(ctx, logger, q, m)
+		return
+	}
+	if err != nil {
+		logger.Error("発掘の依頼のトピックの取得に失敗しました", "error", err)
+		return
+	}
+
+	logger.Info("依頼されたトピックを発掘します", "topic_id", topicID)
+	if err := discover(ctx, topicID); err != nil {
+		if ctx.Err() == nil {
+			logger.Error("依頼されたトピックの発掘に失敗しました。後で再試行します", "topic_id", topicID, "error", err)
+		}
+		return
+	}
+	deleteMessage(ctx, logger, q, m)
+}
+
+func deleteMessage(ctx context.Context, logger *slog.Logger, q Receiver, m Message) {
+	if err := q.Delete(context.WithoutCancel(ctx), m); err != nil {
+		logger.Error("メッセージの削除に失敗しました。再び処理される可能性があります", "error", err)
+	}
+}
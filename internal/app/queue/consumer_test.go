@@ -0,0 +1,124 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/clock"
+	"excavation_service/internal/app/week"
+)
+
+func TestParseTopicRequest(t *testing.T) {
+	req, err := ParseTopicRequest(`{"entity": " 西日暮里 ", "entity_type": "Restaurant", "topic": "西日暮里 カレー"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req != (TopicRequest{Entity: "西日暮里", EntityType: "restaurant", Topic: "西日暮里 カレー"}) {
+		t.Errorf("req = %+v", req)
+	}
+	if req, err := ParseTopicRequest(`{"topic_id": 12}`); err != nil || req.TopicID != 12 {
+		t.Errorf("req = %+v, err = %v", req, err)
+	}
+
+	for _, body := range []string{
+		`not json`,
+		`{}`,
+		`{"topic_id": 12, "entity": "西日暮里"}`,
+		`{"topic_id": -1}`,
+	} {
+		if _, err := ParseTopicRequest(body); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("ParseTopicRequest(%s) error = %v, want ErrInvalidRequest", body, err)
+		}
+	}
+}
+
+type recordingSender struct {
+	mu     sync.Mutex
+	bodies []string
+	fail   bool
+}
+
+func (s *recordingSender) Send(ctx context.Context, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("unavailable")
+	}
+	s.bodies = append(s.bodies, body)
+	return nil
+}
+
+func TestSchedulerEnqueuesOncePerWeek(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	q := &recordingSender{fail: true}
+	s := &scheduler{q: q, sent: make(map[uint]time.Time)}
+	w := week.Of(time.Date(2024, 6, 5, 0, 0, 0, 0, time.UTC))
+
+	if n := s.enqueue(context.Background(), logger, w, []uint{1, 2}); n != 0 {
+		t.Errorf("sent %d while the queue is unavailable", n)
+	}
+	q.fail = false
+	if n := s.enqueue(context.Background(), logger, w, []uint{1, 2}); n != 2 {
+		t.Errorf("sent %d, want 2 after the queue recovers", n)
+	}
+	if n := s.enqueue(context.Background(), logger, w, []uint{1, 2, 3}); n != 1 {
+		t.Errorf("sent %d, want 1 (only the new topic)", n)
+	}
+	if n := s.enqueue(context.Background(), logger, w.AddDate(0, 0, 7), []uint{1}); n != 1 {
+		t.Errorf("sent %d, want 1 in the next week", n)
+	}
+	if q.bodies[0] != `{"tenant":"","topic_id":1,"entity":"","entity_type":"","topic":""}` {
+		t.Errorf("body = %s", q.bodies[0])
+	}
+}
+
+// TestScheduleWeekRolloverは日本時間の月曜日0時に週が替わり、同じトピックの依頼を再び送ることを確認します。
+func TestScheduleWeekRollover(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	loc := week.Location()
+	clk := clock.NewFake(time.Date(2024, 6, 9, 23, 0, 0, 0, loc)) // 日曜日の23時
+	q := &recordingSender{}
+	weeks := make(chan time.Time)
+	s := &scheduler{q: q, clock: clk, sent: make(map[uint]time.Time),
+		due: func(ctx context.Context, w time.Time) ([]uint, error) {
+			weeks <- w
+			return []uint{1, 2}, nil
+		}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.run(ctx, logger, 30*time.Minute)
+		close(done)
+	}()
+
+	want := []time.Time{
+		time.Date(2024, 6, 3, 0, 0, 0, 0, loc),  // 開始時
+		time.Date(2024, 6, 3, 0, 0, 0, 0, loc),  // 23:30
+		time.Date(2024, 6, 10, 0, 0, 0, 0, loc), // 月曜日の0時
+		time.Date(2024, 6, 10, 0, 0, 0, 0, loc), // 0:30
+	}
+	for i, w := range want {
+		if i > 0 {
+			clk.WaitForTickers(1)
+			clk.Advance(30 * time.Minute)
+		}
+		if got := <-weeks; !got.Equal(w) {
+			t.Errorf("check %d: week = %s, want %s", i, got.Format(week.Layout), w.Format(week.Layout))
+		}
+	}
+	cancel()
+	<-done
+
+	// 最後の確認より前の依頼はすべて送り終えている。各週に2件ずつ
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.bodies) != 4 {
+		t.Errorf("sent %d requests, want 4 (2 topics in 2 weeks)", len(q.bodies))
+	}
+}
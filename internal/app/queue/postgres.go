@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/repository"
+
+	"gorm.io/gorm"
+)
+
+// pollInterval はPostgreSQLのキューが空のときに依頼を確認する間隔です。
+const pollInterval = 2 * time.Second
+
+// Postgres はPostgreSQLのtopic_queueテーブルを使うキューです。SQSを用意しない小さな環境で使います。
+// 依頼はSELECT ... FOR UPDATE SKIP LOCKEDで取得するため、複数のconsumeで同じ依頼を同時に処理しません。
+// 取得した依頼はleaseの間に削除しなければ再び取得できるようになり、maxAttempts回取得しても処理を終えなかった依頼はfailedにします。
+type Postgres struct {
+	db          *gorm.DB
+	logger      *slog.Logger
+	lease       time.Duration
+	maxAttempts int
+	claimedBy   string
+}
+
+func NewPostgres(db *gorm.DB, logger *slog.Logger, lease time.Duration, maxAttempts int) *Postgres {
+	host, _ := os.Hostname()
+	return &Postgres{db: db, logger: logger, lease: lease, maxAttempts: maxAttempts, claimedBy: fmt.Sprintf("%s:%d", host, os.Getpid())}
+}
+
+// Receiveは依頼が届くまで最大20秒待ち、届いた依頼を返します。届かなければ空を返します。
+// 発掘は1件に時間がかかり、まとめて取得すると後の依頼の期限が処理を始める前に切れるため、依頼は1件ずつ取得します。
+func (q *Postgres) Receive(ctx context.Context) ([]Message, error) {
+	deadline := time.Now().Add(waitTimeSeconds * time.Second)
+	for {
+		token, err := claimToken()
+		if err != nil {
+			return nil, err
+		}
+		items, failed, err := repository.NewTopicQueueRepository(q.db).WithContext(ctx).Claim(time.Now(), q.lease, 1, q.maxAttempts, token, q.claimedBy)
+		if err != nil {
+			return nil, err
+		}
+		if failed > 0 {
+			q.logger.Warn("試行の上限に達した発掘の依頼をfailedにしました", "count", failed, "max_attempts", q.maxAttempts)
+		}
+		if len(items) > 0 {
+			msgs := make([]Message, len(items))
+			for i, item := range items {
+				if item.Attempts > 1 {
+					q.logger.Warn("処理の期限を過ぎた発掘の依頼を再び取得しました", "message_id", item.ID, "attempts", item.Attempts)
+				}
+				id := strconv.FormatUint(item.ID, 10)
+				msgs[i] = Message{ID: id, Body: item.Body, ReceiptHandle: id + ":" + token}
+			}
+			return msgs, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Deleteは処理を終えた依頼を削除します。期限が切れて別のプロセスが取得し直した依頼は削除せず、エラーを返します。
+func (q *Postgres) Delete(ctx context.Context, m Message) error {
+	idStr, token, ok := strings.Cut(m.ReceiptHandle, ":")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if !ok || err != nil {
+		return fmt.Errorf("invalid receipt handle %q", m.ReceiptHandle)
+	}
+	deleted, err := repository.NewTopicQueueRepository(q.db).WithContext(ctx).Delete(id, token)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("claim of message %s expired before it was deleted", m.ID)
+	}
+	return nil
+}
+
+// Sendはbodyを依頼としてキューに入れます。
+func (q *Postgres) Send(ctx context.Context, body string) error {
+	return repository.NewTopicQueueRepository(q.db).WithContext(ctx).Enqueue(body)
+}
+
+func claimToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"excavation_service/internal/app/clock"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+
+	"gorm.io/gorm"
+)
+
+// Sender はメッセージを送るキューです。SQSとPostgresが満たします。
+type Sender interface {
+	Send(ctx context.Context, body string) error
+}
+
+// Queue は依頼を送受信するキューです。SQSとPostgresが満たします。
+type Queue interface {
+	Receiver
+	Sender
+}
+
+// Scheduleは開始時とintervalごとに、今週のトレンドがまだない有効なトピックの発掘の依頼をキューに送ります。ctxがキャンセルされると終了します。
+// 週はTIMEZONEの月曜日0時に切り替わります(week.Current)。
+// 依頼は優先度の高いトピックから送り(repository.ByPriority)、送った依頼は覚えておき、同じ週に同じトピックの依頼を再び送りません。
+// 依頼はconsumeが受け取って発掘します。
+// 複数のプロセスで動かす場合は、リーダーのプロセスのみで実行してください(leader.Run)。リーダーが替わった直後は
+// 前のリーダーが送って処理中の依頼を再び送ることがありますが、同じ週のトレンドは置き換えられるため結果は重複しません。
+// 現在時刻と確認の間隔はclkに従います。nilの場合はclock.Realを使います。
+func Schedule(ctx context.Context, logger *slog.Logger, db *gorm.DB, q Sender, interval time.Duration, clk clock.Clock) {
+	s := &scheduler{q: q, clock: clock.OrReal(clk), sent: make(map[uint]time.Time),
+		due: func(ctx context.Context, w time.Time) ([]uint, error) {
+			return repository.NewTopicRepository(db).WithContext(ctx).ListDue(w)
+		}}
+	s.run(ctx, logger, interval)
+}
+
+type scheduler struct {
+	q     Sender
+	clock clock.Clock
+	due   func(ctx context.Context, w time.Time) ([]uint, error) // w週のトレンドがまだないトピック
+	sent  map[uint]time.Time                                     // key: トピックのID, value: 依頼を送った週
+}
+
+// runは開始時とintervalごとに今週の依頼を送ります。ctxがキャンセルされると終了します。
+func (s *scheduler) run(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w := week.Of(s.clock.Now())
+		due, err := s.due(ctx, w)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("発掘するトピックの取得に失敗しました", "error", err)
+		}
+		if err == nil {
+			s.enqueue(ctx, logger, w, due)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// enqueueはweek週にまだ依頼を送っていないトピックの依頼を送り、送った数を返します。送れなかった依頼は次の確認で再び送ります。
+func (s *scheduler) enqueue(ctx context.Context, logger *slog.Logger, w time.Time, due []uint) int {
+	sent := 0
+	for _, topicID := range due {
+		if s.sent[topicID].Equal(w) {
+			continue
+		}
+		body, err := json.Marshal(TopicRequest{TopicID: topicID})
+		if err != nil {
+			logger.Error("発掘の依頼の作成に失敗しました", "topic_id", topicID, "error", err)
+			continue
+		}
+		if err := s.q.Send(ctx, string(body)); err != nil {
+			if ctx.Err() == nil {
+				logger.Error("発掘の依頼の送信に失敗しました", "topic_id", topicID, "error", err)
+			}
+			continue
+		}
+		s.sent[topicID] = w
+		sent++
+	}
+	if sent > 0 {
+		logger.Info("定期的な発掘の依頼をキューに送りました", "week", w.Format(week.Layout), "topics", sent)
+	}
+	return sent
+}
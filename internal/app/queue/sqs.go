@@ -0,0 +1,148 @@
+// Package queue は他のシステムからの「このトピックを今すぐ発掘して」という依頼をメッセージキュー(Amazon SQS)から受け取ります。
+// 定期的な発掘もscheduleが同じキューに依頼を送ります。SQSを用意しない小さな環境では、PostgreSQLのテーブルをキューに使えます。
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+const (
+	maxMessages     = 10 // SQSが1回で返すメッセージの最大数
+	waitTimeSeconds = 20 // ロングポーリングの待ち時間の最大
+)
+
+// Message はキューから受け取ったメッセージです。処理を終えたらDeleteで削除します。
+// 削除しなかったメッセージは可視性タイムアウトの後に再び受け取ります。
+type Message struct {
+	ID            string `json:"MessageId"`
+	Body          string `json:"Body"`
+	ReceiptHandle string `json:"ReceiptHandle"`
+}
+
+// SQS はAmazon SQS(またはElasticMQなどのSQS互換のキュー)のキューです。
+// SDKのSQSクライアントの代わりに、JSONプロトコルのAPIを署名付きのHTTPリクエストで直接呼び出します。
+type SQS struct {
+	queueURL    string
+	endpoint    string
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+// NewSQSは標準のAWS認証情報(環境変数、共有設定ファイル、IAMロール)を使うキューを作成します。
+// endpointが空ならqueueURLのホストにリクエストを送ります。リージョンが設定されていなければqueueURLから判定します。
+func NewSQS(ctx context.Context, queueURL, endpoint string) (*SQS, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	u, err := url.Parse(queueURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid queue url %q", queueURL)
+	}
+	if endpoint == "" {
+		endpoint = u.Scheme + "://" + u.Host
+	}
+	region := cfg.Region
+	if region == "" {
+		// https://sqs.<リージョン>.amazonaws.com/<アカウントID>/<キュー名>
+		if parts := strings.Split(u.Host, "."); len(parts) == 4 && parts[0] == "sqs" {
+			region = parts[1]
+		}
+	}
+	if region == "" {
+		return nil, fmt.Errorf("aws region is not configured (set AWS_REGION)")
+	}
+	return &SQS{
+		queueURL:    queueURL,
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		region:      region,
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+		client:      tracing.NewHTTPClient(time.Duration(waitTimeSeconds+10) * time.Second),
+	}, nil
+}
+
+// Receiveはメッセージが届くまで最大20秒待ち、届いたメッセージ(最大10件)を返します。届かなければ空を返します。
+func (q *SQS) Receive(ctx context.Context) ([]Message, error) {
+	var resp struct {
+		Messages []Message `json:"Messages"`
+	}
+	err := q.call(ctx, "ReceiveMessage", map[string]interface{}{
+		"QueueUrl":            q.queueURL,
+		"MaxNumberOfMessages": maxMessages,
+		"WaitTimeSeconds":     waitTimeSeconds,
+	}, &resp)
+	return resp.Messages, err
+}
+
+// Deleteは処理を終えたメッセージをキューから削除します。
+func (q *SQS) Delete(ctx context.Context, m Message) error {
+	return q.call(ctx, "DeleteMessage", map[string]string{"QueueUrl": q.queueURL, "ReceiptHandle": m.ReceiptHandle}, nil)
+}
+
+// Sendはbodyをメッセージとしてキューに送ります。
+func (q *SQS) Send(ctx context.Context, body string) error {
+	return q.call(ctx, "SendMessage", map[string]string{"QueueUrl": q.queueURL, "MessageBody": body}, nil)
+}
+
+func (q *SQS) call(ctx context.Context, action string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+
+	creds, err := q.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("sqs %s: retrieve credentials: %w", action, err)
+	}
+	sum := sha256.Sum256(body)
+	if err := q.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(sum[:]), "sqs", q.region, time.Now()); err != nil {
+		return fmt.Errorf("sqs %s: sign request: %w", action, err)
+	}
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sqs %s: %w", action, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if json.Unmarshal(b, &e) == nil && e.Type != "" {
+			return fmt.Errorf("sqs %s: %s: %s: %s", action, resp.Status, e.Type, e.Message)
+		}
+		return fmt.Errorf("sqs %s: %s: %s", action, resp.Status, bytes.TrimSpace(b))
+	}
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,111 @@
+// Package quota はテナントとAPIキーの1日・1か月のリクエスト数の上限を判定します。
+// リクエスト数はDBのカウンターで数えるため、APIサーバーを複数台で動かしても上限は共通です。
+package quota
+
+import (
+	"time"
+
+	"excavation_service/internal/app/model"
+)
+
+// Period は上限を数える期間です。期間はローカル時刻の暦の日・月で区切ります。
+type Period string
+
+const (
+	Day   Period = "day"
+	Month Period = "month"
+)
+
+// 上限を設定する対象
+const (
+	ScopeTenant = "tenant"
+	ScopeAPIKey = "api_key"
+)
+
+// Startはnowを含む期間の初日(0時)を返します。
+func (p Period) Start(now time.Time) time.Time {
+	y, m, d := now.Date()
+	if p == Month {
+		d = 1
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+}
+
+// Endはnowを含む期間の次の期間の始まりを返します。上限を超えた場合、この時刻にリクエスト数が戻ります。
+func (p Period) End(now time.Time) time.Time {
+	start := p.Start(now)
+	if p == Month {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Limit は1つの対象の1つの期間のリクエスト数の上限です。
+type Limit struct {
+	Scope  string
+	ID     uint
+	Period Period
+	Max    int
+}
+
+// Limitsはテナントtと、APIキーkが指定されていればそのキーに設定された上限を返します。
+func Limits(t *model.Tenant, k *model.APIKey) []Limit {
+	var limits []Limit
+	add := func(scope string, id uint, period Period, max *int) {
+		if max != nil {
+			limits = append(limits, Limit{Scope: scope, ID: id, Period: period, Max: *max})
+		}
+	}
+	if k != nil {
+		add(ScopeAPIKey, k.ID, Day, k.DailyRequestLimit)
+		add(ScopeAPIKey, k.ID, Month, k.MonthlyRequestLimit)
+	}
+	add(ScopeTenant, t.ID, Day, t.DailyRequestLimit)
+	add(ScopeTenant, t.ID, Month, t.MonthlyRequestLimit)
+	return limits
+}
+
+// Counter はリクエスト数のカウンターです。
+type Counter interface {
+	// Incrementは対象の期間(初日がstart)のリクエスト数を1増やし、増やした後のリクエスト数を返します。
+	Increment(scope string, id uint, period Period, start time.Time) (int, error)
+}
+
+// Result は上限の判定の結果です。応答のヘッダーで利用者に残りのリクエスト数を伝えるために使います。
+type Result struct {
+	Period    Period    // 最も厳しい上限の期間
+	Limit     int       // 最も厳しい上限
+	Remaining int       // その上限までの残りのリクエスト数
+	Reset     time.Time // その上限のリクエスト数が戻る時刻
+	Exceeded  bool      // いずれかの上限を超えた
+}
+
+// Checkはlimitsのすべての上限のリクエスト数を1増やし、最も厳しい上限の結果を返します。
+// 上限を超えたものがあれば、その中でリクエスト数が戻るのが最も遅い上限の結果です。limitsが空の場合はokがfalseです。
+func Check(counter Counter, limits []Limit, now time.Time) (result Result, ok bool, err error) {
+	for _, l := range limits {
+		n, err := counter.Increment(l.Scope, l.ID, l.Period, l.Period.Start(now))
+		if err != nil {
+			return Result{}, false, err
+		}
+		r := Result{Period: l.Period, Limit: l.Max, Remaining: max(l.Max-n, 0), Reset: l.Period.End(now), Exceeded: n > l.Max}
+		if !ok || stricter(r, result) {
+			result, ok = r, true
+		}
+	}
+	return result, ok, nil
+}
+
+// stricterはaがbより厳しい結果か判定します。
+func stricter(a, b Result) bool {
+	switch {
+	case a.Exceeded != b.Exceeded:
+		return a.Exceeded
+	case a.Exceeded:
+		return a.Reset.After(b.Reset)
+	case a.Remaining != b.Remaining:
+		return a.Remaining < b.Remaining
+	default:
+		return a.Reset.After(b.Reset)
+	}
+}
@@ -0,0 +1,76 @@
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+)
+
+type fakeCounter map[Limit]int
+
+func (f fakeCounter) Increment(scope string, id uint, period Period, start time.Time) (int, error) {
+	key := Limit{Scope: scope, ID: id, Period: period}
+	f[key]++
+	return f[key], nil
+}
+
+func TestPeriod(t *testing.T) {
+	now := time.Date(2024, 12, 31, 15, 4, 5, 0, time.UTC)
+	if got := Day.Start(now); !got.Equal(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Day.Start = %s", got)
+	}
+	if got := Day.End(now); !got.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Day.End = %s", got)
+	}
+	if got := Month.Start(now); !got.Equal(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Month.Start = %s", got)
+	}
+	if got := Month.End(now); !got.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Month.End = %s", got)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	daily, monthly := 10, 100
+	tenant := &model.Tenant{ID: 1, MonthlyRequestLimit: &monthly}
+	key := &model.APIKey{ID: 5, DailyRequestLimit: &daily}
+	got := Limits(tenant, key)
+	want := []Limit{{ScopeAPIKey, 5, Day, 10}, {ScopeTenant, 1, Month, 100}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Limits = %+v, want %+v", got, want)
+	}
+	if got := Limits(&model.Tenant{ID: 1}, nil); len(got) != 0 {
+		t.Errorf("Limits without quotas = %+v, want none", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	limits := []Limit{{ScopeAPIKey, 5, Day, 1}, {ScopeTenant, 1, Month, 2}}
+	counter := fakeCounter{}
+
+	r, ok, err := Check(counter, limits, now)
+	if err != nil || !ok {
+		t.Fatalf("Check = %v, %v", ok, err)
+	}
+	// 日の上限の残りは0、月の上限の残りは1
+	if r.Limit != 1 || r.Remaining != 0 || r.Exceeded || !r.Reset.Equal(Day.End(now)) {
+		t.Errorf("first check = %+v", r)
+	}
+
+	r, _, _ = Check(counter, limits, now)
+	if r.Period != Day || r.Limit != 1 || !r.Exceeded || !r.Reset.Equal(Day.End(now)) {
+		t.Errorf("second check = %+v, want daily quota exceeded", r)
+	}
+
+	r, _, _ = Check(counter, limits, now)
+	// 両方の上限を超えた場合、リクエスト数が戻るのが遅い月の上限の結果を返す
+	if r.Period != Month || r.Limit != 2 || r.Remaining != 0 || !r.Exceeded || !r.Reset.Equal(Month.End(now)) {
+		t.Errorf("third check = %+v, want monthly quota exceeded", r)
+	}
+
+	if _, ok, _ := Check(counter, nil, now); ok {
+		t.Error("Check without limits returned ok")
+	}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval は満杯に戻ったバケットを捨てる間隔です。満杯のバケットは記録がないのと同じです。
+const sweepInterval = time.Minute
+
+// Memory はバケットをプロセスのメモリに記録するStoreです。APIサーバーを複数台で動かすと、上限は台ごとになります。
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // 満杯に戻る時刻
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket)}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		m.buckets[key] = b
+	}
+	r, left := result(limit, refill(limit, b.tokens, b.last, now), now)
+	b.tokens, b.last, b.full = left, now, r.Reset
+	return r, nil
+}
+
+// sweepは満杯に戻ったバケットを捨てます。
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
@@ -0,0 +1,85 @@
+// Package ratelimit はクライアントごとの短い間隔のリクエスト数をトークンバケットで制限します。
+//
+// quotaの1日・1か月の上限と異なり、瞬間的なリクエストの集中からAPIサーバーとDBを守るためのものです。
+// バケットはプロセスのメモリに持つか、APIサーバーを複数台で動かす場合はRedisで共有します。
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit はバケットの大きさと補充の速さです。
+type Limit struct {
+	Rate  float64 // 1秒あたりに補充するトークンの数
+	Burst int     // バケットに貯められるトークンの数。続けて送れるリクエスト数の上限
+}
+
+// PerMinuteは1分あたりn回、続けてburst回までのリクエストを許すLimitを返します。
+func PerMinute(n, burst int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: burst}
+}
+
+// Result はリクエスト1回分のトークンを取り出した結果です。
+type Result struct {
+	Allowed    bool
+	Limit      int           // バケットの大きさ
+	Remaining  int           // 取り出した後に残っているトークンの数
+	Reset      time.Time     // バケットが満杯に戻る時刻
+	RetryAfter time.Duration // 拒否した場合、次のトークンが補充されるまでの時間
+}
+
+// Store はクライアントごとのバケットを記録します。
+type Store interface {
+	// Takeはkeyのバケットをnowまでの分だけ補充してからトークンを1つ取り出します。
+	// トークンがなければ取り出さずにAllowedがfalseの結果を返します。
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Limiter はStoreのバケットに1つのLimitを適用します。
+type Limiter struct {
+	store Store
+	limit Limit
+}
+
+func New(store Store, limit Limit) *Limiter {
+	return &Limiter{store: store, limit: limit}
+}
+
+// Limitは適用するLimitを返します。
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Takeはkeyのバケットからトークンを1つ取り出します。
+func (l *Limiter) Take(ctx context.Context, key string, now time.Time) (Result, error) {
+	return l.store.Take(ctx, key, l.limit, now)
+}
+
+// resultは補充した後のトークンの数tokensから取り出した結果と、取り出した後のトークンの数を返します。
+func result(limit Limit, tokens float64, now time.Time) (Result, float64) {
+	r := Result{Allowed: tokens >= 1, Limit: limit.Burst}
+	left := tokens
+	if r.Allowed {
+		left--
+	} else {
+		r.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	r.Remaining = int(left)
+	r.Reset = now.Add(seconds((float64(limit.Burst) - left) / limit.Rate))
+	return r, left
+}
+
+// refillは最後に記録したlastからnowまでの分だけtokensを補充します。
+func refill(limit Limit, tokens float64, last, now time.Time) float64 {
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens += elapsed * limit.Rate
+	}
+	return math.Min(tokens, float64(limit.Burst))
+}
+
+// secondsは秒数sを切り上げたミリ秒単位のDurationにします。
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s*1000)) * time.Millisecond
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/testdb"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
+}
+
+// testStoreはバケットが空になるまで取り出し、補充された分だけ再び取り出せることを確認します。
+func testStore(t *testing.T, s Store, key string) {
+	t.Helper()
+	ctx := context.Background()
+	limit := Limit{Rate: 2, Burst: 3}
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 2; i >= 0; i-- {
+		r, err := s.Take(ctx, key, limit, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.Allowed || r.Remaining != i || r.Limit != 3 {
+			t.Fatalf("Take = %+v, want allowed with %d remaining", r, i)
+		}
+	}
+	r, err := s.Take(ctx, key, limit, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Allowed || r.Remaining != 0 {
+		t.Errorf("Take on empty bucket = %+v, want denied", r)
+	}
+	if r.RetryAfter != 500*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 500ms", r.RetryAfter)
+	}
+	if want := now.Add(1500 * time.Millisecond); !r.Reset.Equal(want) {
+		t.Errorf("Reset = %v, want %v", r.Reset, want)
+	}
+
+	// 0.5秒で1つ補充される
+	if r, _ := s.Take(ctx, key, limit, now.Add(500*time.Millisecond)); !r.Allowed || r.Remaining != 0 {
+		t.Errorf("Take after refill = %+v, want allowed", r)
+	}
+	// 補充はバケットの大きさまで
+	if r, _ := s.Take(ctx, key, limit, now.Add(time.Hour)); !r.Allowed || r.Remaining != 2 {
+		t.Errorf("Take after an hour = %+v, want 2 remaining", r)
+	}
+	// キーごとに別のバケット
+	if r, _ := s.Take(ctx, key+":other", limit, now); !r.Allowed || r.Remaining != 2 {
+		t.Errorf("Take with another key = %+v, want 2 remaining", r)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory(), "client")
+}
+
+func TestMemorySweep(t *testing.T) {
+	m := NewMemory()
+	limit := PerMinute(60, 5)
+	now := time.Unix(1_700_000_000, 0)
+	m.Take(context.Background(), "a", limit, now)
+	m.Take(context.Background(), "b", limit, now.Add(time.Minute))
+	if len(m.buckets) != 1 {
+		t.Errorf("buckets = %d after sweep, want 1", len(m.buckets))
+	}
+}
+
+// TestRedisServerは本物のRedis(TEST_REDIS_URLまたはDockerのコンテナ)でバケットを確認します。
+func TestRedisServer(t *testing.T) {
+	s, err := NewRedis(testdb.Redis(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	testStore(t, s, "test:"+strconv.FormatInt(time.Now().UnixNano(), 36))
+}
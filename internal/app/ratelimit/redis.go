@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"excavation_service/internal/app/redisclient"
+)
+
+const redisKeyPrefix = "excavation:ratelimit:"
+
+// takeScriptはバケットを補充してトークンを1つ取り出し、補充した後(取り出す前)のトークンの数を返すLuaのスクリプトです。
+// 補充と取り出しを1つのスクリプトで行うため、複数のAPIサーバーが同時に取り出しても数がずれません。
+// バケットは満杯に戻るまでの時間で消えます。ARGVは1ミリ秒あたりの補充数・バケットの大きさ・現在時刻(Unix時間のミリ秒)です。
+const takeScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate)
+end
+local left = tokens
+if tokens >= 1 then
+	left = tokens - 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(left), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - left) / rate) + 1000)
+return tostring(tokens)
+`
+
+// Redis はバケットをRedisに記録するStoreです。APIサーバーを複数台で動かしても上限は共通です。
+// 時刻は各APIサーバーの時計を使うため、サーバー間の時計のずれの分だけ補充の量がずれます。
+type Redis struct {
+	client *redisclient.Client
+}
+
+// NewRedisはredis://[:password@]host:port[/db]形式のURLのRedisに記録するStoreを作成します。接続は最初に使うときに開きます。
+func NewRedis(rawURL string) (*Redis, error) {
+	client, err := redisclient.New(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{client: client}, nil
+}
+
+func (r *Redis) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	reply, err := r.client.Do(ctx, "EVAL", takeScript, "1", redisKeyPrefix+key,
+		strconv.FormatFloat(limit.Rate/1000, 'g', -1, 64), strconv.Itoa(limit.Burst), strconv.FormatInt(now.UnixMilli(), 10))
+	if err != nil {
+		return Result{}, err
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return Result{}, fmt.Errorf("unexpected reply %v", reply)
+	}
+	tokens, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("unexpected reply %q", s)
+	}
+	res, _ := result(limit, tokens, now)
+	return res, nil
+}
+
+// Closeは接続を閉じます。
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
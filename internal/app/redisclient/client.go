@@ -0,0 +1,182 @@
+// Package redisclient はRedisにRESPで接続する最小限のクライアントです。
+//
+// クライアントライブラリの代わりに1本の接続を使い回し、重複排除(dedup)やレート制限(ratelimit)の記録に使います。
+package redisclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dialTimeout = 5 * time.Second
+	ioTimeout   = 5 * time.Second
+)
+
+// Client はRedisへの接続です。複数のgoroutineから同時に使えますが、コマンドは1つずつ送ります。
+type Client struct {
+	addr     string
+	password string
+	db       int
+	useTLS   bool
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Newはredis://[:password@]host:port[/db]形式のURLのRedisのクライアントを作成します。TLSで接続する場合はrediss://を使います。
+// 接続は最初に使うときに開きます。
+func New(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+		return nil, fmt.Errorf("invalid redis url (want redis://[:password@]host:port[/db])")
+	}
+	r := &Client{addr: u.Host, useTLS: u.Scheme == "rediss"}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if p, ok := u.User.Password(); ok {
+		r.password = p
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis db %q", db)
+		}
+	}
+	return r, nil
+}
+
+// Closeは接続を閉じます。
+func (r *Client) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn, r.r = nil, nil
+	return err
+}
+
+// Doはコマンドを送って応答を返します。応答は単純文字列・一括文字列がstring、整数がint64、nilの一括文字列がnilです。通信に失敗した接続は閉じ、次のコマンドで開き直します。
+func (r *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		if err := r.connect(ctx); err != nil {
+			return nil, fmt.Errorf("connect to redis: %w", err)
+		}
+	}
+	reply, err := r.command(ctx, args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		r.conn.Close()
+		r.conn, r.r = nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis %s: %w", args[0], err)
+	}
+	return reply, nil
+}
+
+func (r *Client) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if r.useTLS {
+		host, _, _ := net.SplitHostPort(r.addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return err
+	}
+	r.conn, r.r = conn, bufio.NewReader(conn)
+	if r.password != "" {
+		if _, err = r.command(ctx, "AUTH", r.password); err != nil {
+			err = fmt.Errorf("auth: %w", err)
+		}
+	}
+	if err == nil && r.db != 0 {
+		if _, err = r.command(ctx, "SELECT", strconv.Itoa(r.db)); err != nil {
+			err = fmt.Errorf("select db %d: %w", r.db, err)
+		}
+	}
+	if err != nil {
+		conn.Close()
+		r.conn, r.r = nil, nil
+	}
+	return err
+}
+
+// commandはコマンドをRESPの配列で送り、応答を1つ読みます。
+func (r *Client) command(ctx context.Context, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(ioTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	r.conn.SetDeadline(deadline)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(r.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readReply(r.r)
+}
+
+// redisError はRedisが返したエラーの応答です。接続は引き続き使えます。
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// readReplyは応答を1つ読みます。単純文字列・一括文字列はstring、整数はint64、nilの一括文字列はnilです。配列の応答は使いません。
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	}
+	return nil, fmt.Errorf("unsupported reply %q", line)
+}
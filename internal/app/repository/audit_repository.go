@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+)
+
+// AuditRepository は管理操作の監査ログを扱うリポジトリです。
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *AuditRepository) WithContext(ctx context.Context) *AuditRepository {
+	return &AuditRepository{db: r.db.WithContext(ctx)}
+}
+
+// Recordは管理操作を記録します。before、afterはJSONに変換して保存し、nilの場合は記録しません。
+// 変更と同じトランザクションのリポジトリで呼び出すと、変更がロールバックされた場合は記録も残りません。
+func (r *AuditRepository) Record(actor, action, targetType string, targetID interface{}, before, after interface{}) error {
+	entry := model.AuditLog{Actor: actor, Action: action, TargetType: targetType}
+	if targetID != nil {
+		entry.TargetID = fmt.Sprint(targetID)
+	}
+	var err error
+	if entry.Before, err = marshalAudit(before); err != nil {
+		return err
+	}
+	if entry.After, err = marshalAudit(after); err != nil {
+		return err
+	}
+	return r.db.Create(&entry).Error
+}
+
+func marshalAudit(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encode audit payload: %w", err)
+	}
+	return b, nil
+}
+
+// AuditFilter は監査ログの絞り込み条件です。空の条件は使いません。
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	Since      time.Time
+	After      Cursor // 前のページの最後の監査ログの作成日時とID
+	Limit      int
+}
+
+// Listは新しい順に監査ログを返します。
+func (r *AuditRepository) List(f AuditFilter) ([]model.AuditLog, error) {
+	var logs []model.AuditLog
+	q := r.db.Scopes(before("created_at", "id", f.After)).Order("created_at DESC, id DESC").Limit(f.Limit)
+	if f.Actor != "" {
+		q = q.Where("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if f.TargetType != "" {
+		q = q.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != "" {
+		q = q.Where("target_id = ?", f.TargetID)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	err := q.Find(&logs).Error
+	return logs, err
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor はParseCursorに渡したcursorが壊れているか、このサービスが発行したものではないことを表します。
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor はキーセットページネーションの位置(前のページの最後の行)です。
+// 一覧は並び順の項目とIDで並べ、次のページはこの位置より後ろの行を読むため、OFFSETと違って後ろのページでも読み飛ばす行が増えません。
+// Atはジョブであれば開始時刻のように一覧ごとの並び順の時刻です。並び順を選べる一覧では、Atの代わりにValuesに並び順の項目の値を持ちます。
+// ゼロ値は先頭のページです。
+type Cursor struct {
+	At     time.Time
+	ID     uint
+	Values []string
+}
+
+// IsZeroは先頭のページのcursorであればtrueを返します。
+func (c Cursor) IsZero() bool {
+	return c.ID == 0 && c.At.IsZero() && len(c.Values) == 0
+}
+
+// Stringはクエリのcursorに指定する不透明な文字列を返します。クライアントは中身に依存せず、応答の値をそのまま渡してください。
+func (c Cursor) String() string {
+	raw := c.At.UTC().Format(time.RFC3339Nano) + "," + strconv.FormatUint(uint64(c.ID), 10)
+	for _, v := range c.Values {
+		raw += "," + v
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursorはCursor.Stringの文字列を読みます。読めなければErrInvalidCursorを返します。
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ",")
+	if len(parts) < 2 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var c Cursor
+	if c.At, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	n, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil || n == 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	c.ID = uint(n)
+	if len(parts) > 2 {
+		c.Values = parts[2:]
+	}
+	return c, nil
+}
+
+// beforeは(atColumn, idColumn)の降順の一覧でcursorより後ろ(cursorの行を含まない)の行に絞り込むスコープです。
+// 先頭のページのcursorでは絞り込みません。
+func before(atColumn, idColumn string, c Cursor) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if c.IsZero() {
+			return db
+		}
+		return db.Where("("+atColumn+", "+idColumn+") < (?, ?)", c.At, c.ID)
+	}
+}
+
+// afterIDはidColumnの昇順の一覧でcursorより後ろ(cursorの行を含まない)の行に絞り込むスコープです。
+// 時刻で並べないID順の一覧に使い、cursorのAtは見ません。先頭のページのcursorでは絞り込みません。
+func afterID(idColumn string, c Cursor) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if c.IsZero() {
+			return db
+		}
+		return db.Where(idColumn+" > ?", c.ID)
+	}
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	c := Cursor{At: time.Date(2024, 6, 3, 12, 34, 56, 789000, time.UTC), ID: 42}
+	got, err := ParseCursor(c.String())
+	if err != nil || !got.At.Equal(c.At) || got.ID != c.ID {
+		t.Errorf("ParseCursor(%q) = %+v, %v; want %+v", c.String(), got, err, c)
+	}
+	if (Cursor{}).IsZero() != true || c.IsZero() {
+		t.Error("IsZero: want true only for the zero cursor")
+	}
+
+	// 数値のID(以前のcursor)、base64でないもの、IDのないもの、時刻でないもの、IDが0のもの
+	for _, s := range []string{"123", "!!", "MjAyNC0wNi0wMw", "bm90LWEtdGltZSw0Mg", "MjAyNC0wNi0wM1QwMDowMDowMFosMA"} {
+		if _, err := ParseCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ParseCursor(%q) error = %v; want ErrInvalidCursor", s, err)
+		}
+	}
+}
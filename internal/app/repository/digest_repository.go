@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DigestRepository は週次ダイジェストメールの購読を扱うリポジトリです。
+type DigestRepository struct {
+	db *gorm.DB
+}
+
+func NewDigestRepository(db *gorm.DB) *DigestRepository {
+	return &DigestRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *DigestRepository) WithContext(ctx context.Context) *DigestRepository {
+	return &DigestRepository{db: r.db.WithContext(ctx)}
+}
+
+// Subscribeはemailの購読を登録します。配信を停止していた場合は購読を再開します。
+// lineUserIDが空でなければLINEで、空ならメールで送るように受け取り方を更新します。
+func (r *DigestRepository) Subscribe(email, lineUserID string) (*model.DigestSubscription, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	sub := model.DigestSubscription{Email: email, Token: token, LineUserID: lineUserID}
+	err = r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "email"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"unsubscribed_at": nil, "line_user_id": lineUserID, "updated_at": time.Now()}),
+	}).Create(&sub).Error
+	if err != nil {
+		return nil, err
+	}
+	// 既存の購読の場合はトークンを変えない
+	if err := r.db.Where("email = ?", email).Take(&sub).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// Unsubscribeはtokenの購読の配信を停止します。該当する購読がなければgorm.ErrRecordNotFoundを返します。
+// 停止済みの購読に対しては何もしません。
+func (r *DigestRepository) Unsubscribe(token string, now time.Time) (*model.DigestSubscription, error) {
+	var sub model.DigestSubscription
+	if err := r.db.Where("token = ?", token).Take(&sub).Error; err != nil {
+		return nil, err
+	}
+	if sub.UnsubscribedAt != nil {
+		return &sub, nil
+	}
+	if err := r.db.Model(&sub).Update("unsubscribed_at", now).Error; err != nil {
+		return nil, err
+	}
+	sub.UnsubscribedAt = &now
+	return &sub, nil
+}
+
+// ListPendingは配信を停止しておらず、week週のダイジェストをまだ送っていない購読をID順に返します。
+func (r *DigestRepository) ListPending(week time.Time) ([]model.DigestSubscription, error) {
+	var subs []model.DigestSubscription
+	err := r.db.Where("unsubscribed_at IS NULL AND (last_sent_week IS NULL OR last_sent_week < ?)", week).
+		Order("id").
+		Find(&subs).Error
+	return subs, err
+}
+
+// MarkSentはweek週のダイジェストを送ったことを記録します。
+func (r *DigestRepository) MarkSent(id uint, week time.Time) error {
+	return r.db.Model(&model.DigestSubscription{}).Where("id = ?", id).Update("last_sent_week", week).Error
+}
+
+// newTokenは配信停止のリンクに使うランダムなトークンを生成します。
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GeocodeCacheRepository は住所の変換結果のキャッシュを扱うリポジトリです。
+type GeocodeCacheRepository struct {
+	db *gorm.DB
+}
+
+func NewGeocodeCacheRepository(db *gorm.DB) *GeocodeCacheRepository {
+	return &GeocodeCacheRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *GeocodeCacheRepository) WithContext(ctx context.Context) *GeocodeCacheRepository {
+	return &GeocodeCacheRepository{db: r.db.WithContext(ctx)}
+}
+
+// Getはproviderでaddressを変換した結果を返します。キャッシュになければgorm.ErrRecordNotFoundを返します。
+func (r *GeocodeCacheRepository) Get(provider, address string) (*model.GeocodeCache, error) {
+	var entry model.GeocodeCache
+	if err := r.db.Where("provider = ? AND address = ?", provider, address).Take(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Putは変換結果を保存します。同じ住所の結果が既にあれば置き換えます。
+func (r *GeocodeCacheRepository) Put(entry *model.GeocodeCache) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "provider"}, {Name: "address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"latitude", "longitude", "created_at"}),
+	}).Create(entry).Error
+}
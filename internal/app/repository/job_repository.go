@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+)
+
+// JobRepository はバッチ処理の実行(ジョブ)を扱うリポジトリです。
+type JobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *JobRepository) WithContext(ctx context.Context) *JobRepository {
+	return &JobRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *JobRepository) Create(job *model.Job) error {
+	return r.db.Create(job).Error
+}
+
+// Saveはジョブの進捗と状態を更新します。
+func (r *JobRepository) Save(job *model.Job) error {
+	return r.db.Model(job).Select(
+		"status", "topics_done", "topics_failed", "pages_fetched", "estimated_finish_at", "error", "finished_at", "updated_at",
+	).Updates(job).Error
+}
+
+// Getはジョブを返します。該当するジョブがなければgorm.ErrRecordNotFoundを返します。
+func (r *JobRepository) Get(id uint) (*model.Job, error) {
+	var job model.Job
+	if err := r.db.Take(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Listは新しい順にジョブを返します。kind、statusが空でなければその種別・状態のジョブのみ返します。
+// afterが先頭のページでなければ、afterのジョブ(前のページの最後のジョブの開始時刻とID)より後ろを返します。
+func (r *JobRepository) List(kind, status string, after Cursor, limit int) ([]model.Job, error) {
+	var jobs []model.Job
+	q := r.db.Scopes(before("started_at", "id", after)).Order("started_at DESC, id DESC").Limit(limit)
+	if kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Find(&jobs).Error
+	return jobs, err
+}
+
+// Finishはジョブを終了した状態にします。errMsgが空でなければ失敗として記録します。
+func (r *JobRepository) Finish(job *model.Job, errMsg string, now time.Time) error {
+	job.Status = model.JobStatusSucceeded
+	if errMsg != "" {
+		job.Status = model.JobStatusFailed
+	}
+	job.Error = errMsg
+	job.FinishedAt = &now
+	job.EstimatedFinishAt = nil
+	return r.Save(job)
+}
+
+// GetByRunIDはrun_idのジョブを返します。該当するジョブがなければgorm.ErrRecordNotFoundを返します。
+func (r *JobRepository) GetByRunID(runID string) (*model.Job, error) {
+	var job model.Job
+	if err := r.db.Where("run_id = ?", runID).Take(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RecordTopicはジョブでのトピックの処理の結果を記録します。
+func (r *JobRepository) RecordTopic(jt *model.JobTopic) error {
+	return r.db.Create(jt).Error
+}
+
+// JobTopicItem はトピック名を付けたトピックの処理の結果です。
+type JobTopicItem struct {
+	model.JobTopic
+	Topic string `json:"topic"`
+}
+
+// ListTopicsはジョブで処理したトピックの結果を処理した順に返します。
+func (r *JobRepository) ListTopics(jobID uint) ([]JobTopicItem, error) {
+	var items []JobTopicItem
+	err := r.db.Table("job_topics AS jt").
+		Select("jt.*, et.topic").
+		Joins("LEFT JOIN entity_topics AS et ON et.id = jt.topic_id").
+		Where("jt.job_id = ?", jobID).
+		Order("jt.started_at, jt.id").
+		Scan(&items).Error
+	return items, err
+}
+
+// CountOutcomesはジョブごとにトピックの結果の件数を集計します。
+func (r *JobRepository) CountOutcomes(jobIDs []uint) (map[uint]map[string]int, error) {
+	counts := make(map[uint]map[string]int, len(jobIDs))
+	if len(jobIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		JobID   uint
+		Outcome string
+		Count   int
+	}
+	err := r.db.Model(&model.JobTopic{}).
+		Select("job_id, outcome, COUNT(*) AS count").
+		Where("job_id IN ?", jobIDs).
+		Group("job_id, outcome").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if counts[row.JobID] == nil {
+			counts[row.JobID] = make(map[string]int)
+		}
+		counts[row.JobID][row.Outcome] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+)
+
+// NotificationPreferenceRepository はトピックごとの通知の設定を扱うリポジトリです。
+type NotificationPreferenceRepository struct {
+	db       *gorm.DB
+	tenantID uint // 0の場合はすべてのテナントが対象
+}
+
+func NewNotificationPreferenceRepository(db *gorm.DB) *NotificationPreferenceRepository {
+	return &NotificationPreferenceRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *NotificationPreferenceRepository) WithContext(ctx context.Context) *NotificationPreferenceRepository {
+	return &NotificationPreferenceRepository{db: r.db.WithContext(ctx), tenantID: r.tenantID}
+}
+
+// ForTenantはテナントtenantIDの通知の設定のみを扱うリポジトリを返します。
+func (r *NotificationPreferenceRepository) ForTenant(tenantID uint) *NotificationPreferenceRepository {
+	return &NotificationPreferenceRepository{db: r.db, tenantID: tenantID}
+}
+
+func (r *NotificationPreferenceRepository) Create(pref *model.NotificationPreference) error {
+	return r.db.Create(pref).Error
+}
+
+// Getは通知の設定を返します。該当する設定がなければgorm.ErrRecordNotFoundを返します。
+func (r *NotificationPreferenceRepository) Get(id uint) (*model.NotificationPreference, error) {
+	var pref model.NotificationPreference
+	if err := r.db.Scopes(topicsOfTenant(r.tenantID)).Take(&pref, id).Error; err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// Listは通知の設定をID順に返します。topicIDが0でなければそのトピックの設定のみ返します。
+// afterが先頭のページでなければ、afterの設定(前のページの最後の設定のID)より後ろを最大limit件返します。
+func (r *NotificationPreferenceRepository) List(topicID uint, after Cursor, limit int) ([]model.NotificationPreference, error) {
+	var prefs []model.NotificationPreference
+	q := r.db.Scopes(topicsOfTenant(r.tenantID), afterID("id", after)).Order("id").Limit(limit)
+	if topicID != 0 {
+		q = q.Where("topic_id = ?", topicID)
+	}
+	err := q.Find(&prefs).Error
+	return prefs, err
+}
+
+// ListByTopicは指定トピックの通知の設定をID順に返します。トレンドの通知先を決めるために使います。
+func (r *NotificationPreferenceRepository) ListByTopic(topicID uint) ([]model.NotificationPreference, error) {
+	var prefs []model.NotificationPreference
+	err := r.db.Scopes(topicsOfTenant(r.tenantID)).Where("topic_id = ?", topicID).Order("id").Find(&prefs).Error
+	return prefs, err
+}
+
+// Updateは通知の設定の通知先・最低スコア・送り方を更新します。該当する設定がなければgorm.ErrRecordNotFoundを返します。
+func (r *NotificationPreferenceRepository) Update(pref *model.NotificationPreference) error {
+	result := r.db.Model(pref).Scopes(topicsOfTenant(r.tenantID)).Select("channel", "min_score", "mode", "updated_at").Updates(pref)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Deleteは通知の設定を削除します。該当する設定がなければgorm.ErrRecordNotFoundを返します。
+func (r *NotificationPreferenceRepository) Delete(id uint) error {
+	result := r.db.Scopes(topicsOfTenant(r.tenantID)).Delete(&model.NotificationPreference{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrIdentityExists は同じプロバイダーの同じメールアドレスのアカウントが既にAPIキーに紐付いていることを表します。
+var ErrIdentityExists = errors.New("account with the same email is already linked")
+
+// OAuthIdentityRepository は外部のアカウントとAPIキーの紐付けを扱うリポジトリです。
+type OAuthIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthIdentityRepository(db *gorm.DB) *OAuthIdentityRepository {
+	return &OAuthIdentityRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *OAuthIdentityRepository) WithContext(ctx context.Context) *OAuthIdentityRepository {
+	return &OAuthIdentityRepository{db: r.db.WithContext(ctx)}
+}
+
+// Linkはプロバイダーproviderのメールアドレスemailのアカウントを、有効なAPIキーkeyに紐付けます。
+// 同じメールアドレスのアカウントが既に紐付いていればErrIdentityExistsを返します。メールアドレスの大文字・小文字は区別しません。
+func (r *OAuthIdentityRepository) Link(provider, email string, key *model.APIKey) (*model.OAuthIdentity, error) {
+	identity := model.OAuthIdentity{Provider: provider, Email: strings.TrimSpace(email), TenantID: key.TenantID, APIKeyID: key.ID}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&model.OAuthIdentity{}).Where("provider = ? AND lower(email) = lower(?)", provider, identity.Email).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrIdentityExists
+		}
+		return tx.Create(&identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// Unlinkはプロバイダーproviderのメールアドレスemailのアカウントの紐付けを削除し、削除した紐付けを返します。
+// 該当する紐付けがなければgorm.ErrRecordNotFoundを返します。
+func (r *OAuthIdentityRepository) Unlink(provider, email string) (*model.OAuthIdentity, error) {
+	var identity model.OAuthIdentity
+	result := r.db.Clauses(clause.Returning{}).
+		Where("provider = ? AND lower(email) = lower(?)", provider, strings.TrimSpace(email)).
+		Delete(&identity)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &identity, nil
+}
+
+// ListはテナントtenantIDのAPIキーに紐付いたアカウントをID順に返します。
+func (r *OAuthIdentityRepository) List(tenantID uint) ([]model.OAuthIdentity, error) {
+	var identities []model.OAuthIdentity
+	err := r.db.Where("tenant_id = ?", tenantID).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+// SignInはプロバイダーproviderでサインインしたアカウント(ユーザーIDsubject、確認済みのメールアドレスemail)の紐付けを返し、
+// 最終サインイン日時をnowに更新します。ユーザーIDで照合し、記録がなければまだサインインしていないメールアドレスの紐付けに
+// ユーザーIDを記録します。紐付いていなければgorm.ErrRecordNotFoundを返します。
+// 紐付け後にほかのユーザーIDのアカウントが同じメールアドレスでサインインしても、そのアカウントは紐付いていないものとして扱います。
+func (r *OAuthIdentityRepository) SignIn(provider, subject, email string, now time.Time) (*model.OAuthIdentity, error) {
+	var identity model.OAuthIdentity
+	result := r.db.Model(&identity).Clauses(clause.Returning{}).
+		Where("provider = ? AND subject = ?", provider, subject).
+		Update("last_sign_in_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return &identity, nil
+	}
+	result = r.db.Model(&identity).Clauses(clause.Returning{}).
+		Where("provider = ? AND lower(email) = lower(?) AND subject IS NULL", provider, email).
+		Updates(map[string]interface{}{"subject": subject, "last_sign_in_at": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &identity, nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/testdb"
+
+	"gorm.io/gorm"
+)
+
+// TestOAuthIdentitySignInはメールアドレスでの紐付けと、初回のサインインで記録したユーザーIDでの照合を確認します。
+func TestOAuthIdentitySignIn(t *testing.T) {
+	db := testdb.Postgres(t)
+	key := model.APIKey{TenantID: model.DefaultTenantID, Name: "dashboard", Prefix: "exc_test", KeyHash: "hash-1", Scopes: []string{model.ScopeRead}}
+	if err := NewTenantRepository(db).CreateAPIKey(&key); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewOAuthIdentityRepository(db)
+	if _, err := repo.Link(model.OAuthProviderGoogle, " Ops@Example.com ", &key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Link(model.OAuthProviderGoogle, "ops@example.com", &key); !errors.Is(err, ErrIdentityExists) {
+		t.Errorf("Link with the same email: err = %v, want ErrIdentityExists", err)
+	}
+
+	now := time.Now()
+	identity, err := repo.SignIn(model.OAuthProviderGoogle, "sub-1", "ops@example.com", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.APIKeyID != key.ID || identity.Subject == nil || *identity.Subject != "sub-1" || identity.LastSignInAt == nil {
+		t.Errorf("first sign-in = %+v, want api key %d with the subject recorded", identity, key.ID)
+	}
+	// 記録したユーザーIDで照合するため、メールアドレスが変わってもサインインできる
+	if _, err := repo.SignIn(model.OAuthProviderGoogle, "sub-1", "renamed@example.com", now); err != nil {
+		t.Errorf("sign-in with a changed email: %v", err)
+	}
+	if _, err := repo.SignIn(model.OAuthProviderGoogle, "sub-2", "ops@example.com", now); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("sign-in of another account with the linked email: err = %v, want ErrRecordNotFound", err)
+	}
+
+	unlinked, err := repo.Unlink(model.OAuthProviderGoogle, "OPS@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unlinked.ID != identity.ID {
+		t.Errorf("Unlink returned %+v, want identity %d", unlinked, identity.ID)
+	}
+	if _, err := repo.SignIn(model.OAuthProviderGoogle, "sub-1", "ops@example.com", now); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("sign-in after unlink: err = %v, want ErrRecordNotFound", err)
+	}
+	if _, err := repo.Unlink(model.OAuthProviderGoogle, "ops@example.com"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("second Unlink: err = %v, want ErrRecordNotFound", err)
+	}
+}
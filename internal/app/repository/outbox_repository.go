@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxRepository はイベントのoutboxを扱うリポジトリです。
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboxRepositoryはdbでoutboxを読み書きするリポジトリを作成します。イベントを書き込みと同じトランザクションで
+// 記録する場合は、トランザクションのtxを渡します。
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *OutboxRepository) WithContext(ctx context.Context) *OutboxRepository {
+	return &OutboxRepository{db: r.db.WithContext(ctx)}
+}
+
+// Enqueueはイベントを記録します。
+func (r *OutboxRepository) Enqueue(event *model.OutboxEvent) error {
+	return r.db.Create(event).Error
+}
+
+// ClaimNextは配信時刻を過ぎた未配信のイベントのうち最も古いものを行をロックして返します。トランザクションの中で呼び出します。
+// 他のプロセスがロック中のイベントは飛ばします。該当するイベントがなければgorm.ErrRecordNotFoundを返します。
+func (r *OutboxRepository) ClaimNext(now time.Time) (*model.OutboxEvent, error) {
+	var event model.OutboxEvent
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND next_attempt_at <= ?", model.OutboxPending, now).
+		Order("next_attempt_at, id").
+		Take(&event).Error
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// MarkPublishedはイベントを配信済みとして記録します。
+func (r *OutboxRepository) MarkPublished(id uint64, at time.Time) error {
+	return r.db.Model(&model.OutboxEvent{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"status": model.OutboxPublished, "published_at": at, "error": ""}).Error
+}
+
+// SaveAttemptは配信に失敗したイベントの試行の結果を保存します。
+func (r *OutboxRepository) SaveAttempt(event *model.OutboxEvent) error {
+	return r.db.Model(event).Select("status", "attempts", "next_attempt_at", "error").Updates(event).Error
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"excavation_service/internal/app/quota"
+
+	"gorm.io/gorm"
+)
+
+// QuotaRepository はリクエスト数の上限の判定に使うカウンターを扱うリポジトリです。quota.Counterを実装します。
+type QuotaRepository struct {
+	db *gorm.DB
+}
+
+func NewQuotaRepository(db *gorm.DB) *QuotaRepository {
+	return &QuotaRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *QuotaRepository) WithContext(ctx context.Context) *QuotaRepository {
+	return &QuotaRepository{db: r.db.WithContext(ctx)}
+}
+
+// Incrementは対象の期間(初日がstart)のリクエスト数を1増やし、増やした後のリクエスト数を返します。
+// 1回のupsertで数えるため、APIサーバーを複数台で動かしても数え漏れません。
+func (r *QuotaRepository) Increment(scope string, id uint, period quota.Period, start time.Time) (int, error) {
+	var requests int
+	err := r.db.Raw(`INSERT INTO quota_counters (scope, scope_id, period, period_start, requests) VALUES (?, ?, ?, ?, 1)
+ON CONFLICT (scope, scope_id, period, period_start) DO UPDATE SET requests = quota_counters.requests + 1
+RETURNING requests`, scope, id, string(period), start.Format("2006-01-02")).Scan(&requests).Error
+	return requests, err
+}
@@ -1,40 +1,396 @@
 package repository
 
 import (
-    "os"
-    "testing"
-    "gorm.io/driver/postgres"
-    "gorm.io/gorm"
-    "excavation_service/internal/app/model"
+	"errors"
+	"os"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/testdb"
+	"excavation_service/internal/app/week"
+
+	"gorm.io/gorm"
 )
 
-func setupTestDB() (*gorm.DB, error) {
-    dsn := os.Getenv("TEST_DATABASE_URL")
-    return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// DBを使うテストはtestdbが用意するPostgreSQL(TEST_DATABASE_URLまたはDockerのコンテナ)で実行する
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
 }
 
 func TestEntityCRUD(t *testing.T) {
-    db, err := setupTestDB()
-    if err != nil {
-        t.Fatalf("DB接続失敗: %v", err)
-    }
-
-    err = db.AutoMigrate(&model.Entity{})
-    if err != nil {
-        t.Fatalf("マイグレーション失敗: %v", err)
-    }
-
-    entity := model.Entity{Name: "テスト温泉", Type: "onsen"}
-    if err := db.Create(&entity).Error; err != nil {
-        t.Fatalf("登録失敗: %v", err)
-    }
-
-    var found model.Entity
-    if err := db.First(&found, "id = ?", entity.ID).Error; err != nil {
-        t.Fatalf("取得失敗: %v", err)
-    }
-
-    if found.Name != entity.Name {
-        t.Fatalf("取得内容不一致: got %v, want %v", found.Name, entity.Name)
-    }
+	db := testdb.Postgres(t)
+
+	entity := model.Entity{Name: "テスト温泉", Type: "onsen"}
+	if err := db.Create(&entity).Error; err != nil {
+		t.Fatalf("登録失敗: %v", err)
+	}
+
+	var found model.Entity
+	if err := db.First(&found, "id = ?", entity.ID).Error; err != nil {
+		t.Fatalf("取得失敗: %v", err)
+	}
+
+	if found.Name != entity.Name {
+		t.Fatalf("取得内容不一致: got %v, want %v", found.Name, entity.Name)
+	}
+}
+
+// TestTrendWeeksはTIMEZONEの週(日本時間の0時)をDATE型の週と比べられることを確認します。
+func TestTrendWeeks(t *testing.T) {
+	db := testdb.Postgres(t)
+	trends := NewTrendRepository(db)
+	topics := NewTopicRepository(db)
+
+	thisWeek := week.Of(time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC))
+	ramen := testdb.Topic(t, db, "ラーメン店", "ラーメン")
+	curry := testdb.Topic(t, db, "カレー店", "カレー")
+	testdb.Trend(t, db, ramen.ID, thisWeek.AddDate(0, 0, -7), 60)
+
+	trend := model.TopicTrend{TopicID: ramen.ID, Week: thisWeek, Score: 70, RunID: "test"}
+	created, err := trends.SaveWeekly(&trend, func(*gorm.DB) error { return nil })
+	if err != nil || !created {
+		t.Fatalf("SaveWeekly = %v, %v; want created", created, err)
+	}
+	// 同じ週に保存し直しても増えない
+	again := model.TopicTrend{TopicID: ramen.ID, Week: thisWeek, Score: 75, RunID: "test"}
+	if created, err := trends.SaveWeekly(&again, func(*gorm.DB) error { return nil }); err != nil || created || again.ID != trend.ID {
+		t.Errorf("SaveWeekly again = %v, %v, id %d; want replaced %d", created, err, again.ID, trend.ID)
+	}
+
+	latest, err := trends.LatestWeek()
+	if err != nil || latest == nil || !week.Same(*latest, thisWeek) {
+		t.Errorf("LatestWeek = %v, %v; want %s", latest, err, thisWeek.Format(week.Layout))
+	}
+	prev, err := trends.LatestBefore(ramen.ID, thisWeek)
+	if err != nil || prev == nil || prev.Score != 60 {
+		t.Errorf("LatestBefore = %+v, %v; want the previous week's trend", prev, err)
+	}
+	due, err := topics.ListDue(thisWeek)
+	if err != nil || len(due) != 1 || due[0] != curry.ID {
+		t.Errorf("ListDue = %v, %v; want [%d]", due, err, curry.ID)
+	}
+}
+
+// TestSaveWeeklyは同じトピック・週を保存し直すと1件のトレンドを置き換え、onCreateは最初の1回だけ呼ぶことを確認します。
+// 並行して保存しても一意制約で失敗せず、作成されるのは1件だけです。
+func TestSaveWeekly(t *testing.T) {
+	db := testdb.Postgres(t)
+	trends := NewTrendRepository(db)
+	thisWeek := week.Of(time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC))
+	countWeek := func(topicID uint) int64 {
+		var n int64
+		if err := db.Model(&model.TopicTrend{}).Where("topic_id = ? AND week = ?", topicID, thisWeek).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	ramen := testdb.Topic(t, db, "ラーメン店", "ラーメン")
+	var onCreate int
+	save := func(score float64) (model.TopicTrend, bool) {
+		trend := model.TopicTrend{TopicID: ramen.ID, Week: thisWeek, Score: score, RunID: "test"}
+		created, err := trends.SaveWeekly(&trend, func(*gorm.DB) error { onCreate++; return nil })
+		if err != nil {
+			t.Fatalf("SaveWeekly() error = %v", err)
+		}
+		return trend, created
+	}
+	first, created := save(70)
+	if !created {
+		t.Error("first SaveWeekly() created = false, want true")
+	}
+	second, created := save(75)
+	if created || second.ID != first.ID || !second.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("second SaveWeekly() = created %v, id %d, created_at %v; want the replaced trend %d created at %v",
+			created, second.ID, second.CreatedAt, first.ID, first.CreatedAt)
+	}
+	if n := countWeek(ramen.ID); n != 1 || onCreate != 1 {
+		t.Errorf("%d trends, onCreate called %d times; want 1 and 1", n, onCreate)
+	}
+	var saved model.TopicTrend
+	if err := db.First(&saved, first.ID).Error; err != nil || saved.Score != 75 {
+		t.Errorf("saved trend = %+v, %v; want score 75", saved, err)
+	}
+
+	curry := testdb.Topic(t, db, "カレー店", "カレー")
+	const runs = 8
+	results := make(chan error, runs)
+	var createdCount atomic.Int32
+	for i := 0; i < runs; i++ {
+		go func() {
+			trend := model.TopicTrend{TopicID: curry.ID, Week: thisWeek, Score: float64(i), RunID: "test"}
+			created, err := trends.SaveWeekly(&trend, func(*gorm.DB) error { return nil })
+			if created {
+				createdCount.Add(1)
+			}
+			results <- err
+		}()
+	}
+	for i := 0; i < runs; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("concurrent SaveWeekly() error = %v", err)
+		}
+	}
+	if n := countWeek(curry.ID); n != 1 || createdCount.Load() != 1 {
+		t.Errorf("%d trends, %d created after concurrent saves; want 1 and 1", n, createdCount.Load())
+	}
+}
+
+// TestEntityRepositoryはエンティティの名前と種別の重複と、トピックのあるエンティティの削除を拒否することを確認します。
+func TestEntityRepository(t *testing.T) {
+	db := testdb.Postgres(t)
+	repo := NewTopicRepository(db).ForTenant(model.DefaultTenantID)
+
+	onsen := model.Entity{Name: "箱根", Type: model.EntityTypeOnsen}
+	if err := repo.CreateEntity(&onsen); err != nil {
+		t.Fatalf("CreateEntity() error = %v", err)
+	}
+	if err := repo.CreateEntity(&model.Entity{Name: "箱根", Type: model.EntityTypeOnsen}); !errors.Is(err, ErrEntityExists) {
+		t.Errorf("CreateEntity() duplicate error = %v, want ErrEntityExists", err)
+	}
+	// 種別が違えば同じ名前でも登録できる
+	brand := model.Entity{Name: "箱根", Type: model.EntityTypeBrand}
+	if err := repo.CreateEntity(&brand); err != nil {
+		t.Fatalf("CreateEntity() error = %v", err)
+	}
+	brand.Type = model.EntityTypeOnsen
+	if err := repo.UpdateEntity(&brand); !errors.Is(err, ErrEntityExists) {
+		t.Errorf("UpdateEntity() duplicate error = %v, want ErrEntityExists", err)
+	}
+
+	curry := testdb.Topic(t, db, "カレー店", "カレー")
+	if err := repo.DeleteEntity(curry.EntityID); !errors.Is(err, ErrEntityHasTopics) {
+		t.Errorf("DeleteEntity() with topics error = %v, want ErrEntityHasTopics", err)
+	}
+	if err := repo.DeleteEntity(onsen.ID); err != nil {
+		t.Errorf("DeleteEntity() error = %v", err)
+	}
+	if _, err := repo.GetEntity(onsen.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetEntity() after delete error = %v, want ErrRecordNotFound", err)
+	}
+	if err := NewTopicRepository(db).ForTenant(2).DeleteEntity(brand.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("DeleteEntity() of another tenant error = %v, want ErrRecordNotFound", err)
+	}
+}
+
+// TestListEntitiesPageはエンティティの一覧を並び順を変えても、cursorで続きのページを重複・欠落なく読めることを確認します。
+func TestListEntitiesPage(t *testing.T) {
+	db := testdb.Postgres(t)
+	repo := NewTopicRepository(db).ForTenant(model.DefaultTenantID)
+	for _, e := range []model.Entity{
+		{Name: "箱根", Type: model.EntityTypeOnsen},
+		{Name: "草津", Type: model.EntityTypeOnsen},
+		{Name: "カレー店", Type: model.EntityTypeRestaurant},
+		{Name: "ラーメン店", Type: model.EntityTypeRestaurant},
+		{Name: "別府", Type: model.EntityTypeOnsen},
+	} {
+		if err := repo.CreateEntity(&e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		sort []SortField
+		want []string
+	}{
+		{nil, []string{"箱根", "草津", "カレー店", "ラーメン店", "別府"}},
+		// 同じ種別はIDを最後の項目と同じ向き(降順)で並べる
+		{[]SortField{{Name: "type", Desc: true}}, []string{"ラーメン店", "カレー店", "別府", "草津", "箱根"}},
+	} {
+		var names []string
+		var after Cursor
+		for page := 0; ; page++ {
+			entities, err := repo.ListEntities(EntityFilter{Sort: tt.sort}, after, 2)
+			if err != nil {
+				t.Fatalf("ListEntities: %v", err)
+			}
+			if len(entities) == 0 {
+				break
+			}
+			if page > 3 {
+				t.Fatalf("ListEntities did not stop; read %v", names)
+			}
+			for _, e := range entities {
+				names = append(names, e.Name)
+			}
+			if after, err = ParseCursor(EntityCursor(entities[len(entities)-1], tt.sort).String()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("sort %v: names = %v; want %v", tt.sort, names, tt.want)
+		}
+	}
+	if _, err := repo.ListEntities(EntityFilter{}, Cursor{ID: 1, Values: []string{"onsen"}}, 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("ListEntities() with a cursor of another sort error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+// TestRankingは週のランキングがその週のスコア順で、無効化したトピックを含まないことを確認します。
+func TestRanking(t *testing.T) {
+	db := testdb.Postgres(t)
+	trends := NewTrendRepository(db)
+	w := week.Of(time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC))
+
+	ramen := testdb.Topic(t, db, "ラーメン店", "ラーメン")
+	curry := testdb.Topic(t, db, "カレー店", "カレー")
+	sushi := testdb.Topic(t, db, "寿司店", "寿司")
+	testdb.Trend(t, db, ramen.ID, w, 40)
+	testdb.Trend(t, db, curry.ID, w, 70)
+	testdb.Trend(t, db, curry.ID, w.AddDate(0, 0, -7), 95)
+	testdb.Trend(t, db, sushi.ID, w, 99)
+	if err := db.Model(&model.EntityTopic{}).Where("id = ?", sushi.ID).Update("disabled_at", time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ranking, err := trends.Ranking(w, 10)
+	if err != nil {
+		t.Fatalf("Ranking: %v", err)
+	}
+	if len(ranking) != 2 {
+		t.Fatalf("Ranking = %+v; want curry and ramen", ranking)
+	}
+	if r := ranking[0]; r.Rank != 1 || r.TopicID != curry.ID || r.EntityName != "カレー店" || r.Score != 70 {
+		t.Errorf("ranking[0] = %+v; want curry ranked 1st with 70", r)
+	}
+	if r := ranking[1]; r.Rank != 2 || r.TopicID != ramen.ID || r.Score != 40 {
+		t.Errorf("ranking[1] = %+v; want ramen ranked 2nd with 40", r)
+	}
+}
+
+// TestListPageはトレンドの一覧をsortの順に、cursorで続きのページを重複・欠落なく読めることを確認します。
+func TestListPage(t *testing.T) {
+	db := testdb.Postgres(t)
+	trends := NewTrendRepository(db)
+	w := week.Of(time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC))
+
+	ramen := testdb.Topic(t, db, "ラーメン店", "ラーメン")
+	curry := testdb.Topic(t, db, "カレー店", "カレー")
+	testdb.Trend(t, db, ramen.ID, w.AddDate(0, 0, -7), 10)
+	testdb.Trend(t, db, curry.ID, w, 20)
+	testdb.Trend(t, db, ramen.ID, w, 30)
+	testdb.Trend(t, db, curry.ID, w.AddDate(0, 0, -14), 30)
+
+	tests := []struct {
+		sort string
+		min  float64
+		want []float64
+	}{
+		// 既定は新しい週から順で、同じ週はIDの降順(後に保存したものが先)
+		{"", 0, []float64{30, 20, 10, 30}},
+		// スコアの降順で、同じスコアは週の昇順
+		{"-score,week", 0, []float64{30, 30, 20, 10}},
+		{"score", 15, []float64{20, 30, 30}},
+	}
+	for _, tt := range tests {
+		sort, err := ParseSort(tt.sort, TrendSortFields)
+		if err != nil {
+			t.Fatalf("ParseSort(%q): %v", tt.sort, err)
+		}
+		f := TrendExportFilter{MinScore: &tt.min}
+		var scores []float64
+		var after Cursor
+		for page := 0; ; page++ {
+			rows, err := trends.ListPage(f, sort, after, 2)
+			if err != nil {
+				t.Fatalf("ListPage(%q): %v", tt.sort, err)
+			}
+			if len(rows) == 0 {
+				break
+			}
+			if page > 2 {
+				t.Fatalf("ListPage(%q) did not stop; read %v", tt.sort, scores)
+			}
+			for _, row := range rows {
+				scores = append(scores, row.Score)
+			}
+			// 応答の文字列を経由しても同じ位置から読めること
+			if after, err = ParseCursor(rows[len(rows)-1].Cursor(sort).String()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !slices.Equal(scores, tt.want) {
+			t.Errorf("ListPage(%q) scores = %v; want %v", tt.sort, scores, tt.want)
+		}
+	}
+	// 並び順の項目の数と合わないcursor
+	if _, err := trends.ListPage(TrendExportFilter{}, nil, Cursor{ID: 1, Values: []string{"1", "2"}}, 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("ListPage with a mismatched cursor error = %v; want ErrInvalidCursor", err)
+	}
+}
+
+// TestHistoryはトピックのトレンドの推移を週ごと・月ごとの平均で返すことを確認します。
+func TestHistory(t *testing.T) {
+	db := testdb.Postgres(t)
+	trends := NewTrendRepository(db)
+
+	ramen := testdb.Topic(t, db, "ラーメン店", "ラーメン")
+	curry := testdb.Topic(t, db, "カレー店", "カレー")
+	may := time.Date(2024, 5, 27, 0, 0, 0, 0, time.UTC) // 5月の最後の月曜日
+	testdb.Trend(t, db, ramen.ID, may.AddDate(0, 0, -7), 40)
+	testdb.Trend(t, db, ramen.ID, may, 60)
+	testdb.Trend(t, db, ramen.ID, may.AddDate(0, 0, 7), 80)
+	testdb.Trend(t, db, curry.ID, may, 99)
+
+	weekly, err := trends.History(ramen.ID, IntervalWeek, time.Time{}, time.Time{})
+	if err != nil || len(weekly) != 3 || weekly[0].Score != 40 || weekly[2].Score != 80 || weekly[2].Weeks != 1 {
+		t.Errorf("History(week) = %+v, %v; want 40, 60, 80 in order", weekly, err)
+	}
+	monthly, err := trends.History(ramen.ID, IntervalMonth, time.Time{}, time.Time{})
+	if err != nil || len(monthly) != 2 {
+		t.Fatalf("History(month) = %+v, %v; want May and June", monthly, err)
+	}
+	if p := monthly[0]; !p.Period.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) || p.Score != 50 || p.Weeks != 2 {
+		t.Errorf("History(month)[0] = %+v; want May averaging 50 over 2 weeks", p)
+	}
+	since, err := trends.History(ramen.ID, IntervalWeek, may, time.Time{})
+	if err != nil || len(since) != 2 {
+		t.Errorf("History(since) = %+v, %v; want 2 weeks", since, err)
+	}
+}
+
+// TestSearchNamesは店舗名の部分一致と表記の近い店舗名で店舗を探し、店舗が言及されていたトレンドを返すことを確認します。
+func TestSearchNames(t *testing.T) {
+	db := testdb.Postgres(t)
+	stores := NewStoreRepository(db)
+	w := week.Of(time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC))
+
+	ramen := testdb.Topic(t, db, "ラーメン店", "ラーメン")
+	trend := testdb.Trend(t, db, ramen.ID, w, 70)
+	mentioned := model.Store{URL: "https://example.com/ichiban", Name: "麺屋 一番星"}
+	unmentioned := model.Store{URL: "https://example.com/niban", Name: "麺屋 二番星"}
+	for _, s := range []*model.Store{&mentioned, &unmentioned} {
+		if err := stores.Upsert(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, page := range []string{"https://example.com/a", "https://example.com/b"} {
+		if err := stores.RecordMention(&model.StoreMention{StoreID: mentioned.ID, TopicID: ramen.ID, Week: w, SourceURL: page, SourceType: "matome"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 言及のない店舗は対象にしない
+	matches, err := stores.SearchNames(0, "一番", 10)
+	if err != nil || len(matches) != 1 || matches[0].ID != mentioned.ID {
+		t.Fatalf("SearchNames(一番) = %+v, %v; want the mentioned store", matches, err)
+	}
+	// 表記の揺れ(空白の有無)にも一致する
+	if matches, err := stores.SearchNames(0, "麺屋一番星", 10); err != nil || len(matches) != 1 {
+		t.Errorf("SearchNames(麺屋一番星) = %+v, %v; want a similar name", matches, err)
+	}
+	if matches, err := stores.SearchNames(999, "一番", 10); err != nil || len(matches) != 0 {
+		t.Errorf("SearchNames(other tenant) = %+v, %v; want none", matches, err)
+	}
+
+	appearances, err := stores.Appearances(0, []uint{mentioned.ID})
+	if err != nil || len(appearances) != 1 {
+		t.Fatalf("Appearances = %+v, %v; want 1", appearances, err)
+	}
+	if a := appearances[0]; a.TrendID != trend.ID || a.Score != 70 || a.Mentions != 2 || a.Topic != "ラーメン" {
+		t.Errorf("Appearances[0] = %+v; want the trend with 2 mentions", a)
+	}
 }
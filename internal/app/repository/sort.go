@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidSort はParseSortに渡した並び順に、並べ替えられない項目や重複した項目があることを表します。
+var ErrInvalidSort = errors.New("invalid sort")
+
+// SortField は一覧の並び順の項目です。
+type SortField struct {
+	Name string
+	Desc bool
+}
+
+// ParseSortはクエリのsort(カンマ区切りの項目名。-を付けた項目は降順)を読みます。例: -score,week
+// 項目名はallowedに含まれるもののみ受け付けるため、読んだ項目名はそのまま列名の対応表の引きに使えます。空文字列はnil(一覧の既定の順)です。
+func ParseSort(s string, allowed []string) ([]SortField, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var fields []SortField
+	for _, part := range strings.Split(s, ",") {
+		f := SortField{Name: strings.TrimSpace(part)}
+		if name, ok := strings.CutPrefix(f.Name, "-"); ok {
+			f.Name, f.Desc = name, true
+		}
+		if !slices.Contains(allowed, f.Name) || slices.ContainsFunc(fields, func(g SortField) bool { return g.Name == f.Name }) {
+			return nil, ErrInvalidSort
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// sortKey はキーセットページネーションで比べる並び順の列です。castはcursorの値(文字列)を列と比べる型に変換するSQLの型です。
+type sortKey struct {
+	column string
+	cast   string
+	desc   bool
+}
+
+// orderByはkeysとIDの列idColumnのORDER BYを返します。同じ値の行の順を決めるため、IDは最後の項目と同じ向きで並べます。
+func orderBy(keys []sortKey, idColumn string) string {
+	parts := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		parts = append(parts, k.column+direction(k.desc))
+	}
+	return strings.Join(append(parts, idColumn+direction(keys[len(keys)-1].desc)), ", ")
+}
+
+// afterKeysはorderByの順でcursorの行(並び順の値values、IDがid)より後ろの行に絞り込む条件と引数を返します。
+// 項目ごとに昇順・降順が混ざるため、行の値の比較ではなく「前の項目が等しく、この項目が後ろ」の条件のORにします。
+func afterKeys(keys []sortKey, values []string, idColumn string, id uint) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for i := 0; i <= len(keys); i++ {
+		var and []string
+		for j := 0; j < i; j++ {
+			and = append(and, keys[j].column+" = CAST(? AS "+keys[j].cast+")")
+			args = append(args, values[j])
+		}
+		if i < len(keys) {
+			and = append(and, keys[i].column+after(keys[i].desc)+"CAST(? AS "+keys[i].cast+")")
+			args = append(args, values[i])
+		} else {
+			and = append(and, idColumn+after(keys[len(keys)-1].desc)+"?")
+			args = append(args, id)
+		}
+		conds = append(conds, "("+strings.Join(and, " AND ")+")")
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+func direction(desc bool) string {
+	if desc {
+		return " DESC"
+	}
+	return ""
+}
+
+// afterは並び順がdescの列で後ろの行を選ぶ比較演算子です。
+func after(desc bool) string {
+	if desc {
+		return " < "
+	}
+	return " > "
+}
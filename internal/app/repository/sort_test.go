@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	got, err := ParseSort("-score, week", TrendSortFields)
+	want := []SortField{{Name: "score", Desc: true}, {Name: "week"}}
+	if err != nil || !slices.Equal(got, want) {
+		t.Errorf("ParseSort = %+v, %v; want %+v", got, err, want)
+	}
+	if got, err := ParseSort("", TrendSortFields); err != nil || got != nil {
+		t.Errorf("ParseSort(\"\") = %+v, %v; want nil", got, err)
+	}
+	// 許可していない項目・SQLを含むもの・重複・空の項目
+	for _, s := range []string{"topic", "score;DROP TABLE topic_trends", "score,-score", "score,", "--score"} {
+		if _, err := ParseSort(s, TrendSortFields); !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("ParseSort(%q) error = %v; want ErrInvalidSort", s, err)
+		}
+	}
+}
+
+func TestAfterKeys(t *testing.T) {
+	keys := []sortKey{{column: "t.score", cast: "double precision", desc: true}, {column: "t.week", cast: "date"}}
+	if got, want := orderBy(keys, "t.id"), "t.score DESC, t.week, t.id"; got != want {
+		t.Errorf("orderBy = %q; want %q", got, want)
+	}
+	cond, args := afterKeys(keys, []string{"70", "2024-06-03"}, "t.id", 5)
+	want := "((t.score < CAST(? AS double precision)) OR " +
+		"(t.score = CAST(? AS double precision) AND t.week > CAST(? AS date)) OR " +
+		"(t.score = CAST(? AS double precision) AND t.week = CAST(? AS date) AND t.id > ?))"
+	if cond != want {
+		t.Errorf("afterKeys = %q; want %q", cond, want)
+	}
+	if wantArgs := []interface{}{"70", "70", "2024-06-03", "70", "2024-06-03", uint(5)}; !slices.Equal(args, wantArgs) {
+		t.Errorf("afterKeys args = %v; want %v", args, wantArgs)
+	}
+}
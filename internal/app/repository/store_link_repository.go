@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StoreLinkRepository は表記の揺れで重複した店舗の組と、その確認待ちのキューを扱うリポジトリです。
+type StoreLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewStoreLinkRepository(db *gorm.DB) *StoreLinkRepository {
+	return &StoreLinkRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *StoreLinkRepository) WithContext(ctx context.Context) *StoreLinkRepository {
+	return &StoreLinkRepository{db: r.db.WithContext(ctx)}
+}
+
+// StoreName は店舗の照合に使う店舗のIDと名前です。
+type StoreName struct {
+	ID   uint
+	Name string
+}
+
+// ListStoreNamesはすべての店舗のIDと名前をID順に返します。
+func (r *StoreLinkRepository) ListStoreNames() ([]StoreName, error) {
+	var names []StoreName
+	err := r.db.Model(&model.Store{}).Select("id, name").Order("id").Scan(&names).Error
+	return names, err
+}
+
+// Proposeは店舗の組を記録し、新しく記録した場合にtrueを返します。
+// 同じ組が既にある場合は、人の確認の結果を上書きしないよう何もしません。
+func (r *StoreLinkRepository) Propose(link *model.StoreLink) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(link)
+	return result.RowsAffected > 0, result.Error
+}
+
+// StoreLinkItem は店舗名とURLを付けた店舗の組です。
+type StoreLinkItem struct {
+	ID            uint
+	StoreID       uint
+	StoreName     string
+	StoreURL      string
+	CanonicalID   uint
+	CanonicalName string
+	CanonicalURL  string
+	Confidence    float64
+	Method        string
+	Status        string
+	CreatedAt     time.Time
+}
+
+// ListPendingは確認待ちの店舗の組を確からしさの高い順に最大limit件返します。
+func (r *StoreLinkRepository) ListPending(limit int) ([]StoreLinkItem, error) {
+	var items []StoreLinkItem
+	err := r.db.Table("store_links AS l").
+		Select("l.id, l.store_id, s.name AS store_name, s.url AS store_url, "+
+			"l.canonical_id, c.name AS canonical_name, c.url AS canonical_url, l.confidence, l.method, l.status, l.created_at").
+		Joins("JOIN stores AS s ON s.id = l.store_id").
+		Joins("JOIN stores AS c ON c.id = l.canonical_id").
+		Where("l.status = ?", model.StoreLinkPending).
+		Order("l.confidence DESC, l.id").
+		Limit(limit).
+		Scan(&items).Error
+	return items, err
+}
+
+// Resolveは確認待ちの店舗の組をstatus(linkedかrejected)にし、確認した操作者reviewerを記録します。
+// 確認待ちの組がなければgorm.ErrRecordNotFoundを返します。
+func (r *StoreLinkRepository) Resolve(id uint, status, reviewer string, at time.Time) error {
+	result := r.db.Model(&model.StoreLink{}).
+		Where("id = ? AND status = ?", id, model.StoreLinkPending).
+		Updates(map[string]interface{}{"status": status, "reviewed_by": reviewer, "reviewed_at": at})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
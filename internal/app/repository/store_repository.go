@@ -0,0 +1,446 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StoreRepository は店舗と店舗への言及を扱うリポジトリです。
+type StoreRepository struct {
+	db *gorm.DB
+}
+
+func NewStoreRepository(db *gorm.DB) *StoreRepository {
+	return &StoreRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *StoreRepository) WithContext(ctx context.Context) *StoreRepository {
+	return &StoreRepository{db: r.db.WithContext(ctx)}
+}
+
+// StoreMentionCount は店舗ごとの言及元ページ数の集計結果です。
+type StoreMentionCount struct {
+	StoreID  uint   `json:"store_id"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Mentions int64  `json:"mentions"`
+}
+
+// UpsertはURLをキーに店舗を登録し、既に存在する場合は店舗名を更新します。
+// store.IDには登録・更新後のIDが設定されます。
+func (r *StoreRepository) Upsert(store *model.Store) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "url"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "updated_at"}),
+	}).Create(store).Error
+}
+
+// RecordMentionは店舗への言及を記録します。同じ週・トピック・ページの言及が既にあれば何もしません。
+func (r *StoreRepository) RecordMention(mention *model.StoreMention) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(mention).Error
+}
+
+// CountMentionsは指定トピック・週の言及元ページ数の合計を返します。
+func (r *StoreRepository) CountMentions(topicID uint, week time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.StoreMention{}).
+		Where("topic_id = ? AND week = ?", topicID, week).
+		Count(&count).Error
+	return count, err
+}
+
+// MostMentionedは指定トピック・週で言及元ページ数の多い店舗を上位limit件返します。
+func (r *StoreRepository) MostMentioned(topicID uint, week time.Time, limit int) ([]StoreMentionCount, error) {
+	var results []StoreMentionCount
+	err := r.db.Table("store_mentions AS m").
+		Select("s.id AS store_id, s.name, s.url, COUNT(DISTINCT m.source_url) AS mentions").
+		Joins("JOIN stores AS s ON s.id = m.store_id").
+		Where("m.topic_id = ? AND m.week = ?", topicID, week).
+		Group("s.id, s.name, s.url").
+		Order("mentions DESC, s.id").
+		Limit(limit).
+		Scan(&results).Error
+	return results, err
+}
+
+// MentionItem は店舗名とURLを付けた店舗への言及です。
+type MentionItem struct {
+	ID         uint      `json:"id"`
+	StoreID    uint      `json:"store_id"`
+	StoreName  string    `json:"store_name"`
+	StoreURL   string    `json:"store_url"`
+	SourceURL  string    `json:"source_url"`
+	SourceType string    `json:"source_type"`
+	RunID      string    `json:"run_id"` // この言及を最初に記録した実行
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ListMentionsは指定トピック・週の店舗への言及を記録した順に返します。
+func (r *StoreRepository) ListMentions(topicID uint, week time.Time) ([]MentionItem, error) {
+	var items []MentionItem
+	err := r.db.Table("store_mentions AS m").
+		Select("m.id, m.store_id, s.name AS store_name, s.url AS store_url, m.source_url, m.source_type, m.run_id, m.created_at").
+		Joins("JOIN stores AS s ON s.id = m.store_id").
+		Where("m.topic_id = ? AND m.week = ?", topicID, week).
+		Order("m.id").
+		Scan(&items).Error
+	return items, err
+}
+
+// NewStore は新しく見つかった店舗と、その店舗に言及していたトピックです。
+type NewStore struct {
+	StoreID uint
+	Name    string
+	URL     string
+	TopicID uint
+	Topic   string
+}
+
+// NewStoresByRunは実行runIDで言及を記録した店舗のうち、since以降に登録されたものを返します。
+// 複数のトピックで言及された店舗はトピックごとに返します。
+func (r *StoreRepository) NewStoresByRun(runID string, since time.Time) ([]NewStore, error) {
+	var stores []NewStore
+	err := r.newStores().
+		Where("m.run_id = ? AND s.created_at >= ?", runID, since).
+		Scan(&stores).Error
+	return stores, err
+}
+
+// NewStoresInWeekはweek週(月曜日から7日間)に登録された店舗を、言及していたトピックごとに返します。
+func (r *StoreRepository) NewStoresInWeek(week time.Time) ([]NewStore, error) {
+	var stores []NewStore
+	err := r.newStores().
+		Where("m.week = ? AND s.created_at >= ? AND s.created_at < ?", week, week, week.AddDate(0, 0, 7)).
+		Scan(&stores).Error
+	return stores, err
+}
+
+// FirstSeenByRunは実行runIDでトピックtopicIDへの言及を記録した店舗のうち、それ以前の実行ではそのトピックで言及されていなかったものを返します。
+// 他のトピックで既に言及されていた店舗も含みます。
+func (r *StoreRepository) FirstSeenByRun(topicID uint, runID string) ([]NewStore, error) {
+	var stores []NewStore
+	err := r.newStores().
+		Where("m.topic_id = ? AND m.run_id = ?", topicID, runID).
+		Where("NOT EXISTS (SELECT 1 FROM store_mentions AS p WHERE p.store_id = m.store_id AND p.topic_id = m.topic_id AND p.run_id IS DISTINCT FROM m.run_id)").
+		Scan(&stores).Error
+	return stores, err
+}
+
+func (r *StoreRepository) newStores() *gorm.DB {
+	return r.db.Table("stores AS s").
+		Select("DISTINCT s.id AS store_id, s.name, s.url, m.topic_id, et.topic").
+		Joins("JOIN store_mentions AS m ON m.store_id = s.id").
+		Joins("JOIN entity_topics AS et ON et.id = m.topic_id").
+		Order("s.id, m.topic_id")
+}
+
+// ListUngeocodedは住所の変換をまだ試みていない店舗をID順に最大limit件返します。
+func (r *StoreRepository) ListUngeocoded(limit int) ([]model.Store, error) {
+	var stores []model.Store
+	err := r.db.Where("geocoded_at IS NULL").Order("id").Limit(limit).Find(&stores).Error
+	return stores, err
+}
+
+// SetAddressは店舗ページから取得した住所を保存します。
+func (r *StoreRepository) SetAddress(id uint, address string) error {
+	return r.db.Model(&model.Store{}).Where("id = ?", id).Update("address", address).Error
+}
+
+// SetImageURLは店舗ページから取得した画像のURLを保存します。
+func (r *StoreRepository) SetImageURL(id uint, imageURL string) error {
+	return r.db.Model(&model.Store{}).Where("id = ?", id).Update("image_url", imageURL).Error
+}
+
+// Quarantineは検証に失敗した店舗の情報を隔離のテーブルに記録します。
+func (r *StoreRepository) Quarantine(q *model.QuarantinedStore) error {
+	return r.db.Create(q).Error
+}
+
+// ListQuarantinedは隔離した店舗の情報を新しい順に最大limit件返します。stageが空でなければその処理で隔離したもののみ返します。
+func (r *StoreRepository) ListQuarantined(stage string, limit int) ([]model.QuarantinedStore, error) {
+	var items []model.QuarantinedStore
+	q := r.db.Order("created_at DESC, id DESC").Limit(limit)
+	if stage != "" {
+		q = q.Where("stage = ?", stage)
+	}
+	err := q.Find(&items).Error
+	return items, err
+}
+
+// SetLocationは住所の変換結果を保存します。住所が見つからなかった場合はlat・lonをnilにします。
+func (r *StoreRepository) SetLocation(id uint, lat, lon *float64, geocodedAt time.Time) error {
+	return r.db.Model(&model.Store{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"latitude": lat, "longitude": lon, "geocoded_at": geocodedAt}).Error
+}
+
+// 近くの店舗の並び順で、中心からの近さとトレンドのスコアを混ぜる重み
+const (
+	nearbyDistanceWeight = 0.5
+	nearbyScoreWeight    = 0.5
+)
+
+// 近くの店舗(Nearby)の並び順
+const (
+	NearbyOrderRank     = "rank"     // 近さとスコアを混ぜたRankの高い順
+	NearbyOrderScore    = "score"    // 最新の週のスコアの高い順。同じスコアは近い順
+	NearbyOrderDistance = "distance" // 近い順
+)
+
+// NearbyOrders はNearbyで指定できる並び順です。
+var NearbyOrders = []string{NearbyOrderRank, NearbyOrderScore, NearbyOrderDistance}
+
+// nearbyOrderBy はNearbyの並び順ごとのORDER BYです。
+var nearbyOrderBy = map[string]string{
+	NearbyOrderRank:     "rank DESC, n.id",
+	NearbyOrderScore:    "n.score DESC, n.distance, n.id",
+	NearbyOrderDistance: "n.distance, n.id",
+}
+
+// NearbyStore はある地点の近くでトレンドになっている店舗です。
+// Scoreは店舗に言及していたトピックのその週のトレンドの最高スコアで、Topicはそのトピックです。
+// Rankは中心からの近さ(半径の端で0、中心で1)とスコア(0〜1に正規化)を混ぜた並び順の値です。
+type NearbyStore struct {
+	ID        uint    `json:"id"`
+	Name      string  `json:"name"`
+	URL       string  `json:"url"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Distance  float64 `json:"distance_m"`
+	Topic     string  `json:"topic"`
+	Score     float64 `json:"score"`
+	Mentions  int64   `json:"mentions"`
+	Rank      float64 `json:"rank"`
+}
+
+// Nearbyは(lat, lon)から半径radius(メートル)以内にあり、week週にトレンドのあるトピックで言及された店舗を、
+// orderの順(NearbyOrders*のいずれか。空の場合は近さとスコアを混ぜたRankの高い順)に最大limit件返します。無効化されたトピックの言及は数えません。
+// tenantIDが0でなければそのテナントのトピックのみ対象です。
+// 同じ週にトピックのトレンドが複数ある場合は最後に保存されたものを使います。earthdistance拡張が必要です。
+func (r *StoreRepository) Nearby(tenantID uint, lat, lon, radius float64, week time.Time, order string, limit int) ([]NearbyStore, error) {
+	orderBy, ok := nearbyOrderBy[order]
+	if !ok {
+		orderBy = nearbyOrderBy[NearbyOrderRank]
+	}
+	trends := r.db.Table("topic_trends AS t").
+		Select("DISTINCT ON (t.topic_id) t.topic_id, t.score").
+		Where("t.week = ?", week).
+		Order("t.topic_id, t.id DESC")
+	// earth_boxで索引を使って候補を絞り、earth_distanceで半径の外の店舗を除く
+	candidates := r.db.Table("stores AS s").
+		Select("s.id, s.name, s.url, s.latitude, s.longitude, "+
+			"earth_distance(ll_to_earth(?, ?), ll_to_earth(s.latitude, s.longitude)) AS distance, "+
+			"(array_agg(et.topic ORDER BY lt.score DESC))[1] AS topic, MAX(lt.score) AS score, COUNT(DISTINCT m.source_url) AS mentions", lat, lon).
+		Joins("JOIN store_mentions AS m ON m.store_id = s.id AND m.week = ?", week).
+		Joins("JOIN (?) AS lt ON lt.topic_id = m.topic_id", trends).
+		Joins("JOIN entity_topics AS et ON et.id = m.topic_id AND et.disabled_at IS NULL").
+		Scopes(tenantColumn("et.tenant_id", tenantID)).
+		Where("s.latitude IS NOT NULL AND s.longitude IS NOT NULL").
+		Where("earth_box(ll_to_earth(?, ?), ?) @> ll_to_earth(s.latitude, s.longitude)", lat, lon, radius).
+		Group("s.id")
+
+	var stores []NearbyStore
+	err := r.db.Table("(?) AS n", candidates).
+		Select("n.*, ? * (1 - n.distance / ?) + ? * n.score / 100 AS rank", nearbyDistanceWeight, radius, nearbyScoreWeight).
+		Where("n.distance <= ?", radius).
+		Order(orderBy).
+		Limit(limit).
+		Scan(&stores).Error
+	return stores, err
+}
+
+// AreaStore はあるエリアでトレンドになっている店舗です。
+// Scoreは店舗に言及していたエリアのトピックのその週のトレンドの最高スコアで、Topicはそのトピックです。
+type AreaStore struct {
+	ID       uint
+	Name     string
+	URL      string
+	ImageURL string
+	Topic    string
+	Score    float64
+	Mentions int64
+}
+
+// TopInAreaはトピック名にareaを含むトピックのうちweek週にトレンドのあるもので言及された店舗を、
+// スコアと言及元ページ数の多い順に最大limit件返します。tenantIDが0でなければそのテナントのトピックのみ対象です。
+// 無効化されたトピックの言及は数えず、同じ週にトピックのトレンドが複数ある場合は最後に保存されたものを使います。
+func (r *StoreRepository) TopInArea(tenantID uint, area string, week time.Time, limit int) ([]AreaStore, error) {
+	trends := r.db.Table("topic_trends AS t").
+		Select("DISTINCT ON (t.topic_id) t.topic_id, t.score").
+		Where("t.week = ?", week).
+		Order("t.topic_id, t.id DESC")
+	var stores []AreaStore
+	err := r.db.Table("stores AS s").
+		Select("s.id, s.name, s.url, s.image_url, (array_agg(et.topic ORDER BY lt.score DESC))[1] AS topic, "+
+			"MAX(lt.score) AS score, COUNT(DISTINCT m.source_url) AS mentions").
+		Joins("JOIN store_mentions AS m ON m.store_id = s.id AND m.week = ?", week).
+		Joins("JOIN (?) AS lt ON lt.topic_id = m.topic_id", trends).
+		Joins("JOIN entity_topics AS et ON et.id = m.topic_id AND et.disabled_at IS NULL").
+		Scopes(tenantColumn("et.tenant_id", tenantID)).
+		Where("et.topic ILIKE ?", "%"+likeEscaper.Replace(area)+"%").
+		Group("s.id").
+		Order("score DESC, mentions DESC, s.id").
+		Limit(limit).
+		Scan(&stores).Error
+	return stores, err
+}
+
+// StoreFeature は地図に載せる、緯度・経度を変換済みの店舗です。
+// Scoreは店舗に言及していたトピックの最新のトレンドの最高スコアで、トレンドがなければnilです。
+type StoreFeature struct {
+	ID                uint
+	Name              string
+	URL               string
+	Address           string
+	Latitude          float64
+	Longitude         float64
+	Topics            pq.StringArray `gorm:"type:text[]"`
+	Score             *float64
+	Mentions          int64
+	LastMentionedWeek time.Time
+}
+
+// Featuresは緯度・経度を変換済みの店舗をID順に返します。areaが空でなければトピック名にareaを含むトピック、
+// topicIDが0でなければそのトピックで言及された店舗のみ返し、Topics・Score・Mentionsもそのトピックのみから求めます。
+// 無効化されたトピックの言及は含めません。tenantIDが0でなければそのテナントのトピックのみ対象です。
+func (r *StoreRepository) Features(tenantID uint, area string, topicID uint) ([]StoreFeature, error) {
+	latest := r.db.Table("topic_trends AS t").
+		Select("DISTINCT ON (t.topic_id) t.topic_id, t.score").
+		Order("t.topic_id, t.week DESC, t.id DESC")
+	q := r.db.Table("stores AS s").
+		Select("s.id, s.name, s.url, s.address, s.latitude, s.longitude, array_agg(DISTINCT et.topic) AS topics, "+
+			"MAX(lt.score) AS score, COUNT(DISTINCT m.source_url) AS mentions, MAX(m.week) AS last_mentioned_week").
+		Joins("JOIN store_mentions AS m ON m.store_id = s.id").
+		Joins("JOIN entity_topics AS et ON et.id = m.topic_id AND et.disabled_at IS NULL").
+		Joins("LEFT JOIN (?) AS lt ON lt.topic_id = m.topic_id", latest).
+		Scopes(tenantColumn("et.tenant_id", tenantID)).
+		Where("s.latitude IS NOT NULL AND s.longitude IS NOT NULL")
+	if area != "" {
+		q = q.Where("et.topic ILIKE ?", "%"+likeEscaper.Replace(area)+"%")
+	}
+	if topicID != 0 {
+		q = q.Where("m.topic_id = ?", topicID)
+	}
+	var features []StoreFeature
+	err := q.Group("s.id").Order("s.id").Scan(&features).Error
+	return features, err
+}
+
+// StoreSummary は店舗と、店舗に言及していたトピック・言及の数です。店舗の検索に使います。
+type StoreSummary struct {
+	ID        uint           `json:"id"`
+	Name      string         `json:"name"`
+	URL       string         `json:"url"`
+	Latitude  *float64       `json:"latitude,omitempty"` // 住所を変換できていなければnil
+	Longitude *float64       `json:"longitude,omitempty"`
+	Topics    pq.StringArray `gorm:"type:text[]" json:"topics"`
+	Mentions  int64          `json:"mentions"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// summariesは店舗ごとのStoreSummaryのクエリです。tenantIDが0でなければ、そのテナントのトピックで言及された店舗のみ返し、
+// Topics・Mentionsもそのテナントのトピックのみから求めます。
+func (r *StoreRepository) summaries(tenantID uint) *gorm.DB {
+	return r.db.Table("stores AS s").
+		Select("s.id, s.name, s.url, s.latitude, s.longitude, s.updated_at, " +
+			"COALESCE(array_agg(DISTINCT et.topic) FILTER (WHERE et.topic IS NOT NULL), '{}') AS topics, COUNT(m.id) AS mentions").
+		Joins("LEFT JOIN store_mentions AS m ON m.store_id = s.id").
+		Joins("LEFT JOIN entity_topics AS et ON et.id = m.topic_id").
+		Scopes(tenantColumn("et.tenant_id", tenantID)).
+		Group("s.id")
+}
+
+// ListSummariesはIDがafterIDより大きい店舗をID順に最大limit件返します。すべての店舗を順に読むために使います。
+// tenantIDが0でなければそのテナントのトピックで言及された店舗のみ対象です。
+func (r *StoreRepository) ListSummaries(tenantID, afterID uint, limit int) ([]StoreSummary, error) {
+	var items []StoreSummary
+	err := r.summaries(tenantID).Where("s.id > ?", afterID).Order("s.id").Limit(limit).Scan(&items).Error
+	return items, err
+}
+
+// SummariesByRunは実行runIDで言及を記録した店舗をID順に返します。tenantIDが0でなければそのテナントのトピックで言及された店舗のみ対象です。
+func (r *StoreRepository) SummariesByRun(tenantID uint, runID string) ([]StoreSummary, error) {
+	var items []StoreSummary
+	err := r.summaries(tenantID).
+		Where("s.id IN (?)", r.db.Model(&model.StoreMention{}).Select("store_id").Where("run_id = ?", runID)).
+		Order("s.id").
+		Scan(&items).Error
+	return items, err
+}
+
+// SearchSummariesは店舗名か言及していたトピックにqueryを含む店舗を、言及の多い順に最大limit件返します。
+// tenantIDが0でなければそのテナントのトピックで言及された店舗のみ対象です。
+func (r *StoreRepository) SearchSummaries(tenantID uint, query string, limit int) ([]StoreSummary, error) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	var items []StoreSummary
+	err := r.summaries(tenantID).
+		Having("s.name ILIKE ? OR bool_or(et.topic ILIKE ?)", pattern, pattern).
+		Order("mentions DESC, s.id").
+		Limit(limit).
+		Scan(&items).Error
+	return items, err
+}
+
+// StoreNameMatch は店舗名の検索に一致した店舗です。Similarityは検索語との類似度(0〜1)です。
+type StoreNameMatch struct {
+	ID         uint
+	Name       string
+	URL        string
+	Similarity float64
+}
+
+// SearchNamesは店舗名がqueryを含むか、表記の近い(pg_trgmの類似度がしきい値以上の)店舗を、
+// queryを含むもの、類似度の高いものから順に最大limit件返します。tenantIDが0でなければそのテナントのトピックで言及された店舗のみ対象です。
+func (r *StoreRepository) SearchNames(tenantID uint, query string, limit int) ([]StoreNameMatch, error) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	mentioned := r.db.Table("store_mentions AS m").
+		Select("1").
+		Joins("JOIN entity_topics AS et ON et.id = m.topic_id").
+		Scopes(tenantColumn("et.tenant_id", tenantID)).
+		Where("m.store_id = s.id")
+	var matches []StoreNameMatch
+	err := r.db.Table("stores AS s").
+		Select("s.id, s.name, s.url, similarity(s.name, ?) AS similarity", query).
+		Where("(s.name ILIKE ? OR s.name % ?)", pattern, query).
+		Where("EXISTS (?)", mentioned).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "s.name ILIKE ? DESC, similarity DESC, s.id", Vars: []interface{}{pattern}}}).
+		Limit(limit).
+		Scan(&matches).Error
+	return matches, err
+}
+
+// StoreAppearance は店舗が言及されていた、トピックのある週のトレンドです。
+type StoreAppearance struct {
+	StoreID  uint
+	TrendID  uint
+	TopicID  uint
+	Topic    string
+	Week     time.Time
+	Score    float64
+	Mentions int64 // その週にトピックで店舗に言及していたページの数
+}
+
+// Appearancesは店舗storeIDsが言及されていた週のトピックのトレンドを、店舗ごとに新しい週から順に返します。
+// 言及はあってもトレンドを保存していない週は含めません。tenantIDが0でなければそのテナントのトピックのみ対象です。
+func (r *StoreRepository) Appearances(tenantID uint, storeIDs []uint) ([]StoreAppearance, error) {
+	if len(storeIDs) == 0 {
+		return nil, nil
+	}
+	var items []StoreAppearance
+	err := r.db.Table("store_mentions AS m").
+		Select("m.store_id, t.id AS trend_id, t.topic_id, et.topic, t.week, t.score, COUNT(DISTINCT m.source_url) AS mentions").
+		Joins("JOIN topic_trends AS t ON t.topic_id = m.topic_id AND t.week = m.week").
+		Joins("JOIN entity_topics AS et ON et.id = m.topic_id").
+		Scopes(tenantColumn("et.tenant_id", tenantID)).
+		Where("m.store_id IN ?", storeIDs).
+		Group("m.store_id, t.id, et.topic").
+		Order("m.store_id, t.week DESC, t.id DESC").
+		Scan(&items).Error
+	return items, err
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+)
+
+// TenantRepository はテナントとそのAPIキー、利用量を扱うリポジトリです。
+type TenantRepository struct {
+	db *gorm.DB
+}
+
+func NewTenantRepository(db *gorm.DB) *TenantRepository {
+	return &TenantRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *TenantRepository) WithContext(ctx context.Context) *TenantRepository {
+	return &TenantRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *TenantRepository) Create(tenant *model.Tenant) error {
+	return r.db.Create(tenant).Error
+}
+
+// Getはテナントを返します。該当するテナントがなければgorm.ErrRecordNotFoundを返します。
+func (r *TenantRepository) Get(id uint) (*model.Tenant, error) {
+	var tenant model.Tenant
+	if err := r.db.Take(&tenant, id).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// GetBySlugはスラッグが一致するテナントを返します。該当するテナントがなければgorm.ErrRecordNotFoundを返します。
+func (r *TenantRepository) GetBySlug(slug string) (*model.Tenant, error) {
+	var tenant model.Tenant
+	if err := r.db.Where("slug = ?", slug).Take(&tenant).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// Listはテナントをid順に返します。
+func (r *TenantRepository) List() ([]model.Tenant, error) {
+	var tenants []model.Tenant
+	err := r.db.Order("id").Find(&tenants).Error
+	return tenants, err
+}
+
+// UpdateQuotasはテナントのトピック数と1日・1か月のリクエスト数の上限を更新します。該当するテナントがなければgorm.ErrRecordNotFoundを返します。
+func (r *TenantRepository) UpdateQuotas(tenant *model.Tenant) error {
+	result := r.db.Model(tenant).Select("max_topics", "daily_request_limit", "monthly_request_limit", "updated_at").Updates(tenant)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *TenantRepository) CreateAPIKey(key *model.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+// GetAPIKeyはidのAPIキーを返します。失効したキーも返します。
+func (r *TenantRepository) GetAPIKey(id uint) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := r.db.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// UpdateAPIKeyQuotasはAPIキーの1日・1か月のリクエスト数の上限を更新します。該当するAPIキーがなければgorm.ErrRecordNotFoundを返します。
+func (r *TenantRepository) UpdateAPIKeyQuotas(key *model.APIKey) error {
+	result := r.db.Model(key).Select("daily_request_limit", "monthly_request_limit").Updates(key)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateAPIKeyScopesはAPIキーのスコープを更新します。該当するAPIキーがなければgorm.ErrRecordNotFoundを返します。
+func (r *TenantRepository) UpdateAPIKeyScopes(key *model.APIKey) error {
+	result := r.db.Model(key).Select("scopes").Updates(key)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListAPIKeysはテナントのAPIキーを失効したものも含めてid順に返します。
+func (r *TenantRepository) ListAPIKeys(tenantID uint) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	err := r.db.Where("tenant_id = ?", tenantID).Order("id").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKeyはAPIキーを失効させます。該当する有効なAPIキーがなければgorm.ErrRecordNotFoundを返します。
+func (r *TenantRepository) RevokeAPIKey(id uint, now time.Time) error {
+	result := r.db.Model(&model.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Authenticateはハッシュが一致する有効なAPIキーとそのテナントを返し、キーの最終利用日時をnowに更新します。
+// 該当するAPIキーがないか、失効している場合はgorm.ErrRecordNotFoundを返します。
+func (r *TenantRepository) Authenticate(keyHash string, now time.Time) (*model.Tenant, *model.APIKey, error) {
+	return r.authenticate(now, "key_hash = ? AND revoked_at IS NULL", keyHash)
+}
+
+// AuthenticateKeyはAuthenticateと同じく、IDがidの有効なAPIキーとそのテナントを返します。
+// アクセストークンの認証で、トークンの発行後に失効したAPIキーのトークンを拒否するために使います。
+func (r *TenantRepository) AuthenticateKey(id uint, now time.Time) (*model.Tenant, *model.APIKey, error) {
+	return r.authenticate(now, "id = ? AND revoked_at IS NULL", id)
+}
+
+func (r *TenantRepository) authenticate(now time.Time, query string, args ...interface{}) (*model.Tenant, *model.APIKey, error) {
+	var key model.APIKey
+	if err := r.db.Where(query, args...).Take(&key).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := r.db.Model(&key).Update("last_used_at", now).Error; err != nil {
+		return nil, nil, err
+	}
+	tenant, err := r.Get(key.TenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tenant, &key, nil
+}
+
+// CountTopicsはテナントのトピック数を返します。無効化したトピックも数えます。
+func (r *TenantRepository) CountTopics(tenantID uint) (int, error) {
+	var n int64
+	err := r.db.Model(&model.EntityTopic{}).Where("tenant_id = ?", tenantID).Count(&n).Error
+	return int(n), err
+}
+
+// topicsOfTenantはtenantIDが0でなければ、topic_idの列を持つテーブルをそのテナントのトピックの行に絞り込むスコープを返します。
+// tenantIDが0の場合はすべてのテナントが対象です。
+func topicsOfTenant(tenantID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if tenantID == 0 {
+			return db
+		}
+		return db.Where("topic_id IN (SELECT id FROM entity_topics WHERE tenant_id = ?)", tenantID)
+	}
+}
+
+// tenantColumnはtenantIDが0でなければ、columnの値がtenantIDの行に絞り込むスコープを返します。
+// tenantIDが0の場合はすべてのテナントが対象です。
+func tenantColumn(column string, tenantID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if tenantID == 0 {
+			return db
+		}
+		return db.Where(column+" = ?", tenantID)
+	}
+}
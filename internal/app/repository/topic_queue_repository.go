@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TopicQueueRepository はPostgreSQLの発掘の依頼のキューを扱うリポジトリです。
+type TopicQueueRepository struct {
+	db *gorm.DB
+}
+
+func NewTopicQueueRepository(db *gorm.DB) *TopicQueueRepository {
+	return &TopicQueueRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *TopicQueueRepository) WithContext(ctx context.Context) *TopicQueueRepository {
+	return &TopicQueueRepository{db: r.db.WithContext(ctx)}
+}
+
+// Enqueueは依頼をキューに入れます。
+func (r *TopicQueueRepository) Enqueue(body string) error {
+	return r.db.Create(&model.TopicQueueItem{Body: body, Status: model.TopicQueuePending}).Error
+}
+
+// Claimは取得待ちの依頼と、取得した期限(lease_until)を過ぎた依頼を古い順に最大limit件取得し、nowからleaseの間は他のプロセスに渡さないようにします。
+// 他のプロセスが取得中の行は飛ばします。期限を過ぎた依頼のうち既にmaxAttempts回取得したものは取得せずfailedにし、その件数を返します。
+func (r *TopicQueueRepository) Claim(now time.Time, lease time.Duration, limit, maxAttempts int, token, claimedBy string) (items []model.TopicQueueItem, failed int64, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.TopicQueueItem{}).
+			Where("status = ? AND lease_until <= ? AND attempts >= ?", model.TopicQueuePending, now, maxAttempts).
+			Update("status", model.TopicQueueFailed)
+		if res.Error != nil {
+			return res.Error
+		}
+		failed = res.RowsAffected
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND (lease_until IS NULL OR lease_until <= ?)", model.TopicQueuePending, now).
+			Order("id").
+			Limit(limit).
+			Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		ids := make([]uint64, len(items))
+		leaseUntil := now.Add(lease)
+		for i := range items {
+			ids[i] = items[i].ID
+			items[i].Attempts++
+			items[i].ClaimToken, items[i].ClaimedBy, items[i].LeaseUntil = token, claimedBy, &leaseUntil
+		}
+		return tx.Model(&model.TopicQueueItem{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"attempts":    gorm.Expr("attempts + 1"),
+			"claim_token": token,
+			"claimed_by":  claimedBy,
+			"lease_until": leaseUntil,
+		}).Error
+	})
+	return items, failed, err
+}
+
+// Deleteは取得した依頼を削除し、削除した場合にtrueを返します。
+// 期限を過ぎて別のプロセスが取得し直した依頼(claim_tokenが異なる)は削除しません。
+func (r *TopicQueueRepository) Delete(id uint64, token string) (bool, error) {
+	res := r.db.Where("id = ? AND claim_token = ?", id, token).Delete(&model.TopicQueueItem{})
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/testdb"
+
+	"gorm.io/gorm"
+)
+
+const (
+	testLease       = 10 * time.Minute
+	testMaxAttempts = 2
+)
+
+func enqueue(t *testing.T, repo *TopicQueueRepository, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := repo.Enqueue(fmt.Sprintf(`{"topic_id": %d}`, i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestClaimSkipLockedは他のプロセスがトランザクションの中で取得中の依頼を、待たずに飛ばして次の依頼を取得することを確認します。
+func TestClaimSkipLocked(t *testing.T) {
+	db := testdb.Postgres(t)
+	repo := NewTopicQueueRepository(db)
+	enqueue(t, repo, 2)
+	now := time.Now()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		first, _, err := NewTopicQueueRepository(tx).Claim(now, testLease, 1, testMaxAttempts, "token-a", "a")
+		if err != nil {
+			return err
+		}
+		// 1件目の行のロックはこのトランザクションが終わるまで残る
+		second, _, err := repo.Claim(now, testLease, 1, testMaxAttempts, "token-b", "b")
+		if err != nil {
+			return err
+		}
+		if len(first) != 1 || len(second) != 1 || first[0].ID == second[0].ID {
+			t.Errorf("first = %+v, second = %+v, want different items", first, second)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestClaimConcurrentは並行して取得しても、期限の間は同じ依頼を2つのプロセスに渡さないことを確認します。
+func TestClaimConcurrent(t *testing.T) {
+	db := testdb.Postgres(t)
+	repo := NewTopicQueueRepository(db)
+	const items, claimers = 10, 4
+	enqueue(t, repo, items)
+	now := time.Now()
+
+	var mu sync.Mutex
+	claimed := make(map[uint64]string)
+	var wg sync.WaitGroup
+	for i := 0; i < claimers; i++ {
+		wg.Add(1)
+		go func(token string) {
+			defer wg.Done()
+			for {
+				got, _, err := repo.Claim(now, testLease, 1, testMaxAttempts, token, token)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(got) == 0 {
+					return
+				}
+				mu.Lock()
+				if other, ok := claimed[got[0].ID]; ok {
+					t.Errorf("item %d claimed by %s and %s", got[0].ID, other, token)
+				}
+				claimed[got[0].ID] = token
+				mu.Unlock()
+			}
+		}(fmt.Sprintf("claimer-%d", i))
+	}
+	wg.Wait()
+	if len(claimed) != items {
+		t.Errorf("claimed %d items, want %d", len(claimed), items)
+	}
+}
+
+// TestClaimLeaseは期限を過ぎた依頼を取得し直せ、試行の上限に達した依頼はfailedにすることを確認します。
+func TestClaimLease(t *testing.T) {
+	db := testdb.Postgres(t)
+	repo := NewTopicQueueRepository(db)
+	enqueue(t, repo, 1)
+	now := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+
+	first, _, err := repo.Claim(now, testLease, 1, testMaxAttempts, "token-a", "a")
+	if err != nil || len(first) != 1 || first[0].Attempts != 1 {
+		t.Fatalf("first claim = %+v, %v", first, err)
+	}
+	if got, _, err := repo.Claim(now.Add(testLease-time.Second), testLease, 1, testMaxAttempts, "token-b", "b"); err != nil || len(got) != 0 {
+		t.Fatalf("claim before the lease expires = %+v, %v, want none", got, err)
+	}
+
+	// 期限を過ぎると別のプロセスが取得し直し、前のプロセスのtokenでは削除できない
+	later := now.Add(testLease)
+	second, _, err := repo.Claim(later, testLease, 1, testMaxAttempts, "token-b", "b")
+	if err != nil || len(second) != 1 || second[0].ID != first[0].ID || second[0].Attempts != 2 || second[0].ClaimToken != "token-b" {
+		t.Fatalf("reclaim = %+v, %v", second, err)
+	}
+	if deleted, err := repo.Delete(first[0].ID, "token-a"); err != nil || deleted {
+		t.Errorf("Delete with the expired token = %v, %v, want false", deleted, err)
+	}
+
+	// 上限の回数取得しても削除されなかった依頼は、期限を過ぎるとfailedにする
+	got, failed, err := repo.Claim(later.Add(testLease), testLease, 1, testMaxAttempts, "token-c", "c")
+	if err != nil || len(got) != 0 || failed != 1 {
+		t.Fatalf("claim after max attempts = %+v, failed %d, %v; want none and 1 failed", got, failed, err)
+	}
+	var item model.TopicQueueItem
+	if err := db.First(&item, first[0].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if item.Status != model.TopicQueueFailed || item.ClaimToken != "token-b" {
+		t.Errorf("item = %+v, want failed with the last token", item)
+	}
+}
+
+func TestDeleteClaimed(t *testing.T) {
+	db := testdb.Postgres(t)
+	repo := NewTopicQueueRepository(db)
+	enqueue(t, repo, 1)
+
+	got, _, err := repo.Claim(time.Now(), testLease, 1, testMaxAttempts, "token-a", "a")
+	if err != nil || len(got) != 1 {
+		t.Fatalf("claim = %+v, %v", got, err)
+	}
+	if deleted, err := repo.Delete(got[0].ID, "other"); err != nil || deleted {
+		t.Errorf("Delete with another token = %v, %v, want false", deleted, err)
+	}
+	if deleted, err := repo.Delete(got[0].ID, "token-a"); err != nil || !deleted {
+		t.Errorf("Delete with the claim token = %v, %v, want true", deleted, err)
+	}
+	var count int64
+	if err := db.Model(&model.TopicQueueItem{}).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("count = %d, %v, want 0", count, err)
+	}
+}
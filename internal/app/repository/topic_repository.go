@@ -0,0 +1,399 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 発掘の順序を決める優先度の加点。トピックの優先度(priority)にこれらを加えた値の大きい順に発掘する
+const (
+	// watchedTopicBoost はウォッチが設定されているトピックへの加点です。通知を待っている人がいる
+	watchedTopicBoost = 10
+	// anomalyBoost は直近anomalyWeeks週の間に前週比の変化量(絶対値)がanomalyDelta以上になったトピックへの加点です。
+	anomalyBoost = 20
+	anomalyWeeks = 4
+	anomalyDelta = 20.0
+)
+
+// ByPriorityはentity_topicsのトピックを、優先度に加点した値の大きい順、同じ値ならID順に並べるスコープです。
+// weekは加点の対象とする急な変化を探す基準の週です。
+func ByPriority(week time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL: "entity_topics.priority" +
+				" + CASE WHEN EXISTS (SELECT 1 FROM watches AS w WHERE w.topic_id = entity_topics.id) THEN ? ELSE 0 END" +
+				" + CASE WHEN EXISTS (SELECT 1 FROM topic_trends AS t WHERE t.topic_id = entity_topics.id AND t.week >= ? AND ABS(t.delta) >= ?) THEN ? ELSE 0 END" +
+				" DESC, entity_topics.id",
+			Vars:               []interface{}{watchedTopicBoost, week.AddDate(0, 0, -7*anomalyWeeks), anomalyDelta, anomalyBoost},
+			WithoutParentheses: true,
+		}})
+	}
+}
+
+// TopicRepository はエンティティとトピックを扱うリポジトリです。
+type TopicRepository struct {
+	db       *gorm.DB
+	tenantID uint // 0の場合はすべてのテナントが対象
+}
+
+func NewTopicRepository(db *gorm.DB) *TopicRepository {
+	return &TopicRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *TopicRepository) WithContext(ctx context.Context) *TopicRepository {
+	return &TopicRepository{db: r.db.WithContext(ctx), tenantID: r.tenantID}
+}
+
+// ForTenantはテナントtenantIDのエンティティとトピックのみを扱うリポジトリを返します。
+func (r *TopicRepository) ForTenant(tenantID uint) *TopicRepository {
+	return &TopicRepository{db: r.db, tenantID: tenantID}
+}
+
+// FindOrCreateEntityはテナントのエンティティのうち名前と種別が一致するものを返します。存在しなければ作成し、createdをtrueで返します。
+// テナントを指定していないリポジトリでは既定のテナントのエンティティを扱います。
+func (r *TopicRepository) FindOrCreateEntity(name, entityType string) (entity *model.Entity, created bool, err error) {
+	tenantID := r.tenantID
+	if tenantID == 0 {
+		tenantID = model.DefaultTenantID
+	}
+	entity = &model.Entity{}
+	err = r.db.Where("tenant_id = ? AND name = ? AND type = ?", tenantID, name, entityType).Take(entity).Error
+	if err == nil {
+		return entity, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	entity = &model.Entity{TenantID: tenantID, Name: name, Type: entityType}
+	if err := r.db.Create(entity).Error; err != nil {
+		return nil, false, err
+	}
+	return entity, true, nil
+}
+
+// エンティティの作成・更新・削除の失敗
+var (
+	ErrEntityExists    = errors.New("entity with the same name and type already exists")
+	ErrEntityHasTopics = errors.New("entity has topics")
+)
+
+// EntityFilter はエンティティの一覧の条件です。ゼロ値の条件は絞り込みません。
+type EntityFilter struct {
+	Type  string
+	Query string      // 名前の部分一致
+	Sort  []SortField // 項目はEntitySortFieldsのいずれか。nilの場合はID順
+}
+
+// EntitySortFields はエンティティの一覧で並べ替えられる項目です。項目名はentitiesの列名と同じです。
+var EntitySortFields = []string{"id", "name", "type", "created_at", "updated_at"}
+
+// entitySortKeys はEntitySortFieldsの項目の列と、cursorに持つ行の値です。
+var entitySortKeys = map[string]struct {
+	sortKey
+	value func(model.Entity) string
+}{
+	"id":         {sortKey{column: "id", cast: "integer"}, func(e model.Entity) string { return strconv.FormatUint(uint64(e.ID), 10) }},
+	"name":       {sortKey{column: "name", cast: "text"}, func(e model.Entity) string { return e.Name }},
+	"type":       {sortKey{column: "type", cast: "text"}, func(e model.Entity) string { return e.Type }},
+	"created_at": {sortKey{column: "created_at", cast: "timestamptz"}, func(e model.Entity) string { return e.CreatedAt.UTC().Format(time.RFC3339Nano) }},
+	"updated_at": {sortKey{column: "updated_at", cast: "timestamptz"}, func(e model.Entity) string { return e.UpdatedAt.UTC().Format(time.RFC3339Nano) }},
+}
+
+// EntityCursorはsortの順のエンティティの一覧で、eの次のページを読むcursorを返します。sortがnil(ID順)の場合はIDのみ持ちます。
+func EntityCursor(e model.Entity, sort []SortField) Cursor {
+	c := Cursor{ID: e.ID}
+	for _, f := range sort {
+		c.Values = append(c.Values, entitySortKeys[f.Name].value(e))
+	}
+	return c
+}
+
+// ListEntitiesはfに一致するエンティティをf.Sortの順(同じ値のエンティティはIDを最後の項目と同じ向きで並べる)に、afterより後ろを最大limit件返します。
+// afterには前のページの最後のエンティティのEntityCursor(f.Sort)を渡し、f.Sortと項目の数が合わなければErrInvalidCursorを返します。
+func (r *TopicRepository) ListEntities(f EntityFilter, after Cursor, limit int) ([]model.Entity, error) {
+	q := r.db.Scopes(tenantColumn("tenant_id", r.tenantID))
+	if f.Type != "" {
+		q = q.Where("type = ?", f.Type)
+	}
+	if f.Query != "" {
+		q = q.Where("name ILIKE ?", "%"+likeEscaper.Replace(f.Query)+"%")
+	}
+	if !after.IsZero() && len(after.Values) != len(f.Sort) {
+		return nil, ErrInvalidCursor
+	}
+	if len(f.Sort) == 0 {
+		q = q.Scopes(afterID("id", after)).Order("id")
+	} else {
+		keys := make([]sortKey, len(f.Sort))
+		for i, s := range f.Sort {
+			keys[i] = entitySortKeys[s.Name].sortKey
+			keys[i].desc = s.Desc
+		}
+		if !after.IsZero() {
+			cond, args := afterKeys(keys, after.Values, "id", after.ID)
+			q = q.Where(cond, args...)
+		}
+		q = q.Order(orderBy(keys, "id"))
+	}
+	var entities []model.Entity
+	err := q.Limit(limit).Find(&entities).Error
+	return entities, err
+}
+
+// GetEntityはエンティティを返します。該当するエンティティがなければgorm.ErrRecordNotFoundを返します。
+func (r *TopicRepository) GetEntity(id uint) (*model.Entity, error) {
+	var entity model.Entity
+	if err := r.db.Scopes(tenantColumn("tenant_id", r.tenantID)).Take(&entity, id).Error; err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// CreateEntityはエンティティを作成します。テナントを指定していないリポジトリでは既定のテナントに作成します。
+// 同じテナントに名前と種別が同じエンティティがあればErrEntityExistsを返します。
+func (r *TopicRepository) CreateEntity(entity *model.Entity) error {
+	entity.TenantID = r.tenantID
+	if entity.TenantID == 0 {
+		entity.TenantID = model.DefaultTenantID
+	}
+	if err := r.checkEntityName(entity); err != nil {
+		return err
+	}
+	return r.db.Create(entity).Error
+}
+
+// UpdateEntityはエンティティの名前と種別を更新します。該当するエンティティがなければgorm.ErrRecordNotFoundを、
+// 同じテナントに名前と種別が同じ他のエンティティがあればErrEntityExistsを返します。
+func (r *TopicRepository) UpdateEntity(entity *model.Entity) error {
+	current, err := r.GetEntity(entity.ID)
+	if err != nil {
+		return err
+	}
+	entity.TenantID = current.TenantID
+	if err := r.checkEntityName(entity); err != nil {
+		return err
+	}
+	return r.db.Model(entity).Select("name", "type", "updated_at").Updates(entity).Error
+}
+
+// checkEntityNameはentityと同じテナントに、名前と種別が同じ他のエンティティがあればErrEntityExistsを返します。
+// FindOrCreateEntityは名前と種別でエンティティを探すため、重複させないようにします。
+func (r *TopicRepository) checkEntityName(entity *model.Entity) error {
+	var n int64
+	err := r.db.Model(&model.Entity{}).
+		Where("tenant_id = ? AND name = ? AND type = ? AND id <> ?", entity.TenantID, entity.Name, entity.Type, entity.ID).
+		Count(&n).Error
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrEntityExists
+	}
+	return nil
+}
+
+// DeleteEntityはトピックのないエンティティを削除します。該当するエンティティがなければgorm.ErrRecordNotFoundを、
+// トピックがあればErrEntityHasTopicsを返します。トピックを消すとトレンドや言及も消えるため、まとめて削除はしません。
+func (r *TopicRepository) DeleteEntity(id uint) error {
+	result := r.db.Scopes(tenantColumn("tenant_id", r.tenantID)).
+		Where("NOT EXISTS (SELECT 1 FROM entity_topics AS et WHERE et.entity_id = entities.id)").
+		Delete(&model.Entity{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	if _, err := r.GetEntity(id); err != nil {
+		return err
+	}
+	return ErrEntityHasTopics
+}
+
+// FindTopicはエンティティに登録されたトピックを返します。該当するトピックがなければgorm.ErrRecordNotFoundを返します。
+func (r *TopicRepository) FindTopic(entityID uint, topic string) (*model.EntityTopic, error) {
+	var t model.EntityTopic
+	if err := r.db.Where("entity_id = ? AND topic = ?", entityID, topic).Take(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateTopicはトピックを作成します。トピックのテナントはエンティティと同じにします。
+func (r *TopicRepository) CreateTopic(topic *model.EntityTopic) error {
+	if topic.TenantID == 0 {
+		var entity model.Entity
+		if err := r.db.Select("tenant_id").Take(&entity, topic.EntityID).Error; err != nil {
+			return err
+		}
+		topic.TenantID = entity.TenantID
+	}
+	return r.db.Create(topic).Error
+}
+
+// TopicListItem はトピックの一覧の1行で、エンティティと直近のトレンドの週を含みます。
+type TopicListItem struct {
+	ID         uint
+	EntityID   uint
+	EntityName string
+	EntityType string
+	Topic      string
+	Priority   int
+	DisabledAt *time.Time
+	LatestWeek *time.Time // トレンドがまだなければnil
+	CreatedAt  time.Time
+}
+
+// ListTopicsはトピックをID順に返します。disabledがnilでなければ、無効化されている(true)、または有効な(false)トピックのみ返します。
+func (r *TopicRepository) ListTopics(disabled *bool) ([]TopicListItem, error) {
+	var items []TopicListItem
+	q := r.topicList()
+	if disabled != nil {
+		if *disabled {
+			q = q.Where("et.disabled_at IS NOT NULL")
+		} else {
+			q = q.Where("et.disabled_at IS NULL")
+		}
+	}
+	err := q.Scan(&items).Error
+	return items, err
+}
+
+// ListEntityTopicsはエンティティのトピックをID順に返します。無効化されているトピックも含みます。
+// afterが先頭のページでなければ、afterのトピック(前のページの最後のトピックのID)より後ろを最大limit件返します。
+func (r *TopicRepository) ListEntityTopics(entityID uint, after Cursor, limit int) ([]TopicListItem, error) {
+	var items []TopicListItem
+	err := r.topicList().Where("et.entity_id = ?", entityID).Scopes(afterID("et.id", after)).Limit(limit).Scan(&items).Error
+	return items, err
+}
+
+// topicListはトピックの一覧(TopicListItem)をID順に返すクエリです。
+func (r *TopicRepository) topicList() *gorm.DB {
+	return r.db.Table("entity_topics AS et").
+		Select("et.id, et.entity_id, e.name AS entity_name, e.type AS entity_type, et.topic, et.priority, et.disabled_at, " +
+			"(SELECT MAX(t.week) FROM topic_trends AS t WHERE t.topic_id = et.id) AS latest_week, et.created_at").
+		Joins("JOIN entities AS e ON e.id = et.entity_id").
+		Scopes(tenantColumn("et.tenant_id", r.tenantID)).
+		Order("et.id")
+}
+
+// ListDueは有効なトピックのうち、week週のトレンドがまだないもののIDを優先度の高い順(ByPriority)に返します。
+func (r *TopicRepository) ListDue(week time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.Table("entity_topics").
+		Scopes(tenantColumn("entity_topics.tenant_id", r.tenantID), ByPriority(week)).
+		Where("entity_topics.disabled_at IS NULL").
+		Where("NOT EXISTS (SELECT 1 FROM topic_trends AS t WHERE t.topic_id = entity_topics.id AND t.week = ?)", week).
+		Pluck("entity_topics.id", &ids).Error
+	return ids, err
+}
+
+// ListEnabledは有効なトピックのIDを優先度の高い順(ByPriority)に返します。今週のトレンドがあるトピックも含みます。
+func (r *TopicRepository) ListEnabled(week time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.Table("entity_topics").
+		Scopes(tenantColumn("entity_topics.tenant_id", r.tenantID), ByPriority(week)).
+		Where("entity_topics.disabled_at IS NULL").
+		Pluck("entity_topics.id", &ids).Error
+	return ids, err
+}
+
+// SetPriorityはトピックの優先度を変更し、更新後のトピックを返します。該当するトピックがなければgorm.ErrRecordNotFoundを返します。
+func (r *TopicRepository) SetPriority(id uint, priority int) (*model.EntityTopic, error) {
+	topic, err := r.GetTopic(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(topic).Update("priority", priority).Error; err != nil {
+		return nil, err
+	}
+	topic.Priority = priority
+	return topic, nil
+}
+
+// GetTopicはトピックを返します。該当するトピックがなければgorm.ErrRecordNotFoundを返します。
+func (r *TopicRepository) GetTopic(id uint) (*model.EntityTopic, error) {
+	var topic model.EntityTopic
+	if err := r.db.Scopes(tenantColumn("tenant_id", r.tenantID)).Take(&topic, id).Error; err != nil {
+		return nil, err
+	}
+	return &topic, nil
+}
+
+// RenameTopicはトピック(検索クエリ)の文字列を変更し、更新後のトピックを返します。該当するトピックがなければgorm.ErrRecordNotFoundを返します。
+func (r *TopicRepository) RenameTopic(id uint, topic string) (*model.EntityTopic, error) {
+	t, err := r.GetTopic(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(t).Update("topic", topic).Error; err != nil {
+		return nil, err
+	}
+	t.Topic = topic
+	return t, nil
+}
+
+// DeleteTopicはトピックを削除します。トピックのトレンド・言及・ウォッチなどもDBの外部キーにより削除されます。
+// 該当するトピックがなければgorm.ErrRecordNotFoundを返します。
+func (r *TopicRepository) DeleteTopic(id uint) error {
+	result := r.db.Scopes(tenantColumn("tenant_id", r.tenantID)).Delete(&model.EntityTopic{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetTopicDisabledはトピックを無効化(disabledがtrue)または有効化し、更新後のトピックを返します。
+// 既に無効なトピックを無効化しても無効化した日時は変わりません。該当するトピックがなければgorm.ErrRecordNotFoundを返します。
+func (r *TopicRepository) SetTopicDisabled(id uint, disabled bool, now time.Time) (*model.EntityTopic, error) {
+	topic, err := r.GetTopic(id)
+	if err != nil {
+		return nil, err
+	}
+	if disabled == (topic.DisabledAt != nil) {
+		return topic, nil
+	}
+	var disabledAt *time.Time
+	if disabled {
+		disabledAt = &now
+	}
+	if err := r.db.Model(topic).Update("disabled_at", disabledAt).Error; err != nil {
+		return nil, err
+	}
+	topic.DisabledAt = disabledAt
+	return topic, nil
+}
+
+// EntityTypesはトピックのエンティティの種別を返します。key: トピックのID, value: エンティティの種別
+func (r *TopicRepository) EntityTypes(topicIDs []uint) (map[uint]string, error) {
+	var rows []struct {
+		ID         uint
+		EntityType string
+	}
+	if len(topicIDs) > 0 {
+		err := r.db.Table("entity_topics AS et").
+			Select("et.id, e.type AS entity_type").
+			Joins("JOIN entities AS e ON e.id = et.entity_id").
+			Where("et.id IN ?", topicIDs).
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	types := make(map[uint]string, len(rows))
+	for _, row := range rows {
+		types[row.ID] = row.EntityType
+	}
+	return types, nil
+}
@@ -0,0 +1,409 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+)
+
+// TrendRepository はトピックのトレンドスコアを扱うリポジトリです。
+type TrendRepository struct {
+	db       *gorm.DB
+	tenantID uint // 0の場合はすべてのテナントが対象
+}
+
+func NewTrendRepository(db *gorm.DB) *TrendRepository {
+	return &TrendRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *TrendRepository) WithContext(ctx context.Context) *TrendRepository {
+	return &TrendRepository{db: r.db.WithContext(ctx), tenantID: r.tenantID}
+}
+
+// ForTenantはテナントtenantIDのトレンドのみを扱うリポジトリを返します。
+func (r *TrendRepository) ForTenant(tenantID uint) *TrendRepository {
+	return &TrendRepository{db: r.db, tenantID: tenantID}
+}
+
+// SaveWeeklyはトレンドをトピック・週ごとに1件として保存します。同じトピック・週のトレンドが既にあれば内容を置き換え、createdをfalseで返します。
+// 発掘をやり直してもトレンドが増えないようにするために使います。
+// onCreateはトレンドを新しく作成した場合のみ同じトランザクションで呼び出します。trend.createdのイベントの記録に使います。
+// 同じトピック・週を並行して保存しても一意制約で失敗しないよう、ON CONFLICTで作成と置き換えを1文で行い、
+// 挿入した行かどうかはxmaxが0か(更新された行ではないか)で判定します。
+func (r *TrendRepository) SaveWeekly(trend *model.TopicTrend, onCreate func(tx *gorm.DB) error) (created bool, err error) {
+	now := time.Now()
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		row := tx.Raw(`INSERT INTO topic_trends (topic_id, week, score, gpt_score, delta, mention_count, top_title, run_id, prompt, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (topic_id, week) DO UPDATE SET
+				score = EXCLUDED.score, gpt_score = EXCLUDED.gpt_score, delta = EXCLUDED.delta, mention_count = EXCLUDED.mention_count,
+				top_title = EXCLUDED.top_title, run_id = EXCLUDED.run_id, prompt = EXCLUDED.prompt, updated_at = EXCLUDED.updated_at
+			RETURNING id, created_at, (xmax = 0)`,
+			trend.TopicID, trend.Week, trend.Score, trend.GPTScore, trend.Delta, trend.MentionCount, trend.TopTitle, trend.RunID, trend.Prompt, now, now).Row()
+		if err := row.Scan(&trend.ID, &trend.CreatedAt, &created); err != nil {
+			return err
+		}
+		trend.UpdatedAt = now
+		if !created {
+			return nil
+		}
+		return onCreate(tx)
+	})
+	return created && err == nil, err
+}
+
+// LatestBeforeは指定トピックでweekより前の週のトレンドのうち最新のものを返します。
+// 該当するトレンドがなければnilを返します。
+func (r *TrendRepository) LatestBefore(topicID uint, week time.Time) (*model.TopicTrend, error) {
+	var trend model.TopicTrend
+	err := r.db.Where("topic_id = ? AND week < ?", topicID, week).
+		Order("week DESC, id DESC").
+		First(&trend).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &trend, nil
+}
+
+// TopicMover は前週比でスコアが大きく動いたトピックです。
+type TopicMover struct {
+	TopicID uint    `json:"topic_id"`
+	Topic   string  `json:"topic"`
+	Score   float64 `json:"score"`
+	Delta   float64 `json:"delta"`
+}
+
+// LatestWeekはトレンドが存在する最新の週を返します。トレンドが1件もなければnilを返します。
+func (r *TrendRepository) LatestWeek() (*time.Time, error) {
+	var latest *time.Time
+	err := r.db.Model(&model.TopicTrend{}).Scopes(topicsOfTenant(r.tenantID)).Select("MAX(week)").Scan(&latest).Error
+	return latest, err
+}
+
+// Moversは指定週で前週比のスコア変化量が大きいトピックを、上昇(gainers)と下落(losers)それぞれ上位limit件返します。
+// 同じ週にトピックのトレンドが複数ある場合は最後に保存されたものを使います。
+func (r *TrendRepository) Movers(week time.Time, limit int) (gainers, losers []TopicMover, err error) {
+	latest := r.db.Table("topic_trends AS t").
+		Select("DISTINCT ON (t.topic_id) t.topic_id, et.topic, t.score, t.delta").
+		Joins("JOIN entity_topics AS et ON et.id = t.topic_id").
+		Scopes(tenantColumn("et.tenant_id", r.tenantID)).
+		Where("t.week = ? AND t.delta IS NOT NULL", week).
+		Order("t.topic_id, t.id DESC")
+
+	err = r.db.Table("(?) AS m", latest).
+		Where("m.delta > 0").
+		Order("m.delta DESC, m.topic_id").
+		Limit(limit).
+		Scan(&gainers).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = r.db.Table("(?) AS m", latest).
+		Where("m.delta < 0").
+		Order("m.delta ASC, m.topic_id").
+		Limit(limit).
+		Scan(&losers).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	return gainers, losers, nil
+}
+
+// TopicScore はトピックのある週のトレンドのスコアです。
+type TopicScore struct {
+	TopicID  uint     `json:"topic_id"`
+	Topic    string   `json:"topic"`
+	Score    float64  `json:"score"`
+	Delta    *float64 `json:"delta,omitempty"`
+	TopTitle string   `json:"top_title"`
+}
+
+// Topは指定週でスコアの高いトピックを上位limit件返します。
+// 同じ週にトピックのトレンドが複数ある場合は最後に保存されたものを使います。
+func (r *TrendRepository) Top(week time.Time, limit int) ([]TopicScore, error) {
+	latest := r.db.Table("topic_trends AS t").
+		Select("DISTINCT ON (t.topic_id) t.topic_id, et.topic, t.score, t.delta, t.top_title").
+		Joins("JOIN entity_topics AS et ON et.id = t.topic_id").
+		Scopes(tenantColumn("et.tenant_id", r.tenantID)).
+		Where("t.week = ?", week).
+		Order("t.topic_id, t.id DESC")
+
+	var top []TopicScore
+	err := r.db.Table("(?) AS m", latest).
+		Order("m.score DESC, m.topic_id").
+		Limit(limit).
+		Scan(&top).Error
+	return top, err
+}
+
+// RankedTopic は週のランキングのトピックです。
+type RankedTopic struct {
+	Rank         int      `json:"rank"`
+	TopicID      uint     `json:"topic_id"`
+	Topic        string   `json:"topic"`
+	EntityID     uint     `json:"entity_id"`
+	EntityName   string   `json:"entity_name"`
+	EntityType   string   `json:"entity_type"`
+	Score        float64  `json:"score"`
+	Delta        *float64 `json:"delta,omitempty"`
+	MentionCount int      `json:"mention_count"`
+	TopTitle     string   `json:"top_title"`
+}
+
+// Rankingは指定週のトピックをスコアの高い順に、エンティティの名前を付けて最大limit件返します。
+// 無効化されたトピックは含めません。スコアが同じトピックはトピックのID順に別の順位を付けます。
+func (r *TrendRepository) Ranking(week time.Time, limit int) ([]RankedTopic, error) {
+	var ranking []RankedTopic
+	err := r.db.Table("topic_trends AS t").
+		Select("ROW_NUMBER() OVER (ORDER BY t.score DESC, t.topic_id) AS rank, t.topic_id, et.topic, "+
+			"e.id AS entity_id, e.name AS entity_name, e.type AS entity_type, t.score, t.delta, t.mention_count, t.top_title").
+		Joins("JOIN entity_topics AS et ON et.id = t.topic_id").
+		Joins("JOIN entities AS e ON e.id = et.entity_id").
+		Scopes(tenantColumn("et.tenant_id", r.tenantID)).
+		Where("t.week = ? AND et.disabled_at IS NULL", week).
+		Order("t.score DESC, t.topic_id").
+		Limit(limit).
+		Scan(&ranking).Error
+	return ranking, err
+}
+
+// LatestTopicScore はトピックの最新の週のトレンドのスコアです。
+type LatestTopicScore struct {
+	TopicScore
+	Week time.Time `json:"week"`
+}
+
+// likeEscaper はLIKEのパターンで特別な意味を持つ文字をエスケープします。
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchLatestはエンティティ名かトピックにqueryを含むトピックの最新のトレンドを、スコアの高い順に最大limit件返します。
+// 無効化されたトピックは含めません。
+func (r *TrendRepository) SearchLatest(query string, limit int) ([]LatestTopicScore, error) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	latest := r.db.Table("topic_trends AS t").
+		Select("DISTINCT ON (t.topic_id) t.topic_id, et.topic, t.score, t.delta, t.top_title, t.week").
+		Joins("JOIN entity_topics AS et ON et.id = t.topic_id").
+		Joins("JOIN entities AS e ON e.id = et.entity_id").
+		Scopes(tenantColumn("et.tenant_id", r.tenantID)).
+		Where("et.disabled_at IS NULL AND (e.name ILIKE ? OR et.topic ILIKE ?)", pattern, pattern).
+		Order("t.topic_id, t.week DESC, t.id DESC")
+
+	var items []LatestTopicScore
+	err := r.db.Table("(?) AS m", latest).
+		Order("m.score DESC, m.topic_id").
+		Limit(limit).
+		Scan(&items).Error
+	return items, err
+}
+
+// FeedTrend はフィードに載せるトピックのある週のトレンドです。
+type FeedTrend struct {
+	ID           uint
+	TopicID      uint
+	Topic        string
+	Week         time.Time
+	Score        float64
+	Delta        *float64
+	MentionCount int
+	TopTitle     string
+	UpdatedAt    time.Time
+}
+
+// Feedはsince以降の週のトレンドを、新しい週から順に週の中ではスコアの高い順に最大limit件返します。
+// areaが空でなければトピック名にareaを含むトピック、minScoreが0より大きければスコアがminScore以上のトレンドのみ返します。
+// 無効化されたトピックは含めません。同じ週にトピックのトレンドが複数ある場合は最後に保存されたものを使います。
+func (r *TrendRepository) Feed(since time.Time, area string, minScore float64, limit int) ([]FeedTrend, error) {
+	latest := r.db.Table("topic_trends AS t").
+		Select("DISTINCT ON (t.topic_id, t.week) t.id, t.topic_id, et.topic, t.week, t.score, t.delta, t.mention_count, t.top_title, t.updated_at").
+		Joins("JOIN entity_topics AS et ON et.id = t.topic_id").
+		Scopes(tenantColumn("et.tenant_id", r.tenantID)).
+		Where("t.week >= ? AND et.disabled_at IS NULL", since).
+		Order("t.topic_id, t.week, t.id DESC")
+	if area != "" {
+		latest = latest.Where("et.topic LIKE ?", "%"+likeEscaper.Replace(area)+"%")
+	}
+
+	q := r.db.Table("(?) AS m", latest)
+	if minScore > 0 {
+		// 最後に保存されたトレンドで判定するため、DISTINCT ONの後で絞り込む
+		q = q.Where("m.score >= ?", minScore)
+	}
+	var trends []FeedTrend
+	err := q.
+		Order("m.week DESC, m.score DESC, m.topic_id").
+		Limit(limit).
+		Scan(&trends).Error
+	return trends, err
+}
+
+// トピックのトレンドの推移(History)の集計の間隔
+const (
+	IntervalWeek  = "week"  // 週ごと(保存したトレンドそのまま)
+	IntervalMonth = "month" // 月ごとの平均
+)
+
+// HistoryIntervals はHistoryで指定できる集計の間隔です。
+var HistoryIntervals = []string{IntervalWeek, IntervalMonth}
+
+// TrendPoint はトピックのトレンドの推移の1点です。月ごとの点は、その月に週の始まる(月曜日がその月の)トレンドを集計します。
+type TrendPoint struct {
+	Period       time.Time // 週の月曜日、または月の初日
+	Score        float64   // 月ごとの点では平均
+	GPTScore     float64   // 月ごとの点では平均
+	MentionCount int       // 月ごとの点では合計
+	Weeks        int       // 集計したトレンドの週の数
+}
+
+// Historyはトピックのトレンドのスコアの推移を古い順に返します。intervalがIntervalMonthの場合は月ごとに平均します。
+// since・untilはゼロ値でなければトレンドの週の範囲です。テナントのトピックでない場合は空の推移を返します。
+func (r *TrendRepository) History(topicID uint, interval string, since, until time.Time) ([]TrendPoint, error) {
+	period := "t.week"
+	if interval == IntervalMonth {
+		period = "CAST(date_trunc('month', t.week) AS date)"
+	}
+	q := r.db.Table("topic_trends AS t").
+		Select(period+" AS period, AVG(t.score) AS score, AVG(t.gpt_score) AS gpt_score, "+
+			"SUM(t.mention_count) AS mention_count, COUNT(*) AS weeks").
+		Joins("JOIN entity_topics AS et ON et.id = t.topic_id").
+		Scopes(tenantColumn("et.tenant_id", r.tenantID)).
+		Where("t.topic_id = ?", topicID)
+	if !since.IsZero() {
+		q = q.Where("t.week >= ?", since)
+	}
+	if !until.IsZero() {
+		q = q.Where("t.week <= ?", until)
+	}
+	var points []TrendPoint
+	err := q.Group("period").Order("period").Scan(&points).Error
+	return points, err
+}
+
+// TrendExportRow はトピックとエンティティの名前を付けたトレンドの1行です。CSVの書き出しとトレンドの一覧で使います。
+type TrendExportRow struct {
+	ID           uint
+	TopicID      uint
+	Topic        string
+	EntityName   string
+	EntityType   string
+	Week         time.Time
+	Score        float64
+	GPTScore     float64
+	Delta        *float64
+	MentionCount int
+	TopTitle     string
+	CreatedAt    time.Time
+}
+
+// TrendExportFilter は書き出す・一覧するトレンドの条件です。ゼロ値の条件は絞り込みません。
+type TrendExportFilter struct {
+	Since      time.Time // この週以降
+	Until      time.Time // この週以前
+	TopicID    uint
+	EntityType string
+	MinScore   *float64 // スコアがこの値以上
+	MaxScore   *float64 // スコアがこの値以下
+}
+
+// TrendSortFields はトレンドの一覧(ListPage)で並べ替えられる項目です。
+var TrendSortFields = []string{"week", "score", "gpt_score", "mention_count", "created_at"}
+
+// trendSortKeys はTrendSortFieldsの項目の列と、cursorに持つ行の値です。
+var trendSortKeys = map[string]struct {
+	sortKey
+	value func(TrendExportRow) string
+}{
+	"week":          {sortKey{column: "t.week", cast: "date"}, func(r TrendExportRow) string { return r.Week.Format("2006-01-02") }},
+	"score":         {sortKey{column: "t.score", cast: "double precision"}, func(r TrendExportRow) string { return strconv.FormatFloat(r.Score, 'g', -1, 64) }},
+	"gpt_score":     {sortKey{column: "t.gpt_score", cast: "double precision"}, func(r TrendExportRow) string { return strconv.FormatFloat(r.GPTScore, 'g', -1, 64) }},
+	"mention_count": {sortKey{column: "t.mention_count", cast: "integer"}, func(r TrendExportRow) string { return strconv.Itoa(r.MentionCount) }},
+	"created_at":    {sortKey{column: "t.created_at", cast: "timestamptz"}, func(r TrendExportRow) string { return r.CreatedAt.UTC().Format(time.RFC3339Nano) }},
+}
+
+// defaultTrendSort はトレンドの一覧の既定の順(新しい週から順)です。
+var defaultTrendSort = []SortField{{Name: "week", Desc: true}}
+
+// Cursorはsortの順の一覧で、この行の次のページを読むcursorを返します。
+func (r TrendExportRow) Cursor(sort []SortField) Cursor {
+	if len(sort) == 0 {
+		sort = defaultTrendSort
+	}
+	c := Cursor{ID: r.ID, Values: make([]string, len(sort))}
+	for i, f := range sort {
+		c.Values[i] = trendSortKeys[f.Name].value(r)
+	}
+	return c
+}
+
+// ExportPageはfに一致するトレンドのうちIDがafterIDより大きいものをID順に最大limit件返します。
+// 前のページの最後のIDを渡して順に読むため、OFFSETと違って後ろのページでも読み飛ばす行が増えず、ページごとのクエリの時間は一定です。
+func (r *TrendRepository) ExportPage(f TrendExportFilter, afterID uint, limit int) ([]TrendExportRow, error) {
+	var rows []TrendExportRow
+	err := r.exportQuery(f).Where("t.id > ?", afterID).Order("t.id").Limit(limit).Scan(&rows).Error
+	return rows, err
+}
+
+// ListPageはfに一致するトレンドをsortの順(同じ値の行はIDの順)に、afterより後ろを最大limit件返します。
+// sortがnilの場合は新しい週から順です。afterには前のページの最後の行のCursor(sort)を渡し、
+// sortと項目の数が合わなければErrInvalidCursorを返します。既定の順は(week, id)の索引を降順に読むため、後ろのページでも遅くなりません。
+func (r *TrendRepository) ListPage(f TrendExportFilter, sort []SortField, after Cursor, limit int) ([]TrendExportRow, error) {
+	if len(sort) == 0 {
+		sort = defaultTrendSort
+	}
+	keys := make([]sortKey, len(sort))
+	for i, s := range sort {
+		keys[i] = trendSortKeys[s.Name].sortKey
+		keys[i].desc = s.Desc
+	}
+	q := r.exportQuery(f)
+	if !after.IsZero() {
+		if len(after.Values) != len(keys) {
+			return nil, ErrInvalidCursor
+		}
+		// 週はdate型のため、時刻を含む値と比べてタイムゾーンで日付がずれないよう、cursorの値は列の型に変換して比べる
+		cond, args := afterKeys(keys, after.Values, "t.id", after.ID)
+		q = q.Where(cond, args...)
+	}
+	var rows []TrendExportRow
+	err := q.Order(orderBy(keys, "t.id")).Limit(limit).Scan(&rows).Error
+	return rows, err
+}
+
+// exportQueryはfに一致するトレンドの書き出し・一覧の項目を読むクエリを返します。
+func (r *TrendRepository) exportQuery(f TrendExportFilter) *gorm.DB {
+	q := r.db.Table("topic_trends AS t").
+		Select("t.id, t.topic_id, et.topic, e.name AS entity_name, e.type AS entity_type, " +
+			"t.week, t.score, t.gpt_score, t.delta, t.mention_count, t.top_title, t.created_at").
+		Joins("JOIN entity_topics AS et ON et.id = t.topic_id").
+		Joins("JOIN entities AS e ON e.id = et.entity_id").
+		Scopes(tenantColumn("et.tenant_id", r.tenantID))
+	if !f.Since.IsZero() {
+		q = q.Where("t.week >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("t.week <= ?", f.Until)
+	}
+	if f.TopicID != 0 {
+		q = q.Where("t.topic_id = ?", f.TopicID)
+	}
+	if f.EntityType != "" {
+		q = q.Where("e.type = ?", f.EntityType)
+	}
+	if f.MinScore != nil {
+		q = q.Where("t.score >= ?", *f.MinScore)
+	}
+	if f.MaxScore != nil {
+		q = q.Where("t.score <= ?", *f.MaxScore)
+	}
+	return q
+}
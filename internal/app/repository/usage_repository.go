@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+)
+
+// UsageRepository はAPIキーごとのAPIの利用量を扱うリポジトリです。
+type UsageRepository struct {
+	db *gorm.DB
+}
+
+func NewUsageRepository(db *gorm.DB) *UsageRepository {
+	return &UsageRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *UsageRepository) WithContext(ctx context.Context) *UsageRepository {
+	return &UsageRepository{db: r.db.WithContext(ctx)}
+}
+
+// Recordは1件のリクエストの利用量をu.Dayの日(ローカル時刻)の集計に加えます。
+func (r *UsageRepository) Record(u model.APIUsage) error {
+	return r.db.Exec(`INSERT INTO api_usage (tenant_id, api_key_id, day, endpoint, requests, errors, request_bytes, response_bytes)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (tenant_id, api_key_id, day, endpoint) DO UPDATE SET
+    requests = api_usage.requests + EXCLUDED.requests,
+    errors = api_usage.errors + EXCLUDED.errors,
+    request_bytes = api_usage.request_bytes + EXCLUDED.request_bytes,
+    response_bytes = api_usage.response_bytes + EXCLUDED.response_bytes`,
+		u.TenantID, u.APIKeyID, u.Day.Format("2006-01-02"), u.Endpoint, u.Requests, u.Errors, u.RequestBytes, u.ResponseBytes).Error
+}
+
+// UsageFilter は利用量の集計の条件です。Since、Untilは日付で、両端を含みます。TenantIDが0、APIKeyIDがnilの場合は絞り込みません。
+type UsageFilter struct {
+	Since    time.Time
+	Until    time.Time
+	TenantID uint
+	APIKeyID *uint // 0はAPIキーなしのリクエスト
+}
+
+// UsageRow は期間内のAPIキーとエンドポイントごとの利用量です。
+type UsageRow struct {
+	TenantID      uint   `json:"tenant_id"`
+	TenantSlug    string `json:"tenant"`
+	APIKeyID      uint   `json:"api_key_id"`
+	APIKeyName    string `json:"api_key_name,omitempty"`
+	APIKeyPrefix  string `json:"api_key_prefix,omitempty"`
+	Endpoint      string `json:"endpoint"`
+	Requests      int64  `json:"requests"`
+	Errors        int64  `json:"errors"`
+	RequestBytes  int64  `json:"request_bytes"`
+	ResponseBytes int64  `json:"response_bytes"`
+}
+
+// Reportは期間内の利用量をテナント、APIキー、エンドポイントごとに合計し、リクエスト数の多い順に返します。
+func (r *UsageRepository) Report(f UsageFilter) ([]UsageRow, error) {
+	q := r.db.Table("api_usage AS u").
+		Select("u.tenant_id, t.slug AS tenant_slug, u.api_key_id, k.name AS api_key_name, k.prefix AS api_key_prefix, u.endpoint, "+
+			"SUM(u.requests) AS requests, SUM(u.errors) AS errors, SUM(u.request_bytes) AS request_bytes, SUM(u.response_bytes) AS response_bytes").
+		Joins("JOIN tenants AS t ON t.id = u.tenant_id").
+		Joins("LEFT JOIN api_keys AS k ON k.id = u.api_key_id").
+		Where("u.day BETWEEN ? AND ?", f.Since.Format("2006-01-02"), f.Until.Format("2006-01-02")).
+		Group("u.tenant_id, t.slug, u.api_key_id, k.name, k.prefix, u.endpoint").
+		Order("requests DESC, u.tenant_id, u.api_key_id, u.endpoint")
+	if f.TenantID != 0 {
+		q = q.Where("u.tenant_id = ?", f.TenantID)
+	}
+	if f.APIKeyID != nil {
+		q = q.Where("u.api_key_id = ?", *f.APIKeyID)
+	}
+	var rows []UsageRow
+	err := q.Scan(&rows).Error
+	return rows, err
+}
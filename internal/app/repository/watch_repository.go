@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+)
+
+// WatchRepository はウォッチ(閾値アラート)を扱うリポジトリです。
+type WatchRepository struct {
+	db       *gorm.DB
+	tenantID uint // 0の場合はすべてのテナントが対象
+}
+
+func NewWatchRepository(db *gorm.DB) *WatchRepository {
+	return &WatchRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *WatchRepository) WithContext(ctx context.Context) *WatchRepository {
+	return &WatchRepository{db: r.db.WithContext(ctx), tenantID: r.tenantID}
+}
+
+// ForTenantはテナントtenantIDのウォッチのみを扱うリポジトリを返します。
+func (r *WatchRepository) ForTenant(tenantID uint) *WatchRepository {
+	return &WatchRepository{db: r.db, tenantID: tenantID}
+}
+
+func (r *WatchRepository) Create(watch *model.Watch) error {
+	return r.db.Create(watch).Error
+}
+
+// Listはウォッチの一覧を返します。subscriberが空でなければその通知先のウォッチのみ返します。
+// afterが先頭のページでなければ、afterのウォッチ(前のページの最後のウォッチのID)より後ろを最大limit件返します。
+func (r *WatchRepository) List(subscriber string, after Cursor, limit int) ([]model.Watch, error) {
+	var watches []model.Watch
+	q := r.db.Scopes(topicsOfTenant(r.tenantID), afterID("id", after)).Order("id").Limit(limit)
+	if subscriber != "" {
+		q = q.Where("subscriber = ?", subscriber)
+	}
+	err := q.Find(&watches).Error
+	return watches, err
+}
+
+// ListByTopicは指定トピックに設定されたウォッチを返します。
+func (r *WatchRepository) ListByTopic(topicID uint) ([]model.Watch, error) {
+	var watches []model.Watch
+	err := r.db.Where("topic_id = ?", topicID).Order("id").Find(&watches).Error
+	return watches, err
+}
+
+// Deleteはウォッチを削除します。該当するウォッチがなければgorm.ErrRecordNotFoundを返します。
+func (r *WatchRepository) Delete(id uint) error {
+	result := r.db.Scopes(topicsOfTenant(r.tenantID)).Delete(&model.Watch{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkNotifiedはウォッチをweekの週に通知済みとして記録します。
+func (r *WatchRepository) MarkNotified(id uint, week time.Time) error {
+	return r.db.Model(&model.Watch{}).Where("id = ?", id).Update("last_notified_week", week).Error
+}
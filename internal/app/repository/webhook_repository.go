@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookRepository はWebhookとその配送を扱うリポジトリです。
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// WithContextはctxを引き継いでクエリを発行するリポジトリを返します。リクエストのキャンセルやトレースをクエリに伝播するために使います。
+func (r *WebhookRepository) WithContext(ctx context.Context) *WebhookRepository {
+	return &WebhookRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *WebhookRepository) Create(webhook *model.Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+// Getは指定IDのWebhookを返します。該当するWebhookがなければgorm.ErrRecordNotFoundを返します。
+func (r *WebhookRepository) Get(id uint) (*model.Webhook, error) {
+	var webhook model.Webhook
+	if err := r.db.Take(&webhook, id).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// ListはWebhookをID順に返します。
+// afterが先頭のページでなければ、afterのWebhook(前のページの最後のWebhookのID)より後ろを最大limit件返します。
+func (r *WebhookRepository) List(after Cursor, limit int) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	err := r.db.Scopes(afterID("id", after)).Order("id").Limit(limit).Find(&webhooks).Error
+	return webhooks, err
+}
+
+// ListByEventはeventを購読しているWebhookを返します。
+func (r *WebhookRepository) ListByEvent(event string) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	err := r.db.Where("? = ANY(events)", event).Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// DeleteはWebhookとその配送を削除します。該当するWebhookがなければgorm.ErrRecordNotFoundを返します。
+func (r *WebhookRepository) Delete(id uint) error {
+	result := r.db.Delete(&model.Webhook{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Enqueueは配送を登録します。
+func (r *WebhookRepository) Enqueue(deliveries []model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Create(&deliveries).Error
+}
+
+// ClaimDueは配送時刻を過ぎた未配送の配送を最大limit件取り出し、次の配送時刻をnow+leaseに延ばします。
+// 複数のプロセスが同時に呼び出しても同じ配送を取り出さないよう、行をロックして他のプロセスがロック中の行は飛ばします。
+// 配送の結果を保存する前にプロセスが止まった場合は、leaseの後に再び取り出されます。
+func (r *WebhookRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.DeliveryPending, now).
+			Order("next_attempt_at, id").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		ids := make([]uint, len(deliveries))
+		for i, d := range deliveries {
+			ids[i] = d.ID
+		}
+		return tx.Model(&model.WebhookDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return deliveries, err
+}
+
+// SaveAttemptは配送の試行の結果を保存します。
+func (r *WebhookRepository) SaveAttempt(d *model.WebhookDelivery) error {
+	return r.db.Model(d).Select(
+		"status", "attempts", "next_attempt_at", "response_status", "error", "delivered_at", "updated_at",
+	).Updates(d).Error
+}
+
+// ListDeliveriesはWebhookの配送を新しい順に返します。statusが空でなければその状態の配送のみ返します。
+// afterが先頭のページでなければ、afterの配送(前のページの最後の配送の作成日時とID)より後ろを返します。
+func (r *WebhookRepository) ListDeliveries(webhookID uint, status string, after Cursor, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	q := r.db.Where("webhook_id = ?", webhookID).Scopes(before("created_at", "id", after)).Order("created_at DESC, id DESC").Limit(limit)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Find(&deliveries).Error
+	return deliveries, err
+}
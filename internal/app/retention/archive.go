@@ -0,0 +1,89 @@
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"excavation_service/internal/app/objectstore"
+)
+
+// Archiver は削除前の行をコールドストレージへ書き出します。
+type Archiver interface {
+	Archive(ctx context.Context, table string, batch int, rows []map[string]interface{}) error
+}
+
+// archiveContentTypeはアーカイブ(gzip圧縮したJSON Lines)のContent-Typeです。
+const archiveContentType = "application/gzip"
+
+// archiveNameはtableのbatch番目のバッチのアーカイブのファイル名を返します。
+func archiveName(table, runAt string, batch int) string {
+	return fmt.Sprintf("%s_%s_%04d.jsonl.gz", table, runAt, batch)
+}
+
+// writeRowsはrowsをgzip圧縮したJSON Lines形式でwに書き出します。
+func writeRows(w io.Writer, rows []map[string]interface{}) error {
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			zw.Close()
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// FileArchiver は行をgzip圧縮したJSON Lines形式で<Dir>/<テーブル名>/以下に書き出します。
+// コンテナのローカルディスクは再起動で消えるため、EXPORT_BUCKETを設定できない環境で、
+// Dirにオブジェクトストレージをマウントしたパスなどを指定する場合に使います。
+type FileArchiver struct {
+	Dir   string
+	runAt string
+}
+
+func NewFileArchiver(dir string, now time.Time) *FileArchiver {
+	return &FileArchiver{Dir: dir, runAt: now.UTC().Format("20060102T150405Z")}
+}
+
+func (a *FileArchiver) Archive(ctx context.Context, table string, batch int, rows []map[string]interface{}) error {
+	dir := filepath.Join(a.Dir, table)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, archiveName(table, a.runAt, batch)))
+	if err != nil {
+		return err
+	}
+	if err := writeRows(f, rows); err != nil {
+		f.Close()
+		return err
+	}
+	// 書き出しが完了していないアーカイブを残したまま行を削除しないよう、Closeのエラーも返す
+	return f.Close()
+}
+
+// StoreArchiver は行をgzip圧縮したJSON Lines形式でオブジェクトストレージのarchive/<テーブル名>/以下に書き出します。
+// EXPORT_BUCKET設定時に、エクスポートと同じバケット・接頭辞へアーカイブするために使います。
+type StoreArchiver struct {
+	store objectstore.Store
+	runAt string
+}
+
+func NewStoreArchiver(store objectstore.Store, now time.Time) *StoreArchiver {
+	return &StoreArchiver{store: store, runAt: now.UTC().Format("20060102T150405Z")}
+}
+
+func (a *StoreArchiver) Archive(ctx context.Context, table string, batch int, rows []map[string]interface{}) error {
+	var buf bytes.Buffer
+	if err := writeRows(&buf, rows); err != nil {
+		return err
+	}
+	return a.store.Put(ctx, "archive/"+table+"/"+archiveName(table, a.runAt, batch), buf.Bytes(), archiveContentType)
+}
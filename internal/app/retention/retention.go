@@ -0,0 +1,116 @@
+// Package retention は保持期間を過ぎた行をアーカイブへ書き出してから削除する処理を提供します。
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// batchSize は1回のアーカイブ・削除で扱う行数です。
+const batchSize = 1000
+
+// Policy はテーブルごとの保持期間です。MaxAgeが0のテーブルは無期限に保持します。
+type Policy struct {
+	Table      string
+	TimeColumn string
+	MaxAge     time.Duration
+}
+
+// Policiesはテーブルごとの保持日数(0で無期限)からPolicyの一覧を返します。ここにないテーブルは削除の対象になりません。
+func Policies(storeMentionsDays, topicTrendsDays int) []Policy {
+	return []Policy{
+		{Table: "store_mentions", TimeColumn: "created_at", MaxAge: days(storeMentionsDays)},
+		{Table: "topic_trends", TimeColumn: "created_at", MaxAge: days(topicTrendsDays)},
+	}
+}
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
+
+// Result はテーブルごとの実行結果です。
+type Result struct {
+	Table    string
+	Cutoff   time.Time
+	Expired  int // Previewで数えた保持期間切れの行数
+	Archived int
+	Deleted  int
+}
+
+// Previewは各Policyで保持期間を過ぎている行を数えます。行のアーカイブや削除は行いません。
+func Preview(ctx context.Context, db *gorm.DB, policies []Policy, now time.Time) ([]Result, error) {
+	var results []Result
+	for _, p := range policies {
+		if p.MaxAge <= 0 {
+			continue
+		}
+		res := Result{Table: p.Table, Cutoff: now.Add(-p.MaxAge)}
+		var n int64
+		if err := db.WithContext(ctx).Table(p.Table).Where(p.TimeColumn+" < ?", res.Cutoff).Count(&n).Error; err != nil {
+			return results, fmt.Errorf("%s: count expired rows: %w", p.Table, err)
+		}
+		res.Expired = int(n)
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// Runは各Policyについて、保持期間を過ぎた行をarchiverに書き出してから削除します。
+// アーカイブに失敗したバッチは削除しません。
+func Run(ctx context.Context, db *gorm.DB, archiver Archiver, policies []Policy, now time.Time) ([]Result, error) {
+	var results []Result
+	for _, p := range policies {
+		if p.MaxAge <= 0 {
+			slog.Info("無期限保持のためスキップ", "table", p.Table)
+			continue
+		}
+		res, err := runPolicy(ctx, db, archiver, p, now)
+		results = append(results, res)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", p.Table, err)
+		}
+		slog.Info("保持期間切れデータを削除しました", "table", p.Table, "cutoff", res.Cutoff, "archived", res.Archived, "deleted", res.Deleted)
+	}
+	return results, nil
+}
+
+func runPolicy(ctx context.Context, db *gorm.DB, archiver Archiver, p Policy, now time.Time) (Result, error) {
+	res := Result{Table: p.Table, Cutoff: now.Add(-p.MaxAge)}
+	for batch := 0; ; batch++ {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+
+		var rows []map[string]interface{}
+		err := db.WithContext(ctx).Table(p.Table).
+			Where(p.TimeColumn+" < ?", res.Cutoff).
+			Order("id").
+			Limit(batchSize).
+			Find(&rows).Error
+		if err != nil {
+			return res, fmt.Errorf("select expired rows: %w", err)
+		}
+		if len(rows) == 0 {
+			return res, nil
+		}
+
+		if err := archiver.Archive(ctx, p.Table, batch, rows); err != nil {
+			return res, fmt.Errorf("archive: %w", err)
+		}
+		res.Archived += len(rows)
+
+		ids := make([]interface{}, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row["id"])
+		}
+		result := db.WithContext(ctx).Exec("DELETE FROM "+p.Table+" WHERE id IN ?", ids)
+		if result.Error != nil {
+			return res, fmt.Errorf("delete archived rows: %w", result.Error)
+		}
+		res.Deleted += int(result.RowsAffected)
+	}
+}
@@ -0,0 +1,154 @@
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/testdb"
+
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
+}
+
+// recordArchiver は書き出した行数をバッチごとに記録します。failBatchのバッチではerrを返します。
+type recordArchiver struct {
+	batches   []int
+	failBatch int
+	err       error
+}
+
+func (a *recordArchiver) Archive(_ context.Context, _ string, batch int, rows []map[string]interface{}) error {
+	if a.err != nil && batch == a.failBatch {
+		return a.err
+	}
+	a.batches = append(a.batches, len(rows))
+	return nil
+}
+
+// seedTrendsはトピックにold件の作成日時がcutoffより前のトレンドと、recent件のcutoff以降のトレンドを作成します。
+func seedTrends(t *testing.T, db *gorm.DB, cutoff time.Time, old, recent int) {
+	t.Helper()
+	topic := testdb.Topic(t, db, "カレー店", "カレー")
+	insert := `INSERT INTO topic_trends (topic_id, week, score, run_id, created_at, updated_at)
+		SELECT ?, DATE '2000-01-03' + (? + i) * 7, 50, 'test', ?, ?
+		FROM generate_series(0, ? - 1) AS i`
+	if err := db.Exec(insert, topic.ID, 0, cutoff.Add(-time.Hour), cutoff.Add(-time.Hour), old).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(insert, topic.ID, old, cutoff, cutoff, recent).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func countTrends(t *testing.T, db *gorm.DB, where string, args ...interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Table("topic_trends").Where(where, args...).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// TestRunはbatchSizeを超える行をバッチに分けて書き出してから削除し、保持期間内の行を残して終わることを確認します。
+func TestRun(t *testing.T) {
+	db := testdb.Postgres(t)
+	now := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	policies := Policies(0, 30)
+	cutoff := now.Add(-policies[1].MaxAge)
+	old := 2*batchSize + 5
+	seedTrends(t, db, cutoff, old, 3)
+
+	archiver := &recordArchiver{}
+	results, err := Run(context.Background(), db, archiver, policies, now)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Archived != old || results[0].Deleted != old {
+		t.Errorf("results = %+v, want %d archived and deleted from topic_trends", results, old)
+	}
+	if want := []int{batchSize, batchSize, 5}; !slices.Equal(archiver.batches, want) {
+		t.Errorf("archived batches = %v, want %v", archiver.batches, want)
+	}
+	if n := countTrends(t, db, "created_at < ?", cutoff); n != 0 {
+		t.Errorf("%d expired rows left, want 0", n)
+	}
+	if n := countTrends(t, db, "created_at >= ?", cutoff); n != 3 {
+		t.Errorf("%d rows within the retention period left, want 3", n)
+	}
+}
+
+// TestRunArchiveFailureはアーカイブに失敗したバッチの行を削除しないことを確認します。
+func TestRunArchiveFailure(t *testing.T) {
+	db := testdb.Postgres(t)
+	now := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	policies := Policies(0, 30)
+	cutoff := now.Add(-policies[1].MaxAge)
+	seedTrends(t, db, cutoff, batchSize+5, 3)
+
+	// 1バッチ目は書き出して削除し、2バッチ目の書き出しで失敗する
+	errArchive := errors.New("bucket unavailable")
+	archiver := &recordArchiver{failBatch: 1, err: errArchive}
+	results, err := Run(context.Background(), db, archiver, policies, now)
+	if !errors.Is(err, errArchive) {
+		t.Fatalf("Run() error = %v, want the archive error", err)
+	}
+	if len(results) != 1 || results[0].Archived != batchSize || results[0].Deleted != batchSize {
+		t.Errorf("results = %+v, want only the first batch archived and deleted", results)
+	}
+	if n := countTrends(t, db, "created_at < ?", cutoff); n != 5 {
+		t.Errorf("%d expired rows left, want the 5 rows of the failed batch", n)
+	}
+	if n := countTrends(t, db, "created_at >= ?", cutoff); n != 3 {
+		t.Errorf("%d rows within the retention period left, want 3", n)
+	}
+}
+
+// memoryStoreは書き込んだオブジェクトをキーごとに保持します。
+type memoryStore map[string][]byte
+
+func (s memoryStore) Put(_ context.Context, key string, body []byte, _ string) error {
+	s[key] = body
+	return nil
+}
+
+// TestStoreArchiverはバッチをgzip圧縮したJSON Linesとしてarchive/<テーブル名>/以下に書き出すことを確認します。
+func TestStoreArchiver(t *testing.T) {
+	store := memoryStore{}
+	archiver := NewStoreArchiver(store, time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC))
+	rows := []map[string]interface{}{{"id": 1.0}, {"id": 2.0}}
+	if err := archiver.Archive(context.Background(), "topic_trends", 3, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	body, ok := store["archive/topic_trends/topic_trends_20261012T090000Z_0003.jsonl.gz"]
+	if !ok {
+		t.Fatalf("keys = %v, want the batch under archive/topic_trends/", slices.Sorted(maps.Keys(store)))
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	for dec := json.NewDecoder(zr); dec.More(); {
+		var row map[string]interface{}
+		if err := dec.Decode(&row); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, row)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("archived rows = %v, want %v", got, rows)
+	}
+}
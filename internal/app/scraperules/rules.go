@@ -0,0 +1,155 @@
+// Package scraperules は食べログのページ解析に使うセレクタや除外パターンを管理します。
+// ルールはYAMLファイルで上書きでき、実行中のプロセスでもファイルの変更を検知して再読み込みするため、
+// 食べログのHTMLが変わった場合でも長時間のクロールを止めずに修正できます。
+//
+// ファイルに書いた項目だけが既定値を上書きします。
+//
+//	matome_selector: ".shop-list__item a"
+//	excluded_paths: ["/dtlrvwlst/", "/rvwr/"]
+//	excluded_names: ["食べログ太郎"]
+//	languages: ["ja", "en"]
+package scraperules
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/andybalholm/cascadia"
+	"gopkg.in/yaml.v3"
+)
+
+// Rules はページ解析のルールです。読み込み後は変更せず、更新時は新しいRulesに置き換えます。
+type Rules struct {
+	MatomeSelector   string   `yaml:"matome_selector"`    // まとめ記事の店舗リンク
+	ListingSelector  string   `yaml:"listing_selector"`   // リストページの店舗リンク
+	AddressSelector  string   `yaml:"address_selector"`   // 店舗ページの住所
+	ExcludedPaths    []string `yaml:"excluded_paths"`     // 店舗ページとみなさないパス(正規表現)
+	StorePagePattern string   `yaml:"store_page_pattern"` // 店舗ページのURL(正規表現)
+	ExcludedNames    []string `yaml:"excluded_names"`     // 店舗名から除去するレビュアー名など
+	Languages        []string `yaml:"languages"`          // 解析するページの言語。空の場合は言語で除外しない
+
+	excludedPaths []*regexp.Regexp
+	storePage     *regexp.Regexp
+}
+
+// Defaultは既定のルールを返します。
+func Default() *Rules {
+	r, err := defaults().compile()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func defaults() *Rules {
+	return &Rules{
+		MatomeSelector:  ".shop-list__item a, .summary-shop__title a, a[href*='tabelog.com'][class*='js-spot-link']",
+		ListingSelector: ".list-rst__title a, .list-rst__wrap a, a.list-rst__rst-name-target",
+		AddressSelector: ".rstinfo-table__address",
+		ExcludedPaths: []string{
+			`/dtlrvwlst/`,   // レビューリストページ
+			`/rvwr/`,        // レビュアーページ
+			`/user/`,        // ユーザーページ
+			`/member/`,      // 会員ページ
+			`/review/`,      // 個別の口コミページ (例: /<store_id>/review/<review_id>/)
+			`/diary/`,       // 日記ページ
+			`/photo/`,       // 写真ページ (例: /<store_id>/photo/)
+			`/dtlphotolst/`, // 写真リストページ
+			`/dtlmenu/`,     // メニュー詳細ページ
+			`/dtlmap/`,      // 詳細マップページ
+			`/help/`,        // ヘルプページ
+			`/terms/`,       // 利用規約
+			`/sitemap/`,     // サイトマップ
+			`/rstLst/`,      // 店舗リストページ (検索結果など)
+			`/word/`,        // 用語解説など
+			`/cond/`,        // 条件検索ページ
+			`/catLst/`,      // カテゴリーリストページ
+			`/aream/`,       // エリアマップページ
+			`/wiki/`,        // Wikiページ
+			`/favorite/`,    // お気に入りページ
+			`/lunch/`,       // ランチ特集など、個別の店舗ページではないもの
+			`/dinner/`,      // ディナー特集など
+			`/party/`,       // パーティー特集など
+			`/matome/`,      // まとめ記事
+		},
+		// 店舗ページURLの典型的なパターン
+		// 例: https://tabelog.com/tokyo/A1311/A131105/13034566/
+		//
+		// tabelog\.com/           : ドメイン
+		// [a-z]{2,8}/             : 都道府県コード (例: tokyo, osaka, fukuokaなど)
+		// A\d{3,4}/               : 広域エリアコード (例: A1311)
+		// A\d{3,6}/               : 詳細エリアコード (例: A131105)
+		// (\d{8}|\d{10})/?$       : 8桁または10桁の店舗ID。末尾にスラッシュが有っても無くてもOK、URLの末尾であること
+		StorePagePattern: `tabelog\.com/[a-z]{2,8}/A\d{3,4}/A\d{3,6}/(\d{8}|\d{10})/?$`,
+		ExcludedNames: []string{
+			"稲毛屋", "maro-j", "はらぺこ大将", "アユボワン！", "komedarian", "ものごころ", "nobuta-nobu", "ramen-king",
+			"グルマン", "食いしん坊", "食べログ太郎", "レビュアー", "レビュアーマスター", "美食家", "グルメキング",
+			"グルメ探偵", "食べログレビュアー", "アユボワン",
+			"honnesan", "taniy", "dragonfly8810", "ropefish", "吉田R", "Wine, women an' song", "Shoebill",
+			"クスクス", "トカトントンガラシ", "びしくれた", "おもひで定食", "たけ1025", "ヘル", "たらく 日暮里店",
+			"ノブヒロ＠上野", "養和軒", "イドカヤ７９７", "シルクロード", "たけとんたんた", "カレーおじさん＼／", "ゆすけ",
+			"玄海寿司 本店", "南幌",
+		},
+		// 英語・中国語などのミラーページは店舗名の抽出を汚すため、日本語のページのみ解析する
+		Languages: []string{"ja"},
+	}
+}
+
+// Parseは既定のルールをYAMLで上書きしたルールを返します。セレクタや正規表現が不正な場合はエラーを返します。
+func Parse(b []byte) (*Rules, error) {
+	r := defaults()
+	if err := yaml.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("parse scrape rules: %w", err)
+	}
+	return r.compile()
+}
+
+func (r *Rules) compile() (*Rules, error) {
+	for _, sel := range []string{r.MatomeSelector, r.ListingSelector, r.AddressSelector} {
+		if _, err := cascadia.ParseGroup(sel); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", sel, err)
+		}
+	}
+	r.excludedPaths = make([]*regexp.Regexp, 0, len(r.ExcludedPaths))
+	for _, p := range r.ExcludedPaths {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid excluded path %q: %w", p, err)
+		}
+		r.excludedPaths = append(r.excludedPaths, re)
+	}
+	re, err := regexp.Compile(r.StorePagePattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store page pattern %q: %w", r.StorePagePattern, err)
+	}
+	r.storePage = re
+	return r, nil
+}
+
+// ExcludedPathは除外パターンに一致したパスのパターンを返します。一致しなければ空文字列です。
+func (r *Rules) ExcludedPath(path string) string {
+	for _, re := range r.excludedPaths {
+		if re.MatchString(path) {
+			return re.String()
+		}
+	}
+	return ""
+}
+
+// MatchStorePageはURLが店舗ページのパターンに一致するかを返します。
+func (r *Rules) MatchStorePage(rawURL string) bool {
+	return r.storePage.MatchString(rawURL)
+}
+
+// AllowsLanguageはlangのページを解析するかを返します。言語を判定できなかったページ(lang == "")は解析します。
+func (r *Rules) AllowsLanguage(lang string) bool {
+	if lang == "" || len(r.Languages) == 0 {
+		return true
+	}
+	for _, l := range r.Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
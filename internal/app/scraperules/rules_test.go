@@ -0,0 +1,66 @@
+package scraperules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseOverridesDefaults(t *testing.T) {
+	r, err := Parse([]byte("matome_selector: \".new-shop a\"\nexcluded_paths: [\"/course/\"]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.MatomeSelector != ".new-shop a" {
+		t.Errorf("MatomeSelector = %q", r.MatomeSelector)
+	}
+	if r.ListingSelector != Default().ListingSelector {
+		t.Errorf("ListingSelector should keep the default, got %q", r.ListingSelector)
+	}
+	if r.ExcludedPath("/tokyo/A1311/A131105/13034566/course/") == "" {
+		t.Error("/course/ should be excluded")
+	}
+	if r.ExcludedPath("/tokyo/A1311/A131105/13034566/dtlrvwlst/") != "" {
+		t.Error("excluded_paths should replace the default list")
+	}
+	if !r.MatchStorePage("https://tabelog.com/tokyo/A1311/A131105/13034566/") {
+		t.Error("default store page pattern should still match")
+	}
+}
+
+func TestLoadKeepsRulesOnInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte("listing_selector: \".rst a\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore()
+	if err := s.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("store_page_pattern: \"(\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Load(path); err == nil {
+		t.Fatal("Load should fail for an invalid pattern")
+	}
+	if got := s.Current().ListingSelector; got != ".rst a" {
+		t.Errorf("ListingSelector = %q, want the previously loaded rules", got)
+	}
+}
+
+func TestAllowsLanguage(t *testing.T) {
+	r := Default()
+	if !r.AllowsLanguage("ja") || !r.AllowsLanguage("") {
+		t.Error("ja and undetected pages should be allowed by default")
+	}
+	if r.AllowsLanguage("en") {
+		t.Error("en should be skipped by default")
+	}
+	r, err := Parse([]byte("languages: []\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.AllowsLanguage("zh") {
+		t.Error("empty languages should disable the filter")
+	}
+}
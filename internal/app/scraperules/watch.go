@@ -0,0 +1,75 @@
+package scraperules
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Store は現在のルールを保持します。ルールの読み込み・置き換えは並行して行っても安全です。
+type Store struct {
+	current atomic.Pointer[Rules]
+	modTime time.Time
+}
+
+// NewStoreは既定のルールを保持するStoreを作成します。
+func NewStore() *Store {
+	s := &Store{}
+	s.current.Store(Default())
+	return s
+}
+
+// Currentは現在のルールを返します。1ページの解析中は同じルールを使うため、ページごとに1回呼び出してください。
+func (s *Store) Current() *Rules {
+	return s.current.Load()
+}
+
+// Loadはpathのファイルからルールを読み込んで置き換えます。不正なファイルの場合は現在のルールを維持してエラーを返します。
+func (s *Store) Load(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	r, err := Parse(b)
+	if err != nil {
+		return err
+	}
+	s.current.Store(r)
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// Watchはctxがキャンセルされるまでintervalごとにpathの更新日時を確認し、変更されていればルールを再読み込みします。
+// 再読み込みに失敗した場合は直前のルールを使い続けます。
+func (s *Store) Watch(ctx context.Context, logger *slog.Logger, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			logger.Error("解析ルールのファイルを確認できません", "path", path, "error", err)
+			continue
+		}
+		if info.ModTime().Equal(s.modTime) {
+			continue
+		}
+		if err := s.Load(path); err != nil {
+			logger.Error("解析ルールの再読み込みに失敗しました。直前のルールを使い続けます", "path", path, "error", err)
+			s.modTime = info.ModTime() // 同じ内容で何度もエラーを出さない
+			continue
+		}
+		logger.Info("解析ルールを再読み込みしました", "path", path)
+	}
+}
@@ -0,0 +1,102 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tracing"
+)
+
+// Meilisearch はMeilisearchのインデックスに店舗を登録して検索するStoreIndexです。
+// 登録はMeilisearch側で非同期に処理されるため、IndexStoresが返った直後の検索には反映されていないことがあります。
+type Meilisearch struct {
+	baseURL string
+	apiKey  string
+	index   string
+	client  *http.Client
+}
+
+func NewMeilisearch(baseURL, apiKey, index string) *Meilisearch {
+	return &Meilisearch{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		index:   index,
+		client:  tracing.NewHTTPClient(10 * time.Second),
+	}
+}
+
+// meilisearchSettings はインデックスの設定です。店舗名を最も重視し、言及の多い店舗を上位にします。
+var meilisearchSettings = map[string]interface{}{
+	"searchableAttributes": []string{"name", "topics", "url"},
+	"sortableAttributes":   []string{"mentions"},
+	"rankingRules":         []string{"words", "typo", "proximity", "attribute", "sort", "exactness", "mentions:desc"},
+}
+
+// Configureはインデックスの検索の設定を更新します。インデックスがなければ作成されます。
+func (m *Meilisearch) Configure(ctx context.Context) error {
+	return m.call(ctx, http.MethodPatch, "/settings", meilisearchSettings, nil)
+}
+
+func (m *Meilisearch) IndexStores(ctx context.Context, stores []repository.StoreSummary) error {
+	return m.call(ctx, http.MethodPost, "/documents?primaryKey=id", stores, nil)
+}
+
+// ClearStoresはインデックスのすべての店舗を削除します。
+func (m *Meilisearch) ClearStores(ctx context.Context) error {
+	return m.call(ctx, http.MethodDelete, "/documents", nil, nil)
+}
+
+func (m *Meilisearch) SearchStores(ctx context.Context, query string, limit int) ([]repository.StoreSummary, error) {
+	var resp struct {
+		Hits []repository.StoreSummary `json:"hits"`
+	}
+	if err := m.call(ctx, http.MethodPost, "/search", map[string]interface{}{"q": query, "limit": limit}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Hits, nil
+}
+
+// callはインデックスのAPI(pathはインデックスのURLからの相対パス)をbody(nilなら本文なし)で呼び出し、応答をout(nilなら読み捨てる)に読み込みます。
+func (m *Meilisearch) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	endpoint := m.baseURL + "/indexes/" + url.PathEscape(m.index) + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if m.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("meilisearch %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("meilisearch %s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
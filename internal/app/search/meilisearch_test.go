@@ -0,0 +1,68 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"excavation_service/internal/app/repository"
+)
+
+func TestMeilisearch(t *testing.T) {
+	var indexed []repository.StoreSummary
+	cleared := false
+	var query map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.RequestURI() {
+		case "POST /indexes/stores/documents?primaryKey=id":
+			json.NewDecoder(r.Body).Decode(&indexed)
+			w.WriteHeader(http.StatusAccepted)
+			io.WriteString(w, `{"taskUid":1}`)
+		case "DELETE /indexes/stores/documents":
+			cleared = r.ContentLength <= 0
+			w.WriteHeader(http.StatusAccepted)
+			io.WriteString(w, `{"taskUid":2}`)
+		case "POST /indexes/stores/search":
+			json.NewDecoder(r.Body).Decode(&query)
+			io.WriteString(w, `{"hits":[{"id":3,"name":"スパイス食堂 ほし","url":"https://tabelog.com/3","topics":["西日暮里 カレー"],"mentions":4}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	m := NewMeilisearch(srv.URL+"/", "key", "stores")
+	stores := []repository.StoreSummary{{ID: 3, Name: "スパイス食堂 ほし", URL: "https://tabelog.com/3", Topics: []string{"西日暮里 カレー"}, Mentions: 4}}
+	if err := m.IndexStores(context.Background(), stores); err != nil {
+		t.Fatal(err)
+	}
+	if len(indexed) != 1 || indexed[0].ID != 3 || indexed[0].Topics[0] != "西日暮里 カレー" {
+		t.Errorf("indexed = %+v", indexed)
+	}
+
+	hits, err := m.SearchStores(context.Background(), "すぱいす", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query["q"] != "すぱいす" || query["limit"] != float64(5) {
+		t.Errorf("query = %v", query)
+	}
+	if len(hits) != 1 || hits[0].Name != "スパイス食堂 ほし" || hits[0].Mentions != 4 {
+		t.Errorf("hits = %+v", hits)
+	}
+
+	if err := m.ClearStores(context.Background()); err != nil || !cleared {
+		t.Errorf("ClearStores() = %v, cleared = %v", err, cleared)
+	}
+
+	if err := NewMeilisearch(srv.URL, "wrong", "stores").IndexStores(context.Background(), stores); err == nil {
+		t.Error("expected error for unauthorized request")
+	}
+}
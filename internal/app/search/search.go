@@ -0,0 +1,88 @@
+// Package search は店舗の全文検索を提供します。
+//
+// Meilisearchを設定した場合は、発掘の実行のたびに言及のあった店舗をインデックスに登録し、表記ゆれや入力の誤りに強い検索を行います。
+// 設定しない場合はPostgreSQLの部分一致(ILIKE)で検索します。
+// 店舗のジャンルや紹介文はまだ収集していないため、インデックスには店舗名・URL・言及していたトピック(エリアを含む)・言及の数のみ登録します。
+// インデックスには既定のテナントのトピックで言及された店舗のみ登録します。他のテナントの店舗はAPIでテナントごとにPostgreSQLで検索します。
+package search
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+
+	"gorm.io/gorm"
+)
+
+// reindexBatchSize はインデックスを作り直すときに1回で登録する店舗の数です。
+const reindexBatchSize = 1000
+
+// StoreSearcher は既定のテナントのトピックで言及された店舗を検索する実装が満たすインターフェースです。
+type StoreSearcher interface {
+	SearchStores(ctx context.Context, query string, limit int) ([]repository.StoreSummary, error)
+}
+
+// StoreIndex は店舗を登録して検索できるインデックスです。
+type StoreIndex interface {
+	StoreSearcher
+	IndexStores(ctx context.Context, stores []repository.StoreSummary) error
+	ClearStores(ctx context.Context) error
+}
+
+// Postgres はPostgreSQLの部分一致で店舗を検索するStoreSearcherです。
+type Postgres struct {
+	repo *repository.StoreRepository
+}
+
+func NewPostgres(db *gorm.DB) *Postgres {
+	return &Postgres{repo: repository.NewStoreRepository(db)}
+}
+
+func (p *Postgres) SearchStores(ctx context.Context, query string, limit int) ([]repository.StoreSummary, error) {
+	return p.repo.WithContext(ctx).SearchSummaries(model.DefaultTenantID, query, limit)
+}
+
+// IndexRunは実行runIDで言及を記録した店舗をインデックスに登録します。
+// 登録に失敗しても実行の結果には影響させず、ログに出力するのみとします。
+func IndexRun(ctx context.Context, logger *slog.Logger, db *gorm.DB, index StoreIndex, runID string) {
+	stores, err := repository.NewStoreRepository(db).WithContext(ctx).SummariesByRun(model.DefaultTenantID, runID)
+	if err != nil {
+		logger.Error("検索インデックスに登録する店舗の取得失敗", "error", err)
+		return
+	}
+	if len(stores) == 0 {
+		return
+	}
+	if err := index.IndexStores(ctx, stores); err != nil {
+		logger.Error("検索インデックスへの店舗の登録失敗", "error", err)
+		return
+	}
+	logger.Info("検索インデックスに店舗を登録しました", "stores", len(stores))
+}
+
+// Reindexはインデックスの店舗を削除してから、既定のテナントのトピックで言及された店舗を登録し直し、登録した数を返します。
+func Reindex(ctx context.Context, db *gorm.DB, index StoreIndex) (int, error) {
+	if err := index.ClearStores(ctx); err != nil {
+		return 0, fmt.Errorf("clear index: %w", err)
+	}
+	repo := repository.NewStoreRepository(db).WithContext(ctx)
+	var afterID uint
+	total := 0
+	for {
+		stores, err := repo.ListSummaries(model.DefaultTenantID, afterID, reindexBatchSize)
+		if err != nil {
+			return total, fmt.Errorf("list stores: %w", err)
+		}
+		if len(stores) == 0 {
+			return total, nil
+		}
+		if err := index.IndexStores(ctx, stores); err != nil {
+			return total, err
+		}
+		total += len(stores)
+		afterID = stores[len(stores)-1].ID
+	}
+}
@@ -0,0 +1,106 @@
+// Package secrets はAPIキーやDBのパスワードなどの秘密情報を外部のバックエンドから取得します。
+// 設定値に「ssm:/path/to/param」や「vault:secret/data/path#key」のような参照を書くと、起動時に実際の値に置き換えられます。
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider は秘密情報を取得するバックエンドです。refはスキームを除いた参照です。
+type Provider interface {
+	Get(ctx context.Context, ref string) (string, error)
+}
+
+// Resolver は設定値に書かれた秘密情報の参照を、スキームに対応するProviderから取得した値に置き換えます。
+// 取得した値はttlの間キャッシュし、期限が切れた後は取得し直すため、ローテーションされた値を拾えます。
+// 取得し直しに失敗した場合は、キャッシュ済みの古い値を返します。
+type Resolver struct {
+	providers map[string]func(ctx context.Context) (Provider, error)
+	ttl       time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	opened  map[string]Provider
+	entries map[string]entry
+}
+
+type entry struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// NewResolverはAWS SSM Parameter Store(ssm:)とVault(vault:)を使うResolverを作成します。
+// 各バックエンドのクライアントは、その参照が初めて使われたときに作成されます。
+func NewResolver(ttl time.Duration) *Resolver {
+	return newResolver(ttl, map[string]func(ctx context.Context) (Provider, error){
+		"ssm":   func(ctx context.Context) (Provider, error) { return NewSSMProvider(ctx) },
+		"vault": func(ctx context.Context) (Provider, error) { return NewVaultProvider() },
+	})
+}
+
+func newResolver(ttl time.Duration, providers map[string]func(ctx context.Context) (Provider, error)) *Resolver {
+	return &Resolver{
+		providers: providers,
+		ttl:       ttl,
+		now:       time.Now,
+		opened:    make(map[string]Provider),
+		entries:   make(map[string]entry),
+	}
+}
+
+// IsRefはvalueが秘密情報の参照であるかを返します。
+func (r *Resolver) IsRef(value string) bool {
+	scheme, _, ok := strings.Cut(value, ":")
+	if !ok {
+		return false
+	}
+	_, known := r.providers[scheme]
+	return known
+}
+
+// Resolveはvalueが秘密情報の参照であれば取得した値を、そうでなければvalueをそのまま返します。
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	if !r.IsRef(value) {
+		return value, nil
+	}
+	scheme, ref, _ := strings.Cut(value, ":")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cached, ok := r.entries[value]
+	if ok && r.now().Sub(cached.fetchedAt) < r.ttl {
+		return cached.value, nil
+	}
+
+	provider, err := r.provider(ctx, scheme)
+	if err == nil {
+		var v string
+		if v, err = provider.Get(ctx, ref); err == nil {
+			r.entries[value] = entry{value: v, fetchedAt: r.now()}
+			return v, nil
+		}
+	}
+	if ok {
+		slog.Warn("秘密情報の再取得に失敗したため、キャッシュ済みの値を使います", "scheme", scheme, "ref", ref, "error", err)
+		return cached.value, nil
+	}
+	return "", fmt.Errorf("resolve %s secret %q: %w", scheme, ref, err)
+}
+
+func (r *Resolver) provider(ctx context.Context, scheme string) (Provider, error) {
+	if p, ok := r.opened[scheme]; ok {
+		return p, nil
+	}
+	p, err := r.providers[scheme](ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.opened[scheme] = p
+	return p, nil
+}
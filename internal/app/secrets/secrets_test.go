@@ -0,0 +1,61 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeProvider struct {
+	values map[string]string
+	err    error
+	calls  int
+}
+
+func (p *fakeProvider) Get(ctx context.Context, ref string) (string, error) {
+	p.calls++
+	if p.err != nil {
+		return "", p.err
+	}
+	return p.values[ref], nil
+}
+
+func TestResolverCachesAndRotates(t *testing.T) {
+	fake := &fakeProvider{values: map[string]string{"/app/key": "v1"}}
+	r := newResolver(time.Minute, map[string]func(ctx context.Context) (Provider, error){
+		"ssm": func(ctx context.Context) (Provider, error) { return fake, nil },
+	})
+	now := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if v, err := r.Resolve(ctx, "plain-value"); err != nil || v != "plain-value" {
+		t.Fatalf("Resolve(plain) = %q, %v", v, err)
+	}
+	if v, _ := r.Resolve(ctx, "ssm:/app/key"); v != "v1" {
+		t.Fatalf("Resolve = %q, want v1", v)
+	}
+
+	// TTL内はキャッシュを返す
+	fake.values["/app/key"] = "v2"
+	if v, _ := r.Resolve(ctx, "ssm:/app/key"); v != "v1" || fake.calls != 1 {
+		t.Fatalf("Resolve within ttl = %q (calls=%d), want cached v1", v, fake.calls)
+	}
+
+	// TTLを過ぎるとローテーションされた値を取得する
+	now = now.Add(2 * time.Minute)
+	if v, _ := r.Resolve(ctx, "ssm:/app/key"); v != "v2" {
+		t.Fatalf("Resolve after ttl = %q, want v2", v)
+	}
+
+	// 再取得に失敗したらキャッシュ済みの値を返す
+	now = now.Add(2 * time.Minute)
+	fake.err = errors.New("unavailable")
+	if v, err := r.Resolve(ctx, "ssm:/app/key"); err != nil || v != "v2" {
+		t.Fatalf("Resolve on failure = %q, %v, want stale v2", v, err)
+	}
+	if _, err := r.Resolve(ctx, "ssm:/app/other"); err == nil {
+		t.Fatal("Resolve of uncached secret should fail")
+	}
+}
@@ -0,0 +1,36 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// SSMProvider はAWS SSM Parameter Storeから秘密情報を取得します。SecureStringは復号して返します。
+// 参照はパラメータ名です (例: ssm:/excavation/prod/openai_api_key)。
+type SSMProvider struct {
+	client *ssm.Client
+}
+
+// NewSSMProviderは標準のAWS認証情報(環境変数、共有設定ファイル、IAMロール)を使うSSMProviderを作成します。
+func NewSSMProvider(ctx context.Context) (*SSMProvider, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	return &SSMProvider{client: ssm.NewFromConfig(cfg)}, nil
+}
+
+func (p *SSMProvider) Get(ctx context.Context, ref string) (string, error) {
+	out, err := p.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(ref),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Parameter.Value), nil
+}
@@ -0,0 +1,49 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// VaultProvider はHashiCorp Vaultから秘密情報を取得します。
+// 参照は「パス#キー」の形式です (例: vault:secret/data/excavation#openai_api_key)。KVのv1・v2のどちらにも対応します。
+type VaultProvider struct {
+	client *vault.Client
+}
+
+// NewVaultProviderは環境変数(VAULT_ADDR、VAULT_TOKENなど)の設定でVaultに接続するVaultProviderを作成します。
+func NewVaultProvider() (*VaultProvider, error) {
+	client, err := vault.NewClient(vault.DefaultConfig())
+	if err != nil {
+		return nil, fmt.Errorf("create vault client: %w", err)
+	}
+	return &VaultProvider{client: client}, nil
+}
+
+func (p *VaultProvider) Get(ctx context.Context, ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || key == "" {
+		return "", fmt.Errorf("vault reference must be path#key")
+	}
+	secret, err := p.client.Logical().ReadWithContext(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", fmt.Errorf("no secret at %s", path)
+	}
+
+	data := secret.Data
+	// KV v2はdataの中にさらにdataがある
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		data = inner
+	}
+	v, ok := data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("key %q not found in %s", key, path)
+	}
+	return v, nil
+}
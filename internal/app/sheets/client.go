@@ -0,0 +1,230 @@
+// Package sheets は週間ランキングをGoogleスプレッドシートに書き出します。
+//
+// 認証にはサービスアカウントの鍵(JSON)を使います。書き出し先のスプレッドシートをサービスアカウントの
+// メールアドレス(client_email)に編集者として共有してください。
+package sheets
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"excavation_service/internal/app/tracing"
+)
+
+const (
+	apiBaseURL      = "https://sheets.googleapis.com/v4/spreadsheets"
+	scope           = "https://www.googleapis.com/auth/spreadsheets"
+	defaultTokenURL = "https://oauth2.googleapis.com/token"
+	tokenLifetime   = time.Hour
+)
+
+// Credentials はサービスアカウントの鍵のうち、認証に使う項目です。
+type Credentials struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+// LoadCredentialsはサービスアカウントの鍵のファイルを読み込みます。
+func LoadCredentials(path string) (*Credentials, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCredentials(b)
+}
+
+// ParseCredentialsはサービスアカウントの鍵(JSON)を読み込みます。
+func ParseCredentials(b []byte) (*Credentials, error) {
+	var c Credentials
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("parse service account key: %w", err)
+	}
+	if c.ClientEmail == "" || c.PrivateKey == "" {
+		return nil, errors.New("parse service account key: client_email and private_key are required")
+	}
+	block, _ := pem.Decode([]byte(c.PrivateKey))
+	if block == nil {
+		return nil, errors.New("parse service account key: private_key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse service account key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("parse service account key: private_key is not an RSA key")
+	}
+	c.key = key
+	if c.TokenURI == "" {
+		c.TokenURI = defaultTokenURL
+	}
+	return &c, nil
+}
+
+// assertionはアクセストークンの取得に使う署名付きのJWTを作成します。
+func (c *Credentials) assertion(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   c.ClientEmail,
+		"scope": scope,
+		"aud":   c.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(tokenLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// Client はSheets APIのクライアントです。アクセストークンは期限が切れるまで使い回します。
+type Client struct {
+	creds   *Credentials
+	baseURL string
+	http    *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func NewClient(creds *Credentials) *Client {
+	return &Client{creds: creds, baseURL: apiBaseURL, http: tracing.NewHTTPClient(30 * time.Second)}
+}
+
+// accessTokenは有効なアクセストークンを返します。期限が近ければ取得し直します。
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.token != "" && now.Add(time.Minute).Before(c.expires) {
+		return c.token, nil
+	}
+
+	assertion, err := c.creds.assertion(now)
+	if err != nil {
+		return "", fmt.Errorf("sign jwt: %w", err)
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.creds.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := c.do(req, &token); err != nil {
+		return "", fmt.Errorf("get google access token: %w", err)
+	}
+	c.token, c.expires = token.AccessToken, now.Add(time.Duration(token.ExpiresIn)*time.Second)
+	return c.token, nil
+}
+
+// callはSheets APIを呼び出し、応答をout(nilなら読み捨てる)に読み込みます。
+func (c *Client) call(ctx context.Context, method, path string, body, out interface{}) error {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err := c.do(req, out); err != nil {
+		return fmt.Errorf("sheets api %s %s: %w", method, strings.SplitN(path, "?", 2)[0], err)
+	}
+	return nil
+}
+
+func (c *Client) do(req *http.Request, out interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ReplaceSheetはスプレッドシートspreadsheetIDのシートtitleの内容をrowsで置き換えます。シートがなければ作成します。
+func (c *Client) ReplaceSheet(ctx context.Context, spreadsheetID, title string, rows [][]interface{}) error {
+	id := url.PathEscape(spreadsheetID)
+	var spreadsheet struct {
+		Sheets []struct {
+			Properties struct {
+				Title string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/"+id+"?fields=sheets.properties.title", nil, &spreadsheet); err != nil {
+		return err
+	}
+	exists := false
+	for _, s := range spreadsheet.Sheets {
+		exists = exists || s.Properties.Title == title
+	}
+	if !exists {
+		req := map[string]interface{}{"requests": []interface{}{
+			map[string]interface{}{"addSheet": map[string]interface{}{"properties": map[string]string{"title": title}}},
+		}}
+		if err := c.call(ctx, http.MethodPost, "/"+id+":batchUpdate", req, nil); err != nil {
+			return err
+		}
+	}
+
+	// シート名はA1記法では'で囲み、'は''と書く
+	sheetRange := url.PathEscape("'" + strings.ReplaceAll(title, "'", "''") + "'")
+	if err := c.call(ctx, http.MethodPost, "/"+id+"/values/"+sheetRange+":clear", map[string]string{}, nil); err != nil {
+		return err
+	}
+	values := map[string]interface{}{"majorDimension": "ROWS", "values": rows}
+	return c.call(ctx, http.MethodPut, "/"+id+"/values/"+sheetRange+"?valueInputOption=RAW", values, nil)
+}
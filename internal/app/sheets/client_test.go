@@ -0,0 +1,103 @@
+package sheets
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"excavation_service/internal/app/repository"
+)
+
+func testCredentials(t *testing.T, tokenURL string) *Credentials {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(map[string]string{
+		"client_email": "exporter@example.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenURL,
+	})
+	creds, err := ParseCredentials(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return creds
+}
+
+func TestReplaceSheet(t *testing.T) {
+	var calls []string
+	var written struct {
+		Values [][]interface{} `json:"values"`
+	}
+	var creds *Credentials
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			r.ParseForm()
+			parts := strings.Split(r.PostForm.Get("assertion"), ".")
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(&creds.key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+				t.Errorf("invalid assertion signature: %v", err)
+			}
+			io.WriteString(w, `{"access_token":"tok","expires_in":3600}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		calls = append(calls, r.Method+" "+r.URL.EscapedPath())
+		switch {
+		case r.Method == http.MethodGet:
+			io.WriteString(w, `{"sheets":[{"properties":{"title":"Sheet1"}}]}`)
+		case r.Method == http.MethodPut:
+			json.NewDecoder(r.Body).Decode(&written)
+			io.WriteString(w, `{}`)
+		default:
+			io.WriteString(w, `{}`)
+		}
+	}))
+	defer srv.Close()
+
+	creds = testCredentials(t, srv.URL+"/token")
+	client := NewClient(creds)
+	client.baseURL = srv.URL
+	delta := 2.5
+	rows := RankingRows(
+		[]repository.TopicScore{{TopicID: 1, Topic: "渋谷 カレー", Score: 72, Delta: &delta, TopTitle: "スパイス"}, {TopicID: 2, Topic: "新宿 ラーメン", Score: 40}},
+		map[uint][]repository.StoreMentionCount{1: {{Name: "食堂 ほし", URL: "https://tabelog.com/1"}, {Name: "喫茶 みどり", URL: "https://tabelog.com/2"}}},
+	)
+	if err := client.ReplaceSheet(context.Background(), "sheet-id", "2024-06-03", rows); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"GET /sheet-id",
+		"POST /sheet-id:batchUpdate",
+		"POST /sheet-id/values/%272024-06-03%27:clear",
+		"PUT /sheet-id/values/%272024-06-03%27",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+	if len(written.Values) != 3 || written.Values[1][5] != "食堂 ほし\n喫茶 みどり" || written.Values[2][3] != "" {
+		t.Errorf("values = %v", written.Values)
+	}
+}
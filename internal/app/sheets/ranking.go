@@ -0,0 +1,61 @@
+package sheets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/week"
+
+	"gorm.io/gorm"
+)
+
+const (
+	rankingLimit = 100 // シートに書き出すトピックの数
+	storesLimit  = 3   // トピックごとに書き出す店舗の数
+)
+
+var rankingHeader = []interface{}{"順位", "トピック", "スコア", "前週比", "話題", "注目の店舗", "店舗のURL"}
+
+// ExportRankingはw週のスコア上位のトピックと言及の多かった店舗を、スプレッドシートspreadsheetIDの
+// 週の名前(YYYY-MM-DD)のシートに書き出し、書き出したトピックの数を返します。同じ週のシートは置き換えます。
+// トピックは既定のテナントのもののみ書き出します。
+func ExportRanking(ctx context.Context, db *gorm.DB, client *Client, spreadsheetID string, w time.Time) (int, error) {
+	top, err := repository.NewTrendRepository(db).WithContext(ctx).ForTenant(model.DefaultTenantID).Top(w, rankingLimit)
+	if err != nil {
+		return 0, fmt.Errorf("top trends: %w", err)
+	}
+	storeRepo := repository.NewStoreRepository(db).WithContext(ctx)
+	stores := make(map[uint][]repository.StoreMentionCount, len(top))
+	for _, t := range top {
+		if stores[t.TopicID], err = storeRepo.MostMentioned(t.TopicID, w, storesLimit); err != nil {
+			return 0, fmt.Errorf("stores of topic %d: %w", t.TopicID, err)
+		}
+	}
+	if err := client.ReplaceSheet(ctx, spreadsheetID, w.Format(week.Layout), RankingRows(top, stores)); err != nil {
+		return 0, err
+	}
+	return len(top), nil
+}
+
+// RankingRowsはランキングを見出し付きのシートの行にします。storesのkeyはトピックのIDです。
+// 前週比は前週のトレンドがなければ空欄にします。
+func RankingRows(top []repository.TopicScore, stores map[uint][]repository.StoreMentionCount) [][]interface{} {
+	rows := [][]interface{}{rankingHeader}
+	for i, t := range top {
+		var delta interface{} = ""
+		if t.Delta != nil {
+			delta = *t.Delta
+		}
+		var names, urls []string
+		for _, s := range stores[t.TopicID] {
+			names = append(names, s.Name)
+			urls = append(urls, s.URL)
+		}
+		rows = append(rows, []interface{}{i + 1, t.Topic, t.Score, delta, t.TopTitle, strings.Join(names, "\n"), strings.Join(urls, "\n")})
+	}
+	return rows
+}
@@ -0,0 +1,178 @@
+// Package storematch は表記の揺れで別の店舗として登録された店舗を見つけます。
+// 店舗名は支店名を除いて正規化し、編集距離と読み(かな)の比較で同じ店舗である確からしさ(0〜1)を求めます。
+// 確からしさがしきい値以上の組は自動で同じ店舗として紐付け、それより低い候補は人が確認するためのキューに入れます。
+package storematch
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// 判定の方法。StoreLink.Methodに記録する
+const (
+	MethodName    = "name"    // 正規化した店舗名の編集距離
+	MethodReading = "reading" // 店舗名の読み(かな)の編集距離
+)
+
+// readingWeight は読みのみで一致した場合の確からしさの係数です。
+// 読みが同じでも表記の異なる別の店舗はあるため、店舗名の一致より低く見積もる
+const readingWeight = 0.95
+
+// Match は2つの店舗名を比較した結果です。
+type Match struct {
+	Confidence float64 // 同じ店舗である確からしさ (0〜1)
+	Method     string  // Confidenceを求めた方法 (Method*)
+}
+
+// branchPattern は店舗名の末尾の支店名です。例: "ラーメン二郎 三田本店" の "三田本店"、"鮨さいとう(六本木店)" の "(六本木店)"
+var branchPattern = regexp.MustCompile(`(?:[\s　]+\S{0,12}(?:店|号館)|[（(][^（()）]{0,16}(?:店|号館)[)）])$`)
+
+// parenPattern は店舗名の末尾の括弧書きです。食べログでは店舗名の読みを括弧書きで付けることがある
+var parenPattern = regexp.MustCompile(`[（(]([^（()）]*)[)）]$`)
+
+// StripBranchは店舗名の末尾の支店名(〇〇店)を取り除きます。支店名のみの店舗名は変更しません。
+func StripBranch(name string) string {
+	name = strings.TrimSpace(name)
+	for {
+		stripped := strings.TrimSpace(branchPattern.ReplaceAllString(name, ""))
+		if stripped == "" || stripped == name {
+			return name
+		}
+		name = stripped
+	}
+}
+
+// Normalizeは比較のため店舗名を正規化します。全角英数字を半角に、英字を小文字に、カタカナをひらがなにし、
+// 空白と記号を取り除きます。末尾の読みの括弧書きと支店名は取り除きます。
+func Normalize(name string) string {
+	name = StripBranch(name)
+	if m := parenPattern.FindStringSubmatchIndex(name); m != nil && isKana(name[m[2]:m[3]]) {
+		name = StripBranch(name[:m[0]])
+	}
+	return fold(name)
+}
+
+// Readingは店舗名の読みを正規化して返します。店舗名がかなのみの場合はその店舗名、
+// 末尾にかなのみの括弧書きがある場合はその括弧書きを読みとします。読みがわからない場合は空文字列です。
+// 漢字の読みの辞書は持たないため、漢字のみの店舗名の読みは求めません。
+func Reading(name string) string {
+	name = StripBranch(name)
+	if m := parenPattern.FindStringSubmatch(name); m != nil && isKana(m[1]) {
+		return fold(m[1])
+	}
+	if isKana(name) {
+		return fold(name)
+	}
+	return ""
+}
+
+// branchOfは店舗名の末尾の支店名を正規化して返します。支店名がなければ空文字列です。
+func branchOf(name string) string {
+	name = strings.TrimSpace(name)
+	return fold(strings.TrimPrefix(name, StripBranch(name)))
+}
+
+// Compareは店舗名a, bが同じ店舗の名前である確からしさを返します。
+// 正規化した店舗名の編集距離と、両方の読みがわかる場合は読みの編集距離を比べ、高い方を採用します。
+// 両方に異なる支店名がある場合は同じチェーンの別の店舗のため、支店名を含めて比べます。
+func Compare(a, b string) Match {
+	if ba, bb := branchOf(a), branchOf(b); ba != "" && bb != "" && ba != bb {
+		return Match{Confidence: similarity(fold(a), fold(b)), Method: MethodName}
+	}
+	best := Match{Confidence: similarity(Normalize(a), Normalize(b)), Method: MethodName}
+	readings := []string{Reading(a), Reading(b)}
+	// 片方にしか読みがない場合は、もう片方の店舗名そのものを読みとして比べる (例: "すきやばし次郎(すきやばしじろう)" と "すきやばしじろう")
+	if readings[0] == "" && readings[1] != "" {
+		readings[0] = Normalize(a)
+	} else if readings[1] == "" && readings[0] != "" {
+		readings[1] = Normalize(b)
+	}
+	if readings[0] != "" && readings[1] != "" {
+		if c := similarity(readings[0], readings[1]) * readingWeight; c > best.Confidence {
+			best = Match{Confidence: c, Method: MethodReading}
+		}
+	}
+	return best
+}
+
+// BlockKeysは比較の候補を絞り込むためのキーを返します。同じキーを持つ店舗どうしのみ比較します。
+// 正規化した店舗名と読みの先頭の文字をキーにします。
+func BlockKeys(name string) []string {
+	var keys []string
+	for _, s := range []string{Normalize(name), Reading(name)} {
+		for _, r := range s {
+			keys = append(keys, string(r))
+			break
+		}
+	}
+	if len(keys) == 2 && keys[0] == keys[1] {
+		keys = keys[:1]
+	}
+	return keys
+}
+
+// foldは全角英数字を半角に、英字を小文字に、カタカナをひらがなにし、文字・数字以外を取り除きます。
+// 長音符(ー)は読みの一部のため残します。
+func fold(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '！' && r <= '～':
+			r -= '！' - '!'
+		case r >= 'ァ' && r <= 'ヶ':
+			r -= 'ァ' - 'ぁ'
+		}
+		r = unicode.ToLower(r)
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || r == 'ー' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isKanaはsが1文字以上のかな(と長音符・空白・中黒)のみからなるかを返します。
+func isKana(s string) bool {
+	hasKana := false
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana) && r != '・':
+			hasKana = true
+		case r == 'ー' || r == '・' || unicode.IsSpace(r):
+		default:
+			return false
+		}
+	}
+	return hasKana
+}
+
+// similarityは1 - (編集距離 / 長い方の文字数)を返します。どちらかが空の場合は0です。
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	longer := max(len(ra), len(rb))
+	return 1 - float64(levenshtein(ra, rb))/float64(longer)
+}
+
+// levenshteinはa, bの編集距離(挿入・削除・置換の回数)を返します。
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
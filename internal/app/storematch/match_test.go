@@ -0,0 +1,59 @@
+package storematch
+
+import "testing"
+
+func TestStripBranch(t *testing.T) {
+	tests := map[string]string{
+		"ラーメン二郎 三田本店": "ラーメン二郎",
+		"鮨さいとう（六本木店）": "鮨さいとう",
+		"鮨さいとう(六本木店)": "鮨さいとう",
+		"そば処 更科":      "そば処 更科",
+		"本店":          "本店",
+		"焼肉ジャンボ 白金店 ": "焼肉ジャンボ",
+	}
+	for in, want := range tests {
+		if got := StripBranch(in); got != want {
+			t.Errorf("StripBranch(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		min    float64
+		max    float64
+		method string
+	}{
+		// 表記の揺れ
+		{"ＡＦＵＲＩ 恵比寿", "afuri恵比寿", 1, 1, MethodName},
+		{"鮨さいとう", "鮨 さいとう 六本木店", 1, 1, MethodName},
+		{"カフェ・ド・ランブル", "カフェ ド ランブル", 1, 1, MethodName},
+		// 読みの括弧書きとかなの店舗名
+		{"すきやばし次郎(すきやばしじろう)", "スキヤバシジロウ", 0.9, 0.95, MethodReading},
+		// 1文字違い
+		{"中華そば 青葉", "中華ソバ青葉", 1, 1, MethodName},
+		{"らぁ麺やまぐち", "らあ麺やまぐち", 0.8, 0.9, MethodName},
+		// 同じチェーンの別の支店
+		{"ラーメン二郎 三田本店", "ラーメン二郎 目黒店", 0, 0.75, MethodName},
+		// 別の店舗
+		{"鮨さいとう", "焼肉ジャンボ", 0, 0.2, MethodName},
+	}
+	for _, tt := range tests {
+		m := Compare(tt.a, tt.b)
+		if m.Confidence < tt.min || m.Confidence > tt.max || m.Method != tt.method {
+			t.Errorf("Compare(%q, %q) = %+v, want confidence in [%.2f, %.2f] by %s", tt.a, tt.b, m, tt.min, tt.max, tt.method)
+		}
+	}
+}
+
+func TestBlockKeys(t *testing.T) {
+	keys := BlockKeys("すきやばし次郎(すきやばしじろう)")
+	if len(keys) != 1 || keys[0] != "す" {
+		t.Errorf("BlockKeys = %q", keys)
+	}
+	keys = BlockKeys("スキヤバシジロウ")
+	if len(keys) != 1 || keys[0] != "す" {
+		t.Errorf("BlockKeys = %q", keys)
+	}
+}
@@ -0,0 +1,97 @@
+package storematch
+
+import (
+	"context"
+	"log/slog"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+
+	"gorm.io/gorm"
+)
+
+// 確からしさのしきい値の既定値
+const (
+	DefaultLinkThreshold   = 0.9
+	DefaultReviewThreshold = 0.75
+)
+
+// Options は店舗の照合の設定です。
+type Options struct {
+	LinkThreshold   float64 // 確からしさがこれ以上の組は自動で紐付ける
+	ReviewThreshold float64 // 確からしさがこれ以上LinkThreshold未満の組は確認待ちのキューに入れる
+	DryRun          bool    // 見つけた組をログに出力するのみで記録しない
+}
+
+// Result は店舗の照合の結果です。
+type Result struct {
+	Stores   int // 照合した店舗の数
+	Compared int // 比較した店舗の組の数
+	Linked   int // 新しく自動で紐付けた組の数
+	Queued   int // 新しく確認待ちのキューに入れた組の数
+}
+
+// Runはすべての店舗の名前を照合し、同じ店舗と思われる組を記録します。
+// 店舗の数の2乗の比較を避けるため、BlockKeysのキーが同じ店舗どうしのみ比較します。
+// 組は先に登録された店舗(IDの小さい方)を正とし、既に記録した組は人の確認の結果を含めて変更しません。
+func Run(ctx context.Context, logger *slog.Logger, db *gorm.DB, opts Options) (Result, error) {
+	repo := repository.NewStoreLinkRepository(db).WithContext(ctx)
+	stores, err := repo.ListStoreNames()
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Stores: len(stores)}
+
+	blocks := make(map[string][]repository.StoreName)
+	for _, s := range stores {
+		for _, key := range BlockKeys(s.Name) {
+			blocks[key] = append(blocks[key], s)
+		}
+	}
+
+	type pair struct{ store, canonical uint }
+	compared := make(map[pair]bool)
+	for _, block := range blocks {
+		for i, canonical := range block {
+			for _, store := range block[i+1:] {
+				if err := ctx.Err(); err != nil {
+					return res, err
+				}
+				p := pair{store: store.ID, canonical: canonical.ID}
+				if compared[p] {
+					continue
+				}
+				compared[p] = true
+				res.Compared++
+
+				m := Compare(canonical.Name, store.Name)
+				if m.Confidence < opts.ReviewThreshold {
+					continue
+				}
+				status := model.StoreLinkPending
+				if m.Confidence >= opts.LinkThreshold {
+					status = model.StoreLinkLinked
+				}
+				logger.Debug("同じ店舗の候補", "store_id", store.ID, "store", store.Name, "canonical_id", canonical.ID,
+					"canonical", canonical.Name, "confidence", m.Confidence, "method", m.Method, "status", status)
+				if opts.DryRun {
+					continue
+				}
+				created, err := repo.Propose(&model.StoreLink{
+					StoreID: store.ID, CanonicalID: canonical.ID, Confidence: m.Confidence, Method: m.Method, Status: status,
+				})
+				if err != nil {
+					return res, err
+				}
+				switch {
+				case !created:
+				case status == model.StoreLinkLinked:
+					res.Linked++
+				default:
+					res.Queued++
+				}
+			}
+		}
+	}
+	return res, nil
+}
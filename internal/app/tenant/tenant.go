@@ -0,0 +1,98 @@
+// Package tenant はテナントのAPIキーの発行・照合と、リクエストのテナントの受け渡しを提供します。
+package tenant
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+
+	"excavation_service/internal/app/model"
+)
+
+// KeyPrefix はAPIキーの接頭辞です。ログや設定ファイルに紛れたキーを見つけやすくするために付けます。
+const KeyPrefix = "exk_"
+
+// prefixLen はAPIキーの一覧に表示するキーの先頭の長さです。
+const prefixLen = len(KeyPrefix) + 8
+
+// NewAPIKeyはテナントtenantIDのAPIキーを発行し、キーそのものと保存するAPIキーを返します。
+// キーそのものは保存しないため、発行時に利用者に渡す必要があります。キーのスコープはすべての操作です。
+func NewAPIKey(tenantID uint, name string) (key string, apiKey *model.APIKey, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	key = KeyPrefix + hex.EncodeToString(b)
+	return key, &model.APIKey{TenantID: tenantID, Name: name, Prefix: key[:prefixLen], KeyHash: HashAPIKey(key),
+		Scopes: slices.Clone(model.APIKeyScopes)}, nil
+}
+
+// HashAPIKeyはAPIキーを照合するためのハッシュを返します。キーは十分に長いランダムな値のため、ソルトは使いません。
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidAPIKeyはkeyがNewAPIKeyで発行した形式か判定します。DBに問い合わせる前に明らかに不正なキーを弾くために使います。
+func ValidAPIKey(key string) bool {
+	hexPart, ok := strings.CutPrefix(key, KeyPrefix)
+	if !ok || len(hexPart) != 48 {
+		return false
+	}
+	_, err := hex.DecodeString(hexPart)
+	return err == nil
+}
+
+type ctxKey struct{}
+
+type apiKeyCtxKey struct{}
+
+type scopesCtxKey struct{}
+
+// NewContextはリクエストのテナントを格納したctxを返します。
+func NewContext(ctx context.Context, tenant *model.Tenant) context.Context {
+	return context.WithValue(ctx, ctxKey{}, tenant)
+}
+
+// FromContextはctxに格納されたテナントを返します。格納されていなければnilです。
+func FromContext(ctx context.Context) *model.Tenant {
+	tenant, _ := ctx.Value(ctxKey{}).(*model.Tenant)
+	return tenant
+}
+
+// WithAPIKeyはリクエストの認証に使ったAPIキーのIDを格納したctxを返します。
+func WithAPIKey(ctx context.Context, keyID uint) context.Context {
+	return context.WithValue(ctx, apiKeyCtxKey{}, keyID)
+}
+
+// APIKeyIDはctxに格納されたAPIキーのIDを返します。APIキーなしのリクエストでは0です。
+func APIKeyID(ctx context.Context) uint {
+	id, _ := ctx.Value(apiKeyCtxKey{}).(uint)
+	return id
+}
+
+// WithScopesはリクエストの認証に使ったAPIキーのスコープを格納したctxを返します。
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesCtxKey{}, scopes)
+}
+
+// HasScopeはリクエストがscopeの操作を許可されているかを返します。
+// APIキーなしのリクエストはキーのスコープで制限しないため、常にtrueです。
+func HasScope(ctx context.Context, scope string) bool {
+	if APIKeyID(ctx) == 0 {
+		return true
+	}
+	scopes, _ := ctx.Value(scopesCtxKey{}).([]string)
+	return slices.Contains(scopes, scope)
+}
+
+// IDはctxに格納されたテナントのIDを返します。格納されていなければ既定のテナントのIDです。
+func ID(ctx context.Context) uint {
+	if tenant := FromContext(ctx); tenant != nil {
+		return tenant.ID
+	}
+	return model.DefaultTenantID
+}
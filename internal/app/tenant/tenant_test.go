@@ -0,0 +1,65 @@
+package tenant
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"excavation_service/internal/app/model"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, apiKey, err := NewAPIKey(3, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ValidAPIKey(key) {
+		t.Errorf("ValidAPIKey(%q) = false, want true", key)
+	}
+	if apiKey.TenantID != 3 || apiKey.Name != "ci" || !strings.HasPrefix(key, apiKey.Prefix) {
+		t.Errorf("apiKey = %+v, want tenant 3 named ci with a prefix of %q", apiKey, key)
+	}
+	if apiKey.KeyHash != HashAPIKey(key) || strings.Contains(apiKey.KeyHash, key[len(KeyPrefix):]) {
+		t.Errorf("KeyHash = %q, want the hash of the key", apiKey.KeyHash)
+	}
+
+	other, _, err := NewAPIKey(3, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == key {
+		t.Error("NewAPIKey returned the same key twice")
+	}
+}
+
+func TestValidAPIKey(t *testing.T) {
+	for _, key := range []string{"", "exk_", "exk_" + strings.Repeat("0", 47), "exk_" + strings.Repeat("z", 48), "abc_" + strings.Repeat("0", 48)} {
+		if ValidAPIKey(key) {
+			t.Errorf("ValidAPIKey(%q) = true, want false", key)
+		}
+	}
+}
+
+func TestID(t *testing.T) {
+	if got := ID(context.Background()); got != model.DefaultTenantID {
+		t.Errorf("ID without tenant = %d, want %d", got, model.DefaultTenantID)
+	}
+	ctx := NewContext(context.Background(), &model.Tenant{ID: 7})
+	if got := ID(ctx); got != 7 {
+		t.Errorf("ID = %d, want 7", got)
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	// APIキーなしのリクエストはスコープで制限しない
+	if !HasScope(context.Background(), model.ScopeWrite) {
+		t.Error("HasScope without api key = false, want true")
+	}
+	ctx := WithScopes(WithAPIKey(context.Background(), 5), []string{model.ScopeRead})
+	if !HasScope(ctx, model.ScopeRead) || HasScope(ctx, model.ScopeWrite) {
+		t.Errorf("HasScope of a read-only key = read %v, write %v; want read only", HasScope(ctx, model.ScopeRead), HasScope(ctx, model.ScopeWrite))
+	}
+	if _, apiKey, _ := NewAPIKey(3, "ci"); !apiKey.HasScope(model.ScopeRead) || !apiKey.HasScope(model.ScopeWrite) {
+		t.Errorf("NewAPIKey scopes = %v, want all scopes", apiKey.Scopes)
+	}
+}
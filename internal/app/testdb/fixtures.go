@@ -0,0 +1,34 @@
+package testdb
+
+import (
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+)
+
+// Topicはエンティティ(飲食店)とそのトピックを作成します。複数のテストで使う共通のデータです。
+func Topic(t testing.TB, db *gorm.DB, entityName, topic string) model.EntityTopic {
+	t.Helper()
+	entity := model.Entity{Name: entityName, Type: model.EntityTypeRestaurant}
+	if err := db.Create(&entity).Error; err != nil {
+		t.Fatalf("create entity: %v", err)
+	}
+	et := model.EntityTopic{EntityID: entity.ID, Topic: topic}
+	if err := db.Create(&et).Error; err != nil {
+		t.Fatalf("create topic: %v", err)
+	}
+	return et
+}
+
+// Trendはトピックのweek週のトレンドを作成します。
+func Trend(t testing.TB, db *gorm.DB, topicID uint, week time.Time, score float64) model.TopicTrend {
+	t.Helper()
+	trend := model.TopicTrend{TopicID: topicID, Week: week, Score: score, RunID: "test"}
+	if err := db.Create(&trend).Error; err != nil {
+		t.Fatalf("create trend: %v", err)
+	}
+	return trend
+}
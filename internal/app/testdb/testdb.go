@@ -0,0 +1,273 @@
+// Package testdb は統合テストで使うPostgreSQLとRedisを用意します。
+//
+// TEST_DATABASE_URL(TEST_REDIS_URL)が設定されていればそのサーバーを使い、設定されていなければDockerでコンテナを起動します。
+// コンテナはテストのプロセスごとに1つ起動し、Mainがテストの後に停止します。Dockerも使えない環境ではテストをスキップします。
+//
+// PostgreSQLではマイグレーションを適用したテンプレートのDBをプロセスごとに1つ作り、テストごとにそれを複製した空のDBを使うため、
+// テストの間でデータを共有しません。TEST_DATABASE_URLのユーザーにはDBを作成する権限(CREATEDB)が必要です。
+//
+// 使うパッケージではTestMainからMainを呼び出してください。
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(testdb.Main(m))
+//	}
+package testdb
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/db"
+	"excavation_service/migrations"
+
+	_ "github.com/lib/pq" // PostgreSQLドライバ
+	"gorm.io/gorm"
+)
+
+const (
+	postgresImage = "postgres:16-alpine"
+	redisImage    = "redis:7-alpine"
+	startTimeout  = 60 * time.Second
+)
+
+// errNoDocker はTEST_DATABASE_URLなどが設定されておらず、Dockerも使えないことを表します。この場合はテストをスキップします。
+var errNoDocker = errors.New("docker is not available")
+
+var (
+	pgOnce     sync.Once
+	pgAdminURL string // DBの作成・削除に使う接続のURL
+	pgTemplate string // マイグレーションを適用したテンプレートのDBの名前
+	pgErr      error
+	dbSeq      atomic.Int64
+
+	redisOnce sync.Once
+	redisURL  string
+	redisErr  error
+
+	mu         sync.Mutex
+	containers []string // 起動したコンテナのID
+)
+
+// Mainはテストを実行し、テストの後にテンプレートのDBを削除して起動したコンテナを停止します。終了コードを返します。
+func Main(m *testing.M) int {
+	code := m.Run()
+	if pgTemplate != "" && pgErr == nil {
+		if admin, err := sql.Open("postgres", pgAdminURL); err == nil {
+			admin.Exec("DROP DATABASE IF EXISTS " + pgTemplate)
+			admin.Close()
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(containers) > 0 {
+		exec.Command("docker", append([]string{"rm", "-f"}, containers...)...).Run()
+	}
+	return code
+}
+
+// Postgresはマイグレーションを適用した空のDBに接続したgorm.DBを返します。DBはテストごとに作成し、テストの終了時に削除します。
+func Postgres(t testing.TB) *gorm.DB {
+	t.Helper()
+	pgOnce.Do(func() { pgAdminURL, pgTemplate, pgErr = preparePostgres() })
+	require(t, "TEST_DATABASE_URL", pgErr)
+
+	ctx := context.Background()
+	name := fmt.Sprintf("test_%d_%d", os.Getpid(), dbSeq.Add(1))
+	admin, err := sql.Open("postgres", pgAdminURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+name+" TEMPLATE "+pgTemplate); err != nil {
+		t.Fatalf("create test database: %v", err)
+	}
+
+	sqlDB, err := sql.Open("postgres", withDatabase(pgAdminURL, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sqlDB.Close()
+		if admin, err := sql.Open("postgres", pgAdminURL); err == nil {
+			admin.Exec("DROP DATABASE IF EXISTS " + name + " WITH (FORCE)")
+			admin.Close()
+		}
+	})
+	gormDB, err := db.OpenGorm(sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gormDB
+}
+
+// preparePostgresはPostgreSQLを用意し、マイグレーションを適用したテンプレートのDBを作成します。
+func preparePostgres() (adminURL, template string, err error) {
+	adminURL = os.Getenv("TEST_DATABASE_URL")
+	if adminURL == "" {
+		addr, err := startContainer(postgresImage, "5432/tcp", "POSTGRES_PASSWORD=test")
+		if err != nil {
+			return "", "", err
+		}
+		adminURL = "postgres://postgres:test@" + addr + "/postgres?sslmode=disable"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+	admin, err := sql.Open("postgres", adminURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer admin.Close()
+	// 起動したコンテナは初期化を終えるまで接続を受け付けない
+	for {
+		if err = admin.PingContext(ctx); err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return "", "", fmt.Errorf("postgres is not ready: %w", err)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	template = fmt.Sprintf("excavation_template_%d", os.Getpid())
+	if _, err := admin.ExecContext(ctx, "DROP DATABASE IF EXISTS "+template); err != nil {
+		return "", "", err
+	}
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+template); err != nil {
+		return "", "", fmt.Errorf("create template database: %w", err)
+	}
+	// 複製元のDBに接続が残っていると複製できないため、適用後に閉じる
+	tmpl, err := sql.Open("postgres", withDatabase(adminURL, template))
+	if err != nil {
+		return "", "", err
+	}
+	defer tmpl.Close()
+	if _, err := db.Migrate(ctx, tmpl, migrations.FS); err != nil {
+		return "", "", fmt.Errorf("migrate template database: %w", err)
+	}
+	return adminURL, template, nil
+}
+
+// withDatabaseはrawURLの接続先のDBをnameに置き換えたURLを返します。
+func withDatabase(rawURL, name string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Path = "/" + name
+	return u.String()
+}
+
+// RedisはRedisのURL(redis://host:port)を返します。サーバーはテストの間で共有するため、テストごとに異なるキーを使ってください。
+func Redis(t testing.TB) string {
+	t.Helper()
+	redisOnce.Do(func() { redisURL, redisErr = prepareRedis() })
+	require(t, "TEST_REDIS_URL", redisErr)
+	return redisURL
+}
+
+func prepareRedis() (string, error) {
+	if u := os.Getenv("TEST_REDIS_URL"); u != "" {
+		return u, nil
+	}
+	addr, err := startContainer(redisImage, "6379/tcp")
+	if err != nil {
+		return "", err
+	}
+	deadline := time.Now().Add(startTimeout)
+	for {
+		if err = pingRedis(addr); err == nil {
+			return "redis://" + addr, nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("redis is not ready: %w", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func pingRedis(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(line) != "+PONG" {
+		return fmt.Errorf("unexpected reply %q", line)
+	}
+	return nil
+}
+
+// startContainerはimageのコンテナを起動し、portを公開したホスト側のアドレス(127.0.0.1:ポート)を返します。
+// Dockerが使えなければerrNoDockerを返します。
+func startContainer(image, port string, env ...string) (string, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return "", errNoDocker
+	}
+	if err := exec.Command("docker", "info").Run(); err != nil {
+		return "", errNoDocker
+	}
+
+	args := []string{"run", "-d", "--rm", "--label", "excavation.testdb=true", "-p", "127.0.0.1::" + port}
+	for _, e := range env {
+		args = append(args, "-e", e)
+	}
+	out, err := exec.Command("docker", append(args, image)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("docker run %s: %w", image, commandError(err))
+	}
+	id := strings.TrimSpace(string(out))
+	mu.Lock()
+	containers = append(containers, id)
+	mu.Unlock()
+
+	out, err = exec.Command("docker", "port", id, port).Output()
+	if err != nil {
+		return "", fmt.Errorf("docker port %s: %w", image, commandError(err))
+	}
+	// 127.0.0.1:49153 (IPv6でも公開している場合は複数行)
+	addr, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return addr, nil
+}
+
+// commandErrorはコマンドの失敗に標準エラー出力を含めます。
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
+
+// requireはサーバーを用意できなかった場合に、Dockerが使えないだけならテストをスキップし、それ以外は失敗させます。
+// envはサーバーを指定する環境変数です。
+func require(t testing.TB, env string, err error) {
+	t.Helper()
+	if errors.Is(err, errNoDocker) {
+		t.Skipf("integration test skipped: set %s or make docker available", env)
+	}
+	if err != nil {
+		t.Fatalf("prepare %s: %v", env, err)
+	}
+}
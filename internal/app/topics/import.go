@@ -0,0 +1,214 @@
+// Package topics はトピック(エンティティと、その検索クエリ)の登録・一括登録・変更をまとめたパッケージです。
+package topics
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+
+	"gorm.io/gorm"
+)
+
+// Row はCSVの1行で、登録するエンティティとトピックです。
+type Row struct {
+	Line   int // CSVの行番号 (ヘッダーが1行目)
+	Entity string
+	Type   string
+	Topic  string // 空の場合はエンティティ名を使う
+}
+
+// Status は1行ごとのインポート結果です。
+type Status string
+
+const (
+	StatusCreated Status = "created" // トピックを作成した
+	StatusSkipped Status = "skipped" // 登録済み、またはファイル内で重複していた
+	StatusError   Status = "error"   // 入力が不正で登録できなかった
+)
+
+// Result は1行のインポート結果です。
+type Result struct {
+	Row
+	Status        Status
+	Reason        string // skipped、errorの理由
+	TopicID       uint
+	EntityCreated bool // エンティティも新たに作成した
+}
+
+// Summary はインポート結果の件数です。
+type Summary struct {
+	Created, Skipped, Errored int
+}
+
+// Summarizeは結果を状態ごとに数えます。
+func Summarize(results []Result) Summary {
+	var s Summary
+	for _, r := range results {
+		switch r.Status {
+		case StatusCreated:
+			s.Created++
+		case StatusSkipped:
+			s.Skipped++
+		case StatusError:
+			s.Errored++
+		}
+	}
+	return s
+}
+
+// ParseCSVはヘッダー付きのCSVを読み込みます。列はヘッダーの名前で識別し、entityとtypeは必須、topicは省略できます。
+// ヘッダーが不正な場合やCSVとして読めない場合はエラーを返します。各行の値の検証はImportで行います。
+func ParseCSV(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // Excelが付けるBOM
+		}
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"entity", "type"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("csv header must contain %q (got %s)", required, strings.Join(header, ","))
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		rows = append(rows, Row{
+			Line:   line,
+			Entity: field(record, "entity"),
+			Type:   strings.ToLower(field(record, "type")),
+			Topic:  field(record, "topic"),
+		})
+	}
+}
+
+// errDryRun はドライランでトランザクションをロールバックするためのエラーです。
+var errDryRun = errors.New("dry run")
+
+// Importはrowsのエンティティとトピックを登録します。登録済みのトピックとファイル内の重複はスキップし、
+// 不正な行はエラーとして記録して残りの行の登録を続けます。
+// 登録は1つのトランザクションで行い、DBのエラーが発生した場合は何も登録しません。
+// dryRunがtrueの場合は同じ処理を行った上でロールバックするため、実際に登録される結果を事前に確認できます。
+// エンティティとトピックはテナントtenantIDに登録し、テナントのトピック数の上限を超える行はエラーとして記録します。
+// 作成したトピックはactorの操作として監査ログに記録します。
+func Import(ctx context.Context, db *gorm.DB, actor string, tenantID uint, rows []Row, dryRun bool) ([]Result, error) {
+	results := make([]Result, 0, len(rows))
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tenants := repository.NewTenantRepository(tx)
+		tenant, err := tenants.Get(tenantID)
+		if err != nil {
+			return fmt.Errorf("get tenant %d: %w", tenantID, err)
+		}
+		var remaining *int // 作成できるトピックの残りの数。nilなら無制限
+		if tenant.MaxTopics != nil {
+			count, err := tenants.CountTopics(tenant.ID)
+			if err != nil {
+				return err
+			}
+			n := *tenant.MaxTopics - count
+			remaining = &n
+		}
+
+		repo := repository.NewTopicRepository(tx).ForTenant(tenant.ID)
+		audit := repository.NewAuditRepository(tx)
+		seen := map[[3]string]int{}
+		for _, row := range rows {
+			res := Result{Row: row}
+			if res.Topic == "" {
+				res.Topic = res.Entity
+			}
+			key := [3]string{res.Entity, res.Type, res.Topic}
+
+			switch {
+			case res.Entity == "":
+				res.Status, res.Reason = StatusError, "entity is required"
+			case !slices.Contains(model.EntityTypes, res.Type):
+				res.Status, res.Reason = StatusError, fmt.Sprintf("type must be one of %s", strings.Join(model.EntityTypes, ", "))
+			case seen[key] != 0:
+				res.Status, res.Reason = StatusSkipped, fmt.Sprintf("duplicate of line %d", seen[key])
+			default:
+				seen[key] = row.Line
+				if err := importRow(repo, audit, actor, remaining, &res); err != nil {
+					return fmt.Errorf("line %d: %w", row.Line, err)
+				}
+			}
+			results = append(results, res)
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return results, nil
+}
+
+func importRow(repo *repository.TopicRepository, audit *repository.AuditRepository, actor string, remaining *int, res *Result) error {
+	entity, created, err := repo.FindOrCreateEntity(res.Entity, res.Type)
+	if err != nil {
+		return err
+	}
+	res.EntityCreated = created
+
+	existing, err := repo.FindTopic(entity.ID, res.Topic)
+	if err == nil {
+		res.Status, res.Reason, res.TopicID = StatusSkipped, "already exists", existing.ID
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if remaining != nil {
+		if *remaining <= 0 {
+			res.Status, res.Reason = StatusError, "topic quota of the tenant exceeded"
+			return nil
+		}
+		*remaining--
+	}
+
+	topic := model.EntityTopic{EntityID: entity.ID, TenantID: entity.TenantID, Topic: res.Topic}
+	if err := repo.CreateTopic(&topic); err != nil {
+		return err
+	}
+	res.Status, res.TopicID = StatusCreated, topic.ID
+	return audit.Record(actor, model.AuditTopicCreate, "topic", topic.ID, nil, map[string]interface{}{
+		"id": topic.ID, "tenant_id": topic.TenantID, "entity_id": entity.ID, "entity": entity.Name, "entity_type": entity.Type, "topic": topic.Topic,
+	})
+}
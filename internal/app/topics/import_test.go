@@ -0,0 +1,44 @@
+package topics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCSV(t *testing.T) {
+	in := "\ufeffType,Entity,topic\n" +
+		"restaurant, 西日暮里 ,西日暮里 ランチ\n" +
+		"\n" +
+		"ONSEN,草津温泉\n" +
+		"\"brand\",\"Foo, Inc.\",\n"
+	rows, err := ParseCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	want := []Row{
+		{Line: 2, Entity: "西日暮里", Type: "restaurant", Topic: "西日暮里 ランチ"},
+		{Line: 4, Entity: "草津温泉", Type: "onsen"},
+		{Line: 5, Entity: "Foo, Inc.", Type: "brand"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("ParseCSV() =\n%+v\nwant\n%+v", rows, want)
+	}
+}
+
+func TestParseCSVRequiresHeader(t *testing.T) {
+	for _, in := range []string{"", "entity,topic\n西日暮里,西日暮里\n"} {
+		if _, err := ParseCSV(strings.NewReader(in)); err == nil {
+			t.Errorf("ParseCSV(%q) error = nil, want error", in)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	got := Summarize([]Result{
+		{Status: StatusCreated}, {Status: StatusCreated}, {Status: StatusSkipped}, {Status: StatusError},
+	})
+	if want := (Summary{Created: 2, Skipped: 1, Errored: 1}); got != want {
+		t.Errorf("Summarize() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,170 @@
+package topics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+
+	"gorm.io/gorm"
+)
+
+// トピックの作成・変更の失敗
+var (
+	ErrTopicExists   = errors.New("topic already exists")
+	ErrQuotaExceeded = errors.New("topic quota of the tenant exceeded")
+)
+
+// Changes はUpdateで変更するトピックの項目です。nilの項目は変更しません。
+type Changes struct {
+	Topic    *string
+	Priority *int
+	Disabled *bool
+}
+
+// Createはテナントのエンティティにトピックを作成し、actorの操作として監査ログに記録します。
+// エンティティがなければgorm.ErrRecordNotFound、同じトピックがあればErrTopicExists、
+// テナントのトピック数の上限に達していればErrQuotaExceededを返します。
+func Create(ctx context.Context, db *gorm.DB, actor string, tenantID, entityID uint, topic string, priority int) (*model.EntityTopic, error) {
+	var created *model.EntityTopic
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewTopicRepository(tx).ForTenant(tenantID)
+		entity, err := repo.GetEntity(entityID)
+		if err != nil {
+			return err
+		}
+		if _, err := repo.FindTopic(entity.ID, topic); err == nil {
+			return ErrTopicExists
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := checkQuota(repository.NewTenantRepository(tx), entity.TenantID); err != nil {
+			return err
+		}
+
+		created = &model.EntityTopic{EntityID: entity.ID, TenantID: entity.TenantID, Topic: topic, Priority: priority}
+		if err := repo.CreateTopic(created); err != nil {
+			return err
+		}
+		return repository.NewAuditRepository(tx).Record(actor, model.AuditTopicCreate, "topic", created.ID, nil, map[string]interface{}{
+			"id": created.ID, "tenant_id": created.TenantID, "entity_id": entity.ID, "entity": entity.Name, "entity_type": entity.Type,
+			"topic": created.Topic, "priority": created.Priority,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// checkQuotaはテナントのトピック数が上限に達していればErrQuotaExceededを返します。
+func checkQuota(tenants *repository.TenantRepository, tenantID uint) error {
+	tenant, err := tenants.Get(tenantID)
+	if err != nil {
+		return err
+	}
+	if tenant.MaxTopics == nil {
+		return nil
+	}
+	count, err := tenants.CountTopics(tenantID)
+	if err != nil {
+		return err
+	}
+	if count >= *tenant.MaxTopics {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Updateはテナントのエンティティのトピックをchangesのとおりに変更し、変更後のトピックを返します。
+// 変更は項目ごとにCLIと同じ操作(topic.rename、topic.priority、topic.disable・topic.enable)として監査ログに記録し、
+// 値が変わらない項目は記録しません。トピックがなければgorm.ErrRecordNotFound、
+// 変更後のトピックがエンティティの他のトピックと同じであればErrTopicExistsを返します。
+func Update(ctx context.Context, db *gorm.DB, actor string, tenantID, entityID, topicID uint, changes Changes, now time.Time) (*model.EntityTopic, error) {
+	var topic *model.EntityTopic
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewTopicRepository(tx).ForTenant(tenantID)
+		audit := repository.NewAuditRepository(tx)
+		var err error
+		if topic, err = entityTopic(repo, entityID, topicID); err != nil {
+			return err
+		}
+
+		if changes.Topic != nil && *changes.Topic != topic.Topic {
+			if other, err := repo.FindTopic(entityID, *changes.Topic); err == nil && other.ID != topicID {
+				return ErrTopicExists
+			} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			before := auditTopic(topic)
+			if topic, err = repo.RenameTopic(topicID, *changes.Topic); err != nil {
+				return err
+			}
+			if err := audit.Record(actor, model.AuditTopicRename, "topic", topicID, before, auditTopic(topic)); err != nil {
+				return err
+			}
+		}
+		if changes.Priority != nil && *changes.Priority != topic.Priority {
+			before := auditTopic(topic)
+			if topic, err = repo.SetPriority(topicID, *changes.Priority); err != nil {
+				return err
+			}
+			if err := audit.Record(actor, model.AuditTopicPriority, "topic", topicID, before, auditTopic(topic)); err != nil {
+				return err
+			}
+		}
+		if changes.Disabled != nil && *changes.Disabled != (topic.DisabledAt != nil) {
+			before := auditTopic(topic)
+			if topic, err = repo.SetTopicDisabled(topicID, *changes.Disabled, now); err != nil {
+				return err
+			}
+			action := model.AuditTopicEnable
+			if *changes.Disabled {
+				action = model.AuditTopicDisable
+			}
+			if err := audit.Record(actor, action, "topic", topicID, before, auditTopic(topic)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return topic, nil
+}
+
+// Deleteはテナントのエンティティのトピックを削除し、actorの操作として監査ログに記録します。
+// トピックのトレンドや言及も削除されます。トピックがなければgorm.ErrRecordNotFoundを返します。
+func Delete(ctx context.Context, db *gorm.DB, actor string, tenantID, entityID, topicID uint) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewTopicRepository(tx).ForTenant(tenantID)
+		topic, err := entityTopic(repo, entityID, topicID)
+		if err != nil {
+			return err
+		}
+		if err := repo.DeleteTopic(topicID); err != nil {
+			return err
+		}
+		return repository.NewAuditRepository(tx).Record(actor, model.AuditTopicDelete, "topic", topicID, auditTopic(topic), nil)
+	})
+}
+
+// entityTopicはエンティティentityIDのトピックtopicIDを返します。他のエンティティのトピックであればgorm.ErrRecordNotFoundを返します。
+func entityTopic(repo *repository.TopicRepository, entityID, topicID uint) (*model.EntityTopic, error) {
+	topic, err := repo.GetTopic(topicID)
+	if err != nil {
+		return nil, err
+	}
+	if topic.EntityID != entityID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return topic, nil
+}
+
+// auditTopicは監査ログに記録するトピックの項目です。
+func auditTopic(t *model.EntityTopic) map[string]interface{} {
+	return map[string]interface{}{"id": t.ID, "entity_id": t.EntityID, "topic": t.Topic, "priority": t.Priority, "disabled_at": t.DisabledAt}
+}
@@ -0,0 +1,70 @@
+package topics
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/testdb"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
+}
+
+// TestManageはAPIからのトピックの作成・変更・削除が、重複とトピック数の上限を守って監査ログに記録されることを確認します。
+func TestManage(t *testing.T) {
+	db := testdb.Postgres(t)
+	ctx := context.Background()
+	one := 1
+	if err := db.Model(&model.Tenant{}).Where("id = ?", model.DefaultTenantID).Update("max_topics", &one).Error; err != nil {
+		t.Fatal(err)
+	}
+	entity := model.Entity{Name: "草津温泉", Type: model.EntityTypeOnsen}
+	if err := repository.NewTopicRepository(db).CreateEntity(&entity); err != nil {
+		t.Fatal(err)
+	}
+
+	topic, err := Create(ctx, db, "api_key:1", model.DefaultTenantID, entity.ID, "草津 日帰り温泉", 5)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := Create(ctx, db, "api_key:1", model.DefaultTenantID, entity.ID, "草津 日帰り温泉", 0); !errors.Is(err, ErrTopicExists) {
+		t.Errorf("Create() duplicate error = %v, want ErrTopicExists", err)
+	}
+	if _, err := Create(ctx, db, "api_key:1", model.DefaultTenantID, entity.ID, "草津 湯畑", 0); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Create() over quota error = %v, want ErrQuotaExceeded", err)
+	}
+
+	name, disabled := "草津 湯畑", true
+	updated, err := Update(ctx, db, "api_key:1", model.DefaultTenantID, entity.ID, topic.ID, Changes{Topic: &name, Disabled: &disabled}, time.Now())
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.Topic != name || updated.DisabledAt == nil || updated.Priority != 5 {
+		t.Errorf("Update() = %+v, want renamed and disabled with priority kept", updated)
+	}
+	// 他のエンティティのトピックとしては扱わない
+	if _, err := Update(ctx, db, "api_key:1", model.DefaultTenantID, entity.ID+1, topic.ID, Changes{Topic: &name}, time.Now()); err == nil {
+		t.Error("Update() with another entity should fail")
+	}
+	if err := Delete(ctx, db, "api_key:1", model.DefaultTenantID, entity.ID, topic.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	logs, err := repository.NewAuditRepository(db).List(repository.AuditFilter{Actor: "api_key:1", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, l := range logs {
+		actions = append(actions, l.Action)
+	}
+	if len(actions) != 4 {
+		t.Errorf("audit actions = %v, want create, rename, disable and delete", actions)
+	}
+}
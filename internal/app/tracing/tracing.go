@@ -0,0 +1,80 @@
+// Package tracing はOpenTelemetryによる分散トレーシングの設定をまとめたパッケージです。
+// スパンはOTLP(HTTP)でエクスポートされ、APIのハンドラー・外部へのHTTPリクエスト・GORMのクエリを1本のトレースとして追跡できます。
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "excavation_service"
+
+// Setupはトレースのエクスポートを設定し、終了時に呼び出す関数を返します。
+//
+//	OTEL_EXPORTER_OTLP_ENDPOINT (またはOTEL_EXPORTER_OTLP_TRACES_ENDPOINT): OTLPの送信先。未設定ならトレースは記録しない
+//	OTEL_SERVICE_NAME: サービス名 (既定はserviceName)
+//
+// その他のOTEL_*環境変数(ヘッダー、サンプリングなど)もOpenTelemetry SDKの仕様どおりに読み込まれます。
+// 終了時の関数は未送信のスパンを送信するため、プロセスの終了前に必ず呼び出してください。
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 後に指定したものが優先されるため、OTEL_SERVICE_NAMEが設定されていればserviceNameより優先される
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Tracerはこのサービスのスパンを作成するTracerを返します。
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// NewHTTPClientは外部へのリクエストごとにスパンを作成し、トレースコンテキストを伝播するHTTPクライアントを返します。
+// timeoutが0の場合はタイムアウトしません。
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: WrapTransport(http.DefaultTransport),
+	}
+}
+
+// WrapTransportはbaseでのリクエストごとにスパンを作成するRoundTripperを返します。
+func WrapTransport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Host
+		}),
+	)
+}
@@ -0,0 +1,134 @@
+// Package trendstream は発掘で保存された新しいトレンドを、APIサーバーに接続しているクライアントに配信します。
+//
+// trend.createdのイベントはoutboxから渡され(Sink)、PostgreSQLのNOTIFYでAPIサーバーのすべてのプロセスに通知します。
+// NOTIFYはイベントを配信済みとするトランザクションのコミット時に送られるため、どのプロセスのRelayが配信しても、
+// 各プロセスのHubがLISTENで受け取り、購読しているクライアント(GET /api/v1/trends/stream)に渡します。
+package trendstream
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"excavation_service/internal/app/db"
+	"excavation_service/internal/app/model"
+
+	"gorm.io/gorm"
+)
+
+// Channel はトレンドを通知するPostgreSQLのチャネルです。
+const Channel = "excavation_trend_created"
+
+// subscriberBuffer を超えて溜まった通知は、読むのが遅いクライアントには渡さずに捨てる
+const subscriberBuffer = 16
+
+// Event は新しく保存されたトレンドです。Dataはtrend.createdのWebhookと同じ内容のJSONです。
+type Event struct {
+	TenantID uint
+	TrendID  uint
+	Data     json.RawMessage
+}
+
+// parseEventは通知の内容(trend.createdのイベントのJSON)をEventにします。
+func parseEvent(payload string) (Event, error) {
+	var head struct {
+		TenantID uint `json:"tenant_id"`
+		TrendID  uint `json:"trend_id"`
+	}
+	if err := json.Unmarshal([]byte(payload), &head); err != nil {
+		return Event{}, err
+	}
+	return Event{TenantID: head.TenantID, TrendID: head.TrendID, Data: json.RawMessage(payload)}, nil
+}
+
+// Sink はtrend.createdのイベントをNOTIFYで通知するoutboxの配信先です。他のイベントは何もしません。
+type Sink struct{}
+
+func (Sink) Name() string {
+	return "trendstream"
+}
+
+func (Sink) Deliver(ctx context.Context, tx *gorm.DB, event model.OutboxEvent) error {
+	if event.Event != model.EventTrendCreated {
+		return nil
+	}
+	if err := tx.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", Channel, string(event.Payload)).Error; err != nil {
+		return fmt.Errorf("notify %s: %w", Channel, err)
+	}
+	return nil
+}
+
+// Hub はLISTENで受け取ったトレンドを、購読しているクライアントに渡します。
+type Hub struct {
+	mu      sync.Mutex
+	subs    map[chan Event]uint // value: 購読しているテナント
+	stopped bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[chan Event]uint)}
+}
+
+// Subscribeはテナントの新しいトレンドを受け取るチャネルと、購読をやめる関数を返します。
+// Hubが止まるとチャネルは閉じられます。
+func (h *Hub) Subscribe(tenantID uint) (<-chan Event, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan Event, subscriberBuffer)
+	if h.stopped {
+		close(ch)
+		return ch, func() {}
+	}
+	h.subs[ch] = tenantID
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishはeventをテナントの購読者に渡します。バッファがいっぱいの購読者には渡しません。
+func (h *Hub) publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch, tenantID := range h.subs {
+		if tenantID != event.TenantID {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// stopはすべての購読者のチャネルを閉じ、以後の購読をすぐに閉じたチャネルで返すようにします。
+func (h *Hub) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		close(ch)
+	}
+	h.subs = make(map[chan Event]uint)
+	h.stopped = true
+}
+
+// RunはLISTENで受け取ったトレンドを購読者に渡します。接続が切れたら接続し直します。
+// ctxがキャンセルされると購読者のチャネルを閉じて終了します。APIサーバーの終了時に、接続中のクライアントを待たずに済むようにするためです。
+func (h *Hub) Run(ctx context.Context, logger *slog.Logger, sqlDB *sql.DB) {
+	defer h.stop()
+	db.Listen(ctx, logger, sqlDB, Channel, func(payload string) {
+		event, err := parseEvent(payload)
+		if err != nil {
+			logger.Warn("トレンドの通知の内容が不正です", "error", err)
+			return
+		}
+		h.publish(event)
+	})
+}
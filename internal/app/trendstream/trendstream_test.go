@@ -0,0 +1,98 @@
+package trendstream
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/testdb"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testdb.Main(m))
+}
+
+func TestHub(t *testing.T) {
+	h := NewHub()
+	a, unsubscribeA := h.Subscribe(1)
+	b, _ := h.Subscribe(2)
+
+	event, err := parseEvent(`{"trend_id": 10, "tenant_id": 1, "topic": "西日暮里 カレー"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.publish(event)
+	select {
+	case got := <-a:
+		if got.TrendID != 10 || got.TenantID != 1 {
+			t.Errorf("event = %+v", got)
+		}
+	default:
+		t.Fatal("subscriber of tenant 1 did not receive the event")
+	}
+	select {
+	case got := <-b:
+		t.Errorf("subscriber of tenant 2 received %+v", got)
+	default:
+	}
+
+	// 読まない購読者のバッファがいっぱいでもpublishは止まらない
+	for i := 0; i < subscriberBuffer*2; i++ {
+		h.publish(event)
+	}
+	unsubscribeA()
+	unsubscribeA()
+
+	h.stop()
+	if _, ok := <-b; ok {
+		t.Error("channel is open after stop")
+	}
+	c, _ := h.Subscribe(1)
+	if _, ok := <-c; ok {
+		t.Error("Subscribe after stop returned an open channel")
+	}
+}
+
+// TestListenは、Sinkの通知がHubの購読者に届くことを本物のPostgreSQLで確認します。
+func TestListen(t *testing.T) {
+	gormDB := testdb.Postgres(t)
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h := NewHub()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Run(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), sqlDB)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	events, _ := h.Subscribe(3)
+
+	// LISTENするまで通知を送り直す
+	event := model.OutboxEvent{Event: model.EventTrendCreated, Payload: []byte(`{"trend_id": 7, "tenant_id": 3}`)}
+	deadline := time.After(10 * time.Second)
+	for {
+		if err := (Sink{}).Deliver(ctx, gormDB, event); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-events:
+			if got.TrendID != 7 {
+				t.Errorf("event = %+v", got)
+			}
+			return
+		case <-time.After(2 * time.Second):
+		case <-deadline:
+			t.Fatal("no notification received")
+		}
+	}
+}
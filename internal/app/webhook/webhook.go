@@ -0,0 +1,226 @@
+// Package webhook はイベント(トレンドの作成、店舗の発見、実行の失敗、ウォッチ中のトピックでの新しい店舗)を購読しているWebhookに署名付きのJSONを配送します。
+//
+// イベントはoutboxから渡され(Sink)、購読しているWebhookごとに配送としてDBに登録し、APIサーバーで動くDispatcherが配送します。
+// 失敗した配送は間隔を延ばしながら再試行し、結果は配送ログとして残ります。
+//
+// 受信側はX-Excavation-Signatureヘッダーで内容を検証できます。署名は「X-Excavation-Timestampの値 + "." + 本文」の
+// HMAC-SHA256で、"sha256=<16進数>"の形式です。
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"excavation_service/internal/app/model"
+	"excavation_service/internal/app/repository"
+	"excavation_service/internal/app/tracing"
+
+	"gorm.io/gorm"
+)
+
+// 配送のリクエストのヘッダー
+const (
+	SignatureHeader = "X-Excavation-Signature"
+	TimestampHeader = "X-Excavation-Timestamp"
+	EventHeader     = "X-Excavation-Event"
+	DeliveryHeader  = "X-Excavation-Delivery"
+)
+
+const (
+	maxAttempts = 8                // この回数失敗した配送は再試行しない
+	baseBackoff = 30 * time.Second // 1回目の失敗の後の再試行までの間隔。失敗するたびに2倍にする
+	maxBackoff  = 6 * time.Hour
+	claimLease  = 5 * time.Minute // 取り出した配送の結果を保存せずにプロセスが止まった場合に、再び取り出すまでの時間
+	batchSize   = 20
+)
+
+// envelope はWebhookに送るJSONです。
+type envelope struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// NewSecretはWebhookの署名の鍵を生成します。
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sink はoutboxのイベントを、購読しているすべてのWebhookへの配送として登録するoutboxの配信先です。
+// 配送はイベントを配信済みとするのと同じトランザクションで登録するため、同じイベントの配送が重複して登録されることはありません。
+type Sink struct{}
+
+func (Sink) Name() string {
+	return "webhook"
+}
+
+// Deliverはイベントを購読しているすべてのWebhookへの配送を登録します。購読しているWebhookがなければ何もしません。
+func (Sink) Deliver(ctx context.Context, tx *gorm.DB, event model.OutboxEvent) error {
+	repo := repository.NewWebhookRepository(tx).WithContext(ctx)
+	webhooks, err := repo.ListByEvent(event.Event)
+	if err != nil {
+		return fmt.Errorf("list webhooks for %s: %w", event.Event, err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(envelope{Event: event.Event, CreatedAt: event.CreatedAt, Data: event.Payload})
+	if err != nil {
+		return fmt.Errorf("encode %s payload: %w", event.Event, err)
+	}
+	now := time.Now()
+	deliveries := make([]model.WebhookDelivery, len(webhooks))
+	for i, w := range webhooks {
+		deliveries[i] = model.WebhookDelivery{
+			WebhookID:     w.ID,
+			Event:         event.Event,
+			Payload:       payload,
+			Status:        model.DeliveryPending,
+			NextAttemptAt: now,
+		}
+	}
+	return repo.Enqueue(deliveries)
+}
+
+// Signはsecretでtimestampとbodyに署名し、X-Excavation-Signatureヘッダーの値を返します。
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoffはattempts回失敗した配送を再試行するまでの間隔を返します。
+func backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// Dispatcher は配送時刻を過ぎた配送をWebhookに送ります。
+type Dispatcher struct {
+	repo   *repository.WebhookRepository
+	client *http.Client
+}
+
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+	return &Dispatcher{repo: repository.NewWebhookRepository(db), client: tracing.NewHTTPClient(10 * time.Second)}
+}
+
+// Runはintervalごとに配送時刻を過ぎた配送を送ります。ctxがキャンセルされると終了します。
+func (d *Dispatcher) Run(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// 溜まっている配送は続けて送る
+		for {
+			n, err := d.DeliverDue(ctx, logger)
+			if err != nil {
+				logger.Error("Webhookの配送に失敗しました", "error", err)
+			}
+			if err != nil || n < batchSize {
+				break
+			}
+		}
+	}
+}
+
+// DeliverDueは配送時刻を過ぎた配送を最大batchSize件送り、試行した件数を返します。
+func (d *Dispatcher) DeliverDue(ctx context.Context, logger *slog.Logger) (int, error) {
+	repo := d.repo.WithContext(ctx)
+	deliveries, err := repo.ClaimDue(time.Now(), claimLease, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("claim deliveries: %w", err)
+	}
+	webhooks := make(map[uint]*model.Webhook)
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			if webhook, err = repo.Get(delivery.WebhookID); err != nil {
+				return i, fmt.Errorf("get webhook %d: %w", delivery.WebhookID, err)
+			}
+			webhooks[delivery.WebhookID] = webhook
+		}
+
+		d.attempt(ctx, webhook, delivery, time.Now())
+		if delivery.Status != model.DeliverySucceeded {
+			logger.Warn("Webhookへの配送が失敗しました", "webhook_id", webhook.ID, "delivery_id", delivery.ID,
+				"attempts", delivery.Attempts, "status", delivery.Status, "error", delivery.Error)
+		}
+		if err := repo.SaveAttempt(delivery); err != nil {
+			return i, fmt.Errorf("save delivery %d: %w", delivery.ID, err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// attemptは配送を1回試行し、結果をdeliveryに記録します。
+func (d *Dispatcher) attempt(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery, now time.Time) {
+	delivery.Attempts++
+	delivery.UpdatedAt = now
+	status, err := d.post(ctx, webhook, delivery, now)
+	delivery.ResponseStatus = nil
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+	if err == nil {
+		delivery.Status, delivery.Error, delivery.DeliveredAt = model.DeliverySucceeded, "", &now
+		return
+	}
+	delivery.Error = err.Error()
+	if delivery.Attempts >= maxAttempts {
+		delivery.Status = model.DeliveryFailed
+		return
+	}
+	delivery.NextAttemptAt = now.Add(backoff(delivery.Attempts))
+}
+
+// postは配送の内容を署名してWebhookのURLに送り、HTTPステータスを返します。2xx以外はエラーです。
+func (d *Dispatcher) post(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "excavation-webhook/1")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
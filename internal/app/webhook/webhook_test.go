@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"excavation_service/internal/app/model"
+)
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, w := range want {
+		if got := backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+	if got := backoff(20); got != maxBackoff {
+		t.Errorf("backoff(20) = %s, want %s", got, maxBackoff)
+	}
+}
+
+func TestAttempt(t *testing.T) {
+	status := http.StatusOK
+	var gotSignature bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		gotSignature = r.Header.Get(SignatureHeader) == Sign("secret", ts, body) && r.Header.Get(EventHeader) == model.EventTrendCreated
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	d := &Dispatcher{client: srv.Client()}
+	webhook := &model.Webhook{ID: 1, URL: srv.URL, Secret: "secret"}
+	now := time.Now()
+
+	delivery := &model.WebhookDelivery{ID: 10, Event: model.EventTrendCreated, Payload: []byte(`{"event":"trend.created"}`), Status: model.DeliveryPending}
+	d.attempt(context.Background(), webhook, delivery, now)
+	if delivery.Status != model.DeliverySucceeded || delivery.DeliveredAt == nil || *delivery.ResponseStatus != http.StatusOK {
+		t.Errorf("delivery = %+v, want succeeded", delivery)
+	}
+	if !gotSignature {
+		t.Error("request was not signed with the webhook secret")
+	}
+
+	status = http.StatusInternalServerError
+	delivery = &model.WebhookDelivery{ID: 11, Event: model.EventTrendCreated, Payload: []byte(`{}`), Status: model.DeliveryPending}
+	d.attempt(context.Background(), webhook, delivery, now)
+	if delivery.Status != model.DeliveryPending || delivery.Attempts != 1 || !delivery.NextAttemptAt.Equal(now.Add(baseBackoff)) || delivery.Error == "" {
+		t.Errorf("delivery = %+v, want pending retry after %s", delivery, baseBackoff)
+	}
+
+	delivery.Attempts = maxAttempts - 1
+	d.attempt(context.Background(), webhook, delivery, now)
+	if delivery.Status != model.DeliveryFailed {
+		t.Errorf("status = %s after %d attempts, want failed", delivery.Status, delivery.Attempts)
+	}
+}
@@ -0,0 +1,100 @@
+// Package week はトレンドを集計する週の扱いをまとめたパッケージです。週は月曜日始まりです。
+//
+// 週と日の境界はサーバーのタイムゾーンではなく、設定したタイムゾーン(TIMEZONE、既定はAsia/Tokyo)で判定します。
+// UTCのコンテナで動かしても、日本の週が9時間ずれないようにするためです。
+package week
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Layout は週をAPIやログで表す際の日付フォーマットです。週の月曜日の日付で表します。
+const Layout = "2006-01-02"
+
+// DefaultTimezone は週と日の境界に使う既定のタイムゾーンです。
+const DefaultTimezone = "Asia/Tokyo"
+
+var location atomic.Pointer[time.Location]
+
+func init() {
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		// タイムゾーンのデータベースがない環境でも日本時間で判定する
+		loc = time.FixedZone("JST", 9*60*60)
+	}
+	location.Store(loc)
+}
+
+// Locationは週と日の境界に使うタイムゾーンを返します。
+func Location() *time.Location {
+	return location.Load()
+}
+
+// SetLocationは週と日の境界に使うタイムゾーンを設定します。起動時に設定を読み込んだ後に呼び出します。
+func SetLocation(loc *time.Location) {
+	location.Store(loc)
+}
+
+// Ofはtを含む週の月曜日(0時)を返します。週は設定したタイムゾーンで判定します。
+func Of(t time.Time) time.Time {
+	t = Day(t)
+	offset := (int(t.Weekday()) + 6) % 7 // 月曜日からの日数
+	return t.AddDate(0, 0, -offset)
+}
+
+// Currentは今週の月曜日(0時)を返します。
+func Current() time.Time {
+	return Of(time.Now())
+}
+
+// Dayはtを含む日の0時を設定したタイムゾーンで返します。
+func Day(t time.Time) time.Time {
+	t = t.In(Location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// ParseはYYYY-MM-DD形式の日付を解釈し、その日を含む週の月曜日を返します。
+func Parse(s string) (time.Time, error) {
+	t, err := ParseDay(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return Of(t), nil
+}
+
+// ParseDayはYYYY-MM-DD形式の日付を、設定したタイムゾーンのその日の0時として解釈します。
+func ParseDay(s string) (time.Time, error) {
+	return time.ParseInLocation(Layout, s, Location())
+}
+
+// ParseISOはYYYY-WW形式(ISO 8601の年と週番号。YYYY-Wwwも可)の週を解釈し、その週の月曜日を返します。
+// ISO 8601の週も月曜日始まりのため、Ofで求める週と一致します。
+func ParseISO(s string) (time.Time, error) {
+	v := strings.Replace(s, "-W", "-", 1)
+	var year, num int
+	if n, _ := fmt.Sscanf(v, "%4d-%2d", &year, &num); n != 2 || len(v) != len("2006-01") {
+		return time.Time{}, fmt.Errorf("invalid ISO week %q", s)
+	}
+	// 1月4日を含む週が第1週
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, Location())
+	monday := Of(jan4).AddDate(0, 0, 7*(num-1))
+	if y, w := monday.ISOWeek(); num < 1 || y != year || w != num {
+		return time.Time{}, fmt.Errorf("invalid ISO week %q", s)
+	}
+	return monday, nil
+}
+
+// ISOはtを含む週をYYYY-WW形式(ISO 8601の年と週番号)で返します。
+func ISO(t time.Time) string {
+	year, num := t.ISOWeek()
+	return fmt.Sprintf("%04d-%02d", year, num)
+}
+
+// Sameはaとbが同じ週(または日)を表すかを返します。
+// DBのDATE型から読み込んだ値はUTCの0時になるため、時刻ではなくそれぞれのタイムゾーンでの日付を比べます。
+func Same(a, b time.Time) bool {
+	return a.Format(Layout) == b.Format(Layout)
+}
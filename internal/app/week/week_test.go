@@ -0,0 +1,78 @@
+package week
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOf(t *testing.T) {
+	loc := Location()
+	cases := []struct {
+		in   time.Time
+		want time.Time
+	}{
+		{time.Date(2024, 6, 3, 10, 0, 0, 0, loc), time.Date(2024, 6, 3, 0, 0, 0, 0, loc)}, // 月曜日
+		{time.Date(2024, 6, 5, 23, 59, 0, 0, loc), time.Date(2024, 6, 3, 0, 0, 0, 0, loc)},
+		{time.Date(2024, 6, 9, 12, 0, 0, 0, loc), time.Date(2024, 6, 3, 0, 0, 0, 0, loc)}, // 日曜日
+		// UTCでは日曜日でも、日本時間では月曜日
+		{time.Date(2024, 6, 9, 15, 0, 0, 0, time.UTC), time.Date(2024, 6, 10, 0, 0, 0, 0, loc)},
+		{time.Date(2024, 6, 9, 14, 59, 0, 0, time.UTC), time.Date(2024, 6, 3, 0, 0, 0, 0, loc)},
+	}
+	for _, c := range cases {
+		if got := Of(c.in); !got.Equal(c.want) {
+			t.Errorf("Of(%v) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestSetLocation(t *testing.T) {
+	defer SetLocation(Location())
+	SetLocation(time.UTC)
+	in := time.Date(2024, 6, 9, 15, 0, 0, 0, time.UTC)
+	if got, want := Of(in), time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Of(%v) in UTC = %v, want %v", in, got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	got, err := Parse("2024-06-06")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if want := time.Date(2024, 6, 3, 0, 0, 0, 0, Location()); !got.Equal(want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+	if _, err := Parse("2024-W23"); err == nil {
+		t.Error("Parse() with invalid format should fail")
+	}
+	// DATE型から読み込んだUTCの0時の値と同じ週
+	if !Same(got, time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Same(%v, 2024-06-03 UTC) = false", got)
+	}
+}
+
+func TestParseISO(t *testing.T) {
+	loc := Location()
+	cases := map[string]time.Time{
+		"2024-23":  time.Date(2024, 6, 3, 0, 0, 0, 0, loc),
+		"2024-W23": time.Date(2024, 6, 3, 0, 0, 0, 0, loc),
+		// 第1週が前年の12月から始まる年と、53週ある年
+		"2025-01": time.Date(2024, 12, 30, 0, 0, 0, 0, loc),
+		"2020-53": time.Date(2020, 12, 28, 0, 0, 0, 0, loc),
+	}
+	for in, want := range cases {
+		got, err := ParseISO(in)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseISO(%q) = %v, %v; want %v", in, got, err, want)
+		}
+		if s, want := ISO(got), strings.Replace(in, "-W", "-", 1); s != want {
+			t.Errorf("ISO(%v) = %q, want %q", got, s, want)
+		}
+	}
+	for _, in := range []string{"2024-00", "2021-53", "2024-1", "2024-W1", "2024-06-03", "2024-231"} {
+		if _, err := ParseISO(in); err == nil {
+			t.Errorf("ParseISO(%q) should fail", in)
+		}
+	}
+}
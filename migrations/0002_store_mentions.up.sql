@@ -0,0 +1,25 @@
+ALTER TABLE topic_trends ADD COLUMN IF NOT EXISTS gpt_score DOUBLE PRECISION;
+ALTER TABLE topic_trends ADD COLUMN IF NOT EXISTS mention_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE topic_trends ADD COLUMN IF NOT EXISTS top_title TEXT;
+
+CREATE TABLE IF NOT EXISTS stores (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS store_mentions (
+    id SERIAL PRIMARY KEY,
+    store_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    topic_id INTEGER NOT NULL REFERENCES entity_topics(id) ON DELETE CASCADE,
+    week DATE NOT NULL,
+    source_url TEXT NOT NULL,
+    source_type TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (store_id, topic_id, week, source_url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_store_mentions_topic_week ON store_mentions (topic_id, week);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS watches (
+    id SERIAL PRIMARY KEY,
+    topic_id INTEGER NOT NULL REFERENCES entity_topics(id) ON DELETE CASCADE,
+    subscriber TEXT NOT NULL,
+    min_score DOUBLE PRECISION,
+    min_delta DOUBLE PRECISION,
+    last_notified_week DATE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_watches_topic_id ON watches (topic_id);
+CREATE INDEX IF NOT EXISTS idx_watches_subscriber ON watches (subscriber);
@@ -0,0 +1,10 @@
+ALTER TABLE topic_trends ADD COLUMN IF NOT EXISTS delta DOUBLE PRECISION;
+
+-- 既存のトレンドは同じトピックの直前のトレンドとの差分で埋める
+WITH d AS (
+    SELECT id, score - LAG(score) OVER (PARTITION BY topic_id ORDER BY week, id) AS delta
+    FROM topic_trends
+)
+UPDATE topic_trends t SET delta = d.delta FROM d WHERE t.id = d.id AND t.delta IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_topic_trends_week_delta ON topic_trends (week, delta);
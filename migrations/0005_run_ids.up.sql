@@ -0,0 +1,8 @@
+-- 保存したスコアや言及を、それを作成したバッチ実行(ログのrun_id)まで辿れるようにする
+ALTER TABLE topic_trends ADD COLUMN IF NOT EXISTS run_id TEXT;
+ALTER TABLE topic_trends ADD COLUMN IF NOT EXISTS prompt TEXT;
+-- 言及は同じ週に何度見つかっても1件のため、最初に記録した実行のIDが残る
+ALTER TABLE store_mentions ADD COLUMN IF NOT EXISTS run_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_topic_trends_run_id ON topic_trends (run_id);
+CREATE INDEX IF NOT EXISTS idx_store_mentions_run_id ON store_mentions (run_id);
@@ -0,0 +1,2 @@
+-- 無効化したトピックは発掘の対象から外す。トレンドやウォッチは残すため行は削除しない
+ALTER TABLE entity_topics ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
@@ -0,0 +1,19 @@
+-- バッチ処理の実行と進捗。実行中も定期的に更新し、APIから進捗を確認できるようにする
+CREATE TABLE IF NOT EXISTS jobs (
+    id SERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    run_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    topics_total INTEGER NOT NULL DEFAULT 0,
+    topics_done INTEGER NOT NULL DEFAULT 0,
+    topics_failed INTEGER NOT NULL DEFAULT 0,
+    pages_fetched BIGINT NOT NULL DEFAULT 0,
+    estimated_finish_at TIMESTAMPTZ,
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_kind_started_at ON jobs (kind, started_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_run_id ON jobs (run_id);
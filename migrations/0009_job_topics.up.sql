@@ -0,0 +1,16 @@
+-- 発掘の実行ごとのトピックの結果。今週トレンドがない理由をDBに入らずに調べられるようにする
+CREATE TABLE IF NOT EXISTS job_topics (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    topic_id INTEGER NOT NULL,
+    outcome TEXT NOT NULL,
+    trend_id INTEGER,
+    mentions INTEGER NOT NULL DEFAULT 0,
+    top_title TEXT,
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_topics_job_id ON job_topics (job_id);
+CREATE INDEX IF NOT EXISTS idx_job_topics_topic_id ON job_topics (topic_id);
@@ -0,0 +1,10 @@
+-- 週次ダイジェストメールの購読者。tokenは配信停止のリンクに使う
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+    id SERIAL PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    token TEXT NOT NULL UNIQUE,
+    last_sent_week DATE,
+    unsubscribed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+-- 週次ダイジェストをメールの代わりにLINEで受け取る購読者のLINEのユーザーID。空ならメールで送る
+ALTER TABLE digest_subscriptions ADD COLUMN IF NOT EXISTS line_user_id TEXT NOT NULL DEFAULT '';
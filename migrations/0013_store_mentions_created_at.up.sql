@@ -0,0 +1,2 @@
+-- export-rawで1日分の言及を取り出せるようにする
+CREATE INDEX IF NOT EXISTS idx_store_mentions_created_at ON store_mentions (created_at);
@@ -0,0 +1,2 @@
+-- ウォッチ中のトピックで初めて言及された店舗を、スコアの計算を待たずに通知するか
+ALTER TABLE watches ADD COLUMN IF NOT EXISTS notify_new_stores BOOLEAN NOT NULL DEFAULT FALSE;
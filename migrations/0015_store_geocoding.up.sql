@@ -0,0 +1,17 @@
+-- 店舗ページから取得した住所と、住所から変換した緯度・経度。geocoded_atは変換を試みた日時(見つからなかった場合も記録する)
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS address TEXT NOT NULL DEFAULT '';
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS geocoded_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_stores_ungeocoded ON stores (id) WHERE geocoded_at IS NULL;
+
+-- 住所の変換結果のキャッシュ。見つからなかった住所は緯度・経度がNULL
+CREATE TABLE IF NOT EXISTS geocode_cache (
+    provider TEXT NOT NULL,
+    address TEXT NOT NULL,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (provider, address)
+);
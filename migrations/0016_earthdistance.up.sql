@@ -0,0 +1,5 @@
+-- 店舗の近くのトレンドの検索(/api/v1/stores/nearby)に使う。earthdistanceはPostgreSQLの標準の拡張で、cubeに依存する
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+CREATE INDEX IF NOT EXISTS idx_stores_location ON stores USING gist (ll_to_earth(latitude, longitude)) WHERE latitude IS NOT NULL AND longitude IS NOT NULL;
@@ -0,0 +1,11 @@
+-- トピックごと・通知先ごとのトレンドの通知の設定。modeがimmediateならトレンドの保存時に、digestなら実行の終了時にまとめて送る
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    id SERIAL PRIMARY KEY,
+    topic_id INTEGER NOT NULL REFERENCES entity_topics(id) ON DELETE CASCADE,
+    channel TEXT NOT NULL,
+    min_score DOUBLE PRECISION,
+    mode TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (topic_id, channel)
+);
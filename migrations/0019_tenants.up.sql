@@ -0,0 +1,41 @@
+-- テナント(組織)。1つのデプロイで複数の利用者(メディアサイトなど)がそれぞれのトピックを分けて運用するために使う。
+-- 既存のデータはすべて既定のテナント(id 1)に属する
+CREATE TABLE IF NOT EXISTS tenants (
+    id SERIAL PRIMARY KEY,
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    max_topics INTEGER,
+    daily_request_limit INTEGER,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+INSERT INTO tenants (id, slug, name) VALUES (1, 'default', '既定のテナント') ON CONFLICT (id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('tenants', 'id'), GREATEST((SELECT MAX(id) FROM tenants), 1));
+
+ALTER TABLE entities ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE entity_topics ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+CREATE INDEX IF NOT EXISTS idx_entities_tenant_id ON entities (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_entity_topics_tenant_id ON entity_topics (tenant_id);
+
+-- APIキー。キーそのものは保存せず、SHA-256のハッシュで照合する。prefixは一覧でキーを見分けるために残す
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant_id ON api_keys (tenant_id);
+
+-- テナントの日ごとのAPIリクエスト数。daily_request_limitの判定に使う
+CREATE TABLE IF NOT EXISTS tenant_usage (
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    requests INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant_id, day)
+);
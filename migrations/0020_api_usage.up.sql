@@ -0,0 +1,15 @@
+-- APIキーごと・エンドポイントごとの日別の利用量。課金や上限の見直しのために集計する。
+-- api_key_idが0の行はAPIキーなしで既定のテナントとして受け付けたリクエスト
+CREATE TABLE IF NOT EXISTS api_usage (
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    api_key_id INTEGER NOT NULL DEFAULT 0,
+    day DATE NOT NULL,
+    endpoint TEXT NOT NULL,
+    requests INTEGER NOT NULL DEFAULT 0,
+    errors INTEGER NOT NULL DEFAULT 0,
+    request_bytes BIGINT NOT NULL DEFAULT 0,
+    response_bytes BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant_id, api_key_id, day, endpoint)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_usage_day ON api_usage (day);
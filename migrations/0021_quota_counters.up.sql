@@ -0,0 +1,17 @@
+-- 1日・1か月のリクエスト数の上限。テナントとAPIキーのそれぞれに設定でき、未設定(NULL)なら無制限
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS monthly_request_limit INTEGER;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS daily_request_limit INTEGER;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS monthly_request_limit INTEGER;
+
+-- 上限の判定に使うリクエスト数。scopeは"tenant"か"api_key"、periodは"day"か"month"で、period_startは期間の初日
+CREATE TABLE IF NOT EXISTS quota_counters (
+    scope TEXT NOT NULL,
+    scope_id INTEGER NOT NULL,
+    period TEXT NOT NULL,
+    period_start DATE NOT NULL,
+    requests INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (scope, scope_id, period, period_start)
+);
+
+-- テナントの1日のリクエスト数はquota_countersで数える
+DROP TABLE IF EXISTS tenant_usage;
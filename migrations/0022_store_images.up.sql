@@ -0,0 +1,2 @@
+-- 店舗ページのog:imageの画像のURL。埋め込み用のウィジェットに載せる。未取得なら空文字列
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS image_url TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+-- 発掘をやり直しても同じトピック・週のトレンドが増えないよう、トレンドはトピック・週ごとに1件とする。
+-- 既に複数ある場合は最後に保存したものを残し、実行の結果が指すトレンドも残したものに付け替える
+WITH ranked AS (
+    SELECT id, FIRST_VALUE(id) OVER (PARTITION BY topic_id, week ORDER BY id DESC) AS keep_id
+    FROM topic_trends
+)
+UPDATE job_topics j SET trend_id = r.keep_id FROM ranked r WHERE j.trend_id = r.id AND r.id <> r.keep_id;
+
+DELETE FROM topic_trends t
+USING topic_trends newer
+WHERE newer.topic_id = t.topic_id AND newer.week = t.week AND newer.id > t.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_topic_trends_topic_week ON topic_trends (topic_id, week);
@@ -0,0 +1,16 @@
+-- 店舗名の表記の揺れで別の店舗として登録された店舗の組。確からしさが低い組はstatusがpendingのまま人の確認を待つ
+CREATE TABLE IF NOT EXISTS store_links (
+    id SERIAL PRIMARY KEY,
+    store_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    canonical_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    confidence DOUBLE PRECISION NOT NULL,
+    method TEXT NOT NULL,
+    status TEXT NOT NULL,
+    reviewed_by TEXT NOT NULL DEFAULT '',
+    reviewed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (store_id, canonical_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_store_links_status ON store_links (status);
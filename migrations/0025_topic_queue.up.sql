@@ -0,0 +1,14 @@
+-- SQSを使わない小さな環境向けの発掘の依頼のキュー。consumeがSELECT ... FOR UPDATE SKIP LOCKEDで依頼を取得し、
+-- lease_untilまでに処理を終えなかった依頼は再び取得できるようになる。発掘に成功した依頼は削除する
+CREATE TABLE IF NOT EXISTS topic_queue (
+    id BIGSERIAL PRIMARY KEY,
+    body TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    claim_token TEXT NOT NULL DEFAULT '',
+    claimed_by TEXT NOT NULL DEFAULT '',
+    lease_until TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_topic_queue_pending ON topic_queue (id) WHERE status = 'pending';
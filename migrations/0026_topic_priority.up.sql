@@ -0,0 +1,2 @@
+-- トピックの優先度。値が大きいトピックほど先に発掘する。ウォッチや直近の急な変化による加点はクエリで計算する
+ALTER TABLE entity_topics ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- トピックの発掘で使い切ったクロールの予算の種類 (カンマ区切り)。使い切らなかった場合は空
+ALTER TABLE job_topics ADD COLUMN IF NOT EXISTS budget_exhausted TEXT NOT NULL DEFAULT '';
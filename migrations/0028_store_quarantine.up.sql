@@ -0,0 +1,13 @@
+-- 検証に失敗したため保存しなかった店舗の情報。reasonsは失敗した検証のカンマ区切り、dataは検証した情報のJSON
+CREATE TABLE IF NOT EXISTS store_quarantine (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    name TEXT NOT NULL,
+    stage TEXT NOT NULL,
+    reasons TEXT NOT NULL,
+    data TEXT NOT NULL,
+    run_id TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_store_quarantine_created_at ON store_quarantine (created_at);
@@ -0,0 +1,2 @@
+-- トピックの発掘の失敗の分類 (apperr.Code)。店舗の候補が見つからなかった場合はno_results。それ以外は空
+ALTER TABLE job_topics ADD COLUMN IF NOT EXISTS error_code TEXT NOT NULL DEFAULT '';
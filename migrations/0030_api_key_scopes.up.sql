@@ -0,0 +1,2 @@
+-- APIキーで許可する操作 (read: データの取得, write: データの変更)。既存のキーはこれまでどおりすべての操作を許可する
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{read,write}';
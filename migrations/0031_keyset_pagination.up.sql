@@ -0,0 +1,5 @@
+-- 一覧のキーセットページネーション(cursor)で、並び順の(時刻, ID)の索引を降順に読めるようにする
+CREATE INDEX IF NOT EXISTS idx_topic_trends_week_id ON topic_trends (week, id);
+CREATE INDEX IF NOT EXISTS idx_jobs_started_at_id ON jobs (started_at, id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at_id ON audit_logs (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id_created_at_id ON webhook_deliveries (webhook_id, created_at, id);
@@ -0,0 +1,3 @@
+-- 店舗名の検索(GET /api/v1/stores/search)で、部分一致と表記の近い店舗名をトライグラムの索引で探す
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_stores_name_trgm ON stores USING gin (name gin_trgm_ops);
@@ -0,0 +1,3 @@
+-- 発掘の実行で見つかった店舗の数。トピックごとの数と、その実行の合計を記録する。既存の実行は0とする
+ALTER TABLE job_topics ADD COLUMN IF NOT EXISTS stores INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS stores_found INTEGER NOT NULL DEFAULT 0;
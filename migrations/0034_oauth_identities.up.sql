@@ -0,0 +1,15 @@
+-- Googleアカウントでのサインインと、サインイン時にアクセストークンを発行するテナントのAPIキーの紐付け。
+-- 運用者がメールアドレスで紐付け(subjectは空)、初回のサインインでGoogleのユーザーID(subject)を記録する。
+-- 以降はsubjectで照合するため、アカウントのメールアドレスが変わっても同じAPIキーでサインインできる
+CREATE TABLE IF NOT EXISTS oauth_identities (
+    id SERIAL PRIMARY KEY,
+    provider TEXT NOT NULL,
+    email TEXT NOT NULL,
+    subject TEXT,
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    api_key_id INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    last_sign_in_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oauth_identities_provider_email ON oauth_identities (provider, lower(email));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oauth_identities_provider_subject ON oauth_identities (provider, subject) WHERE subject IS NOT NULL;
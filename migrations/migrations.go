@@ -0,0 +1,9 @@
+// Package migrations はDBのマイグレーション用SQLをバイナリに埋め込みます。
+package migrations
+
+import "embed"
+
+// FS は番号順に適用する*.up.sqlファイルです。
+//
+//go:embed *.up.sql
+var FS embed.FS